@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// admissionpolicygen generates CEL ValidatingAdmissionPolicies and their
+// bindings enforcing the OpenAPI validation rules of our CRDs, for clusters
+// that cannot run validating webhooks. The generated bundle is checked in at
+// crd/config/policies.
+package main
+
+//go:generate go run . --crds ../../crd/config/crds --output ../../crd/config/policies/validating-admission-policies.yaml
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/pflag"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	crdDir = pflag.String("crds", "crd/config/crds", "Directory of the CRD manifests.")
+	output = pflag.StringP("output", "o", "", "File to write the policies to. Defaults to stdout.")
+)
+
+func main() {
+	klog.InitFlags(nil)
+	defer klog.Flush()
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine) // this is required to setup klog flags
+	pflag.Parse()
+
+	crds, err := loadCRDs(*crdDir)
+	if err != nil {
+		klog.Exitf("Failed to load the CRDs: %v", err)
+	}
+	data, err := generate(crds)
+	if err != nil {
+		klog.Exitf("Failed to generate the policies: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		klog.Exitf("Failed to create the output directory: %v", err)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		klog.Exitf("Failed to write the policies: %v", err)
+	}
+}
+
+// loadCRDs reads the CRDs of the YAML manifests in dir, sorted by name.
+func loadCRDs(dir string) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var crds []*apiextensionsv1.CustomResourceDefinition
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			if err := decoder.Decode(crd); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+			}
+			if crd.Kind != "CustomResourceDefinition" {
+				continue
+			}
+			crds = append(crds, crd)
+		}
+	}
+	sort.Slice(crds, func(i, j int) bool { return crds[i].Name < crds[j].Name })
+	return crds, nil
+}
+
+// generate returns the policies of the CRDs, followed by their bindings, as a
+// multi-document YAML manifest.
+func generate(crds []*apiextensionsv1.CustomResourceDefinition) ([]byte, error) {
+	var objects []interface{}
+	var bindings []interface{}
+	for _, crd := range crds {
+		crdPolicies, crdBindings := policiesForCRD(crd)
+		for _, policy := range crdPolicies {
+			objects = append(objects, policy)
+		}
+		for _, binding := range crdBindings {
+			bindings = append(bindings, binding)
+		}
+	}
+	objects = append(objects, bindings...)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Code generated by cmd/admissionpolicygen. DO NOT EDIT.\n")
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
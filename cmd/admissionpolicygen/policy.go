@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// skippedRootProperties are not validated by the policies: metadata is
+// validated by the API server and status is written through a subresource.
+var skippedRootProperties = map[string]bool{
+	"apiVersion": true,
+	"kind":       true,
+	"metadata":   true,
+	"status":     true,
+}
+
+// celReservedKeywords must be escaped when used as field names, see
+// https://kubernetes.io/docs/reference/using-api/cel/#escaping.
+var celReservedKeywords = map[string]bool{
+	"true": true, "false": true, "null": true, "in": true, "as": true,
+	"break": true, "const": true, "continue": true, "else": true, "for": true,
+	"function": true, "if": true, "import": true, "let": true, "loop": true,
+	"package": true, "namespace": true, "return": true, "var": true,
+	"void": true, "while": true,
+}
+
+// policiesForCRD returns a ValidatingAdmissionPolicy and its binding for every
+// served version of the CRD.
+func policiesForCRD(crd *apiextensionsv1.CustomResourceDefinition) ([]*admissionregistrationv1alpha1.ValidatingAdmissionPolicy, []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding) {
+	var policies []*admissionregistrationv1alpha1.ValidatingAdmissionPolicy
+	var bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+	for _, version := range crd.Spec.Versions {
+		if !version.Served || version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		validations := schemaValidations(version.Schema.OpenAPIV3Schema, "object", "", 0)
+		if len(validations) == 0 {
+			klog.V(2).Infof("CRD %s version %s has no validation rules, skipping it", crd.Name, version.Name)
+			continue
+		}
+		name := crd.Spec.Names.Plural + "-" + version.Name + "." + crd.Spec.Group
+		failurePolicy := admissionregistrationv1alpha1.Fail
+		policies = append(policies, &admissionregistrationv1alpha1.ValidatingAdmissionPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1alpha1.SchemeGroupVersion.String(), Kind: "ValidatingAdmissionPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicySpec{
+				FailurePolicy: &failurePolicy,
+				MatchConstraints: &admissionregistrationv1alpha1.MatchResources{
+					ResourceRules: []admissionregistrationv1alpha1.NamedRuleWithOperations{{
+						RuleWithOperations: admissionregistrationv1alpha1.RuleWithOperations{
+							Operations: []admissionregistrationv1alpha1.OperationType{admissionregistrationv1alpha1.Create, admissionregistrationv1alpha1.Update},
+							Rule: admissionregistrationv1alpha1.Rule{
+								APIGroups:   []string{crd.Spec.Group},
+								APIVersions: []string{version.Name},
+								Resources:   []string{crd.Spec.Names.Plural},
+							},
+						},
+					}},
+				},
+				Validations: validations,
+			},
+		})
+		bindings = append(bindings, &admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1alpha1.SchemeGroupVersion.String(), Kind: "ValidatingAdmissionPolicyBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName: name,
+			},
+		})
+	}
+	return policies, bindings
+}
+
+// schemaValidations returns the CEL validations enforcing the constraints of
+// the schema on the value of expr, whose field path is path. Array items are
+// bound to the variables i0, i1... according to their nesting depth.
+func schemaValidations(schema *apiextensionsv1.JSONSchemaProps, expr, path string, depth int) []admissionregistrationv1alpha1.Validation {
+	var validations []admissionregistrationv1alpha1.Validation
+	add := func(expression, message string) {
+		validations = append(validations, admissionregistrationv1alpha1.Validation{Expression: expression, Message: message})
+	}
+	field := path
+	if field == "" {
+		field = "object"
+	}
+
+	if len(schema.Enum) > 0 {
+		var values, quoted []string
+		for _, value := range schema.Enum {
+			display := string(value.Raw)
+			if unquoted, err := strconv.Unquote(display); err == nil {
+				display = unquoted
+			}
+			values = append(values, display)
+			quoted = append(quoted, celLiteral(value.Raw))
+		}
+		add(fmt.Sprintf("%s in [%s]", expr, strings.Join(quoted, ", ")), fmt.Sprintf("%s must be one of %s", field, strings.Join(values, ", ")))
+	}
+	if schema.MinLength != nil {
+		add(fmt.Sprintf("size(%s) >= %d", expr, *schema.MinLength), fmt.Sprintf("%s must be at least %d characters long", field, *schema.MinLength))
+	}
+	if schema.MaxLength != nil {
+		add(fmt.Sprintf("size(%s) <= %d", expr, *schema.MaxLength), fmt.Sprintf("%s must be at most %d characters long", field, *schema.MaxLength))
+	}
+	if schema.Pattern != "" {
+		add(fmt.Sprintf("%s.matches(%s)", expr, strconv.Quote(schema.Pattern)), fmt.Sprintf("%s must match %s", field, schema.Pattern))
+	}
+	if schema.Minimum != nil {
+		op := ">="
+		if schema.ExclusiveMinimum {
+			op = ">"
+		}
+		add(fmt.Sprintf("%s %s %s", expr, op, numberLiteral(schema.Type, *schema.Minimum)), fmt.Sprintf("%s must be %s %s", field, op, numberLiteral(schema.Type, *schema.Minimum)))
+	}
+	if schema.Maximum != nil {
+		op := "<="
+		if schema.ExclusiveMaximum {
+			op = "<"
+		}
+		add(fmt.Sprintf("%s %s %s", expr, op, numberLiteral(schema.Type, *schema.Maximum)), fmt.Sprintf("%s must be %s %s", field, op, numberLiteral(schema.Type, *schema.Maximum)))
+	}
+	if schema.MinItems != nil {
+		add(fmt.Sprintf("size(%s) >= %d", expr, *schema.MinItems), fmt.Sprintf("%s must have at least %d items", field, *schema.MinItems))
+	}
+	if schema.MaxItems != nil {
+		add(fmt.Sprintf("size(%s) <= %d", expr, *schema.MaxItems), fmt.Sprintf("%s must have at most %d items", field, *schema.MaxItems))
+	}
+	if len(schema.XValidations) > 0 {
+		klog.Warningf("Ignoring the x-kubernetes-validations rules of %s, rules referring to self cannot be translated", field)
+	}
+
+	if schema.Items != nil && schema.Items.Schema != nil {
+		item := fmt.Sprintf("i%d", depth)
+		for _, v := range schemaValidations(schema.Items.Schema, item, field+"[*]", depth+1) {
+			add(fmt.Sprintf("%s.all(%s, %s)", expr, item, v.Expression), v.Message)
+		}
+	}
+
+	for _, name := range schema.Required {
+		if path == "" && skippedRootProperties[name] {
+			continue
+		}
+		add(fmt.Sprintf("has(%s.%s)", expr, celFieldName(name)), fmt.Sprintf("%s is required", joinPath(path, name)))
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if path == "" && skippedRootProperties[name] {
+			continue
+		}
+		property := schema.Properties[name]
+		propertyExpr := expr + "." + celFieldName(name)
+		// Missing required properties are reported by their own validation.
+		for _, v := range schemaValidations(&property, propertyExpr, joinPath(path, name), depth) {
+			add(fmt.Sprintf("!has(%s) || %s", propertyExpr, v.Expression), v.Message)
+		}
+	}
+	return validations
+}
+
+// celFieldName escapes a property name for field selection in CEL.
+func celFieldName(name string) string {
+	if celReservedKeywords[name] {
+		return "__" + name + "__"
+	}
+	name = strings.ReplaceAll(name, "__", "__underscores__")
+	name = strings.ReplaceAll(name, ".", "__dot__")
+	name = strings.ReplaceAll(name, "-", "__dash__")
+	return strings.ReplaceAll(name, "/", "__slash__")
+}
+
+// celLiteral returns the CEL literal of a JSON value.
+func celLiteral(raw []byte) string {
+	if value, err := strconv.Unquote(string(raw)); err == nil {
+		return strconv.Quote(value)
+	}
+	return string(raw)
+}
+
+// numberLiteral returns the CEL literal of a bound of an integer or number schema.
+func numberLiteral(schemaType string, value float64) string {
+	literal := strconv.FormatFloat(value, 'f', -1, 64)
+	if schemaType == "number" && !strings.ContainsAny(literal, ".e") {
+		literal += ".0"
+	}
+	return literal
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
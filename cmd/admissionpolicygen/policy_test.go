@@ -0,0 +1,212 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const testCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Cluster
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          metadata:
+            type: object
+          spec:
+            type: object
+            required:
+            - type
+            properties:
+              type:
+                type: string
+                enum:
+                - L2
+                - L3
+              namespace:
+                type: string
+                minLength: 1
+              vlanID:
+                type: integer
+                minimum: 1
+                maximum: 4094
+              routes:
+                type: array
+                maxItems: 2
+                items:
+                  type: object
+                  required:
+                  - to
+                  properties:
+                    to:
+                      type: string
+                      pattern: ^[0-9./]+$
+          status:
+            type: object
+            required:
+            - phase
+  - name: v1alpha1
+    served: false
+    storage: false
+    schema:
+      openAPIV3Schema:
+        type: object
+`
+
+func TestPoliciesForCRD(t *testing.T) {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal([]byte(testCRD), crd); err != nil {
+		t.Fatalf("failed to decode the CRD: %v", err)
+	}
+	policies, bindings := policiesForCRD(crd)
+	if len(policies) != 1 || len(bindings) != 1 {
+		t.Fatalf("got %d policies and %d bindings, want one of each for the served version", len(policies), len(bindings))
+	}
+	policy := policies[0]
+	if policy.Name != "widgets-v1.example.com" || bindings[0].Spec.PolicyName != policy.Name {
+		t.Errorf("got policy %q bound by %q, want widgets-v1.example.com", policy.Name, bindings[0].Spec.PolicyName)
+	}
+	rules := policy.Spec.MatchConstraints.ResourceRules
+	if len(rules) != 1 || rules[0].APIGroups[0] != "example.com" || rules[0].APIVersions[0] != "v1" || rules[0].Resources[0] != "widgets" {
+		t.Errorf("got resource rules %+v, want widgets.example.com/v1", rules)
+	}
+
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		t.Fatalf("failed to create the CEL environment: %v", err)
+	}
+	testCases := []struct {
+		desc       string
+		object     string
+		wantFailed []string
+	}{
+		{
+			desc:   "valid",
+			object: `{"spec": {"type": "L2", "namespace": "ns", "vlanID": 10, "routes": [{"to": "10.0.0.0/8"}]}}`,
+		},
+		{
+			desc:   "status is not validated",
+			object: `{"spec": {"type": "L3"}, "status": {}}`,
+		},
+		{
+			desc:       "missing required field",
+			object:     `{"spec": {}}`,
+			wantFailed: []string{"spec.type is required"},
+		},
+		{
+			desc:   "invalid values",
+			object: `{"spec": {"type": "Device", "namespace": "", "vlanID": 4095, "routes": [{"to": "a"}, {}, {"to": "1.1.1.1"}]}}`,
+			wantFailed: []string{
+				"spec.type must be one of L2, L3",
+				"spec.namespace must be at least 1 characters long",
+				"spec.vlanID must be <= 4094",
+				"spec.routes must have at most 2 items",
+				"spec.routes[*].to is required",
+				"spec.routes[*].to must match ^[0-9./]+$",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var object map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.object), &object); err != nil {
+				t.Fatalf("invalid test object: %v", err)
+			}
+			failed := make(map[string]bool)
+			for _, validation := range policy.Spec.Validations {
+				ast, issues := env.Compile(validation.Expression)
+				if issues != nil && issues.Err() != nil {
+					t.Fatalf("failed to compile %q: %v", validation.Expression, issues.Err())
+				}
+				program, err := env.Program(ast)
+				if err != nil {
+					t.Fatalf("failed to create the program of %q: %v", validation.Expression, err)
+				}
+				out, _, err := program.Eval(map[string]interface{}{"object": escapeFieldNames(object)})
+				if err != nil {
+					// Evaluation errors reject the object, like a false result.
+					failed[validation.Message] = true
+					continue
+				}
+				if out.Value() != true {
+					failed[validation.Message] = true
+				}
+			}
+			for _, message := range tc.wantFailed {
+				if !failed[message] {
+					t.Errorf("validation %q passed, want it to fail", message)
+				}
+				delete(failed, message)
+			}
+			for message := range failed {
+				t.Errorf("validation %q failed, want it to pass", message)
+			}
+		})
+	}
+}
+
+// escapeFieldNames escapes the field names of the object like the API server
+// does when evaluating the policies.
+func escapeFieldNames(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		escaped := make(map[string]interface{}, len(value))
+		for name, field := range value {
+			escaped[celFieldName(name)] = escapeFieldNames(field)
+		}
+		return escaped
+	case []interface{}:
+		escaped := make([]interface{}, len(value))
+		for i, item := range value {
+			escaped[i] = escapeFieldNames(item)
+		}
+		return escaped
+	}
+	return value
+}
+
+func TestCELFieldName(t *testing.T) {
+	for name, want := range map[string]string{
+		"vlanID":    "vlanID",
+		"namespace": "__namespace__",
+		"a-b.c/d":   "a__dash__b__dot__c__slash__d",
+		"x__y":      "x__underscores__y",
+	} {
+		if got := celFieldName(name); got != want {
+			t.Errorf("celFieldName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -17,7 +17,13 @@ import (
 	"k8s.io/controller-manager/controller"
 )
 
-const jsonContentType = "application/json"
+const (
+	jsonContentType = "application/json"
+
+	// gkeNetworkParamSetSubnetSyncPeriod is the period at which the
+	// GKENetworkParamSets are synced with the secondary ranges of their subnets.
+	gkeNetworkParamSetSubnetSyncPeriod = 5 * time.Minute
+)
 
 func startGkeNetworkParamSetControllerWrapper(initCtx app.ControllerInitContext, config *cloudcontrollerconfig.CompletedConfig, c cloudprovider.Interface) app.InitFunc {
 	return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
@@ -41,13 +47,14 @@ func startGkeNetworkParamsController(ccmConfig *cloudcontrollerconfig.CompletedC
 		return nil, false, err
 	}
 
-	//no resync, we dont want to automatically update objects if their state changes in gcp
+	//no resync, the controller syncs the subnets of the objects periodically
 	gkeNetworkParamSetInformer := v1alpha1.NewGKENetworkParamSetInformer(networkClient, 0*time.Second, cache.Indexers{})
 
 	gkeNetworkParamsetController := gkenetworkparamsetcontroller.NewGKENetworkParamSetController(
 		networkClient,
 		gkeNetworkParamSetInformer,
 		gceCloud,
+		gkeNetworkParamSetSubnetSyncPeriod,
 	)
 
 	go gkeNetworkParamSetInformer.Run(controllerCtx.Stop)
@@ -20,6 +20,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"math/rand"
 	"os"
 	"time"
@@ -34,9 +35,11 @@ import (
 	"k8s.io/component-base/logs"
 	_ "k8s.io/component-base/metrics/prometheus/clientgo" // load all the prometheus client-go plugins
 	_ "k8s.io/component-base/metrics/prometheus/version"  // for version metric registration
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
 	"k8s.io/klog/v2"
 
-	_ "k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider-gcp/providers/gce"
 )
 
 func main() {
@@ -63,6 +66,34 @@ func main() {
 		Constructor: startGkeNetworkParamSetControllerWrapper,
 	}
 
+	// The network usage controller polls the compute API and is opt-in.
+	controllerInitializers["networkusage"] = app.ControllerInitFuncConstructor{
+		Constructor: startNetworkUsageControllerWrapper,
+	}
+	app.ControllersDisabledByDefault.Insert("networkusage")
+
+	// The multi-network readiness controller watches all pods and is opt-in.
+	controllerInitializers["multinetworkreadiness"] = app.ControllerInitFuncConstructor{
+		Constructor: startMultiNetworkReadinessControllerWrapper,
+	}
+	app.ControllersDisabledByDefault.Insert("multinetworkreadiness")
+
+	// The route garbage collector deletes cloud resources and is opt-in.
+	controllerInitializers["routegc"] = app.ControllerInitFuncConstructor{
+		Constructor: startRouteGCControllerWrapper,
+	}
+	app.ControllersDisabledByDefault.Insert("routegc")
+
+	// The node lifecycle controller also monitors the nodes when the
+	// instance watcher sees instances deleted.
+	nodeLifecycle := controllerInitializers["cloud-node-lifecycle"]
+	nodeLifecycle.Constructor = startNodeLifecycleControllerWrapper
+	controllerInitializers["cloud-node-lifecycle"] = nodeLifecycle
+
+	for name, initializer := range controllerInitializers {
+		controllerInitializers[name] = withComputeMetrics(name, initializer)
+	}
+
 	command := app.NewCloudControllerManagerCommand(ccmOptions, cloudInitializer, controllerInitializers, fss, wait.NeverStop)
 
 	logs.InitLogs()
@@ -73,6 +104,29 @@ func main() {
 	}
 }
 
+// withComputeMetrics attributes the compute API calls of the controller to it
+// in the compute API metrics of the provider. The controller is given a GCE
+// cloud of its own sharing the state of the others, since most cloud methods
+// do not make their calls with the context of the controller.
+func withComputeMetrics(name string, initializer app.ControllerInitFuncConstructor) app.ControllerInitFuncConstructor {
+	constructor := initializer.Constructor
+	initializer.Constructor = func(initContext app.ControllerInitContext, completedConfig *config.CompletedConfig, cloud cloudprovider.Interface) app.InitFunc {
+		if gceCloud, ok := cloud.(*gce.Cloud); ok {
+			controllerCloud, err := gceCloud.ForController(name)
+			if err != nil {
+				klog.Warningf("Failed to create the GCE cloud of controller %s, its compute API calls are not attributed to it: %v", name, err)
+			} else {
+				cloud = controllerCloud
+			}
+		}
+		initFunc := constructor(initContext, completedConfig, cloud)
+		return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+			return initFunc(gce.WithController(ctx, name), controllerCtx)
+		}
+	}
+	return initializer
+}
+
 func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
 	cloudConfig := config.ComponentConfig.KubeCloudShared.CloudProvider
 
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+
+	cloudprovider "k8s.io/cloud-provider"
+	multinetworkreadinesscontroller "k8s.io/cloud-provider-gcp/pkg/controller/multinetworkreadiness"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+)
+
+func startMultiNetworkReadinessControllerWrapper(initCtx app.ControllerInitContext, config *cloudcontrollerconfig.CompletedConfig, c cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		return startMultiNetworkReadinessController(controllerCtx)
+	}
+}
+
+func startMultiNetworkReadinessController(controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+	multiNetworkReadinessController := multinetworkreadinesscontroller.NewMultiNetworkReadinessController(
+		controllerCtx.ClientBuilder.ClientOrDie("multinetworkreadiness-controller"),
+		controllerCtx.InformerFactory.Core().V1().Pods(),
+		controllerCtx.InformerFactory.Core().V1().Nodes(),
+	)
+	go multiNetworkReadinessController.Run(1, controllerCtx.Stop, controllerCtx.ControllerManagerMetrics)
+	return nil, true, nil
+}
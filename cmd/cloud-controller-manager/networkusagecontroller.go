@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cloudprovider "k8s.io/cloud-provider"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	networkusagecontroller "k8s.io/cloud-provider-gcp/pkg/controller/networkusage"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkinformer"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+)
+
+func startNetworkUsageControllerWrapper(initCtx app.ControllerInitContext, config *cloudcontrollerconfig.CompletedConfig, c cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		return startNetworkUsageController(config, controllerCtx, c)
+	}
+}
+
+func startNetworkUsageController(ccmConfig *cloudcontrollerconfig.CompletedConfig, controllerCtx genericcontrollermanager.ControllerContext, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+	gceCloud, ok := cloud.(*gce.Cloud)
+	if !ok {
+		err := fmt.Errorf("NetworkUsageController does not support %v provider", cloud.ProviderName())
+		return nil, false, err
+	}
+
+	kubeConfig := ccmConfig.Complete().Kubeconfig
+	kubeConfig.ContentType = jsonContentType // required to serialize Networks to json
+
+	networkClient, err := networkclientset.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	nwInfFactory := networkinformer.NewSharedInformerFactory(networkClient, 0)
+	nodeInformer := controllerCtx.InformerFactory.Core().V1().Nodes()
+	networkUsageController := networkusagecontroller.NewNetworkUsageController(
+		networkClient,
+		nwInfFactory.Networking().V1().Networks(),
+		nwInfFactory.Networking().V1alpha1().GKENetworkParamSets(),
+		nodeInformer.Lister(),
+		nodeInformer.Informer().HasSynced,
+		gceCloud,
+		networkusagecontroller.DefaultRefreshPeriod,
+	)
+	nwInfFactory.Start(controllerCtx.Stop)
+
+	go networkUsageController.Run(controllerCtx.Stop, controllerCtx.ControllerManagerMetrics)
+	return nil, true, nil
+}
@@ -24,30 +24,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"strings"
-	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
 	cloudprovider "k8s.io/cloud-provider"
 	nodeipamcontrolleroptions "k8s.io/cloud-provider-gcp/cmd/cloud-controller-manager/options"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
 	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
-	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1"
 	nodeipamcontroller "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam"
 	nodeipamconfig "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+	nodeipamconfigscheme "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/scheme"
+	nodeipamconfigv1alpha1 "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/v1alpha1"
 	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/audit"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/checkpoint"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/health"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkinformer"
 	"k8s.io/cloud-provider/app"
 	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
 	genericcontrollermanager "k8s.io/controller-manager/app"
 	"k8s.io/controller-manager/controller"
 	"k8s.io/klog/v2"
 	netutils "k8s.io/utils/net"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	// defaultNodeMaskCIDRIPv4 is default mask size for IPv4 node cidr
-	defaultNodeMaskCIDRIPv4 = 24
+	defaultNodeMaskCIDRIPv4 = nodeipamconfigv1alpha1.DefaultNodeCIDRMaskSizeIPv4
 	// defaultNodeMaskCIDRIPv6 is default mask size for IPv6 node cidr
-	defaultNodeMaskCIDRIPv6 = 64
+	defaultNodeMaskCIDRIPv6 = nodeipamconfigv1alpha1.DefaultNodeCIDRMaskSizeIPv6
 )
 
 type nodeIPAMController struct {
@@ -60,14 +76,23 @@ func (nodeIpamController *nodeIPAMController) startNodeIpamControllerWrapper(ini
 	if len(errors) > 0 {
 		klog.Fatal("NodeIPAM controller values are not properly set.")
 	}
-	nodeIpamController.nodeIPAMControllerOptions.ApplyTo(&nodeIpamController.nodeIPAMControllerConfiguration)
+	cfg, err := nodeIpamController.nodeIPAMControllerOptions.Config()
+	if err != nil {
+		klog.Fatalf("NodeIPAM controller configuration could not be loaded: %v", err)
+	}
 
 	return func(ctx context.Context, controllerContext genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
-		return startNodeIpamController(completedConfig, nodeIpamController.nodeIPAMControllerConfiguration, controllerContext, cloud)
+		return startNodeIpamController(completedConfig, cfg, &nodeIpamController.nodeIPAMControllerOptions, controllerContext, cloud)
 	}
 }
 
-func startNodeIpamController(ccmConfig *cloudcontrollerconfig.CompletedConfig, nodeIPAMConfig nodeipamconfig.NodeIPAMControllerConfiguration, ctx genericcontrollermanager.ControllerContext, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+func startNodeIpamController(ccmConfig *cloudcontrollerconfig.CompletedConfig, cfg *nodeipamconfig.NodeIPAMConfiguration, opts *nodeipamcontrolleroptions.NodeIPAMControllerOptions, ctx genericcontrollermanager.ControllerContext, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+	nodeIPAMConfig := cfg.NodeIPAMController
+	allocatorType := ccmConfig.ComponentConfig.KubeCloudShared.CIDRAllocatorType
+	if cfg.CIDRAllocatorType != "" {
+		allocatorType = cfg.CIDRAllocatorType
+	}
+
 	var serviceCIDR *net.IPNet
 	var secondaryServiceCIDR *net.IPNet
 
@@ -76,6 +101,12 @@ func startNodeIpamController(ccmConfig *cloudcontrollerconfig.CompletedConfig, n
 		return nil, false, fmt.Errorf("the AllocateNodeCIDRs is not enabled")
 	}
 
+	// failure: only the cloud allocator stops and resumes with the pod CIDR
+	// ownership; the range allocator tracks the CIDRs it allocated in memory.
+	if cfg.PodCIDROwnershipLease && ipam.CIDRAllocatorType(allocatorType) != ipam.CloudAllocatorType {
+		return nil, false, fmt.Errorf("PodCIDROwnershipLease is only supported by the %s allocator, not %s", ipam.CloudAllocatorType, allocatorType)
+	}
+
 	// failure: bad cidrs in config
 	clusterCIDRs, dualStack, err := processCIDRs(ccmConfig.ComponentConfig.KubeCloudShared.ClusterCIDR)
 	if err != nil {
@@ -125,35 +156,206 @@ func startNodeIpamController(ccmConfig *cloudcontrollerconfig.CompletedConfig, n
 		return nil, false, err
 	}
 
-	kubeConfig := ccmConfig.Complete().Kubeconfig
-	kubeConfig.ContentType = "application/json" // required to serialize Networks to json
-	networkClient, err := networkclientset.NewForConfig(kubeConfig)
+	// The informers and the writes use separate clients with their own rate
+	// limits, so that the writes are not throttled behind list and watch
+	// requests. Their user agents tell them apart in the API server. The
+	// network clients may run with a dedicated identity, see
+	// networkKubeconfig.
+	clientConnection := cfg.ClientConnection
+	networkConfig, err := networkKubeconfig(ccmConfig.Complete().Kubeconfig, opts.NetworkKubeconfig)
 	if err != nil {
 		return nil, false, err
 	}
-	nwInfFactory := networkinformers.NewSharedInformerFactory(networkClient, 30*time.Second)
+	informerConfig := nodeIPAMClientConfig(networkConfig, "node-ipam-informers", clientConnection.InformerQPS, clientConnection.InformerBurst)
+	informerConfig.ContentType = jsonContentType // required to serialize Networks to json
+	informerNetworkClient, err := networkclientset.NewForConfig(informerConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	writeConfig := nodeIPAMClientConfig(networkConfig, "node-ipam-writer", clientConnection.WriteQPS, clientConnection.WriteBurst)
+	writeConfig.ContentType = jsonContentType
+	networkClient, err := networkclientset.NewForConfig(writeConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	kubeClient, err := clientset.NewForConfig(nodeIPAMClientConfig(ctx.ClientBuilder.ConfigOrDie("node-controller"), "node-controller-writer", clientConnection.WriteQPS, clientConnection.WriteBurst))
+	if err != nil {
+		return nil, false, err
+	}
+	nwInfFactory := networkinformer.NewSharedInformerFactory(informerNetworkClient, cfg.MultiNetwork.ResyncPeriod.Duration)
 	nwInformer := nwInfFactory.Networking().V1().Networks()
 	gnpInformer := nwInfFactory.Networking().V1alpha1().GKENetworkParamSets()
+	cloudAllocatorParams := ipam.CloudAllocatorParams{
+		EnableMultiNetworking:        cfg.MultiNetwork.Enabled,
+		NodeLocalIPAM:                cfg.MultiNetwork.NodeLocalIPAM,
+		ShadowAllocator:              cfg.MultiNetwork.ShadowAllocator,
+		DefaultNetworkName:           cfg.MultiNetwork.DefaultNetworkName,
+		IPv6PrimaryPodCIDR:           len(clusterCIDRs) > 0 && netutils.IsIPv6CIDR(clusterCIDRs[0]),
+		MaxAdditionalNetworks:        int(cfg.MultiNetwork.MaxAdditionalNetworks),
+		NodeCoordinationLeases:       cfg.MultiNetwork.NodeCoordinationLeases,
+		NodeCleanupHooks:             cfg.MultiNetwork.NodeCleanupHooks,
+		PredictiveAllocation:         cfg.MultiNetwork.PredictiveAllocation,
+		NetworkRolloutNodesPerMinute: int(cfg.MultiNetwork.NetworkRolloutNodesPerMinute),
+		ComputeAPIVersion:            cfg.MultiNetwork.ComputeAPIVersion,
+		DisableIPCapacity:            cfg.MultiNetwork.DisableIPCapacity,
+		MaxAliasRangeMaskSize:        int(cfg.MultiNetwork.MaxAliasRangeMaskSize),
+		AnnotationEncoding:           cfg.MultiNetwork.AnnotationEncoding,
+		LegacyAnnotations:            cfg.MultiNetwork.LegacyAnnotations,
+		RequireReadyParams:           cfg.MultiNetwork.RequireReadyParams,
+		ClusterName:                  ccmConfig.ComponentConfig.KubeCloudShared.ClusterName,
+		NetworkClient:                networkClient,
+		NetworkConfig:                informerConfig,
+		StrandedPodCIDRThreshold:     cfg.StrandedPodCIDRThreshold.Duration,
+		PodIPReconciliation:          cfg.PodIPReconciliation,
+		PodInformer:                  ctx.InformerFactory.Core().V1().Pods(),
+		UpdateRetryTimeout:           cfg.Backoff.InitialDelay.Duration,
+		MaxUpdateRetryTimeout:        cfg.Backoff.MaxDelay.Duration,
+		UpdateMaxRetries:             int(cfg.Backoff.MaxRetries),
+		FailureConditionThreshold:    int(cfg.Backoff.FailureConditionThreshold),
+		EventQPS:                     clientConnection.EventQPS,
+		EventBurst:                   int(clientConnection.EventBurst),
+	}
+	if cfg.MultiNetwork.Enabled {
+		// The multi-network reconciler runs on controller-runtime, whose
+		// components log through its global logger.
+		ctrl.SetLogger(klog.NewKlogr())
+	}
+	if cfg.AuditSink != "" {
+		if cloudAllocatorParams.AuditSink, err = audit.New(cfg.AuditSink); err != nil {
+			return nil, false, err
+		}
+	}
+	for _, e := range cfg.PodRangeExemptions {
+		exemption, err := ipam.NewPodRangeExemption(e.LabelSelector, e.TaintKey)
+		if err != nil {
+			return nil, false, err
+		}
+		cloudAllocatorParams.PodRangeExemptions = append(cloudAllocatorParams.PodRangeExemptions, exemption)
+	}
+	if cfg.AllocationCheckpoints || cfg.HealthStatus {
+		dynamicClient, err := dynamic.NewForConfig(writeConfig)
+		if err != nil {
+			return nil, false, err
+		}
+		if cfg.AllocationCheckpoints {
+			cloudAllocatorParams.CheckpointStore = checkpoint.NewStore(dynamicClient)
+		}
+		if cfg.HealthStatus {
+			cloudAllocatorParams.HealthPublisher = health.NewPublisher(dynamicClient)
+		}
+	}
 	nodeIpamController, err := nodeipamcontroller.NewNodeIpamController(
 		ctx.InformerFactory.Core().V1().Nodes(),
 		cloud,
-		ctx.ClientBuilder.ClientOrDie("node-controller"),
+		kubeClient,
 		nwInformer,
 		gnpInformer,
 		clusterCIDRs,
 		serviceCIDR,
 		secondaryServiceCIDR,
 		nodeCIDRMaskSizes,
-		ipam.CIDRAllocatorType(ccmConfig.ComponentConfig.KubeCloudShared.CIDRAllocatorType),
+		ipam.CIDRAllocatorType(allocatorType),
+		cloudAllocatorParams,
 	)
 	if err != nil {
 		return nil, false, err
 	}
+	if opts.DumpEffectiveConfig {
+		params := ipam.CIDRAllocatorParams{
+			ClusterCIDRs:         clusterCIDRs,
+			ServiceCIDR:          serviceCIDR,
+			SecondaryServiceCIDR: secondaryServiceCIDR,
+			NodeCIDRMaskSizes:    nodeCIDRMaskSizes,
+			Cloud:                cloudAllocatorParams,
+		}
+		// The Networks are only listed if the allocator watches them, as
+		// their CRD may not be installed otherwise. The informer must be
+		// registered before the factory starts.
+		var networkInformer networkinformers.NetworkInformer
+		if ipam.CIDRAllocatorType(allocatorType) == ipam.CloudAllocatorType && cfg.MultiNetwork.Enabled {
+			networkInformer = nwInformer
+			networkInformer.Informer()
+		}
+		go dumpEffectiveConfig(os.Stdout, cfg, ipam.CIDRAllocatorType(allocatorType), params, networkInformer, ctx.Stop)
+	}
 	nwInfFactory.Start(ctx.Stop)
-	go nodeIpamController.Run(ctx.Stop, ctx.ControllerManagerMetrics)
+	if !cfg.PodCIDROwnershipLease {
+		go nodeIpamController.Run(ctx.Stop, ctx.ControllerManagerMetrics)
+		return nil, true, nil
+	}
+	// The controller only runs while the allocator owns the pod CIDRs of
+	// the cluster, so that it does not duel with another allocator, and
+	// waits to own them again after it lost them.
+	ownership := ipam.NewPodCIDROwnership(kubeClient, ipam.PodCIDROwnerIdentity(ipam.CIDRAllocatorType(allocatorType)))
+	go func() {
+		for {
+			owned, ok := ownership.Acquire(ctx.Stop)
+			if !ok {
+				return
+			}
+			nodeIpamController.Run(owned, ctx.ControllerManagerMetrics)
+		}
+	}()
 	return nil, true, nil
 }
 
+// dumpEffectiveConfig writes the resolved node IPAM configuration, followed
+// by the summary of the allocator, as YAML documents to w. The summary has a
+// snapshot of the Networks once the informer synced, unless it is nil.
+func dumpEffectiveConfig(w io.Writer, cfg *nodeipamconfig.NodeIPAMConfiguration, allocatorType ipam.CIDRAllocatorType, params ipam.CIDRAllocatorParams, nwInformer networkinformers.NetworkInformer, stop <-chan struct{}) {
+	var networks []*networkv1.Network
+	if nwInformer != nil {
+		if !cache.WaitForNamedCacheSync("node IPAM effective config", stop, nwInformer.Informer().HasSynced) {
+			return
+		}
+		var err error
+		if networks, err = nwInformer.Lister().List(labels.Everything()); err != nil {
+			klog.Errorf("Failed to list the Networks of the node IPAM effective config: %v", err)
+			return
+		}
+	}
+	cfg = cfg.DeepCopy()
+	cfg.CIDRAllocatorType = string(allocatorType)
+	cfgData, err := nodeipamconfigscheme.Encode(cfg)
+	if err != nil {
+		klog.Errorf("Failed to encode the node IPAM effective config: %v", err)
+		return
+	}
+	summaryData, err := yaml.Marshal(ipam.SummarizeAllocator(allocatorType, params, networks))
+	if err != nil {
+		klog.Errorf("Failed to encode the node IPAM allocator summary: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "%s---\n%s", cfgData, summaryData)
+}
+
+// nodeIPAMClientConfig returns a copy of the config with the given user agent
+// and rate limits.
+func nodeIPAMClientConfig(config *restclient.Config, userAgent string, qps float32, burst int32) *restclient.Config {
+	config = restclient.AddUserAgent(restclient.CopyConfig(config), userAgent)
+	config.QPS = qps
+	config.Burst = int(burst)
+	// A rate limiter shared with other clients would override the QPS.
+	config.RateLimiter = nil
+	return config
+}
+
+// networkKubeconfig returns the config the clients of the Network and
+// GKENetworkParamSet objects are built from: the kubeconfig at path if set, so
+// that the RBAC rules of these resources can be granted to a dedicated
+// service account instead of the controller manager, else the given config.
+func networkKubeconfig(config *restclient.Config, path string) (*restclient.Config, error) {
+	if path == "" {
+		return config, nil
+	}
+	networkConfig, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the network kubeconfig %q: %v", path, err)
+	}
+	klog.V(2).Infof("Node IPAM controller using the network kubeconfig %q", path)
+	return networkConfig, nil
+}
+
 // processCIDRs is a helper function that works on a comma separated cidrs and returns
 // a list of typed cidrs
 // a flag if cidrs represents a dual stack
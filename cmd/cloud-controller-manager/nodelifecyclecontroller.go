@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	nodelifecyclecontroller "k8s.io/cloud-provider/controllers/nodelifecycle"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+func startNodeLifecycleControllerWrapper(initCtx app.ControllerInitContext, config *cloudcontrollerconfig.CompletedConfig, c cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		return startNodeLifecycleController(ctx, initCtx, config, controllerCtx, c)
+	}
+}
+
+// startNodeLifecycleController starts the cloud node lifecycle controller of
+// the cloud provider library. Besides its periodic monitoring of the nodes,
+// the nodes are monitored as soon as the instance watcher of the GCE cloud
+// sees instances deleted, so that their nodes are deleted without waiting for
+// the next period.
+func startNodeLifecycleController(ctx context.Context, initCtx app.ControllerInitContext, ccmConfig *cloudcontrollerconfig.CompletedConfig, controllerCtx genericcontrollermanager.ControllerContext, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+	nodeLifecycleController, err := nodelifecyclecontroller.NewCloudNodeLifecycleController(
+		ccmConfig.SharedInformers.Core().V1().Nodes(),
+		// cloud node lifecycle controller uses existing cluster role from node-controller
+		ccmConfig.ClientBuilder.ClientOrDie(initCtx.ClientName),
+		cloud,
+		ccmConfig.ComponentConfig.KubeCloudShared.NodeMonitorPeriod.Duration,
+	)
+	if err != nil {
+		klog.Warningf("failed to start cloud node lifecycle controller: %s", err)
+		return nil, false, nil
+	}
+
+	if gceCloud, ok := cloud.(*gce.Cloud); ok {
+		deleted := make(chan struct{}, 1)
+		gceCloud.AddInstanceChangeHandler(func(change gce.InstanceChange) {
+			if change.Type != gce.InstanceDeleted {
+				return
+			}
+			select {
+			case deleted <- struct{}{}:
+			default:
+			}
+		})
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-deleted:
+					nodeLifecycleController.MonitorNodes(ctx)
+				}
+			}
+		}()
+	}
+
+	go nodeLifecycleController.Run(ctx, controllerCtx.ControllerManagerMetrics)
+	return nil, true, nil
+}
@@ -18,16 +18,28 @@ package options
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/pflag"
 
 	nodeipamconfig "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+	nodeipamconfigscheme "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/scheme"
 )
 
 // NodeIPAMControllerOptions holds the NodeIpamController options.
 type NodeIPAMControllerOptions struct {
 	*nodeipamconfig.NodeIPAMControllerConfiguration
+	// ConfigFile is the path to a NodeIPAMConfiguration file. When set, the
+	// nodeipam flags are ignored.
+	ConfigFile string
+	// NetworkKubeconfig is the path to the kubeconfig file of the clients of
+	// the Network and GKENetworkParamSet objects, empty to use the kubeconfig
+	// of the controller manager. It is used even if ConfigFile is set.
+	NetworkKubeconfig string
+	// DumpEffectiveConfig prints the resolved configuration of the controller
+	// on the standard output at startup.
+	DumpEffectiveConfig bool
 }
 
 // AddFlags adds flags related to NodeIpamController for controller manager to the specified FlagSet.
@@ -39,6 +51,33 @@ func (o *NodeIPAMControllerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.Int32Var(&o.NodeCIDRMaskSize, "node-cidr-mask-size", o.NodeCIDRMaskSize, "Mask size for node cidr in cluster. Default is 24 for IPv4 and 64 for IPv6.")
 	fs.Int32Var(&o.NodeCIDRMaskSizeIPv4, "node-cidr-mask-size-ipv4", o.NodeCIDRMaskSizeIPv4, "Mask size for IPv4 node cidr in dual-stack cluster. Default is 24.")
 	fs.Int32Var(&o.NodeCIDRMaskSizeIPv6, "node-cidr-mask-size-ipv6", o.NodeCIDRMaskSizeIPv6, "Mask size for IPv6 node cidr in dual-stack cluster. Default is 64.")
+	fs.StringVar(&o.ConfigFile, "nodeipam-config", o.ConfigFile, "Path to a NodeIPAMConfiguration file. When set, the other nodeipam flags are ignored.")
+	fs.StringVar(&o.NetworkKubeconfig, "nodeipam-network-kubeconfig", o.NetworkKubeconfig, "Path to a kubeconfig file the node IPAM controller reads and updates Networks and GKENetworkParamSets with, e.g. of a dedicated service account or with a token of a dedicated audience, so that the RBAC rules of these resources do not widen the identity of the controller manager. The kubeconfig of the controller manager is used if empty. Honored even if --nodeipam-config is set.")
+	fs.BoolVar(&o.DumpEffectiveConfig, "nodeipam-dump-effective-config", o.DumpEffectiveConfig, "Print the configuration of the node IPAM controller resolved from its flags or --nodeipam-config, the enabled allocator features, the CIDRs and a snapshot of the Networks on the standard output at startup, for supportability.")
+}
+
+// Config returns the NodeIPAMConfiguration loaded from --nodeipam-config, or
+// the defaulted configuration built from the nodeipam flags if it is not set.
+func (o *NodeIPAMControllerOptions) Config() (*nodeipamconfig.NodeIPAMConfiguration, error) {
+	if o.ConfigFile != "" {
+		data, err := os.ReadFile(o.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nodeipam config file %q: %v", o.ConfigFile, err)
+		}
+		cfg, err := nodeipamconfigscheme.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode nodeipam config file %q: %v", o.ConfigFile, err)
+		}
+		return cfg, nil
+	}
+	cfg, err := nodeipamconfigscheme.Default()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.ApplyTo(&cfg.NodeIPAMController); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 // ApplyTo fills up NodeIpamController config with options.
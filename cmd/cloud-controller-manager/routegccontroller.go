@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cloudprovider "k8s.io/cloud-provider"
+	routegccontroller "k8s.io/cloud-provider-gcp/pkg/controller/routegc"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+)
+
+func startRouteGCControllerWrapper(initCtx app.ControllerInitContext, config *cloudcontrollerconfig.CompletedConfig, c cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, controllerCtx genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		return startRouteGCController(config, controllerCtx, c)
+	}
+}
+
+func startRouteGCController(ccmConfig *cloudcontrollerconfig.CompletedConfig, controllerCtx genericcontrollermanager.ControllerContext, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+	gceCloud, ok := cloud.(*gce.Cloud)
+	if !ok {
+		err := fmt.Errorf("RouteGCController does not support %v provider", cloud.ProviderName())
+		return nil, false, err
+	}
+
+	nodeInformer := controllerCtx.InformerFactory.Core().V1().Nodes()
+	routeGCController := routegccontroller.NewRouteGCController(
+		gceCloud,
+		ccmConfig.ComponentConfig.KubeCloudShared.ClusterName,
+		nodeInformer.Lister(),
+		nodeInformer.Informer().HasSynced,
+		routegccontroller.DefaultCollectPeriod,
+	)
+
+	gceCloud.AddInstanceChangeHandler(routeGCController.InstanceChanged)
+	go routeGCController.Run(controllerCtx.Stop, controllerCtx.ControllerManagerMetrics)
+	return nil, true, nil
+}
@@ -47,9 +47,10 @@ var (
 )
 
 // gkeSigner uses external calls to GKE in order to sign certificate signing
-// requests.
+// requests, or a Cloud KMS key if one is configured.
 type gkeSigner struct {
 	webhook      *webhook.GenericWebhook
+	kms          *kmsSigner
 	ctx          *controllerContext
 	retryBackoff *wait.Backoff
 	validators   []csrValidator
@@ -57,6 +58,17 @@ type gkeSigner struct {
 
 // newGKESigner will create a new instance of a gkeSigner.
 func newGKESigner(ctx *controllerContext) (*gkeSigner, error) {
+	if ctx.clusterSigningKMSKey != "" {
+		kms, err := newKMSSigner(ctx.gcpCfg.CloudKMS, ctx.clusterSigningKMSKey, ctx.clusterSigningKMSCertFile, ctx.clusterSigningDuration)
+		if err != nil {
+			return nil, err
+		}
+		return &gkeSigner{
+			kms:        kms,
+			ctx:        ctx,
+			validators: csrValidators(ctx),
+		}, nil
+	}
 	clientConfig, err := webhook.LoadKubeconfig(ctx.clusterSigningGKEKubeconfig, nil)
 	if err != nil {
 		return nil, err
@@ -135,8 +147,17 @@ func (s *gkeSigner) metricLabel(csr *capi.CertificateSigningRequest, x509cr *x50
 
 // Sign will make an external call to GKE order to sign the given
 // *capi.CertificateSigningRequest, using the gkeSigner's
-// kubeConfigFile.
+// kubeConfigFile, or sign it with the Cloud KMS key.
 func (s *gkeSigner) sign(csr *capi.CertificateSigningRequest) (*capi.CertificateSigningRequest, error) {
+	if s.kms != nil {
+		cert, err := s.kms.sign(csr)
+		if err != nil {
+			s.ctx.recorder.Eventf(csr, "Warning", "SigningError", "error while signing with Cloud KMS: %v", err)
+			return nil, err
+		}
+		csr.Status.Certificate = cert
+		return csr, nil
+	}
 	var statusCode int
 	var result rest.Result
 	webhook.WithExponentialBackoff(context.TODO(), *ClusterSigningGKERetryBackoff, func() error {
@@ -28,6 +28,7 @@ import (
 
 	"cloud.google.com/go/compute/metadata"
 	"golang.org/x/oauth2"
+	cloudkms "google.golang.org/api/cloudkms/v1"
 	betacompute "google.golang.org/api/compute/v0.beta"
 	compute "google.golang.org/api/compute/v1"
 	container "google.golang.org/api/container/v1"
@@ -49,6 +50,7 @@ type gcpConfig struct {
 	Compute               *compute.Service
 	BetaCompute           *betacompute.Service
 	Container             *container.Service
+	CloudKMS              *cloudkms.Service
 }
 
 func getRegionFromLocation(loc string) (string, error) {
@@ -118,6 +120,12 @@ func loadGCPConfig(gceConfigPath, gceAPIEndpointOverride string) (gcpConfig, err
 	if gkeAPIEndpoint != "" {
 		a.Container.BasePath = gkeAPIEndpoint
 	}
+
+	a.CloudKMS, err = cloudkms.New(client)
+	if err != nil {
+		return a, fmt.Errorf("creating Cloud KMS API client: %v", err)
+	}
+	a.CloudKMS.UserAgent = userAgentName
 	a.Container.UserAgent = userAgentName
 
 	// Get cluster zone from metadata server.
@@ -0,0 +1,363 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	capi "k8s.io/api/certificates/v1"
+	"k8s.io/cloud-provider-gcp/pkg/csrmetrics"
+	"k8s.io/klog/v2"
+	certutil "k8s.io/kubernetes/pkg/apis/certificates/v1"
+	"k8s.io/kubernetes/pkg/controller/certificates/authority"
+)
+
+const (
+	// kmsKeyVersionRefreshInterval is how often the enabled versions of the
+	// Cloud KMS key are listed to pick up a rotation of the key.
+	kmsKeyVersionRefreshInterval = 10 * time.Minute
+
+	// kmsSigningBackdate and kmsSigningShortTTL mirror the policy of the
+	// kube-controller-manager local signer.
+	kmsSigningBackdate = 5 * time.Minute
+	kmsSigningShortTTL = 8 * time.Hour
+	// kmsSigningMinTTL is the lower bound of the duration requested in the
+	// CSRs, twice the backdate.
+	kmsSigningMinTTL = 10 * time.Minute
+)
+
+// kmsSigner signs certificate signing requests locally, with a CA whose
+// private key is an asymmetric signing key in Cloud KMS.
+//
+// The key is rotated by adding a version to it and appending the CA
+// certificate of the new version to the certificate bundle. The signer uses
+// the newest enabled version that has a certificate in the bundle, so a new
+// version is only used once its certificate is distributed, and a disabled
+// version is dropped at the next refresh or as soon as KMS rejects a signature
+// with it. The bundle is read again at every refresh, so appending a
+// certificate to it does not require a restart.
+type kmsSigner struct {
+	service    *cloudkms.Service
+	keyName    string
+	caCertFile string
+	certTTL    time.Duration
+	now        func() time.Time
+	interval   time.Duration
+
+	mu          sync.Mutex
+	caCerts     []*x509.Certificate
+	current     *kmsKeyVersion
+	refreshedAt time.Time
+	// publicKeys caches the public keys of the key versions, which never
+	// change.
+	publicKeys map[string]crypto.PublicKey
+}
+
+// kmsKeyVersion is a version of the Cloud KMS key with its CA certificate. It
+// implements crypto.Signer.
+type kmsKeyVersion struct {
+	service *cloudkms.Service
+	name    string
+	cert    *x509.Certificate
+}
+
+// newKMSSigner creates a kmsSigner for the Cloud KMS key keyName, of the form
+// projects/*/locations/*/keyRings/*/cryptoKeys/*, with the CA certificates of
+// its versions read from the PEM bundle at caCertFile.
+func newKMSSigner(service *cloudkms.Service, keyName, caCertFile string, certTTL time.Duration) (*kmsSigner, error) {
+	if service == nil {
+		return nil, fmt.Errorf("no Cloud KMS API client to sign certificates with %q", keyName)
+	}
+	if len(strings.Split(keyName, "/")) != 8 || !strings.HasPrefix(keyName, "projects/") {
+		return nil, fmt.Errorf("invalid Cloud KMS key name %q, want projects/*/locations/*/keyRings/*/cryptoKeys/*", keyName)
+	}
+	caCerts, err := readCACerts(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return &kmsSigner{
+		service:    service,
+		keyName:    keyName,
+		caCertFile: caCertFile,
+		caCerts:    caCerts,
+		certTTL:    certTTL,
+		now:        time.Now,
+		interval:   kmsKeyVersionRefreshInterval,
+		publicKeys: make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+// readCACerts reads the PEM bundle of CA certificates at file.
+func readCACerts(file string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificates: %v", err)
+	}
+	caCerts, err := parseCACerts(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA certificates in %q: %v", file, err)
+	}
+	return caCerts, nil
+}
+
+func parseCACerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+// sign signs the CSR with the current version of the key and returns the PEM
+// encoded certificate. A signature refused by KMS is retried once with the
+// version picked after a refresh, in case the key was rotated.
+func (s *kmsSigner) sign(csr *capi.CertificateSigningRequest) ([]byte, error) {
+	x509cr, err := certutil.ParseCSR(csr.Spec.Request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse csr %q: %v", csr.Name, err)
+	}
+	version, err := s.currentVersion(false)
+	if err != nil {
+		return nil, err
+	}
+	der, err := s.signWith(version, x509cr, csr)
+	if err != nil {
+		klog.Warningf("Signing csr %q with Cloud KMS key version %q failed, refreshing the key versions: %v", csr.Name, version.name, err)
+		retryVersion, refreshErr := s.currentVersion(true)
+		if refreshErr != nil || retryVersion.name == version.name {
+			return nil, err
+		}
+		if der, err = s.signWith(retryVersion, x509cr, csr); err != nil {
+			return nil, err
+		}
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func (s *kmsSigner) signWith(version *kmsKeyVersion, x509cr *x509.CertificateRequest, csr *capi.CertificateSigningRequest) ([]byte, error) {
+	ca := &authority.CertificateAuthority{
+		Certificate: version.cert,
+		PrivateKey:  version,
+	}
+	return ca.Sign(x509cr.Raw, authority.PermissiveSigningPolicy{
+		TTL:      s.duration(csr.Spec.ExpirationSeconds),
+		Usages:   csr.Spec.Usages,
+		Backdate: kmsSigningBackdate,
+		Short:    kmsSigningShortTTL,
+		Now:      s.now,
+	})
+}
+
+// duration returns the TTL of the certificate, honoring the duration
+// requested in the CSR within [kmsSigningMinTTL, certTTL].
+func (s *kmsSigner) duration(expirationSeconds *int32) time.Duration {
+	if expirationSeconds == nil {
+		return s.certTTL
+	}
+	switch requested := time.Duration(*expirationSeconds) * time.Second; {
+	case requested > s.certTTL:
+		return s.certTTL
+	case requested < kmsSigningMinTTL:
+		return kmsSigningMinTTL
+	default:
+		return requested
+	}
+}
+
+// currentVersion returns the version of the key to sign with, refreshing it
+// if forced or if the last refresh is older than the refresh interval. A
+// failed periodic refresh keeps the current version.
+func (s *kmsSigner) currentVersion(force bool) (*kmsKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil && !force && s.now().Sub(s.refreshedAt) < s.interval {
+		return s.current, nil
+	}
+	s.reloadCACerts()
+	version, err := s.newestVersion()
+	if err != nil {
+		if s.current != nil && !force {
+			klog.Errorf("Failed to refresh the versions of Cloud KMS key %q, still signing with %q: %v", s.keyName, s.current.name, err)
+			return s.current, nil
+		}
+		return nil, err
+	}
+	if s.current == nil || s.current.name != version.name {
+		klog.Infof("Signing certificates with Cloud KMS key version %q", version.name)
+	}
+	s.current = version
+	s.refreshedAt = s.now()
+	return version, nil
+}
+
+// reloadCACerts reads the CA certificate bundle again. The certificates
+// already read are kept if the bundle cannot be read.
+func (s *kmsSigner) reloadCACerts() {
+	caCerts, err := readCACerts(s.caCertFile)
+	if err != nil {
+		klog.Errorf("Failed to reload the CA certificates of Cloud KMS key %q, keeping the %d previously read: %v", s.keyName, len(s.caCerts), err)
+		return
+	}
+	s.caCerts = caCerts
+}
+
+// newestVersion lists the enabled versions of the key and returns the newest
+// one with a CA certificate in the bundle.
+func (s *kmsSigner) newestVersion() (*kmsKeyVersion, error) {
+	var names []string
+	recordMetric := csrmetrics.OutboundRPCStartRecorder("kms.CryptoKeyVersions.List")
+	err := s.service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.List(s.keyName).Filter("state=ENABLED").Pages(context.TODO(), func(resp *cloudkms.ListCryptoKeyVersionsResponse) error {
+		for _, v := range resp.CryptoKeyVersions {
+			names = append(names, v.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		recordMetric(csrmetrics.OutboundRPCStatusError)
+		return nil, fmt.Errorf("listing the versions of Cloud KMS key %q: %v", s.keyName, err)
+	}
+	recordMetric(csrmetrics.OutboundRPCStatusOK)
+
+	var newest *kmsKeyVersion
+	var newestID int64
+	for _, name := range names {
+		id, err := strconv.ParseInt(path.Base(name), 10, 64)
+		if err != nil {
+			klog.Warningf("Ignoring Cloud KMS key version %q with a non-numeric ID", name)
+			continue
+		}
+		if newest != nil && id <= newestID {
+			continue
+		}
+		publicKey, err := s.publicKey(name)
+		if err != nil {
+			return nil, err
+		}
+		cert := s.caCert(publicKey)
+		if cert == nil {
+			klog.Warningf("Ignoring Cloud KMS key version %q without a CA certificate", name)
+			continue
+		}
+		newest = &kmsKeyVersion{service: s.service, name: name, cert: cert}
+		newestID = id
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no enabled version of Cloud KMS key %q has a CA certificate", s.keyName)
+	}
+	return newest, nil
+}
+
+// publicKey returns the public key of the key version. Only the PKCS#1 v1.5
+// RSA and the ECDSA algorithms are supported, as they are the ones chosen by
+// x509.CreateCertificate for RSA and EC keys.
+func (s *kmsSigner) publicKey(name string) (crypto.PublicKey, error) {
+	if key, ok := s.publicKeys[name]; ok {
+		return key, nil
+	}
+	recordMetric := csrmetrics.OutboundRPCStartRecorder("kms.CryptoKeyVersions.GetPublicKey")
+	resp, err := s.service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.GetPublicKey(name).Do()
+	if err != nil {
+		recordMetric(csrmetrics.OutboundRPCStatusError)
+		return nil, fmt.Errorf("getting the public key of Cloud KMS key version %q: %v", name, err)
+	}
+	recordMetric(csrmetrics.OutboundRPCStatusOK)
+	if !strings.HasPrefix(resp.Algorithm, "RSA_SIGN_PKCS1_") && !strings.HasPrefix(resp.Algorithm, "EC_SIGN_P") {
+		return nil, fmt.Errorf("unsupported algorithm %q of Cloud KMS key version %q", resp.Algorithm, name)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM public key for Cloud KMS key version %q", name)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the public key of Cloud KMS key version %q: %v", name, err)
+	}
+	s.publicKeys[name] = key
+	return key, nil
+}
+
+// caCert returns the certificate of the bundle for the public key, if any.
+func (s *kmsSigner) caCert(publicKey crypto.PublicKey) *x509.Certificate {
+	key, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil
+	}
+	for _, cert := range s.caCerts {
+		if key.Equal(cert.PublicKey) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// Public implements crypto.Signer.
+func (v *kmsKeyVersion) Public() crypto.PublicKey {
+	return v.cert.PublicKey
+}
+
+// Sign implements crypto.Signer by signing the digest with the key version in
+// Cloud KMS.
+func (v *kmsKeyVersion) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	encoded := base64.StdEncoding.EncodeToString(digest)
+	var d cloudkms.Digest
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		d.Sha256 = encoded
+	case crypto.SHA384:
+		d.Sha384 = encoded
+	case crypto.SHA512:
+		d.Sha512 = encoded
+	default:
+		return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+	}
+	recordMetric := csrmetrics.KMSSigningStartRecorder(path.Base(v.name))
+	resp, err := v.service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.AsymmetricSign(v.name, &cloudkms.AsymmetricSignRequest{Digest: &d}).Do()
+	if err != nil {
+		recordMetric(csrmetrics.OutboundRPCStatusError)
+		return nil, fmt.Errorf("signing with Cloud KMS key version %q: %v", v.name, err)
+	}
+	recordMetric(csrmetrics.OutboundRPCStatusOK)
+	return base64.StdEncoding.DecodeString(resp.Signature)
+}
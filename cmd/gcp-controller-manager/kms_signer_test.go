@@ -0,0 +1,334 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+	capi "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+const testKMSKey = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+
+// fakeKMS serves the Cloud KMS API calls of the kmsSigner for the ECDSA
+// versions of testKMSKey.
+type fakeKMS struct {
+	mu       sync.Mutex
+	keys     map[string]*ecdsa.PrivateKey
+	enabled  map[string]bool
+	signedBy []string
+}
+
+func newFakeKMS(t *testing.T, versions ...string) *fakeKMS {
+	f := &fakeKMS{
+		keys:    make(map[string]*ecdsa.PrivateKey),
+		enabled: make(map[string]bool),
+	}
+	for _, v := range versions {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("error generating key: %v", err)
+		}
+		name := testKMSKey + "/cryptoKeyVersions/" + v
+		f.keys[name] = key
+		f.enabled[name] = true
+	}
+	return f
+}
+
+func (f *fakeKMS) setEnabled(version string, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled[testKMSKey+"/cryptoKeyVersions/"+version] = enabled
+}
+
+func (f *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case strings.HasSuffix(name, "/cryptoKeyVersions"):
+		resp := &cloudkms.ListCryptoKeyVersionsResponse{}
+		for v, enabled := range f.enabled {
+			if enabled {
+				resp.CryptoKeyVersions = append(resp.CryptoKeyVersions, &cloudkms.CryptoKeyVersion{Name: v, State: "ENABLED"})
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case strings.HasSuffix(name, "/publicKey"):
+		key := f.keys[strings.TrimSuffix(name, "/publicKey")]
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(&cloudkms.PublicKey{
+			Algorithm: "EC_SIGN_P256_SHA256",
+			Pem:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		})
+	case strings.HasSuffix(name, ":asymmetricSign"):
+		version := strings.TrimSuffix(name, ":asymmetricSign")
+		if !f.enabled[version] {
+			http.Error(w, "key version is disabled", http.StatusBadRequest)
+			return
+		}
+		var req cloudkms.AsymmetricSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		digest, _ := base64.StdEncoding.DecodeString(req.Digest.Sha256)
+		sig, err := ecdsa.SignASN1(rand.Reader, f.keys[version], digest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.signedBy = append(f.signedBy, version)
+		json.NewEncoder(w).Encode(&cloudkms.AsymmetricSignResponse{Signature: base64.StdEncoding.EncodeToString(sig)})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// caCertFile writes the self-signed CA certificates of the versions to a PEM
+// bundle.
+func (f *fakeKMS) caCertFile(t *testing.T, versions ...string) string {
+	var bundle []byte
+	for i, v := range versions {
+		key := f.keys[testKMSKey+"/cryptoKeyVersions/"+v]
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: "kms-ca-" + v},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatalf("error creating CA certificate: %v", err)
+		}
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	file := filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(file, bundle, 0600); err != nil {
+		t.Fatalf("error writing CA certificates: %v", err)
+	}
+	return file
+}
+
+func newTestKMSService(t *testing.T, f *fakeKMS) *cloudkms.Service {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	service, err := cloudkms.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("error creating Cloud KMS client: %v", err)
+	}
+	return service
+}
+
+func kmsTestCSR(name string) *capi.CertificateSigningRequest {
+	return &capi.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: capi.CertificateSigningRequestSpec{
+			Request:    generateCSR(),
+			SignerName: capi.KubeAPIServerClientKubeletSignerName,
+			Usages:     []capi.KeyUsage{capi.UsageDigitalSignature, capi.UsageClientAuth},
+		},
+		Status: statusApproved,
+	}
+}
+
+// issuer returns the common name of the issuer of the PEM certificate.
+func issuer(t *testing.T, certPEM []byte) string {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("no PEM certificate in %q", certPEM)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	return cert.Issuer.CommonName
+}
+
+func TestKMSSigner(t *testing.T) {
+	f := newFakeKMS(t, "1", "2", "3")
+	csr := kmsTestCSR("kms-csr")
+	ctlCtx := &controllerContext{
+		client:                    fake.NewSimpleClientset(csr),
+		recorder:                  record.NewFakeRecorder(10),
+		gcpCfg:                    gcpConfig{CloudKMS: newTestKMSService(t, f)},
+		clusterSigningKMSKey:      testKMSKey,
+		clusterSigningKMSCertFile: f.caCertFile(t, "1", "2"),
+		clusterSigningDuration:    time.Hour,
+	}
+	signer, err := newGKESigner(ctlCtx)
+	if err != nil {
+		t.Fatalf("error creating GKESigner: %v", err)
+	}
+
+	// Version 3 has no CA certificate yet: version 2 is the newest usable.
+	processed, csrOut, err := signer.handleInternal(csr)
+	if err != nil || !processed {
+		t.Fatalf("handleInternal() = %v, %v, want processed", processed, err)
+	}
+	if got := issuer(t, csrOut.Status.Certificate); got != "kms-ca-2" {
+		t.Errorf("certificate issued by %q, want kms-ca-2", got)
+	}
+
+	// Disabling version 2 makes the signer refresh and fall back to version 1.
+	f.setEnabled("2", false)
+	processed, csrOut, err = signer.handleInternal(kmsTestCSR("kms-csr"))
+	if err != nil || !processed {
+		t.Fatalf("handleInternal() = %v, %v, want processed", processed, err)
+	}
+	if got := issuer(t, csrOut.Status.Certificate); got != "kms-ca-1" {
+		t.Errorf("certificate issued by %q after disabling version 2, want kms-ca-1", got)
+	}
+	if len(f.signedBy) != 2 {
+		t.Errorf("got %d KMS signatures, want 2", len(f.signedBy))
+	}
+}
+
+func TestKMSSignerRotation(t *testing.T) {
+	f := newFakeKMS(t, "1", "2")
+	signer, err := newKMSSigner(newTestKMSService(t, f), testKMSKey, f.caCertFile(t, "1", "2"), time.Hour)
+	if err != nil {
+		t.Fatalf("error creating kmsSigner: %v", err)
+	}
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+
+	f.setEnabled("2", false)
+	if _, err := signer.sign(kmsTestCSR("before-rotation")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	f.setEnabled("2", true)
+
+	// The new version is only picked up after the refresh interval.
+	if _, err := signer.sign(kmsTestCSR("cached")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	now = now.Add(kmsKeyVersionRefreshInterval)
+	if _, err := signer.sign(kmsTestCSR("after-rotation")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	want := []string{
+		testKMSKey + "/cryptoKeyVersions/1",
+		testKMSKey + "/cryptoKeyVersions/1",
+		testKMSKey + "/cryptoKeyVersions/2",
+	}
+	if strings.Join(f.signedBy, ",") != strings.Join(want, ",") {
+		t.Errorf("signed by %v, want %v", f.signedBy, want)
+	}
+}
+
+func TestKMSSignerReloadsCACerts(t *testing.T) {
+	f := newFakeKMS(t, "1", "2")
+	certFile := f.caCertFile(t, "1")
+	signer, err := newKMSSigner(newTestKMSService(t, f), testKMSKey, certFile, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating kmsSigner: %v", err)
+	}
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+
+	if _, err := signer.sign(kmsTestCSR("before-distribution")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	// Distribute the certificate of version 2, then break the bundle: the
+	// certificates read last are kept.
+	bundle, err := ioutil.ReadFile(f.caCertFile(t, "1", "2"))
+	if err != nil {
+		t.Fatalf("error reading CA certificates: %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, bundle, 0600); err != nil {
+		t.Fatalf("error writing CA certificates: %v", err)
+	}
+	now = now.Add(kmsKeyVersionRefreshInterval)
+	if _, err := signer.sign(kmsTestCSR("after-distribution")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, nil, 0600); err != nil {
+		t.Fatalf("error writing CA certificates: %v", err)
+	}
+	now = now.Add(kmsKeyVersionRefreshInterval)
+	if _, err := signer.sign(kmsTestCSR("broken-bundle")); err != nil {
+		t.Fatalf("sign() = %v", err)
+	}
+	want := []string{
+		testKMSKey + "/cryptoKeyVersions/1",
+		testKMSKey + "/cryptoKeyVersions/2",
+		testKMSKey + "/cryptoKeyVersions/2",
+	}
+	if strings.Join(f.signedBy, ",") != strings.Join(want, ",") {
+		t.Errorf("signed by %v, want %v", f.signedBy, want)
+	}
+}
+
+func TestNewKMSSignerErrors(t *testing.T) {
+	f := newFakeKMS(t, "1")
+	service := newTestKMSService(t, f)
+	certFile := f.caCertFile(t, "1")
+	emptyFile := filepath.Join(t.TempDir(), "empty.crt")
+	if err := ioutil.WriteFile(emptyFile, nil, 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	cases := []struct {
+		name     string
+		service  *cloudkms.Service
+		keyName  string
+		certFile string
+	}{
+		{name: "no client", keyName: testKMSKey, certFile: certFile},
+		{name: "invalid key name", service: service, keyName: "projects/p/keyRings/r", certFile: certFile},
+		{name: "missing certificates", service: service, keyName: testKMSKey, certFile: filepath.Join(os.TempDir(), "does-not-exist.crt")},
+		{name: "no certificate", service: service, keyName: testKMSKey, certFile: emptyFile},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := newKMSSigner(c.service, c.keyName, c.certFile, time.Hour); err == nil {
+				t.Errorf("newKMSSigner() = nil error, want error")
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"sort"
+	"time"
 
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
@@ -32,6 +33,9 @@ type controllerContext struct {
 	recorder                               record.EventRecorder
 	gcpCfg                                 gcpConfig
 	clusterSigningGKEKubeconfig            string
+	clusterSigningKMSKey                   string
+	clusterSigningKMSCertFile              string
+	clusterSigningDuration                 time.Duration
 	csrApproverVerifyClusterMembership     bool
 	csrApproverAllowLegacyKubelet          bool
 	csrApproverUseGCEInstanceListReferrers bool
@@ -40,6 +44,7 @@ type controllerContext struct {
 	hmsSyncNodeURL                         string
 	delayDirectPathGSARemove               bool
 	clearStalePodsOnNodeRegistration       bool
+	nodePoolApprovalPolicies               *nodePoolApprovalPolicies
 }
 
 // loops returns all the control loops that the GCPControllerManager can start.
@@ -100,6 +105,7 @@ func loops() map[string]func(context.Context, *controllerContext) error {
 				controllerCtx.client,
 				controllerCtx.sharedInformers.Core().V1().Nodes(),
 				controllerCtx.gcpCfg.Compute,
+				*nodeReservationLabels,
 			)
 			if err != nil {
 				return err
@@ -66,6 +66,9 @@ var (
 	metricsPort                            = pflag.Int("metrics-port", 8089, "Deprecated. Port to expose Prometheus metrics on. If not set, uses the value of --port.")
 	kubeconfig                             = pflag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information.")
 	clusterSigningGKEKubeconfig            = pflag.String("cluster-signing-gke-kubeconfig", "", "If set, use the kubeconfig file to call GKE to sign cluster-scoped certificates instead of using a local private key.")
+	clusterSigningKMSKey                   = pflag.String("cluster-signing-kms-key", "", "If set, sign cluster-scoped certificates locally with the newest enabled version of this Cloud KMS key, of the form projects/*/locations/*/keyRings/*/cryptoKeys/*, instead of calling GKE.")
+	clusterSigningKMSCertFile              = pflag.String("cluster-signing-kms-cert-file", "", "Path to the PEM bundle with the CA certificates of the versions of --cluster-signing-kms-key. The bundle is read again whenever the key versions are refreshed, and a new key version is used once its certificate is in the bundle.")
+	clusterSigningDuration                 = pflag.Duration("cluster-signing-duration", 365*24*time.Hour, "The max length of duration of the certificates signed with --cluster-signing-kms-key.")
 	gceConfigPath                          = pflag.String("gce-config", "/etc/gce.conf", "Path to gce.conf.")
 	controllers                            = pflag.StringSlice("controllers", []string{"*"}, "Controllers to enable. Possible controllers are: "+strings.Join(loopNames(), ",")+".")
 	csrApproverVerifyClusterMembership     = pflag.Bool("csr-validate-cluster-membership", true, "Validate that VMs requesting CSRs belong to current GKE cluster.")
@@ -79,6 +82,8 @@ var (
 	kubeletReadOnlyCSRApprover             = pflag.Bool("kubelet-read-only-csr-approver", false, "Enable kubelet readonly csr approver or not")
 	autopilotEnabled                       = pflag.Bool("autopilot", false, "Is this a GKE Autopilot cluster.")
 	clearStalePodsOnNodeRegistration       = pflag.Bool("clearStalePodsOnNodeRegistration", false, "If true, after node registration, delete pods bound to old node.")
+	csrNodePoolPoliciesConfig              = pflag.String("csr-node-pool-policies-config", "", "Path to a YAML file with the approval policies (AutoApprove, Deny or RequireAttestation) of the node CSRs per node pool, matched by instance name prefix or GCE instance labels.")
+	nodeReservationLabels                  = pflag.Bool("node-reservation-labels", false, "If true, the node annotator labels nodes with the reservation affinity and committed-use coverage of their instance.")
 )
 
 func main() {
@@ -104,6 +109,9 @@ func main() {
 
 	s := &controllerManager{
 		clusterSigningGKEKubeconfig:            *clusterSigningGKEKubeconfig,
+		clusterSigningKMSKey:                   *clusterSigningKMSKey,
+		clusterSigningKMSCertFile:              *clusterSigningKMSCertFile,
+		clusterSigningDuration:                 *clusterSigningDuration,
 		gceConfigPath:                          *gceConfigPath,
 		gceAPIEndpointOverride:                 *gceAPIEndpointOverride,
 		controllers:                            *controllers,
@@ -119,6 +127,9 @@ func main() {
 		autopilotEnabled:                       *autopilotEnabled,
 		clearStalePodsOnNodeRegistration:       *clearStalePodsOnNodeRegistration,
 	}
+	if s.clusterSigningKMSKey != "" && s.clusterSigningGKEKubeconfig != "" {
+		klog.Exitf("--cluster-signing-kms-key and --cluster-signing-gke-kubeconfig are mutually exclusive")
+	}
 	var err error
 	s.informerKubeconfig, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
@@ -137,6 +148,12 @@ func main() {
 	if err != nil {
 		klog.Exitf("failed loading GCP config: %v", err)
 	}
+	if *csrNodePoolPoliciesConfig != "" {
+		s.nodePoolApprovalPolicies, err = loadNodePoolApprovalPolicies(*csrNodePoolPoliciesConfig)
+		if err != nil {
+			klog.Exitf("failed loading node pool approval policies: %v", err)
+		}
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -165,6 +182,9 @@ func main() {
 type controllerManager struct {
 	// Fields initialized from flags.
 	clusterSigningGKEKubeconfig            string
+	clusterSigningKMSKey                   string
+	clusterSigningKMSCertFile              string
+	clusterSigningDuration                 time.Duration
 	gceConfigPath                          string
 	gceAPIEndpointOverride                 string
 	controllers                            []string
@@ -182,10 +202,11 @@ type controllerManager struct {
 	kubeletReadOnlyCSRApprover bool
 
 	// Fields initialized from other sources.
-	gcpConfig            gcpConfig
-	informerKubeconfig   *restclient.Config
-	controllerKubeconfig *restclient.Config
-	healthz              *healthz.Handler
+	gcpConfig                gcpConfig
+	nodePoolApprovalPolicies *nodePoolApprovalPolicies
+	informerKubeconfig       *restclient.Config
+	controllerKubeconfig     *restclient.Config
+	healthz                  *healthz.Handler
 }
 
 func (s *controllerManager) isEnabled(name string) bool {
@@ -241,6 +262,9 @@ func run(s *controllerManager) error {
 				}),
 				gcpCfg:                                 s.gcpConfig,
 				clusterSigningGKEKubeconfig:            s.clusterSigningGKEKubeconfig,
+				clusterSigningKMSKey:                   s.clusterSigningKMSKey,
+				clusterSigningKMSCertFile:              s.clusterSigningKMSCertFile,
+				clusterSigningDuration:                 s.clusterSigningDuration,
 				csrApproverVerifyClusterMembership:     s.csrApproverVerifyClusterMembership,
 				csrApproverAllowLegacyKubelet:          s.csrApproverAllowLegacyKubelet,
 				csrApproverUseGCEInstanceListReferrers: s.csrApproverUseGCEInstanceListReferrers,
@@ -249,6 +273,7 @@ func run(s *controllerManager) error {
 				hmsSyncNodeURL:                         s.hmsSyncNodeURL,
 				delayDirectPathGSARemove:               s.delayDirectPathGSARemove,
 				clearStalePodsOnNodeRegistration:       s.clearStalePodsOnNodeRegistration,
+				nodePoolApprovalPolicies:               s.nodePoolApprovalPolicies,
 			}); err != nil {
 				klog.Fatalf("Failed to start %q: %v", name, err)
 			}
@@ -62,7 +62,7 @@ type nodeAnnotator struct {
 	getInstance func(nodeURL string) (*compute.Instance, error)
 }
 
-func newNodeAnnotator(client clientset.Interface, nodeInformer coreinformers.NodeInformer, cs *compute.Service) (*nodeAnnotator, error) {
+func newNodeAnnotator(client clientset.Interface, nodeInformer coreinformers.NodeInformer, cs *compute.Service, reservationLabels bool) (*nodeAnnotator, error) {
 	gce := compute.NewInstancesService(cs)
 
 	// TODO(mikedanese): create a registry for the labels that GKE uses. This was
@@ -143,6 +143,12 @@ func newNodeAnnotator(client clientset.Interface, nodeInformer coreinformers.Nod
 					return true
 				},
 			},
+			{
+				// Runs after the labels reconciler, which drops the node pool
+				// label when kube-labels does not set it.
+				name:     "nodepool-reconciler",
+				annotate: reconcileNodePoolLabels,
+			},
 			{
 				name: "taints-reconciler",
 				annotate: func(node *core.Node, instance *compute.Instance) bool {
@@ -169,6 +175,12 @@ func newNodeAnnotator(client clientset.Interface, nodeInformer coreinformers.Nod
 			},
 		},
 	}
+	if reservationLabels {
+		na.annotators = append(na.annotators, annotator{
+			name:     "reservation-reconciler",
+			annotate: newReservationLabeler(cs).reconcile,
+		})
+	}
 	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    na.add,
 		UpdateFunc: na.update,
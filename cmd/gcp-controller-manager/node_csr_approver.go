@@ -102,6 +102,7 @@ func csrValidators(ctx *controllerContext) []csrValidator {
 			validate:      validateTPMAttestation,
 			permission:    authorization.ResourceAttributes{Group: "certificates.k8s.io", Resource: "certificatesigningrequests", Verb: "create", Subresource: "nodeclient"},
 			approveMsg:    "Auto approving kubelet client certificate with TPM attestation after SubjectAccessReview.",
+			tpmAttested:   true,
 
 			preApproveHook: ensureNodeMatchesMetadataOrDelete,
 		},
@@ -150,6 +151,17 @@ func (a *nodeApprover) handle(ctx context.Context, csr *capi.CertificateSigningR
 			continue
 		}
 		klog.Infof("validator %q: matched CSR %q", r.name, csr.Name)
+		rule, err := a.ctx.nodePoolApprovalPolicies.ruleFor(a.ctx, x509cr)
+		if err != nil {
+			return fmt.Errorf("matching CSR %q to the node pool approval policies: %v", csr.Name, err)
+		}
+		if rule != nil {
+			if msg := rule.deniedBy(r, isNodeClientCert(csr, x509cr)); msg != "" {
+				klog.Infof("validator %q: CSR %q denied by node pool rule %q", r.name, csr.Name, rule.Name)
+				recordValidatorMetric(csrmetrics.ApprovalStatusDeny)
+				return a.updateCSR(csr, false, msg)
+			}
+		}
 		if r.validate != nil {
 			ok, err := r.validate(a.ctx, csr, x509cr)
 			if err != nil {
@@ -233,6 +245,9 @@ type csrValidator struct {
 	authFlowLabel string
 	approveMsg    string
 	denyMsg       string
+	// tpmAttested is true if the validator verifies the TPM attestation of
+	// the node, as required by nodePoolPolicyRequireAttestation.
+	tpmAttested bool
 
 	// recognize is a required field that returns true if this csrValidator is
 	// applicable to given CSR.
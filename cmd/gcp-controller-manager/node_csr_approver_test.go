@@ -1067,6 +1067,7 @@ func fakeGCPAPI(t *testing.T, ekPub *rsa.PublicKey) (*http.Client, *httptest.Ser
 				Id:                2,
 				Name:              "i1",
 				Zone:              formatInstanceZone("p0", "z0"),
+				Labels:            map[string]string{"goog-k8s-node-pool-name": "secure-pool"},
 				NetworkInterfaces: []*compute.NetworkInterface{{NetworkIP: "1.2.3.5"}},
 			})
 		case "/compute/v1/projects/2/zones/z0/instances/i0":
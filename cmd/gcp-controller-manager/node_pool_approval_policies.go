@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// nodePoolApprovalPolicy is the way the node approver handles the CSRs of the
+// nodes of a node pool.
+type nodePoolApprovalPolicy string
+
+const (
+	// nodePoolPolicyAutoApprove approves the CSRs passing the validators, as
+	// for the nodes matching no rule.
+	nodePoolPolicyAutoApprove nodePoolApprovalPolicy = "AutoApprove"
+	// nodePoolPolicyDeny denies all the CSRs of the nodes.
+	nodePoolPolicyDeny nodePoolApprovalPolicy = "Deny"
+	// nodePoolPolicyRequireAttestation denies the client CSRs of the nodes
+	// without a TPM attestation, such as the ones of the legacy kubelet
+	// bootstrap flow.
+	nodePoolPolicyRequireAttestation nodePoolApprovalPolicy = "RequireAttestation"
+)
+
+// nodePoolApprovalRule applies a policy to the nodes whose name starts with
+// the instance prefix and whose GCE instance has all the labels. At least one
+// of them is set.
+type nodePoolApprovalRule struct {
+	// Name identifies the rule in logs and CSR conditions, e.g. the name of
+	// the node pool.
+	Name           string                 `json:"name"`
+	InstancePrefix string                 `json:"instancePrefix,omitempty"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+	Policy         nodePoolApprovalPolicy `json:"policy"`
+}
+
+// nodePoolApprovalPolicies is the content of the file set by
+// --csr-node-pool-policies-config. The first rule matching the node of a CSR
+// applies. For example:
+//
+//	rules:
+//	- name: payments
+//	  labels:
+//	    goog-k8s-node-pool-name: payments
+//	  policy: RequireAttestation
+//	- name: quarantine
+//	  instancePrefix: gke-cluster-quarantine-
+//	  policy: Deny
+type nodePoolApprovalPolicies struct {
+	Rules []nodePoolApprovalRule `json:"rules"`
+}
+
+// loadNodePoolApprovalPolicies reads and validates the node pool approval
+// policies in the file at path.
+func loadNodePoolApprovalPolicies(path string) (*nodePoolApprovalPolicies, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policies := &nodePoolApprovalPolicies{}
+	if err := yaml.UnmarshalStrict(data, policies); err != nil {
+		return nil, fmt.Errorf("parsing %q: %v", path, err)
+	}
+	for i, rule := range policies.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: no name", i)
+		}
+		if rule.InstancePrefix == "" && len(rule.Labels) == 0 {
+			return nil, fmt.Errorf("rule %q: neither instancePrefix nor labels is set", rule.Name)
+		}
+		switch rule.Policy {
+		case nodePoolPolicyAutoApprove, nodePoolPolicyDeny, nodePoolPolicyRequireAttestation:
+		default:
+			return nil, fmt.Errorf("rule %q: invalid policy %q, want one of %s, %s or %s", rule.Name, rule.Policy, nodePoolPolicyAutoApprove, nodePoolPolicyDeny, nodePoolPolicyRequireAttestation)
+		}
+	}
+	return policies, nil
+}
+
+// needsInstance returns true if a rule matches on the labels of the GCE
+// instance.
+func (p *nodePoolApprovalPolicies) needsInstance() bool {
+	for _, rule := range p.Rules {
+		if len(rule.Labels) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFor returns the first rule matching the node of the CSR, or nil if there
+// is none. The GCE instance of the node is only looked up for the rules with
+// labels; an instance not found matches none of them.
+func (p *nodePoolApprovalPolicies) ruleFor(ctx *controllerContext, x509cr *x509.CertificateRequest) (*nodePoolApprovalRule, error) {
+	if p == nil || !strings.HasPrefix(x509cr.Subject.CommonName, "system:node:") {
+		return nil, nil
+	}
+	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
+	var instanceLabels map[string]string
+	if p.needsInstance() {
+		instance, err := getInstanceByName(ctx, nodeName)
+		switch {
+		case errors.Is(err, errInstanceNotFound):
+			klog.Warningf("No instance found for node %q, skipping the node pool approval rules with labels", nodeName)
+		case err != nil:
+			return nil, fmt.Errorf("getting the instance of node %q: %v", nodeName, err)
+		default:
+			instanceLabels = instance.Labels
+		}
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.InstancePrefix != "" && !strings.HasPrefix(nodeName, rule.InstancePrefix) {
+			continue
+		}
+		if !hasLabels(instanceLabels, rule.Labels) {
+			continue
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
+
+func hasLabels(labels, want map[string]string) bool {
+	for key, value := range want {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// deniedBy returns the message denying the CSR recognized by the validator
+// under the policy of the rule, or the empty string if the policy lets the
+// validator decide.
+func (rule *nodePoolApprovalRule) deniedBy(v csrValidator, clientCert bool) string {
+	switch rule.Policy {
+	case nodePoolPolicyDeny:
+		return fmt.Sprintf("Denied by the %s approval policy of node pool rule %q.", rule.Policy, rule.Name)
+	case nodePoolPolicyRequireAttestation:
+		if clientCert && !v.tpmAttested {
+			return fmt.Sprintf("Denied by the %s approval policy of node pool rule %q: the CSR has no TPM attestation.", rule.Policy, rule.Name)
+		}
+	}
+	return ""
+}
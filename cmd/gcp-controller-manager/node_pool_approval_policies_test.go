@@ -0,0 +1,190 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	authorization "k8s.io/api/authorization/v1"
+	capi "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	testclient "k8s.io/client-go/testing"
+)
+
+func writePoliciesFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("error writing policies: %v", err)
+	}
+	return file
+}
+
+func TestLoadNodePoolApprovalPolicies(t *testing.T) {
+	file := writePoliciesFile(t, `
+rules:
+- name: secure
+  labels:
+    goog-k8s-node-pool-name: secure-pool
+  policy: RequireAttestation
+- name: quarantine
+  instancePrefix: gke-c-quarantine-
+  policy: Deny
+`)
+	got, err := loadNodePoolApprovalPolicies(file)
+	if err != nil {
+		t.Fatalf("loadNodePoolApprovalPolicies() = %v", err)
+	}
+	want := &nodePoolApprovalPolicies{Rules: []nodePoolApprovalRule{
+		{Name: "secure", Labels: map[string]string{"goog-k8s-node-pool-name": "secure-pool"}, Policy: nodePoolPolicyRequireAttestation},
+		{Name: "quarantine", InstancePrefix: "gke-c-quarantine-", Policy: nodePoolPolicyDeny},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("loadNodePoolApprovalPolicies() returned unexpected diff (-want +got):\n%s", diff)
+	}
+
+	for desc, content := range map[string]string{
+		"no name":        "rules:\n- instancePrefix: a\n  policy: Deny\n",
+		"no selector":    "rules:\n- name: a\n  policy: Deny\n",
+		"invalid policy": "rules:\n- name: a\n  instancePrefix: a\n  policy: Allow\n",
+		"unknown field":  "rules:\n- name: a\n  instancePrefix: a\n  policy: Deny\n  pool: a\n",
+	} {
+		t.Run(desc, func(t *testing.T) {
+			if _, err := loadNodePoolApprovalPolicies(writePoliciesFile(t, content)); err == nil {
+				t.Errorf("loadNodePoolApprovalPolicies() = nil error, want error")
+			}
+		})
+	}
+}
+
+func TestNodeApproverNodePoolPolicies(t *testing.T) {
+	policies := &nodePoolApprovalPolicies{Rules: []nodePoolApprovalRule{
+		{Name: "quarantine", InstancePrefix: "gke-c-quarantine-", Policy: nodePoolPolicyDeny},
+		{Name: "secure", Labels: map[string]string{"goog-k8s-node-pool-name": "secure-pool"}, Policy: nodePoolPolicyRequireAttestation},
+		{Name: "default", InstancePrefix: "gke-c-", Policy: nodePoolPolicyAutoApprove},
+	}}
+	cases := []struct {
+		desc        string
+		nodeName    string
+		tpmAttested bool
+		serverCert  bool
+		wantType    capi.RequestConditionType
+	}{
+		{
+			desc:     "no matching rule",
+			nodeName: "other-node",
+			wantType: capi.CertificateApproved,
+		},
+		{
+			desc:     "auto approve",
+			nodeName: "gke-c-default-1",
+			wantType: capi.CertificateApproved,
+		},
+		{
+			desc:        "deny",
+			nodeName:    "gke-c-quarantine-1",
+			tpmAttested: true,
+			wantType:    capi.CertificateDenied,
+		},
+		{
+			desc:        "require attestation with attestation",
+			nodeName:    "i1",
+			tpmAttested: true,
+			wantType:    capi.CertificateApproved,
+		},
+		{
+			desc:     "require attestation without attestation",
+			nodeName: "i1",
+			wantType: capi.CertificateDenied,
+		},
+		{
+			desc:       "require attestation for server certificate",
+			nodeName:   "i1",
+			serverCert: true,
+			wantType:   capi.CertificateApproved,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpClient, srv := fakeGCPAPI(t, nil)
+			defer srv.Close()
+			cs, err := compute.New(httpClient)
+			if err != nil {
+				t.Fatalf("creating GCE API client: %v", err)
+			}
+			client := &fake.Clientset{}
+			client.AddReactor("create", "subjectaccessreviews", func(action testclient.Action) (handled bool, ret runtime.Object, err error) {
+				return true, &authorization.SubjectAccessReview{
+					Status: authorization.SubjectAccessReviewStatus{Allowed: true},
+				}, nil
+			})
+			ctx := &controllerContext{client: client, nodePoolApprovalPolicies: policies}
+			ctx.gcpCfg.Compute = cs
+			ctx.gcpCfg.ProjectID = "p0"
+			ctx.gcpCfg.Zones = []string{"z0"}
+			approver := nodeApprover{
+				ctx: ctx,
+				validators: []csrValidator{{
+					name:        "test",
+					approveMsg:  "tester",
+					tpmAttested: c.tpmAttested,
+					recognize: func(_ *capi.CertificateSigningRequest, _ *x509.CertificateRequest) bool {
+						return true
+					},
+				}},
+			}
+
+			pk, err := ecdsa.GenerateKey(elliptic.P224(), insecureRand)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := csrBuilder{
+				cn:         "system:node:" + c.nodeName,
+				orgs:       []string{"system:nodes"},
+				signerName: capi.KubeAPIServerClientKubeletSignerName,
+				usages:     kubeletClientUsages,
+				key:        pk,
+			}
+			if c.serverCert {
+				b.signerName = capi.KubeletServingSignerName
+				b.usages = kubeletServerUsages
+			}
+			if err := approver.handle(context.TODO(), makeFancyTestCSR(t, b)); err != nil {
+				t.Fatalf("handle() = %v", err)
+			}
+
+			var conditions []capi.CertificateSigningRequestCondition
+			for _, a := range client.Actions() {
+				if u, ok := a.(testclient.UpdateActionImpl); ok {
+					conditions = u.Object.(*capi.CertificateSigningRequest).Status.Conditions
+				}
+			}
+			if len(conditions) != 1 || conditions[0].Type != c.wantType {
+				t.Errorf("got conditions %+v, want a %s condition", conditions, c.wantType)
+			}
+		})
+	}
+}
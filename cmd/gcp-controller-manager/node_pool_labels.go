@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path"
+
+	compute "google.golang.org/api/compute/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// nodePoolLabelKey is the node label holding the name of the GKE node pool.
+	// It is normally set from the kube-labels metadata by the labels reconciler.
+	nodePoolLabelKey = "cloud.google.com/gke-nodepool"
+
+	// nodePoolInstanceLabelKey is the GCE label set by GKE on the instances of
+	// a node pool.
+	nodePoolInstanceLabelKey = "goog-k8s-node-pool-name"
+	// instanceTemplateMetadataKey is the metadata set by managed instance
+	// groups on their instances, e.g.
+	// projects/123/global/instanceTemplates/gke-cluster-pool-1234.
+	instanceTemplateMetadataKey = "instance-template"
+)
+
+// reconcileNodePoolLabels sets the node pool and instance template labels of
+// nodes whose kubelet and kube-labels metadata do not provide them, so that
+// node pools can be targeted by scheduling and multi-network policies. The
+// node pool label is never overwritten, as kube-labels remains the source of
+// truth when it is set.
+func reconcileNodePoolLabels(node *core.Node, instance *compute.Instance) bool {
+	desired := make(map[string]string)
+	if _, ok := node.Labels[nodePoolLabelKey]; !ok {
+		if pool := instance.Labels[nodePoolInstanceLabelKey]; pool != "" {
+			desired[nodePoolLabelKey] = pool
+		}
+	}
+	if template := instanceMetadata(instance, instanceTemplateMetadataKey); template != "" {
+		desired[wellknown.InstanceTemplateLabelKey] = path.Base(template)
+	}
+
+	modified := false
+	for key, value := range desired {
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			klog.Warningf("Not setting label %s=%q on node %s: %v", key, value, node.Name, errs)
+			continue
+		}
+		if node.Labels[key] == value {
+			continue
+		}
+		if node.Labels == nil {
+			node.Labels = make(map[string]string)
+		}
+		node.Labels[key] = value
+		modified = true
+	}
+	return modified
+}
+
+// instanceMetadata returns the value of the metadata item of the instance, or
+// the empty string if it is not set.
+func instanceMetadata(instance *compute.Instance, key string) string {
+	if instance.Metadata == nil {
+		return ""
+	}
+	for _, item := range instance.Metadata.Items {
+		if item != nil && item.Key == key && item.Value != nil {
+			return *item.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	core "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileNodePoolLabels(t *testing.T) {
+	template := "projects/123/global/instanceTemplates/gke-cluster-pool-1-abcd"
+	longTemplate := "projects/123/global/instanceTemplates/" + strings.Repeat("a", 64)
+	cases := map[string]struct {
+		labels       map[string]string
+		instance     *compute.Instance
+		wantLabels   map[string]string
+		wantModified bool
+	}{
+		"no metadata or labels": {
+			instance: &compute.Instance{},
+		},
+		"node pool and template are set": {
+			instance: &compute.Instance{
+				Labels:   map[string]string{"goog-k8s-node-pool-name": "pool-1"},
+				Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: "instance-template", Value: &template}}},
+			},
+			wantLabels: map[string]string{
+				"cloud.google.com/gke-nodepool": "pool-1",
+				"node.gke.io/instance-template": "gke-cluster-pool-1-abcd",
+			},
+			wantModified: true,
+		},
+		"node pool from kube-labels is kept": {
+			labels: map[string]string{"cloud.google.com/gke-nodepool": "default-pool"},
+			instance: &compute.Instance{
+				Labels: map[string]string{"goog-k8s-node-pool-name": "pool-1"},
+			},
+			wantLabels: map[string]string{"cloud.google.com/gke-nodepool": "default-pool"},
+		},
+		"template is updated": {
+			labels: map[string]string{"node.gke.io/instance-template": "gke-cluster-pool-1-old"},
+			instance: &compute.Instance{
+				Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: "instance-template", Value: &template}}},
+			},
+			wantLabels:   map[string]string{"node.gke.io/instance-template": "gke-cluster-pool-1-abcd"},
+			wantModified: true,
+		},
+		"invalid label values are skipped": {
+			instance: &compute.Instance{
+				Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: "instance-template", Value: &longTemplate}}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			node := &core.Node{ObjectMeta: v1.ObjectMeta{Name: "node", Labels: c.labels}}
+			if modified := reconcileNodePoolLabels(node, c.instance); modified != c.wantModified {
+				t.Errorf("reconcileNodePoolLabels() = %v, want %v", modified, c.wantModified)
+			}
+			if diff := cmp.Diff(c.wantLabels, node.Labels); diff != "" {
+				t.Errorf("reconcileNodePoolLabels() unexpected labels (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
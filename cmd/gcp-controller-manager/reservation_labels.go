@@ -0,0 +1,208 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// specificReservationKey is the reservation affinity key selecting a
+	// reservation by name.
+	specificReservationKey = "compute.googleapis.com/reservation-name"
+
+	// commitmentsCacheTTL is how long the commitments of a region are cached.
+	commitmentsCacheTTL = 10 * time.Minute
+)
+
+// reservationAffinities maps the reservation affinity types of the compute API
+// to the values of wellknown.ReservationAffinityLabelKey.
+var reservationAffinities = map[string]string{
+	"ANY_RESERVATION":      "any",
+	"SPECIFIC_RESERVATION": "specific",
+	"NO_RESERVATION":       "none",
+}
+
+// commitmentTypes maps machine series to the type of the commitments covering
+// them.
+var commitmentTypes = map[string]string{
+	"n1":  "GENERAL_PURPOSE",
+	"e2":  "GENERAL_PURPOSE_E2",
+	"n2":  "GENERAL_PURPOSE_N2",
+	"n2d": "GENERAL_PURPOSE_N2D",
+	"t2d": "GENERAL_PURPOSE_T2D",
+	"c2":  "COMPUTE_OPTIMIZED",
+	"c2d": "COMPUTE_OPTIMIZED_C2D",
+	"m1":  "MEMORY_OPTIMIZED",
+	"m2":  "MEMORY_OPTIMIZED",
+	"a2":  "ACCELERATOR_OPTIMIZED",
+}
+
+// reservationLabeler labels nodes with the reservation affinity and the
+// committed-use coverage of their instance, so that schedulers and autoscalers
+// can prefer covered capacity.
+type reservationLabeler struct {
+	listCommitments func(project, region string) ([]*compute.Commitment, error)
+	now             func() time.Time
+
+	lock        sync.Mutex
+	commitments map[string]commitmentsCacheEntry
+}
+
+type commitmentsCacheEntry struct {
+	commitments []*compute.Commitment
+	fetched     time.Time
+}
+
+func newReservationLabeler(cs *compute.Service) *reservationLabeler {
+	commitments := compute.NewRegionCommitmentsService(cs)
+	return &reservationLabeler{
+		listCommitments: func(project, region string) ([]*compute.Commitment, error) {
+			var list []*compute.Commitment
+			err := commitments.List(project, region).Pages(context.TODO(), func(page *compute.CommitmentList) error {
+				list = append(list, page.Items...)
+				return nil
+			})
+			return list, err
+		},
+		now: time.Now,
+	}
+}
+
+// reconcile sets the reservation labels of the node from its instance.
+func (rl *reservationLabeler) reconcile(node *core.Node, instance *compute.Instance) bool {
+	desired := map[string]string{
+		wellknown.ReservationAffinityLabelKey: "",
+		wellknown.ReservationNameLabelKey:     "",
+	}
+	if ra := instance.ReservationAffinity; ra != nil {
+		desired[wellknown.ReservationAffinityLabelKey] = reservationAffinities[ra.ConsumeReservationType]
+		if ra.ConsumeReservationType == "SPECIFIC_RESERVATION" && ra.Key == specificReservationKey && len(ra.Values) == 1 {
+			desired[wellknown.ReservationNameLabelKey] = path.Base(ra.Values[0])
+		}
+	}
+	if covered, ok := rl.committedUse(node, instance); ok {
+		desired[wellknown.CommittedUseLabelKey] = strconv.FormatBool(covered)
+	}
+
+	modified := false
+	for key, value := range desired {
+		if value == "" {
+			if _, ok := node.Labels[key]; ok {
+				delete(node.Labels, key)
+				modified = true
+			}
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			klog.Warningf("Not setting label %s=%q on node %s: %v", key, value, node.Name, errs)
+			continue
+		}
+		if node.Labels[key] == value {
+			continue
+		}
+		if node.Labels == nil {
+			node.Labels = make(map[string]string)
+		}
+		node.Labels[key] = value
+		modified = true
+	}
+	return modified
+}
+
+// committedUse returns whether an active commitment of the region of the
+// instance covers its machine series. It returns false as second value when
+// the coverage is unknown, leaving the label as is.
+func (rl *reservationLabeler) committedUse(node *core.Node, instance *compute.Instance) (bool, bool) {
+	if s := instance.Scheduling; s != nil && (s.Preemptible || s.ProvisioningModel == "SPOT") {
+		return false, true
+	}
+	commitmentType, ok := commitmentTypes[machineSeries(instance.MachineType)]
+	if !ok {
+		return false, true
+	}
+	project, zone, _, err := parseNodeURL(node.Spec.ProviderID)
+	if err != nil {
+		return false, false
+	}
+	commitments, err := rl.regionCommitments(project, zoneRegion(zone))
+	if err != nil {
+		klog.Errorf("Error listing the commitments of region %s: %v", zoneRegion(zone), err)
+		return false, false
+	}
+	for _, commitment := range commitments {
+		if commitment.Status == "ACTIVE" && commitment.Type == commitmentType {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// regionCommitments returns the commitments of the region, cached for
+// commitmentsCacheTTL.
+func (rl *reservationLabeler) regionCommitments(project, region string) ([]*compute.Commitment, error) {
+	key := project + "/" + region
+	rl.lock.Lock()
+	entry, ok := rl.commitments[key]
+	rl.lock.Unlock()
+	if ok && rl.now().Sub(entry.fetched) < commitmentsCacheTTL {
+		return entry.commitments, nil
+	}
+	commitments, err := rl.listCommitments(project, region)
+	if err != nil {
+		return nil, err
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	if rl.commitments == nil {
+		rl.commitments = make(map[string]commitmentsCacheEntry)
+	}
+	rl.commitments[key] = commitmentsCacheEntry{commitments: commitments, fetched: rl.now()}
+	return commitments, nil
+}
+
+// machineSeries returns the series of a machine type URL, e.g. "n2" for
+// zones/us-central1-b/machineTypes/n2-standard-4. N1 custom machine types
+// have no series prefix.
+func machineSeries(machineType string) string {
+	name := path.Base(machineType)
+	if strings.HasPrefix(name, "custom-") {
+		return "n1"
+	}
+	series, _, _ := strings.Cut(name, "-")
+	return series
+}
+
+// zoneRegion returns the region of a zone, e.g. "us-central1" for
+// "us-central1-b".
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
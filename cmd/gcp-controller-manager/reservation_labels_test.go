@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	core "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileReservationLabels(t *testing.T) {
+	const machineType = "zones/us-central1-b/machineTypes/n2-standard-4"
+	n2Commitment := &compute.Commitment{Name: "n2", Status: "ACTIVE", Type: "GENERAL_PURPOSE_N2"}
+	cases := map[string]struct {
+		labels         map[string]string
+		instance       *compute.Instance
+		commitments    []*compute.Commitment
+		commitmentsErr error
+		wantLabels     map[string]string
+		wantModified   bool
+	}{
+		"any reservation without commitment": {
+			instance: &compute.Instance{
+				MachineType:         machineType,
+				ReservationAffinity: &compute.ReservationAffinity{ConsumeReservationType: "ANY_RESERVATION"},
+			},
+			wantLabels: map[string]string{
+				"node.gke.io/reservation-affinity": "any",
+				"node.gke.io/committed-use":        "false",
+			},
+			wantModified: true,
+		},
+		"specific reservation covered by a commitment": {
+			instance: &compute.Instance{
+				MachineType: machineType,
+				ReservationAffinity: &compute.ReservationAffinity{
+					ConsumeReservationType: "SPECIFIC_RESERVATION",
+					Key:                    "compute.googleapis.com/reservation-name",
+					Values:                 []string{"projects/p/zones/us-central1-b/reservations/res-1"},
+				},
+			},
+			commitments: []*compute.Commitment{n2Commitment},
+			wantLabels: map[string]string{
+				"node.gke.io/reservation-affinity": "specific",
+				"node.gke.io/reservation-name":     "res-1",
+				"node.gke.io/committed-use":        "true",
+			},
+			wantModified: true,
+		},
+		"expired commitment of another series": {
+			instance:     &compute.Instance{MachineType: "zones/us-central1-b/machineTypes/e2-medium"},
+			commitments:  []*compute.Commitment{n2Commitment, {Name: "e2", Status: "EXPIRED", Type: "GENERAL_PURPOSE_E2"}},
+			wantLabels:   map[string]string{"node.gke.io/committed-use": "false"},
+			wantModified: true,
+		},
+		"spot instances are not covered": {
+			instance: &compute.Instance{
+				MachineType: machineType,
+				Scheduling:  &compute.Scheduling{ProvisioningModel: "SPOT"},
+			},
+			commitments:  []*compute.Commitment{n2Commitment},
+			wantLabels:   map[string]string{"node.gke.io/committed-use": "false"},
+			wantModified: true,
+		},
+		"stale reservation labels are removed": {
+			labels: map[string]string{
+				"node.gke.io/reservation-affinity": "specific",
+				"node.gke.io/reservation-name":     "res-1",
+				"node.gke.io/committed-use":        "true",
+			},
+			instance:     &compute.Instance{MachineType: machineType},
+			commitments:  []*compute.Commitment{n2Commitment},
+			wantLabels:   map[string]string{"node.gke.io/committed-use": "true"},
+			wantModified: true,
+		},
+		"unknown coverage keeps the label": {
+			labels:         map[string]string{"node.gke.io/committed-use": "true"},
+			instance:       &compute.Instance{MachineType: machineType},
+			commitmentsErr: fmt.Errorf("permission denied"),
+			wantLabels:     map[string]string{"node.gke.io/committed-use": "true"},
+		},
+		"labels up to date": {
+			labels: map[string]string{
+				"node.gke.io/reservation-affinity": "none",
+				"node.gke.io/committed-use":        "true",
+			},
+			instance: &compute.Instance{
+				MachineType:         machineType,
+				ReservationAffinity: &compute.ReservationAffinity{ConsumeReservationType: "NO_RESERVATION"},
+			},
+			commitments: []*compute.Commitment{n2Commitment},
+			wantLabels: map[string]string{
+				"node.gke.io/reservation-affinity": "none",
+				"node.gke.io/committed-use":        "true",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rl := &reservationLabeler{
+				listCommitments: func(project, region string) ([]*compute.Commitment, error) {
+					if project != "p" || region != "us-central1" {
+						t.Errorf("listed the commitments of %s/%s, want p/us-central1", project, region)
+					}
+					return c.commitments, c.commitmentsErr
+				},
+				now: time.Now,
+			}
+			node := &core.Node{
+				ObjectMeta: v1.ObjectMeta{Name: "node", Labels: c.labels},
+				Spec:       core.NodeSpec{ProviderID: "gce://p/us-central1-b/node"},
+			}
+			if modified := rl.reconcile(node, c.instance); modified != c.wantModified {
+				t.Errorf("reconcile() = %v, want %v", modified, c.wantModified)
+			}
+			if diff := cmp.Diff(c.wantLabels, node.Labels); diff != "" {
+				t.Errorf("reconcile() unexpected labels (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegionCommitmentsCache(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := 0
+	rl := &reservationLabeler{
+		listCommitments: func(project, region string) ([]*compute.Commitment, error) {
+			calls++
+			return nil, nil
+		},
+		now: func() time.Time { return now },
+	}
+	for _, step := range []struct {
+		elapsed   time.Duration
+		wantCalls int
+	}{
+		{0, 1},
+		{commitmentsCacheTTL / 2, 1},
+		{commitmentsCacheTTL, 2},
+	} {
+		now = now.Add(step.elapsed)
+		if _, err := rl.regionCommitments("p", "us-central1"); err != nil {
+			t.Fatalf("regionCommitments() returned err %v", err)
+		}
+		if calls != step.wantCalls {
+			t.Errorf("after %v: listed the commitments %d times, want %d", step.elapsed, calls, step.wantCalls)
+		}
+	}
+}
+
+func TestMachineSeries(t *testing.T) {
+	for machineType, want := range map[string]string{
+		"zones/us-central1-b/machineTypes/n2-standard-4": "n2",
+		"n2d-highmem-2": "n2d",
+		"zones/us-central1-b/machineTypes/custom-2-4096":                                      "n1",
+		"zones/us-central1-b/machineTypes/e2-custom-2-4096":                                   "e2",
+		"https://www.googleapis.com/compute/v1/projects/p/zones/z/machineTypes/a2-highgpu-1g": "a2",
+	} {
+		if got := machineSeries(machineType); got != want {
+			t.Errorf("machineSeries(%q) = %q, want %q", machineType, got, want)
+		}
+	}
+}
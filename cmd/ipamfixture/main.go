@@ -0,0 +1,191 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// ipamfixture snapshots the Networks, GKENetworkParamSets and sanitized node
+// instance interfaces of a cluster into a fixture consumable by the nodeipam
+// allocator tests, see pkg/controller/nodeipam/ipam/testdata/fixtures.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/spf13/pflag"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/test"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	kubeconfig    = pflag.String("kubeconfig", "", "Path to the kubeconfig of the cluster. Defaults to the in-cluster or default loading rules.")
+	output        = pflag.StringP("output", "o", "", "File to write the fixture to. Defaults to stdout.")
+	nodeSelector  = pflag.String("node-selector", "", "Label selector of the nodes to snapshot. All nodes are included by default.")
+	maxNodes      = pflag.Int("max-nodes", 10, "Maximum number of nodes to snapshot.")
+	providerIDRE  = regexp.MustCompile(`^gce://([^/]+)/([^/]+)/([^/]+)$`)
+	errNoProvider = fmt.Errorf("node has no GCE providerID")
+)
+
+// interfacesGetter returns the network interfaces of the instance with the given providerID.
+type interfacesGetter func(ctx context.Context, providerID string) ([]*compute.NetworkInterface, error)
+
+func main() {
+	klog.InitFlags(nil)
+	defer klog.Flush()
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine) // this is required to setup klog flags
+	pflag.Parse()
+
+	ctx := context.Background()
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		klog.Exitf("Failed to load kubeconfig: %v", err)
+	}
+	config.ContentType = "application/json" // required to serialize Networks to json
+	kubeClient, err := clientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Failed to create kubernetes client: %v", err)
+	}
+	networkClient, err := networkclientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Failed to create network client: %v", err)
+	}
+	computeService, err := compute.NewService(ctx)
+	if err != nil {
+		klog.Exitf("Failed to create compute client: %v", err)
+	}
+
+	fixture, err := snapshot(ctx, kubeClient, networkClient, computeInterfacesGetter(computeService))
+	if err != nil {
+		klog.Exitf("Failed to snapshot the cluster: %v", err)
+	}
+	data, err := yaml.Marshal(fixture)
+	if err != nil {
+		klog.Exitf("Failed to marshal the fixture: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		klog.Exitf("Failed to write the fixture: %v", err)
+	}
+}
+
+// snapshot builds the fixture of the cluster.
+func snapshot(ctx context.Context, kubeClient clientset.Interface, networkClient networkclientset.Interface, getInterfaces interfacesGetter) (*test.Fixture, error) {
+	fixture := &test.Fixture{}
+	networks, err := networkClient.NetworkingV1().Networks().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %v", err)
+	}
+	for _, network := range networks.Items {
+		network.TypeMeta = metav1.TypeMeta{}
+		network.ObjectMeta = sanitizeObjectMeta(network.ObjectMeta)
+		fixture.Networks = append(fixture.Networks, network)
+	}
+	params, err := networkClient.NetworkingV1alpha1().GKENetworkParamSets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GKENetworkParamSets: %v", err)
+	}
+	for _, gnp := range params.Items {
+		gnp.TypeMeta = metav1.TypeMeta{}
+		gnp.ObjectMeta = sanitizeObjectMeta(gnp.ObjectMeta)
+		fixture.GKENetworkParamSets = append(fixture.GKENetworkParamSets, gnp)
+	}
+	nodes, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: *nodeSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	for i := range nodes.Items {
+		if len(fixture.Nodes) >= *maxNodes {
+			break
+		}
+		nodeFixture, err := snapshotNode(ctx, &nodes.Items[i], len(fixture.Nodes), getInterfaces)
+		if err != nil {
+			klog.Warningf("Skipping node %s: %v", nodes.Items[i].Name, err)
+			continue
+		}
+		fixture.Nodes = append(fixture.Nodes, *nodeFixture)
+	}
+	return fixture, nil
+}
+
+// snapshotNode returns the sanitized interfaces of a node along with the
+// allocation currently published on it. Node names are replaced by their
+// index so that the fixture does not identify the instances.
+func snapshotNode(ctx context.Context, node *v1.Node, index int, getInterfaces interfacesGetter) (*test.NodeFixture, error) {
+	interfaces, err := getInterfaces(ctx, node.Spec.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	nodeFixture := &test.NodeFixture{
+		Name:     fmt.Sprintf("node%d", index),
+		PodCIDRs: node.Spec.PodCIDRs,
+	}
+	for _, inf := range interfaces {
+		nodeFixture.Interfaces = append(nodeFixture.Interfaces, test.SanitizeInterface(inf))
+	}
+	ann, ok, err := networkannotations.NodeAnnotation(node.Annotations, networkv1.NorthInterfacesAnnotationKey)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if nodeFixture.NorthInterfaces, err = networkv1.ParseNorthInterfacesAnnotation(ann); err != nil {
+			return nil, fmt.Errorf("invalid north-interfaces annotation: %v", err)
+		}
+	}
+	ann, ok, err = networkannotations.NodeAnnotation(node.Annotations, networkv1.MultiNetworkAnnotationKey)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if nodeFixture.AdditionalNodeNetworks, err = networkv1.ParseMultiNetworkAnnotation(ann); err != nil {
+			return nil, fmt.Errorf("invalid networks annotation: %v", err)
+		}
+	}
+	return nodeFixture, nil
+}
+
+// sanitizeObjectMeta keeps only the name of an object.
+func sanitizeObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: meta.Name}
+}
+
+func computeInterfacesGetter(service *compute.Service) interfacesGetter {
+	return func(ctx context.Context, providerID string) ([]*compute.NetworkInterface, error) {
+		matches := providerIDRE.FindStringSubmatch(providerID)
+		if len(matches) != 4 {
+			return nil, errNoProvider
+		}
+		instance, err := service.Instances.Get(matches[1], matches[2], matches[3]).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		return instance.NetworkInterfaces, nil
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/test"
+)
+
+func TestSnapshot(t *testing.T) {
+	network := &networkv1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: "red", UID: "uid", ResourceVersion: "10", Labels: map[string]string{"team": "a"}},
+		Spec: networkv1.NetworkSpec{
+			Type:          networkv1.L3NetworkType,
+			ParametersRef: &networkv1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-params"},
+		},
+	}
+	gnp := &networkv1alpha1.GKENetworkParamSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "red-params", UID: "uid"},
+		Spec:       networkv1alpha1.GKENetworkParamSetSpec{VPC: "red", VPCSubnet: "red-subnet"},
+	}
+	nodes := []fakeNode{
+		{
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "gke-cluster-pool-1234",
+					Annotations: map[string]string{
+						networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"}]`,
+						networkv1.MultiNetworkAnnotationKey:    `[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24"]}]`,
+					},
+				},
+				Spec: v1.NodeSpec{ProviderID: "gce://project/zone/gke-cluster-pool-1234", PodCIDRs: []string{"10.4.0.0/24"}},
+			},
+			interfaces: []*compute.NetworkInterface{
+				{Name: "nic0", Network: "default", Fingerprint: "abc", AccessConfigs: []*compute.AccessConfig{{NatIP: "34.1.1.1"}}},
+				{Name: "nic1", Network: "red", Subnetwork: "red-subnet", NetworkIP: "10.0.0.2", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "172.16.0.0/24", SubnetworkRangeName: "red-pods"}}},
+			},
+		},
+		{
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+		},
+	}
+	kubeClient := k8sfake.NewSimpleClientset(nodes[0].node, nodes[1].node)
+	getInterfaces := func(_ context.Context, providerID string) ([]*compute.NetworkInterface, error) {
+		for _, n := range nodes {
+			if n.node.Spec.ProviderID == providerID && providerID != "" {
+				return n.interfaces, nil
+			}
+		}
+		return nil, errNoProvider
+	}
+
+	got, err := snapshot(context.Background(), kubeClient, fake.NewSimpleClientset(network, gnp), getInterfaces)
+	if err != nil {
+		t.Fatalf("snapshot() returned err %v", err)
+	}
+	want := &test.Fixture{
+		Networks: []networkv1.Network{
+			{ObjectMeta: metav1.ObjectMeta{Name: "red"}, Spec: network.Spec},
+		},
+		GKENetworkParamSets: []networkv1alpha1.GKENetworkParamSet{
+			{ObjectMeta: metav1.ObjectMeta{Name: "red-params"}, Spec: gnp.Spec},
+		},
+		Nodes: []test.NodeFixture{
+			{
+				Name: "node0",
+				Interfaces: []*compute.NetworkInterface{
+					{Name: "nic0", Network: "default"},
+					{Name: "nic1", Network: "red", Subnetwork: "red-subnet", NetworkIP: "10.0.0.2", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "172.16.0.0/24", SubnetworkRangeName: "red-pods"}}},
+				},
+				PodCIDRs:               []string{"10.4.0.0/24"},
+				NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "red", IpAddress: "10.0.0.2"}},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("snapshot() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type fakeNode struct {
+	node       *v1.Node
+	interfaces []*compute.NetworkInterface
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// networkcheck validates the Networks and GKENetworkParamSets of a cluster
+// against the live compute resources they reference and prints a report. It
+// exits with a non-zero status if any error is found.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+	"k8s.io/klog/v2"
+)
+
+var (
+	kubeconfig = pflag.String("kubeconfig", "", "Path to the kubeconfig of the cluster. Defaults to the in-cluster or default loading rules.")
+	project    = pflag.String("project", "", "Network project used to resolve partial VPC and subnet references.")
+	region     = pflag.String("region", "", "Region of the cluster, used to resolve partial subnet references.")
+)
+
+const (
+	severityError   = "ERROR"
+	severityWarning = "WARNING"
+
+	gkeNetworkParamSetKind = "GKENetworkParamSet"
+)
+
+// finding is a problem found in the configuration of an object.
+type finding struct {
+	Severity string
+	Object   string
+	Message  string
+}
+
+// computeGetter fetches the compute resources referenced by GKENetworkParamSets.
+type computeGetter interface {
+	GetNetwork(ctx context.Context, project, name string) (*compute.Network, error)
+	GetSubnetwork(ctx context.Context, project, region, name string) (*compute.Subnetwork, error)
+}
+
+func main() {
+	klog.InitFlags(nil)
+	defer klog.Flush()
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine) // this is required to setup klog flags
+	pflag.Parse()
+
+	ctx := context.Background()
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		klog.Exitf("Failed to load kubeconfig: %v", err)
+	}
+	config.ContentType = "application/json" // required to serialize Networks to json
+	networkClient, err := networkclientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Failed to create network client: %v", err)
+	}
+	computeService, err := compute.NewService(ctx)
+	if err != nil {
+		klog.Exitf("Failed to create compute client: %v", err)
+	}
+
+	findings, err := check(ctx, networkClient, &computeServiceGetter{service: computeService}, gcpurl.Defaults{Project: *project, Region: *region})
+	if err != nil {
+		klog.Exitf("Failed to check the cluster: %v", err)
+	}
+	if printReport(os.Stdout, findings) {
+		os.Exit(1)
+	}
+}
+
+// check validates every Network and GKENetworkParamSet of the cluster.
+func check(ctx context.Context, networkClient networkclientset.Interface, getter computeGetter, defaults gcpurl.Defaults) ([]finding, error) {
+	networks, err := networkClient.NetworkingV1().Networks().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %v", err)
+	}
+	params, err := networkClient.NetworkingV1alpha1().GKENetworkParamSets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GKENetworkParamSets: %v", err)
+	}
+	gnps := make(map[string]*networkv1alpha1.GKENetworkParamSet)
+	for i := range params.Items {
+		gnps[params.Items[i].Name] = &params.Items[i]
+	}
+
+	var findings []finding
+	referencedBy := make(map[string][]string)
+	for i := range networks.Items {
+		network := &networks.Items[i]
+		object := "Network " + network.Name
+		ref := network.Spec.ParametersRef
+		if ref == nil {
+			if network.Spec.Type == networkv1.L3NetworkType || networkv1.IsDefaultNetwork(network.Name) {
+				findings = append(findings, finding{severityError, object, "spec.parametersRef is not set; reference the GKENetworkParamSet describing the VPC and subnet of the network"})
+			}
+			continue
+		}
+		if ref.Kind != gkeNetworkParamSetKind {
+			findings = append(findings, finding{severityError, object, fmt.Sprintf("spec.parametersRef.kind is %q, only %s is supported", ref.Kind, gkeNetworkParamSetKind)})
+			continue
+		}
+		if _, ok := gnps[ref.Name]; !ok {
+			findings = append(findings, finding{severityError, object, fmt.Sprintf("GKENetworkParamSet %s does not exist; create it or fix spec.parametersRef.name", ref.Name)})
+			continue
+		}
+		referencedBy[ref.Name] = append(referencedBy[ref.Name], network.Name)
+	}
+
+	names := make([]string, 0, len(gnps))
+	for name := range gnps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		object := "GKENetworkParamSet " + name
+		switch networks := referencedBy[name]; len(networks) {
+		case 0:
+			findings = append(findings, finding{severityWarning, object, "not referenced by any Network"})
+		case 1:
+		default:
+			sort.Strings(networks)
+			findings = append(findings, finding{severityError, object, fmt.Sprintf("referenced by several Networks %v; only the oldest one is allocated", networks)})
+		}
+		findings = append(findings, checkGKENetworkParamSet(ctx, gnps[name], getter, defaults)...)
+	}
+	return findings, nil
+}
+
+// checkGKENetworkParamSet validates the VPC, subnet and secondary ranges referenced by the GKENetworkParamSet.
+func checkGKENetworkParamSet(ctx context.Context, gnp *networkv1alpha1.GKENetworkParamSet, getter computeGetter, defaults gcpurl.Defaults) []finding {
+	object := "GKENetworkParamSet " + gnp.Name
+	var findings []finding
+	if _, err := gkenetworkparamset.Conditions(gnp); err != nil {
+		findings = append(findings, finding{severityWarning, object, err.Error()})
+	} else if !gkenetworkparamset.SubnetReady(gnp) {
+		findings = append(findings, finding{severityError, object, "the controller reported the subnet as not ready, see the " + wellknown.ConditionsAnnotationKey + " annotation"})
+	}
+
+	vpc, err := qualify(gnp.Spec.VPC, gcpurl.KindNetworks, defaults)
+	if err != nil {
+		return append(findings, finding{severityError, object, fmt.Sprintf("spec.vpc: %v", err)})
+	}
+	if _, err := getter.GetNetwork(ctx, vpc.Project, vpc.Name); err != nil {
+		return append(findings, finding{severityError, object, fmt.Sprintf("VPC %s: %s", vpc, describeError(err))})
+	}
+	subnetID, err := qualify(gnp.Spec.VPCSubnet, gcpurl.KindSubnetworks, defaults)
+	if err != nil {
+		return append(findings, finding{severityError, object, fmt.Sprintf("spec.vpcSubnet: %v", err)})
+	}
+	subnet, err := getter.GetSubnetwork(ctx, subnetID.Project, subnetID.Region, subnetID.Name)
+	if err != nil {
+		return append(findings, finding{severityError, object, fmt.Sprintf("subnet %s: %s", subnetID, describeError(err))})
+	}
+	if !gcpurl.Matches(subnet.Network, vpc.String(), gcpurl.KindNetworks, defaults) {
+		findings = append(findings, finding{severityError, object, fmt.Sprintf("subnet %s belongs to VPC %s, not %s", subnetID, subnet.Network, vpc)})
+	}
+	if !gkenetworkparamset.CompatibleSubnetPurpose(subnet.Purpose) {
+		findings = append(findings, finding{severityError, object, fmt.Sprintf("subnet %s has purpose %s; use a PRIVATE subnet", subnetID, subnet.Purpose)})
+	}
+	if gnp.Spec.PodIPv4Ranges != nil {
+		ranges := make(map[string]bool)
+		for _, r := range subnet.SecondaryIpRanges {
+			ranges[r.RangeName] = true
+		}
+		for _, rangeName := range gkenetworkparamset.PodIPv4RangeNames(gnp) {
+			if !ranges[rangeName] {
+				findings = append(findings, finding{severityError, object, fmt.Sprintf("secondary range %s does not exist in subnet %s; add it to the subnet or remove it from spec.podIPv4Ranges", rangeName, subnetID)})
+			}
+		}
+	}
+	return findings
+}
+
+// qualify parses a VPC or subnet reference and fills in the missing project
+// and region from defaults.
+func qualify(ref, kind string, defaults gcpurl.Defaults) (*gcpurl.ResourceID, error) {
+	id, err := gcpurl.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	id = id.Qualified(kind, defaults)
+	if id.Project == "" {
+		return nil, fmt.Errorf("%q does not name a project, set --project", ref)
+	}
+	if kind == gcpurl.KindSubnetworks && id.Region == "" {
+		return nil, fmt.Errorf("%q does not name a region, set --region", ref)
+	}
+	return id, nil
+}
+
+// describeError returns an actionable description of a compute API error.
+func describeError(err error) string {
+	if apiErr, ok := err.(*googleapi.Error); ok {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return "does not exist"
+		case http.StatusForbidden:
+			return "access denied, check the permissions of the credentials"
+		}
+	}
+	return err.Error()
+}
+
+// printReport writes the findings and returns true if any of them is an error.
+func printReport(w io.Writer, findings []finding) bool {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No problems found.")
+		return false
+	}
+	failed := false
+	for _, f := range findings {
+		fmt.Fprintf(w, "%-7s %s: %s\n", f.Severity, f.Object, f.Message)
+		if f.Severity == severityError {
+			failed = true
+		}
+	}
+	return failed
+}
+
+// computeServiceGetter implements computeGetter with the compute API.
+type computeServiceGetter struct {
+	service *compute.Service
+}
+
+func (g *computeServiceGetter) GetNetwork(ctx context.Context, project, name string) (*compute.Network, error) {
+	return g.service.Networks.Get(project, name).Context(ctx).Do()
+}
+
+func (g *computeServiceGetter) GetSubnetwork(ctx context.Context, project, region, name string) (*compute.Subnetwork, error) {
+	return g.service.Subnetworks.Get(project, region, name).Context(ctx).Do()
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+)
+
+type fakeComputeGetter struct {
+	networks    map[string]*compute.Network
+	subnetworks map[string]*compute.Subnetwork
+}
+
+func (g *fakeComputeGetter) GetNetwork(_ context.Context, project, name string) (*compute.Network, error) {
+	if nw, ok := g.networks[project+"/"+name]; ok {
+		return nw, nil
+	}
+	return nil, &googleapi.Error{Code: http.StatusNotFound}
+}
+
+func (g *fakeComputeGetter) GetSubnetwork(_ context.Context, project, region, name string) (*compute.Subnetwork, error) {
+	if subnet, ok := g.subnetworks[project+"/"+region+"/"+name]; ok {
+		return subnet, nil
+	}
+	return nil, &googleapi.Error{Code: http.StatusNotFound}
+}
+
+func network(name, params string) *networkv1.Network {
+	return &networkv1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: networkv1.NetworkSpec{
+			Type:          networkv1.L3NetworkType,
+			ParametersRef: &networkv1.NetworkParametersReference{Group: "networking.gke.io", Kind: gkeNetworkParamSetKind, Name: params},
+		},
+	}
+}
+
+func params(name, vpc, subnet string, rangeNames ...string) *networkv1alpha1.GKENetworkParamSet {
+	gnp := &networkv1alpha1.GKENetworkParamSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       networkv1alpha1.GKENetworkParamSetSpec{VPC: vpc, VPCSubnet: subnet},
+	}
+	if len(rangeNames) > 0 {
+		gnp.Spec.PodIPv4Ranges = &networkv1alpha1.SecondaryRanges{RangeNames: rangeNames}
+	}
+	return gnp
+}
+
+func TestCheck(t *testing.T) {
+	getter := &fakeComputeGetter{
+		networks: map[string]*compute.Network{
+			"project/default": {Name: "default"},
+			"project/red":     {Name: "red"},
+		},
+		subnetworks: map[string]*compute.Subnetwork{
+			"project/us-central1/default": {
+				Name:              "default",
+				Network:           "https://www.googleapis.com/compute/v1/projects/project/global/networks/default",
+				SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{{RangeName: "pods"}},
+			},
+			"project/us-central1/red": {
+				Name:    "red",
+				Network: "https://www.googleapis.com/compute/v1/projects/project/global/networks/red",
+				Purpose: "PRIVATE_SERVICE_CONNECT",
+			},
+			"project/us-central1/blue": {
+				Name:    "blue",
+				Network: "https://www.googleapis.com/compute/v1/projects/project/global/networks/default",
+			},
+		},
+	}
+	unreferenced := params("unreferenced", "default", "default", "pods")
+	client := fake.NewSimpleClientset(
+		network(networkv1.DefaultPodNetworkName, "default"),
+		network("red", "red"),
+		network("red-copy", "red"),
+		network("blue", "blue"),
+		network("missing", "missing"),
+		params("default", "default", "default", "pods", "missing-range"),
+		params("red", "red", "red"),
+		params("blue", "projects/project/global/networks/red", "blue"),
+		unreferenced,
+	)
+
+	findings, err := check(context.Background(), client, getter, gcpurl.Defaults{Project: "project", Region: "us-central1"})
+	if err != nil {
+		t.Fatalf("check() returned err %v", err)
+	}
+	want := []finding{
+		{severityError, "Network missing", "GKENetworkParamSet missing does not exist; create it or fix spec.parametersRef.name"},
+		{severityError, "GKENetworkParamSet blue", "subnet projects/project/regions/us-central1/subnetworks/blue belongs to VPC https://www.googleapis.com/compute/v1/projects/project/global/networks/default, not projects/project/global/networks/red"},
+		{severityError, "GKENetworkParamSet default", "secondary range missing-range does not exist in subnet projects/project/regions/us-central1/subnetworks/default; add it to the subnet or remove it from spec.podIPv4Ranges"},
+		{severityError, "GKENetworkParamSet red", "referenced by several Networks [red red-copy]; only the oldest one is allocated"},
+		{severityError, "GKENetworkParamSet red", "subnet projects/project/regions/us-central1/subnetworks/red has purpose PRIVATE_SERVICE_CONNECT; use a PRIVATE subnet"},
+		{severityWarning, "GKENetworkParamSet unreferenced", "not referenced by any Network"},
+	}
+	if diff := cmp.Diff(want, findings); diff != "" {
+		t.Errorf("check() findings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckUnqualifiedReferences(t *testing.T) {
+	client := fake.NewSimpleClientset(network("red", "red"), params("red", "red", "red"))
+	findings, err := check(context.Background(), client, &fakeComputeGetter{}, gcpurl.Defaults{})
+	if err != nil {
+		t.Fatalf("check() returned err %v", err)
+	}
+	want := []finding{
+		{severityError, "GKENetworkParamSet red", `spec.vpc: "red" does not name a project, set --project`},
+	}
+	if diff := cmp.Diff(want, findings); diff != "" {
+		t.Errorf("check() findings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var buf bytes.Buffer
+	if printReport(&buf, nil) {
+		t.Errorf("printReport() of no findings failed")
+	}
+	if got, want := buf.String(), "No problems found.\n"; got != want {
+		t.Errorf("printReport() = %q, want %q", got, want)
+	}
+	buf.Reset()
+	if !printReport(&buf, []finding{{severityWarning, "Network red", "warning"}, {severityError, "Network blue", "error"}}) {
+		t.Errorf("printReport() of an error did not fail")
+	}
+	if got, want := buf.String(), "WARNING Network red: warning\nERROR   Network blue: error\n"; got != want {
+		t.Errorf("printReport() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// networksim simulates a change of a GKENetworkParamSet before it is applied:
+// it prints the nodes whose pod CIDRs or multi-network annotations would
+// change and the pods whose IPs are in the ranges the nodes would lose. It
+// exits with a non-zero status if any pod would be affected.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"github.com/spf13/pflag"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	kubeconfig   = pflag.String("kubeconfig", "", "Path to the kubeconfig of the cluster. Defaults to the in-cluster or default loading rules.")
+	paramsFile   = pflag.String("params-file", "", "File holding the proposed GKENetworkParamSet, in YAML or JSON. It replaces the GKENetworkParamSet of the same name, or is added if there is none.")
+	nodeSelector = pflag.String("node-selector", "", "Label selector of the nodes to simulate. All nodes are included by default.")
+	providerIDRE = regexp.MustCompile(`^gce://([^/]+)/([^/]+)/([^/]+)$`)
+)
+
+// interfacesGetter returns the network interfaces of the instance with the given providerID.
+type interfacesGetter func(ctx context.Context, providerID string) ([]*compute.NetworkInterface, error)
+
+// nodeChange is the change of the allocation of a node.
+type nodeChange struct {
+	Node   string
+	Before *ipam.SimulatedAllocation
+	After  *ipam.SimulatedAllocation
+	// RemovedRanges are the ranges of the node that are not allocated to it
+	// after the change.
+	RemovedRanges []string
+	// AffectedPods are the pods of the node with an IP in RemovedRanges.
+	AffectedPods []string
+}
+
+func main() {
+	klog.InitFlags(nil)
+	defer klog.Flush()
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine) // this is required to setup klog flags
+	pflag.Parse()
+
+	if *paramsFile == "" {
+		klog.Exitf("--params-file is required")
+	}
+	proposed, err := loadParams(*paramsFile)
+	if err != nil {
+		klog.Exitf("Failed to load the proposed GKENetworkParamSet: %v", err)
+	}
+
+	ctx := context.Background()
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		klog.Exitf("Failed to load kubeconfig: %v", err)
+	}
+	config.ContentType = "application/json" // required to serialize Networks to json
+	kubeClient, err := clientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Failed to create kubernetes client: %v", err)
+	}
+	networkClient, err := networkclientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Failed to create network client: %v", err)
+	}
+	computeService, err := compute.NewService(ctx)
+	if err != nil {
+		klog.Exitf("Failed to create compute client: %v", err)
+	}
+
+	changes, err := simulate(ctx, kubeClient, networkClient, computeInterfacesGetter(computeService), proposed)
+	if err != nil {
+		klog.Exitf("Failed to simulate the change: %v", err)
+	}
+	if printReport(os.Stdout, proposed.Name, changes) {
+		os.Exit(1)
+	}
+}
+
+// loadParams reads a GKENetworkParamSet from a YAML or JSON file.
+func loadParams(path string) (*networkv1alpha1.GKENetworkParamSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	gnp := &networkv1alpha1.GKENetworkParamSet{}
+	if err := yaml.UnmarshalStrict(data, gnp); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", path, err)
+	}
+	if gnp.Name == "" {
+		return nil, fmt.Errorf("%q has no metadata.name", path)
+	}
+	return gnp, nil
+}
+
+// simulate computes the allocation of every node with the proposed
+// GKENetworkParamSet and returns the nodes whose allocation changes.
+func simulate(ctx context.Context, kubeClient clientset.Interface, networkClient networkclientset.Interface, getInterfaces interfacesGetter, proposed *networkv1alpha1.GKENetworkParamSet) ([]nodeChange, error) {
+	networkList, err := networkClient.NetworkingV1().Networks().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %v", err)
+	}
+	var networks []*networkv1.Network
+	for i := range networkList.Items {
+		networks = append(networks, &networkList.Items[i])
+	}
+	paramsList, err := networkClient.NetworkingV1alpha1().GKENetworkParamSets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GKENetworkParamSets: %v", err)
+	}
+	params := []*networkv1alpha1.GKENetworkParamSet{proposed}
+	for i := range paramsList.Items {
+		if paramsList.Items[i].Name != proposed.Name {
+			params = append(params, &paramsList.Items[i])
+		}
+	}
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: *nodeSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	var changes []nodeChange
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !providerIDRE.MatchString(node.Spec.ProviderID) {
+			klog.V(2).Infof("Skipping node %s without GCE providerID", node.Name)
+			continue
+		}
+		change, err := simulateNode(ctx, kubeClient, node, getInterfaces, networks, params)
+		if err != nil {
+			klog.Warningf("Skipping node %s: %v", node.Name, err)
+			continue
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+// simulateNode returns the change of the allocation of the node, or nil if it
+// does not change.
+func simulateNode(ctx context.Context, kubeClient clientset.Interface, node *v1.Node, getInterfaces interfacesGetter, networks []*networkv1.Network, params []*networkv1alpha1.GKENetworkParamSet) (*nodeChange, error) {
+	before, err := ipam.PublishedAllocation(node)
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := getInterfaces(ctx, node.Spec.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := ipam.SimulateMultiNetworkAllocation(node, interfaces, networks, params)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil, nil
+	}
+	change := &nodeChange{
+		Node:          node.Name,
+		Before:        before,
+		After:         after,
+		RemovedRanges: removedRanges(before, after),
+	}
+	if len(change.RemovedRanges) == 0 {
+		return change, nil
+	}
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for i := range pods.Items {
+		if ip := podIPInRanges(&pods.Items[i], change.RemovedRanges); ip != "" {
+			change.AffectedPods = append(change.AffectedPods, fmt.Sprintf("%s/%s (%s)", pods.Items[i].Namespace, pods.Items[i].Name, ip))
+		}
+	}
+	sort.Strings(change.AffectedPods)
+	return change, nil
+}
+
+// removedRanges returns the pod ranges of the before allocation that are not
+// in the after allocation.
+func removedRanges(before, after *ipam.SimulatedAllocation) []string {
+	kept := make(map[string]bool)
+	for _, cidr := range allocationRanges(after) {
+		kept[cidr] = true
+	}
+	var removed []string
+	for _, cidr := range allocationRanges(before) {
+		if !kept[cidr] {
+			removed = append(removed, cidr)
+		}
+	}
+	return removed
+}
+
+// allocationRanges returns the pod ranges of all the networks of the allocation.
+func allocationRanges(allocation *ipam.SimulatedAllocation) []string {
+	ranges := append([]string(nil), allocation.PodCIDRs...)
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		ranges = append(ranges, nw.Cidrs...)
+	}
+	return ranges
+}
+
+// podIPInRanges returns an IP of the pod, on any network, in one of the
+// ranges, or "" if there is none. Host network pods use the node IPs.
+func podIPInRanges(pod *v1.Pod, ranges []string) string {
+	if pod.Spec.HostNetwork {
+		return ""
+	}
+	var ips []string
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	if ann, ok := pod.Annotations[networkv1.PodIPsAnnotationKey]; ok {
+		podIPs, err := networkv1.ParsePodIPsAnnotation(ann)
+		if err != nil {
+			klog.Warningf("Ignoring invalid %s annotation of pod %s/%s: %v", networkv1.PodIPsAnnotationKey, pod.Namespace, pod.Name, err)
+		}
+		for _, podIP := range podIPs {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	for _, cidr := range ranges {
+		_, ipNet, err := netutils.ParseCIDRSloppy(cidr)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if parsed := netutils.ParseIPSloppy(ip); parsed != nil && ipNet.Contains(parsed) {
+				return ip
+			}
+		}
+	}
+	return ""
+}
+
+// printReport writes the changes and returns true if any pod is affected.
+func printReport(w io.Writer, gnpName string, changes []nodeChange) bool {
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "No node is affected by the change of GKENetworkParamSet %s.\n", gnpName)
+		return false
+	}
+	affected := false
+	for _, change := range changes {
+		fmt.Fprintf(w, "Node %s:\n", change.Node)
+		if !reflect.DeepEqual(change.Before.PodCIDRs, change.After.PodCIDRs) {
+			fmt.Fprintf(w, "  pod CIDRs: %v -> %v\n", change.Before.PodCIDRs, change.After.PodCIDRs)
+		}
+		if !reflect.DeepEqual(change.Before.NorthInterfaces, change.After.NorthInterfaces) {
+			fmt.Fprintf(w, "  %s: %s -> %s\n", networkv1.NorthInterfacesAnnotationKey, marshal(change.Before.NorthInterfaces), marshal(change.After.NorthInterfaces))
+		}
+		if !reflect.DeepEqual(change.Before.AdditionalNodeNetworks, change.After.AdditionalNodeNetworks) {
+			fmt.Fprintf(w, "  %s: %s -> %s\n", networkv1.MultiNetworkAnnotationKey, marshal(change.Before.AdditionalNodeNetworks), marshal(change.After.AdditionalNodeNetworks))
+		}
+		if len(change.RemovedRanges) > 0 {
+			fmt.Fprintf(w, "  removed ranges: %v\n", change.RemovedRanges)
+		}
+		for _, pod := range change.AffectedPods {
+			fmt.Fprintf(w, "  pod using a removed range: %s\n", pod)
+			affected = true
+		}
+	}
+	return affected
+}
+
+// marshal returns the annotation value, or "none".
+func marshal(annotation interface{}) string {
+	if reflect.ValueOf(annotation).Len() == 0 {
+		return "none"
+	}
+	value, err := networkv1.MarshalAnnotation(annotation)
+	if err != nil {
+		return fmt.Sprintf("%v", annotation)
+	}
+	return value
+}
+
+func computeInterfacesGetter(service *compute.Service) interfacesGetter {
+	return func(ctx context.Context, providerID string) ([]*compute.NetworkInterface, error) {
+		matches := providerIDRE.FindStringSubmatch(providerID)
+		instance, err := service.Instances.Get(matches[1], matches[2], matches[3]).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		return instance.NetworkInterfaces, nil
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam"
+)
+
+const (
+	networkURL = "https://www.googleapis.com/compute/v1/projects/test-project/global/networks/"
+	subnetURL  = "https://www.googleapis.com/compute/v1/projects/test-project/regions/us-central1/subnetworks/"
+)
+
+func TestSimulate(t *testing.T) {
+	networks := []*networkv1.Network{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: networkv1.NetworkSpec{
+				Type:          networkv1.L3NetworkType,
+				ParametersRef: &networkv1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "default-params"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "red"},
+			Spec: networkv1.NetworkSpec{
+				Type:          networkv1.L3NetworkType,
+				ParametersRef: &networkv1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-params"},
+			},
+		},
+	}
+	defaultParams := &networkv1alpha1.GKENetworkParamSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "default-params"},
+		Spec: networkv1alpha1.GKENetworkParamSetSpec{
+			VPC:           "default",
+			VPCSubnet:     "default",
+			PodIPv4Ranges: &networkv1alpha1.SecondaryRanges{RangeNames: []string{"pods"}},
+		},
+	}
+	redParams := func(rangeNames ...string) *networkv1alpha1.GKENetworkParamSet {
+		return &networkv1alpha1.GKENetworkParamSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "red-params"},
+			Spec: networkv1alpha1.GKENetworkParamSetSpec{
+				VPC:           "red",
+				VPCSubnet:     "red",
+				PodIPv4Ranges: &networkv1alpha1.SecondaryRanges{RangeNames: rangeNames},
+			},
+		}
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node0",
+			Annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"}]`,
+				networkv1.MultiNetworkAnnotationKey:    `[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24"]}]`,
+			},
+		},
+		Spec: v1.NodeSpec{ProviderID: "gce://test-project/us-central1-a/node0", PodCIDRs: []string{"10.4.0.0/24"}},
+	}
+	interfaces := []*compute.NetworkInterface{
+		{Name: "nic0", Network: networkURL + "default", Subnetwork: subnetURL + "default", NetworkIP: "10.128.0.2", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "10.4.0.0/24", SubnetworkRangeName: "pods"}}},
+		{Name: "nic1", Network: networkURL + "red", Subnetwork: subnetURL + "red", NetworkIP: "10.0.0.2", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "172.16.0.0/24", SubnetworkRangeName: "red-pods-b"}}},
+	}
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "red-pod",
+				Annotations: map[string]string{networkv1.PodIPsAnnotationKey: `[{"networkName":"red","ip":"172.16.0.5"}]`},
+			},
+			Spec:   v1.PodSpec{NodeName: "node0"},
+			Status: v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.4.0.5"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "default-pod"},
+			Spec:       v1.PodSpec{NodeName: "node0"},
+			Status:     v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.4.0.6"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "host-pod"},
+			Spec:       v1.PodSpec{NodeName: "node0", HostNetwork: true},
+			Status:     v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.128.0.2"}}},
+		},
+	}
+	before := &ipam.SimulatedAllocation{
+		PodCIDRs:               []string{"10.4.0.0/24"},
+		NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "red", IpAddress: "10.0.0.2"}},
+		AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}}},
+	}
+
+	testCases := []struct {
+		desc         string
+		proposed     *networkv1alpha1.GKENetworkParamSet
+		want         []nodeChange
+		wantAffected bool
+	}{
+		{
+			desc:     "unchanged ranges",
+			proposed: redParams("red-pods-a", "red-pods-b"),
+		},
+		{
+			desc:     "unused range removed",
+			proposed: redParams("red-pods-b"),
+		},
+		{
+			desc:     "range of the node removed",
+			proposed: redParams("red-pods-a"),
+			want: []nodeChange{
+				{
+					Node:          "node0",
+					Before:        before,
+					After:         &ipam.SimulatedAllocation{PodCIDRs: []string{"10.4.0.0/24"}},
+					RemovedRanges: []string{"172.16.0.0/24"},
+					AffectedPods:  []string{"default/red-pod (172.16.0.5)"},
+				},
+			},
+			wantAffected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			kubeClient := k8sfake.NewSimpleClientset(node, pods[0], pods[1], pods[2])
+			networkClient := fake.NewSimpleClientset(networks[0], networks[1], defaultParams, redParams("red-pods-a", "red-pods-b"))
+			getInterfaces := func(_ context.Context, providerID string) ([]*compute.NetworkInterface, error) {
+				return interfaces, nil
+			}
+
+			got, err := simulate(context.Background(), kubeClient, networkClient, getInterfaces, tc.proposed)
+			if err != nil {
+				t.Fatalf("simulate() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("simulate() mismatch (-want +got):\n%s", diff)
+			}
+			var out bytes.Buffer
+			if affected := printReport(&out, tc.proposed.Name, got); affected != tc.wantAffected {
+				t.Errorf("printReport() = %t, want %t", affected, tc.wantAffected)
+			}
+			if len(tc.want) == 0 && !strings.Contains(out.String(), "No node is affected") {
+				t.Errorf("printReport() wrote %q, want no affected node", out.String())
+			}
+		})
+	}
+}
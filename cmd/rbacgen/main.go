@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// rbacgen generates the minimal ClusterRole of the cloud controller manager
+// for the controllers it runs and the features of its node IPAM
+// configuration, for clusters deploying it outside GKE. It takes the values
+// of the --controllers and --nodeipam-config flags of the cloud controller
+// manager, e.g.
+//
+//	rbacgen --controllers='*,-route' --nodeipam-config=nodeipam.yaml
+//
+// The rules assume that the controllers run with the identity of the cloud
+// controller manager, i.e. without --use-service-account-credentials.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nodeipamconfig "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+	nodeipamconfigscheme "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/scheme"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	controllerSpec  = pflag.StringSlice("controllers", []string{"*"}, "Controllers of the cloud controller manager, as in its --controllers flag.")
+	nodeIPAMConfig  = pflag.String("nodeipam-config", "", "NodeIPAMConfiguration file of the cloud controller manager, as in its --nodeipam-config flag. The default configuration is used if empty.")
+	roleName        = pflag.String("name", "system:cloud-controller-manager", "Name of the ClusterRole.")
+	networkRoleName = pflag.String("network-role-name", "", "Name of a separate ClusterRole holding the rules of the Networks and GKENetworkParamSets read by the node IPAM controller, for the identity of --nodeipam-network-kubeconfig. The rules are part of the main ClusterRole if empty.")
+	output          = pflag.StringP("output", "o", "", "File to write the ClusterRoles to. Defaults to stdout.")
+)
+
+func main() {
+	klog.InitFlags(nil)
+	defer klog.Flush()
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine) // this is required to setup klog flags
+	pflag.Parse()
+
+	enabled, err := enabledControllers(*controllerSpec)
+	if err != nil {
+		klog.Exitf("Invalid controllers: %v", err)
+	}
+	cfg, err := loadNodeIPAMConfig(*nodeIPAMConfig)
+	if err != nil {
+		klog.Exitf("Failed to load the node IPAM configuration: %v", err)
+	}
+	data, err := generate(*roleName, *networkRoleName, features{controllers: enabled, nodeIPAM: cfg})
+	if err != nil {
+		klog.Exitf("Failed to generate the ClusterRoles: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		klog.Exitf("Failed to create the output directory: %v", err)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		klog.Exitf("Failed to write the ClusterRoles: %v", err)
+	}
+}
+
+// loadNodeIPAMConfig returns the node IPAM configuration of the file at path,
+// or the default configuration if path is empty.
+func loadNodeIPAMConfig(path string) (*nodeipamconfig.NodeIPAMConfiguration, error) {
+	if path == "" {
+		return nodeipamconfigscheme.Default()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := nodeipamconfigscheme.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// generate returns the ClusterRole of the features, followed by the network
+// ClusterRole if networkName is set, as a multi-document YAML manifest.
+func generate(name, networkName string, f features) ([]byte, error) {
+	perms, networkPerms := permissions(f)
+	var roles []*rbacv1.ClusterRole
+	if networkName == "" {
+		roles = append(roles, clusterRole(name, append(perms, networkPerms...)))
+	} else {
+		roles = append(roles, clusterRole(name, perms), clusterRole(networkName, networkPerms))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Code generated by cmd/rbacgen. DO NOT EDIT.\n")
+	for _, role := range roles {
+		data, err := yaml.Marshal(role)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func clusterRole(name string, perms []permission) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      policyRules(perms),
+	}
+}
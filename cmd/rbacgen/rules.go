@@ -0,0 +1,240 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeipamconfig "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam"
+)
+
+const (
+	coreGroup         = ""
+	coordinationGroup = "coordination.k8s.io"
+	networkingGroup   = "networking.gke.io"
+)
+
+// controllers are the controllers of the cloud controller manager, along with
+// whether they run by default. It must follow the controllers registered in
+// cmd/cloud-controller-manager.
+var controllers = map[string]bool{
+	"cloud-node":            true,
+	"cloud-node-lifecycle":  true,
+	"service":               true,
+	"route":                 true,
+	"nodeipam":              true,
+	"gkenetworkparamset":    true,
+	"networkusage":          false,
+	"multinetworkreadiness": false,
+	"routegc":               false,
+}
+
+// permission grants verbs on a resource of an API group, restricted to the
+// object of the given name if it is not empty.
+type permission struct {
+	group        string
+	resource     string
+	resourceName string
+	verbs        []string
+}
+
+// features are the capabilities of the cloud controller manager the rules are
+// generated for.
+type features struct {
+	// controllers are the names of the controllers that run.
+	controllers sets.String
+	// nodeIPAM is the configuration of the node IPAM controller.
+	nodeIPAM *nodeipamconfig.NodeIPAMConfiguration
+}
+
+// enabledControllers returns the controllers enabled by the value of the
+// --controllers flag of the cloud controller manager: '*' enables the
+// controllers that run by default, 'foo' enables the controller named foo and
+// '-foo' disables it.
+func enabledControllers(spec []string) (sets.String, error) {
+	enabled := sets.NewString()
+	disabled := sets.NewString()
+	for _, name := range spec {
+		switch {
+		case name == "*":
+			for controller, byDefault := range controllers {
+				if byDefault {
+					enabled.Insert(controller)
+				}
+			}
+		case strings.HasPrefix(name, "-"):
+			if _, ok := controllers[name[1:]]; !ok {
+				return nil, fmt.Errorf("unknown controller %q", name[1:])
+			}
+			disabled.Insert(name[1:])
+		default:
+			if _, ok := controllers[name]; !ok {
+				return nil, fmt.Errorf("unknown controller %q", name)
+			}
+			enabled.Insert(name)
+		}
+	}
+	return enabled.Difference(disabled), nil
+}
+
+// permissions returns the permissions needed by the cloud controller manager
+// with the given features. The permissions of the Networks and
+// GKENetworkParamSets read by the node IPAM controller are returned
+// separately, as they may be granted to the identity of
+// --nodeipam-network-kubeconfig.
+func permissions(f features) (perms, networkPerms []permission) {
+	perms = []permission{
+		// Leader election, events and delegated authentication and
+		// authorization of the secure port.
+		{group: coordinationGroup, resource: "leases", verbs: []string{"create"}},
+		{group: coordinationGroup, resource: "leases", resourceName: "cloud-controller-manager", verbs: []string{"get", "update"}},
+		{group: coreGroup, resource: "events", verbs: []string{"create", "patch", "update"}},
+		{group: "events.k8s.io", resource: "events", verbs: []string{"create", "patch", "update"}},
+		{group: "authentication.k8s.io", resource: "tokenreviews", verbs: []string{"create"}},
+		{group: "authorization.k8s.io", resource: "subjectaccessreviews", verbs: []string{"create"}},
+		// The cluster ID of the GCE provider.
+		{group: coreGroup, resource: "configmaps", verbs: []string{"create", "get", "list", "watch"}},
+	}
+	if f.controllers.Has("cloud-node") {
+		perms = append(perms,
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"get", "list", "watch", "update", "patch"}},
+			permission{group: coreGroup, resource: "nodes/status", verbs: []string{"patch", "update"}},
+		)
+	}
+	if f.controllers.Has("cloud-node-lifecycle") {
+		perms = append(perms, permission{group: coreGroup, resource: "nodes", verbs: []string{"get", "list", "watch", "patch", "delete"}})
+	}
+	if f.controllers.Has("service") {
+		perms = append(perms,
+			permission{group: coreGroup, resource: "services", verbs: []string{"get", "list", "watch", "patch", "update"}},
+			permission{group: coreGroup, resource: "services/status", verbs: []string{"patch", "update"}},
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"list", "watch"}},
+		)
+	}
+	if f.controllers.Has("route") {
+		perms = append(perms,
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"list", "watch"}},
+			permission{group: coreGroup, resource: "nodes/status", verbs: []string{"patch"}},
+		)
+	}
+	if f.controllers.Has("nodeipam") {
+		perms = append(perms,
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"get", "list", "watch", "patch"}},
+			permission{group: coreGroup, resource: "nodes/status", verbs: []string{"patch"}},
+		)
+		cfg := f.nodeIPAM
+		if cfg.CIDRAllocatorType != string(ipam.RangeAllocatorType) && cfg.MultiNetwork.Enabled {
+			networkPerms = append(networkPerms,
+				permission{group: networkingGroup, resource: "networks", verbs: []string{"get", "list", "watch", "patch"}},
+				permission{group: networkingGroup, resource: "gkenetworkparamsets", verbs: []string{"get", "list", "watch"}},
+			)
+			if cfg.MultiNetwork.NodeCoordinationLeases {
+				perms = append(perms, permission{group: coordinationGroup, resource: "leases", verbs: []string{"get", "list", "create", "update"}})
+			}
+		}
+		if cfg.StrandedPodCIDRThreshold.Duration > 0 || cfg.PodIPReconciliation {
+			perms = append(perms, permission{group: coreGroup, resource: "pods", verbs: []string{"list", "watch"}})
+		}
+		if cfg.AllocationCheckpoints {
+			networkPerms = append(networkPerms, permission{group: networkingGroup, resource: "nodeipamcheckpoints", verbs: []string{"list", "create", "update", "delete"}})
+		}
+		if cfg.HealthStatus {
+			networkPerms = append(networkPerms, permission{group: networkingGroup, resource: "nodeipamhealths", verbs: []string{"get", "create", "update"}})
+		}
+		if cfg.PodCIDROwnershipLease {
+			perms = append(perms,
+				permission{group: coordinationGroup, resource: "leases", verbs: []string{"create"}},
+				permission{group: coordinationGroup, resource: "leases", resourceName: wellknown.PodCIDROwnershipLeaseName, verbs: []string{"get", "update"}},
+			)
+		}
+	}
+	if f.controllers.Has("gkenetworkparamset") {
+		perms = append(perms,
+			permission{group: networkingGroup, resource: "gkenetworkparamsets", verbs: []string{"get", "list", "watch", "patch"}},
+			permission{group: networkingGroup, resource: "gkenetworkparamsets/status", verbs: []string{"patch"}},
+		)
+	}
+	if f.controllers.Has("networkusage") {
+		perms = append(perms,
+			permission{group: networkingGroup, resource: "networks", verbs: []string{"get", "list", "watch", "update"}},
+			permission{group: networkingGroup, resource: "gkenetworkparamsets", verbs: []string{"list", "watch"}},
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"list", "watch"}},
+		)
+	}
+	if f.controllers.Has("multinetworkreadiness") {
+		perms = append(perms,
+			permission{group: coreGroup, resource: "pods", verbs: []string{"list", "watch"}},
+			permission{group: coreGroup, resource: "pods/status", verbs: []string{"update"}},
+			permission{group: coreGroup, resource: "nodes", verbs: []string{"list", "watch"}},
+		)
+	}
+	if f.controllers.Has("routegc") {
+		perms = append(perms, permission{group: coreGroup, resource: "nodes", verbs: []string{"list", "watch"}})
+	}
+	return perms, networkPerms
+}
+
+// policyRules returns the minimal rules granting the permissions: the verbs
+// of each resource are merged, and the resources of a group granted the same
+// verbs share a rule. The rules are sorted so that the output is stable.
+func policyRules(perms []permission) []rbacv1.PolicyRule {
+	type target struct{ group, resource, resourceName string }
+	verbs := make(map[target]sets.String)
+	for _, p := range perms {
+		t := target{p.group, p.resource, p.resourceName}
+		if verbs[t] == nil {
+			verbs[t] = sets.NewString()
+		}
+		verbs[t].Insert(p.verbs...)
+	}
+	type ruleKey struct{ group, resourceName, verbs string }
+	resources := make(map[ruleKey][]string)
+	for t, v := range verbs {
+		key := ruleKey{t.group, t.resourceName, strings.Join(v.List(), ",")}
+		resources[key] = append(resources[key], t.resource)
+	}
+	var rules []rbacv1.PolicyRule
+	for key, res := range resources {
+		sort.Strings(res)
+		rule := rbacv1.PolicyRule{
+			APIGroups: []string{key.group},
+			Resources: res,
+			Verbs:     strings.Split(key.verbs, ","),
+		}
+		if key.resourceName != "" {
+			rule.ResourceNames = []string{key.resourceName}
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.APIGroups[0] != b.APIGroups[0] {
+			return a.APIGroups[0] < b.APIGroups[0]
+		}
+		if a.Resources[0] != b.Resources[0] {
+			return a.Resources[0] < b.Resources[0]
+		}
+		return len(a.ResourceNames) < len(b.ResourceNames)
+	})
+	return rules
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeipamconfigscheme "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+func TestEnabledControllers(t *testing.T) {
+	testCases := []struct {
+		spec    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			spec: []string{"*"},
+			want: []string{"cloud-node", "cloud-node-lifecycle", "gkenetworkparamset", "nodeipam", "route", "service"},
+		},
+		{
+			spec: []string{"*", "-route", "multinetworkreadiness"},
+			want: []string{"cloud-node", "cloud-node-lifecycle", "gkenetworkparamset", "multinetworkreadiness", "nodeipam", "service"},
+		},
+		{
+			spec: []string{"nodeipam"},
+			want: []string{"nodeipam"},
+		},
+		{
+			spec:    []string{"*", "-routes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		got, err := enabledControllers(tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("enabledControllers(%q) returned err %v, want error %v", tc.spec, err, tc.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got.List()); !tc.wantErr && diff != "" {
+			t.Errorf("enabledControllers(%q) mismatch (-want +got):\n%s", tc.spec, diff)
+		}
+	}
+}
+
+// allows returns true if the rules grant the verb on the resource.
+func allows(rules []rbacv1.PolicyRule, group, resource, verb string) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		if sets.NewString(rule.APIGroups...).Has(group) && sets.NewString(rule.Resources...).Has(resource) && sets.NewString(rule.Verbs...).Has(verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPermissions(t *testing.T) {
+	type access struct{ group, resource, verb string }
+	testCases := []struct {
+		desc                   string
+		controllers            []string
+		allocatorType          string
+		multiNetwork           bool
+		nodeCoordinationLeases bool
+		strandedPodCIDRs       bool
+		podIPReconciliation    bool
+		allocationCheckpoints  bool
+		healthStatus           bool
+		want                   []access
+		wantNetwork            []access
+		wantDenied             []access
+	}{
+		{
+			desc:         "node IPAM with multi-network",
+			controllers:  []string{"nodeipam"},
+			multiNetwork: true,
+			want:         []access{{"", "nodes", "patch"}, {"", "nodes/status", "patch"}},
+			wantNetwork:  []access{{"networking.gke.io", "networks", "watch"}, {"networking.gke.io", "networks", "patch"}, {"networking.gke.io", "gkenetworkparamsets", "list"}},
+			wantDenied:   []access{{"", "nodes", "delete"}, {"", "services", "list"}, {"", "pods", "list"}, {"coordination.k8s.io", "leases", "get"}},
+		},
+		{
+			desc:        "node IPAM without multi-network",
+			controllers: []string{"nodeipam"},
+			want:        []access{{"", "nodes", "patch"}},
+			wantDenied:  []access{{"networking.gke.io", "networks", "list"}},
+		},
+		{
+			desc:          "range allocator",
+			controllers:   []string{"nodeipam"},
+			allocatorType: "RangeAllocator",
+			multiNetwork:  true,
+			wantDenied:    []access{{"networking.gke.io", "networks", "list"}},
+		},
+		{
+			desc:                   "node IPAM with coordination leases and stranded pod CIDRs",
+			controllers:            []string{"nodeipam"},
+			multiNetwork:           true,
+			nodeCoordinationLeases: true,
+			strandedPodCIDRs:       true,
+			want:                   []access{{"coordination.k8s.io", "leases", "update"}, {"", "pods", "watch"}},
+		},
+		{
+			desc:                "node IPAM with pod IP reconciliation",
+			controllers:         []string{"nodeipam"},
+			podIPReconciliation: true,
+			want:                []access{{"", "pods", "list"}, {"", "pods", "watch"}},
+		},
+		{
+			desc:                  "node IPAM with allocation checkpoints",
+			controllers:           []string{"nodeipam"},
+			allocationCheckpoints: true,
+			wantNetwork:           []access{{"networking.gke.io", "nodeipamcheckpoints", "list"}, {"networking.gke.io", "nodeipamcheckpoints", "update"}, {"networking.gke.io", "nodeipamcheckpoints", "delete"}},
+			wantDenied:            []access{{"networking.gke.io", "networks", "list"}},
+		},
+		{
+			desc:         "node IPAM with health status",
+			controllers:  []string{"nodeipam"},
+			healthStatus: true,
+			wantNetwork:  []access{{"networking.gke.io", "nodeipamhealths", "get"}, {"networking.gke.io", "nodeipamhealths", "create"}, {"networking.gke.io", "nodeipamhealths", "update"}},
+			wantDenied:   []access{{"networking.gke.io", "nodeipamcheckpoints", "list"}},
+		},
+		{
+			desc:        "routes off",
+			controllers: []string{"*", "-route", "-nodeipam"},
+			want:        []access{{"", "services/status", "patch"}, {"", "nodes", "delete"}, {"networking.gke.io", "gkenetworkparamsets/status", "patch"}},
+			wantDenied:  []access{{"networking.gke.io", "networks", "list"}},
+		},
+		{
+			desc:        "opt-in controllers",
+			controllers: []string{"networkusage", "multinetworkreadiness"},
+			want:        []access{{"networking.gke.io", "networks", "update"}, {"", "pods/status", "update"}, {"", "nodes", "watch"}},
+			wantDenied:  []access{{"", "nodes", "patch"}, {"", "services", "list"}},
+		},
+		{
+			desc:        "route garbage collection",
+			controllers: []string{"routegc"},
+			want:        []access{{"", "nodes", "list"}, {"", "nodes", "watch"}},
+			wantDenied:  []access{{"", "nodes", "delete"}, {"", "nodes/status", "patch"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			enabled, err := enabledControllers(tc.controllers)
+			if err != nil {
+				t.Fatalf("enabledControllers() returned err %v", err)
+			}
+			cfg, err := nodeipamconfigscheme.Default()
+			if err != nil {
+				t.Fatalf("Default() returned err %v", err)
+			}
+			cfg.CIDRAllocatorType = tc.allocatorType
+			cfg.MultiNetwork.Enabled = tc.multiNetwork
+			cfg.MultiNetwork.NodeCoordinationLeases = tc.nodeCoordinationLeases
+			if tc.strandedPodCIDRs {
+				cfg.StrandedPodCIDRThreshold = metav1.Duration{Duration: time.Hour}
+			}
+			cfg.PodIPReconciliation = tc.podIPReconciliation
+			cfg.AllocationCheckpoints = tc.allocationCheckpoints
+			cfg.HealthStatus = tc.healthStatus
+			perms, networkPerms := permissions(features{controllers: enabled, nodeIPAM: cfg})
+			rules, networkRules := policyRules(perms), policyRules(networkPerms)
+			// Every controller needs leader election and events.
+			for _, a := range append(tc.want, access{"", "events", "create"}, access{"coordination.k8s.io", "leases", "create"}) {
+				if !allows(rules, a.group, a.resource, a.verb) {
+					t.Errorf("rules do not allow %s of %s in group %q", a.verb, a.resource, a.group)
+				}
+			}
+			for _, a := range tc.wantNetwork {
+				if !allows(networkRules, a.group, a.resource, a.verb) {
+					t.Errorf("network rules do not allow %s of %s in group %q", a.verb, a.resource, a.group)
+				}
+			}
+			for _, a := range tc.wantDenied {
+				if allows(rules, a.group, a.resource, a.verb) || allows(networkRules, a.group, a.resource, a.verb) {
+					t.Errorf("rules allow %s of %s in group %q", a.verb, a.resource, a.group)
+				}
+			}
+		})
+	}
+}
+
+func TestPermissionsPodCIDROwnershipLease(t *testing.T) {
+	cfg, err := nodeipamconfigscheme.Default()
+	if err != nil {
+		t.Fatalf("Default() returned err %v", err)
+	}
+	cfg.PodCIDROwnershipLease = true
+	perms, _ := permissions(features{controllers: sets.NewString("nodeipam"), nodeIPAM: cfg})
+	want := rbacv1.PolicyRule{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, ResourceNames: []string{wellknown.PodCIDROwnershipLeaseName}, Verbs: []string{"get", "update"}}
+	for _, rule := range policyRules(perms) {
+		if cmp.Equal(want, rule) {
+			return
+		}
+	}
+	t.Errorf("rules do not allow getting and updating the pod CIDR ownership lease")
+}
+
+func TestPolicyRules(t *testing.T) {
+	got := policyRules([]permission{
+		{group: "", resource: "nodes", verbs: []string{"list", "watch"}},
+		{group: "", resource: "services", verbs: []string{"watch", "list"}},
+		{group: "", resource: "nodes", verbs: []string{"watch"}},
+		{group: "coordination.k8s.io", resource: "leases", resourceName: "cloud-controller-manager", verbs: []string{"get"}},
+		{group: "coordination.k8s.io", resource: "leases", verbs: []string{"create"}},
+	})
+	want := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"nodes", "services"}, Verbs: []string{"list", "watch"}},
+		{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, ResourceNames: []string{"cloud-controller-manager"}, Verbs: []string{"get"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("policyRules() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGenerateNetworkRole(t *testing.T) {
+	cfg, err := nodeipamconfigscheme.Default()
+	if err != nil {
+		t.Fatalf("Default() returned err %v", err)
+	}
+	f := features{controllers: sets.NewString("nodeipam"), nodeIPAM: cfg}
+	testCases := []struct {
+		networkName string
+		wantRoles   []string
+	}{
+		{wantRoles: []string{"ccm"}},
+		{networkName: "ccm-networks", wantRoles: []string{"ccm", "ccm-networks"}},
+	}
+	for _, tc := range testCases {
+		data, err := generate("ccm", tc.networkName, f)
+		if err != nil {
+			t.Fatalf("generate() returned err %v", err)
+		}
+		var roles []rbacv1.ClusterRole
+		for _, doc := range strings.Split(string(data), "---\n")[1:] {
+			var role rbacv1.ClusterRole
+			if err := yaml.UnmarshalStrict([]byte(doc), &role); err != nil {
+				t.Fatalf("generate() returned invalid YAML %q: %v", doc, err)
+			}
+			roles = append(roles, role)
+		}
+		var names []string
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+		if diff := cmp.Diff(tc.wantRoles, names); diff != "" {
+			t.Fatalf("generate(%q) roles mismatch (-want +got):\n%s", tc.networkName, diff)
+		}
+		// The networks are only granted to the network role if there is one.
+		last := roles[len(roles)-1]
+		if !allows(last.Rules, "networking.gke.io", "networks", "list") {
+			t.Errorf("generate(%q): role %s does not allow listing networks", tc.networkName, last.Name)
+		}
+		if len(roles) > 1 && allows(roles[0].Rules, "networking.gke.io", "networks", "list") {
+			t.Errorf("generate(%q): role %s allows listing networks", tc.networkName, roles[0].Name)
+		}
+	}
+}
@@ -129,6 +129,14 @@ type NodeNetwork struct {
 	Cidrs []string `json:"cidrs"`
 	// Scope specifies if the network is local to a node or global across a node pool.
 	Scope string `json:"scope"`
+	// TrafficClass is the DSCP class the traffic of the network is marked
+	// with, e.g. "AF41", set if the Network declares one.
+	TrafficClass string `json:"trafficClass,omitempty"`
+	// DSCP is the DSCP value of TrafficClass.
+	DSCP *int `json:"dscp,omitempty"`
+	// Gateway is the gateway address of the network on the node, set if the
+	// GKENetworkParamSet of the network declares a node gateway.
+	Gateway string `json:"gateway,omitempty"`
 }
 
 // NorthInterface specifies interface data on a node.
@@ -138,6 +146,9 @@ type NorthInterface struct {
 	Network string `json:"network"`
 	// IP address of the interface.
 	IpAddress string `json:"ipAddress"`
+	// IPv6 address of the interface, set if the interface has an IPv6
+	// configuration and the network supports IPv6.
+	IpV6Address string `json:"ipV6Address,omitempty"`
 }
 
 // ParseNodeNetworkAnnotation parses the given annotation to NodeNetworkAnnotation.
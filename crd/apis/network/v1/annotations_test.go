@@ -196,6 +196,14 @@ func TestParseNorthInterfacesAnnotation(t *testing.T) {
 			},
 			expected: `[{"network":"network-a","ipAddress":"10.0.0.1"},{"network":"network-b","ipAddress":"20.0.0.1"}]`,
 		},
+		{
+			name: "list with IPv6 addresses",
+			input: NorthInterfacesAnnotation{
+				{Network: "network-a", IpAddress: "10.0.0.1", IpV6Address: "2001:db8::1"},
+				{Network: "network-b", IpAddress: "20.0.0.1"},
+			},
+			expected: `[{"network":"network-a","ipAddress":"10.0.0.1","ipV6Address":"2001:db8::1"},{"network":"network-b","ipAddress":"20.0.0.1"}]`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -85,6 +85,19 @@ type NetworkSpec struct {
 	// +optional
 	NodeInterfaceMatcher NodeInterfaceMatcher `json:"nodeInterfaceMatcher,omitempty"`
 
+	// NodeSelector restricts the network to the nodes whose labels match it.
+	// Nodes not matching it are not attached to the network, even if they
+	// have an interface on it. An empty selector matches every node.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// IPResourceName is the extended resource name the IP capacity of the
+	// network is published under on the nodes, e.g. example.com/red-ip, so
+	// that schedulers relying on the resource names of a device plugin keep
+	// working. It defaults to networking.gke.io.networks/<name>.IP.
+	// +optional
+	IPResourceName string `json:"ipResourceName,omitempty"`
+
 	// L2NetworkConfig includes all the network config related to L2 type network
 	// +optional
 	L2NetworkConfig *L2NetworkConfig `json:"l2NetworkConfig,omitempty"`
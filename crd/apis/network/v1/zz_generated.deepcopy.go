@@ -287,6 +287,13 @@ func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
 		**out = **in
 	}
 	in.NodeInterfaceMatcher.DeepCopyInto(&out.NodeInterfaceMatcher)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.L2NetworkConfig != nil {
 		in, out := &in.L2NetworkConfig, &out.L2NetworkConfig
 		*out = new(L2NetworkConfig)
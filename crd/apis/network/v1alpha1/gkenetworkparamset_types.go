@@ -32,8 +32,17 @@ const (
 
 // SecondaryRanges represents ranges of network addresses.
 type SecondaryRanges struct {
+	// RangeNames are the names of the secondary ranges of the subnet, or their
+	// full paths, e.g.
+	// projects/p/regions/r/subnetworks/s/secondaryIpRanges/pods.
 	// +kubebuilder:validation:MinItems:=1
 	RangeNames []string `json:"rangeNames"`
+
+	// ZoneRangeNames maps a zone to the names of the secondary ranges used by
+	// the nodes of the zone, so that pod IPs stay zonal. Nodes of zones that
+	// are not in the map use RangeNames.
+	// +optional
+	ZoneRangeNames map[string][]string `json:"zoneRangeNames,omitempty"`
 }
 
 // GKENetworkParamSetSpec contains the specifications for network object
@@ -56,6 +65,22 @@ type GKENetworkParamSetSpec struct {
 	// This field is required and valid only for L3 typed network
 	// +optional
 	PodIPv4Ranges *SecondaryRanges `json:"podIPv4Ranges,omitempty"`
+
+	// NodeGateway publishes a gateway address per node for the network, taken
+	// from the CIDR of the node, so that CNI plugins and route agents agree on
+	// it. No gateway address is published if it is not set.
+	// +optional
+	NodeGateway *NodeGateway `json:"nodeGateway,omitempty"`
+}
+
+// NodeGateway selects the gateway address of the nodes in their CIDR.
+type NodeGateway struct {
+	// Offset is the offset of the gateway address from the first address of
+	// the CIDR of the node. The first usable address, offset 1, is used if it
+	// is not set.
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	Offset *int32 `json:"offset,omitempty"`
 }
 
 // NetworkRanges represents ranges of network addresses.
@@ -69,6 +94,27 @@ type GKENetworkParamSetStatus struct {
 	// PodCIDRs specifies the CIDRs from which IPs will be used for Pod interfaces
 	// +optional
 	PodCIDRs *NetworkRanges `json:"podCIDRs,omitempty"`
+
+	// SecondaryRanges lists the secondary ranges of the VPC subnet, as last
+	// observed in GCE.
+	// +optional
+	SecondaryRanges []SubnetSecondaryRange `json:"secondaryRanges,omitempty"`
+
+	// Conditions is a list of the conditions of the GKENetworkParamSet, e.g.
+	// Ready.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SubnetSecondaryRange is a secondary range of a VPC subnet.
+type SubnetSecondaryRange struct {
+	// RangeName is the name of the secondary range.
+	RangeName string `json:"rangeName"`
+
+	// IPCIDRRange is the CIDR of the secondary range.
+	IPCIDRRange string `json:"ipCidrRange"`
 }
 
 // +genclient
@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -117,6 +118,11 @@ func (in *GKENetworkParamSetSpec) DeepCopyInto(out *GKENetworkParamSetSpec) {
 		*out = new(SecondaryRanges)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.NodeGateway != nil {
+		in, out := &in.NodeGateway, &out.NodeGateway
+		*out = new(NodeGateway)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GKENetworkParamSetSpec.
@@ -137,6 +143,18 @@ func (in *GKENetworkParamSetStatus) DeepCopyInto(out *GKENetworkParamSetStatus)
 		*out = new(NetworkRanges)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SecondaryRanges != nil {
+		in, out := &in.SecondaryRanges, &out.SecondaryRanges
+		*out = make([]SubnetSecondaryRange, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GKENetworkParamSetStatus.
@@ -433,6 +451,26 @@ func (in *NetworkStatus) DeepCopy() *NetworkStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeGateway) DeepCopyInto(out *NodeGateway) {
+	*out = *in
+	if in.Offset != nil {
+		in, out := &in.Offset, &out.Offset
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeGateway.
+func (in *NodeGateway) DeepCopy() *NodeGateway {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeGateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeInterfaceMatcher) DeepCopyInto(out *NodeInterfaceMatcher) {
 	*out = *in
@@ -476,6 +514,22 @@ func (in *SecondaryRanges) DeepCopyInto(out *SecondaryRanges) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ZoneRangeNames != nil {
+		in, out := &in.ZoneRangeNames, &out.ZoneRangeNames
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecondaryRanges.
@@ -487,3 +541,18 @@ func (in *SecondaryRanges) DeepCopy() *SecondaryRanges {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetSecondaryRange) DeepCopyInto(out *SubnetSecondaryRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetSecondaryRange.
+func (in *SubnetSecondaryRange) DeepCopy() *SubnetSecondaryRange {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetSecondaryRange)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,122 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+// ConversionDataAnnotationKey holds the fields of a v2 Network that have no
+// v1 counterpart, e.g. the parameters of the additional subnets, on the v1
+// Network it is converted to, so that converting it back to v2 is lossless.
+const ConversionDataAnnotationKey = "networking.gke.io/v2-conversion-data"
+
+// conversionData is the value of ConversionDataAnnotationKey.
+type conversionData struct {
+	// Parameters are the parameters after the first one.
+	Parameters []NetworkParametersReference `json:"parameters,omitempty"`
+	// Conditions are the conditions of the status.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConvertToV1 converts the v2 Network to the v1 storage version. The first
+// parameters are stored in ParametersRef, and the other parameters and the
+// conditions in ConversionDataAnnotationKey.
+func ConvertToV1(in *Network, out *v1.Network) error {
+	out.TypeMeta = metav1.TypeMeta{APIVersion: v1.SchemeGroupVersion.String(), Kind: "Network"}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	delete(out.Annotations, ConversionDataAnnotationKey)
+
+	spec := in.Spec.DeepCopy()
+	out.Spec = v1.NetworkSpec{
+		Type:                 v1.NetworkType(spec.Type),
+		Provider:             (*v1.ProviderType)(spec.Provider),
+		NodeInterfaceMatcher: v1.NodeInterfaceMatcher{InterfaceName: spec.NodeInterface.InterfaceName},
+		NodeSelector:         spec.NodeSelector,
+		IPResourceName:       spec.IPResourceName,
+		NetworkLifecycle:     (*v1.LifecycleType)(spec.Lifecycle),
+		Gateway4:             spec.IPv4Gateway,
+		ExternalDHCP4:        spec.IPv4ExternalDHCP,
+	}
+	if spec.L2 != nil {
+		out.Spec.L2NetworkConfig = &v1.L2NetworkConfig{VlanID: spec.L2.VlanID, PrefixLength4: spec.L2.IPv4PrefixLength}
+	}
+	for _, route := range spec.Routes {
+		out.Spec.Routes = append(out.Spec.Routes, v1.Route{To: route.To})
+	}
+	if spec.DNSConfig != nil {
+		out.Spec.DNSConfig = &v1.DNSConfig{Nameservers: spec.DNSConfig.Nameservers, Searches: spec.DNSConfig.Searches}
+	}
+	var data conversionData
+	if len(spec.Parameters) > 0 {
+		ref := spec.Parameters[0]
+		out.Spec.ParametersRef = &v1.NetworkParametersReference{Group: ref.Group, Kind: ref.Kind, Name: ref.Name, Namespace: ref.Namespace}
+		data.Parameters = spec.Parameters[1:]
+	}
+	out.Status = v1.NetworkStatus{}
+	if len(in.Status.Conditions) > 0 {
+		data.Conditions = in.DeepCopy().Status.Conditions
+	}
+
+	if len(data.Parameters) == 0 && len(data.Conditions) == 0 {
+		if len(out.Annotations) == 0 {
+			out.Annotations = nil
+		}
+		return nil
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the v2 fields of network %s: %v", in.Name, err)
+	}
+	if out.Annotations == nil {
+		out.Annotations = make(map[string]string)
+	}
+	out.Annotations[ConversionDataAnnotationKey] = string(value)
+	return nil
+}
+
+// ConvertFromV1 converts the v1 storage version to the v2 Network, restoring
+// the fields stored in ConversionDataAnnotationKey by ConvertToV1.
+func ConvertFromV1(in *v1.Network, out *Network) error {
+	out.TypeMeta = metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "Network"}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	var data conversionData
+	if value, ok := out.Annotations[ConversionDataAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &data); err != nil {
+			return fmt.Errorf("invalid %s annotation on network %s: %v", ConversionDataAnnotationKey, in.Name, err)
+		}
+		delete(out.Annotations, ConversionDataAnnotationKey)
+		if len(out.Annotations) == 0 {
+			out.Annotations = nil
+		}
+	}
+
+	spec := in.Spec.DeepCopy()
+	out.Spec = NetworkSpec{
+		Type:             NetworkType(spec.Type),
+		Provider:         (*ProviderType)(spec.Provider),
+		NodeInterface:    NodeInterfaceMatcher{InterfaceName: spec.NodeInterfaceMatcher.InterfaceName},
+		NodeSelector:     spec.NodeSelector,
+		IPResourceName:   spec.IPResourceName,
+		Lifecycle:        (*LifecycleType)(spec.NetworkLifecycle),
+		IPv4Gateway:      spec.Gateway4,
+		IPv4ExternalDHCP: spec.ExternalDHCP4,
+	}
+	if spec.L2NetworkConfig != nil {
+		out.Spec.L2 = &L2NetworkConfig{VlanID: spec.L2NetworkConfig.VlanID, IPv4PrefixLength: spec.L2NetworkConfig.PrefixLength4}
+	}
+	for _, route := range spec.Routes {
+		out.Spec.Routes = append(out.Spec.Routes, Route{To: route.To})
+	}
+	if spec.DNSConfig != nil {
+		out.Spec.DNSConfig = &DNSConfig{Nameservers: spec.DNSConfig.Nameservers, Searches: spec.DNSConfig.Searches}
+	}
+	if ref := spec.ParametersRef; ref != nil {
+		out.Spec.Parameters = append([]NetworkParametersReference{{Group: ref.Group, Kind: ref.Kind, Name: ref.Name, Namespace: ref.Namespace}}, data.Parameters...)
+	}
+	out.Status = NetworkStatus{Conditions: data.Conditions}
+	return nil
+}
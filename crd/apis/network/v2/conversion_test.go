@@ -0,0 +1,155 @@
+package v2
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestRoundTripFromV2(t *testing.T) {
+	provider := GKE
+	lifecycle := UserManagedLifecycle
+	dhcp := true
+	testCases := []struct {
+		desc string
+		in   *Network
+		want *v1.Network
+	}{
+		{
+			desc: "minimal network",
+			in: &Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "red"},
+				Spec:       NetworkSpec{Type: L3NetworkType},
+			},
+			want: &v1.Network{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "networking.gke.io/v1", Kind: "Network"},
+				ObjectMeta: metav1.ObjectMeta{Name: "red"},
+				Spec:       v1.NetworkSpec{Type: v1.L3NetworkType},
+			},
+		},
+		{
+			desc: "renamed fields",
+			in: &Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "red", Annotations: map[string]string{"a": "b"}},
+				Spec: NetworkSpec{
+					Type:             L2NetworkType,
+					Provider:         &provider,
+					NodeInterface:    NodeInterfaceMatcher{InterfaceName: stringPtr("eth1")},
+					NodeSelector:     map[string]string{"pool": "red"},
+					IPResourceName:   "example.com/red-ip",
+					L2:               &L2NetworkConfig{VlanID: int32Ptr(10), IPv4PrefixLength: int32Ptr(24)},
+					Lifecycle:        &lifecycle,
+					Routes:           []Route{{To: "10.0.0.0/8"}},
+					IPv4Gateway:      stringPtr("10.0.0.1"),
+					DNSConfig:        &DNSConfig{Nameservers: []string{"8.8.8.8"}, Searches: []string{"example.com"}},
+					IPv4ExternalDHCP: &dhcp,
+					Parameters:       []NetworkParametersReference{{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red"}},
+				},
+			},
+			want: &v1.Network{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "networking.gke.io/v1", Kind: "Network"},
+				ObjectMeta: metav1.ObjectMeta{Name: "red", Annotations: map[string]string{"a": "b"}},
+				Spec: v1.NetworkSpec{
+					Type:                 v1.L2NetworkType,
+					Provider:             (*v1.ProviderType)(&provider),
+					NodeInterfaceMatcher: v1.NodeInterfaceMatcher{InterfaceName: stringPtr("eth1")},
+					NodeSelector:         map[string]string{"pool": "red"},
+					IPResourceName:       "example.com/red-ip",
+					L2NetworkConfig:      &v1.L2NetworkConfig{VlanID: int32Ptr(10), PrefixLength4: int32Ptr(24)},
+					NetworkLifecycle:     (*v1.LifecycleType)(&lifecycle),
+					Routes:               []v1.Route{{To: "10.0.0.0/8"}},
+					Gateway4:             stringPtr("10.0.0.1"),
+					DNSConfig:            &v1.DNSConfig{Nameservers: []string{"8.8.8.8"}, Searches: []string{"example.com"}},
+					ExternalDHCP4:        &dhcp,
+					ParametersRef:        &v1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red"},
+				},
+			},
+		},
+		{
+			desc: "multi-subnet parameters and conditions",
+			in: &Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "red"},
+				Spec: NetworkSpec{
+					Type: L3NetworkType,
+					Parameters: []NetworkParametersReference{
+						{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-a"},
+						{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-b"},
+					},
+				},
+				Status: NetworkStatus{Conditions: []metav1.Condition{{Type: NetworkReady, Status: metav1.ConditionTrue, Reason: "ParamsValid"}}},
+			},
+			want: &v1.Network{
+				TypeMeta: metav1.TypeMeta{APIVersion: "networking.gke.io/v1", Kind: "Network"},
+				ObjectMeta: metav1.ObjectMeta{Name: "red", Annotations: map[string]string{
+					ConversionDataAnnotationKey: `{"parameters":[{"group":"networking.gke.io","kind":"GKENetworkParamSet","name":"red-b"}],"conditions":[{"type":"Ready","status":"True","lastTransitionTime":null,"reason":"ParamsValid","message":""}]}`,
+				}},
+				Spec: v1.NetworkSpec{
+					Type:          v1.L3NetworkType,
+					ParametersRef: &v1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-a"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := &v1.Network{}
+			if err := ConvertToV1(tc.in, got); err != nil {
+				t.Fatalf("ConvertToV1() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ConvertToV1() mismatch (-want +got):\n%s", diff)
+			}
+
+			back := &Network{}
+			if err := ConvertFromV1(got, back); err != nil {
+				t.Fatalf("ConvertFromV1() returned err %v", err)
+			}
+			want := tc.in.DeepCopy()
+			want.TypeMeta = metav1.TypeMeta{APIVersion: "networking.gke.io/v2", Kind: "Network"}
+			if diff := cmp.Diff(want, back); diff != "" {
+				t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRoundTripFromV1(t *testing.T) {
+	in := &v1.Network{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.gke.io/v1", Kind: "Network"},
+		ObjectMeta: metav1.ObjectMeta{Name: "red", Labels: map[string]string{"a": "b"}},
+		Spec: v1.NetworkSpec{
+			Type:                 v1.L3NetworkType,
+			NodeInterfaceMatcher: v1.NodeInterfaceMatcher{InterfaceName: stringPtr("eth1")},
+			L2NetworkConfig:      &v1.L2NetworkConfig{PrefixLength4: int32Ptr(24)},
+			ParametersRef:        &v1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red", Namespace: stringPtr("ns")},
+		},
+	}
+	v2 := &Network{}
+	if err := ConvertFromV1(in, v2); err != nil {
+		t.Fatalf("ConvertFromV1() returned err %v", err)
+	}
+	got := &v1.Network{}
+	if err := ConvertToV1(v2, got); err != nil {
+		t.Fatalf("ConvertToV1() returned err %v", err)
+	}
+	if diff := cmp.Diff(in, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConvertFromV1InvalidConversionData(t *testing.T) {
+	in := &v1.Network{ObjectMeta: metav1.ObjectMeta{Name: "red", Annotations: map[string]string{ConversionDataAnnotationKey: "{"}}}
+	if err := ConvertFromV1(in, &Network{}); err == nil {
+		t.Errorf("ConvertFromV1() returned no error for an invalid %s annotation", ConversionDataAnnotationKey)
+	}
+}
@@ -0,0 +1,19 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v2 is the v2 version of the API. It is converted from and to the
+// v1 storage version by the conversion webhook of the Network CRD, see
+// k8s.io/cloud-provider-gcp/crd/conversion.
+// +kubebuilder:object:generate=true
+// +groupName=networking.gke.io
+package v2
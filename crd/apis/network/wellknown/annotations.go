@@ -0,0 +1,166 @@
+package wellknown
+
+import networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+
+// Annotations of the nodes.
+const (
+	// NorthInterfacesAnnotationKey holds the north interfaces of the node, one
+	// per additional network.
+	NorthInterfacesAnnotationKey = networkv1.NorthInterfacesAnnotationKey
+	// MultiNetworkAnnotationKey holds the additional networks of the node and
+	// their pod CIDRs.
+	MultiNetworkAnnotationKey = networkv1.MultiNetworkAnnotationKey
+	// LegacyNorthInterfacesAnnotationKey is the key of the north interfaces
+	// annotation read by the dataplane agents predating
+	// NorthInterfacesAnnotationKey.
+	LegacyNorthInterfacesAnnotationKey = "container.googleapis.com/north-interfaces"
+	// LegacyMultiNetworkAnnotationKey is the key of the networks annotation
+	// read by the dataplane agents predating MultiNetworkAnnotationKey.
+	LegacyMultiNetworkAnnotationKey = "container.googleapis.com/networks"
+	// InstanceIDAnnotationKey is set to the ID of the instance whose network
+	// interfaces the pod CIDRs and multi-network annotations of the node were
+	// allocated from. An instance recreated with the same name has a new ID.
+	InstanceIDAnnotationKey = "networking.gke.io/instance-id"
+	// DelegatedRangesAnnotationKey holds the secondary ranges a node agent may
+	// attach for every additional network.
+	DelegatedRangesAnnotationKey = "networking.gke.io/delegated-ranges"
+	// IPResourceNamesAnnotationKey holds the extended resources advertising
+	// the IP capacity of the additional networks of the node.
+	IPResourceNamesAnnotationKey = "networking.gke.io/ip-resource-names"
+	// ExpectedNetworksAnnotationKey holds the JSON list of the additional
+	// networks the node is expected to join before its allocation completes.
+	ExpectedNetworksAnnotationKey = "networking.gke.io/expected-networks"
+	// InterfaceReservationsAnnotationKey holds the ledger of the addresses of
+	// the additional network ranges of the node reserved to individual pods.
+	// Pod IPAM on the node must not hand out reserved addresses.
+	InterfaceReservationsAnnotationKey = "networking.gke.io/interface-reservations"
+	// PinnedNetworksAnnotationKey can be set by operators to a JSON map from
+	// additional network name to the CIDRs published instead of the
+	// allocated ones.
+	PinnedNetworksAnnotationKey = "networking.gke.io/pinned-networks"
+	// StrandedSinceAnnotationKey is set to the RFC 3339 time since which the
+	// pod CIDR of the node is not used by any pod.
+	StrandedSinceAnnotationKey = "networking.gke.io/pod-cidr-stranded-since"
+	// IPCapacityPendingAnnotationKey is "true" on nodes whose multi-network
+	// annotations were published but whose IP capacity was not updated yet,
+	// and "false" once it was.
+	IPCapacityPendingAnnotationKey = "networking.gke.io/ip-capacity-pending"
+	// CIDRAllocationRetriesAnnotationKey is set on nodes whose updates failed
+	// to the number of retries already scheduled. It is removed after a
+	// successful update.
+	CIDRAllocationRetriesAnnotationKey = "networking.gke.io/cidr-allocation-retries"
+	// CompactAnnotationKey is the annotation holding all the multi-network
+	// data of a node in a single value. It is written instead of the
+	// NorthInterfacesAnnotationKey and MultiNetworkAnnotationKey annotations
+	// by controllers configured with the compact encoding, for nodes attached
+	// to so many networks that the per-key annotations grow too large.
+	//
+	// The value is a JSON object holding the values of the per-key
+	// annotations:
+	//
+	//	{"northInterfaces":[...],"networks":[...]}
+	//
+	// or the base64 encoding of the gzip compression of that object. Nodes
+	// carrying it are read from it, whatever their per-key annotations.
+	CompactAnnotationKey = "networking.gke.io/compact-networks"
+)
+
+// Labels of the nodes.
+const (
+	// SliceLabelKey is set on the nodes of a multi-host accelerator slice to
+	// the name of the slice.
+	SliceLabelKey = "cloud.google.com/gke-accelerator-slice"
+	// SliceHostLabelKey is set on the nodes of a multi-host accelerator slice
+	// to the index of the host in the slice. Host 0 is the primary host.
+	SliceHostLabelKey = "cloud.google.com/gke-accelerator-slice-host"
+	// NetworkPerformanceTierLabelKey is set to the total egress bandwidth
+	// tier configured on the instance of the node, e.g. "tier_1".
+	NetworkPerformanceTierLabelKey = "cloud.google.com/gce-network-performance-tier"
+	// InstanceTemplateLabelKey is set to the name of the instance template
+	// the node was created from.
+	InstanceTemplateLabelKey = "node.gke.io/instance-template"
+	// ReservationAffinityLabelKey is set to the reservation affinity of the
+	// instance of the node: "any", "specific" or "none".
+	ReservationAffinityLabelKey = "node.gke.io/reservation-affinity"
+	// ReservationNameLabelKey is set to the name of the reservation consumed
+	// by instances with a specific reservation affinity.
+	ReservationNameLabelKey = "node.gke.io/reservation-name"
+	// CommittedUseLabelKey is set to "true" when an active commitment of the
+	// region covers the machine series of the instance of the node, and to
+	// "false" otherwise. Spot and preemptible instances are never covered.
+	CommittedUseLabelKey = "node.gke.io/committed-use"
+)
+
+// Annotations of the Networks.
+const (
+	// ClusterSelectorAnnotationKey restricts a Network synced to several
+	// clusters to the clusters whose labels match its label selector.
+	ClusterSelectorAnnotationKey = "networking.gke.io/cluster-selector"
+	// ExternalIPAMAnnotationKey is set to the gRPC endpoint of the external
+	// IPAM provider allocating the per-node ranges of the Network.
+	ExternalIPAMAnnotationKey = "networking.gke.io/external-ipam-endpoint"
+	// IPAllocationAnnotationKey is set to IPAllocationNone on the accelerator
+	// fabric Networks, whose addresses are managed by the fabric.
+	IPAllocationAnnotationKey = "networking.gke.io/ip-allocation"
+	// IPAllocationNone is the value of IPAllocationAnnotationKey of the
+	// Networks without pod CIDRs.
+	IPAllocationNone = "None"
+	// StackTypeAnnotationKey is set to StackTypeDualStack on the Networks
+	// supporting IPv6.
+	StackTypeAnnotationKey = "networking.gke.io/stack-type"
+	// StackTypeDualStack is the value of StackTypeAnnotationKey of the
+	// dual-stack Networks.
+	StackTypeDualStack = "IPV4_IPV6"
+	// NetworkConflictAnnotationKey holds the JSON encoded metav1.Condition of
+	// type NetworkConflictConditionType of a Network referring to the same
+	// secondary range as an older Network.
+	NetworkConflictAnnotationKey = "networking.gke.io/network-conflict"
+	// NetworkPausedAnnotationKey can be set to "true" to suspend the changes
+	// to the nodes attached to the Network.
+	NetworkPausedAnnotationKey = "networking.gke.io/paused"
+	// NetworkPausedConditionAnnotationKey holds the JSON encoded
+	// metav1.Condition of type NetworkPausedConditionType of a paused Network.
+	NetworkPausedConditionAnnotationKey = "networking.gke.io/paused-condition"
+	// TrafficClassAnnotationKey can be set to the DSCP class the traffic of
+	// the Network is marked with, e.g. "AF41" or "EF".
+	TrafficClassAnnotationKey = "networking.gke.io/traffic-class"
+	// NodeCleanupWebhookAnnotationKey is set to the URL of the hook called
+	// when a node of the Network is deleted.
+	NodeCleanupWebhookAnnotationKey = "networking.gke.io/node-cleanup-webhook"
+	// UsageAnnotationKey holds the aggregated usage of the Network in the
+	// cluster.
+	UsageAnnotationKey = "networking.gke.io/usage"
+	// DefaultNetworkAnnotationKey is set to "true" on the Network the pod
+	// CIDRs of the nodes are allocated from, for deployments not naming it
+	// after networkv1.DefaultPodNetworkName.
+	DefaultNetworkAnnotationKey = "networking.gke.io/default-network"
+)
+
+// Annotations of the GKENetworkParamSets.
+const (
+	// ConditionsAnnotationKey holds the JSON encoded []metav1.Condition of a
+	// GKENetworkParamSet.
+	ConditionsAnnotationKey = "networking.gke.io/conditions"
+	// PerNodeMaskSizeAnnotationKey can be set to the prefix length of the
+	// alias IP ranges attached to every node for the Network, e.g. "26".
+	PerNodeMaskSizeAnnotationKey = "networking.gke.io/pod-ipv4-per-node-mask-size"
+	// NicTypeAnnotationKey can be set to restrict the Networks to the node
+	// interfaces of the given vNIC type, e.g. "GVNIC" or "VIRTIO_NET".
+	NicTypeAnnotationKey = "networking.gke.io/nic-type"
+)
+
+// Annotations and finalizers of the node coordination Leases.
+const (
+	// PublishedAnnotationKey holds the digest of the multi-network state last
+	// published on the node by the allocator.
+	PublishedAnnotationKey = "networking.gke.io/published"
+	// AcknowledgedAnnotationKey holds the digest of the multi-network state
+	// last set up by the node agent.
+	AcknowledgedAnnotationKey = "networking.gke.io/acknowledged"
+	// CleanupNetworksAnnotationKey lists, comma separated, the networks whose
+	// cleanup hooks are pending for the node.
+	CleanupNetworksAnnotationKey = "networking.gke.io/cleanup-networks"
+	// NodeCleanupFinalizer keeps the coordination Lease of a deleted node
+	// until the cleanup hooks of its networks complete.
+	NodeCleanupFinalizer = "networking.gke.io/node-network-cleanup"
+)
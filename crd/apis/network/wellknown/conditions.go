@@ -0,0 +1,117 @@
+package wellknown
+
+// Condition types of the nodes.
+const (
+	// CIDRAllocationFailedCondition is true on the nodes whose CIDR update
+	// failed repeatedly.
+	CIDRAllocationFailedCondition = "CIDRAllocationFailed"
+	// PinnedAllocationCondition is true on the nodes whose additional network
+	// CIDRs are pinned by PinnedNetworksAnnotationKey.
+	PinnedAllocationCondition = "PinnedAllocation"
+	// VPCNotAttachedToNodeCondition is true on the nodes that have no
+	// interface in the VPC of a Network, but one in a VPC peered with it.
+	VPCNotAttachedToNodeCondition = "VPCNotAttachedToNode"
+)
+
+// Reasons of the node conditions.
+const (
+	CIDRAllocationFailedReason    = "RepeatedAllocationFailures"
+	CIDRAllocationSucceededReason = "CIDRAllocated"
+	NetworksPinnedReason          = "NetworksPinned"
+	InvalidPinnedNetworksReason   = "InvalidPinnedNetworks"
+	NetworksNotPinnedReason       = "NetworksNotPinned"
+	VPCPeeredReason               = "VPCPeeredNotAttached"
+	VPCsAttachedReason            = "VPCsAttached"
+)
+
+// Condition types of the Networks and GKENetworkParamSets.
+const (
+	// NetworkConflictConditionType is the type of the condition stored in
+	// NetworkConflictAnnotationKey.
+	NetworkConflictConditionType = "Conflict"
+	// NetworkPausedConditionType is the type of the condition stored in
+	// NetworkPausedConditionAnnotationKey.
+	NetworkPausedConditionType = "Paused"
+	// SubnetReadyConditionType is false if the subnet referenced by the
+	// GKENetworkParamSet cannot be used for pod networking.
+	SubnetReadyConditionType = "SubnetReady"
+	// SecondaryRangesFoundConditionType is false if the GKENetworkParamSet
+	// names secondary ranges that do not exist in its subnet.
+	SecondaryRangesFoundConditionType = "SecondaryRangesFound"
+	// ParamsReadyConditionType is true once every other condition of the
+	// GKENetworkParamSet is true, i.e. its network can be allocated.
+	ParamsReadyConditionType = "Ready"
+)
+
+// Reasons of the Network and GKENetworkParamSet conditions.
+const (
+	NetworkConflictReason           = "DuplicateSecondaryRange"
+	NetworkConflictResolvedReason   = "NetworkConflictResolved"
+	NetworkPausedReason             = "NetworkPaused"
+	NetworkResumedReason            = "NetworkResumed"
+	SubnetReadyReason               = "SubnetReady"
+	IncompatibleSubnetPurposeReason = "IncompatibleSubnetPurpose"
+	SecondaryRangesFoundReason      = "SecondaryRangesFound"
+	SecondaryRangeNotFoundReason    = "SecondaryRangeNotFound"
+	InvalidSecondaryRangeReason     = "InvalidSecondaryRange"
+	ParamsReadyReason               = "ParamsReady"
+	ParamsNotReadyReason            = "ParamsNotReady"
+)
+
+// Condition types of the pods.
+const (
+	// MultiNetworkReadyConditionType is the pod readiness gate true once the
+	// node of the pod has been allocated all of its additional networks.
+	MultiNetworkReadyConditionType = "networking.gke.io/multi-network-ready"
+)
+
+// Reasons of the pod conditions.
+const (
+	NetworksReadyReason    = "NetworksReady"
+	NetworksNotReadyReason = "NetworksNotReady"
+)
+
+// Condition types of the NodeIPAMHealth.
+const (
+	// ConvergedConditionType is true if every node is up to date.
+	ConvergedConditionType = "Converged"
+	// SheddingLoadConditionType is true if the allocator defers node updates
+	// to shed load.
+	SheddingLoadConditionType = "SheddingLoad"
+)
+
+// Reasons of the NodeIPAMHealth conditions.
+const (
+	AllNodesUpToDateReason = "AllNodesUpToDate"
+	NodesPendingReason     = "NodesPending"
+	QueueSaturatedReason   = "QueueSaturated"
+	UpdatesDeferredReason  = "UpdatesDeferred"
+	NoUpdateDeferredReason = "NoUpdateDeferred"
+)
+
+// Reasons of the events recorded on the nodes, Networks and pods.
+const (
+	InstanceRecreatedReason              = "InstanceRecreated"
+	PodCIDRMaskSizeMismatchReason        = "PodCIDRMaskSizeMismatch"
+	InterfaceReservationsExhaustedReason = "InterfaceReservationsExhausted"
+	InvalidTrafficClassReason            = "InvalidTrafficClass"
+	InvalidNodeGatewayReason             = "InvalidNodeGateway"
+	DuplicateSliceRangeReason            = "DuplicateSliceRange"
+	PodRangeExemptReason                 = "PodRangeExempt"
+	AliasRangeTooSmallReason             = "AliasRangeTooSmall"
+	PodIPOutsidePodCIDRsReason           = "PodIPOutsidePodCIDRs"
+	TooManyAdditionalNetworksReason      = "TooManyAdditionalNetworks"
+	UnmanagedProviderIDReason            = "UnmanagedProviderID"
+
+	// Reasons of the allocation errors, also the reason label of the
+	// allocation errors metric.
+	MissingProviderIDReason    = "MissingProviderID"
+	MalformedProviderIDReason  = "MalformedProviderID"
+	InstanceNotFoundReason     = "InstanceNotFound"
+	CloudAPIErrorReason        = "CloudAPIError"
+	NoMatchingRangeReason      = "NoMatchingRange"
+	InvalidNetworkParamsReason = "InvalidNetworkParams"
+	ExternalIPAMErrorReason    = "ExternalIPAMError"
+	NodeUpdateFailedReason     = "NodeUpdateFailed"
+	UnknownErrorReason         = "Unknown"
+)
@@ -0,0 +1,20 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wellknown holds the annotation and label keys, resource names and
+// prefixes, condition types and reasons written by the controllers of
+// cloud-provider-gcp on nodes, Networks, GKENetworkParamSets and the objects
+// they own. CNI plugins and node agents reading them should use these
+// constants rather than copies of their values. The values are part of the
+// contract with these consumers and must not change.
+package wellknown
@@ -0,0 +1,22 @@
+package wellknown
+
+import networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+
+// Names and prefixes of the resources.
+const (
+	// NetworkResourceKeyPrefix is the prefix of the extended resources
+	// advertising the IP capacity of the Networks on the nodes.
+	NetworkResourceKeyPrefix = networkv1.NetworkResourceKeyPrefix
+	// NodeCoordinationLeasePrefix is the prefix of the names of the node
+	// coordination Leases, followed by the name of the node.
+	NodeCoordinationLeasePrefix = "multinetwork-"
+	// PodCIDROwnershipLeaseName is the name of the Lease held by the
+	// controller allocating the pod CIDRs of the nodes.
+	PodCIDROwnershipLeaseName = "pod-cidr-allocator"
+	// NodeIPAMCheckpointPrefix is the prefix of the names of the
+	// NodeIPAMCheckpoints.
+	NodeIPAMCheckpointPrefix = "node-ipam-"
+	// NodeIPAMHealthName is the name of the NodeIPAMHealth of the cloud CIDR
+	// allocator.
+	NodeIPAMHealthName = "cloud-cidr-allocator"
+)
@@ -0,0 +1,118 @@
+package wellknown
+
+import "testing"
+
+// TestValues guards against renames: the values are read by CNI plugins and
+// node agents, changing one breaks them.
+func TestValues(t *testing.T) {
+	for _, tc := range []struct {
+		name, got, want string
+	}{
+		{"NorthInterfacesAnnotationKey", NorthInterfacesAnnotationKey, "networking.gke.io/north-interfaces"},
+		{"MultiNetworkAnnotationKey", MultiNetworkAnnotationKey, "networking.gke.io/networks"},
+		{"LegacyNorthInterfacesAnnotationKey", LegacyNorthInterfacesAnnotationKey, "container.googleapis.com/north-interfaces"},
+		{"LegacyMultiNetworkAnnotationKey", LegacyMultiNetworkAnnotationKey, "container.googleapis.com/networks"},
+		{"InstanceIDAnnotationKey", InstanceIDAnnotationKey, "networking.gke.io/instance-id"},
+		{"DelegatedRangesAnnotationKey", DelegatedRangesAnnotationKey, "networking.gke.io/delegated-ranges"},
+		{"IPResourceNamesAnnotationKey", IPResourceNamesAnnotationKey, "networking.gke.io/ip-resource-names"},
+		{"ExpectedNetworksAnnotationKey", ExpectedNetworksAnnotationKey, "networking.gke.io/expected-networks"},
+		{"InterfaceReservationsAnnotationKey", InterfaceReservationsAnnotationKey, "networking.gke.io/interface-reservations"},
+		{"PinnedNetworksAnnotationKey", PinnedNetworksAnnotationKey, "networking.gke.io/pinned-networks"},
+		{"StrandedSinceAnnotationKey", StrandedSinceAnnotationKey, "networking.gke.io/pod-cidr-stranded-since"},
+		{"IPCapacityPendingAnnotationKey", IPCapacityPendingAnnotationKey, "networking.gke.io/ip-capacity-pending"},
+		{"CIDRAllocationRetriesAnnotationKey", CIDRAllocationRetriesAnnotationKey, "networking.gke.io/cidr-allocation-retries"},
+		{"CompactAnnotationKey", CompactAnnotationKey, "networking.gke.io/compact-networks"},
+		{"SliceLabelKey", SliceLabelKey, "cloud.google.com/gke-accelerator-slice"},
+		{"SliceHostLabelKey", SliceHostLabelKey, "cloud.google.com/gke-accelerator-slice-host"},
+		{"NetworkPerformanceTierLabelKey", NetworkPerformanceTierLabelKey, "cloud.google.com/gce-network-performance-tier"},
+		{"InstanceTemplateLabelKey", InstanceTemplateLabelKey, "node.gke.io/instance-template"},
+		{"ReservationAffinityLabelKey", ReservationAffinityLabelKey, "node.gke.io/reservation-affinity"},
+		{"ReservationNameLabelKey", ReservationNameLabelKey, "node.gke.io/reservation-name"},
+		{"CommittedUseLabelKey", CommittedUseLabelKey, "node.gke.io/committed-use"},
+		{"ClusterSelectorAnnotationKey", ClusterSelectorAnnotationKey, "networking.gke.io/cluster-selector"},
+		{"ExternalIPAMAnnotationKey", ExternalIPAMAnnotationKey, "networking.gke.io/external-ipam-endpoint"},
+		{"IPAllocationAnnotationKey", IPAllocationAnnotationKey, "networking.gke.io/ip-allocation"},
+		{"IPAllocationNone", IPAllocationNone, "None"},
+		{"StackTypeAnnotationKey", StackTypeAnnotationKey, "networking.gke.io/stack-type"},
+		{"StackTypeDualStack", StackTypeDualStack, "IPV4_IPV6"},
+		{"NetworkConflictAnnotationKey", NetworkConflictAnnotationKey, "networking.gke.io/network-conflict"},
+		{"NetworkPausedAnnotationKey", NetworkPausedAnnotationKey, "networking.gke.io/paused"},
+		{"NetworkPausedConditionAnnotationKey", NetworkPausedConditionAnnotationKey, "networking.gke.io/paused-condition"},
+		{"TrafficClassAnnotationKey", TrafficClassAnnotationKey, "networking.gke.io/traffic-class"},
+		{"NodeCleanupWebhookAnnotationKey", NodeCleanupWebhookAnnotationKey, "networking.gke.io/node-cleanup-webhook"},
+		{"UsageAnnotationKey", UsageAnnotationKey, "networking.gke.io/usage"},
+		{"DefaultNetworkAnnotationKey", DefaultNetworkAnnotationKey, "networking.gke.io/default-network"},
+		{"ConditionsAnnotationKey", ConditionsAnnotationKey, "networking.gke.io/conditions"},
+		{"PerNodeMaskSizeAnnotationKey", PerNodeMaskSizeAnnotationKey, "networking.gke.io/pod-ipv4-per-node-mask-size"},
+		{"NicTypeAnnotationKey", NicTypeAnnotationKey, "networking.gke.io/nic-type"},
+		{"PublishedAnnotationKey", PublishedAnnotationKey, "networking.gke.io/published"},
+		{"AcknowledgedAnnotationKey", AcknowledgedAnnotationKey, "networking.gke.io/acknowledged"},
+		{"CleanupNetworksAnnotationKey", CleanupNetworksAnnotationKey, "networking.gke.io/cleanup-networks"},
+		{"NodeCleanupFinalizer", NodeCleanupFinalizer, "networking.gke.io/node-network-cleanup"},
+		{"NetworkResourceKeyPrefix", NetworkResourceKeyPrefix, "networking.gke.io.networks/"},
+		{"NodeCoordinationLeasePrefix", NodeCoordinationLeasePrefix, "multinetwork-"},
+		{"PodCIDROwnershipLeaseName", PodCIDROwnershipLeaseName, "pod-cidr-allocator"},
+		{"NodeIPAMCheckpointPrefix", NodeIPAMCheckpointPrefix, "node-ipam-"},
+		{"NodeIPAMHealthName", NodeIPAMHealthName, "cloud-cidr-allocator"},
+		{"CIDRAllocationFailedCondition", CIDRAllocationFailedCondition, "CIDRAllocationFailed"},
+		{"PinnedAllocationCondition", PinnedAllocationCondition, "PinnedAllocation"},
+		{"VPCNotAttachedToNodeCondition", VPCNotAttachedToNodeCondition, "VPCNotAttachedToNode"},
+		{"CIDRAllocationFailedReason", CIDRAllocationFailedReason, "RepeatedAllocationFailures"},
+		{"CIDRAllocationSucceededReason", CIDRAllocationSucceededReason, "CIDRAllocated"},
+		{"NetworksPinnedReason", NetworksPinnedReason, "NetworksPinned"},
+		{"InvalidPinnedNetworksReason", InvalidPinnedNetworksReason, "InvalidPinnedNetworks"},
+		{"NetworksNotPinnedReason", NetworksNotPinnedReason, "NetworksNotPinned"},
+		{"VPCPeeredReason", VPCPeeredReason, "VPCPeeredNotAttached"},
+		{"VPCsAttachedReason", VPCsAttachedReason, "VPCsAttached"},
+		{"NetworkConflictConditionType", NetworkConflictConditionType, "Conflict"},
+		{"NetworkPausedConditionType", NetworkPausedConditionType, "Paused"},
+		{"SubnetReadyConditionType", SubnetReadyConditionType, "SubnetReady"},
+		{"SecondaryRangesFoundConditionType", SecondaryRangesFoundConditionType, "SecondaryRangesFound"},
+		{"ParamsReadyConditionType", ParamsReadyConditionType, "Ready"},
+		{"NetworkConflictReason", NetworkConflictReason, "DuplicateSecondaryRange"},
+		{"NetworkConflictResolvedReason", NetworkConflictResolvedReason, "NetworkConflictResolved"},
+		{"NetworkPausedReason", NetworkPausedReason, "NetworkPaused"},
+		{"NetworkResumedReason", NetworkResumedReason, "NetworkResumed"},
+		{"SubnetReadyReason", SubnetReadyReason, "SubnetReady"},
+		{"IncompatibleSubnetPurposeReason", IncompatibleSubnetPurposeReason, "IncompatibleSubnetPurpose"},
+		{"SecondaryRangesFoundReason", SecondaryRangesFoundReason, "SecondaryRangesFound"},
+		{"SecondaryRangeNotFoundReason", SecondaryRangeNotFoundReason, "SecondaryRangeNotFound"},
+		{"InvalidSecondaryRangeReason", InvalidSecondaryRangeReason, "InvalidSecondaryRange"},
+		{"ParamsReadyReason", ParamsReadyReason, "ParamsReady"},
+		{"ParamsNotReadyReason", ParamsNotReadyReason, "ParamsNotReady"},
+		{"MultiNetworkReadyConditionType", MultiNetworkReadyConditionType, "networking.gke.io/multi-network-ready"},
+		{"NetworksReadyReason", NetworksReadyReason, "NetworksReady"},
+		{"NetworksNotReadyReason", NetworksNotReadyReason, "NetworksNotReady"},
+		{"ConvergedConditionType", ConvergedConditionType, "Converged"},
+		{"SheddingLoadConditionType", SheddingLoadConditionType, "SheddingLoad"},
+		{"AllNodesUpToDateReason", AllNodesUpToDateReason, "AllNodesUpToDate"},
+		{"NodesPendingReason", NodesPendingReason, "NodesPending"},
+		{"QueueSaturatedReason", QueueSaturatedReason, "QueueSaturated"},
+		{"UpdatesDeferredReason", UpdatesDeferredReason, "UpdatesDeferred"},
+		{"NoUpdateDeferredReason", NoUpdateDeferredReason, "NoUpdateDeferred"},
+		{"InstanceRecreatedReason", InstanceRecreatedReason, "InstanceRecreated"},
+		{"PodCIDRMaskSizeMismatchReason", PodCIDRMaskSizeMismatchReason, "PodCIDRMaskSizeMismatch"},
+		{"InterfaceReservationsExhaustedReason", InterfaceReservationsExhaustedReason, "InterfaceReservationsExhausted"},
+		{"InvalidTrafficClassReason", InvalidTrafficClassReason, "InvalidTrafficClass"},
+		{"InvalidNodeGatewayReason", InvalidNodeGatewayReason, "InvalidNodeGateway"},
+		{"DuplicateSliceRangeReason", DuplicateSliceRangeReason, "DuplicateSliceRange"},
+		{"PodRangeExemptReason", PodRangeExemptReason, "PodRangeExempt"},
+		{"AliasRangeTooSmallReason", AliasRangeTooSmallReason, "AliasRangeTooSmall"},
+		{"PodIPOutsidePodCIDRsReason", PodIPOutsidePodCIDRsReason, "PodIPOutsidePodCIDRs"},
+		{"TooManyAdditionalNetworksReason", TooManyAdditionalNetworksReason, "TooManyAdditionalNetworks"},
+		{"UnmanagedProviderIDReason", UnmanagedProviderIDReason, "UnmanagedProviderID"},
+		{"MissingProviderIDReason", MissingProviderIDReason, "MissingProviderID"},
+		{"MalformedProviderIDReason", MalformedProviderIDReason, "MalformedProviderID"},
+		{"InstanceNotFoundReason", InstanceNotFoundReason, "InstanceNotFound"},
+		{"CloudAPIErrorReason", CloudAPIErrorReason, "CloudAPIError"},
+		{"NoMatchingRangeReason", NoMatchingRangeReason, "NoMatchingRange"},
+		{"InvalidNetworkParamsReason", InvalidNetworkParamsReason, "InvalidNetworkParams"},
+		{"ExternalIPAMErrorReason", ExternalIPAMErrorReason, "ExternalIPAMError"},
+		{"NodeUpdateFailedReason", NodeUpdateFailedReason, "NodeUpdateFailed"},
+		{"UnknownErrorReason", UnknownErrorReason, "Unknown"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
@@ -27,12 +27,14 @@ import (
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
 	networkingv1 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1"
 	networkingv1alpha1 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1alpha1"
+	networkingv2 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v2"
 )
 
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
 	NetworkingV1alpha1() networkingv1alpha1.NetworkingV1alpha1Interface
 	NetworkingV1() networkingv1.NetworkingV1Interface
+	NetworkingV2() networkingv2.NetworkingV2Interface
 }
 
 // Clientset contains the clients for groups.
@@ -40,6 +42,7 @@ type Clientset struct {
 	*discovery.DiscoveryClient
 	networkingV1alpha1 *networkingv1alpha1.NetworkingV1alpha1Client
 	networkingV1       *networkingv1.NetworkingV1Client
+	networkingV2       *networkingv2.NetworkingV2Client
 }
 
 // NetworkingV1alpha1 retrieves the NetworkingV1alpha1Client
@@ -52,6 +55,11 @@ func (c *Clientset) NetworkingV1() networkingv1.NetworkingV1Interface {
 	return c.networkingV1
 }
 
+// NetworkingV2 retrieves the NetworkingV2Client
+func (c *Clientset) NetworkingV2() networkingv2.NetworkingV2Interface {
+	return c.networkingV2
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -104,6 +112,10 @@ func NewForConfigAndClient(c *rest.Config, httpClient *http.Client) (*Clientset,
 	if err != nil {
 		return nil, err
 	}
+	cs.networkingV2, err = networkingv2.NewForConfigAndClient(&configShallowCopy, httpClient)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfigAndClient(&configShallowCopy, httpClient)
 	if err != nil {
@@ -127,6 +139,7 @@ func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.networkingV1alpha1 = networkingv1alpha1.New(c)
 	cs.networkingV1 = networkingv1.New(c)
+	cs.networkingV2 = networkingv2.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+// This file is not generated. It provides builders for tests of code using
+// the network clientset, e.g. the node IPAM controller.
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+)
+
+const (
+	// NetworksResource is the resource name of Networks, for use in reactors.
+	NetworksResource = "networks"
+	// GKENetworkParamSetsResource is the resource name of GKENetworkParamSets,
+	// for use in reactors.
+	GKENetworkParamSetsResource = "gkenetworkparamsets"
+)
+
+// Option configures a Clientset built by NewClientsetWith.
+type Option func(*builder)
+
+type builder struct {
+	objects  []runtime.Object
+	reactors []reactor
+}
+
+type reactor struct {
+	verb, resource string
+	reaction       testing.ReactionFunc
+}
+
+// WithNetworks adds copies of the Networks to the clientset.
+func WithNetworks(networks ...*networkv1.Network) Option {
+	return func(b *builder) {
+		for _, network := range networks {
+			b.objects = append(b.objects, network.DeepCopy())
+		}
+	}
+}
+
+// WithParams adds copies of the GKENetworkParamSets to the clientset.
+func WithParams(params ...*networkv1alpha1.GKENetworkParamSet) Option {
+	return func(b *builder) {
+		for _, p := range params {
+			b.objects = append(b.objects, p.DeepCopy())
+		}
+	}
+}
+
+// WithObjects adds the objects to the clientset.
+func WithObjects(objects ...runtime.Object) Option {
+	return func(b *builder) {
+		b.objects = append(b.objects, objects...)
+	}
+}
+
+// WithReactor prepends the reaction for the verb and resource, "*" matching
+// any of them, to the reactors of the clientset.
+func WithReactor(verb, resource string, reaction testing.ReactionFunc) Option {
+	return func(b *builder) {
+		b.reactors = append(b.reactors, reactor{verb: verb, resource: resource, reaction: reaction})
+	}
+}
+
+// WithFailures makes the first n calls of the verb on the resource fail with
+// err, see FailingReaction.
+func WithFailures(verb, resource string, n int, err error) Option {
+	return WithReactor(verb, resource, FailingReaction(n, err))
+}
+
+// NewClientsetWith returns a clientset configured by the options. Reactors are
+// evaluated in the order of the options, before the object tracker.
+func NewClientsetWith(opts ...Option) *Clientset {
+	b := &builder{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	cs := NewSimpleClientset(b.objects...)
+	for i := len(b.reactors) - 1; i >= 0; i-- {
+		r := b.reactors[i]
+		cs.PrependReactor(r.verb, r.resource, r.reaction)
+	}
+	return cs
+}
+
+// FailingReaction returns a reaction that fails the first n calls with err and
+// lets the following ones through to the next reactors, to inject transient
+// errors. A negative n fails every call. It is safe for concurrent use.
+func FailingReaction(n int, err error) testing.ReactionFunc {
+	var lock sync.Mutex
+	calls := 0
+	return func(action testing.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if n >= 0 && calls > n {
+			return false, nil, nil
+		}
+		return true, nil, err
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+)
+
+func TestNewClientsetWith(t *testing.T) {
+	network := &networkv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "blue-network"}}
+	params := &networkv1alpha1.GKENetworkParamSet{ObjectMeta: metav1.ObjectMeta{Name: "blue-params"}}
+	injected := errors.New("injected error")
+	cs := NewClientsetWith(
+		WithNetworks(network),
+		WithParams(params),
+		WithFailures("get", NetworksResource, 2, injected),
+	)
+	ctx := context.TODO()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cs.NetworkingV1().Networks().Get(ctx, network.Name, metav1.GetOptions{}); err != injected {
+			t.Fatalf("Get() call %d returned err %v, want %v", i, err, injected)
+		}
+	}
+	if _, err := cs.NetworkingV1().Networks().Get(ctx, network.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("Get() after the failures returned err %v", err)
+	}
+	if _, err := cs.NetworkingV1alpha1().GKENetworkParamSets().Get(ctx, params.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("Get(%s) returned err %v", params.Name, err)
+	}
+
+	// The clientset holds copies of the objects.
+	network.Spec.Type = networkv1.L2NetworkType
+	got, err := cs.NetworkingV1().Networks().Get(ctx, network.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() returned err %v", err)
+	}
+	if got.Spec.Type != "" {
+		t.Errorf("Network type = %q, want the clientset unaffected by changes of the input", got.Spec.Type)
+	}
+}
+
+func TestReactorOrder(t *testing.T) {
+	first := errors.New("first")
+	second := errors.New("second")
+	cs := NewClientsetWith(
+		WithFailures("list", "*", -1, first),
+		WithReactor("list", NetworksResource, func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, second
+		}),
+	)
+	for i := 0; i < 3; i++ {
+		if _, err := cs.NetworkingV1().Networks().List(context.TODO(), metav1.ListOptions{}); err != first {
+			t.Fatalf("List() call %d returned err %v, want %v", i, err, first)
+		}
+	}
+}
@@ -29,6 +29,8 @@ import (
 	fakenetworkingv1 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1/fake"
 	networkingv1alpha1 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1alpha1"
 	fakenetworkingv1alpha1 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1alpha1/fake"
+	networkingv2 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v2"
+	fakenetworkingv2 "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v2/fake"
 )
 
 // NewSimpleClientset returns a clientset that will respond with the provided objects.
@@ -90,3 +92,8 @@ func (c *Clientset) NetworkingV1alpha1() networkingv1alpha1.NetworkingV1alpha1In
 func (c *Clientset) NetworkingV1() networkingv1.NetworkingV1Interface {
 	return &fakenetworkingv1.FakeNetworkingV1{Fake: &c.Fake}
 }
+
+// NetworkingV2 retrieves the NetworkingV2Client
+func (c *Clientset) NetworkingV2() networkingv2.NetworkingV2Interface {
+	return &fakenetworkingv2.FakeNetworkingV2{Fake: &c.Fake}
+}
@@ -26,6 +26,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	networkingv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
 	networkingv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networkingv2 "k8s.io/cloud-provider-gcp/crd/apis/network/v2"
 )
 
 var Scheme = runtime.NewScheme()
@@ -34,6 +35,7 @@ var ParameterCodec = runtime.NewParameterCodec(Scheme)
 var localSchemeBuilder = runtime.SchemeBuilder{
 	networkingv1alpha1.AddToScheme,
 	networkingv1.AddToScheme,
+	networkingv2.AddToScheme,
 }
 
 // AddToScheme adds all types of this clientset into the given scheme. This allows composition
@@ -25,6 +25,7 @@ import (
 	cache "k8s.io/client-go/tools/cache"
 	v1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
 	v1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	v2 "k8s.io/cloud-provider-gcp/crd/apis/network/v2"
 )
 
 // GenericInformer is type of SharedIndexInformer which will locate and delegate to other
@@ -67,6 +68,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	case v1alpha1.SchemeGroupVersion.WithResource("networkinterfaces"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Networking().V1alpha1().NetworkInterfaces().Informer()}, nil
 
+		// Group=networking.gke.io, Version=v2
+	case v2.SchemeGroupVersion.WithResource("networks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Networking().V2().Networks().Informer()}, nil
+
 	}
 
 	return nil, fmt.Errorf("no informer found for %v", resource)
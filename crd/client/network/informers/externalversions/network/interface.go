@@ -22,6 +22,7 @@ import (
 	internalinterfaces "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/internalinterfaces"
 	v1 "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1"
 	v1alpha1 "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1alpha1"
+	v2 "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v2"
 )
 
 // Interface provides access to each of this group's versions.
@@ -30,6 +31,8 @@ type Interface interface {
 	V1alpha1() v1alpha1.Interface
 	// V1 provides access to shared informers for resources in V1.
 	V1() v1.Interface
+	// V2 provides access to shared informers for resources in V2.
+	V2() v2.Interface
 }
 
 type group struct {
@@ -52,3 +55,8 @@ func (g *group) V1alpha1() v1alpha1.Interface {
 func (g *group) V1() v1.Interface {
 	return v1.New(g.factory, g.namespace, g.tweakListOptions)
 }
+
+// V2 returns a new v2.Interface.
+func (g *group) V2() v2.Interface {
+	return v2.New(g.factory, g.namespace, g.tweakListOptions)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conversion implements the conversion webhook of the Network CRD,
+// which converts Networks between the v1 storage version and v2.
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networkv2 "k8s.io/cloud-provider-gcp/crd/apis/network/v2"
+)
+
+// maxRequestBytes is the maximum size of a ConversionReview.
+const maxRequestBytes = 10 << 20
+
+// NetworkWebhook serves the ConversionReviews of the Network CRD, see
+// spec.conversion.webhook of the CustomResourceDefinition.
+type NetworkWebhook struct{}
+
+var _ http.Handler = NetworkWebhook{}
+
+// ServeHTTP converts the Networks of the ConversionReview of the request to
+// the desired API version.
+func (NetworkWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode the ConversionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "ConversionReview has no request", http.StatusBadRequest)
+		return
+	}
+	review.Response = Review(review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode the ConversionReview: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// Review converts the Networks of the request to its desired API version.
+// The conversion fails as a whole if one of the objects cannot be converted.
+func Review(req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	resp := &apiextensionsv1.ConversionResponse{UID: req.UID}
+	for _, obj := range req.Objects {
+		converted, err := ConvertNetwork(obj.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			resp.ConvertedObjects = nil
+			resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return resp
+		}
+		resp.ConvertedObjects = append(resp.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	resp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return resp
+}
+
+// ConvertNetwork converts the JSON of a Network to the desired API version.
+// Networks are converted between v1alpha1 and v1 by only changing their
+// apiVersion, as the None conversion strategy the CRD used before, and
+// between v1alpha1 and v2 through v1.
+func ConvertNetwork(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("failed to decode the object: %v", err)
+	}
+	if typeMeta.Kind != "Network" {
+		return nil, fmt.Errorf("unexpected kind %q, only Networks are converted", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+	v1alpha1Version := networkv1alpha1.SchemeGroupVersion.String()
+	v1Version := networkv1.SchemeGroupVersion.String()
+	v2Version := networkv2.SchemeGroupVersion.String()
+	for _, version := range []string{typeMeta.APIVersion, desiredAPIVersion} {
+		if version != v1alpha1Version && version != v1Version && version != v2Version {
+			return nil, fmt.Errorf("unsupported conversion of Network %s to %s", typeMeta.APIVersion, desiredAPIVersion)
+		}
+	}
+
+	var err error
+	if typeMeta.APIVersion == v1alpha1Version {
+		if raw, err = setAPIVersion(raw, v1Version); err != nil {
+			return nil, err
+		}
+	}
+	if typeMeta.APIVersion == v2Version {
+		in := &networkv2.Network{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s Network: %v", v2Version, err)
+		}
+		out := &networkv1.Network{}
+		if err := networkv2.ConvertToV1(in, out); err != nil {
+			return nil, err
+		}
+		if raw, err = json.Marshal(out); err != nil {
+			return nil, err
+		}
+	}
+	switch desiredAPIVersion {
+	case v1alpha1Version:
+		return setAPIVersion(raw, v1alpha1Version)
+	case v2Version:
+		in := &networkv1.Network{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s Network: %v", v1Version, err)
+		}
+		out := &networkv2.Network{}
+		if err := networkv2.ConvertFromV1(in, out); err != nil {
+			return nil, err
+		}
+		return json.Marshal(out)
+	}
+	return raw, nil
+}
+
+// setAPIVersion returns the JSON of the object with the given apiVersion,
+// keeping all its other fields.
+func setAPIVersion(raw []byte, apiVersion string) ([]byte, error) {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode the object: %v", err)
+	}
+	obj["apiVersion"] = apiVersion
+	return json.Marshal(obj)
+}
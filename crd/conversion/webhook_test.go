@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	v1Network       = `{"apiVersion":"networking.gke.io/v1","kind":"Network","metadata":{"name":"red"},"spec":{"type":"L3","nodeInterfaceMatcher":{},"gateway4":"10.0.0.1","parametersRef":{"group":"networking.gke.io","kind":"GKENetworkParamSet","name":"red"}}}`
+	v1alpha1Network = `{"apiVersion":"networking.gke.io/v1alpha1","kind":"Network","metadata":{"name":"red"},"spec":{"type":"L3","nodeInterfaceMatcher":{},"gateway4":"10.0.0.1","parametersRef":{"group":"networking.gke.io","kind":"GKENetworkParamSet","name":"red"}}}`
+	v2Network       = `{"apiVersion":"networking.gke.io/v2","kind":"Network","metadata":{"name":"red"},"spec":{"type":"L3","nodeInterface":{},"ipv4Gateway":"10.0.0.1","parameters":[{"group":"networking.gke.io","kind":"GKENetworkParamSet","name":"red"}]}}`
+)
+
+func TestConvertNetwork(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		in      string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc:    "v1 to v2",
+			in:      v1Network,
+			version: "networking.gke.io/v2",
+			want:    v2Network,
+		},
+		{
+			desc:    "v2 to v1",
+			in:      v2Network,
+			version: "networking.gke.io/v1",
+			want:    v1Network,
+		},
+		{
+			desc:    "v1alpha1 to v1",
+			in:      v1alpha1Network,
+			version: "networking.gke.io/v1",
+			want:    v1Network,
+		},
+		{
+			desc:    "v1 to v1alpha1",
+			in:      v1Network,
+			version: "networking.gke.io/v1alpha1",
+			want:    v1alpha1Network,
+		},
+		{
+			desc:    "v1alpha1 to v2",
+			in:      v1alpha1Network,
+			version: "networking.gke.io/v2",
+			want:    v2Network,
+		},
+		{
+			desc:    "v2 to v1alpha1",
+			in:      v2Network,
+			version: "networking.gke.io/v1alpha1",
+			want:    v1alpha1Network,
+		},
+		{
+			desc:    "same version",
+			in:      v1Network,
+			version: "networking.gke.io/v1",
+			want:    v1Network,
+		},
+		{
+			desc:    "unknown version",
+			in:      v1Network,
+			version: "networking.gke.io/v3",
+			wantErr: true,
+		},
+		{
+			desc:    "other kind",
+			in:      `{"apiVersion":"networking.gke.io/v1","kind":"NetworkInterface","metadata":{"name":"red"}}`,
+			version: "networking.gke.io/v2",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ConvertNetwork([]byte(tc.in), tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ConvertNetwork() returned err %v, want error %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(decode(t, []byte(tc.want)), decode(t, got)); diff != "" {
+				t.Errorf("ConvertNetwork() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetworkWebhook(t *testing.T) {
+	server := httptest.NewServer(NetworkWebhook{})
+	defer server.Close()
+
+	testCases := []struct {
+		desc       string
+		objects    []string
+		wantStatus string
+		want       []string
+	}{
+		{
+			desc:       "converted objects",
+			objects:    []string{v1Network, v1alpha1Network},
+			wantStatus: metav1.StatusSuccess,
+			want:       []string{v2Network, v2Network},
+		},
+		{
+			desc:       "one object fails",
+			objects:    []string{v1Network, `{"apiVersion":"networking.gke.io/v1","kind":"GKENetworkParamSet"}`},
+			wantStatus: metav1.StatusFailure,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			review := apiextensionsv1.ConversionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+				Request:  &apiextensionsv1.ConversionRequest{UID: "uid", DesiredAPIVersion: "networking.gke.io/v2"},
+			}
+			for _, obj := range tc.objects {
+				review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: []byte(obj)})
+			}
+			body, err := json.Marshal(review)
+			if err != nil {
+				t.Fatalf("error in test setup, could not marshal the review: %v", err)
+			}
+			resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("failed to post the review: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got HTTP status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			got := apiextensionsv1.ConversionReview{}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			if got.Response == nil || got.Response.UID != "uid" {
+				t.Fatalf("got response %+v, want the UID of the request", got.Response)
+			}
+			if got.Response.Result.Status != tc.wantStatus {
+				t.Errorf("got status %q (%s), want %q", got.Response.Result.Status, got.Response.Result.Message, tc.wantStatus)
+			}
+			if len(got.Response.ConvertedObjects) != len(tc.want) {
+				t.Fatalf("got %d converted objects, want %d", len(got.Response.ConvertedObjects), len(tc.want))
+			}
+			for i, want := range tc.want {
+				if diff := cmp.Diff(decode(t, []byte(want)), decode(t, got.Response.ConvertedObjects[i].Raw)); diff != "" {
+					t.Errorf("converted object %d mismatch (-want +got):\n%s", i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkWebhookBadRequest(t *testing.T) {
+	server := httptest.NewServer(NetworkWebhook{})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got HTTP status %d for a GET, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+	resp, err = http.Post(server.URL, "application/json", bytes.NewReader([]byte(`{"kind":"ConversionReview"}`)))
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got HTTP status %d for a review without request, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// decode returns the JSON object without the null creationTimestamp and
+// empty status added by the typed conversions.
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatalf("failed to decode %s: %v", data, err)
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	if status, ok := obj["status"].(map[string]interface{}); ok && len(status) == 0 {
+		delete(obj, "status")
+	}
+	return obj
+}
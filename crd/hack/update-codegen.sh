@@ -41,7 +41,7 @@ echo "Generating network CRD clientset"
 "${SCRIPT_ROOT}/hack/generate-groups.sh" all \
   k8s.io/cloud-provider-gcp/crd/client/network \
   k8s.io/cloud-provider-gcp/crd/apis \
-  "network:v1alpha1,v1" \
+  "network:v1alpha1,v1,v2" \
   --go-header-file "${SCRIPT_ROOT}/hack/boilerplate.go.txt"
 
 echo "Generating firewall CRD clientset"
@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkenetworkparamset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1alpha1"
+)
+
+// compatibleSubnetPurposes lists the subnet purposes usable for pod networking.
+// An empty purpose is reported for PRIVATE subnets.
+var compatibleSubnetPurposes = map[string]bool{
+	"":                 true,
+	"PRIVATE":          true,
+	"PRIVATE_RFC_1918": true,
+}
+
+// CompatibleSubnetPurpose returns true if subnets with the given purpose can be
+// used for pod networking.
+func CompatibleSubnetPurpose(purpose string) bool {
+	return compatibleSubnetPurposes[purpose]
+}
+
+// subnetReadyCondition returns the SubnetReady condition of a GKENetworkParamSet
+// referencing the given subnet.
+func subnetReadyCondition(subnet *compute.Subnetwork) v1.Condition {
+	if !CompatibleSubnetPurpose(subnet.Purpose) {
+		return v1.Condition{
+			Type:    wellknown.SubnetReadyConditionType,
+			Status:  v1.ConditionFalse,
+			Reason:  wellknown.IncompatibleSubnetPurposeReason,
+			Message: fmt.Sprintf("subnet %s has purpose %s, only PRIVATE subnets can be used for pod networking", subnet.Name, subnet.Purpose),
+		}
+	}
+	return v1.Condition{
+		Type:   wellknown.SubnetReadyConditionType,
+		Status: v1.ConditionTrue,
+		Reason: wellknown.SubnetReadyReason,
+	}
+}
+
+// Conditions returns the conditions stored in the
+// wellknown.ConditionsAnnotationKey annotation of the GKENetworkParamSet.
+func Conditions(params *networkv1alpha1.GKENetworkParamSet) ([]v1.Condition, error) {
+	value, ok := params.Annotations[wellknown.ConditionsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var conditions []v1.Condition
+	if err := json.Unmarshal([]byte(value), &conditions); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", wellknown.ConditionsAnnotationKey, err)
+	}
+	return conditions, nil
+}
+
+// SubnetReady returns false if the GKENetworkParamSet is known to reference a
+// subnet that cannot be used for pod networking.
+func SubnetReady(params *networkv1alpha1.GKENetworkParamSet) bool {
+	conditions, err := Conditions(params)
+	if err != nil {
+		return true
+	}
+	return !meta.IsStatusConditionFalse(conditions, wellknown.SubnetReadyConditionType)
+}
+
+// readyCondition returns the Ready condition of a GKENetworkParamSet, true
+// if all the given conditions are true.
+func readyCondition(conditions ...v1.Condition) v1.Condition {
+	for _, condition := range conditions {
+		if condition.Status != v1.ConditionTrue {
+			return v1.Condition{
+				Type:    wellknown.ParamsReadyConditionType,
+				Status:  v1.ConditionFalse,
+				Reason:  wellknown.ParamsNotReadyReason,
+				Message: fmt.Sprintf("condition %s is %s: %s", condition.Type, condition.Status, condition.Message),
+			}
+		}
+	}
+	return v1.Condition{
+		Type:   wellknown.ParamsReadyConditionType,
+		Status: v1.ConditionTrue,
+		Reason: wellknown.ParamsReadyReason,
+	}
+}
+
+// statusConditions returns the status conditions of the GKENetworkParamSet
+// updated with the given conditions. The transition times of the conditions
+// whose status did not change are kept.
+func statusConditions(params *networkv1alpha1.GKENetworkParamSet, newConditions ...v1.Condition) []v1.Condition {
+	var conditions []v1.Condition
+	for _, condition := range params.Status.Conditions {
+		conditions = append(conditions, *condition.DeepCopy())
+	}
+	for _, condition := range newConditions {
+		condition.ObservedGeneration = params.Generation
+		meta.SetStatusCondition(&conditions, condition)
+	}
+	return conditions
+}
+
+// Ready returns true if the status of the GKENetworkParamSet has a true Ready
+// condition.
+func Ready(params *networkv1alpha1.GKENetworkParamSet) bool {
+	return meta.IsStatusConditionTrue(params.Status.Conditions, wellknown.ParamsReadyConditionType)
+}
+
+// setConditions sets the conditions in the wellknown.ConditionsAnnotationKey
+// annotation of the GKENetworkParamSet, if they changed.
+func setConditions(ctx context.Context, paramSetClient v1alpha1.GKENetworkParamSetInterface, params *networkv1alpha1.GKENetworkParamSet, newConditions ...v1.Condition) error {
+	conditions, err := Conditions(params)
+	if err != nil {
+		klog.Warningf("Resetting conditions of GKENetworkParamSet %s: %v", params.Name, err)
+		conditions = nil
+	}
+	changed := false
+	for _, condition := range newConditions {
+		if existing := meta.FindStatusCondition(conditions, condition.Type); existing != nil &&
+			existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			continue
+		}
+		meta.SetStatusCondition(&conditions, condition)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	value, err := json.Marshal(conditions)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{wellknown.ConditionsAnnotationKey: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := paramSetClient.Patch(ctx, params.Name, types.MergePatchType, patch, v1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to update GKENetworkParamSet conditions: %v", err)
+	}
+	return nil
+}
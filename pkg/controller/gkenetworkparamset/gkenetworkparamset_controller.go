@@ -18,6 +18,7 @@ package gkenetworkparamset
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"k8s.io/klog/v2"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 
@@ -34,6 +36,7 @@ import (
 	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
 	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/typed/network/v1alpha1"
 	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
 
 	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
 )
@@ -44,6 +47,9 @@ type Controller struct {
 	networkClientset         networkclientset.Interface
 	gceCloud                 *gce.Cloud
 	queue                    workqueue.RateLimitingInterface
+	// subnetSyncPeriod is the period at which all the GKENetworkParamSets are
+	// synced with their subnets. Zero disables the periodic sync.
+	subnetSyncPeriod time.Duration
 }
 
 // NewGKENetworkParamSetController returns a new
@@ -51,6 +57,7 @@ func NewGKENetworkParamSetController(
 	networkClientset networkclientset.Interface,
 	gkeNetworkParamsInformer cache.SharedIndexInformer,
 	gceCloud *gce.Cloud,
+	subnetSyncPeriod time.Duration,
 ) *Controller {
 
 	return &Controller{
@@ -58,6 +65,7 @@ func NewGKENetworkParamSetController(
 		gkeNetworkParamsInformer: gkeNetworkParamsInformer,
 		gceCloud:                 gceCloud,
 		queue:                    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "gkenetworkparamset"),
+		subnetSyncPeriod:         subnetSyncPeriod,
 	}
 
 }
@@ -96,6 +104,9 @@ func (c *Controller) Run(numWorkers int, stopCh <-chan struct{}, controllerManag
 	for i := 0; i < numWorkers; i++ {
 		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
 	}
+	if c.subnetSyncPeriod > 0 {
+		go wait.Until(c.enqueueAll, c.subnetSyncPeriod, stopCh)
+	}
 
 	<-stopCh
 }
@@ -159,19 +170,50 @@ func (c *Controller) syncGKENetworkParamSet(ctx context.Context, key string) err
 
 	params := obj.(*networkv1alpha1.GKENetworkParamSet)
 
-	subnet, err := c.gceCloud.GetSubnetwork(c.gceCloud.Region(), params.Spec.VPCSubnet)
+	subnet, err := c.getSubnetwork(params.Spec.VPCSubnet)
 	if err != nil {
 		return err
 	}
 
 	cidrs := extractRelevantCidrs(subnet, params)
 
-	err = updateGKENetworkParamSetStatus(ctx, c.networkClientset.NetworkingV1alpha1().GKENetworkParamSets(), params, cidrs)
+	condition := subnetReadyCondition(subnet)
+	if condition.Status != v1.ConditionTrue {
+		klog.Warningf("GKENetworkParamSet %s is not usable: %s", params.Name, condition.Message)
+	}
+	rangesCondition := secondaryRangesCondition(subnet, params)
+	if rangesCondition.Status != v1.ConditionTrue {
+		klog.Warningf("GKENetworkParamSet %s references missing secondary ranges: %s", params.Name, rangesCondition.Message)
+	}
+	conditions := statusConditions(params, readyCondition(condition, rangesCondition))
+
+	paramSetClient := c.networkClientset.NetworkingV1alpha1().GKENetworkParamSets()
+	err = updateGKENetworkParamSetStatus(ctx, paramSetClient, params, cidrs, subnetSecondaryRanges(subnet), conditions)
 	if err != nil {
 		return err
 	}
+	return setConditions(ctx, paramSetClient, params, condition, rangesCondition)
+}
 
-	return nil
+// getSubnetwork fetches the subnetwork referenced by a GKENetworkParamSet. The
+// reference may be a bare name, in which case the cluster region is used, or a
+// relative or fully qualified URL in the network project.
+func (c *Controller) getSubnetwork(ref string) (*compute.Subnetwork, error) {
+	id, err := gcpurl.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if id.Kind != "" && id.Kind != gcpurl.KindSubnetworks {
+		return nil, fmt.Errorf("%q is not a subnetwork", ref)
+	}
+	if id.Project != "" && id.Project != c.gceCloud.NetworkProjectID() {
+		return nil, fmt.Errorf("subnetwork %q is not in the network project %q", ref, c.gceCloud.NetworkProjectID())
+	}
+	region := c.gceCloud.Region()
+	if id.Region != "" {
+		region = id.Region
+	}
+	return c.gceCloud.GetSubnetwork(region, id.Name)
 }
 
 // extractRelevantCidrs returns the CIDRS of the named ranges in paramset
@@ -196,7 +238,7 @@ func extractRelevantCidrs(subnet *compute.Subnetwork, paramset *networkv1alpha1.
 }
 
 func paramSetIncludesRange(params *networkv1alpha1.GKENetworkParamSet, secondaryRangeName string) bool {
-	for _, rn := range params.Spec.PodIPv4Ranges.RangeNames {
+	for _, rn := range PodIPv4RangeNames(params) {
 		if rn == secondaryRangeName {
 			return true
 		}
@@ -204,14 +246,23 @@ func paramSetIncludesRange(params *networkv1alpha1.GKENetworkParamSet, secondary
 	return false
 }
 
-// updateGKENetworkParamSetStatus performs a status update for the given GKENetworkParamSet on the cluster with the given cidrs
-func updateGKENetworkParamSetStatus(ctx context.Context, paramSetClient v1alpha1.GKENetworkParamSetInterface, gkeNetworkParamSet *networkv1alpha1.GKENetworkParamSet, cidrs []string) error {
-	gkeNetworkParamSet.Status.PodCIDRs = &networkv1alpha1.NetworkRanges{
-		CIDRBlocks: cidrs,
-	}
-
+// updateGKENetworkParamSetStatus performs a status update for the given GKENetworkParamSet on the cluster with the given cidrs,
+// the secondary ranges of its subnet and its conditions
+func updateGKENetworkParamSetStatus(ctx context.Context, paramSetClient v1alpha1.GKENetworkParamSetInterface, gkeNetworkParamSet *networkv1alpha1.GKENetworkParamSet, cidrs []string, secondaryRanges []networkv1alpha1.SubnetSecondaryRange, conditions []v1.Condition) error {
 	klog.V(4).Infof("GKENetworkParamSet cidrs are: %v", cidrs)
-	_, err := paramSetClient.UpdateStatus(ctx, gkeNetworkParamSet, v1.UpdateOptions{})
+	// The secondary ranges are always set, so that the ranges removed from the
+	// subnet are cleared.
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"podCIDRs":        networkv1alpha1.NetworkRanges{CIDRBlocks: cidrs},
+			"secondaryRanges": secondaryRanges,
+			"conditions":      conditions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = paramSetClient.Patch(ctx, gkeNetworkParamSet.Name, types.MergePatchType, patch, v1.PatchOptions{}, "status")
 	if err != nil {
 		return fmt.Errorf("failed to update GKENetworkParamSet Status CIDRs: %v", err)
 	}
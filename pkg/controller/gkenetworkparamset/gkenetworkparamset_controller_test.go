@@ -2,15 +2,18 @@ package gkenetworkparamset
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
 	"github.com/onsi/gomega"
 	"google.golang.org/api/compute/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
 	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
 	gkenetworkparamset "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1alpha1"
 	"k8s.io/cloud-provider-gcp/providers/gce"
@@ -37,6 +40,7 @@ func setupGKENetworkParamSetController() *testGKENetworkParamSetController {
 		fakeNetworking,
 		gkeNetworkParamSetInformer,
 		fakeGCE,
+		0,
 	)
 	metrics := controllers.NewControllerManagerMetrics("test")
 
@@ -370,3 +374,342 @@ func TestValidParamSetSubnetRange(t *testing.T) {
 	}).Should(gomega.BeTrue(), "GKENetworkParamSet Status should be updated with subnet cidr.")
 
 }
+
+func TestAddValidParamSetQualifiedSubnetReference(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	testVals := setupGKENetworkParamSetController()
+
+	subnetName := "test-subnet"
+	subnetSecondaryRangeName := "test-secondary-range"
+	subnetSecondaryCidr := "10.0.0.0/24"
+	subnetKey := meta.RegionalKey(subnetName, testVals.clusterValues.Region)
+	subnet := &compute.Subnetwork{
+		Name: subnetName,
+		SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{
+			{
+				IpCidrRange: subnetSecondaryCidr,
+				RangeName:   subnetSecondaryRangeName,
+			},
+		},
+	}
+
+	err := testVals.cloud.Compute().Subnetworks().Insert(ctx, subnetKey, subnet)
+	if err != nil {
+		t.Error(err)
+	}
+
+	testVals.runGKENetworkParamSetController(ctx)
+
+	gkeNetworkParamSetName := "test-paramset"
+	paramSet := &v1alpha1.GKENetworkParamSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name: gkeNetworkParamSetName,
+		},
+		Spec: v1alpha1.GKENetworkParamSetSpec{
+			VPC:       "default",
+			VPCSubnet: "https://www.googleapis.com/compute/v1/projects/" + testVals.clusterValues.ProjectID + "/regions/" + testVals.clusterValues.Region + "/subnetworks/" + subnetName,
+			PodIPv4Ranges: &v1alpha1.SecondaryRanges{
+				RangeNames: []string{
+					subnetSecondaryRangeName,
+				},
+			},
+		},
+	}
+	_, err = testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Create(ctx, paramSet, v1.CreateOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	g.Eventually(func() (bool, error) {
+		paramSet, err := testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Get(ctx, gkeNetworkParamSetName, v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		cidrExists := paramSet.Status.PodCIDRs != nil && len(paramSet.Status.PodCIDRs.CIDRBlocks) > 0
+		if cidrExists {
+			g.Ω(paramSet.Status.PodCIDRs.CIDRBlocks).Should(gomega.ConsistOf(subnetSecondaryCidr))
+			return true, nil
+		}
+
+		return false, nil
+	}).Should(gomega.BeTrue(), "GKENetworkParamSet Status should be updated with secondary range cidr.")
+}
+
+func TestParamSetIncompatibleSubnetPurpose(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	testVals := setupGKENetworkParamSetController()
+
+	subnetName := "test-subnet"
+	subnetCidr := "10.0.0.0/24"
+	subnetKey := meta.RegionalKey(subnetName, testVals.clusterValues.Region)
+	subnet := &compute.Subnetwork{
+		Name:        subnetName,
+		IpCidrRange: subnetCidr,
+		Purpose:     "PRIVATE_SERVICE_CONNECT",
+	}
+
+	err := testVals.cloud.Compute().Subnetworks().Insert(ctx, subnetKey, subnet)
+	if err != nil {
+		t.Error(err)
+	}
+	testVals.runGKENetworkParamSetController(ctx)
+
+	gkeNetworkParamSetName := "test-paramset"
+	paramSet := &v1alpha1.GKENetworkParamSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name: gkeNetworkParamSetName,
+		},
+		Spec: v1alpha1.GKENetworkParamSetSpec{
+			VPC:       "default",
+			VPCSubnet: subnetName,
+		},
+	}
+	_, err = testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Create(ctx, paramSet, v1.CreateOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	g.Eventually(func() (bool, error) {
+		paramSet, err := testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Get(ctx, gkeNetworkParamSetName, v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		conditions, err := Conditions(paramSet)
+		if err != nil || len(conditions) == 0 {
+			return false, err
+		}
+		condition := apimeta.FindStatusCondition(conditions, wellknown.SubnetReadyConditionType)
+		g.Ω(condition).ShouldNot(gomega.BeNil())
+		g.Ω(condition.Status).Should(gomega.Equal(v1.ConditionFalse))
+		g.Ω(condition.Reason).Should(gomega.Equal(wellknown.IncompatibleSubnetPurposeReason))
+		g.Ω(SubnetReady(paramSet)).Should(gomega.BeFalse())
+		ready := apimeta.FindStatusCondition(paramSet.Status.Conditions, wellknown.ParamsReadyConditionType)
+		g.Ω(ready).ShouldNot(gomega.BeNil())
+		g.Ω(ready.Reason).Should(gomega.Equal(wellknown.ParamsNotReadyReason))
+		g.Ω(Ready(paramSet)).Should(gomega.BeFalse())
+		return true, nil
+	}).Should(gomega.BeTrue(), "GKENetworkParamSet should have a false SubnetReady condition.")
+
+}
+
+func TestSubnetReadyCondition(t *testing.T) {
+	for purpose, want := range map[string]v1.ConditionStatus{
+		"":                        v1.ConditionTrue,
+		"PRIVATE":                 v1.ConditionTrue,
+		"PRIVATE_RFC_1918":        v1.ConditionTrue,
+		"PRIVATE_SERVICE_CONNECT": v1.ConditionFalse,
+		"REGIONAL_MANAGED_PROXY":  v1.ConditionFalse,
+	} {
+		if got := subnetReadyCondition(&compute.Subnetwork{Name: "test-subnet", Purpose: purpose}); got.Status != want {
+			t.Errorf("subnetReadyCondition(purpose %q) = %v, want %v", purpose, got.Status, want)
+		}
+	}
+}
+
+func TestReadyCondition(t *testing.T) {
+	subnetReady := v1.Condition{Type: wellknown.SubnetReadyConditionType, Status: v1.ConditionTrue, Reason: wellknown.SubnetReadyReason}
+	rangesMissing := v1.Condition{Type: wellknown.SecondaryRangesFoundConditionType, Status: v1.ConditionFalse, Reason: wellknown.SecondaryRangeNotFoundReason, Message: "secondary ranges missing do not exist"}
+	if got := readyCondition(subnetReady); got.Status != v1.ConditionTrue || got.Reason != wellknown.ParamsReadyReason {
+		t.Errorf("readyCondition(%s) = %s %s, want %s %s", subnetReady.Type, got.Status, got.Reason, v1.ConditionTrue, wellknown.ParamsReadyReason)
+	}
+	got := readyCondition(subnetReady, rangesMissing)
+	if got.Status != v1.ConditionFalse || got.Reason != wellknown.ParamsNotReadyReason {
+		t.Errorf("readyCondition(%s, %s) = %s %s, want %s %s", subnetReady.Type, rangesMissing.Type, got.Status, got.Reason, v1.ConditionFalse, wellknown.ParamsNotReadyReason)
+	}
+	if want := "condition SecondaryRangesFound is False: secondary ranges missing do not exist"; got.Message != want {
+		t.Errorf("readyCondition() message = %q, want %q", got.Message, want)
+	}
+
+	params := &v1alpha1.GKENetworkParamSet{}
+	params.Status.Conditions = statusConditions(params, readyCondition(subnetReady))
+	transition := params.Status.Conditions[0].LastTransitionTime
+	params.Status.Conditions[0].LastTransitionTime = v1.NewTime(transition.Add(-time.Hour))
+	if conditions := statusConditions(params, readyCondition(subnetReady)); !conditions[0].LastTransitionTime.Equal(&params.Status.Conditions[0].LastTransitionTime) {
+		t.Errorf("statusConditions() changed the transition time of an unchanged condition")
+	}
+	if !Ready(params) {
+		t.Errorf("Ready() = false, want true")
+	}
+}
+
+func TestSecondaryRangesCondition(t *testing.T) {
+	subnet := &compute.Subnetwork{
+		Name:     "test-subnet",
+		SelfLink: "https://www.googleapis.com/compute/v1/projects/test-project/regions/us-central1/subnetworks/test-subnet",
+		SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{
+			{RangeName: "range-a", IpCidrRange: "10.0.0.0/24"},
+			{RangeName: "range-b", IpCidrRange: "10.0.1.0/24"},
+		},
+	}
+	testCases := []struct {
+		desc        string
+		ranges      *v1alpha1.SecondaryRanges
+		wantStatus  v1.ConditionStatus
+		wantMessage string
+	}{
+		{
+			desc:       "subnet range",
+			wantStatus: v1.ConditionTrue,
+		},
+		{
+			desc:       "existing ranges",
+			ranges:     &v1alpha1.SecondaryRanges{RangeNames: []string{"range-a", "range-b"}},
+			wantStatus: v1.ConditionTrue,
+		},
+		{
+			desc:        "missing ranges",
+			ranges:      &v1alpha1.SecondaryRanges{RangeNames: []string{"range-a", "range-c", "range-d"}},
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: "secondary ranges range-c, range-d do not exist in subnet test-subnet",
+		},
+		{
+			desc:       "range paths",
+			ranges:     &v1alpha1.SecondaryRanges{RangeNames: []string{"projects/test-project/regions/us-central1/subnetworks/test-subnet/secondaryIpRanges/range-a", "range-b"}},
+			wantStatus: v1.ConditionTrue,
+		},
+		{
+			desc:        "missing range path",
+			ranges:      &v1alpha1.SecondaryRanges{RangeNames: []string{"projects/test-project/regions/us-central1/subnetworks/test-subnet/secondaryIpRanges/range-c"}},
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: "secondary ranges range-c do not exist in subnet test-subnet",
+		},
+		{
+			desc:        "range of another subnet",
+			ranges:      &v1alpha1.SecondaryRanges{RangeNames: []string{"projects/test-project/regions/us-central1/subnetworks/other-subnet/secondaryIpRanges/range-a"}},
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: `secondary range "projects/test-project/regions/us-central1/subnetworks/other-subnet/secondaryIpRanges/range-a" is not in subnet https://www.googleapis.com/compute/v1/projects/test-project/regions/us-central1/subnetworks/test-subnet`,
+		},
+		{
+			desc:        "invalid range reference",
+			ranges:      &v1alpha1.SecondaryRanges{RangeNames: []string{"test-subnet/range-a"}},
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: `invalid secondary range reference "test-subnet/range-a"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			params := &v1alpha1.GKENetworkParamSet{Spec: v1alpha1.GKENetworkParamSetSpec{PodIPv4Ranges: tc.ranges}}
+			got := secondaryRangesCondition(subnet, params)
+			if got.Status != tc.wantStatus || got.Message != tc.wantMessage {
+				t.Errorf("secondaryRangesCondition() = %s %q, want %s %q", got.Status, got.Message, tc.wantStatus, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPodIPv4RangeNames(t *testing.T) {
+	params := &v1alpha1.GKENetworkParamSet{
+		Spec: v1alpha1.GKENetworkParamSetSpec{
+			VPCSubnet: "projects/test-project/regions/us-central1/subnetworks/test-subnet",
+			PodIPv4Ranges: &v1alpha1.SecondaryRanges{RangeNames: []string{
+				"range-a",
+				"https://www.googleapis.com/compute/v1/projects/test-project/regions/us-central1/subnetworks/test-subnet/secondaryIpRanges/range-b",
+				"projects/test-project/regions/us-central1/subnetworks/other-subnet/secondaryIpRanges/range-c",
+			}},
+		},
+	}
+	want := []string{"range-a", "range-b", "projects/test-project/regions/us-central1/subnetworks/other-subnet/secondaryIpRanges/range-c"}
+	if got := PodIPv4RangeNames(params); !reflect.DeepEqual(got, want) {
+		t.Errorf("PodIPv4RangeNames() = %v, want %v", got, want)
+	}
+}
+
+func TestParamSetSecondaryRangesSync(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	testVals := setupGKENetworkParamSetController()
+	testVals.controller.subnetSyncPeriod = 100 * time.Millisecond
+
+	subnetName := "test-subnet"
+	subnetKey := meta.RegionalKey(subnetName, testVals.clusterValues.Region)
+	subnet := &compute.Subnetwork{
+		Name: subnetName,
+		SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{
+			{
+				IpCidrRange: "10.0.0.0/24",
+				RangeName:   "test-secondary-range-1",
+			},
+		},
+	}
+	if err := testVals.cloud.Compute().Subnetworks().Insert(ctx, subnetKey, subnet); err != nil {
+		t.Error(err)
+	}
+
+	testVals.runGKENetworkParamSetController(ctx)
+
+	gkeNetworkParamSetName := "test-paramset"
+	paramSet := &v1alpha1.GKENetworkParamSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name: gkeNetworkParamSetName,
+		},
+		Spec: v1alpha1.GKENetworkParamSetSpec{
+			VPC:       "default",
+			VPCSubnet: subnetName,
+			PodIPv4Ranges: &v1alpha1.SecondaryRanges{
+				RangeNames: []string{"test-secondary-range-2"},
+			},
+		},
+	}
+	if _, err := testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Create(ctx, paramSet, v1.CreateOptions{}); err != nil {
+		t.Error(err)
+	}
+
+	secondaryRangesFound := func() (*v1.Condition, error) {
+		paramSet, err := testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Get(ctx, gkeNetworkParamSetName, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		conditions, err := Conditions(paramSet)
+		if err != nil {
+			return nil, err
+		}
+		return apimeta.FindStatusCondition(conditions, wellknown.SecondaryRangesFoundConditionType), nil
+	}
+	g.Eventually(func() (bool, error) {
+		condition, err := secondaryRangesFound()
+		if err != nil || condition == nil {
+			return false, err
+		}
+		g.Ω(condition.Status).Should(gomega.Equal(v1.ConditionFalse))
+		g.Ω(condition.Reason).Should(gomega.Equal(wellknown.SecondaryRangeNotFoundReason))
+		return true, nil
+	}).Should(gomega.BeTrue(), "GKENetworkParamSet should have a false SecondaryRangesFound condition.")
+
+	// The range added to the subnet is observed by the periodic sync.
+	subnet.SecondaryIpRanges = append(subnet.SecondaryIpRanges, &compute.SubnetworkSecondaryRange{
+		IpCidrRange: "10.0.1.0/24",
+		RangeName:   "test-secondary-range-2",
+	})
+	if err := testVals.cloud.Compute().Subnetworks().Delete(ctx, subnetKey); err != nil {
+		t.Error(err)
+	}
+	if err := testVals.cloud.Compute().Subnetworks().Insert(ctx, subnetKey, subnet); err != nil {
+		t.Error(err)
+	}
+	g.Eventually(func() (bool, error) {
+		condition, err := secondaryRangesFound()
+		if err != nil || condition == nil {
+			return false, err
+		}
+		return condition.Status == v1.ConditionTrue, nil
+	}).Should(gomega.BeTrue(), "GKENetworkParamSet should have a true SecondaryRangesFound condition.")
+
+	want := []v1alpha1.SubnetSecondaryRange{
+		{RangeName: "test-secondary-range-1", IPCIDRRange: "10.0.0.0/24"},
+		{RangeName: "test-secondary-range-2", IPCIDRRange: "10.0.1.0/24"},
+	}
+	g.Eventually(func() ([]v1alpha1.SubnetSecondaryRange, error) {
+		paramSet, err := testVals.networkClient.NetworkingV1alpha1().GKENetworkParamSets().Get(ctx, gkeNetworkParamSetName, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return paramSet.Status.SecondaryRanges, nil
+	}).Should(gomega.Equal(want), "GKENetworkParamSet status should list the secondary ranges of the subnet.")
+}
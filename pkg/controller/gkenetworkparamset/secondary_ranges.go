@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gkenetworkparamset
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+)
+
+// PodIPv4RangeNames returns the names of the secondary ranges of
+// spec.podIPv4Ranges. The ranges may be referenced by name or by full path,
+// e.g. projects/p/regions/r/subnetworks/s/secondaryIpRanges/pods, see
+// gcpurl.ParseSecondaryRange. Invalid references and ranges of other subnets
+// than spec.vpcSubnet are returned unchanged, so that they match no range;
+// the controller reports them in the SecondaryRangesFound condition.
+func PodIPv4RangeNames(params *networkv1alpha1.GKENetworkParamSet) []string {
+	if params.Spec.PodIPv4Ranges == nil {
+		return nil
+	}
+	names := make([]string, 0, len(params.Spec.PodIPv4Ranges.RangeNames))
+	for _, ref := range params.Spec.PodIPv4Ranges.RangeNames {
+		name, err := secondaryRangeName(ref, params.Spec.VPCSubnet)
+		if err != nil {
+			name = ref
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// secondaryRangeName returns the name of the referenced secondary range, or
+// an error if the reference is invalid or a range of another subnet than the
+// given one.
+func secondaryRangeName(ref, subnet string) (string, error) {
+	rangeSubnet, name, err := gcpurl.ParseSecondaryRange(ref)
+	if err != nil {
+		return "", err
+	}
+	if rangeSubnet != nil && !gcpurl.Matches(rangeSubnet.String(), subnet, gcpurl.KindSubnetworks, gcpurl.Defaults{}) {
+		return "", fmt.Errorf("secondary range %q is not in subnet %s", ref, subnet)
+	}
+	return name, nil
+}
+
+// subnetSecondaryRanges returns the secondary ranges of the subnet.
+func subnetSecondaryRanges(subnet *compute.Subnetwork) []networkv1alpha1.SubnetSecondaryRange {
+	ranges := []networkv1alpha1.SubnetSecondaryRange{}
+	for _, sr := range subnet.SecondaryIpRanges {
+		ranges = append(ranges, networkv1alpha1.SubnetSecondaryRange{RangeName: sr.RangeName, IPCIDRRange: sr.IpCidrRange})
+	}
+	return ranges
+}
+
+// secondaryRangesCondition returns the SecondaryRangesFound condition of a
+// GKENetworkParamSet referencing the given subnet.
+func secondaryRangesCondition(subnet *compute.Subnetwork, params *networkv1alpha1.GKENetworkParamSet) v1.Condition {
+	subnetRef := subnet.SelfLink
+	if subnetRef == "" {
+		subnetRef = params.Spec.VPCSubnet
+	}
+	var invalid, missing []string
+	if params.Spec.PodIPv4Ranges != nil {
+		for _, ref := range params.Spec.PodIPv4Ranges.RangeNames {
+			rangeName, err := secondaryRangeName(ref, subnetRef)
+			if err != nil {
+				invalid = append(invalid, err.Error())
+				continue
+			}
+			found := false
+			for _, sr := range subnet.SecondaryIpRanges {
+				if sr.RangeName == rangeName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, rangeName)
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return v1.Condition{
+			Type:    wellknown.SecondaryRangesFoundConditionType,
+			Status:  v1.ConditionFalse,
+			Reason:  wellknown.InvalidSecondaryRangeReason,
+			Message: strings.Join(invalid, "; "),
+		}
+	}
+	if len(missing) > 0 {
+		return v1.Condition{
+			Type:    wellknown.SecondaryRangesFoundConditionType,
+			Status:  v1.ConditionFalse,
+			Reason:  wellknown.SecondaryRangeNotFoundReason,
+			Message: fmt.Sprintf("secondary ranges %s do not exist in subnet %s", strings.Join(missing, ", "), subnet.Name),
+		}
+	}
+	return v1.Condition{
+		Type:   wellknown.SecondaryRangesFoundConditionType,
+		Status: v1.ConditionTrue,
+		Reason: wellknown.SecondaryRangesFoundReason,
+	}
+}
+
+// enqueueAll queues every GKENetworkParamSet, so that changes of their subnets
+// in GCE are observed.
+func (c *Controller) enqueueAll() {
+	for _, key := range c.gkeNetworkParamsInformer.GetIndexer().ListKeys() {
+		c.queue.Add(key)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multinetworkreadiness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+
+	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
+)
+
+// Pods listing additional networks in their networkv1.InterfaceAnnotationKey
+// annotation can declare wellknown.MultiNetworkReadyConditionType in
+// spec.readinessGates, so that they are only reported ready once their node has
+// been allocated all of these networks.
+
+const (
+	controllerName = "multinetworkreadiness"
+)
+
+// Controller sets the wellknown.MultiNetworkReadyConditionType condition of the
+// pods declaring it as a readiness gate.
+type Controller struct {
+	kubeClient  clientset.Interface
+	podLister   corelisters.PodLister
+	podsSynced  cache.InformerSynced
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+	queue       workqueue.RateLimitingInterface
+}
+
+// NewMultiNetworkReadinessController returns a new multi-network readiness controller.
+func NewMultiNetworkReadinessController(
+	kubeClient clientset.Interface,
+	podInformer coreinformers.PodInformer,
+	nodeInformer coreinformers.NodeInformer,
+) *Controller {
+	c := &Controller{
+		kubeClient:  kubeClient,
+		podLister:   podInformer.Lister(),
+		podsSynced:  podInformer.Informer().HasSynced,
+		nodeLister:  nodeInformer.Lister(),
+		nodesSynced: nodeInformer.Informer().HasSynced,
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName),
+	}
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePod,
+		UpdateFunc: func(old, new interface{}) {
+			c.enqueuePod(new)
+		},
+	})
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueNodePods,
+		UpdateFunc: func(old, new interface{}) {
+			oldNode, ok := old.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := new.(*v1.Node)
+			if !ok {
+				return
+			}
+			if oldNode.Annotations[networkv1.MultiNetworkAnnotationKey] == newNode.Annotations[networkv1.MultiNetworkAnnotationKey] &&
+				oldNode.Annotations[networkv1.NorthInterfacesAnnotationKey] == newNode.Annotations[networkv1.NorthInterfacesAnnotationKey] &&
+				oldNode.Annotations[wellknown.CompactAnnotationKey] == newNode.Annotations[wellknown.CompactAnnotationKey] &&
+				oldNode.Annotations[wellknown.DelegatedRangesAnnotationKey] == newNode.Annotations[wellknown.DelegatedRangesAnnotationKey] {
+				return
+			}
+			c.enqueueNodePods(newNode)
+		},
+	})
+	return c
+}
+
+// Run starts the workers of the controller until stopCh is closed.
+func (c *Controller) Run(numWorkers int, stopCh <-chan struct{}, controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics) {
+	defer utilruntime.HandleCrash()
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting multinetworkreadiness controller")
+	defer klog.Infof("Shutting down multinetworkreadiness controller")
+	controllerManagerMetrics.ControllerStarted(controllerName)
+	defer controllerManagerMetrics.ControllerStopped(controllerName)
+
+	if !cache.WaitForNamedCacheSync(controllerName, stopCh, c.podsSynced, c.nodesSynced) {
+		return
+	}
+	for i := 0; i < numWorkers; i++ {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+
+	<-stopCh
+}
+
+// enqueuePod queues the pod if it declares the readiness gate and is scheduled.
+func (c *Controller) enqueuePod(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || !hasReadinessGate(pod) || pod.Spec.NodeName == "" {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// enqueueNodePods queues the pods scheduled on the node.
+func (c *Controller) enqueueNodePods(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == node.Name {
+			c.enqueuePod(pod)
+		}
+	}
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.syncPod(ctx, key.(string))
+	if err == nil {
+		c.queue.Forget(key)
+		return true
+	}
+	if c.queue.NumRequeues(key) < 5 {
+		klog.Warningf("Error while updating the multi-network readiness of pod %v, retrying: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	utilruntime.HandleError(err)
+	klog.Errorf("Dropping pod %q out of the queue: %v", key, err)
+	return true
+}
+
+// syncPod sets the readiness gate condition of the pod from the networks
+// allocated to its node.
+func (c *Controller) syncPod(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	pod, err := c.podLister.Pods(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !hasReadinessGate(pod) || pod.Spec.NodeName == "" || !pod.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	node, err := c.nodeLister.Get(pod.Spec.NodeName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	condition := v1.PodCondition{
+		Type:   wellknown.MultiNetworkReadyConditionType,
+		Status: v1.ConditionTrue,
+		Reason: wellknown.NetworksReadyReason,
+	}
+	required, err := requiredNetworks(pod)
+	if err != nil {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = wellknown.NetworksNotReadyReason
+		condition.Message = err.Error()
+	} else if missing := missingNetworks(node, required); len(missing) > 0 {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = wellknown.NetworksNotReadyReason
+		condition.Message = fmt.Sprintf("Node %s is not allocated networks %s", node.Name, strings.Join(missing, ", "))
+	}
+	if _, existing := podutil.GetPodCondition(&pod.Status, wellknown.MultiNetworkReadyConditionType); existing != nil &&
+		existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+		return nil
+	}
+
+	pod = pod.DeepCopy()
+	condition.LastTransitionTime = metav1.Now()
+	podutil.UpdatePodCondition(&pod.Status, &condition)
+	if _, err := c.kubeClient.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the %s condition: %v", wellknown.MultiNetworkReadyConditionType, err)
+	}
+	klog.V(2).Infof("Set condition %s of pod %s to %s: %s", wellknown.MultiNetworkReadyConditionType, key, condition.Status, condition.Message)
+	return nil
+}
+
+// hasReadinessGate returns true if the pod declares the multi-network readiness gate.
+func hasReadinessGate(pod *v1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == wellknown.MultiNetworkReadyConditionType {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredNetworks returns the additional networks referenced by the
+// interfaces annotation of the pod. Interfaces referring to NetworkInterface
+// objects are not considered.
+func requiredNetworks(pod *v1.Pod) ([]string, error) {
+	ann, ok := pod.Annotations[networkv1.InterfaceAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	interfaces, err := networkannotations.ParseInterfaces(ann)
+	if err != nil {
+		return nil, err
+	}
+	var networks []string
+	for _, inf := range interfaces {
+		if inf.Network == nil || networkv1.IsDefaultNetwork(*inf.Network) {
+			continue
+		}
+		networks = append(networks, *inf.Network)
+	}
+	return networks, nil
+}
+
+// missingNetworks returns the networks that are not published on the node,
+// sorted by name. Networks with pod CIDRs are listed in the
+// networkv1.MultiNetworkAnnotationKey annotation, host networks only in the
+// networkv1.NorthInterfacesAnnotationKey annotation, or both in the
+// wellknown.CompactAnnotationKey annotation. With node-local IPAM,
+// delegated networks are only ready once the node agent published their pod
+// CIDRs.
+func missingNetworks(node *v1.Node, networks []string) []string {
+	published := make(map[string]bool)
+	if northInterfaces, _, err := networkannotations.ParseNodeNorthInterfaces(node.Annotations); err == nil {
+		for _, inf := range northInterfaces {
+			published[inf.Network] = true
+		}
+	}
+	if ann, ok := node.Annotations[wellknown.DelegatedRangesAnnotationKey]; ok {
+		var delegatedRanges ipam.DelegatedRangesAnnotation
+		if err := json.Unmarshal([]byte(ann), &delegatedRanges); err == nil {
+			for _, r := range delegatedRanges {
+				published[r.Network] = false
+			}
+		}
+	}
+	if nodeNetworks, _, err := networkannotations.ParseNodeMultiNetwork(node.Annotations); err == nil {
+		for _, nw := range nodeNetworks {
+			published[nw.Name] = true
+		}
+	}
+	var missing []string
+	for _, network := range networks {
+		if !published[network] {
+			missing = append(missing, network)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multinetworkreadiness
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestSyncPod(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		podAnnotations  map[string]string
+		noReadinessGate bool
+		nodeAnnotations map[string]string
+		wantStatus      v1.ConditionStatus
+		wantMessage     string
+	}{
+		{
+			desc:            "networks allocated",
+			podAnnotations:  map[string]string{networkv1.InterfaceAnnotationKey: `[{"interfaceName":"eth0","network":"pod-network"},{"interfaceName":"eth1","network":"red"},{"interfaceName":"eth2","network":"blue"}]`},
+			nodeAnnotations: map[string]string{networkv1.MultiNetworkAnnotationKey: `[{"name":"red","cidrs":["172.16.0.0/24"],"scope":"host-local"}]`, networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"},{"network":"blue","ipAddress":"10.1.0.2"}]`},
+			wantStatus:      v1.ConditionTrue,
+		},
+		{
+			desc:            "network missing",
+			podAnnotations:  map[string]string{networkv1.InterfaceAnnotationKey: `[{"interfaceName":"eth1","network":"red"},{"interfaceName":"eth2","network":"blue"}]`},
+			nodeAnnotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"}]`},
+			wantStatus:      v1.ConditionFalse,
+			wantMessage:     "Node node0 is not allocated networks blue",
+		},
+		{
+			desc:           "delegated network not yet set up",
+			podAnnotations: map[string]string{networkv1.InterfaceAnnotationKey: `[{"interfaceName":"eth1","network":"red"}]`},
+			nodeAnnotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"}]`,
+				wellknown.DelegatedRangesAnnotationKey: `[{"network":"red","interface":"nic1","subnetwork":"red","rangeNames":["RedRangeA"]}]`,
+			},
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: "Node node0 is not allocated networks red",
+		},
+		{
+			desc:            "corrupted networks annotation",
+			podAnnotations:  map[string]string{networkv1.InterfaceAnnotationKey: `[{"interfaceName":"eth1","network":"red"}]`},
+			nodeAnnotations: map[string]string{networkv1.MultiNetworkAnnotationKey: `[{"name":"red","cidrs":["172.16.0.0/33"],"scope":"host-local"}]`},
+			wantStatus:      v1.ConditionFalse,
+			wantMessage:     "Node node0 is not allocated networks red",
+		},
+		{
+			desc:           "invalid interfaces annotation",
+			podAnnotations: map[string]string{networkv1.InterfaceAnnotationKey: `{`},
+			wantStatus:     v1.ConditionFalse,
+		},
+		{
+			desc:       "default network only",
+			wantStatus: v1.ConditionTrue,
+		},
+		{
+			desc:            "no readiness gate",
+			podAnnotations:  map[string]string{networkv1.InterfaceAnnotationKey: `[{"interfaceName":"eth1","network":"red"}]`},
+			noReadinessGate: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.nodeAnnotations}}
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod0", Annotations: tc.podAnnotations},
+				Spec:       v1.PodSpec{NodeName: node.Name},
+			}
+			if !tc.noReadinessGate {
+				pod.Spec.ReadinessGates = []v1.PodReadinessGate{{ConditionType: wellknown.MultiNetworkReadyConditionType}}
+			}
+			client := fake.NewSimpleClientset(node, pod)
+			informerFactory := informers.NewSharedInformerFactory(client, 0)
+			podInformer := informerFactory.Core().V1().Pods()
+			nodeInformer := informerFactory.Core().V1().Nodes()
+			podInformer.Informer().GetStore().Add(pod)
+			nodeInformer.Informer().GetStore().Add(node)
+			c := NewMultiNetworkReadinessController(client, podInformer, nodeInformer)
+
+			if err := c.syncPod(context.TODO(), "default/pod0"); err != nil {
+				t.Fatalf("syncPod() returned err %v", err)
+			}
+			got, err := client.CoreV1().Pods("default").Get(context.TODO(), "pod0", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the pod: %v", err)
+			}
+			_, condition := podutil.GetPodCondition(&got.Status, wellknown.MultiNetworkReadyConditionType)
+			if tc.wantStatus == "" {
+				if condition != nil {
+					t.Errorf("got condition %v, want none", condition)
+				}
+				return
+			}
+			if condition == nil {
+				t.Fatalf("got no %s condition, want status %s", wellknown.MultiNetworkReadyConditionType, tc.wantStatus)
+			}
+			if condition.Status != tc.wantStatus {
+				t.Errorf("got condition status %s, want %s", condition.Status, tc.wantStatus)
+			}
+			if tc.wantMessage != "" && condition.Message != tc.wantMessage {
+				t.Errorf("got condition message %q, want %q", condition.Message, tc.wantMessage)
+			}
+
+			// An unchanged condition is not written again.
+			podInformer.Informer().GetStore().Update(got)
+			client.ClearActions()
+			if err := c.syncPod(context.TODO(), "default/pod0"); err != nil {
+				t.Fatalf("syncPod() returned err %v", err)
+			}
+			if actions := client.Actions(); len(actions) != 0 {
+				t.Errorf("got API calls %v for an unchanged condition, want none", actions)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkusage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	networkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1"
+	alphanetworkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1alpha1"
+	networklister "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1"
+	alphanetworklister "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+
+	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
+)
+
+const (
+	// DefaultRefreshPeriod is the default period between two usage refreshes.
+	DefaultRefreshPeriod = 5 * time.Minute
+)
+
+// NetworkUsage summarizes the use of a Network in the cluster.
+type NetworkUsage struct {
+	// Nodes is the number of nodes attached to the network.
+	Nodes int `json:"nodes"`
+	// Ranges holds the usage of every secondary range of the network.
+	Ranges []RangeUsage `json:"ranges,omitempty"`
+}
+
+// RangeUsage summarizes the pod IPs allocated to nodes from a secondary range.
+type RangeUsage struct {
+	// Name is the name of the secondary range.
+	Name string `json:"name"`
+	// CIDR is the IP range of the secondary range.
+	CIDR string `json:"cidr"`
+	// Allocated is the number of pod IPs allocated to nodes from the range.
+	Allocated int64 `json:"allocated"`
+	// Free is the number of pod IPs of the range not allocated to any node.
+	Free int64 `json:"free"`
+}
+
+// Controller periodically publishes the NetworkUsage of every Network.
+type Controller struct {
+	networkClientset networkclientset.Interface
+	networksLister   networklister.NetworkLister
+	networksSynced   cache.InformerSynced
+	gnpLister        alphanetworklister.GKENetworkParamSetLister
+	gnpSynced        cache.InformerSynced
+	nodeLister       corelisters.NodeLister
+	nodesSynced      cache.InformerSynced
+	gceCloud         *gce.Cloud
+	refreshPeriod    time.Duration
+}
+
+// NewNetworkUsageController returns a new network usage controller.
+func NewNetworkUsageController(
+	networkClientset networkclientset.Interface,
+	nwInformer networkinformer.NetworkInformer,
+	gnpInformer alphanetworkinformer.GKENetworkParamSetInformer,
+	nodeLister corelisters.NodeLister,
+	nodesSynced cache.InformerSynced,
+	gceCloud *gce.Cloud,
+	refreshPeriod time.Duration,
+) *Controller {
+	return &Controller{
+		networkClientset: networkClientset,
+		networksLister:   nwInformer.Lister(),
+		networksSynced:   nwInformer.Informer().HasSynced,
+		gnpLister:        gnpInformer.Lister(),
+		gnpSynced:        gnpInformer.Informer().HasSynced,
+		nodeLister:       nodeLister,
+		nodesSynced:      nodesSynced,
+		gceCloud:         gceCloud,
+		refreshPeriod:    refreshPeriod,
+	}
+}
+
+// Run refreshes the usage of the Networks every refresh period until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}, controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics) {
+	defer utilruntime.HandleCrash()
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	klog.Infof("Starting networkusage controller")
+	defer klog.Infof("Shutting down networkusage controller")
+	controllerManagerMetrics.ControllerStarted("networkusage")
+	defer controllerManagerMetrics.ControllerStopped("networkusage")
+
+	if !cache.WaitForNamedCacheSync("networkusage", stopCh, c.networksSynced, c.gnpSynced, c.nodesSynced) {
+		return
+	}
+	go wait.UntilWithContext(ctx, c.sync, c.refreshPeriod)
+
+	<-stopCh
+}
+
+// sync publishes the usage of every Network that is not being deleted.
+func (c *Controller) sync(ctx context.Context) {
+	networks, err := c.networksLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list networks: %v", err)
+		return
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list nodes: %v", err)
+		return
+	}
+	for _, network := range networks {
+		if !network.DeletionTimestamp.IsZero() {
+			continue
+		}
+		usage, err := c.networkUsage(network, nodes)
+		if err != nil {
+			klog.Warningf("Failed to compute the usage of network %s: %v", network.Name, err)
+			continue
+		}
+		if err := c.publish(ctx, network, usage); err != nil {
+			klog.Warningf("Failed to publish the usage of network %s: %v", network.Name, err)
+		}
+	}
+}
+
+// networkUsage aggregates the node pod CIDRs of a network into the usage of its secondary ranges.
+func (c *Controller) networkUsage(network *networkv1.Network, nodes []*v1.Node) (*NetworkUsage, error) {
+	usage := &NetworkUsage{}
+	var nodeCIDRs []*net.IPNet
+	for _, node := range nodes {
+		cidrs, attached := nodePodCIDRs(node, network.Name)
+		if !attached {
+			continue
+		}
+		usage.Nodes++
+		nodeCIDRs = append(nodeCIDRs, cidrs...)
+	}
+	if network.Spec.ParametersRef == nil {
+		return usage, nil
+	}
+	gnp, err := c.gnpLister.Get(network.Spec.ParametersRef.Name)
+	if err != nil {
+		return nil, err
+	}
+	if gnp.Spec.PodIPv4Ranges == nil || len(gnp.Spec.PodIPv4Ranges.RangeNames) == 0 {
+		return usage, nil
+	}
+	subnet, err := c.getSubnetwork(gnp.Spec.VPCSubnet)
+	if err != nil {
+		return nil, err
+	}
+	for _, rangeName := range gkenetworkparamset.PodIPv4RangeNames(gnp) {
+		sr := secondaryRange(subnet, rangeName)
+		if sr == nil {
+			continue
+		}
+		usage.Ranges = append(usage.Ranges, rangeUsage(sr, nodeCIDRs))
+	}
+	return usage, nil
+}
+
+// getSubnetwork fetches the subnetwork referenced by a GKENetworkParamSet.
+func (c *Controller) getSubnetwork(ref string) (*compute.Subnetwork, error) {
+	id, err := gcpurl.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	region := c.gceCloud.Region()
+	if id.Region != "" {
+		region = id.Region
+	}
+	return c.gceCloud.GetSubnetwork(region, id.Name)
+}
+
+// publish sets the usage annotation on the network if it changed.
+func (c *Controller) publish(ctx context.Context, network *networkv1.Network, usage *NetworkUsage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	if network.Annotations[wellknown.UsageAnnotationKey] == string(data) {
+		return nil
+	}
+	network = network.DeepCopy()
+	if network.Annotations == nil {
+		network.Annotations = map[string]string{}
+	}
+	network.Annotations[wellknown.UsageAnnotationKey] = string(data)
+	if _, err := c.networkClientset.NetworkingV1().Networks().Update(ctx, network, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update Network usage annotation: %v", err)
+	}
+	klog.V(4).Infof("Network %s usage is: %s", network.Name, data)
+	return nil
+}
+
+// nodePodCIDRs returns the pod CIDRs of a network on the node, and whether the
+// node is attached to the network at all.
+func nodePodCIDRs(node *v1.Node, networkName string) ([]*net.IPNet, bool) {
+	if networkv1.IsDefaultNetwork(networkName) {
+		cidrs, err := netutils.ParseCIDRs(node.Spec.PodCIDRs)
+		if err != nil {
+			return nil, true
+		}
+		return cidrs, len(node.Spec.PodCIDRs) > 0
+	}
+	attached := false
+	if northInterfaces, ok, err := networkannotations.ParseNodeNorthInterfaces(node.Annotations); err == nil && ok {
+		for _, inf := range northInterfaces {
+			attached = attached || inf.Network == networkName
+		}
+	}
+	nodeNetworks, ok, err := networkannotations.ParseNodeMultiNetwork(node.Annotations)
+	if err != nil || !ok {
+		return nil, attached
+	}
+	var cidrs []*net.IPNet
+	for _, nw := range nodeNetworks {
+		if nw.Name != networkName {
+			continue
+		}
+		attached = true
+		for _, cidr := range nw.Cidrs {
+			if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+				cidrs = append(cidrs, ipNet)
+			}
+		}
+	}
+	return cidrs, attached
+}
+
+func secondaryRange(subnet *compute.Subnetwork, rangeName string) *compute.SubnetworkSecondaryRange {
+	for _, sr := range subnet.SecondaryIpRanges {
+		if sr.RangeName == rangeName {
+			return sr
+		}
+	}
+	return nil
+}
+
+// rangeUsage counts the IPs of the node CIDRs contained in the secondary range.
+func rangeUsage(sr *compute.SubnetworkSecondaryRange, nodeCIDRs []*net.IPNet) RangeUsage {
+	usage := RangeUsage{Name: sr.RangeName, CIDR: sr.IpCidrRange}
+	_, rangeNet, err := net.ParseCIDR(sr.IpCidrRange)
+	if err != nil {
+		return usage
+	}
+	for _, cidr := range nodeCIDRs {
+		if rangeNet.Contains(cidr.IP) {
+			usage.Allocated += netutils.RangeSize(cidr)
+		}
+	}
+	usage.Free = netutils.RangeSize(rangeNet) - usage.Allocated
+	return usage
+}
@@ -0,0 +1,123 @@
+package networkusage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+)
+
+func TestSyncPublishesNetworkUsage(t *testing.T) {
+	ctx := context.Background()
+	clusterValues := gce.DefaultTestClusterValues()
+	fakeGCE := gce.NewFakeGCECloud(clusterValues)
+	subnet := &compute.Subnetwork{
+		Name: "red-subnet",
+		SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{
+			{RangeName: "red-pods-a", IpCidrRange: "172.16.0.0/22"},
+			{RangeName: "red-pods-b", IpCidrRange: "172.17.0.0/22"},
+			{RangeName: "unused", IpCidrRange: "172.18.0.0/22"},
+		},
+	}
+	if err := fakeGCE.Compute().Subnetworks().Insert(ctx, meta.RegionalKey(subnet.Name, clusterValues.Region), subnet); err != nil {
+		t.Fatalf("error in test setup, could not create subnet: %v", err)
+	}
+
+	networks := []*networkv1.Network{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "red"},
+			Spec: networkv1.NetworkSpec{
+				Type:          networkv1.L3NetworkType,
+				ParametersRef: &networkv1.NetworkParametersReference{Group: "networking.gke.io", Kind: "GKENetworkParamSet", Name: "red-params"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "host"},
+			Spec:       networkv1.NetworkSpec{Type: networkv1.L3NetworkType},
+		},
+	}
+	gnp := &networkv1alpha1.GKENetworkParamSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "red-params"},
+		Spec: networkv1alpha1.GKENetworkParamSetSpec{
+			VPC:           "red",
+			VPCSubnet:     subnet.Name,
+			PodIPv4Ranges: &networkv1alpha1.SecondaryRanges{RangeNames: []string{"red-pods-a", "red-pods-b"}},
+		},
+	}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-a",
+				Annotations: map[string]string{
+					networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.2"},{"network":"host","ipAddress":"10.1.0.2"}]`,
+					networkv1.MultiNetworkAnnotationKey:    `[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24"]}]`,
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-b",
+				Annotations: map[string]string{
+					networkv1.NorthInterfacesAnnotationKey: `[{"network":"red","ipAddress":"10.0.0.3"}]`,
+					networkv1.MultiNetworkAnnotationKey:    `[{"name":"red","scope":"host-local","cidrs":["172.16.1.0/25"]}]`,
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-c"},
+		},
+	}
+
+	networkClient := fake.NewSimpleClientset(networks[0], networks[1], gnp)
+	nwInfFactory := networkinformers.NewSharedInformerFactory(networkClient, 0)
+	nwInformer := nwInfFactory.Networking().V1().Networks()
+	gnpInformer := nwInfFactory.Networking().V1alpha1().GKENetworkParamSets()
+	nodeInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	for _, nw := range networks {
+		nwInformer.Informer().GetStore().Add(nw)
+	}
+	gnpInformer.Informer().GetStore().Add(gnp)
+	for _, node := range nodes {
+		nodeInformer.Informer().GetStore().Add(node)
+	}
+	c := NewNetworkUsageController(networkClient, nwInformer, gnpInformer, nodeInformer.Lister(), nodeInformer.Informer().HasSynced, fakeGCE, DefaultRefreshPeriod)
+
+	c.sync(ctx)
+
+	want := map[string]NetworkUsage{
+		"red": {
+			Nodes: 2,
+			Ranges: []RangeUsage{
+				{Name: "red-pods-a", CIDR: "172.16.0.0/22", Allocated: 384, Free: 640},
+				{Name: "red-pods-b", CIDR: "172.17.0.0/22", Allocated: 0, Free: 1024},
+			},
+		},
+		"host": {Nodes: 1},
+	}
+	for name, wantUsage := range want {
+		network, err := networkClient.NetworkingV1().Networks().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get(%s) returned err %v", name, err)
+		}
+		var got NetworkUsage
+		if err := json.Unmarshal([]byte(network.Annotations[wellknown.UsageAnnotationKey]), &got); err != nil {
+			t.Fatalf("network %s has an invalid usage annotation %q: %v", name, network.Annotations[wellknown.UsageAnnotationKey], err)
+		}
+		if diff := cmp.Diff(wantUsage, got); diff != "" {
+			t.Errorf("network %s usage mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scheme holds the scheme and codecs used to load the node IPAM
+// controller configuration file.
+package scheme
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/v1alpha1"
+)
+
+var (
+	// Scheme is the runtime.Scheme to which all node IPAM config API types are registered.
+	Scheme = runtime.NewScheme()
+
+	// Codecs provides access to encoding and decoding for the scheme.
+	Codecs = serializer.NewCodecFactory(Scheme, serializer.EnableStrict)
+)
+
+func init() {
+	AddToScheme(Scheme)
+}
+
+// AddToScheme builds the node IPAM config scheme using all known versions of the API.
+func AddToScheme(scheme *runtime.Scheme) {
+	utilruntime.Must(config.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(scheme.SetVersionPriority(v1alpha1.SchemeGroupVersion))
+}
+
+// Decode decodes a versioned NodeIPAMConfiguration, applies its defaults and
+// returns the internal representation.
+func Decode(data []byte) (*config.NodeIPAMConfiguration, error) {
+	obj, gvk, err := Codecs.UniversalDecoder().Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg, ok := obj.(*config.NodeIPAMConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("couldn't decode as NodeIPAMConfiguration, got %s", gvk)
+	}
+	return cfg, nil
+}
+
+// Encode returns the YAML representation of the NodeIPAMConfiguration in the
+// preferred API version, which Decode loads back.
+func Encode(cfg *config.NodeIPAMConfiguration) ([]byte, error) {
+	info, ok := runtime.SerializerInfoForMediaType(Codecs.SupportedMediaTypes(), runtime.ContentTypeYAML)
+	if !ok {
+		return nil, fmt.Errorf("no serializer for %s", runtime.ContentTypeYAML)
+	}
+	encoder := Codecs.EncoderForVersion(info.Serializer, v1alpha1.SchemeGroupVersion)
+	return runtime.Encode(encoder, cfg)
+}
+
+// Default returns the internal NodeIPAMConfiguration with the defaults of the
+// preferred API version applied.
+func Default() (*config.NodeIPAMConfiguration, error) {
+	versioned := &v1alpha1.NodeIPAMConfiguration{}
+	Scheme.Default(versioned)
+	cfg := &config.NodeIPAMConfiguration{}
+	if err := Scheme.Convert(versioned, cfg, nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
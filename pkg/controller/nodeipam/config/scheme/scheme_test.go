@@ -0,0 +1,221 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		data      string
+		want      *config.NodeIPAMConfiguration
+		expectErr bool
+	}{
+		{
+			desc: "empty config is defaulted",
+			data: `
+apiVersion: nodeipam.config.gke.io/v1alpha1
+kind: NodeIPAMConfiguration
+`,
+			want: &config.NodeIPAMConfiguration{
+				MultiNetwork: config.MultiNetworkConfiguration{
+					Enabled:                      true,
+					ResyncPeriod:                 metav1.Duration{Duration: 30 * time.Second},
+					MaxAdditionalNetworks:        7,
+					NetworkRolloutNodesPerMinute: 0,
+					ComputeAPIVersion:            "v1",
+					AnnotationEncoding:           "per-key",
+				},
+				Backoff: config.BackoffConfiguration{
+					InitialDelay:              metav1.Duration{Duration: 250 * time.Millisecond},
+					MaxDelay:                  metav1.Duration{Duration: 5 * time.Second},
+					MaxRetries:                10,
+					FailureConditionThreshold: 5,
+				},
+				ClientConnection: config.ClientConnectionConfiguration{
+					InformerQPS:   5,
+					InformerBurst: 10,
+					WriteQPS:      20,
+					WriteBurst:    30,
+					EventQPS:      1,
+					EventBurst:    100,
+				},
+			},
+		},
+		{
+			desc: "all fields set",
+			data: `
+apiVersion: nodeipam.config.gke.io/v1alpha1
+kind: NodeIPAMConfiguration
+cidrAllocatorType: CloudAllocator
+serviceCIDR: 10.0.0.0/16
+secondaryServiceCIDR: fd00::/108
+nodeCIDRMaskSizeIPv4: 25
+nodeCIDRMaskSizeIPv6: 112
+multiNetwork:
+  enabled: false
+  resyncPeriod: 1m
+  nodeLocalIPAM: true
+  shadowAllocator: indexed
+  defaultNetworkName: primary
+  maxAdditionalNetworks: 4
+  nodeCoordinationLeases: true
+  networkRolloutNodesPerMinute: 120
+  computeAPIVersion: beta
+  disableIPCapacity: true
+  maxAliasRangeMaskSize: 29
+  annotationEncoding: compact
+  legacyAnnotations: true
+  requireReadyParams: true
+backoff:
+  initialDelay: 1s
+  maxDelay: 1m
+  maxRetries: 3
+  failureConditionThreshold: 2
+clientConnection:
+  informerQPS: 1
+  informerBurst: 2
+  writeQPS: 50
+  writeBurst: 100
+  eventQPS: 5
+  eventBurst: 10
+strandedPodCIDRThreshold: 6h
+podCIDROwnershipLease: true
+auditSink: /var/log/node-ipam-audit.log
+podRangeExemptions:
+- labelSelector: node-role.kubernetes.io/control-plane
+- taintKey: dedicated
+podIPReconciliation: true
+allocationCheckpoints: true
+healthStatus: true
+`,
+			want: &config.NodeIPAMConfiguration{
+				CIDRAllocatorType: "CloudAllocator",
+				NodeIPAMController: config.NodeIPAMControllerConfiguration{
+					ServiceCIDR:          "10.0.0.0/16",
+					SecondaryServiceCIDR: "fd00::/108",
+					NodeCIDRMaskSizeIPv4: 25,
+					NodeCIDRMaskSizeIPv6: 112,
+				},
+				MultiNetwork: config.MultiNetworkConfiguration{
+					Enabled:                      false,
+					ResyncPeriod:                 metav1.Duration{Duration: time.Minute},
+					NodeLocalIPAM:                true,
+					ShadowAllocator:              "indexed",
+					DefaultNetworkName:           "primary",
+					MaxAdditionalNetworks:        4,
+					NodeCoordinationLeases:       true,
+					NetworkRolloutNodesPerMinute: 120,
+					ComputeAPIVersion:            "beta",
+					DisableIPCapacity:            true,
+					MaxAliasRangeMaskSize:        29,
+					AnnotationEncoding:           "compact",
+					LegacyAnnotations:            true,
+					RequireReadyParams:           true,
+				},
+				Backoff: config.BackoffConfiguration{
+					InitialDelay:              metav1.Duration{Duration: time.Second},
+					MaxDelay:                  metav1.Duration{Duration: time.Minute},
+					MaxRetries:                3,
+					FailureConditionThreshold: 2,
+				},
+				ClientConnection: config.ClientConnectionConfiguration{
+					InformerQPS:   1,
+					InformerBurst: 2,
+					WriteQPS:      50,
+					WriteBurst:    100,
+					EventQPS:      5,
+					EventBurst:    10,
+				},
+				StrandedPodCIDRThreshold: metav1.Duration{Duration: 6 * time.Hour},
+				PodCIDROwnershipLease:    true,
+				AuditSink:                "/var/log/node-ipam-audit.log",
+				PodRangeExemptions: []config.PodRangeExemption{
+					{LabelSelector: "node-role.kubernetes.io/control-plane"},
+					{TaintKey: "dedicated"},
+				},
+				PodIPReconciliation:   true,
+				AllocationCheckpoints: true,
+				HealthStatus:          true,
+			},
+		},
+		{
+			desc: "unknown field is rejected",
+			data: `
+apiVersion: nodeipam.config.gke.io/v1alpha1
+kind: NodeIPAMConfiguration
+nodeCIDRMask: 24
+`,
+			expectErr: true,
+		},
+		{
+			desc: "unknown version is rejected",
+			data: `
+apiVersion: nodeipam.config.gke.io/v1
+kind: NodeIPAMConfiguration
+`,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := Decode([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Fatalf("Decode() returned err %v, want error %v", err, tc.expectErr)
+			}
+			if tc.expectErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decode() returned unexpected config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in, err := Default()
+	if err != nil {
+		t.Fatalf("Default() returned err %v", err)
+	}
+	in.CIDRAllocatorType = "CloudAllocator"
+	in.NodeIPAMController.NodeCIDRMaskSize = 26
+	in.MultiNetwork.Enabled = false
+	in.MultiNetwork.MaxAdditionalNetworks = 0
+	in.MultiNetwork.NetworkRolloutNodesPerMinute = 120
+	in.Backoff.MaxRetries = 0
+	in.Backoff.FailureConditionThreshold = 0
+
+	data, err := Encode(in)
+	if err != nil {
+		t.Fatalf("Encode() returned err %v", err)
+	}
+	out, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() returned err %v for\n%s", err, data)
+	}
+	if diff := cmp.Diff(in, out); diff != "" {
+		t.Errorf("round trip returned unexpected config (-want +got):\n%s", diff)
+	}
+}
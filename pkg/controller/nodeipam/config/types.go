@@ -16,6 +16,184 @@ limitations under the License.
 
 package config
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeIPAMConfiguration is the file based configuration of the node IPAM
+// controller. It replaces the individual nodeipam flags when loaded through
+// --nodeipam-config.
+type NodeIPAMConfiguration struct {
+	metav1.TypeMeta
+
+	// CIDRAllocatorType is the type of CIDR allocator to use. When empty the
+	// value of --cidr-allocator-type is used.
+	CIDRAllocatorType string
+	// NodeIPAMController holds the service CIDR and node mask size settings.
+	NodeIPAMController NodeIPAMControllerConfiguration
+	// MultiNetwork holds the settings for allocating pod CIDRs of additional networks.
+	MultiNetwork MultiNetworkConfiguration
+	// Backoff holds the retry settings for failed node CIDR updates.
+	Backoff BackoffConfiguration
+	// ClientConnection holds the rate limits of the API clients of the
+	// controller.
+	ClientConnection ClientConnectionConfiguration
+	// StrandedPodCIDRThreshold is the time after which a node without pods
+	// using its pod CIDR is reported as stranded, so that autoscaling and
+	// capacity reclamation tooling can consider it for scale-down. Zero
+	// disables the tracking.
+	StrandedPodCIDRThreshold metav1.Duration
+	// PodCIDROwnershipLease makes the allocator claim a Lease asserting it
+	// owns the pod CIDRs of the cluster before it starts, stop allocating
+	// while another allocator owns them, and resume once it owns them again.
+	// Only the cloud allocator supports it. The kube-controller-manager does
+	// not claim the Lease itself, see PodCIDROwnerKubeControllerManager.
+	PodCIDROwnershipLease bool
+	// AuditSink is where the changes of the pod CIDRs, annotations and IP
+	// capacity of the nodes are recorded with their reason: an http:// or
+	// https:// URL the records are POSTed to, or the path of a file they are
+	// appended to as JSON lines. Empty disables the audit.
+	AuditSink string
+	// PodRangeExemptions selects the nodes, e.g. the control-plane ones,
+	// whose instance has no pod alias IP range on purpose. They are skipped
+	// with a warning instead of being retried and reported as failed.
+	PodRangeExemptions []PodRangeExemption
+	// PodIPReconciliation enables the periodic check of the IPs of the pods
+	// against the pod CIDRs published on their node. The pods whose IPs fall
+	// outside of them, the sign of a stale allocation or of a drift of the
+	// dataplane, are counted in a metric and reported with events.
+	PodIPReconciliation bool
+	// AllocationCheckpoints persists the pod CIDRs published on the nodes in
+	// NodeIPAMCheckpoint objects. After a restart, the nodes still matching
+	// them are not read from the compute API until they are refreshed in the
+	// background, which shortens the cold start of large clusters.
+	AllocationCheckpoints bool
+	// HealthStatus publishes the health of the cloud allocator in the
+	// cluster scoped NodeIPAMHealth object: the last time every node was up
+	// to date, the error rate of the node updates and whether the updates of
+	// nodes are deferred to shed load.
+	HealthStatus bool
+}
+
+// PodRangeExemption selects the nodes matching both its label selector and
+// taint key, at least one of which is set.
+type PodRangeExemption struct {
+	// LabelSelector is a label selector of the nodes, e.g.
+	// node-role.kubernetes.io/control-plane.
+	LabelSelector string
+	// TaintKey is the key of a taint of the nodes, e.g.
+	// node-role.kubernetes.io/control-plane.
+	TaintKey string
+}
+
+// MultiNetworkConfiguration contains elements describing multi-network pod CIDR allocation.
+type MultiNetworkConfiguration struct {
+	// Enabled allows the cloud allocator to allocate pod CIDRs for additional
+	// networks. When disabled only the default network is considered.
+	Enabled bool
+	// ResyncPeriod is the resync period of the Network and GKENetworkParamSet informers.
+	ResyncPeriod metav1.Duration
+	// NodeLocalIPAM delegates the alias IP range attach of additional networks
+	// to a node agent. The controller only validates and publishes the secondary
+	// range names on the node instead of the concrete pod CIDRs.
+	NodeLocalIPAM bool
+	// ShadowAllocator is the name of an alternative multi-network allocation
+	// algorithm run in shadow mode. Its results are compared with the active
+	// algorithm and exported as metrics, but never written to the nodes.
+	ShadowAllocator string
+	// DefaultNetworkName is the name of the Network the pod CIDRs of the nodes
+	// are allocated from, for deployments not using the built-in default
+	// network names.
+	DefaultNetworkName string
+	// MaxAdditionalNetworks is the maximum number of additional networks
+	// published on a node. The networks beyond it are ignored. Zero disables the
+	// limit.
+	MaxAdditionalNetworks int32
+	// NodeCoordinationLeases enables a coordination Lease per node, updated
+	// after the multi-network state of the node is published, which node
+	// agents acknowledge once the node side setup is done.
+	NodeCoordinationLeases bool
+	// NodeCleanupHooks enables calling the cleanup hook of each additional
+	// network of a deleted node, so that external systems can release the
+	// resources of the node. It requires NodeCoordinationLeases.
+	NodeCleanupHooks bool
+	// PredictiveAllocation publishes the additional networks a new node is
+	// expected to join, predicted from the instance template of its managed
+	// instance group, while its instance is not visible yet.
+	PredictiveAllocation bool
+	// NetworkRolloutNodesPerMinute is the rate at which the nodes are
+	// reconciled after a Network is created or changed, so that the updates
+	// of large clusters are spread over time. Zero reconciles all the nodes
+	// at once.
+	NetworkRolloutNodesPerMinute int32
+	// ComputeAPIVersion is the version of the compute API the instances of
+	// the nodes are read with, one of v1, beta or alpha. The beta and alpha
+	// APIs return network interface fields, e.g. of IPv6 or per-NIC features,
+	// before the GA API.
+	ComputeAPIVersion string
+	// DisableIPCapacity stops publishing the IP capacity of the additional
+	// networks in the node status, for clusters scheduling the pods of the
+	// additional networks with device plugins. The multi-network annotations
+	// are still published.
+	DisableIPCapacity bool
+	// MaxAliasRangeMaskSize is the longest prefix length of the alias IP
+	// ranges of additional networks published on the nodes, e.g. 29. Smaller
+	// ranges are skipped with an event, as their few pod IPs would break the
+	// workloads of the network later. Zero accepts ranges of any size.
+	MaxAliasRangeMaskSize int32
+	// AnnotationEncoding is the encoding of the multi-network annotations of
+	// the nodes, one of per-key, compact or compact-gzip. See
+	// wellknown.CompactAnnotationKey for the compact encodings.
+	AnnotationEncoding string
+	// LegacyAnnotations additionally publishes the north interfaces and the
+	// networks of the nodes under the annotation keys read by older
+	// dataplane agents. Not supported with node-local IPAM.
+	LegacyAnnotations bool
+	// RequireReadyParams skips the additional networks whose
+	// GKENetworkParamSet does not have a true Ready condition, so that the
+	// nodes are not allocated networks whose infrastructure is still being
+	// provisioned.
+	RequireReadyParams bool
+}
+
+// BackoffConfiguration contains elements describing the retries of failed node CIDR updates.
+type BackoffConfiguration struct {
+	// InitialDelay is the time to wait before requeuing a node for the first retry.
+	InitialDelay metav1.Duration
+	// MaxDelay is the maximum time to wait between retries.
+	MaxDelay metav1.Duration
+	// MaxRetries is the number of retries before a node is dropped from the queue.
+	MaxRetries int32
+	// FailureConditionThreshold is the number of consecutive failed updates
+	// after which the CIDRAllocationFailed condition is set on the node. Zero
+	// disables the condition.
+	FailureConditionThreshold int32
+}
+
+// ClientConnectionConfiguration contains the rate limits of the API clients
+// of the node IPAM controller. Writes use their own client so that they are
+// not throttled behind the list and watch requests of the informers.
+type ClientConnectionConfiguration struct {
+	// InformerQPS is the QPS of the client listing and watching Networks and
+	// GKENetworkParamSets.
+	InformerQPS float32
+	// InformerBurst is the burst of the informer client.
+	InformerBurst int32
+	// WriteQPS is the QPS of the client updating nodes, leases, events and
+	// Networks.
+	WriteQPS float32
+	// WriteBurst is the burst of the write client.
+	WriteBurst int32
+	// EventQPS is the QPS of the events of each reason recorded by the cloud
+	// allocator. The events beyond it are dropped, so that an outage failing
+	// the updates of every node does not flood the API server with events.
+	EventQPS float32
+	// EventBurst is the burst of the events of each reason.
+	EventBurst int32
+}
+
 // NodeIPAMControllerConfiguration contains elements describing NodeIPAMController.
 type NodeIPAMControllerConfiguration struct {
 	// ServiceCIDR is CIDR Range for Services in cluster.
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config"
 	"k8s.io/kube-controller-manager/config/v1alpha1"
 )
@@ -38,3 +39,115 @@ func Convert_v1alpha1_NodeIPAMControllerConfiguration_To_config_NodeIPAMControll
 func Convert_config_NodeIPAMControllerConfiguration_To_v1alpha1_NodeIPAMControllerConfiguration(in *config.NodeIPAMControllerConfiguration, out *v1alpha1.NodeIPAMControllerConfiguration, s conversion.Scope) error {
 	return autoConvert_config_NodeIPAMControllerConfiguration_To_v1alpha1_NodeIPAMControllerConfiguration(in, out, s)
 }
+
+func addConversionFuncs(s *runtime.Scheme) error {
+	if err := s.AddConversionFunc((*NodeIPAMConfiguration)(nil), (*config.NodeIPAMConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_NodeIPAMConfiguration_To_config_NodeIPAMConfiguration(a.(*NodeIPAMConfiguration), b.(*config.NodeIPAMConfiguration), scope)
+	}); err != nil {
+		return err
+	}
+	return s.AddConversionFunc((*config.NodeIPAMConfiguration)(nil), (*NodeIPAMConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_NodeIPAMConfiguration_To_v1alpha1_NodeIPAMConfiguration(a.(*config.NodeIPAMConfiguration), b.(*NodeIPAMConfiguration), scope)
+	})
+}
+
+// Convert_v1alpha1_NodeIPAMConfiguration_To_config_NodeIPAMConfiguration converts a versioned NodeIPAMConfiguration
+// to its internal representation.
+func Convert_v1alpha1_NodeIPAMConfiguration_To_config_NodeIPAMConfiguration(in *NodeIPAMConfiguration, out *config.NodeIPAMConfiguration, s conversion.Scope) error {
+	out.CIDRAllocatorType = in.CIDRAllocatorType
+	out.NodeIPAMController.ServiceCIDR = in.ServiceCIDR
+	out.NodeIPAMController.SecondaryServiceCIDR = in.SecondaryServiceCIDR
+	out.NodeIPAMController.NodeCIDRMaskSize = in.NodeCIDRMaskSize
+	out.NodeIPAMController.NodeCIDRMaskSizeIPv4 = in.NodeCIDRMaskSizeIPv4
+	out.NodeIPAMController.NodeCIDRMaskSizeIPv6 = in.NodeCIDRMaskSizeIPv6
+	if in.MultiNetwork.Enabled != nil {
+		out.MultiNetwork.Enabled = *in.MultiNetwork.Enabled
+	}
+	out.MultiNetwork.ResyncPeriod = in.MultiNetwork.ResyncPeriod
+	out.MultiNetwork.NodeLocalIPAM = in.MultiNetwork.NodeLocalIPAM
+	out.MultiNetwork.ShadowAllocator = in.MultiNetwork.ShadowAllocator
+	out.MultiNetwork.DefaultNetworkName = in.MultiNetwork.DefaultNetworkName
+	if in.MultiNetwork.MaxAdditionalNetworks != nil {
+		out.MultiNetwork.MaxAdditionalNetworks = *in.MultiNetwork.MaxAdditionalNetworks
+	}
+	out.MultiNetwork.NodeCoordinationLeases = in.MultiNetwork.NodeCoordinationLeases
+	out.MultiNetwork.NodeCleanupHooks = in.MultiNetwork.NodeCleanupHooks
+	out.MultiNetwork.PredictiveAllocation = in.MultiNetwork.PredictiveAllocation
+	if in.MultiNetwork.NetworkRolloutNodesPerMinute != nil {
+		out.MultiNetwork.NetworkRolloutNodesPerMinute = *in.MultiNetwork.NetworkRolloutNodesPerMinute
+	}
+	out.MultiNetwork.ComputeAPIVersion = in.MultiNetwork.ComputeAPIVersion
+	out.MultiNetwork.DisableIPCapacity = in.MultiNetwork.DisableIPCapacity
+	out.MultiNetwork.MaxAliasRangeMaskSize = in.MultiNetwork.MaxAliasRangeMaskSize
+	out.MultiNetwork.AnnotationEncoding = in.MultiNetwork.AnnotationEncoding
+	out.MultiNetwork.LegacyAnnotations = in.MultiNetwork.LegacyAnnotations
+	out.MultiNetwork.RequireReadyParams = in.MultiNetwork.RequireReadyParams
+	out.Backoff.InitialDelay = in.Backoff.InitialDelay
+	out.Backoff.MaxDelay = in.Backoff.MaxDelay
+	if in.Backoff.MaxRetries != nil {
+		out.Backoff.MaxRetries = *in.Backoff.MaxRetries
+	}
+	if in.Backoff.FailureConditionThreshold != nil {
+		out.Backoff.FailureConditionThreshold = *in.Backoff.FailureConditionThreshold
+	}
+	out.ClientConnection = config.ClientConnectionConfiguration(in.ClientConnection)
+	out.StrandedPodCIDRThreshold = in.StrandedPodCIDRThreshold
+	out.PodCIDROwnershipLease = in.PodCIDROwnershipLease
+	out.AuditSink = in.AuditSink
+	out.PodRangeExemptions = nil
+	for _, e := range in.PodRangeExemptions {
+		out.PodRangeExemptions = append(out.PodRangeExemptions, config.PodRangeExemption(e))
+	}
+	out.PodIPReconciliation = in.PodIPReconciliation
+	out.AllocationCheckpoints = in.AllocationCheckpoints
+	out.HealthStatus = in.HealthStatus
+	return nil
+}
+
+// Convert_config_NodeIPAMConfiguration_To_v1alpha1_NodeIPAMConfiguration converts an internal NodeIPAMConfiguration
+// to its versioned representation.
+func Convert_config_NodeIPAMConfiguration_To_v1alpha1_NodeIPAMConfiguration(in *config.NodeIPAMConfiguration, out *NodeIPAMConfiguration, s conversion.Scope) error {
+	out.CIDRAllocatorType = in.CIDRAllocatorType
+	out.ServiceCIDR = in.NodeIPAMController.ServiceCIDR
+	out.SecondaryServiceCIDR = in.NodeIPAMController.SecondaryServiceCIDR
+	out.NodeCIDRMaskSize = in.NodeIPAMController.NodeCIDRMaskSize
+	out.NodeCIDRMaskSizeIPv4 = in.NodeIPAMController.NodeCIDRMaskSizeIPv4
+	out.NodeCIDRMaskSizeIPv6 = in.NodeIPAMController.NodeCIDRMaskSizeIPv6
+	enabled := in.MultiNetwork.Enabled
+	out.MultiNetwork.Enabled = &enabled
+	out.MultiNetwork.ResyncPeriod = in.MultiNetwork.ResyncPeriod
+	out.MultiNetwork.NodeLocalIPAM = in.MultiNetwork.NodeLocalIPAM
+	out.MultiNetwork.ShadowAllocator = in.MultiNetwork.ShadowAllocator
+	out.MultiNetwork.DefaultNetworkName = in.MultiNetwork.DefaultNetworkName
+	maxAdditionalNetworks := in.MultiNetwork.MaxAdditionalNetworks
+	out.MultiNetwork.MaxAdditionalNetworks = &maxAdditionalNetworks
+	out.MultiNetwork.NodeCoordinationLeases = in.MultiNetwork.NodeCoordinationLeases
+	out.MultiNetwork.NodeCleanupHooks = in.MultiNetwork.NodeCleanupHooks
+	out.MultiNetwork.PredictiveAllocation = in.MultiNetwork.PredictiveAllocation
+	networkRolloutNodesPerMinute := in.MultiNetwork.NetworkRolloutNodesPerMinute
+	out.MultiNetwork.NetworkRolloutNodesPerMinute = &networkRolloutNodesPerMinute
+	out.MultiNetwork.ComputeAPIVersion = in.MultiNetwork.ComputeAPIVersion
+	out.MultiNetwork.DisableIPCapacity = in.MultiNetwork.DisableIPCapacity
+	out.MultiNetwork.MaxAliasRangeMaskSize = in.MultiNetwork.MaxAliasRangeMaskSize
+	out.MultiNetwork.AnnotationEncoding = in.MultiNetwork.AnnotationEncoding
+	out.MultiNetwork.LegacyAnnotations = in.MultiNetwork.LegacyAnnotations
+	out.MultiNetwork.RequireReadyParams = in.MultiNetwork.RequireReadyParams
+	out.Backoff.InitialDelay = in.Backoff.InitialDelay
+	out.Backoff.MaxDelay = in.Backoff.MaxDelay
+	maxRetries := in.Backoff.MaxRetries
+	out.Backoff.MaxRetries = &maxRetries
+	failureConditionThreshold := in.Backoff.FailureConditionThreshold
+	out.Backoff.FailureConditionThreshold = &failureConditionThreshold
+	out.ClientConnection = ClientConnectionConfiguration(in.ClientConnection)
+	out.StrandedPodCIDRThreshold = in.StrandedPodCIDRThreshold
+	out.PodCIDROwnershipLease = in.PodCIDROwnershipLease
+	out.AuditSink = in.AuditSink
+	out.PodRangeExemptions = nil
+	for _, e := range in.PodRangeExemptions {
+		out.PodRangeExemptions = append(out.PodRangeExemptions, PodRangeExemption(e))
+	}
+	out.PodIPReconciliation = in.PodIPReconciliation
+	out.AllocationCheckpoints = in.AllocationCheckpoints
+	out.HealthStatus = in.HealthStatus
+	return nil
+}
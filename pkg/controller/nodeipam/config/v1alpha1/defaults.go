@@ -17,9 +17,144 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	kubectrlmgrconfigv1alpha1 "k8s.io/kube-controller-manager/config/v1alpha1"
+	"k8s.io/utils/pointer"
+)
+
+// The defaults of the node IPAM configuration, which the allocators and the
+// deployment tooling share.
+const (
+	// DefaultMultiNetworkResyncPeriod is the default resync period of the
+	// multi-network informers.
+	DefaultMultiNetworkResyncPeriod = 30 * time.Second
+	// DefaultMaxAdditionalNetworks is the default max no. of additional
+	// networks published on a node. GCE instances have at most 8 network
+	// interfaces, one of which is used by the default network.
+	DefaultMaxAdditionalNetworks = 7
+	// DefaultNetworkRolloutNodesPerMinute is the default rate at which the
+	// nodes are reconciled after a Network is created or changed. The rollouts
+	// are disabled by default, all the nodes being reconciled at once.
+	DefaultNetworkRolloutNodesPerMinute = 0
+	// DefaultComputeAPIVersion is the default version of the compute API.
+	DefaultComputeAPIVersion = "v1"
+	// DefaultAnnotationEncoding is the default encoding of the multi-network
+	// annotations of the nodes.
+	DefaultAnnotationEncoding = "per-key"
+
+	// DefaultBackoffInitialDelay is the default time to wait before retrying
+	// a failed node update.
+	DefaultBackoffInitialDelay = 250 * time.Millisecond
+	// DefaultBackoffMaxDelay is the default maximum time between the retries
+	// of a failed node update.
+	DefaultBackoffMaxDelay = 5 * time.Second
+	// DefaultBackoffMaxRetries is the default max retries of a failed node
+	// update.
+	DefaultBackoffMaxRetries = 10
+	// DefaultFailureConditionThreshold is the default no. of consecutive
+	// failed updates after which the CIDRAllocationFailed condition is set on
+	// the node.
+	DefaultFailureConditionThreshold = 5
+
+	// DefaultInformerQPS and DefaultInformerBurst are the default rate limits
+	// of the clients of the informers.
+	DefaultInformerQPS   = 5
+	DefaultInformerBurst = 10
+	// DefaultWriteQPS and DefaultWriteBurst are the default rate limits of the
+	// client updating the nodes.
+	DefaultWriteQPS   = 20
+	DefaultWriteBurst = 30
+	// DefaultEventQPS and DefaultEventBurst are the default rate limits of
+	// the events of each reason recorded by the cloud allocator.
+	DefaultEventQPS   = 1
+	DefaultEventBurst = 100
+
+	// DefaultNodeCIDRMaskSizeIPv4 and DefaultNodeCIDRMaskSizeIPv6 are the
+	// mask sizes of the node CIDRs used when none is configured. They are not
+	// set by SetDefaults_NodeIPAMConfiguration as they depend on the cluster
+	// CIDR family.
+	DefaultNodeCIDRMaskSizeIPv4 = 24
+	DefaultNodeCIDRMaskSizeIPv6 = 64
 )
 
+// NewDefaultNodeIPAMConfiguration returns a NodeIPAMConfiguration with all
+// the defaults set, e.g. for deployment tooling rendering the configuration
+// file rather than duplicating the default values.
+func NewDefaultNodeIPAMConfiguration() *NodeIPAMConfiguration {
+	obj := &NodeIPAMConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersion.String(),
+			Kind:       "NodeIPAMConfiguration",
+		},
+	}
+	SetDefaults_NodeIPAMConfiguration(obj)
+	return obj
+}
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&NodeIPAMConfiguration{}, func(obj interface{}) {
+		SetDefaults_NodeIPAMConfiguration(obj.(*NodeIPAMConfiguration))
+	})
+	return nil
+}
+
+// SetDefaults_NodeIPAMConfiguration sets the defaults of a NodeIPAMConfiguration
+// loaded from a configuration file. The node mask sizes are left unset as they
+// depend on the cluster CIDR family.
+func SetDefaults_NodeIPAMConfiguration(obj *NodeIPAMConfiguration) {
+	if obj.MultiNetwork.Enabled == nil {
+		obj.MultiNetwork.Enabled = pointer.Bool(true)
+	}
+	if obj.MultiNetwork.ResyncPeriod.Duration == 0 {
+		obj.MultiNetwork.ResyncPeriod = metav1.Duration{Duration: DefaultMultiNetworkResyncPeriod}
+	}
+	if obj.MultiNetwork.MaxAdditionalNetworks == nil {
+		obj.MultiNetwork.MaxAdditionalNetworks = pointer.Int32(DefaultMaxAdditionalNetworks)
+	}
+	if obj.MultiNetwork.NetworkRolloutNodesPerMinute == nil {
+		obj.MultiNetwork.NetworkRolloutNodesPerMinute = pointer.Int32(DefaultNetworkRolloutNodesPerMinute)
+	}
+	if obj.MultiNetwork.ComputeAPIVersion == "" {
+		obj.MultiNetwork.ComputeAPIVersion = DefaultComputeAPIVersion
+	}
+	if obj.MultiNetwork.AnnotationEncoding == "" {
+		obj.MultiNetwork.AnnotationEncoding = DefaultAnnotationEncoding
+	}
+	if obj.Backoff.InitialDelay.Duration == 0 {
+		obj.Backoff.InitialDelay = metav1.Duration{Duration: DefaultBackoffInitialDelay}
+	}
+	if obj.Backoff.MaxDelay.Duration == 0 {
+		obj.Backoff.MaxDelay = metav1.Duration{Duration: DefaultBackoffMaxDelay}
+	}
+	if obj.Backoff.MaxRetries == nil {
+		obj.Backoff.MaxRetries = pointer.Int32(DefaultBackoffMaxRetries)
+	}
+	if obj.Backoff.FailureConditionThreshold == nil {
+		obj.Backoff.FailureConditionThreshold = pointer.Int32(DefaultFailureConditionThreshold)
+	}
+	if obj.ClientConnection.InformerQPS == 0 {
+		obj.ClientConnection.InformerQPS = DefaultInformerQPS
+	}
+	if obj.ClientConnection.InformerBurst == 0 {
+		obj.ClientConnection.InformerBurst = DefaultInformerBurst
+	}
+	if obj.ClientConnection.WriteQPS == 0 {
+		obj.ClientConnection.WriteQPS = DefaultWriteQPS
+	}
+	if obj.ClientConnection.WriteBurst == 0 {
+		obj.ClientConnection.WriteBurst = DefaultWriteBurst
+	}
+	if obj.ClientConnection.EventQPS == 0 {
+		obj.ClientConnection.EventQPS = DefaultEventQPS
+	}
+	if obj.ClientConnection.EventBurst == 0 {
+		obj.ClientConnection.EventBurst = DefaultEventBurst
+	}
+}
+
 // RecommendedDefaultNodeIPAMControllerConfiguration defaults a pointer to a
 // NodeIPAMControllerConfiguration struct. This will set the recommended default
 // values, but they may be subject to change between API versions. This function
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestSetDefaultsNodeIPAMConfiguration(t *testing.T) {
+	testCases := []struct {
+		desc string
+		in   *NodeIPAMConfiguration
+		want *NodeIPAMConfiguration
+	}{
+		{
+			desc: "empty config",
+			in:   &NodeIPAMConfiguration{},
+			want: &NodeIPAMConfiguration{
+				MultiNetwork: MultiNetworkConfiguration{
+					Enabled:                      pointer.Bool(true),
+					ResyncPeriod:                 metav1.Duration{Duration: 30 * time.Second},
+					MaxAdditionalNetworks:        pointer.Int32(7),
+					NetworkRolloutNodesPerMinute: pointer.Int32(0),
+					ComputeAPIVersion:            "v1",
+					AnnotationEncoding:           "per-key",
+				},
+				Backoff: BackoffConfiguration{
+					InitialDelay:              metav1.Duration{Duration: 250 * time.Millisecond},
+					MaxDelay:                  metav1.Duration{Duration: 5 * time.Second},
+					MaxRetries:                pointer.Int32(10),
+					FailureConditionThreshold: pointer.Int32(5),
+				},
+				ClientConnection: ClientConnectionConfiguration{
+					InformerQPS:   5,
+					InformerBurst: 10,
+					WriteQPS:      20,
+					WriteBurst:    30,
+					EventQPS:      1,
+					EventBurst:    100,
+				},
+			},
+		},
+		{
+			desc: "explicit values are kept",
+			in: &NodeIPAMConfiguration{
+				CIDRAllocatorType: "RangeAllocator",
+				NodeCIDRMaskSize:  26,
+				MultiNetwork: MultiNetworkConfiguration{
+					Enabled:                      pointer.Bool(false),
+					ResyncPeriod:                 metav1.Duration{Duration: time.Minute},
+					MaxAdditionalNetworks:        pointer.Int32(0),
+					NetworkRolloutNodesPerMinute: pointer.Int32(120),
+					ComputeAPIVersion:            "beta",
+					AnnotationEncoding:           "compact-gzip",
+				},
+				Backoff: BackoffConfiguration{
+					InitialDelay:              metav1.Duration{Duration: time.Second},
+					MaxDelay:                  metav1.Duration{Duration: 10 * time.Second},
+					MaxRetries:                pointer.Int32(0),
+					FailureConditionThreshold: pointer.Int32(0),
+				},
+				ClientConnection: ClientConnectionConfiguration{
+					InformerQPS:   1,
+					InformerBurst: 2,
+					WriteQPS:      50,
+					WriteBurst:    100,
+					EventQPS:      5,
+					EventBurst:    10,
+				},
+			},
+			want: &NodeIPAMConfiguration{
+				CIDRAllocatorType: "RangeAllocator",
+				NodeCIDRMaskSize:  26,
+				MultiNetwork: MultiNetworkConfiguration{
+					Enabled:                      pointer.Bool(false),
+					ResyncPeriod:                 metav1.Duration{Duration: time.Minute},
+					MaxAdditionalNetworks:        pointer.Int32(0),
+					NetworkRolloutNodesPerMinute: pointer.Int32(120),
+					ComputeAPIVersion:            "beta",
+					AnnotationEncoding:           "compact-gzip",
+				},
+				Backoff: BackoffConfiguration{
+					InitialDelay:              metav1.Duration{Duration: time.Second},
+					MaxDelay:                  metav1.Duration{Duration: 10 * time.Second},
+					MaxRetries:                pointer.Int32(0),
+					FailureConditionThreshold: pointer.Int32(0),
+				},
+				ClientConnection: ClientConnectionConfiguration{
+					InformerQPS:   1,
+					InformerBurst: 2,
+					WriteQPS:      50,
+					WriteBurst:    100,
+					EventQPS:      5,
+					EventBurst:    10,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			SetDefaults_NodeIPAMConfiguration(tc.in)
+			if diff := cmp.Diff(tc.want, tc.in); diff != "" {
+				t.Errorf("SetDefaults_NodeIPAMConfiguration() returned unexpected config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewDefaultNodeIPAMConfiguration(t *testing.T) {
+	want := &NodeIPAMConfiguration{}
+	SetDefaults_NodeIPAMConfiguration(want)
+	want.APIVersion = "nodeipam.config.gke.io/v1alpha1"
+	want.Kind = "NodeIPAMConfiguration"
+	if diff := cmp.Diff(want, NewDefaultNodeIPAMConfiguration()); diff != "" {
+		t.Errorf("NewDefaultNodeIPAMConfiguration() returned unexpected config (-want +got):\n%s", diff)
+	}
+}
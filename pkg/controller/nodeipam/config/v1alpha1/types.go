@@ -0,0 +1,214 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeIPAMConfiguration is the file based configuration of the node IPAM controller.
+type NodeIPAMConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// cidrAllocatorType is the type of CIDR allocator to use. When empty the
+	// value of --cidr-allocator-type is used.
+	CIDRAllocatorType string `json:"cidrAllocatorType,omitempty"`
+	// serviceCIDR is CIDR Range for Services in cluster.
+	ServiceCIDR string `json:"serviceCIDR,omitempty"`
+	// secondaryServiceCIDR is CIDR Range for Services in cluster. This is used in dual stack clusters.
+	// SecondaryServiceCIDR must be of different IP family than ServiceCIDR.
+	SecondaryServiceCIDR string `json:"secondaryServiceCIDR,omitempty"`
+	// nodeCIDRMaskSize is the mask size for node cidr in single-stack cluster.
+	NodeCIDRMaskSize int32 `json:"nodeCIDRMaskSize,omitempty"`
+	// nodeCIDRMaskSizeIPv4 is the mask size for IPv4 node cidr in dual-stack cluster.
+	NodeCIDRMaskSizeIPv4 int32 `json:"nodeCIDRMaskSizeIPv4,omitempty"`
+	// nodeCIDRMaskSizeIPv6 is the mask size for IPv6 node cidr in dual-stack cluster.
+	NodeCIDRMaskSizeIPv6 int32 `json:"nodeCIDRMaskSizeIPv6,omitempty"`
+	// multiNetwork holds the settings for allocating pod CIDRs of additional networks.
+	MultiNetwork MultiNetworkConfiguration `json:"multiNetwork"`
+	// backoff holds the retry settings for failed node CIDR updates.
+	Backoff BackoffConfiguration `json:"backoff"`
+	// clientConnection holds the rate limits of the API clients of the
+	// controller.
+	ClientConnection ClientConnectionConfiguration `json:"clientConnection"`
+	// strandedPodCIDRThreshold is the time after which a node without pods
+	// using its pod CIDR is reported as stranded, so that autoscaling and
+	// capacity reclamation tooling can consider it for scale-down. Zero
+	// disables the tracking, the default.
+	StrandedPodCIDRThreshold metav1.Duration `json:"strandedPodCIDRThreshold,omitempty"`
+	// podCIDROwnershipLease makes the allocator claim a Lease asserting it
+	// owns the pod CIDRs of the cluster before it starts, stop allocating
+	// while another allocator owns them, and resume once it owns them again.
+	// Only the cloud allocator supports it. The kube-controller-manager does
+	// not claim the Lease itself, see PodCIDROwnerKubeControllerManager.
+	// Disabled by default.
+	PodCIDROwnershipLease bool `json:"podCIDROwnershipLease,omitempty"`
+	// auditSink is where the changes of the pod CIDRs, annotations and IP
+	// capacity of the nodes are recorded with their reason: an http:// or
+	// https:// URL the records are POSTed to, or the path of a file they are
+	// appended to as JSON lines. Empty, the default, disables the audit.
+	AuditSink string `json:"auditSink,omitempty"`
+	// podRangeExemptions selects the nodes, e.g. the control-plane ones,
+	// whose instance has no pod alias IP range on purpose. They are skipped
+	// with a warning instead of being retried and reported as failed. Empty
+	// by default.
+	PodRangeExemptions []PodRangeExemption `json:"podRangeExemptions,omitempty"`
+	// podIPReconciliation enables the periodic check of the IPs of the pods
+	// against the pod CIDRs published on their node. The pods whose IPs fall
+	// outside of them, the sign of a stale allocation or of a drift of the
+	// dataplane, are counted in a metric and reported with events. Disabled
+	// by default.
+	PodIPReconciliation bool `json:"podIPReconciliation,omitempty"`
+	// allocationCheckpoints persists the pod CIDRs published on the nodes in
+	// NodeIPAMCheckpoint objects. After a restart, the nodes still matching
+	// them are not read from the compute API until they are refreshed in the
+	// background, which shortens the cold start of large clusters. Disabled
+	// by default.
+	AllocationCheckpoints bool `json:"allocationCheckpoints,omitempty"`
+	// healthStatus publishes the health of the cloud allocator in the
+	// cluster scoped NodeIPAMHealth object: the last time every node was up
+	// to date, the error rate of the node updates and whether the updates of
+	// nodes are deferred to shed load. Disabled by default.
+	HealthStatus bool `json:"healthStatus,omitempty"`
+}
+
+// PodRangeExemption selects the nodes matching both its label selector and
+// taint key, at least one of which is set.
+type PodRangeExemption struct {
+	// labelSelector is a label selector of the nodes, e.g.
+	// node-role.kubernetes.io/control-plane.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// taintKey is the key of a taint of the nodes, e.g.
+	// node-role.kubernetes.io/control-plane.
+	TaintKey string `json:"taintKey,omitempty"`
+}
+
+// MultiNetworkConfiguration contains elements describing multi-network pod CIDR allocation.
+type MultiNetworkConfiguration struct {
+	// enabled allows the cloud allocator to allocate pod CIDRs for additional
+	// networks. When disabled only the default network is considered.
+	// Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+	// resyncPeriod is the resync period of the Network and GKENetworkParamSet informers.
+	// Defaults to 30s.
+	ResyncPeriod metav1.Duration `json:"resyncPeriod,omitempty"`
+	// nodeLocalIPAM delegates the alias IP range attach of additional networks
+	// to a node agent. The controller only validates and publishes the secondary
+	// range names on the node instead of the concrete pod CIDRs.
+	NodeLocalIPAM bool `json:"nodeLocalIPAM,omitempty"`
+	// shadowAllocator is the name of an alternative multi-network allocation
+	// algorithm run in shadow mode. Its results are compared with the active
+	// algorithm and exported as metrics, but never written to the nodes.
+	ShadowAllocator string `json:"shadowAllocator,omitempty"`
+	// defaultNetworkName is the name of the Network the pod CIDRs of the nodes
+	// are allocated from, for deployments not using the built-in default
+	// network names. Defaults to the built-in names.
+	DefaultNetworkName string `json:"defaultNetworkName,omitempty"`
+	// maxAdditionalNetworks is the maximum number of additional networks
+	// published on a node. The networks beyond it are ignored and reported
+	// with an event. Zero disables the limit. Defaults to 7, the number of
+	// network interfaces of a GCE instance besides the default one.
+	MaxAdditionalNetworks *int32 `json:"maxAdditionalNetworks,omitempty"`
+	// nodeCoordinationLeases enables a coordination Lease per node, updated
+	// after the multi-network state of the node is published, which node
+	// agents acknowledge once the node side setup is done.
+	NodeCoordinationLeases bool `json:"nodeCoordinationLeases,omitempty"`
+	// nodeCleanupHooks enables calling the cleanup hook of each additional
+	// network of a deleted node, so that external systems can release the
+	// resources of the node. It requires nodeCoordinationLeases.
+	NodeCleanupHooks bool `json:"nodeCleanupHooks,omitempty"`
+	// predictiveAllocation publishes the additional networks a new node is
+	// expected to join, predicted from the instance template of its managed
+	// instance group, while its instance is not visible yet.
+	PredictiveAllocation bool `json:"predictiveAllocation,omitempty"`
+	// networkRolloutNodesPerMinute is the rate at which the nodes are
+	// reconciled after a Network is created or changed, so that the updates
+	// of large clusters are spread over time. Zero reconciles all the nodes
+	// at once. Defaults to 0.
+	NetworkRolloutNodesPerMinute *int32 `json:"networkRolloutNodesPerMinute,omitempty"`
+	// computeAPIVersion is the version of the compute API the instances of
+	// the nodes are read with, one of v1, beta or alpha. The beta and alpha
+	// APIs return network interface fields, e.g. of IPv6 or per-NIC features,
+	// before the GA API. Defaults to v1.
+	ComputeAPIVersion string `json:"computeAPIVersion,omitempty"`
+	// disableIPCapacity stops publishing the IP capacity of the additional
+	// networks in the node status, for clusters scheduling the pods of the
+	// additional networks with device plugins. The multi-network annotations
+	// are still published.
+	DisableIPCapacity bool `json:"disableIPCapacity,omitempty"`
+	// maxAliasRangeMaskSize is the longest prefix length of the alias IP
+	// ranges of additional networks published on the nodes, e.g. 29. Smaller
+	// ranges are skipped with an event, as their few pod IPs would break the
+	// workloads of the network later. Zero accepts ranges of any size.
+	MaxAliasRangeMaskSize int32 `json:"maxAliasRangeMaskSize,omitempty"`
+	// annotationEncoding is the encoding of the multi-network annotations of
+	// the nodes, one of per-key, compact or compact-gzip. See
+	// wellknown.CompactAnnotationKey for the compact encodings. Defaults to
+	// per-key.
+	AnnotationEncoding string `json:"annotationEncoding,omitempty"`
+	// legacyAnnotations additionally publishes the north interfaces and the
+	// networks of the nodes under the annotation keys read by older
+	// dataplane agents. Not supported with node-local IPAM. Disabled by
+	// default.
+	LegacyAnnotations bool `json:"legacyAnnotations,omitempty"`
+	// requireReadyParams skips the additional networks whose
+	// GKENetworkParamSet does not have a true Ready condition, so that the
+	// nodes are not allocated networks whose infrastructure is still being
+	// provisioned. Disabled by default.
+	RequireReadyParams bool `json:"requireReadyParams,omitempty"`
+}
+
+// BackoffConfiguration contains elements describing the retries of failed node CIDR updates.
+type BackoffConfiguration struct {
+	// initialDelay is the time to wait before requeuing a node for the first retry.
+	// Defaults to 250ms.
+	InitialDelay metav1.Duration `json:"initialDelay,omitempty"`
+	// maxDelay is the maximum time to wait between retries. Defaults to 5s.
+	MaxDelay metav1.Duration `json:"maxDelay,omitempty"`
+	// maxRetries is the number of retries before a node is dropped from the queue.
+	// Defaults to 10.
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// failureConditionThreshold is the number of consecutive failed updates
+	// after which the CIDRAllocationFailed condition is set on the node. Zero
+	// disables the condition. Defaults to 5.
+	FailureConditionThreshold *int32 `json:"failureConditionThreshold,omitempty"`
+}
+
+// ClientConnectionConfiguration contains the rate limits of the API clients
+// of the node IPAM controller. Writes use their own client so that they are
+// not throttled behind the list and watch requests of the informers.
+type ClientConnectionConfiguration struct {
+	// informerQPS is the QPS of the client listing and watching Networks and
+	// GKENetworkParamSets. Defaults to 5.
+	InformerQPS float32 `json:"informerQPS,omitempty"`
+	// informerBurst is the burst of the informer client. Defaults to 10.
+	InformerBurst int32 `json:"informerBurst,omitempty"`
+	// writeQPS is the QPS of the client updating nodes, leases, events and
+	// Networks. Defaults to 20.
+	WriteQPS float32 `json:"writeQPS,omitempty"`
+	// writeBurst is the burst of the write client. Defaults to 30.
+	WriteBurst int32 `json:"writeBurst,omitempty"`
+	// eventQPS is the QPS of the events of each reason recorded by the cloud
+	// allocator. The events beyond it are dropped, so that an outage failing
+	// the updates of every node does not flood the API server with events.
+	// Defaults to 1.
+	EventQPS float32 `json:"eventQPS,omitempty"`
+	// eventBurst is the burst of the events of each reason. Defaults to 100.
+	EventBurst int32 `json:"eventBurst,omitempty"`
+}
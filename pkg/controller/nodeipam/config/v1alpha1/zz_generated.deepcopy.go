@@ -20,3 +20,132 @@ limitations under the License.
 // Code generated by deepcopy-gen. DO NOT EDIT.
 
 package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackoffConfiguration) DeepCopyInto(out *BackoffConfiguration) {
+	*out = *in
+	out.InitialDelay = in.InitialDelay
+	out.MaxDelay = in.MaxDelay
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureConditionThreshold != nil {
+		in, out := &in.FailureConditionThreshold, &out.FailureConditionThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackoffConfiguration.
+func (in *BackoffConfiguration) DeepCopy() *BackoffConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(BackoffConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientConnectionConfiguration) DeepCopyInto(out *ClientConnectionConfiguration) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientConnectionConfiguration.
+func (in *ClientConnectionConfiguration) DeepCopy() *ClientConnectionConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientConnectionConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiNetworkConfiguration) DeepCopyInto(out *MultiNetworkConfiguration) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	out.ResyncPeriod = in.ResyncPeriod
+	if in.MaxAdditionalNetworks != nil {
+		in, out := &in.MaxAdditionalNetworks, &out.MaxAdditionalNetworks
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetworkRolloutNodesPerMinute != nil {
+		in, out := &in.NetworkRolloutNodesPerMinute, &out.NetworkRolloutNodesPerMinute
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiNetworkConfiguration.
+func (in *MultiNetworkConfiguration) DeepCopy() *MultiNetworkConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiNetworkConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeIPAMConfiguration) DeepCopyInto(out *NodeIPAMConfiguration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.MultiNetwork.DeepCopyInto(&out.MultiNetwork)
+	in.Backoff.DeepCopyInto(&out.Backoff)
+	out.ClientConnection = in.ClientConnection
+	if in.PodRangeExemptions != nil {
+		in, out := &in.PodRangeExemptions, &out.PodRangeExemptions
+		*out = make([]PodRangeExemption, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeIPAMConfiguration.
+func (in *NodeIPAMConfiguration) DeepCopy() *NodeIPAMConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeIPAMConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeIPAMConfiguration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRangeExemption) DeepCopyInto(out *PodRangeExemption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRangeExemption.
+func (in *PodRangeExemption) DeepCopy() *PodRangeExemption {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRangeExemption)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,134 @@
+package ipam
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/allocator"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/checkpoint"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// checkpointFlushInterval is the interval at which the changed allocation
+	// checkpoints are written.
+	checkpointFlushInterval = 30 * time.Second
+	// checkpointRefreshInterval is the interval at which checkpointRefreshBatch
+	// of the nodes served from the allocation checkpoints are refreshed from
+	// the compute API, so that a cold start does not burst the compute API.
+	checkpointRefreshInterval = time.Second
+	checkpointRefreshBatch    = 10
+)
+
+// loadCheckpoints reads the allocation checkpoints of the nodes. Until they
+// are refreshed, the nodes whose providerID and pod CIDRs still match their
+// checkpoint are not read from the compute API, see servedFromCheckpoint.
+// Without the checkpoints, all the nodes are read from the compute API.
+func (ca *cloudCIDRAllocator) loadCheckpoints() {
+	entries, err := ca.checkpoints.Load(context.TODO())
+	if err != nil {
+		klog.ErrorS(err, "Failed to load the allocation checkpoints, reading all the nodes from the compute API")
+		return
+	}
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.checkpointed = make(map[string]checkpoint.Entry)
+	for name, entry := range entries {
+		// The nodes deleted while the allocator was down are not released.
+		if _, err := ca.nodeLister.Get(name); errors.IsNotFound(err) {
+			ca.checkpoints.Forget(name)
+			continue
+		}
+		ca.checkpointed[name] = entry
+	}
+	checkpointedNodes.Set(float64(len(ca.checkpointed)))
+	klog.InfoS("Loaded the allocation checkpoints", "nodes", len(ca.checkpointed))
+}
+
+// servedFromCheckpoint returns true if the node still matches its allocation
+// checkpoint and its network is available, in which case it is left as is
+// until it is refreshed.
+func (ca *cloudCIDRAllocator) servedFromCheckpoint(node *v1.Node) bool {
+	ca.lock.Lock()
+	entry, ok := ca.checkpointed[node.Name]
+	ca.lock.Unlock()
+	if !ok {
+		return false
+	}
+	if entry.ProviderID != node.Spec.ProviderID || !reflect.DeepEqual(entry.PodCIDRs, node.Spec.PodCIDRs) || allocator.NetworkUnavailable(node) {
+		klog.V(2).InfoS("Node does not match its allocation checkpoint", "node", klog.KObj(node))
+		ca.forgetCheckpointedNode(node.Name)
+		return false
+	}
+	klog.V(4).InfoS("Serving node from its allocation checkpoint", "node", klog.KObj(node))
+	return true
+}
+
+// recordCheckpoint records the pod CIDRs published on the node in its
+// allocation checkpoint.
+func (ca *cloudCIDRAllocator) recordCheckpoint(node *v1.Node, podCIDRs []string) {
+	if ca.checkpoints == nil {
+		return
+	}
+	ca.checkpoints.Record(checkpoint.Entry{Node: node.Name, ProviderID: node.Spec.ProviderID, PodCIDRs: podCIDRs})
+}
+
+// forgetCheckpoint removes a deleted node from the allocation checkpoints.
+func (ca *cloudCIDRAllocator) forgetCheckpoint(nodeName string) {
+	if ca.checkpoints == nil {
+		return
+	}
+	ca.forgetCheckpointedNode(nodeName)
+	ca.checkpoints.Forget(nodeName)
+}
+
+// forgetCheckpointedNode stops serving the node from its allocation
+// checkpoint. It returns true if it was served from it.
+func (ca *cloudCIDRAllocator) forgetCheckpointedNode(nodeName string) bool {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if _, ok := ca.checkpointed[nodeName]; !ok {
+		return false
+	}
+	delete(ca.checkpointed, nodeName)
+	checkpointedNodes.Set(float64(len(ca.checkpointed)))
+	return true
+}
+
+// refreshCheckpointedNode requeues the node served from its allocation
+// checkpoint, if it is, so that it is read from the compute API.
+func (ca *cloudCIDRAllocator) refreshCheckpointedNode(nodeName string) {
+	if !ca.forgetCheckpointedNode(nodeName) {
+		return
+	}
+	ca.queueNode(nodeName, 0)
+}
+
+// refreshCheckpointedNodes refreshes the next checkpointRefreshBatch of the
+// nodes served from the allocation checkpoints.
+func (ca *cloudCIDRAllocator) refreshCheckpointedNodes() {
+	ca.lock.Lock()
+	names := make([]string, 0, len(ca.checkpointed))
+	for name := range ca.checkpointed {
+		names = append(names, name)
+	}
+	ca.lock.Unlock()
+	sort.Strings(names)
+	if len(names) > checkpointRefreshBatch {
+		names = names[:checkpointRefreshBatch]
+	}
+	for _, name := range names {
+		ca.refreshCheckpointedNode(name)
+	}
+}
+
+// flushCheckpoints writes the changed allocation checkpoints.
+func (ca *cloudCIDRAllocator) flushCheckpoints() {
+	if err := ca.checkpoints.Flush(context.TODO()); err != nil {
+		klog.ErrorS(err, "Failed to write the allocation checkpoints")
+	}
+}
@@ -0,0 +1,101 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/checkpoint"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestAllocationCheckpoints(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	store := checkpoint.NewStore(dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		checkpoint.Resource: "NodeIPAMCheckpointList",
+	}))
+	// The checkpoints written before the restart of the allocator.
+	previous := checkpoint.New(store)
+	previous.Record(checkpoint.Entry{Node: "node0", ProviderID: "gce://test-project/us-central1-b/node0", PodCIDRs: []string{"10.0.0.0/24"}})
+	previous.Record(checkpoint.Entry{Node: "node1", ProviderID: "gce://test-project/us-central1-b/node1", PodCIDRs: []string{"10.9.0.0/24"}})
+	previous.Record(checkpoint.Entry{Node: "deleted", ProviderID: "gce://test-project/us-central1-b/deleted", PodCIDRs: []string{"10.2.0.0/24"}})
+	if err := previous.Flush(context.TODO()); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+
+	node := func(name, podCIDR string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/" + name, PodCIDR: podCIDR, PodCIDRs: []string{podCIDR}},
+			Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeNetworkUnavailable, Status: v1.ConditionFalse}}},
+		}
+	}
+	node0, node1 := node("node0", "10.0.0.0/24"), node("node1", "10.1.0.0/24")
+	clientSet := fake.NewSimpleClientset(node0, node1)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	for _, n := range []*v1.Node{node0, node1} {
+		if err := sharedInformer.Core().V1().Nodes().Informer().GetStore().Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errCompute := errors.New("compute API unavailable")
+	params := DefaultCloudAllocatorParams()
+	params.EnableMultiNetworking = false
+	ca := withQueue(&cloudCIDRAllocator{
+		client:      clientSet,
+		nodeLister:  sharedInformer.Core().V1().Nodes().Lister(),
+		recorder:    record.NewFakeRecorder(10),
+		instances:   &fakeInstances{err: errCompute},
+		checkpoints: checkpoint.New(store),
+		params:      params,
+	})
+
+	ca.loadCheckpoints()
+	if got, _ := testutil.GetGaugeMetricValue(checkpointedNodes); got != 2 {
+		t.Errorf("checkpointed nodes = %v, want 2", got)
+	}
+	if err := ca.updateCIDRAllocation(node0.Name); err != nil {
+		t.Errorf("updateCIDRAllocation() returned err %v for a node matching its checkpoint", err)
+	}
+	if err := ca.updateCIDRAllocation(node1.Name); err == nil {
+		t.Errorf("updateCIDRAllocation() returned nil err for a node not matching its checkpoint, want the compute error")
+	}
+	if got, _ := testutil.GetGaugeMetricValue(checkpointedNodes); got != 1 {
+		t.Errorf("checkpointed nodes after the updates = %v, want 1", got)
+	}
+
+	ca.refreshCheckpointedNodes()
+	if got := <-ca.queue.Updates(); got != node0.Name {
+		t.Errorf("refreshed node %q, want %q", got, node0.Name)
+	}
+	if got, _ := testutil.GetGaugeMetricValue(checkpointedNodes); got != 0 {
+		t.Errorf("checkpointed nodes after the refresh = %v, want 0", got)
+	}
+	if err := ca.updateCIDRAllocation(node0.Name); err == nil {
+		t.Errorf("updateCIDRAllocation() returned nil err for a refreshed node, want the compute error")
+	}
+
+	ca.ReleaseCIDR(node0)
+	ca.flushCheckpoints()
+	entries, err := checkpoint.New(store).Load(context.TODO())
+	if err != nil {
+		t.Fatalf("Load() returned err %v", err)
+	}
+	for _, name := range []string{"node0", "deleted"} {
+		if _, ok := entries[name]; ok {
+			t.Errorf("checkpoint of deleted node %q was not removed", name)
+		}
+	}
+	if _, ok := entries["node1"]; !ok {
+		t.Errorf("checkpoint of node1 was removed, want it kept until node1 is updated")
+	}
+}
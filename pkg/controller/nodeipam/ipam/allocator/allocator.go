@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package allocator holds the parts of the node IPAM CIDR allocators that do
+// not depend on where the pod CIDRs come from: the CIDRAllocator interface
+// the controller runs, the work queue retrying failed nodes with a backoff,
+// and the helpers publishing the pod CIDRs on the nodes.
+//
+// Platforms running the node IPAM controller with their own source of pod
+// CIDRs implement Source and build their allocator with New:
+//
+//	type source struct{ ... }
+//
+//	func (s *source) Allocate(ctx context.Context, node *v1.Node) ([]string, error) { ... }
+//	func (s *source) Release(ctx context.Context, node *v1.Node) error { ... }
+//
+//	alloc := allocator.New(client, nodeInformer, &source{}, allocator.DefaultOptions())
+//	go alloc.Run(stopCh)
+//
+// The GCE allocators of the ipam package are built from the same parts.
+package allocator
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	informers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	nodeipamconfigv1alpha1 "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/v1alpha1"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+// CIDRAllocator is an interface implemented by things that know how
+// to allocate/occupy/recycle CIDR for nodes.
+type CIDRAllocator interface {
+	// AllocateOrOccupyCIDR looks at the given node, assigns it a valid
+	// CIDR if it doesn't currently have one or mark the CIDR as used if
+	// the node already have one.
+	AllocateOrOccupyCIDR(node *v1.Node) error
+	// ReleaseCIDR releases the CIDR of the removed node
+	ReleaseCIDR(node *v1.Node) error
+	// Run starts all the working logic of the allocator.
+	Run(stopCh <-chan struct{})
+}
+
+// Source allocates the pod CIDRs of the nodes for the allocators built by New.
+type Source interface {
+	// Allocate returns the pod CIDRs of the node, the primary one first,
+	// allocating them if needed. It is called again for the same node on
+	// retries and updates, and must return the same CIDRs.
+	Allocate(ctx context.Context, node *v1.Node) ([]string, error)
+	// Release releases the pod CIDRs of the deleted node.
+	Release(ctx context.Context, node *v1.Node) error
+}
+
+// defaultWorkers is the no. of nodes updated concurrently by default.
+const defaultWorkers = 30
+
+// Options are the parameters of the allocators built by New.
+type Options struct {
+	// Workers is the number of nodes updated concurrently.
+	Workers int
+	// RetryTimeout is the time to wait before requeuing a failed node for
+	// the first retry.
+	RetryTimeout time.Duration
+	// MaxRetryTimeout is the maximum amount of time between retries.
+	MaxRetryTimeout time.Duration
+	// MaxRetries is the max retries for a failed node.
+	MaxRetries int
+}
+
+// DefaultOptions returns the default backoff of the node IPAM controller.
+func DefaultOptions() Options {
+	return Options{
+		Workers:         defaultWorkers,
+		RetryTimeout:    nodeipamconfigv1alpha1.DefaultBackoffInitialDelay,
+		MaxRetryTimeout: nodeipamconfigv1alpha1.DefaultBackoffMaxDelay,
+		MaxRetries:      nodeipamconfigv1alpha1.DefaultBackoffMaxRetries,
+	}
+}
+
+// sourceAllocator publishes the pod CIDRs of a Source on the nodes.
+type sourceAllocator struct {
+	client     clientset.Interface
+	source     Source
+	nodeLister corelisters.NodeLister
+	nodeSynced cache.InformerSynced
+	queue      *Queue
+	workers    int
+}
+
+// New returns an allocator publishing the pod CIDRs allocated by the source on
+// the nodes of the informer that have none, and marking their network
+// available. Failed nodes are retried with a backoff.
+func New(client clientset.Interface, nodeInformer informers.NodeInformer, source Source, opts Options) CIDRAllocator {
+	a := &sourceAllocator{
+		client:     client,
+		source:     source,
+		nodeLister: nodeInformer.Lister(),
+		nodeSynced: nodeInformer.Informer().HasSynced,
+		workers:    opts.Workers,
+	}
+	a.queue = NewQueue(opts.RetryTimeout, opts.MaxRetryTimeout, opts.MaxRetries, a.updateNode)
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: nodeutil.CreateAddNodeHandler(a.AllocateOrOccupyCIDR),
+		UpdateFunc: nodeutil.CreateUpdateNodeHandler(func(_, newNode *v1.Node) error {
+			return a.AllocateOrOccupyCIDR(newNode)
+		}),
+		DeleteFunc: nodeutil.CreateDeleteNodeHandler(a.ReleaseCIDR),
+	})
+	return a
+}
+
+func (a *sourceAllocator) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+
+	if !cache.WaitForNamedCacheSync("cidrallocator", stopCh, a.nodeSynced) {
+		return
+	}
+	a.queue.Run(a.workers, stopCh)
+}
+
+func (a *sourceAllocator) AllocateOrOccupyCIDR(node *v1.Node) error {
+	if node == nil || !NeedsAllocation(node) {
+		return nil
+	}
+	if !a.queue.Add(node.Name) {
+		klog.V(2).InfoS("Node is already in a process of CIDR assignment", "node", klog.KObj(node))
+	}
+	return nil
+}
+
+func (a *sourceAllocator) ReleaseCIDR(node *v1.Node) error {
+	if node == nil {
+		return nil
+	}
+	return a.source.Release(context.TODO(), node)
+}
+
+// updateNode publishes the pod CIDRs of the node taken from the queue.
+func (a *sourceAllocator) updateNode(nodeName string) error {
+	node, err := a.nodeLister.Get(nodeName)
+	if err != nil {
+		return err
+	}
+	if !NeedsAllocation(node) {
+		return nil
+	}
+	podCIDRs := node.Spec.PodCIDRs
+	if len(podCIDRs) == 0 {
+		if podCIDRs, err = a.source.Allocate(context.TODO(), node); err != nil {
+			return err
+		}
+		if err := PublishPodCIDRs(a.client, node, podCIDRs); err != nil {
+			return err
+		}
+	}
+	return MarkNetworkAvailable(a.client, node.Name)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package allocator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeSource allocates a fixed pod CIDR to every node.
+type fakeSource struct {
+	mu       sync.Mutex
+	released []string
+}
+
+func (s *fakeSource) Allocate(_ context.Context, node *v1.Node) ([]string, error) {
+	return []string{"10.1.0.0/24", "fd00::/64"}, nil
+}
+
+func (s *fakeSource) Release(_ context.Context, node *v1.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released = append(s.released, node.Name)
+	return nil
+}
+
+func TestSourceAllocator(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	client := fake.NewSimpleClientset(node)
+	factory := informers.NewSharedInformerFactory(client, time.Hour)
+	source := &fakeSource{}
+	opts := DefaultOptions()
+	opts.Workers = 1
+	alloc := New(client, factory.Core().V1().Nodes(), source, opts)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	go alloc.Run(stopCh)
+
+	var got *v1.Node
+	err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		var err error
+		got, err = client.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return len(got.Spec.PodCIDRs) > 0 && !NetworkUnavailable(got), nil
+	})
+	if err != nil {
+		t.Fatalf("node was not allocated its pod CIDRs: %v", err)
+	}
+	if got.Spec.PodCIDR != "10.1.0.0/24" || len(got.Spec.PodCIDRs) != 2 {
+		t.Errorf("got pod CIDRs %q %v, want 10.1.0.0/24 first of two", got.Spec.PodCIDR, got.Spec.PodCIDRs)
+	}
+
+	if err := alloc.ReleaseCIDR(got); err != nil {
+		t.Fatalf("ReleaseCIDR() = %v", err)
+	}
+	if len(source.released) != 1 || source.released[0] != node.Name {
+		t.Errorf("released %v, want [%s]", source.released, node.Name)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package allocator
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
+	utiltaints "k8s.io/cloud-provider-gcp/pkg/util/taints"
+	"k8s.io/klog/v2"
+)
+
+// NeedsAllocation returns true if the node has no pod CIDR, or its network is
+// not marked available yet.
+func NeedsAllocation(node *v1.Node) bool {
+	if len(node.Spec.PodCIDRs) == 0 {
+		return true
+	}
+	return NetworkUnavailable(node)
+}
+
+// NetworkUnavailable returns true if the NodeNetworkUnavailable condition of
+// the node is not false, or the node has the network unavailable taint.
+func NetworkUnavailable(node *v1.Node) bool {
+	networkUnavailableTaint := &v1.Taint{Key: v1.TaintNodeNetworkUnavailable, Effect: v1.TaintEffectNoSchedule}
+	_, cond := nodeutil.GetNodeCondition(&node.Status, v1.NodeNetworkUnavailable)
+	return cond == nil || cond.Status != v1.ConditionFalse || utiltaints.TaintExists(node.Spec.Taints, networkUnavailableTaint)
+}
+
+// PublishPodCIDRs sets the pod CIDRs of the node, the primary one first.
+func PublishPodCIDRs(client clientset.Interface, node *v1.Node, podCIDRs []string) error {
+	if len(podCIDRs) == 0 {
+		return fmt.Errorf("no pod CIDR allocated to node %s", node.Name)
+	}
+	if err := utilnode.PatchNodeCIDRs(client, types.NodeName(node.Name), podCIDRs); err != nil {
+		return err
+	}
+	klog.InfoS("Set the node PodCIDRs", "nodeName", node.Name, "cidrStrings", podCIDRs)
+	return nil
+}
+
+// MarkNetworkAvailable sets the NodeNetworkUnavailable condition of the node
+// to false once its pod CIDRs are routed.
+func MarkNetworkAvailable(client clientset.Interface, nodeName string) error {
+	return MarkNetworkAvailableAt(client, nodeName, metav1.Now())
+}
+
+// MarkNetworkAvailableAt is MarkNetworkAvailable with the transition time of
+// the condition.
+func MarkNetworkAvailableAt(client clientset.Interface, nodeName string, now metav1.Time) error {
+	err := utilnode.SetNodeCondition(client, types.NodeName(nodeName), v1.NodeCondition{
+		Type:               v1.NodeNetworkUnavailable,
+		Status:             v1.ConditionFalse,
+		Reason:             "RouteCreated",
+		Message:            "NodeController create implicit route",
+		LastTransitionTime: now,
+	})
+	if err != nil {
+		klog.ErrorS(err, "Error setting route status for the node", "nodeName", nodeName)
+	}
+	return err
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package allocator
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// queueSize is the max no. of node updates that can be enqueued.
+const queueSize = 5000
+
+// RetryTimeout returns the time to wait before the retry number count of a
+// failed node update: initialTimeout doubled on every retry up to maxTimeout,
+// with a jitter of +/-50%.
+func RetryTimeout(count int, initialTimeout, maxTimeout time.Duration) time.Duration {
+	timeout := initialTimeout
+	for i := 0; i < count && timeout < maxTimeout; i++ {
+		timeout *= 2
+	}
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	return time.Duration(timeout.Nanoseconds()/2 + rand.Int63n(timeout.Nanoseconds()))
+}
+
+// noRetryError wraps the errors of the node updates that are not retried.
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// NoRetry wraps the error of a node update so that the Queue drops the node
+// instead of retrying it.
+func NoRetry(err error) error {
+	return &noRetryError{err: err}
+}
+
+// Queue is the work queue of the node updates of an allocator. A node is
+// queued at most once at a time; its failed updates are retried with the
+// backoff of RetryTimeout until they succeed, fail with an error wrapped by
+// NoRetry, or exceed the max retries, after which the node leaves the queue
+// and can be added again.
+//
+// A paused queue drops the nodes added to it, so that the event handlers
+// feeding it never block while no worker reads it. The queue pauses when Run
+// returns, and drops the nodes it holds.
+type Queue struct {
+	initialTimeout time.Duration
+	maxTimeout     time.Duration
+	maxRetries     int
+	process        func(nodeName string) error
+	updates        chan string
+
+	// Clock schedules the retries. The real clock is used if it is nil.
+	Clock clock.WithDelayedExecution
+	// Retrying is called, if set, with the retry count of a failed node
+	// before its retry is scheduled.
+	Retrying func(nodeName string, retries int)
+	// Removed is called, if set, when a node leaves the queue.
+	Removed func(nodeName string)
+
+	lock sync.Mutex
+	// retries holds the retry count of the nodes in the queue.
+	retries map[string]int
+	paused  bool
+	// runs counts the resumptions of the queue, so that the retries scheduled
+	// before a pause are dropped.
+	runs int
+}
+
+// NewQueue returns a queue updating the nodes with process.
+func NewQueue(initialTimeout, maxTimeout time.Duration, maxRetries int, process func(nodeName string) error) *Queue {
+	return &Queue{
+		initialTimeout: initialTimeout,
+		maxTimeout:     maxTimeout,
+		maxRetries:     maxRetries,
+		process:        process,
+		updates:        make(chan string, queueSize),
+		retries:        make(map[string]int),
+	}
+}
+
+// Add queues the update of the node, and returns false if it is already
+// queued or the queue is paused.
+func (q *Queue) Add(nodeName string) bool {
+	return q.AddWithRetries(nodeName, 0)
+}
+
+// AddWithRetries queues the update of a node whose updates already failed
+// retries times, e.g. before a restart, after the backoff of its next retry.
+// It returns false if the node is already queued or the queue is paused.
+func (q *Queue) AddWithRetries(nodeName string, retries int) bool {
+	q.lock.Lock()
+	if _, found := q.retries[nodeName]; found || q.paused {
+		q.lock.Unlock()
+		return false
+	}
+	q.retries[nodeName] = retries
+	run := q.runs
+	q.lock.Unlock()
+	if retries <= 0 {
+		q.updates <- nodeName
+		return true
+	}
+	timeout := RetryTimeout(retries, q.initialTimeout, q.maxTimeout)
+	klog.V(2).Infof("Resuming the backoff of %q, updating it after %v", nodeName, timeout)
+	q.afterFunc(timeout, func() {
+		q.requeue(run, nodeName)
+	})
+	return true
+}
+
+// requeue sends a node whose retry is due to the workers, unless the queue
+// paused since the retry was scheduled.
+func (q *Queue) requeue(run int, nodeName string) {
+	q.lock.Lock()
+	dropped := q.paused || q.runs != run
+	q.lock.Unlock()
+	if dropped {
+		return
+	}
+	q.updates <- nodeName
+}
+
+// Retries returns the retry count of the node, or false if it is not queued.
+func (q *Queue) Retries(nodeName string) (int, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	retries, ok := q.retries[nodeName]
+	return retries, ok
+}
+
+// Len returns the number of nodes in the queue, including the ones waiting
+// for a retry.
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.retries)
+}
+
+// Pause drops the queued nodes, and the nodes added until the queue is
+// resumed.
+func (q *Queue) Pause() {
+	q.lock.Lock()
+	q.paused = true
+	dropped := make([]string, 0, len(q.retries))
+	for nodeName := range q.retries {
+		dropped = append(dropped, nodeName)
+	}
+	q.retries = make(map[string]int)
+	q.lock.Unlock()
+	for len(q.updates) > 0 {
+		select {
+		case <-q.updates:
+		default:
+		}
+	}
+	if len(dropped) > 0 {
+		klog.Infof("Dropped %d nodes from the paused queue", len(dropped))
+	}
+	if q.Removed != nil {
+		for _, nodeName := range dropped {
+			q.Removed(nodeName)
+		}
+	}
+}
+
+// Resume resumes a paused queue.
+func (q *Queue) Resume() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.paused {
+		q.paused = false
+		q.runs++
+	}
+}
+
+// Run resumes the queue and updates the queued nodes with the workers until
+// stopCh is closed, and then pauses the queue.
+func (q *Queue) Run(workers int, stopCh <-chan struct{}) {
+	q.Resume()
+	for i := 0; i < workers; i++ {
+		go q.worker(stopCh)
+	}
+	<-stopCh
+	q.Pause()
+}
+
+// Updates returns the channel of the nodes to update, read by the workers of
+// Run. Callers running their own workers pass the nodes read from it to
+// Process.
+func (q *Queue) Updates() <-chan string {
+	return q.updates
+}
+
+func (q *Queue) worker(stopCh <-chan struct{}) {
+	for {
+		select {
+		case nodeName := <-q.updates:
+			q.Process(nodeName)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Process updates the node, and schedules its retry if it failed. Nodes
+// dropped from the queue since they were sent to the workers are skipped.
+func (q *Queue) Process(nodeName string) {
+	if _, queued := q.Retries(nodeName); !queued {
+		return
+	}
+	err := q.process(nodeName)
+	if err == nil {
+		klog.V(3).Infof("Updated CIDR for %q", nodeName)
+		q.remove(nodeName)
+		return
+	}
+	klog.Errorf("Error updating CIDR for %q: %v", nodeName, err)
+	var noRetry *noRetryError
+	if errors.As(err, &noRetry) {
+		klog.Errorf("Not retrying update for %q, dropping from queue", nodeName)
+		q.remove(nodeName)
+		return
+	}
+	run, count, timeout := q.nextRetry(nodeName)
+	switch {
+	case count == 0:
+		// The queue paused while the node was updated.
+		return
+	case count > q.maxRetries:
+		klog.Errorf("Exceeded retry count for %q, dropping from queue", nodeName)
+		q.remove(nodeName)
+		return
+	}
+	if q.Retrying != nil {
+		q.Retrying(nodeName, count)
+	}
+	klog.V(2).Infof("Retrying update for %q after %v", nodeName, timeout)
+	q.afterFunc(timeout, func() {
+		q.requeue(run, nodeName)
+	})
+}
+
+// nextRetry counts a retry of the node and returns the current run of the
+// queue, the count and the time to wait before the retry. The count is 0 if
+// the node was dropped from the queue, and exceeds the max retries, without
+// being recorded, if the node must not be retried.
+func (q *Queue) nextRetry(nodeName string) (int, int, time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	retries, queued := q.retries[nodeName]
+	if !queued {
+		return 0, 0, 0
+	}
+	count := retries + 1
+	if count > q.maxRetries {
+		return 0, count, 0
+	}
+	q.retries[nodeName] = count
+	return q.runs, count, RetryTimeout(count, q.initialTimeout, q.maxTimeout)
+}
+
+func (q *Queue) remove(nodeName string) {
+	q.lock.Lock()
+	delete(q.retries, nodeName)
+	q.lock.Unlock()
+	if q.Removed != nil {
+		q.Removed(nodeName)
+	}
+}
+
+func (q *Queue) afterFunc(d time.Duration, f func()) {
+	if q.Clock != nil {
+		q.Clock.AfterFunc(d, f)
+		return
+	}
+	time.AfterFunc(d, f)
+}
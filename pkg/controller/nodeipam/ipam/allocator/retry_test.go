@@ -0,0 +1,184 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func withinExpectedRange(got time.Duration, expected time.Duration) bool {
+	return got >= expected/2 && got <= 3*expected/2
+}
+
+func TestRetryTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		count int
+		want  time.Duration
+	}{
+		{count: 0, want: 250 * time.Millisecond},
+		{count: 1, want: 500 * time.Millisecond},
+		{count: 2, want: 1000 * time.Millisecond},
+		{count: 3, want: 2000 * time.Millisecond},
+		{count: 50, want: 5000 * time.Millisecond},
+	} {
+		t.Run(fmt.Sprintf("count %d", tc.count), func(t *testing.T) {
+			if got := RetryTimeout(tc.count, 250*time.Millisecond, 5*time.Second); !withinExpectedRange(got, tc.want) {
+				t.Errorf("RetryTimeout(%d) = %v; want %v", tc.count, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	const maxRetries = 2
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	calls := 0
+	q := NewQueue(time.Second, 10*time.Second, maxRetries, func(nodeName string) error {
+		calls++
+		return errors.New("allocation failed")
+	})
+	q.Clock = fakeClock
+
+	if !q.Add("node") {
+		t.Fatalf("Add() = false for a new node")
+	}
+	if q.Add("node") {
+		t.Errorf("Add() = true for a queued node")
+	}
+	for retry := 0; retry <= maxRetries; retry++ {
+		select {
+		case name := <-q.updates:
+			q.Process(name)
+		default:
+			t.Fatalf("node was not queued for update %d", retry)
+		}
+		fakeClock.Step(10 * time.Second)
+	}
+	if calls != maxRetries+1 {
+		t.Errorf("node updated %d times, want %d", calls, maxRetries+1)
+	}
+	if q.Len() != 0 {
+		t.Errorf("node is still queued after exceeding %d retries", maxRetries)
+	}
+	if fakeClock.HasWaiters() {
+		t.Errorf("a retry is scheduled after exceeding %d retries", maxRetries)
+	}
+	if !q.Add("node") {
+		t.Errorf("Add() = false for a node dropped from the queue")
+	}
+}
+
+func TestQueueNoRetry(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	q := NewQueue(time.Second, 10*time.Second, 5, func(nodeName string) error {
+		return NoRetry(errors.New("invalid node"))
+	})
+	q.Clock = fakeClock
+	var removed []string
+	q.Removed = func(nodeName string) {
+		removed = append(removed, nodeName)
+	}
+	q.Retrying = func(nodeName string, retries int) {
+		t.Errorf("Retrying(%q, %d) called for an error that is not retried", nodeName, retries)
+	}
+
+	q.Add("node")
+	q.Process(<-q.Updates())
+	if q.Len() != 0 {
+		t.Errorf("node is still queued after an error that is not retried")
+	}
+	if fakeClock.HasWaiters() {
+		t.Errorf("a retry is scheduled after an error that is not retried")
+	}
+	if len(removed) != 1 || removed[0] != "node" {
+		t.Errorf("removed nodes = %v, want [node]", removed)
+	}
+}
+
+func TestQueueAddWithRetries(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	q := NewQueue(time.Second, 10*time.Second, 5, func(nodeName string) error {
+		return errors.New("allocation failed")
+	})
+	q.Clock = fakeClock
+	var retrying []int
+	q.Retrying = func(nodeName string, retries int) {
+		retrying = append(retrying, retries)
+	}
+
+	if !q.AddWithRetries("node", 2) {
+		t.Fatalf("AddWithRetries() = false for a new node")
+	}
+	if len(q.Updates()) != 0 {
+		t.Fatalf("node with retries was queued without backoff")
+	}
+	if got, ok := q.Retries("node"); !ok || got != 2 {
+		t.Errorf("Retries() = %d, %v, want 2, true", got, ok)
+	}
+	// The backoff of the third retry is at most 3/2 of 4s.
+	fakeClock.Step(6 * time.Second)
+	select {
+	case name := <-q.Updates():
+		q.Process(name)
+	default:
+		t.Fatalf("node was not queued after its backoff")
+	}
+	if len(retrying) != 1 || retrying[0] != 3 {
+		t.Errorf("Retrying() called with %v, want [3]", retrying)
+	}
+}
+
+func TestQueuePause(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	q := NewQueue(time.Second, 10*time.Second, 5, func(nodeName string) error {
+		return errors.New("allocation failed")
+	})
+	q.Clock = fakeClock
+	var removed []string
+	q.Removed = func(nodeName string) {
+		removed = append(removed, nodeName)
+	}
+
+	q.Add("retrying")
+	q.Process(<-q.Updates())
+	q.Add("queued")
+	q.Pause()
+	if q.Len() != 0 || len(q.Updates()) != 0 {
+		t.Errorf("paused queue holds %d nodes, %d sent to the workers, want none", q.Len(), len(q.Updates()))
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed nodes = %v, want the 2 queued nodes", removed)
+	}
+	if q.Add("node") {
+		t.Errorf("Add() = true for a paused queue")
+	}
+
+	q.Resume()
+	// The retry scheduled before the pause is dropped.
+	fakeClock.Step(10 * time.Second)
+	if len(q.Updates()) != 0 {
+		t.Errorf("retry scheduled before the pause was sent to the workers")
+	}
+	if !q.Add("node") {
+		t.Errorf("Add() = false for a resumed queue")
+	}
+}
@@ -0,0 +1,83 @@
+package ipam
+
+import (
+	"net"
+	"sort"
+
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// Optional features of the CIDR allocators, reported at startup so that their
+// rollout can be tracked across clusters.
+const (
+	// featureMultiNetwork is the allocation of pod CIDRs of additional networks.
+	featureMultiNetwork = "multinetwork"
+	// featureIPv6 is the allocation of IPv6 pod CIDRs in IPv6 or dual-stack clusters.
+	featureIPv6 = "ipv6"
+	// featureIPCapacity is the publishing of the IP capacity of additional
+	// networks in the node status.
+	featureIPCapacity = "ip_capacity"
+	// featureNodeLocalIPAM is the delegation of the alias IP range attach of
+	// additional networks to a node agent.
+	featureNodeLocalIPAM = "node_local_ipam"
+	// featureShadowAllocator is the dry-run of an alternative multi-network
+	// allocation algorithm whose results are never written to the nodes.
+	featureShadowAllocator = "shadow_allocator"
+	// featureNodeCoordinationLeases is the handshake with node agents through a
+	// coordination Lease per node.
+	featureNodeCoordinationLeases = "node_coordination_leases"
+	// featureNodeCleanupHooks is the call of the cleanup hooks of the networks
+	// of deleted nodes.
+	featureNodeCleanupHooks = "node_cleanup_hooks"
+	// featurePredictiveAllocation is the prediction of the networks of new
+	// nodes from their instance template.
+	featurePredictiveAllocation = "predictive_allocation"
+)
+
+// allocatorFeatures returns whether each optional feature is enabled in an
+// allocator of the given type and parameters.
+func allocatorFeatures(allocatorType CIDRAllocatorType, params CIDRAllocatorParams) map[string]bool {
+	multiNetwork := allocatorType == CloudAllocatorType && params.Cloud.EnableMultiNetworking
+	return map[string]bool{
+		featureMultiNetwork:           multiNetwork,
+		featureIPv6:                   hasIPv6CIDR(params.ClusterCIDRs),
+		featureIPCapacity:             multiNetwork && !params.Cloud.NodeLocalIPAM && !params.Cloud.DisableIPCapacity,
+		featureNodeLocalIPAM:          multiNetwork && params.Cloud.NodeLocalIPAM,
+		featureShadowAllocator:        multiNetwork && params.Cloud.ShadowAllocator != "",
+		featureNodeCoordinationLeases: multiNetwork && params.Cloud.NodeCoordinationLeases,
+		featureNodeCleanupHooks:       multiNetwork && params.Cloud.NodeCleanupHooks,
+		featurePredictiveAllocation:   multiNetwork && params.Cloud.PredictiveAllocation,
+	}
+}
+
+func hasIPv6CIDR(cidrs []*net.IPNet) bool {
+	for _, cidr := range cidrs {
+		if netutils.IsIPv6CIDR(cidr) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAllocatorFeatures logs the optional features of the allocator and
+// exports them in the allocator_feature_enabled metric.
+func recordAllocatorFeatures(allocatorType CIDRAllocatorType, params CIDRAllocatorParams) {
+	registerAllocatorFeatureMetrics()
+	features := allocatorFeatures(allocatorType, params)
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	keysAndValues := []interface{}{"allocator", allocatorType}
+	for _, name := range names {
+		value := 0.0
+		if features[name] {
+			value = 1
+		}
+		allocatorFeatureEnabled.WithLabelValues(string(allocatorType), name).Set(value)
+		keysAndValues = append(keysAndValues, name, features[name])
+	}
+	klog.InfoS("CIDR allocator features", keysAndValues...)
+}
@@ -0,0 +1,123 @@
+package ipam
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestAllocatorFeatures(t *testing.T) {
+	_, ipv4CIDR, _ := net.ParseCIDR("10.0.0.0/16")
+	_, ipv6CIDR, _ := net.ParseCIDR("fd00::/48")
+	testCases := []struct {
+		desc          string
+		allocatorType CIDRAllocatorType
+		params        CIDRAllocatorParams
+		want          map[string]bool
+	}{
+		{
+			desc:          "range allocator",
+			allocatorType: RangeAllocatorType,
+			params:        CIDRAllocatorParams{ClusterCIDRs: []*net.IPNet{ipv4CIDR}, Cloud: CloudAllocatorParams{EnableMultiNetworking: true}},
+			want: map[string]bool{
+				featureMultiNetwork:           false,
+				featureIPv6:                   false,
+				featureIPCapacity:             false,
+				featureNodeLocalIPAM:          false,
+				featureShadowAllocator:        false,
+				featureNodeCoordinationLeases: false,
+				featureNodeCleanupHooks:       false,
+				featurePredictiveAllocation:   false,
+			},
+		},
+		{
+			desc:          "dual-stack cloud allocator",
+			allocatorType: CloudAllocatorType,
+			params:        CIDRAllocatorParams{ClusterCIDRs: []*net.IPNet{ipv4CIDR, ipv6CIDR}, Cloud: DefaultCloudAllocatorParams()},
+			want: map[string]bool{
+				featureMultiNetwork:           true,
+				featureIPv6:                   true,
+				featureIPCapacity:             true,
+				featureNodeLocalIPAM:          false,
+				featureShadowAllocator:        false,
+				featureNodeCoordinationLeases: false,
+				featureNodeCleanupHooks:       false,
+				featurePredictiveAllocation:   false,
+			},
+		},
+		{
+			desc:          "node-local IPAM with a shadow allocator, coordination leases and cleanup hooks",
+			allocatorType: CloudAllocatorType,
+			params: CIDRAllocatorParams{Cloud: CloudAllocatorParams{
+				EnableMultiNetworking:  true,
+				NodeLocalIPAM:          true,
+				ShadowAllocator:        IndexedMultiNetworkAllocator,
+				NodeCoordinationLeases: true,
+				NodeCleanupHooks:       true,
+			}},
+			want: map[string]bool{
+				featureMultiNetwork:           true,
+				featureIPv6:                   false,
+				featureIPCapacity:             false,
+				featureNodeLocalIPAM:          true,
+				featureShadowAllocator:        true,
+				featureNodeCoordinationLeases: true,
+				featureNodeCleanupHooks:       true,
+				featurePredictiveAllocation:   false,
+			},
+		},
+		{
+			desc:          "multi-networking without IP capacity",
+			allocatorType: CloudAllocatorType,
+			params:        CIDRAllocatorParams{Cloud: CloudAllocatorParams{EnableMultiNetworking: true, DisableIPCapacity: true}},
+			want: map[string]bool{
+				featureMultiNetwork:           true,
+				featureIPv6:                   false,
+				featureIPCapacity:             false,
+				featureNodeLocalIPAM:          false,
+				featureShadowAllocator:        false,
+				featureNodeCoordinationLeases: false,
+				featureNodeCleanupHooks:       false,
+				featurePredictiveAllocation:   false,
+			},
+		},
+		{
+			desc:          "multi-networking disabled",
+			allocatorType: CloudAllocatorType,
+			params:        CIDRAllocatorParams{Cloud: CloudAllocatorParams{ShadowAllocator: IndexedMultiNetworkAllocator}},
+			want: map[string]bool{
+				featureMultiNetwork:           false,
+				featureIPv6:                   false,
+				featureIPCapacity:             false,
+				featureNodeLocalIPAM:          false,
+				featureShadowAllocator:        false,
+				featureNodeCoordinationLeases: false,
+				featureNodeCleanupHooks:       false,
+				featurePredictiveAllocation:   false,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := allocatorFeatures(tc.allocatorType, tc.params)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("allocatorFeatures() returned unexpected features (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRecordAllocatorFeatures(t *testing.T) {
+	recordAllocatorFeatures(CloudAllocatorType, CIDRAllocatorParams{Cloud: DefaultCloudAllocatorParams()})
+	for feature, want := range map[string]float64{featureMultiNetwork: 1, featureNodeLocalIPAM: 0} {
+		got, err := testutil.GetGaugeMetricValue(allocatorFeatureEnabled.WithLabelValues(string(CloudAllocatorType), feature))
+		if err != nil {
+			t.Fatalf("failed to read the %s feature gauge: %v", feature, err)
+		}
+		if got != want {
+			t.Errorf("%s feature gauge = %v, want %v", feature, got, want)
+		}
+	}
+}
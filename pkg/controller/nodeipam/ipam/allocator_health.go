@@ -0,0 +1,101 @@
+package ipam
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/health"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// healthPublishInterval is the interval at which the health of the
+	// allocator is published, and the window its error rate is computed over.
+	healthPublishInterval = time.Minute
+	// queueHighWatermark is the no. of queued nodes from which the allocator
+	// is reported to shed load, as adding nodes to a full queue blocks the
+	// event handlers.
+	queueHighWatermark = cidrUpdateQueueSize * 8 / 10
+)
+
+// recordUpdateResult counts the update of a node taken from the work queue
+// in the error rate of the allocator.
+func (ca *cloudCIDRAllocator) recordUpdateResult(err error) {
+	ca.healthUpdates.Add(1)
+	if err != nil {
+		ca.healthFailedUpdates.Add(1)
+	}
+}
+
+// recordFullResync records the time every node is up to date, when the last
+// node leaves the work queue without any node parked on a terminal error. It
+// must be called with lock held.
+func (ca *cloudCIDRAllocator) recordFullResync() {
+	if ca.queue.Len() == 0 && len(ca.parkedNodes) == 0 {
+		ca.lastFullResync = ca.now()
+	}
+}
+
+// deferredNodes returns the no. of nodes whose update is deferred: the nodes
+// waiting for their turn in a network rollout and the nodes served from their
+// allocation checkpoint.
+func (ca *cloudCIDRAllocator) deferredNodes() int {
+	ca.rolloutLock.Lock()
+	deferred := 0
+	for _, rollout := range ca.rollouts {
+		deferred += len(rollout.pending)
+	}
+	ca.rolloutLock.Unlock()
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	return deferred + len(ca.checkpointed)
+}
+
+// healthStatus returns the health of the allocator, with the error rate of
+// the node updates since the last call.
+func (ca *cloudCIDRAllocator) healthStatus() health.Status {
+	updates, failed := ca.healthUpdates.Swap(0), ca.healthFailedUpdates.Swap(0)
+	status := health.Status{
+		Window:        metav1.Duration{Duration: healthPublishInterval},
+		Updates:       updates,
+		FailedUpdates: failed,
+		DeferredNodes: int32(ca.deferredNodes()),
+	}
+	if updates > 0 {
+		status.ErrorRatePercent = int32(failed * 100 / updates)
+	}
+	status.QueuedNodes = int32(ca.queue.Len())
+	ca.lock.Lock()
+	status.ParkedNodes = int32(len(ca.parkedNodes))
+	if !ca.lastFullResync.IsZero() {
+		lastFullResync := ca.lastFullResync
+		status.LastFullResyncTime = &lastFullResync
+	}
+	ca.lock.Unlock()
+
+	now := ca.now()
+	converged := metav1.Condition{Type: wellknown.ConvergedConditionType, Status: metav1.ConditionTrue, Reason: wellknown.AllNodesUpToDateReason, LastTransitionTime: now}
+	if status.QueuedNodes > 0 || status.ParkedNodes > 0 {
+		converged.Status, converged.Reason = metav1.ConditionFalse, wellknown.NodesPendingReason
+	}
+	shedding := metav1.Condition{Type: wellknown.SheddingLoadConditionType, Status: metav1.ConditionFalse, Reason: wellknown.NoUpdateDeferredReason, LastTransitionTime: now}
+	switch {
+	case status.QueuedNodes >= queueHighWatermark:
+		shedding.Status, shedding.Reason = metav1.ConditionTrue, wellknown.QueueSaturatedReason
+	case status.DeferredNodes > 0:
+		shedding.Status, shedding.Reason = metav1.ConditionTrue, wellknown.UpdatesDeferredReason
+	}
+	meta.SetStatusCondition(&status.Conditions, converged)
+	meta.SetStatusCondition(&status.Conditions, shedding)
+	return status
+}
+
+// publishHealth publishes the health of the allocator.
+func (ca *cloudCIDRAllocator) publishHealth() {
+	if err := ca.params.HealthPublisher.Publish(context.TODO(), ca.healthStatus()); err != nil {
+		klog.ErrorS(err, "Failed to publish the health of the allocator")
+	}
+}
@@ -0,0 +1,99 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/health"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPublishHealth(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		health.Resource: "NodeIPAMHealthList",
+	})
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/node0"},
+	}
+	clientSet := fake.NewSimpleClientset(node)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	if err := sharedInformer.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+		t.Fatal(err)
+	}
+	params := DefaultCloudAllocatorParams()
+	params.EnableMultiNetworking = false
+	params.HealthPublisher = health.NewPublisher(client)
+	params.UpdateRetryTimeout = time.Second
+	params.MaxUpdateRetryTimeout = time.Second
+	params.UpdateMaxRetries = 1
+	fakeClock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ca := withQueue(&cloudCIDRAllocator{
+		client:      clientSet,
+		nodeLister:  sharedInformer.Core().V1().Nodes().Lister(),
+		recorder:    record.NewFakeRecorder(10),
+		instances:   &fakeInstances{err: errors.New("compute API unavailable")},
+		clock:       fakeClock,
+		parkedNodes: map[string]string{},
+		params:      params,
+	})
+
+	get := func() *health.NodeIPAMHealth {
+		t.Helper()
+		ca.publishHealth()
+		u, err := client.Resource(health.Resource).Get(context.TODO(), wellknown.NodeIPAMHealthName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() returned err %v", err)
+		}
+		var got health.NodeIPAMHealth
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &got); err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+
+	// The node fails to update and is requeued with a backoff.
+	ca.queueNode(node.Name, 0)
+	ca.queue.Process(<-ca.queue.Updates())
+	got := get()
+	if got.Status.Updates != 1 || got.Status.FailedUpdates != 1 || got.Status.ErrorRatePercent != 100 {
+		t.Errorf("updates = %d, failed = %d, error rate = %d%%, want 1, 1, 100%%", got.Status.Updates, got.Status.FailedUpdates, got.Status.ErrorRatePercent)
+	}
+	if got.Status.QueuedNodes != 1 || got.Status.LastFullResyncTime != nil {
+		t.Errorf("queued nodes = %d, last full resync = %v, want 1 and none", got.Status.QueuedNodes, got.Status.LastFullResyncTime)
+	}
+	if meta.IsStatusConditionTrue(got.Status.Conditions, wellknown.ConvergedConditionType) {
+		t.Errorf("%s condition is true with a queued node", wellknown.ConvergedConditionType)
+	}
+
+	// The node leaves the queue after its last retry, the counters are reset
+	// for the next window.
+	fakeClock.Step(time.Minute)
+	ca.queue.Process(<-ca.queue.Updates())
+	get()
+	got = get()
+	if got.Status.Updates != 0 || got.Status.ErrorRatePercent != 0 {
+		t.Errorf("updates = %d, error rate = %d%%, want 0 in the next window", got.Status.Updates, got.Status.ErrorRatePercent)
+	}
+	if got.Status.LastFullResyncTime == nil || !got.Status.LastFullResyncTime.Time.Equal(fakeClock.Now()) {
+		t.Errorf("last full resync = %v, want %v", got.Status.LastFullResyncTime, fakeClock.Now())
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, wellknown.ConvergedConditionType) {
+		t.Errorf("%s condition is not true with no queued node", wellknown.ConvergedConditionType)
+	}
+	if meta.IsStatusConditionTrue(got.Status.Conditions, wellknown.SheddingLoadConditionType) {
+		t.Errorf("%s condition is true with no deferred node", wellknown.SheddingLoadConditionType)
+	}
+}
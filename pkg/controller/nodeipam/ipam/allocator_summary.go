@@ -0,0 +1,78 @@
+package ipam
+
+import (
+	"net"
+	"sort"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+// AllocatorSummary is the resolved configuration of a CIDR allocator, dumped
+// at startup for supportability.
+type AllocatorSummary struct {
+	Type                 CIDRAllocatorType `json:"type"`
+	ClusterCIDRs         []string          `json:"clusterCIDRs"`
+	ServiceCIDR          string            `json:"serviceCIDR,omitempty"`
+	SecondaryServiceCIDR string            `json:"secondaryServiceCIDR,omitempty"`
+	NodeCIDRMaskSizes    []int             `json:"nodeCIDRMaskSizes"`
+	// Features are the optional features of the allocator, see
+	// allocatorFeatures.
+	Features map[string]bool `json:"features"`
+	// Networks is a snapshot of the Networks of the cluster, sorted by name.
+	Networks []NetworkSummary `json:"networks,omitempty"`
+}
+
+// NetworkSummary is the part of a Network the allocator depends on.
+type NetworkSummary struct {
+	Name          string                `json:"name"`
+	Type          networkv1.NetworkType `json:"type"`
+	ParametersRef string                `json:"parametersRef,omitempty"`
+	// IPResourceName is the custom name of the IP capacity resource of the
+	// network, see the ipResourceName field of the Network spec.
+	IPResourceName string `json:"ipResourceName,omitempty"`
+	Deleting       bool   `json:"deleting,omitempty"`
+}
+
+// SummarizeAllocator returns the summary of an allocator of the given type and
+// parameters, with a snapshot of the networks.
+func SummarizeAllocator(allocatorType CIDRAllocatorType, params CIDRAllocatorParams, networks []*networkv1.Network) AllocatorSummary {
+	summary := AllocatorSummary{
+		Type:                 allocatorType,
+		ClusterCIDRs:         cidrStrings(params.ClusterCIDRs),
+		ServiceCIDR:          cidrString(params.ServiceCIDR),
+		SecondaryServiceCIDR: cidrString(params.SecondaryServiceCIDR),
+		NodeCIDRMaskSizes:    params.NodeCIDRMaskSizes,
+		Features:             allocatorFeatures(allocatorType, params),
+	}
+	for _, network := range networks {
+		ns := NetworkSummary{
+			Name:           network.Name,
+			Type:           network.Spec.Type,
+			IPResourceName: network.Spec.IPResourceName,
+			Deleting:       network.DeletionTimestamp != nil,
+		}
+		if ref := network.Spec.ParametersRef; ref != nil {
+			ns.ParametersRef = ref.Kind + "/" + ref.Name
+		}
+		summary.Networks = append(summary.Networks, ns)
+	}
+	sort.Slice(summary.Networks, func(i, j int) bool {
+		return summary.Networks[i].Name < summary.Networks[j].Name
+	})
+	return summary
+}
+
+func cidrStrings(cidrs []*net.IPNet) []string {
+	strs := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		strs = append(strs, cidr.String())
+	}
+	return strs
+}
+
+func cidrString(cidr *net.IPNet) string {
+	if cidr == nil {
+		return ""
+	}
+	return cidr.String()
+}
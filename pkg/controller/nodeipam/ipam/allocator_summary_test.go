@@ -0,0 +1,41 @@
+package ipam
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+func TestSummarizeAllocator(t *testing.T) {
+	_, clusterCIDR, _ := net.ParseCIDR("10.0.0.0/16")
+	_, serviceCIDR, _ := net.ParseCIDR("10.1.0.0/20")
+	red := network(redNetworkName, redGKENetworkParamsName)
+	red.Spec.IPResourceName = "example.com/red-ip"
+	deleting := network("blue", "blue-gnp")
+	deleting.DeletionTimestamp = &metav1.Time{}
+	params := CIDRAllocatorParams{
+		ClusterCIDRs:      []*net.IPNet{clusterCIDR},
+		ServiceCIDR:       serviceCIDR,
+		NodeCIDRMaskSizes: []int{24},
+		Cloud:             DefaultCloudAllocatorParams(),
+	}
+
+	got := SummarizeAllocator(CloudAllocatorType, params, []*networkv1.Network{red, deleting})
+	want := AllocatorSummary{
+		Type:              CloudAllocatorType,
+		ClusterCIDRs:      []string{"10.0.0.0/16"},
+		ServiceCIDR:       "10.1.0.0/20",
+		NodeCIDRMaskSizes: []int{24},
+		Features:          allocatorFeatures(CloudAllocatorType, params),
+		Networks: []NetworkSummary{
+			{Name: redNetworkName, Type: networkv1.L3NetworkType, ParametersRef: gkeNetworkParamsKind + "/" + redGKENetworkParamsName, IPResourceName: "example.com/red-ip"},
+			{Name: "blue", Type: networkv1.L3NetworkType, ParametersRef: gkeNetworkParamsKind + "/blue-gnp", Deleting: true},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SummarizeAllocator() returned unexpected summary (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the changes of the pod CIDRs, annotations and IP
+// capacity published on the nodes by the node IPAM controller, for the
+// traceability of the IP allocations.
+//
+// The records are JSON encoded, either appended as lines to a file, or POSTed
+// one by one to a webhook.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookTimeout bounds the calls to the webhook.
+const webhookTimeout = 10 * time.Second
+
+// Change is the change of a field of a node.
+type Change struct {
+	// Field is the path of the field, e.g. spec.podCIDRs,
+	// metadata.annotations[networking.gke.io/north-interfaces] or
+	// status.capacity[networking.gke.io.networks/red.IP].
+	Field string `json:"field"`
+	// Before is the value of the field before the change, empty if it was
+	// not set.
+	Before string `json:"before,omitempty"`
+	// After is the value of the field after the change, empty if it was
+	// removed.
+	After string `json:"after,omitempty"`
+}
+
+// Record is an update of a node.
+type Record struct {
+	Time time.Time `json:"time"`
+	Node string    `json:"node"`
+	// Reason is why the node was updated, e.g. CIDRAllocation.
+	Reason  string   `json:"reason"`
+	Changes []Change `json:"changes"`
+}
+
+// Sink records the updates of the nodes.
+type Sink interface {
+	Record(record Record) error
+}
+
+// New returns the sink of the target: the records are POSTed to http:// and
+// https:// URLs, and appended to the file at any other path.
+func New(target string) (Sink, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return NewWebhookSink(target), nil
+	}
+	return NewFileSink(target)
+}
+
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink returns a sink appending the records to the file as JSON lines.
+// The file is created if it does not exist.
+func NewFileSink(path string) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit file: %v", err)
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Record(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+type webhookSink struct {
+	url string
+}
+
+// NewWebhookSink returns a sink POSTing every record to the URL.
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{url: url}
+}
+
+func (s *webhookSink) Record(record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid audit webhook: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit webhook failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
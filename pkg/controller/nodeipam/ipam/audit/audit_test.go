@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{
+			Time:    time.Unix(1000, 0).UTC(),
+			Node:    "node0",
+			Reason:  "CIDRAllocation",
+			Changes: []Change{{Field: "spec.podCIDRs", After: "10.0.0.0/24"}},
+		},
+		{
+			Time:    time.Unix(2000, 0).UTC(),
+			Node:    "node1",
+			Reason:  "CIDRAllocation",
+			Changes: []Change{{Field: "metadata.annotations[networking.gke.io/instance-id]", Before: "1", After: "2"}},
+		},
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := New(path)
+	if err != nil {
+		t.Fatalf("New() returned err %v", err)
+	}
+	want := testRecords()
+	for _, record := range want {
+		if err := sink.Record(record); err != nil {
+			t.Fatalf("Record() returned err %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open the audit file: %v", err)
+	}
+	defer file.Close()
+	var got []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		got = append(got, record)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected records (-want +got):\n%s", diff)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []Record
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var record Record
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Errorf("invalid record: %v", err)
+		}
+		got = append(got, record)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sink, err := New(server.URL)
+	if err != nil {
+		t.Fatalf("New() returned err %v", err)
+	}
+	want := testRecords()
+	for _, record := range want {
+		if err := sink.Record(record); err != nil {
+			t.Fatalf("Record() returned err %v", err)
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected records (-want +got):\n%s", diff)
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Record(want[0]); err == nil {
+		t.Errorf("Record() returned no error for a failed webhook call")
+	}
+}
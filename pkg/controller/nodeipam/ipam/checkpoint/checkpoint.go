@@ -0,0 +1,382 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package checkpoint persists the last-known-good pod CIDR allocations of the
+// nodes in NodeIPAMCheckpoint objects, so that a restarted cloud CIDR
+// allocator can trust the nodes already matching them while it refreshes
+// them from the compute API in the background.
+//
+// The allocations are stored in chunks of ChunkSize nodes, one
+// NodeIPAMCheckpoint per chunk, named
+// wellknown.NodeIPAMCheckpointPrefix<index>. A node stays in its chunk until it
+// is forgotten, so that only the chunks of the changed nodes are written.
+// NodeIPAMCheckpoint is an internal, cluster scoped resource of the
+// networking.gke.io/v1alpha1 API read and written with a dynamic client, see
+// crd/config/crds/networking.gke.io_nodeipamcheckpoints.yaml.
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ChunkSize is the max no. of nodes of a NodeIPAMCheckpoint.
+	ChunkSize = 100
+)
+
+// Resource is the resource of the NodeIPAMCheckpoints.
+var Resource = schema.GroupVersionResource{Group: "networking.gke.io", Version: "v1alpha1", Resource: "nodeipamcheckpoints"}
+
+// Entry is the last-known-good allocation of a node.
+type Entry struct {
+	// Node is the name of the node.
+	Node string `json:"node"`
+	// ProviderID is the providerID of the node the pod CIDRs were allocated
+	// to, so that a recreated instance is not matched.
+	ProviderID string `json:"providerID"`
+	// PodCIDRs are the pod CIDRs published on the node.
+	PodCIDRs []string `json:"podCIDRs,omitempty"`
+}
+
+// NodeIPAMCheckpoint holds the allocations of a chunk of nodes.
+type NodeIPAMCheckpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Entries []Entry `json:"entries,omitempty"`
+}
+
+// Store reads and writes the NodeIPAMCheckpoints.
+type Store interface {
+	List(ctx context.Context) ([]*NodeIPAMCheckpoint, error)
+	// Create and Update set the resource version of the written checkpoint.
+	Create(ctx context.Context, checkpoint *NodeIPAMCheckpoint) error
+	Update(ctx context.Context, checkpoint *NodeIPAMCheckpoint) error
+	Delete(ctx context.Context, name string) error
+}
+
+// dynamicStore is the Store of the NodeIPAMCheckpoints of an API server.
+type dynamicStore struct {
+	client dynamic.ResourceInterface
+}
+
+// NewStore returns the Store of the NodeIPAMCheckpoints read and written with
+// the client.
+func NewStore(client dynamic.Interface) Store {
+	return &dynamicStore{client: client.Resource(Resource)}
+}
+
+func (s *dynamicStore) List(ctx context.Context) ([]*NodeIPAMCheckpoint, error) {
+	list, err := s.client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []*NodeIPAMCheckpoint
+	for i := range list.Items {
+		checkpoint := &NodeIPAMCheckpoint{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, checkpoint); err != nil {
+			return nil, fmt.Errorf("invalid NodeIPAMCheckpoint %s: %v", list.Items[i].GetName(), err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+func (s *dynamicStore) Create(ctx context.Context, checkpoint *NodeIPAMCheckpoint) error {
+	obj, err := toUnstructured(checkpoint)
+	if err != nil {
+		return err
+	}
+	created, err := s.client.Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	checkpoint.ResourceVersion = created.GetResourceVersion()
+	return nil
+}
+
+func (s *dynamicStore) Update(ctx context.Context, checkpoint *NodeIPAMCheckpoint) error {
+	obj, err := toUnstructured(checkpoint)
+	if err != nil {
+		return err
+	}
+	updated, err := s.client.Update(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	checkpoint.ResourceVersion = updated.GetResourceVersion()
+	return nil
+}
+
+func (s *dynamicStore) Delete(ctx context.Context, name string) error {
+	return s.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func toUnstructured(checkpoint *NodeIPAMCheckpoint) (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{Object: object}
+	obj.SetAPIVersion(Resource.GroupVersion().String())
+	obj.SetKind("NodeIPAMCheckpoint")
+	return obj, nil
+}
+
+// chunk is a NodeIPAMCheckpoint as last read or written, and the entries it
+// is to be written with.
+type chunk struct {
+	// exists is set if the NodeIPAMCheckpoint of the chunk was read or
+	// written.
+	exists          bool
+	resourceVersion string
+	entries         map[string]Entry
+	dirty           bool
+}
+
+// Checkpointer holds the allocations of the nodes in chunks and writes the
+// changed chunks to its Store.
+type Checkpointer struct {
+	store Store
+
+	mu     sync.Mutex
+	chunks map[int]*chunk
+	// nodeChunks holds the index of the chunk of each node.
+	nodeChunks map[string]int
+	// stale is set after a failed write, so that the resource versions of the
+	// chunks are read again before the next one.
+	stale bool
+}
+
+// New returns a Checkpointer of the NodeIPAMCheckpoints of the store.
+func New(store Store) *Checkpointer {
+	return &Checkpointer{
+		store:      store,
+		chunks:     make(map[int]*chunk),
+		nodeChunks: make(map[string]int),
+	}
+}
+
+// chunkIndex returns the index of the chunk of the name, or false if it is not
+// the name of a chunk.
+func chunkIndex(name string) (int, bool) {
+	if !strings.HasPrefix(name, wellknown.NodeIPAMCheckpointPrefix) {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(name, wellknown.NodeIPAMCheckpointPrefix))
+	return index, err == nil && index >= 0
+}
+
+// Load reads the NodeIPAMCheckpoints of the store and returns their entries
+// by node name. The nodes keep their chunk.
+func (c *Checkpointer) Load(ctx context.Context) (map[string]Entry, error) {
+	checkpoints, err := c.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make(map[string]Entry)
+	for _, checkpoint := range checkpoints {
+		index, ok := chunkIndex(checkpoint.Name)
+		if !ok {
+			klog.Warningf("Ignoring NodeIPAMCheckpoint %s, not named %s<index>", checkpoint.Name, wellknown.NodeIPAMCheckpointPrefix)
+			continue
+		}
+		ch := &chunk{exists: true, resourceVersion: checkpoint.ResourceVersion, entries: make(map[string]Entry)}
+		for _, e := range checkpoint.Entries {
+			if _, ok := c.nodeChunks[e.Node]; ok {
+				// A node in several chunks is kept in the first one.
+				ch.dirty = true
+				continue
+			}
+			ch.entries[e.Node] = e
+			c.nodeChunks[e.Node] = index
+			entries[e.Node] = e
+		}
+		c.chunks[index] = ch
+	}
+	c.stale = false
+	return entries, nil
+}
+
+// Record records the allocation of a node. New nodes join the first chunk
+// that is not full.
+func (c *Checkpointer) Record(e Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, ok := c.nodeChunks[e.Node]
+	if !ok {
+		for index = 0; ; index++ {
+			if ch := c.chunks[index]; ch == nil || len(ch.entries) < ChunkSize {
+				break
+			}
+		}
+		c.nodeChunks[e.Node] = index
+	}
+	ch := c.chunks[index]
+	if ch == nil {
+		ch = &chunk{entries: make(map[string]Entry)}
+		c.chunks[index] = ch
+	}
+	if old, ok := ch.entries[e.Node]; ok && reflect.DeepEqual(old, e) {
+		return
+	}
+	ch.entries[e.Node] = e
+	ch.dirty = true
+}
+
+// Forget removes a deleted node from its chunk.
+func (c *Checkpointer) Forget(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, ok := c.nodeChunks[nodeName]
+	if !ok {
+		return
+	}
+	delete(c.nodeChunks, nodeName)
+	delete(c.chunks[index].entries, nodeName)
+	c.chunks[index].dirty = true
+}
+
+// Flush writes the changed chunks to the store, deleting the empty ones. The
+// chunks that failed to be written are written again by the next call.
+func (c *Checkpointer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	stale := c.stale
+	c.mu.Unlock()
+	if stale {
+		if err := c.refreshResourceVersions(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	var writes []*NodeIPAMCheckpoint
+	exists := make(map[string]bool)
+	for index, ch := range c.chunks {
+		if !ch.dirty {
+			continue
+		}
+		checkpoint := &NodeIPAMCheckpoint{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s%d", wellknown.NodeIPAMCheckpointPrefix, index), ResourceVersion: ch.resourceVersion}}
+		for _, e := range ch.entries {
+			checkpoint.Entries = append(checkpoint.Entries, e)
+		}
+		sort.Slice(checkpoint.Entries, func(i, j int) bool { return checkpoint.Entries[i].Node < checkpoint.Entries[j].Node })
+		writes = append(writes, checkpoint)
+		exists[checkpoint.Name] = ch.exists
+		ch.dirty = false
+	}
+	c.mu.Unlock()
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Name < writes[j].Name })
+
+	var errs []string
+	for _, checkpoint := range writes {
+		index, _ := chunkIndex(checkpoint.Name)
+		err := c.write(ctx, checkpoint, exists[checkpoint.Name])
+		c.mu.Lock()
+		ch := c.chunks[index]
+		switch {
+		case err != nil:
+			ch.dirty = true
+			c.stale = true
+			errs = append(errs, fmt.Sprintf("%s: %v", checkpoint.Name, err))
+		case len(checkpoint.Entries) == 0 && len(ch.entries) == 0:
+			delete(c.chunks, index)
+		default:
+			ch.exists = len(checkpoint.Entries) > 0
+			ch.resourceVersion = checkpoint.ResourceVersion
+		}
+		c.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to write the NodeIPAMCheckpoints: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// write creates, updates or deletes the NodeIPAMCheckpoint of a chunk.
+func (c *Checkpointer) write(ctx context.Context, checkpoint *NodeIPAMCheckpoint, exists bool) error {
+	switch {
+	case len(checkpoint.Entries) == 0:
+		if !exists {
+			return nil
+		}
+		if err := c.store.Delete(ctx, checkpoint.Name); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		checkpoint.ResourceVersion = ""
+		return nil
+	case !exists:
+		return c.store.Create(ctx, checkpoint)
+	default:
+		return c.store.Update(ctx, checkpoint)
+	}
+}
+
+// refreshResourceVersions reads the resource versions of the chunks again,
+// keeping the entries to write.
+func (c *Checkpointer) refreshResourceVersions(ctx context.Context) error {
+	checkpoints, err := c.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found := make(map[int]bool)
+	for _, checkpoint := range checkpoints {
+		index, ok := chunkIndex(checkpoint.Name)
+		if !ok {
+			continue
+		}
+		found[index] = true
+		ch := c.chunks[index]
+		if ch == nil {
+			// Not a chunk of this allocator anymore.
+			ch = &chunk{entries: make(map[string]Entry)}
+			c.chunks[index] = ch
+		}
+		if !ch.exists || ch.resourceVersion != checkpoint.ResourceVersion {
+			ch.exists = true
+			ch.resourceVersion = checkpoint.ResourceVersion
+			ch.dirty = true
+		}
+	}
+	for index, ch := range c.chunks {
+		if !found[index] && ch.exists {
+			ch.exists = false
+			ch.resourceVersion = ""
+			ch.dirty = true
+		}
+	}
+	c.stale = false
+	return nil
+}
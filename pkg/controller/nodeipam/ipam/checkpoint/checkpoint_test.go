@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newFakeStore() (Store, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		Resource: "NodeIPAMCheckpointList",
+	})
+	return NewStore(client), client
+}
+
+func entry(i int) Entry {
+	return Entry{
+		Node:       fmt.Sprintf("node%d", i),
+		ProviderID: fmt.Sprintf("gce://p/z/node%d", i),
+		PodCIDRs:   []string{fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)},
+	}
+}
+
+func chunkSizes(t *testing.T, store Store) map[string]int {
+	t.Helper()
+	checkpoints, err := store.List(context.TODO())
+	if err != nil {
+		t.Fatalf("List() returned err %v", err)
+	}
+	sizes := make(map[string]int)
+	for _, checkpoint := range checkpoints {
+		sizes[checkpoint.Name] = len(checkpoint.Entries)
+	}
+	return sizes
+}
+
+func TestCheckpointerFlushAndLoad(t *testing.T) {
+	ctx := context.TODO()
+	store, _ := newFakeStore()
+	c := New(store)
+	for i := 0; i < ChunkSize+1; i++ {
+		c.Record(entry(i))
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"node-ipam-0": ChunkSize, "node-ipam-1": 1}, chunkSizes(t, store)); diff != "" {
+		t.Errorf("chunks after Flush() (-want +got):\n%s", diff)
+	}
+
+	restarted := New(store)
+	got, err := restarted.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load() returned err %v", err)
+	}
+	if len(got) != ChunkSize+1 {
+		t.Fatalf("Load() returned %d entries, want %d", len(got), ChunkSize+1)
+	}
+	if diff := cmp.Diff(entry(7), got["node7"]); diff != "" {
+		t.Errorf("Load() entry of node7 (-want +got):\n%s", diff)
+	}
+
+	// A forgotten node frees a slot in its chunk for the next new node, and
+	// emptied chunks are deleted.
+	restarted.Forget("node3")
+	restarted.Forget(entry(ChunkSize).Node)
+	restarted.Record(entry(ChunkSize + 1))
+	if err := restarted.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"node-ipam-0": ChunkSize}, chunkSizes(t, store)); diff != "" {
+		t.Errorf("chunks after Forget() (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckpointerFlushOnlyDirtyChunks(t *testing.T) {
+	ctx := context.TODO()
+	store, client := newFakeStore()
+	c := New(store)
+	for i := 0; i < 2*ChunkSize; i++ {
+		c.Record(entry(i))
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+	client.ClearActions()
+
+	c.Record(entry(1))
+	c.Record(Entry{Node: "node150", ProviderID: "gce://p/z/node150", PodCIDRs: []string{"10.1.0.0/24"}})
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+	var updated []string
+	for _, action := range client.Actions() {
+		if update, ok := action.(clienttesting.UpdateAction); ok {
+			updated = append(updated, update.GetObject().(*unstructured.Unstructured).GetName())
+		}
+	}
+	if diff := cmp.Diff([]string{"node-ipam-1"}, updated); diff != "" {
+		t.Errorf("updated chunks (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckpointerFlushError(t *testing.T) {
+	ctx := context.TODO()
+	store, client := newFakeStore()
+	c := New(store)
+	c.Record(entry(0))
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+
+	client.PrependReactor("update", "nodeipamcheckpoints", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("injected error")
+	})
+	c.Record(entry(1))
+	if err := c.Flush(ctx); err == nil {
+		t.Fatalf("Flush() returned nil err, want err")
+	}
+
+	client.ReactionChain = client.ReactionChain[1:]
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned err %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"node-ipam-0": 2}, chunkSizes(t, store)); diff != "" {
+		t.Errorf("chunks after retried Flush() (-want +got):\n%s", diff)
+	}
+}
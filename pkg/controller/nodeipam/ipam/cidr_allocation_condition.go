@@ -0,0 +1,70 @@
+package ipam
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
+	"k8s.io/klog/v2"
+)
+
+// failureCount returns the number of consecutive failed updates of the node,
+// including the one that just failed.
+func (ca *cloudCIDRAllocator) failureCount(nodeName string) int {
+	retries, ok := ca.queue.Retries(nodeName)
+	if !ok {
+		return 1
+	}
+	return retries + 1
+}
+
+// reportAllocationFailure sets wellknown.CIDRAllocationFailedCondition on the
+// node once its updates failed FailureConditionThreshold consecutive times, so
+// that autoscalers and remediation systems can act on it.
+func (ca *cloudCIDRAllocator) reportAllocationFailure(nodeName string, failures int, allocErr error) {
+	if ca.params.FailureConditionThreshold <= 0 || failures < ca.params.FailureConditionThreshold {
+		return
+	}
+	node, err := ca.nodeLister.Get(nodeName)
+	if err != nil {
+		return
+	}
+	if _, condition := nodeutil.GetNodeCondition(&node.Status, wellknown.CIDRAllocationFailedCondition); condition != nil && condition.Status == v1.ConditionTrue {
+		return
+	}
+	err = utilnode.SetNodeCondition(ca.client, types.NodeName(nodeName), v1.NodeCondition{
+		Type:               wellknown.CIDRAllocationFailedCondition,
+		Status:             v1.ConditionTrue,
+		Reason:             wellknown.CIDRAllocationFailedReason,
+		Message:            fmt.Sprintf("%d consecutive CIDR allocation attempts failed, last error: %v", failures, allocErr),
+		LastTransitionTime: ca.now(),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Error setting the CIDR allocation failure condition of the node", "nodeName", nodeName)
+	}
+}
+
+// clearAllocationFailure sets wellknown.CIDRAllocationFailedCondition back to
+// false after a successful update of a node that had it set.
+func (ca *cloudCIDRAllocator) clearAllocationFailure(nodeName string) {
+	node, err := ca.nodeLister.Get(nodeName)
+	if err != nil {
+		return
+	}
+	if _, condition := nodeutil.GetNodeCondition(&node.Status, wellknown.CIDRAllocationFailedCondition); condition == nil || condition.Status != v1.ConditionTrue {
+		return
+	}
+	err = utilnode.SetNodeCondition(ca.client, types.NodeName(nodeName), v1.NodeCondition{
+		Type:               wellknown.CIDRAllocationFailedCondition,
+		Status:             v1.ConditionFalse,
+		Reason:             wellknown.CIDRAllocationSucceededReason,
+		Message:            "CIDR allocation succeeded",
+		LastTransitionTime: ca.now(),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Error clearing the CIDR allocation failure condition of the node", "nodeName", nodeName)
+	}
+}
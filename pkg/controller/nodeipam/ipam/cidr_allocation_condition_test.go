@@ -0,0 +1,87 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+)
+
+func TestAllocationFailureCondition(t *testing.T) {
+	const nodeName = "test-node"
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+	clientSet := fake.NewSimpleClientset(node)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	nodeStore := sharedInformer.Core().V1().Nodes().Informer().GetStore()
+	if err := nodeStore.Add(node); err != nil {
+		t.Fatal(err)
+	}
+	params := DefaultCloudAllocatorParams()
+	params.FailureConditionThreshold = 3
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: sharedInformer.Core().V1().Nodes().Lister(),
+		params:     params,
+	})
+	condition := func() *v1.NodeCondition {
+		t.Helper()
+		got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Keep the lister in sync with the patched node.
+		if err := nodeStore.Update(got); err != nil {
+			t.Fatal(err)
+		}
+		_, c := nodeutil.GetNodeCondition(&got.Status, wellknown.CIDRAllocationFailedCondition)
+		return c
+	}
+
+	allocErr := errors.New("instance not found")
+	for failures := 1; failures < params.FailureConditionThreshold; failures++ {
+		ca.reportAllocationFailure(nodeName, failures, allocErr)
+	}
+	if c := condition(); c != nil {
+		t.Fatalf("condition set after %d failures: %+v", params.FailureConditionThreshold-1, c)
+	}
+
+	ca.reportAllocationFailure(nodeName, params.FailureConditionThreshold, allocErr)
+	c := condition()
+	if c == nil || c.Status != v1.ConditionTrue || c.Reason != wellknown.CIDRAllocationFailedReason {
+		t.Fatalf("condition after %d failures = %+v, want status %s and reason %s", params.FailureConditionThreshold, c, v1.ConditionTrue, wellknown.CIDRAllocationFailedReason)
+	}
+
+	ca.clearAllocationFailure(nodeName)
+	c = condition()
+	if c == nil || c.Status != v1.ConditionFalse || c.Reason != wellknown.CIDRAllocationSucceededReason {
+		t.Fatalf("condition after success = %+v, want status %s and reason %s", c, v1.ConditionFalse, wellknown.CIDRAllocationSucceededReason)
+	}
+}
+
+func TestAllocationFailureConditionDisabled(t *testing.T) {
+	const nodeName = "test-node"
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+	clientSet := fake.NewSimpleClientset(node)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	if err := sharedInformer.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+		t.Fatal(err)
+	}
+	params := DefaultCloudAllocatorParams()
+	params.FailureConditionThreshold = 0
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: sharedInformer.Core().V1().Nodes().Lister(),
+		params:     params,
+	})
+	ca.reportAllocationFailure(nodeName, 100, errors.New("instance not found"))
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got actions %v with the condition disabled, want none", actions)
+	}
+}
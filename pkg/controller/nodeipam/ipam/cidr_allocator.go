@@ -22,8 +22,14 @@ import (
 	"net"
 	"time"
 
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
 	networkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1"
 	alphanetworkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1alpha1"
+	nodeipamconfigv1alpha1 "k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/config/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/allocator"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/audit"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/checkpoint"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/health"
 	"k8s.io/klog/v2"
 
 	"k8s.io/api/core/v1"
@@ -33,6 +39,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	informers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
 	cloudprovider "k8s.io/cloud-provider"
 )
 
@@ -69,13 +76,34 @@ const (
 	cidrUpdateRetries = 3
 
 	// updateRetryTimeout is the time to wait before requeing a failed node for retry
-	updateRetryTimeout = 250 * time.Millisecond
+	updateRetryTimeout = nodeipamconfigv1alpha1.DefaultBackoffInitialDelay
 
 	// maxUpdateRetryTimeout is the maximum amount of time between timeouts.
-	maxUpdateRetryTimeout = 5 * time.Second
+	maxUpdateRetryTimeout = nodeipamconfigv1alpha1.DefaultBackoffMaxDelay
 
 	// updateMaxRetries is the max retries for a failed node
-	updateMaxRetries = 10
+	updateMaxRetries = nodeipamconfigv1alpha1.DefaultBackoffMaxRetries
+
+	// failureConditionThreshold is the no. of consecutive failed updates after which
+	// the CIDRAllocationFailed condition is set on the node.
+	failureConditionThreshold = nodeipamconfigv1alpha1.DefaultFailureConditionThreshold
+
+	// maxAdditionalNetworks is the max no. of additional networks published on a
+	// node.
+	maxAdditionalNetworks = nodeipamconfigv1alpha1.DefaultMaxAdditionalNetworks
+
+	// networkRolloutNodesPerMinute is the rate at which the nodes are
+	// reconciled after a Network is created or changed.
+	networkRolloutNodesPerMinute = nodeipamconfigv1alpha1.DefaultNetworkRolloutNodesPerMinute
+
+	// eventQPS and eventBurst are the token bucket limiting the events
+	// recorded by the cloud allocator for each reason.
+	eventQPS   = nodeipamconfigv1alpha1.DefaultEventQPS
+	eventBurst = nodeipamconfigv1alpha1.DefaultEventBurst
+
+	// networkCRDDiscoveryInterval is the interval at which the cloud allocator checks
+	// whether the multi-network CRDs are installed.
+	networkCRDDiscoveryInterval = time.Minute
 )
 
 // nodePollInterval is used in listing node
@@ -83,17 +111,9 @@ const (
 var nodePollInterval = 10 * time.Second
 
 // CIDRAllocator is an interface implemented by things that know how
-// to allocate/occupy/recycle CIDR for nodes.
-type CIDRAllocator interface {
-	// AllocateOrOccupyCIDR looks at the given node, assigns it a valid
-	// CIDR if it doesn't currently have one or mark the CIDR as used if
-	// the node already have one.
-	AllocateOrOccupyCIDR(node *v1.Node) error
-	// ReleaseCIDR releases the CIDR of the removed node
-	ReleaseCIDR(node *v1.Node) error
-	// Run starts all the working logic of the allocator.
-	Run(stopCh <-chan struct{})
-}
+// to allocate/occupy/recycle CIDR for nodes. It is defined in the allocator
+// package, shared with the allocators of other platforms.
+type CIDRAllocator = allocator.CIDRAllocator
 
 // CIDRAllocatorParams is parameters that's required for creating new
 // cidr range allocator.
@@ -106,6 +126,133 @@ type CIDRAllocatorParams struct {
 	SecondaryServiceCIDR *net.IPNet
 	// NodeCIDRMaskSizes is list of node cidr mask sizes
 	NodeCIDRMaskSizes []int
+	// Cloud holds the parameters of the cloud CIDR allocator.
+	Cloud CloudAllocatorParams
+}
+
+// CloudAllocatorParams is parameters that's required for creating new
+// cloud CIDR allocator.
+type CloudAllocatorParams struct {
+	// EnableMultiNetworking allows allocating pod CIDRs for additional networks.
+	EnableMultiNetworking bool
+	// NodeLocalIPAM delegates the alias IP range attach of additional networks
+	// to a node agent, see wellknown.DelegatedRangesAnnotationKey.
+	NodeLocalIPAM bool
+	// ShadowAllocator is the name of a multi-network allocation algorithm run
+	// in shadow mode next to the active one, see shadowMultiNetworkAllocators.
+	ShadowAllocator string
+	// DefaultNetworkName is the name of the Network the pod CIDRs are
+	// allocated from. The built-in default network names are used if it is
+	// empty. See also wellknown.DefaultNetworkAnnotationKey.
+	DefaultNetworkName string
+	// IPv6PrimaryPodCIDR orders the IPv6 pod CIDR of dual-stack nodes first,
+	// as in clusters whose primary cluster CIDR is IPv6.
+	IPv6PrimaryPodCIDR bool
+	// ClusterName is matched by the cluster selector of Networks shared by
+	// several clusters, see wellknown.ClusterSelectorAnnotationKey.
+	ClusterName string
+	// MaxAdditionalNetworks is the maximum number of additional networks
+	// published on a node. Zero disables the limit.
+	MaxAdditionalNetworks int
+	// NodeCoordinationLeases enables the coordination Lease of each node, see
+	// wellknown.PublishedAnnotationKey.
+	NodeCoordinationLeases bool
+	// NodeCleanupHooks enables the cleanup hooks of the networks of deleted
+	// nodes, see wellknown.NodeCleanupWebhookAnnotationKey. It requires
+	// NodeCoordinationLeases.
+	NodeCleanupHooks bool
+	// PredictiveAllocation publishes the networks a node is expected to join,
+	// predicted from its instance template, before its instance is visible,
+	// see wellknown.ExpectedNetworksAnnotationKey.
+	PredictiveAllocation bool
+	// NetworkRolloutNodesPerMinute is the rate at which the nodes are
+	// requeued after a Network is created or changed, see
+	// rolloutNetworkNodes. Zero requeues all the nodes at once.
+	NetworkRolloutNodesPerMinute int
+	// ComputeAPIVersion is the version of the compute API the instances of the
+	// nodes are read with, see ComputeAPIVersionBeta. The GA API is used if it
+	// is empty.
+	ComputeAPIVersion string
+	// DisableIPCapacity stops publishing the IP capacity of the additional
+	// networks in the node status. The capacity published before is removed.
+	DisableIPCapacity bool
+	// MaxAliasRangeMaskSize is the longest prefix length of the alias IP
+	// ranges of additional networks published on the nodes. Smaller ranges
+	// are skipped. Zero accepts ranges of any size.
+	MaxAliasRangeMaskSize int
+	// AnnotationEncoding is the encoding of the multi-network annotations of
+	// the nodes, see AnnotationEncodingCompact. The per-key annotations are
+	// published if it is empty.
+	AnnotationEncoding string
+	// LegacyAnnotations additionally publishes the multi-network annotations
+	// under their legacy keys, see
+	// wellknown.LegacyNorthInterfacesAnnotationKey.
+	LegacyAnnotations bool
+	// RequireReadyParams skips the additional networks whose
+	// GKENetworkParamSet is not Ready, see paramsReady.
+	RequireReadyParams bool
+	// NetworkClient is used to report conflicting Networks, see
+	// wellknown.NetworkConflictAnnotationKey. Conflicts are only logged if it
+	// is nil.
+	NetworkClient networkclientset.Interface
+	// NetworkConfig is the client config of the manager of the
+	// multi-network reconciler, which watches the Networks and
+	// GKENetworkParamSets. Their changes are not watched if it is nil.
+	NetworkConfig *restclient.Config
+	// StrandedPodCIDRThreshold is the time after which a node whose pod CIDR is
+	// not used by any pod is reported, see
+	// wellknown.StrandedSinceAnnotationKey. Zero disables the tracking.
+	StrandedPodCIDRThreshold time.Duration
+	// PodIPReconciliation enables the report of the pods whose IPs fall
+	// outside of the pod CIDRs of their node, see reconcilePodIPs.
+	PodIPReconciliation bool
+	// PodInformer tracks the pods using the pod CIDR of each node. It is
+	// required when StrandedPodCIDRThreshold or PodIPReconciliation is set.
+	PodInformer informers.PodInformer
+	// UpdateRetryTimeout is the time to wait before requeuing a failed node for the first retry.
+	UpdateRetryTimeout time.Duration
+	// MaxUpdateRetryTimeout is the maximum amount of time between retries.
+	MaxUpdateRetryTimeout time.Duration
+	// UpdateMaxRetries is the max retries for a failed node.
+	UpdateMaxRetries int
+	// FailureConditionThreshold is the number of consecutive failed updates
+	// after which wellknown.CIDRAllocationFailedCondition is set on the node.
+	// Zero disables it.
+	FailureConditionThreshold int
+	// AuditSink records the changes of the pod CIDRs, annotations and IP
+	// capacity of the nodes. The changes are not recorded if it is nil.
+	AuditSink audit.Sink
+	// PodRangeExemptions selects the nodes whose instance has no pod alias IP
+	// range on purpose, see skipPodRangeExemptNode.
+	PodRangeExemptions []PodRangeExemption
+	// CheckpointStore persists the pod CIDRs published on the nodes, so that
+	// a restarted allocator serves the nodes from it while it refreshes them
+	// from the compute API. The allocations are not persisted if it is nil.
+	CheckpointStore checkpoint.Store
+	// HealthPublisher publishes the health of the allocator in a
+	// NodeIPAMHealth object. The health is not published if it is nil.
+	HealthPublisher *health.Publisher
+	// EventQPS is the rate at which the events of each reason are recorded,
+	// see newRateLimitedRecorder. Zero disables the limit.
+	EventQPS float32
+	// EventBurst is the number of events of each reason recorded in a burst.
+	EventBurst int
+}
+
+// DefaultCloudAllocatorParams returns the cloud CIDR allocator parameters
+// used when none are configured.
+func DefaultCloudAllocatorParams() CloudAllocatorParams {
+	return CloudAllocatorParams{
+		EnableMultiNetworking:        true,
+		MaxAdditionalNetworks:        maxAdditionalNetworks,
+		NetworkRolloutNodesPerMinute: networkRolloutNodesPerMinute,
+		UpdateRetryTimeout:           updateRetryTimeout,
+		MaxUpdateRetryTimeout:        maxUpdateRetryTimeout,
+		UpdateMaxRetries:             updateMaxRetries,
+		FailureConditionThreshold:    failureConditionThreshold,
+		EventQPS:                     eventQPS,
+		EventBurst:                   eventBurst,
+	}
 }
 
 // New creates a new CIDR range allocator.
@@ -115,11 +262,12 @@ func New(kubeClient clientset.Interface, cloud cloudprovider.Interface, nodeInfo
 		return nil, err
 	}
 
+	recordAllocatorFeatures(allocatorType, allocatorParams)
 	switch allocatorType {
 	case RangeAllocatorType:
 		return NewCIDRRangeAllocator(kubeClient, nodeInformer, allocatorParams, nodeList)
 	case CloudAllocatorType:
-		return NewCloudCIDRAllocator(kubeClient, cloud, nwInformer, gnpInformer, nodeInformer)
+		return NewCloudCIDRAllocator(kubeClient, cloud, nwInformer, gnpInformer, nodeInformer, allocatorParams.Cloud)
 	default:
 		return nil, fmt.Errorf("invalid CIDR allocator type: %v", allocatorType)
 	}
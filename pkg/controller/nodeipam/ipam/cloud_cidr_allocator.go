@@ -20,24 +20,24 @@ limitations under the License.
 package ipam
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"math/rand"
 	"net"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
 	"k8s.io/klog/v2"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	informers "k8s.io/client-go/informers/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -48,20 +48,25 @@ import (
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	cloudprovider "k8s.io/cloud-provider"
 	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
 	networkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1"
 	alphanetworkinformer "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions/network/v1alpha1"
 	networklister "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1"
 	alphanetworklister "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/allocator"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/checkpoint"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/externalipam"
 	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
-	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
-	utiltaints "k8s.io/cloud-provider-gcp/pkg/util/taints"
 	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/utils/clock"
 	netutils "k8s.io/utils/net"
 )
 
-// nodeProcessingInfo tracks information related to current nodes in processing
-type nodeProcessingInfo struct {
-	retries int
+// cloudInstances is the part of the cloud used to read the instances of the
+// nodes. It is implemented by *gce.Cloud. The IPv6 pod CIDRs are read from the
+// interfaces of the instances, see gce.InterfaceIPv6PodCIDR.
+type cloudInstances interface {
+	InstanceByProviderID(providerID string) (*compute.Instance, error)
 }
 
 // cloudCIDRAllocator allocates node CIDRs according to IP address aliases
@@ -71,6 +76,11 @@ type nodeProcessingInfo struct {
 type cloudCIDRAllocator struct {
 	client clientset.Interface
 	cloud  *gce.Cloud
+	// instances reads the instances of the nodes. The cloud is used if it is nil.
+	instances cloudInstances
+	// clock stamps the conditions set on the nodes and schedules the retries
+	// of their failed updates. The real clock is used if it is nil.
+	clock clock.WithDelayedExecution
 	// networksLister is able to list/get networks and is populated by the shared network informer passed to
 	// NewCloudCIDRAllocator.
 	networksLister networklister.NetworkLister
@@ -82,29 +92,111 @@ type cloudCIDRAllocator struct {
 	nodeLister corelisters.NodeLister
 	// nodesSynced returns true if the node shared informer has been synced at least once.
 	nodesSynced cache.InformerSynced
-
-	// Channel that is used to pass updating Nodes to the background.
-	// This increases the throughput of CIDR assignment by parallelization
-	// and not blocking on long operations (which shouldn't be done from
-	// event handlers anyway).
-	nodeUpdateChannel chan string
-	recorder          record.EventRecorder
-
-	// Keep a set of nodes that are currectly being processed to avoid races in CIDR allocation
-	lock              sync.Mutex
-	nodesInProcessing map[string]*nodeProcessingInfo
+	// podIndexer holds the pods indexed by node, see trackPodCIDRUsage. It is
+	// nil if neither the stranded pod CIDRs nor the pod IPs are tracked.
+	podIndexer cache.Indexer
+	// podsSynced returns true if the pod shared informer has been synced at least once.
+	podsSynced cache.InformerSynced
+
+	// queue passes the nodes to update to the workers. This increases the
+	// throughput of CIDR assignment by parallelization and not blocking on
+	// long operations (which shouldn't be done from event handlers anyway).
+	// A node is queued at most once at a time to avoid races in CIDR
+	// allocation.
+	queue    *allocator.Queue
+	recorder record.EventRecorder
+
+	lock sync.Mutex
+	// foreignNodes is the set of nodes whose providerID is not a GCE instance.
+	foreignNodes map[string]bool
+	// limitedNetworks holds the comma separated, sorted additional networks
+	// ignored on each node, see limitAdditionalNetworks.
+	limitedNetworks map[string]string
+	// podRangeExemptNodes is the set of nodes skipped as they are exempted
+	// from having a pod alias IP range.
+	podRangeExemptNodes map[string]bool
+	// parkedNodes holds the nodes whose last update failed with a terminal
+	// error, with the network the error is about, see parkNode.
+	parkedNodes map[string]string
+	// priorPodCIDRs holds the pod CIDRs of the deleted nodes, see
+	// rememberPodCIDRs.
+	priorPodCIDRs map[string]priorPodCIDRs
+	// podCIDRIdleSince holds the time since which the pod CIDR of each node
+	// is not used by any pod, see reportStrandedPodCIDRs.
+	podCIDRIdleSince map[string]time.Time
+	// podIPsOutsidePodCIDRs is the set of pods whose IPs were outside of the
+	// pod CIDRs of their node at the last check, see reconcilePodIPs.
+	podIPsOutsidePodCIDRs map[types.UID]bool
+	// checkpoints persists the pod CIDRs published on the nodes. It is nil if
+	// the allocation checkpoints are disabled.
+	checkpoints *checkpoint.Checkpointer
+	// checkpointed holds the allocation checkpoints of the nodes served from
+	// them until they are refreshed, see servedFromCheckpoint.
+	checkpointed map[string]checkpoint.Entry
+	// lastFullResync is the last time every node was up to date, see
+	// recordFullResync.
+	lastFullResync metav1.Time
+	// healthUpdates and healthFailedUpdates count the node updates since the
+	// health of the allocator was last published.
+	healthUpdates       atomic.Int64
+	healthFailedUpdates atomic.Int64
+	// leaseDigests holds the multi-network state digest and cleanup networks
+	// last recorded in the coordination Lease of each node, see
+	// publishNodeCoordinationLease.
+	leaseDigests map[string]string
+	// peeringCache holds the VPCs peered with the VPCs of the node interfaces.
+	peeringCache map[string]peeringCacheEntry
+	// getNetwork fetches a VPC. The compute API is used if it is nil.
+	getNetwork func(project, name string) (*compute.Network, error)
+	// instanceGroupsCache holds the instance templates of the managed instance
+	// groups per zone, see predictedInterfaces.
+	instanceGroupsCache map[string]instanceGroupsCacheEntry
+	// templateInterfaces holds the network interfaces per instance template.
+	templateInterfaces map[string][]*compute.NetworkInterface
+	// listInstanceGroups lists the managed instance groups of a zone. The
+	// compute API is used if it is nil.
+	listInstanceGroups func(project, zone string) ([]*compute.InstanceGroupManager, error)
+	// getInstanceTemplate fetches a global instance template. The compute API
+	// is used if it is nil.
+	getInstanceTemplate func(project, name string) (*compute.InstanceTemplate, error)
+	// externalAllocators holds the clients of the external IPAM providers per endpoint.
+	externalAllocators map[string]externalipam.ExternalAllocatorClient
+
+	// params holds the multi-networking and retry settings of the allocator.
+	params CloudAllocatorParams
+	// networkEvents holds the warnings about the nodes of the networks not yet
+	// recorded on the Networks, see recordNetworkEvent.
+	networkEventsLock sync.Mutex
+	networkEvents     map[networkEventKey]*networkEventAggregate
+	// rollouts holds the nodes not yet requeued after a change of each
+	// network, and rolloutOrder the networks in the order they take turns,
+	// see rolloutNetworkNodes.
+	rolloutLock  sync.Mutex
+	rollouts     map[string]*networkRollout
+	rolloutOrder []string
+	// started is the time the allocator was created. The Networks created
+	// before are not new, see networkPredicate.
+	started time.Time
+	// multiNetwork requeues the nodes after the changes of the Networks and
+	// GKENetworkParamSets if multi-networking is enabled.
+	multiNetwork *multiNetworkReconciler
+	// annotationCache holds the last serialized multi-network annotations per node.
+	annotationCache multiNetworkAnnotationCache
+	// networkCRDsMissing is set when discovery reports that the multi-network CRDs are
+	// not installed, in which case only the default network is allocated.
+	networkCRDsMissing atomic.Bool
 }
 
 var _ CIDRAllocator = (*cloudCIDRAllocator)(nil)
 
 // NewCloudCIDRAllocator creates a new cloud CIDR allocator.
-func NewCloudCIDRAllocator(client clientset.Interface, cloud cloudprovider.Interface, nwInformer networkinformer.NetworkInformer, gnpInformer alphanetworkinformer.GKENetworkParamSetInformer, nodeInformer informers.NodeInformer) (CIDRAllocator, error) {
+func NewCloudCIDRAllocator(client clientset.Interface, cloud cloudprovider.Interface, nwInformer networkinformer.NetworkInformer, gnpInformer alphanetworkinformer.GKENetworkParamSetInformer, nodeInformer informers.NodeInformer, params CloudAllocatorParams) (CIDRAllocator, error) {
 	if client == nil {
 		klog.Fatalf("kubeClient is nil when starting NodeController")
 	}
 
 	eventBroadcaster := record.NewBroadcaster()
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cidrAllocator"})
+	recorder := newRateLimitedRecorder(eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cidrAllocator"}), params.EventQPS, params.EventBurst)
 	eventBroadcaster.StartStructuredLogging(0)
 	klog.V(0).Infof("Sending events to api server.")
 	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: client.CoreV1().Events("")})
@@ -114,146 +206,253 @@ func NewCloudCIDRAllocator(client clientset.Interface, cloud cloudprovider.Inter
 		err := fmt.Errorf("cloudCIDRAllocator does not support %v provider", cloud.ProviderName())
 		return nil, err
 	}
+	if err := validateShadowAllocator(params.ShadowAllocator); err != nil {
+		return nil, err
+	}
+	if err := validateComputeAPIVersion(params.ComputeAPIVersion); err != nil {
+		return nil, err
+	}
+	if err := validateMaxAliasRangeMaskSize(params.MaxAliasRangeMaskSize); err != nil {
+		return nil, err
+	}
+	if err := validateAnnotationEncoding(params.AnnotationEncoding, params.NodeLocalIPAM); err != nil {
+		return nil, err
+	}
+	if params.LegacyAnnotations && params.NodeLocalIPAM {
+		return nil, fmt.Errorf("legacy annotations are not supported with node-local IPAM")
+	}
+	if params.NodeCleanupHooks && !params.NodeCoordinationLeases {
+		return nil, fmt.Errorf("node cleanup hooks require the node coordination leases")
+	}
 	ca := &cloudCIDRAllocator{
-		client:            client,
-		cloud:             gceCloud,
-		networksLister:    nwInformer.Lister(),
-		gnpLister:         gnpInformer.Lister(),
-		nodeLister:        nodeInformer.Lister(),
-		nodesSynced:       nodeInformer.Informer().HasSynced,
-		nodeUpdateChannel: make(chan string, cidrUpdateQueueSize),
-		recorder:          recorder,
-		nodesInProcessing: map[string]*nodeProcessingInfo{},
+		client:         client,
+		cloud:          gceCloud,
+		instances:      newCloudInstances(gceCloud, params.ComputeAPIVersion),
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		nodeLister:     nodeInformer.Lister(),
+		nodesSynced:    nodeInformer.Informer().HasSynced,
+		recorder:       recorder,
+		params:         params,
+		started:        time.Now(),
+	}
+	ca.queue = ca.newQueue()
+	if params.CheckpointStore != nil {
+		ca.checkpoints = checkpoint.New(params.CheckpointStore)
+	}
+
+	if params.StrandedPodCIDRThreshold > 0 || params.PodIPReconciliation {
+		if params.PodInformer == nil {
+			return nil, fmt.Errorf("tracking the pod CIDR usage requires a pod informer")
+		}
+		if err := ca.trackPodCIDRUsage(params.PodInformer); err != nil {
+			return nil, err
+		}
 	}
 
 	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: nodeutil.CreateAddNodeHandler(ca.AllocateOrOccupyCIDR),
-		UpdateFunc: nodeutil.CreateUpdateNodeHandler(func(_, newNode *v1.Node) error {
+		UpdateFunc: nodeutil.CreateUpdateNodeHandler(func(oldNode, newNode *v1.Node) error {
+			// Nodes served from their allocation checkpoint are refreshed as
+			// soon as they may have been reallocated.
+			if oldNode.Status.NodeInfo.BootID != newNode.Status.NodeInfo.BootID || nodeInputsChanged(oldNode, newNode) {
+				ca.refreshCheckpointedNode(newNode.Name)
+			}
+			// Nodes parked on a terminal error wait for their inputs to change.
+			if ca.nodeParked(newNode.Name) && !nodeInputsChanged(oldNode, newNode) {
+				return nil
+			}
 			if newNode.Spec.PodCIDR == "" {
 				return ca.AllocateOrOccupyCIDR(newNode)
 			}
+			// Reservation requests are satisfied on the next update of the node.
+			if params.EnableMultiNetworking && hasPendingInterfaceReservations(newNode) {
+				return ca.AllocateOrOccupyCIDR(newNode)
+			}
+			// Pinned CIDRs are published as soon as operators set them.
+			if params.EnableMultiNetworking && oldNode.Annotations[wellknown.PinnedNetworksAnnotationKey] != newNode.Annotations[wellknown.PinnedNetworksAnnotationKey] {
+				return ca.AllocateOrOccupyCIDR(newNode)
+			}
+			// A new boot ID reveals a reboot or a recreation of the instance.
+			if oldNode.Status.NodeInfo.BootID != newNode.Status.NodeInfo.BootID {
+				return ca.AllocateOrOccupyCIDR(newNode)
+			}
+			// The node selectors of the Networks are matched against the labels of the node.
+			if params.EnableMultiNetworking && !labels.Equals(oldNode.Labels, newNode.Labels) {
+				return ca.AllocateOrOccupyCIDR(newNode)
+			}
 			// Even if PodCIDR is assigned, but NetworkUnavailable condition is
 			// set to true, we need to process the node to set the condition.
-			networkUnavailableTaint := &v1.Taint{Key: v1.TaintNodeNetworkUnavailable, Effect: v1.TaintEffectNoSchedule}
-			_, cond := nodeutil.GetNodeCondition(&newNode.Status, v1.NodeNetworkUnavailable)
-			if cond == nil || cond.Status != v1.ConditionFalse || utiltaints.TaintExists(newNode.Spec.Taints, networkUnavailableTaint) {
+			if allocator.NetworkUnavailable(newNode) {
 				return ca.AllocateOrOccupyCIDR(newNode)
 			}
 			return nil
 		}),
 		DeleteFunc: nodeutil.CreateDeleteNodeHandler(ca.ReleaseCIDR),
 	})
+	gceCloud.AddInstanceChangeHandler(ca.requeueChangedInstanceNode)
+	if params.EnableMultiNetworking {
+		ca.multiNetwork = newMultiNetworkReconciler(ca)
+	}
+
+	registerCloudAllocatorMetrics()
 
 	klog.V(0).Infof("Using cloud CIDR allocator (provider: %v)", cloud.ProviderName())
 	return ca, nil
 }
 
+// computeInstances returns the reader of the instances of the nodes.
+func (ca *cloudCIDRAllocator) computeInstances() cloudInstances {
+	if ca.instances != nil {
+		return ca.instances
+	}
+	return ca.cloud
+}
+
+// now returns the current time of the clock of the allocator.
+func (ca *cloudCIDRAllocator) now() metav1.Time {
+	if ca.clock != nil {
+		return metav1.NewTime(ca.clock.Now())
+	}
+	return metav1.Now()
+}
+
 func (ca *cloudCIDRAllocator) Run(stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 
 	klog.Infof("Starting cloud CIDR allocator")
 	defer klog.Infof("Shutting down cloud CIDR allocator")
 
-	if !cache.WaitForNamedCacheSync("cidrallocator", stopCh, ca.nodesSynced) {
+	cacheSyncs := []cache.InformerSynced{ca.nodesSynced}
+	if ca.podsSynced != nil {
+		cacheSyncs = append(cacheSyncs, ca.podsSynced)
+	}
+	if !cache.WaitForNamedCacheSync("cidrallocator", stopCh, cacheSyncs...) {
 		return
 	}
 
-	for i := 0; i < cidrUpdateWorkers; i++ {
-		go ca.worker(stopCh)
+	if ca.params.EnableMultiNetworking {
+		// Check synchronously first so that the workers start with the right allocation path.
+		ca.syncNetworkCRDs()
+		go wait.Until(ca.syncNetworkCRDs, networkCRDDiscoveryInterval, stopCh)
+		go wait.Until(ca.flushNetworkEvents, networkEventFlushInterval, stopCh)
+		if ca.params.NetworkConfig != nil {
+			go wait.Until(func() { ca.runMultiNetworkController(stopCh) }, networkCRDDiscoveryInterval, stopCh)
+		}
+		if ca.params.NetworkRolloutNodesPerMinute > 0 {
+			go wait.Until(ca.advanceNetworkRollouts, ca.networkRolloutInterval(), stopCh)
+		}
+	}
+	if ca.params.NodeCleanupHooks {
+		ca.resumeNodeCleanups()
+	}
+	if ca.params.StrandedPodCIDRThreshold > 0 {
+		go wait.Until(ca.reportStrandedPodCIDRs, strandedPodCIDRCheckInterval, stopCh)
+	}
+	if ca.params.PodIPReconciliation {
+		go wait.Until(ca.reconcilePodIPs, podIPReconciliationInterval, stopCh)
+	}
+	if ca.checkpoints != nil {
+		// Load synchronously first so that the workers serve the nodes from
+		// their checkpoint.
+		ca.loadCheckpoints()
+		go wait.Until(ca.flushCheckpoints, checkpointFlushInterval, stopCh)
+		go wait.Until(ca.refreshCheckpointedNodes, checkpointRefreshInterval, stopCh)
+	}
+	if ca.params.HealthPublisher != nil {
+		go wait.Until(ca.publishHealth, healthPublishInterval, stopCh)
 	}
 
-	<-stopCh
+	ca.queue.Resume()
+	ca.queueNodes()
+	ca.queue.Run(cidrUpdateWorkers, stopCh)
 }
 
-func (ca *cloudCIDRAllocator) worker(stopChan <-chan struct{}) {
-	for {
-		select {
-		case workItem, ok := <-ca.nodeUpdateChannel:
-			if !ok {
-				klog.Warning("Channel nodeCIDRUpdateChannel was unexpectedly closed")
-				return
-			}
-			if err := ca.updateCIDRAllocation(workItem); err == nil {
-				klog.V(3).Infof("Updated CIDR for %q", workItem)
-			} else {
-				klog.Errorf("Error updating CIDR for %q: %v", workItem, err)
-				if canRetry, timeout := ca.retryParams(workItem); canRetry {
-					klog.V(2).Infof("Retrying update for %q after %v", workItem, timeout)
-					time.AfterFunc(timeout, func() {
-						// Requeue the failed node for update again.
-						ca.nodeUpdateChannel <- workItem
-					})
-					continue
-				}
-				klog.Errorf("Exceeded retry count for %q, dropping from queue", workItem)
-			}
-			ca.removeNodeFromProcessing(workItem)
-		case <-stopChan:
-			return
-		}
-	}
+// newQueue returns the work queue of the allocator, retrying the failed node
+// updates with the backoff of the params. The queue is paused until the
+// allocator runs, so that the event handlers do not fill it while no worker
+// reads it, e.g. while another allocator owns the pod CIDRs.
+func (ca *cloudCIDRAllocator) newQueue() *allocator.Queue {
+	q := allocator.NewQueue(ca.params.UpdateRetryTimeout, ca.params.MaxUpdateRetryTimeout, ca.params.UpdateMaxRetries, ca.processNode)
+	if ca.clock != nil {
+		q.Clock = ca.clock
+	}
+	q.Retrying = ca.persistRetries
+	q.Removed = ca.removedFromQueue
+	q.Pause()
+	return q
 }
 
-func (ca *cloudCIDRAllocator) insertNodeToProcessing(nodeName string) bool {
-	ca.lock.Lock()
-	defer ca.lock.Unlock()
-	if _, found := ca.nodesInProcessing[nodeName]; found {
-		return false
+// queueNodes queues all the nodes, as their events were dropped while the
+// allocator was not running.
+func (ca *cloudCIDRAllocator) queueNodes() {
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list the nodes to allocate: %v", err)
+		return
+	}
+	for _, node := range nodes {
+		ca.AllocateOrOccupyCIDR(node)
 	}
-	ca.nodesInProcessing[nodeName] = &nodeProcessingInfo{}
-	return true
 }
 
-func (ca *cloudCIDRAllocator) retryParams(nodeName string) (bool, time.Duration) {
-	ca.lock.Lock()
-	defer ca.lock.Unlock()
-
-	entry, ok := ca.nodesInProcessing[nodeName]
-	if !ok {
-		klog.Errorf("Cannot get retryParams for %q as entry does not exist", nodeName)
-		return false, 0
+// processNode updates the node taken from the work queue. Nodes failing with
+// an error that is not retried are dropped from the queue; nodes parked on a
+// terminal error are updated again as soon as their inputs change.
+func (ca *cloudCIDRAllocator) processNode(nodeName string) error {
+	err := ca.updateCIDRAllocation(nodeName)
+	ca.recordUpdateResult(err)
+	if err == nil {
+		allocationRetries.Observe(float64(ca.failureCount(nodeName) - 1))
+		ca.clearAllocationFailure(nodeName)
+		ca.clearPersistedRetries(nodeName)
+		ca.unparkNode(nodeName)
+		return nil
 	}
-
-	count := entry.retries + 1
-	if count > updateMaxRetries {
-		return false, 0
+	ca.recordAllocationError(nodeName, err)
+	ca.reportAllocationFailure(nodeName, ca.failureCount(nodeName), err)
+	if terminalError(err) {
+		klog.Errorf("Not retrying update for %q until its inputs change: %v", nodeName, errorReason(err))
+		ca.parkNode(nodeName, err)
+		ca.clearPersistedRetries(nodeName)
+		return allocator.NoRetry(err)
 	}
-	ca.nodesInProcessing[nodeName].retries = count
-
-	return true, nodeUpdateRetryTimeout(count)
+	if !retriableError(err) {
+		klog.Errorf("Not retrying update for %q: %v", nodeName, errorReason(err))
+		return allocator.NoRetry(err)
+	}
+	return err
 }
 
-func nodeUpdateRetryTimeout(count int) time.Duration {
-	timeout := updateRetryTimeout
-	for i := 0; i < count && timeout < maxUpdateRetryTimeout; i++ {
-		timeout *= 2
-	}
-	if timeout > maxUpdateRetryTimeout {
-		timeout = maxUpdateRetryTimeout
+// queueNode puts the node into the work queue, after the backoff of its next
+// retry if its updates already failed retries times. It returns false if the
+// node is already queued.
+func (ca *cloudCIDRAllocator) queueNode(nodeName string, retries int) bool {
+	if !ca.queue.AddWithRetries(nodeName, retries) {
+		return false
 	}
-	return time.Duration(timeout.Nanoseconds()/2 + rand.Int63n(timeout.Nanoseconds()))
+	pendingNodes.Set(float64(ca.queue.Len()))
+	return true
 }
 
-func (ca *cloudCIDRAllocator) removeNodeFromProcessing(nodeName string) {
+// removedFromQueue is called when a node leaves the work queue.
+func (ca *cloudCIDRAllocator) removedFromQueue(nodeName string) {
+	pendingNodes.Set(float64(ca.queue.Len()))
 	ca.lock.Lock()
 	defer ca.lock.Unlock()
-	delete(ca.nodesInProcessing, nodeName)
+	ca.recordFullResync()
 }
 
-// WARNING: If you're adding any return calls or defer any more work from this
-// function you have to make sure to update nodesInProcessing properly with the
-// disposition of the node when the work is done.
 func (ca *cloudCIDRAllocator) AllocateOrOccupyCIDR(node *v1.Node) error {
 	if node == nil {
 		return nil
 	}
-	if !ca.insertNodeToProcessing(node.Name) {
-		klog.V(2).InfoS("Node is already in a process of CIDR assignment", "node", klog.KObj(node))
+	// Failing nodes keep backing off across restarts.
+	if !ca.queueNode(node.Name, ca.restoredRetries(node)) {
+		klog.V(2).InfoS("Node is already in a process of CIDR assignment, or the allocator is not running", "node", klog.KObj(node))
 		return nil
 	}
-
 	klog.V(4).Infof("Putting node %s into the work queue", node.Name)
-	ca.nodeUpdateChannel <- node.Name
 	return nil
 }
 
@@ -268,45 +467,90 @@ func (ca *cloudCIDRAllocator) updateCIDRAllocation(nodeName string) error {
 		return err
 	}
 	if node.Spec.ProviderID == "" {
-		return fmt.Errorf("node %s doesn't have providerID", nodeName)
+		return allocationErrorf(ErrMissingProviderID, "node %s doesn't have providerID", nodeName)
+	}
+	if isMalformedGCEProviderID(node.Spec.ProviderID) {
+		return allocationErrorf(ErrMalformedProviderID, "node %s has malformed providerID %q", nodeName, node.Spec.ProviderID)
+	}
+	if !isGCEProviderID(node.Spec.ProviderID) {
+		ca.skipForeignNode(node)
+		return nil
 	}
-	instance, err := ca.cloud.InstanceByProviderID(node.Spec.ProviderID)
+	if ca.servedFromCheckpoint(node) {
+		return nil
+	}
+	instance, err := ca.computeInstances().InstanceByProviderID(node.Spec.ProviderID)
 	if err != nil {
+		ca.publishExpectedNetworks(node)
 		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRNotAvailable")
-		return fmt.Errorf("failed to get instance from provider: %v", err)
+		return instanceError(err)
 	}
+	recreated := ca.handleInstanceRecreation(node, instance)
 
 	cidrStrings := make([]string, 0)
 	var northInterfaces networkv1.NorthInterfacesAnnotation
 	var additionalNodeNetworks networkv1.MultiNetworkAnnotation
+	var delegatedRanges DelegatedRangesAnnotation
 
 	if len(instance.NetworkInterfaces) == 0 || (len(instance.NetworkInterfaces) == 1 && len(instance.NetworkInterfaces[0].AliasIpRanges) == 0) {
+		if ca.skipPodRangeExemptNode(node) {
+			return nil
+		}
+		ca.publishExpectedNetworks(node)
 		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRNotAvailable")
-		return fmt.Errorf("failed to allocate cidr: Node %v has no ranges from which CIDRs can be allocated", node.Name)
+		return allocationErrorf(ErrNoMatchingRange, "failed to allocate cidr: Node %v has no ranges from which CIDRs can be allocated", node.Name)
 	}
 	// nodes in clusters WITHOUT multi-networking are expected to have only 1 network-interface with 1 alias IP range.
-	if len(instance.NetworkInterfaces) == 1 && len(instance.NetworkInterfaces[0].AliasIpRanges) == 1 {
+	// When multi-networking is disabled, only the first alias IP range of the first interface is considered.
+	if !ca.multiNetworkEnabled() || (len(instance.NetworkInterfaces) == 1 && len(instance.NetworkInterfaces[0].AliasIpRanges) == 1) {
+		if len(instance.NetworkInterfaces[0].AliasIpRanges) == 0 {
+			if ca.skipPodRangeExemptNode(node) {
+				return nil
+			}
+			nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRNotAvailable")
+			return allocationErrorf(ErrNoMatchingRange, "failed to allocate cidr: Node %v has no alias IP ranges on its first interface", node.Name)
+		}
 		cidrStrings = append(cidrStrings, instance.NetworkInterfaces[0].AliasIpRanges[0].IpCidrRange)
-		ipv6Addr := ca.cloud.GetIPV6Address(instance.NetworkInterfaces[0])
+		ipv6Addr := gce.InterfaceIPv6PodCIDR(instance.NetworkInterfaces[0])
 		if ipv6Addr != nil {
 			cidrStrings = append(cidrStrings, ipv6Addr.String())
 		}
 	} else {
 		// multi-networking enabled clusters
-		cidrStrings, northInterfaces, additionalNodeNetworks, err = ca.PerformMultiNetworkCIDRAllocation(node, instance.NetworkInterfaces)
+		cidrStrings, northInterfaces, additionalNodeNetworks, delegatedRanges, err = ca.PerformMultiNetworkCIDRAllocation(node, instance.NetworkInterfaces)
+		if ca.params.ShadowAllocator != "" {
+			ca.runShadowAllocation(node, instance.NetworkInterfaces, multiNetworkAllocation{
+				DefaultNwCIDRs:         cidrStrings,
+				NorthInterfaces:        northInterfaces,
+				AdditionalNodeNetworks: additionalNodeNetworks,
+				DelegatedRanges:        delegatedRanges,
+			}, err)
+		}
 		if err != nil {
 			nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRNotAvailable")
-			return fmt.Errorf("failed to get cidr(s) from provider: %v", err)
+			return fmt.Errorf("failed to get cidr(s) from provider: %w", err)
 		}
-	}
+		ca.reportPeeredVPCs(node, instance.NetworkInterfaces)
+		limited := ca.limitAdditionalNetworks(node, ca.dedupeSliceRanges(node, multiNetworkAllocation{
+			NorthInterfaces:        northInterfaces,
+			AdditionalNodeNetworks: additionalNodeNetworks,
+			DelegatedRanges:        delegatedRanges,
+		}))
+		limited = ca.pinAdditionalNetworks(node, limited)
+		limited = ca.holdPausedNetworks(node, limited)
+		northInterfaces, additionalNodeNetworks, delegatedRanges = limited.NorthInterfaces, limited.AdditionalNodeNetworks, limited.DelegatedRanges
+		northInterfaces = ca.withIPv6Addresses(northInterfaces, instance.NetworkInterfaces)
+		additionalNodeNetworks = ca.withTrafficClasses(node, additionalNodeNetworks)
+		additionalNodeNetworks = ca.withNodeGateways(node, additionalNodeNetworks)
+	}
+	// Can have at most 2 ips (one for v4 and one for v6), in a stable order.
+	cidrStrings = ca.preferPriorPodCIDRs(node, instance, ca.canonicalPodCIDRs(cidrStrings))
 	if len(cidrStrings) == 0 {
+		if ca.skipPodRangeExemptNode(node) {
+			return nil
+		}
 		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRNotAvailable")
-		return fmt.Errorf("failed to allocate cidr: Node %v has no CIDRs", node.Name)
-	}
-	//Can have at most 2 ips (one for v4 and one for v6)
-	if len(cidrStrings) > 2 {
-		klog.InfoS("Got more than 2 ips, truncating to 2", "cidrStrings", cidrStrings)
-		cidrStrings = cidrStrings[:2]
+		return allocationErrorf(ErrNoMatchingRange, "failed to allocate cidr: Node %v has no CIDRs", node.Name)
 	}
 
 	cidrs, err := netutils.ParseCIDRs(cidrStrings)
@@ -318,8 +562,9 @@ func (ca *cloudCIDRAllocator) updateCIDRAllocation(nodeName string) error {
 	if err != nil {
 		return fmt.Errorf("err: %v, CIDRS: %v", err, cidrStrings)
 	}
+	var podCIDRs []string
 	if needUpdate {
-		if node.Spec.PodCIDR != "" {
+		if node.Spec.PodCIDR != "" && !recreated {
 			klog.ErrorS(nil, "PodCIDR being reassigned!", "nodeName", node.Name, "node.Spec.PodCIDRs", node.Spec.PodCIDRs, "cidrStrings", cidrStrings)
 			// We fall through and set the CIDR despite this error. This
 			// implements the same logic as implemented in the
@@ -327,35 +572,36 @@ func (ca *cloudCIDRAllocator) updateCIDRAllocation(nodeName string) error {
 			//
 			// See https://github.com/kubernetes/kubernetes/pull/42147#discussion_r103357248
 		}
-		for i := 0; i < cidrUpdateRetries; i++ {
-			if err = utilnode.PatchNodeCIDRs(ca.client, types.NodeName(node.Name), cidrStrings); err == nil {
-				klog.InfoS("Set the node PodCIDRs", "nodeName", node.Name, "cidrStrings", cidrStrings)
-				break
-			}
-		}
+		podCIDRs = cidrStrings
+	}
+	// The pod CIDRs are published along with the multi-network annotations.
+	if err := ca.reconcileMultiNetwork(node, podCIDRs, multiNetworkAllocation{
+		NorthInterfaces:        northInterfaces,
+		AdditionalNodeNetworks: additionalNodeNetworks,
+		DelegatedRanges:        delegatedRanges,
+	}); err != nil {
+		return err
 	}
-	if err != nil {
-		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRAssignmentFailed")
-		klog.ErrorS(err, "Failed to update the node PodCIDR after multiple attempts", "nodeName", node.Name, "cidrStrings", cidrStrings)
+	if node.Spec.PodCIDR == "" && len(podCIDRs) > 0 {
+		podCIDRAssignmentLatency.Observe(ca.now().Sub(node.CreationTimestamp.Time).Seconds())
+	}
+	if err := ca.clearExpectedNetworks(node); err != nil {
 		return err
 	}
-
-	if northInterfaces != nil || additionalNodeNetworks != nil {
-		if err := ca.updateMultiNetworkAnnotations(node, northInterfaces, additionalNodeNetworks); err != nil {
-			return err
-		}
+	if err := ca.recordInstanceID(node, instance); err != nil {
+		return err
 	}
-	err = utilnode.SetNodeCondition(ca.client, types.NodeName(node.Name), v1.NodeCondition{
-		Type:               v1.NodeNetworkUnavailable,
-		Status:             v1.ConditionFalse,
-		Reason:             "RouteCreated",
-		Message:            "NodeController create implicit route",
-		LastTransitionTime: metav1.Now(),
-	})
-	if err != nil {
-		klog.ErrorS(err, "Error setting route status for the node", "nodeName", node.Name)
+	if err := ca.updateNetworkPerformanceLabel(node, instance); err != nil {
+		return err
 	}
-	return err
+	if err := allocator.MarkNetworkAvailableAt(ca.client, node.Name, ca.now()); err != nil {
+		return err
+	}
+	if podCIDRs == nil {
+		podCIDRs = node.Spec.PodCIDRs
+	}
+	ca.recordCheckpoint(node, podCIDRs)
+	return nil
 }
 
 func needPodCIDRsUpdate(node *v1.Node, podCIDRs []*net.IPNet) (bool, error) {
@@ -384,8 +630,17 @@ func needPodCIDRsUpdate(node *v1.Node, podCIDRs []*net.IPNet) (bool, error) {
 		if dualStack, _ := netutils.IsDualStackCIDRs(podCIDRs); !dualStack {
 			return false, fmt.Errorf("IPs are not dual stack")
 		}
-		for idx, cidr := range podCIDRs {
-			if !cmp.Equal(nodePodCIDRs[idx], cidr) {
+		// The pod CIDRs of a node cannot change once set, only compare them
+		// as sets so that a node written in another order is not updated.
+		for _, cidr := range podCIDRs {
+			found := false
+			for _, nodePodCIDR := range nodePodCIDRs {
+				if cmp.Equal(nodePodCIDR, cidr) {
+					found = true
+					break
+				}
+			}
+			if !found {
 				return true, nil
 			}
 		}
@@ -399,79 +654,93 @@ func needPodCIDRsUpdate(node *v1.Node, podCIDRs []*net.IPNet) (bool, error) {
 func (ca *cloudCIDRAllocator) ReleaseCIDR(node *v1.Node) error {
 	klog.V(2).Infof("Node %v PodCIDR (%v) will be released by external cloud provider (not managed by controller)",
 		node.Name, node.Spec.PodCIDR)
+	ca.annotationCache.forget(node.Name)
+	ca.forgetForeignNode(node.Name)
+	ca.forgetPodRangeExemptNode(node.Name)
+	ca.forgetLimitedNetworks(node.Name)
+	ca.forgetCheckpoint(node.Name)
+	ca.unparkNode(node.Name)
+	ca.forgetNodeCoordinationLease(node.Name)
+	ca.rememberPodCIDRs(node)
+	ca.releaseExternalRanges(node)
+	if ca.params.NodeCleanupHooks {
+		go ca.cleanupNode(node.Name)
+	}
 	return nil
 }
 
-func (ca *cloudCIDRAllocator) updateMultiNetworkAnnotations(node *v1.Node, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation) error {
-	northInterfaceAnn, err := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+// updateMultiNetworkAnnotations publishes the pod CIDRs, if not nil, along with
+// the multi-networking annotations and IP capacity of the node.
+func (ca *cloudCIDRAllocator) updateMultiNetworkAnnotations(node *v1.Node, podCIDRs []string, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation) error {
+	update := nodeUpdate{PodCIDRs: podCIDRs}
+	reservations, reservationsChanged, err := ca.reconcileInterfaceReservations(node, additionalNodeNetworks)
 	if err != nil {
-		klog.ErrorS(err, "Failed to marshal the north interfaces annotation for multi-networking", "nodeName", node.Name)
 		return err
 	}
-	additionalNodeNwAnn, err := networkv1.MarshalAnnotation(additionalNodeNetworks)
-	if err != nil {
-		klog.ErrorS(err, "Failed to marshal the additional node networks annotation for multi-networking", "nodeName", node.Name)
-		return err
+	if reservationsChanged {
+		update.Annotations = map[string]string{wellknown.InterfaceReservationsAnnotationKey: reservations}
 	}
-	if node.Annotations == nil {
-		node.Annotations = make(map[string]string)
-	}
-	node.Annotations[networkv1.NorthInterfacesAnnotationKey] = northInterfaceAnn
-	node.Annotations[networkv1.MultiNetworkAnnotationKey] = additionalNodeNwAnn
-	node.Status.Capacity, err = allocateIPCapacity(node, additionalNodeNetworks)
-	if err != nil {
-		return err
+	annotationsUpToDate := ca.annotationEncodingUpToDate(node) && ca.annotationCache.upToDate(node, northInterfaces, additionalNodeNetworks) && ca.legacyAnnotationsUpToDate(node)
+	capacityNetworks := additionalNodeNetworks
+	if ca.params.DisableIPCapacity {
+		// The IP capacity published before it was disabled is removed.
+		capacityNetworks = nil
 	}
-	// Prepare patch bytes for the node update.
-	patchBytes, err := json.Marshal([]interface{}{
-		map[string]interface{}{
-			"op":    "replace",
-			"path":  "/metadata/annotations",
-			"value": node.Annotations,
-		},
-		map[string]interface{}{
-			"op":    "add",
-			"path":  "/status/capacity",
-			"value": node.Status.Capacity,
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to build patch bytes for multi-networking: %v", err)
+	capacityUpToDate := ipCapacityUpToDate(node, capacityNetworks, ca.ipResourceName)
+	if annotationsUpToDate && capacityUpToDate {
+		klog.V(4).InfoS("Multi-network annotations and capacity are up to date", "nodeName", node.Name)
+		return ca.publishNodeUpdate(node, update)
 	}
 	// Since dynamic network addition/deletion is a use case to be supported, we aspire to build these annotations and IP capacities every time from scratch.
-	// Hence we are using a JSON patch merge strategy instead of strategic merge strategy on the node during update.
-	if _, err = ca.client.CoreV1().Nodes().Patch(context.TODO(), node.Name, types.JSONPatchType, patchBytes, metav1.PatchOptions{}, "status"); err != nil {
-		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRAssignmentFailed")
-		klog.ErrorS(err, "Failed to update the node annotations and capacity for multi-networking", "nodeName", node.Name)
-		return err
-	}
-	return nil
-}
-
-// allocateIPCapacity updates the extended IP resource capacity for every non-default network on the node.
-func allocateIPCapacity(node *v1.Node, nodeNetworks networkv1.MultiNetworkAnnotation) (v1.ResourceList, error) {
-	resourceList := node.Status.Capacity
-	if resourceList == nil {
-		resourceList = make(v1.ResourceList)
+	if !annotationsUpToDate {
+		northInterfaceAnn, additionalNodeNwAnn, err := ca.annotationCache.marshal(node.Name, northInterfaces, additionalNodeNetworks)
+		if err != nil {
+			klog.ErrorS(err, "Failed to marshal the multi-networking annotations", "nodeName", node.Name)
+			return err
+		}
+		if err := ca.encodeMultiNetworkAnnotations(node, &update, northInterfaceAnn, additionalNodeNwAnn); err != nil {
+			klog.ErrorS(err, "Failed to encode the multi-networking annotations", "nodeName", node.Name)
+			return err
+		}
 	}
-	// Rebuild the IP capacity for all the networks on the node by deleting the existing IP capacities first.
-	for name := range resourceList {
-		if strings.HasPrefix(name.String(), networkv1.NetworkResourceKeyPrefix) {
-			delete(resourceList, name)
+	if !capacityUpToDate {
+		if update.IPCapacity, err = networkIPCapacities(capacityNetworks, ca.ipResourceName); err != nil {
+			return err
 		}
 	}
+	return ca.publishNodeUpdate(node, update)
+}
+
+// networkIPCapacities returns the extended IP resource capacity of every non-default network on the node,
+// under the resource names returned by resourceName.
+func networkIPCapacities(nodeNetworks networkv1.MultiNetworkAnnotation, resourceName func(network string) v1.ResourceName) (v1.ResourceList, error) {
+	resourceList := make(v1.ResourceList, len(nodeNetworks))
 	for _, nw := range nodeNetworks {
-		_, ipNet, err := net.ParseCIDR(nw.Cidrs[0])
+		ipCount, err := networkIPCapacity(nw)
 		if err != nil {
 			return nil, err
 		}
-		var ipCount int64 = 1
-		size := netutils.RangeSize(ipNet)
-		if size > 1 {
-			// The number of IPs supported are halved and returned for overprovisioning purposes.
-			ipCount = size >> 1
-		}
-		resourceList[v1.ResourceName(networkv1.NetworkResourceKeyPrefix+nw.Name+".IP")] = *resource.NewQuantity(int64(ipCount), resource.DecimalSI)
+		resourceList[resourceName(nw.Name)] = *resource.NewQuantity(ipCount, resource.DecimalSI)
 	}
 	return resourceList, nil
 }
+
+// networkIPCapacity returns the number of pod IPs advertised for a network on the node.
+func networkIPCapacity(nw networkv1.NodeNetwork) (int64, error) {
+	_, ipNet, err := net.ParseCIDR(nw.Cidrs[0])
+	if err != nil {
+		return 0, err
+	}
+	var ipCount int64 = 1
+	size := netutils.RangeSize(ipNet)
+	if size > 1 {
+		// The number of IPs supported are halved and returned for overprovisioning purposes.
+		ipCount = size >> 1
+	}
+	return ipCount, nil
+}
+
+// networkIPResourceName returns the default extended resource name of the IPs of a network.
+func networkIPResourceName(network string) v1.ResourceName {
+	return v1.ResourceName(networkv1.NetworkResourceKeyPrefix + network + ".IP")
+}
@@ -20,71 +20,132 @@ limitations under the License.
 package ipam
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
 	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	testingclock "k8s.io/utils/clock/testing"
 	netutils "k8s.io/utils/net"
 )
 
-func hasNodeInProcessing(ca *cloudCIDRAllocator, name string) bool {
-	ca.lock.Lock()
-	defer ca.lock.Unlock()
+// withQueue sets the work queue of an allocator built for a test.
+func withQueue(ca *cloudCIDRAllocator) *cloudCIDRAllocator {
+	ca.queue = ca.newQueue()
+	ca.queue.Resume()
+	return ca
+}
 
-	_, found := ca.nodesInProcessing[name]
+func hasNodeInProcessing(ca *cloudCIDRAllocator, name string) bool {
+	_, found := ca.queue.Retries(name)
 	return found
 }
 
-func TestBoundedRetries(t *testing.T) {
-	clientSet := fake.NewSimpleClientset()
-	updateChan := make(chan string, 1) // need to buffer as we are using only on go routine
-	stopChan := make(chan struct{})
-	sharedInfomer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+func TestQueueNodesAfterPause(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	clientSet := fake.NewSimpleClientset(node)
+	nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
 	ca := &cloudCIDRAllocator{
-		client:            clientSet,
-		nodeUpdateChannel: updateChan,
-		nodeLister:        sharedInfomer.Core().V1().Nodes().Lister(),
-		nodesSynced:       sharedInfomer.Core().V1().Nodes().Informer().HasSynced,
-		nodesInProcessing: map[string]*nodeProcessingInfo{},
+		client:     clientSet,
+		nodeLister: nodeInformer.Lister(),
+		params:     DefaultCloudAllocatorParams(),
 	}
-	go ca.worker(stopChan)
-	nodeName := "testNode"
-	ca.AllocateOrOccupyCIDR(&v1.Node{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: nodeName,
-		},
-	})
-	for hasNodeInProcessing(ca, nodeName) {
-		// wait for node to finish processing (should terminate and not time out)
+	ca.queue = ca.newQueue()
+
+	// The events of the nodes are dropped until the allocator runs.
+	if err := ca.AllocateOrOccupyCIDR(node); err != nil {
+		t.Fatalf("AllocateOrOccupyCIDR() returned err %v", err)
+	}
+	if hasNodeInProcessing(ca, node.Name) || len(ca.queue.Updates()) != 0 {
+		t.Fatalf("node was queued before the allocator runs")
+	}
+	ca.queue.Resume()
+	ca.queueNodes()
+	if !hasNodeInProcessing(ca, node.Name) || len(ca.queue.Updates()) != 1 {
+		t.Errorf("node was not queued once the allocator runs")
 	}
 }
 
-func withinExpectedRange(got time.Duration, expected time.Duration) bool {
-	return got >= expected/2 && got <= 3*expected/2
-}
+func TestBoundedRetries(t *testing.T) {
+	const providerID = "gce://test-project/us-central1-b/testNode"
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "testNode"}, Spec: v1.NodeSpec{ProviderID: providerID}}
+	clientSet := fake.NewSimpleClientset(node)
+	nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
+	params := DefaultCloudAllocatorParams()
+	params.UpdateRetryTimeout = time.Second
+	params.MaxUpdateRetryTimeout = 8 * time.Second
+	params.UpdateMaxRetries = 5
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		instances:  &fakeInstances{err: errors.New("compute API unavailable")},
+		clock:      fakeClock,
+		nodeLister: nodeInformer.Lister(),
+		recorder:   record.NewFakeRecorder(100),
+		params:     params,
+	})
+	if err := ca.AllocateOrOccupyCIDR(node); err != nil {
+		t.Fatalf("AllocateOrOccupyCIDR() returned err %v", err)
+	}
 
-func TestNodeUpdateRetryTimeout(t *testing.T) {
-	for _, tc := range []struct {
-		count int
-		want  time.Duration
-	}{
-		{count: 0, want: 250 * time.Millisecond},
-		{count: 1, want: 500 * time.Millisecond},
-		{count: 2, want: 1000 * time.Millisecond},
-		{count: 3, want: 2000 * time.Millisecond},
-		{count: 50, want: 5000 * time.Millisecond},
-	} {
-		t.Run(fmt.Sprintf("count %d", tc.count), func(t *testing.T) {
-			if got := nodeUpdateRetryTimeout(tc.count); !withinExpectedRange(got, tc.want) {
-				t.Errorf("nodeUpdateRetryTimeout(tc.count) = %v; want %v", got, tc.want)
-			}
-		})
+	// Each failed update is retried after a backoff jittered around a timeout
+	// doubling from the initial one up to the maximum.
+	wantTimeouts := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second}
+	for retry, timeout := range wantTimeouts {
+		select {
+		case name := <-ca.queue.Updates():
+			ca.queue.Process(name)
+		default:
+			t.Fatalf("node was not queued for retry %d", retry)
+		}
+		if !hasNodeInProcessing(ca, node.Name) {
+			t.Fatalf("node was dropped after %d retries, want %d", retry, len(wantTimeouts))
+		}
+		fakeClock.Step(timeout/2 - time.Nanosecond)
+		if got := len(ca.queue.Updates()); got != 0 {
+			t.Fatalf("node was queued for retry %d less than %v after the failure", retry, timeout/2)
+		}
+		fakeClock.Step(timeout)
+	}
+	select {
+	case name := <-ca.queue.Updates():
+		ca.queue.Process(name)
+	default:
+		t.Fatalf("node was not queued for the last retry")
+	}
+	if hasNodeInProcessing(ca, node.Name) {
+		t.Errorf("node is still processed after exceeding %d retries", params.UpdateMaxRetries)
+	}
+	if fakeClock.HasWaiters() {
+		t.Errorf("a retry is scheduled after exceeding %d retries", params.UpdateMaxRetries)
 	}
 }
 
@@ -144,6 +205,13 @@ func TestNeedPodCIDRsUpdate(t *testing.T) {
 			nodePodCIDRs: []string{"10.10.10.0/24", "2001:db8:0::/64"},
 			want:         false,
 		},
+		{
+			desc:         "want false - matching v4 and v6 cidrs in another order",
+			cidrs:        []string{"10.10.10.0/24", "2001:db8::/64"},
+			nodePodCIDR:  "2001:db8::/64",
+			nodePodCIDRs: []string{"2001:db8::/64", "10.10.10.0/24"},
+			want:         false,
+		},
 		{
 			desc:         "want true - matching v4 and non matching v6 cidrs",
 			cidrs:        []string{"10.10.10.0/24", "2001:db8::/64"},
@@ -196,6 +264,7 @@ type multiNetworkTestCase struct {
 	fakeNodeHandler        *testutil.FakeNodeHandler
 	northInterfaces        networkv1.NorthInterfacesAnnotation
 	additionalNodeNetworks networkv1.MultiNetworkAnnotation
+	disableIPCapacity      bool
 	expectedIPCapacities   map[string]int64
 	expectErr              bool
 }
@@ -322,15 +391,82 @@ func TestUpdateMultiNetworkAnnotations(t *testing.T) {
 				networkv1.NetworkResourceKeyPrefix + "Blue-Network.IP": 128,
 			},
 		},
+		{
+			description: "[valid] - node with IP capacity disabled",
+			fakeNodeHandler: &testutil.FakeNodeHandler{
+				Existing: []*v1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node0",
+							Labels: map[string]string{
+								"testLabel-0": "node0",
+							},
+						},
+					},
+				},
+				Clientset: fake.NewSimpleClientset(),
+			},
+			northInterfaces: []networkv1.NorthInterface{
+				{
+					Network:   "Blue-Network",
+					IpAddress: "172.10.0.1",
+				},
+			},
+			additionalNodeNetworks: []networkv1.NodeNetwork{
+				{
+					Name:  "Blue-Network",
+					Cidrs: []string{"30.20.10.0/24"},
+					Scope: "host-local",
+				},
+			},
+			disableIPCapacity: true,
+		},
+		{
+			description: "[valid] - node with IP capacity disabled after it was published",
+			fakeNodeHandler: &testutil.FakeNodeHandler{
+				Existing: []*v1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node0",
+							Labels: map[string]string{
+								"testLabel-0": "node0",
+							},
+						},
+						Status: v1.NodeStatus{
+							Capacity: v1.ResourceList{
+								networkv1.NetworkResourceKeyPrefix + "Blue-Network.IP": resource.MustParse("128"),
+							},
+						},
+					},
+				},
+				Clientset: fake.NewSimpleClientset(),
+			},
+			northInterfaces: []networkv1.NorthInterface{
+				{
+					Network:   "Blue-Network",
+					IpAddress: "172.10.0.1",
+				},
+			},
+			additionalNodeNetworks: []networkv1.NodeNetwork{
+				{
+					Name:  "Blue-Network",
+					Cidrs: []string{"30.20.10.0/24"},
+					Scope: "host-local",
+				},
+			},
+			disableIPCapacity: true,
+		},
 	}
 	// test function
 	testFunc := func(tc multiNetworkTestCase) {
 		for _, node := range tc.fakeNodeHandler.Existing {
 			var err error
 			ca := &cloudCIDRAllocator{
-				client: tc.fakeNodeHandler,
+				client:         tc.fakeNodeHandler,
+				networksLister: networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking().V1().Networks().Lister(),
+				params:         CloudAllocatorParams{DisableIPCapacity: tc.disableIPCapacity},
 			}
-			if err = ca.updateMultiNetworkAnnotations(node, tc.northInterfaces, tc.additionalNodeNetworks); err != nil {
+			if err = ca.updateMultiNetworkAnnotations(node, nil, tc.northInterfaces, tc.additionalNodeNetworks); err != nil {
 				if !tc.expectErr {
 					t.Fatalf("unexpected error %v", err)
 				}
@@ -365,3 +501,223 @@ func TestUpdateMultiNetworkAnnotations(t *testing.T) {
 		testFunc(tc)
 	}
 }
+
+// fakeInstances serves a single instance, or an error.
+type fakeInstances struct {
+	instance *compute.Instance
+	err      error
+}
+
+func (f *fakeInstances) InstanceByProviderID(providerID string) (*compute.Instance, error) {
+	return f.instance, f.err
+}
+
+func TestUpdateCIDRAllocationErrors(t *testing.T) {
+	const providerID = "gce://test-project/us-central1-b/node0"
+	defaultInterface := func(aliasIPRanges ...string) *compute.NetworkInterface {
+		inf := interfaces(defaultVPCName, defaultVPCSubnetName, "10.0.0.2", nil)
+		for _, cidr := range aliasIPRanges {
+			inf.AliasIpRanges = append(inf.AliasIpRanges, &compute.AliasIpRange{IpCidrRange: cidr, SubnetworkRangeName: defaultSecondaryRangeA})
+		}
+		return inf
+	}
+	conflict := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "nodes"}, "node0", fmt.Errorf("the object has been modified"))
+	}
+	testCases := []struct {
+		desc          string
+		providerID    string
+		noNode        bool
+		instance      *compute.Instance
+		instanceErr   error
+		multiNetwork  bool
+		patchReactor  k8stesting.ReactionFunc
+		wantErr       string
+		wantErrKind   error
+		wantEvent     string
+		wantPodCIDRs  []string
+		wantCondition bool
+	}{
+		{
+			desc:   "node deleted",
+			noNode: true,
+		},
+		{
+			desc:        "node without providerID",
+			wantErr:     "doesn't have providerID",
+			wantErrKind: ErrMissingProviderID,
+		},
+		{
+			desc:       "node of another provider",
+			providerID: "kind://docker/kind/node0",
+			wantEvent:  wellknown.UnmanagedProviderIDReason,
+		},
+		{
+			desc:        "instance not found",
+			providerID:  providerID,
+			instanceErr: &googleapi.Error{Code: http.StatusNotFound},
+			wantErr:     "failed to get instance from provider",
+			wantErrKind: ErrInstanceNotFound,
+			wantEvent:   "CIDRNotAvailable",
+		},
+		{
+			desc:        "instance without interfaces",
+			providerID:  providerID,
+			instance:    &compute.Instance{},
+			wantErr:     "has no ranges from which CIDRs can be allocated",
+			wantErrKind: ErrNoMatchingRange,
+			wantEvent:   "CIDRNotAvailable",
+		},
+		{
+			desc:        "single interface without alias IP ranges",
+			providerID:  providerID,
+			instance:    &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{defaultInterface()}},
+			wantErr:     "has no ranges from which CIDRs can be allocated",
+			wantErrKind: ErrNoMatchingRange,
+			wantEvent:   "CIDRNotAvailable",
+		},
+		{
+			desc:       "first interface without alias IP ranges",
+			providerID: providerID,
+			instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{
+				defaultInterface(),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.0.2", []*compute.AliasIpRange{{IpCidrRange: "10.11.0.0/24"}}),
+			}},
+			wantErr:     "has no alias IP ranges on its first interface",
+			wantErrKind: ErrNoMatchingRange,
+			wantEvent:   "CIDRNotAvailable",
+		},
+		{
+			desc:         "multi-network allocation without networks",
+			providerID:   providerID,
+			multiNetwork: true,
+			instance:     &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{defaultInterface("10.10.0.0/24", "10.10.1.0/24")}},
+			wantErr:      "has no CIDRs",
+			wantErrKind:  ErrNoMatchingRange,
+			wantEvent:    "CIDRNotAvailable",
+		},
+		{
+			desc:         "node patch conflict",
+			providerID:   providerID,
+			instance:     &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{defaultInterface("10.10.0.0/24")}},
+			patchReactor: conflict,
+			wantErr:      "the object has been modified",
+			wantErrKind:  ErrNodeUpdate,
+			wantEvent:    "CIDRAssignmentFailed",
+		},
+		{
+			desc:          "success",
+			providerID:    providerID,
+			instance:      &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{defaultInterface("10.10.0.0/24")}},
+			wantPodCIDRs:  []string{"10.10.0.0/24"},
+			wantCondition: true,
+		},
+		{
+			desc:       "dual-stack success",
+			providerID: providerID,
+			instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{func() *compute.NetworkInterface {
+				inf := defaultInterface("10.10.0.0/24")
+				inf.StackType = "IPV4_IPV6"
+				inf.Ipv6Address = "2600:1900:4000:1::"
+				return inf
+			}()}},
+			wantPodCIDRs:  []string{"10.10.0.0/24", "2600:1900:4000:1::/112"},
+			wantCondition: true,
+		},
+		{
+			desc:       "dual-stack success with an external IPv6 address",
+			providerID: providerID,
+			instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{func() *compute.NetworkInterface {
+				inf := defaultInterface("10.10.0.0/24")
+				inf.StackType = "IPV4_IPV6"
+				inf.Ipv6AccessType = "EXTERNAL"
+				inf.Ipv6AccessConfigs = []*compute.AccessConfig{{ExternalIpv6: "2600:1901:0:1::"}}
+				return inf
+			}()}},
+			wantPodCIDRs:  []string{"10.10.0.0/24", "2600:1901:0:1::/112"},
+			wantCondition: true,
+		},
+		{
+			desc:       "dual-stack success with an external IPv6 delegated prefix",
+			providerID: providerID,
+			instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{func() *compute.NetworkInterface {
+				inf := defaultInterface("10.10.0.0/24")
+				inf.StackType = "IPV4_IPV6"
+				inf.Ipv6AccessType = "EXTERNAL"
+				inf.Ipv6AccessConfigs = []*compute.AccessConfig{{ExternalIpv6: "2600:1901:0:1::/96", ExternalIpv6PrefixLength: 96}}
+				return inf
+			}()}},
+			wantPodCIDRs:  []string{"10.10.0.0/24", "2600:1901:0:1::/112"},
+			wantCondition: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: tc.providerID},
+			}
+			clientSet := fake.NewSimpleClientset(node)
+			if tc.patchReactor != nil {
+				clientSet.PrependReactor("patch", "nodes", tc.patchReactor)
+			}
+			nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+			if !tc.noNode {
+				if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+					t.Fatalf("error in test setup, could not add node: %v", err)
+				}
+			}
+			nwInfFactory := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking()
+			recorder := record.NewFakeRecorder(10)
+			fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+			ca := &cloudCIDRAllocator{
+				client:         clientSet,
+				nodeLister:     nodeInformer.Lister(),
+				networksLister: nwInfFactory.V1().Networks().Lister(),
+				gnpLister:      nwInfFactory.V1alpha1().GKENetworkParamSets().Lister(),
+				recorder:       recorder,
+				instances:      &fakeInstances{instance: tc.instance, err: tc.instanceErr},
+				clock:          fakeClock,
+				params:         CloudAllocatorParams{EnableMultiNetworking: tc.multiNetwork},
+			}
+
+			err := ca.updateCIDRAllocation(node.Name)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("updateCIDRAllocation() returned err %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("updateCIDRAllocation() returned err %v, want %q", err, tc.wantErr)
+			}
+			if tc.wantErrKind != nil && !errors.Is(err, tc.wantErrKind) {
+				t.Errorf("updateCIDRAllocation() returned err %v, want an error of kind %v", err, tc.wantErrKind)
+			}
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			if tc.wantEvent != "" && (len(events) == 0 || !strings.Contains(events[0], tc.wantEvent)) {
+				t.Errorf("got events %v, want a %s event", events, tc.wantEvent)
+			}
+			if tc.wantEvent == "" && len(events) != 0 {
+				t.Errorf("got events %v, want none", events)
+			}
+
+			got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the node: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantPodCIDRs, got.Spec.PodCIDRs); diff != "" {
+				t.Errorf("node pod CIDRs mismatch (-want +got):\n%s", diff)
+			}
+			_, condition := nodeutil.GetNodeCondition(&got.Status, v1.NodeNetworkUnavailable)
+			if tc.wantCondition {
+				if condition == nil || condition.Status != v1.ConditionFalse || !condition.LastTransitionTime.Time.Equal(fakeClock.Now()) {
+					t.Errorf("got NetworkUnavailable condition %+v, want false at %v", condition, fakeClock.Now())
+				}
+			} else if condition != nil {
+				t.Errorf("got NetworkUnavailable condition %+v, want none", condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+)
+
+const (
+	// ComputeAPIVersionV1 reads the instances of the nodes with the GA
+	// compute API.
+	ComputeAPIVersionV1 = "v1"
+	// ComputeAPIVersionBeta reads the instances of the nodes with the beta
+	// compute API, which returns network interface fields, e.g. of IPv6 or
+	// per-NIC features, before they are available in the GA API.
+	ComputeAPIVersionBeta = "beta"
+	// ComputeAPIVersionAlpha reads the instances of the nodes with the alpha
+	// compute API.
+	ComputeAPIVersionAlpha = "alpha"
+
+	// instanceFetchTimeout is the timeout of the instance reads of the beta
+	// and alpha compute APIs.
+	instanceFetchTimeout = time.Minute
+)
+
+// validateComputeAPIVersion returns an error if the compute API version is
+// not supported.
+func validateComputeAPIVersion(version string) error {
+	switch version {
+	case "", ComputeAPIVersionV1, ComputeAPIVersionBeta, ComputeAPIVersionAlpha:
+		return nil
+	}
+	return fmt.Errorf("unsupported compute API version %q", version)
+}
+
+// newCloudInstances returns the reader of the instances of the nodes for the
+// compute API version. The cloud reads the GA API itself.
+func newCloudInstances(cloud *gce.Cloud, version string) cloudInstances {
+	switch version {
+	case ComputeAPIVersionBeta:
+		return &versionedInstances{Cloud: cloud, get: func(ctx context.Context, project, zone, name string) (interface{}, error) {
+			return cloud.ComputeServices().Beta.Instances.Get(project, zone, name).Context(ctx).Do()
+		}}
+	case ComputeAPIVersionAlpha:
+		return &versionedInstances{Cloud: cloud, get: func(ctx context.Context, project, zone, name string) (interface{}, error) {
+			return cloud.ComputeServices().Alpha.Instances.Get(project, zone, name).Context(ctx).Do()
+		}}
+	}
+	return nil
+}
+
+// versionedInstances reads the instances of the nodes with a beta or alpha
+// compute API and converts them to the GA model used by the allocator, see
+// toComputeInstance.
+type versionedInstances struct {
+	*gce.Cloud
+	// get reads an instance with the compute API version.
+	get func(ctx context.Context, project, zone, name string) (interface{}, error)
+}
+
+func (vi *versionedInstances) InstanceByProviderID(providerID string) (*compute.Instance, error) {
+	match := gceProviderIDRE.FindStringSubmatch(providerID)
+	if match == nil {
+		return nil, fmt.Errorf("providerID %q is not a GCE instance", providerID)
+	}
+	project, zone, name := match[1], match[2], match[3]
+	// Instance names may be qualified by the domain of the node name.
+	if ix := strings.Index(name, "."); ix != -1 {
+		name = name[:ix]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), instanceFetchTimeout)
+	defer cancel()
+	instance, err := vi.get(ctx, project, zone, name)
+	if err != nil {
+		return nil, err
+	}
+	return toComputeInstance(instance)
+}
+
+// toComputeInstance converts an instance of the beta or alpha compute API to
+// the GA model. The API versions share the JSON representation of the
+// resources, so the fields known to the GA client, which include fields the
+// GA API does not return yet, are kept and the others are dropped.
+func toComputeInstance(instance interface{}) (*compute.Instance, error) {
+	switch instance.(type) {
+	case *computebeta.Instance, *computealpha.Instance:
+	default:
+		return nil, fmt.Errorf("unexpected instance type %T", instance)
+	}
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert instance: %v", err)
+	}
+	out := &compute.Instance{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("failed to convert instance: %v", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,106 @@
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestVersionedInstanceByProviderID(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		providerID string
+		instance   interface{}
+		wantKey    string
+		want       *compute.Instance
+		wantErr    bool
+	}{
+		{
+			desc:       "beta instance",
+			providerID: "gce://testProject/us-central1-b/node0",
+			instance: &computebeta.Instance{
+				Name: "node0",
+				NetworkInterfaces: []*computebeta.NetworkInterface{
+					{Name: "nic0", Network: "default", NicType: "GVNIC", StackType: "IPV4_IPV6", Ipv6Address: "2600:1900::1"},
+				},
+			},
+			wantKey: "testProject/us-central1-b/node0",
+			want: &compute.Instance{
+				Name: "node0",
+				NetworkInterfaces: []*compute.NetworkInterface{
+					{Name: "nic0", Network: "default", NicType: "GVNIC", StackType: "IPV4_IPV6", Ipv6Address: "2600:1900::1"},
+				},
+			},
+		},
+		{
+			desc:       "alpha instance",
+			providerID: "gce://testProject/us-central1-b/node0.c.testProject.internal",
+			instance: &computealpha.Instance{
+				Name: "node0",
+				NetworkInterfaces: []*computealpha.NetworkInterface{
+					{Name: "nic0", Network: "default", NicType: "IDPF"},
+				},
+			},
+			wantKey: "testProject/us-central1-b/node0",
+			want: &compute.Instance{
+				Name: "node0",
+				NetworkInterfaces: []*compute.NetworkInterface{
+					{Name: "nic0", Network: "default", NicType: "IDPF"},
+				},
+			},
+		},
+		{
+			desc:       "invalid providerID",
+			providerID: "aws:///us-east-1a/i-0123",
+			wantErr:    true,
+		},
+		{
+			desc:       "unexpected instance type",
+			providerID: "gce://testProject/us-central1-b/node0",
+			instance:   "node0",
+			wantKey:    "testProject/us-central1-b/node0",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var gotKey string
+			instances := &versionedInstances{get: func(ctx context.Context, project, zone, name string) (interface{}, error) {
+				gotKey = fmt.Sprintf("%s/%s/%s", project, zone, name)
+				return tc.instance, nil
+			}}
+
+			got, err := instances.InstanceByProviderID(tc.providerID)
+			if gotKey != tc.wantKey {
+				t.Errorf("got instance %q read, want %q", gotKey, tc.wantKey)
+			}
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("InstanceByProviderID() returned err %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("InstanceByProviderID() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewCloudInstances(t *testing.T) {
+	for _, version := range []string{"", ComputeAPIVersionV1} {
+		if instances := newCloudInstances(nil, version); instances != nil {
+			t.Errorf("newCloudInstances(%q) = %T, want nil to read the GA API with the cloud", version, instances)
+		}
+	}
+	for _, version := range []string{ComputeAPIVersionBeta, ComputeAPIVersionAlpha} {
+		if _, ok := newCloudInstances(nil, version).(*versionedInstances); !ok {
+			t.Errorf("newCloudInstances(%q) is not a versioned reader", version)
+		}
+	}
+	if err := validateComputeAPIVersion("v2"); err == nil {
+		t.Errorf("validateComputeAPIVersion(%q) returned no error", "v2")
+	}
+}
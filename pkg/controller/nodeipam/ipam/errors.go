@@ -0,0 +1,138 @@
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// Kinds of the errors returned by the cloud CIDR allocator. Callers test for
+// them with errors.Is.
+var (
+	// ErrMissingProviderID is returned for nodes without a providerID. They
+	// are queued again when their providerID is set.
+	ErrMissingProviderID = errors.New("node has no providerID")
+	// ErrMalformedProviderID is returned for nodes whose providerID refers to
+	// a GCE instance but cannot be parsed.
+	ErrMalformedProviderID = errors.New("malformed providerID")
+	// ErrInstanceNotFound is returned for nodes whose instance no longer exists.
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrCloudAPI is returned when a request to the compute API failed.
+	ErrCloudAPI = errors.New("compute API request failed")
+	// ErrNoMatchingRange is returned for nodes whose instance has no alias IP
+	// range from which their pod CIDRs can be allocated.
+	ErrNoMatchingRange = errors.New("no matching range")
+	// ErrParamsInvalid is returned when the network parameters of the node, or
+	// the GKENetworkParamSets they refer to, are missing or invalid.
+	ErrParamsInvalid = errors.New("invalid network parameters")
+	// ErrExternalIPAM is returned when an external IPAM provider failed to
+	// allocate the ranges of the node.
+	ErrExternalIPAM = errors.New("external IPAM provider failure")
+	// ErrNodeUpdate is returned when the node could not be updated.
+	ErrNodeUpdate = errors.New("node update failed")
+)
+
+// errorReasons maps the kinds of errors to the reasons of the events recorded
+// on the nodes and to the reason label of the allocation errors metric.
+var errorReasons = map[error]string{
+	ErrMissingProviderID:   wellknown.MissingProviderIDReason,
+	ErrMalformedProviderID: wellknown.MalformedProviderIDReason,
+	ErrInstanceNotFound:    wellknown.InstanceNotFoundReason,
+	ErrCloudAPI:            wellknown.CloudAPIErrorReason,
+	ErrNoMatchingRange:     wellknown.NoMatchingRangeReason,
+	ErrParamsInvalid:       wellknown.InvalidNetworkParamsReason,
+	ErrExternalIPAM:        wellknown.ExternalIPAMErrorReason,
+	ErrNodeUpdate:          wellknown.NodeUpdateFailedReason,
+}
+
+// AllocationError is an error of the cloud CIDR allocator of a given kind.
+// Its message is the message of the underlying error.
+type AllocationError struct {
+	// Kind is one of the Err* errors of the package.
+	Kind error
+	// Err is the underlying error.
+	Err error
+	// Network is the name of the network the error is about, if any. The
+	// errors about a network are recorded on the Network, see
+	// recordAllocationError.
+	Network string
+}
+
+func (e *AllocationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AllocationError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is the kind of the error.
+func (e *AllocationError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// allocationErrorf returns an AllocationError of the given kind, formatting
+// the message as fmt.Errorf does.
+func allocationErrorf(kind error, format string, args ...interface{}) error {
+	return &AllocationError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// networkAllocationErrorf returns an AllocationError of the given kind about
+// the given network, formatting the message as fmt.Errorf does.
+func networkAllocationErrorf(kind error, network, format string, args ...interface{}) error {
+	return &AllocationError{Kind: kind, Err: fmt.Errorf(format, args...), Network: network}
+}
+
+// instanceError returns the AllocationError of a failure to get the instance
+// of a node.
+func instanceError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.Is(err, cloudprovider.InstanceNotFound) || (errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound) {
+		return allocationErrorf(ErrInstanceNotFound, "failed to get instance from provider: %w", err)
+	}
+	return allocationErrorf(ErrCloudAPI, "failed to get instance from provider: %w", err)
+}
+
+// errorReason returns the reason of the kind of the error.
+func errorReason(err error) string {
+	var allocErr *AllocationError
+	if errors.As(err, &allocErr) {
+		if reason, ok := errorReasons[allocErr.Kind]; ok {
+			return reason
+		}
+	}
+	return wellknown.UnknownErrorReason
+}
+
+// retriableError returns false for the errors that retrying the update of the
+// node cannot fix: nodes without providerID are queued again once it is set,
+// nodes whose instance was deleted are about to be deleted, and terminal errors
+// wait for the inputs of the node to change, see terminalError.
+func retriableError(err error) bool {
+	return !errors.Is(err, ErrMissingProviderID) && !errors.Is(err, ErrInstanceNotFound) && !terminalError(err)
+}
+
+// recordAllocationError counts the failed update of the node by reason and
+// records the error on the node. Errors about a network are aggregated on the
+// Network instead, as a misconfigured network fails on all its nodes.
+func (ca *cloudCIDRAllocator) recordAllocationError(nodeName string, err error) {
+	reason := errorReason(err)
+	allocationErrors.WithLabelValues(reason).Inc()
+	var allocErr *AllocationError
+	if errors.As(err, &allocErr) && allocErr.Network != "" {
+		ca.recordNetworkEvent(allocErr.Network, nodeName, reason, fmt.Sprintf("CIDR allocation failed, last error: %v", err))
+		return
+	}
+	node, getErr := ca.nodeLister.Get(nodeName)
+	if getErr != nil {
+		klog.V(4).Infof("Not recording the allocation error of node %s: %v", nodeName, getErr)
+		return
+	}
+	ca.recorder.Eventf(node, v1.EventTypeWarning, reason, "CIDR allocation failed: %v", err)
+}
@@ -0,0 +1,124 @@
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestAllocationErrorClassification(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		err           error
+		wantKind      error
+		wantReason    string
+		wantRetriable bool
+	}{
+		{
+			desc:          "unclassified error",
+			err:           errors.New("boom"),
+			wantReason:    wellknown.UnknownErrorReason,
+			wantRetriable: true,
+		},
+		{
+			desc:       "missing providerID",
+			err:        allocationErrorf(ErrMissingProviderID, "node %s doesn't have providerID", "node0"),
+			wantKind:   ErrMissingProviderID,
+			wantReason: "MissingProviderID",
+		},
+		{
+			desc:       "instance not found by the cloud provider",
+			err:        instanceError(cloudprovider.InstanceNotFound),
+			wantKind:   ErrInstanceNotFound,
+			wantReason: "InstanceNotFound",
+		},
+		{
+			desc:       "instance not found by the compute API",
+			err:        instanceError(&googleapi.Error{Code: http.StatusNotFound}),
+			wantKind:   ErrInstanceNotFound,
+			wantReason: "InstanceNotFound",
+		},
+		{
+			desc:          "compute API failure",
+			err:           instanceError(&googleapi.Error{Code: http.StatusTooManyRequests}),
+			wantKind:      ErrCloudAPI,
+			wantReason:    "CloudAPIError",
+			wantRetriable: true,
+		},
+		{
+			desc:       "wrapped error",
+			err:        fmt.Errorf("failed to get cidr(s) from provider: %w", allocationErrorf(ErrParamsInvalid, "missing params")),
+			wantKind:   ErrParamsInvalid,
+			wantReason: "InvalidNetworkParams",
+		},
+		{
+			desc:       "malformed providerID",
+			err:        allocationErrorf(ErrMalformedProviderID, "node %s has malformed providerID %q", "node0", "gce://project/instance"),
+			wantKind:   ErrMalformedProviderID,
+			wantReason: "MalformedProviderID",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.wantKind != nil && !errors.Is(tc.err, tc.wantKind) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tc.err, tc.wantKind)
+			}
+			if got := errorReason(tc.err); got != tc.wantReason {
+				t.Errorf("errorReason() = %q, want %q", got, tc.wantReason)
+			}
+			if got := retriableError(tc.err); got != tc.wantRetriable {
+				t.Errorf("retriableError() = %t, want %t", got, tc.wantRetriable)
+			}
+		})
+	}
+}
+
+func TestAllocationErrorUnwrap(t *testing.T) {
+	apiErr := &googleapi.Error{Code: http.StatusInternalServerError}
+	err := instanceError(apiErr)
+	var got *googleapi.Error
+	if !errors.As(err, &got) || got != apiErr {
+		t.Errorf("errors.As() did not find the underlying error of %v", err)
+	}
+	if !strings.Contains(err.Error(), "failed to get instance from provider") {
+		t.Errorf("got message %q, want the message of the underlying error", err.Error())
+	}
+}
+
+func TestRecordAllocationError(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(node), time.Hour).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{nodeLister: nodeInformer.Lister(), recorder: recorder}
+
+	before, _ := testutil.GetCounterMetricValue(allocationErrors.WithLabelValues("NoMatchingRange"))
+	ca.recordAllocationError(node.Name, allocationErrorf(ErrNoMatchingRange, "no ranges"))
+	if got, _ := testutil.GetCounterMetricValue(allocationErrors.WithLabelValues("NoMatchingRange")); got-before != 1 {
+		t.Errorf("got %v more NoMatchingRange errors, want 1", got-before)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "Warning NoMatchingRange") {
+			t.Errorf("got event %q, want a NoMatchingRange warning", event)
+		}
+	default:
+		t.Errorf("got no event, want a NoMatchingRange warning")
+	}
+}
@@ -0,0 +1,77 @@
+package ipam
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+// rateLimitedRecorder drops the events of a reason once its token bucket is
+// empty, so that an outage failing the updates of every node does not flood
+// the API server with events. Each reason has its own bucket, so that a burst
+// of one reason does not hide the events of the others.
+type rateLimitedRecorder struct {
+	recorder record.EventRecorder
+	qps      float32
+	burst    int
+
+	lock     sync.Mutex
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+var _ record.EventRecorder = (*rateLimitedRecorder)(nil)
+
+// newRateLimitedRecorder wraps the recorder with a token bucket of qps and
+// burst per reason. The recorder is returned as is if qps is zero.
+func newRateLimitedRecorder(recorder record.EventRecorder, qps float32, burst int) record.EventRecorder {
+	if qps <= 0 {
+		return recorder
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedRecorder{
+		recorder: recorder,
+		qps:      qps,
+		burst:    burst,
+		limiters: map[string]flowcontrol.RateLimiter{},
+	}
+}
+
+// accept returns true if an event of the reason can be recorded.
+func (r *rateLimitedRecorder) accept(reason string) bool {
+	r.lock.Lock()
+	limiter, ok := r.limiters[reason]
+	if !ok {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(r.qps, r.burst)
+		r.limiters[reason] = limiter
+	}
+	r.lock.Unlock()
+	if limiter.TryAccept() {
+		return true
+	}
+	droppedEvents.WithLabelValues(reason).Inc()
+	klog.V(4).Infof("Dropping event %s, the rate limit of the reason is exceeded", reason)
+	return false
+}
+
+func (r *rateLimitedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.accept(reason) {
+		r.recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r *rateLimitedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.accept(reason) {
+		r.recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *rateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.accept(reason) {
+		r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
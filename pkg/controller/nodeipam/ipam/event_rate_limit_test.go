@@ -0,0 +1,54 @@
+package ipam
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRateLimitedRecorder(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		qps   float32
+		burst int
+		// events is the number of events recorded for each reason.
+		events int
+		want   int
+	}{
+		{
+			desc:   "no limit",
+			events: 10,
+			want:   20,
+		},
+		{
+			desc:   "burst exceeded",
+			qps:    0.001,
+			burst:  3,
+			events: 10,
+			want:   6,
+		},
+		{
+			desc:   "burst not exceeded",
+			qps:    0.001,
+			burst:  10,
+			events: 10,
+			want:   20,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeRecorder := record.NewFakeRecorder(100)
+			recorder := newRateLimitedRecorder(fakeRecorder, tc.qps, tc.burst)
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+			for i := 0; i < tc.events; i++ {
+				recorder.Event(node, v1.EventTypeWarning, "CIDRNotAvailable", "no CIDR")
+				recorder.Eventf(node, v1.EventTypeWarning, "InvalidTrafficClass", "network %d", i)
+			}
+			if got := len(fakeRecorder.Events); got != tc.want {
+				t.Errorf("recorded %d events, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package externalipam defines the gRPC service implemented by external IPAM
+// providers allocating the per-node ranges of additional networks, e.g. to
+// integrate with enterprise IPAM systems.
+//
+// The service uses JSON encoded messages, negotiated with the
+// "application/grpc+json" content type, so that providers can implement it
+// without sharing generated code:
+//
+//	service ExternalAllocator {
+//	  rpc AllocateNodeRanges(AllocateNodeRangesRequest) returns (AllocateNodeRangesResponse);
+//	  rpc ReleaseNodeRanges(ReleaseNodeRangesRequest) returns (ReleaseNodeRangesResponse);
+//	}
+//
+// Allocations must be idempotent: allocating the ranges of a node that already
+// has ranges returns the same ranges.
+package externalipam
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the fully qualified name of the gRPC service.
+	ServiceName = "cloudprovidergcp.ipam.v1.ExternalAllocator"
+
+	// codecName is the content subtype of the JSON codec.
+	codecName = "json"
+)
+
+// AllocateNodeRangesRequest asks for the ranges of a node on a network.
+type AllocateNodeRangesRequest struct {
+	// Network is the name of the Network object.
+	Network string `json:"network"`
+	// Node is the name of the node.
+	Node string `json:"node"`
+	// Interface is the name of the node interface attached to the network, e.g. nic1.
+	Interface string `json:"interface"`
+	// InterfaceIP is the primary IP address of the interface.
+	InterfaceIP string `json:"interfaceIP"`
+	// Subnetwork is the subnetwork of the interface.
+	Subnetwork string `json:"subnetwork"`
+}
+
+// AllocateNodeRangesResponse holds the ranges allocated to the node.
+type AllocateNodeRangesResponse struct {
+	// CIDRs are the pod CIDRs of the node on the network.
+	CIDRs []string `json:"cidrs"`
+}
+
+// ReleaseNodeRangesRequest asks to release the ranges of a deleted node.
+type ReleaseNodeRangesRequest struct {
+	// Network is the name of the Network object.
+	Network string `json:"network"`
+	// Node is the name of the node.
+	Node string `json:"node"`
+}
+
+// ReleaseNodeRangesResponse is the empty response of ReleaseNodeRanges.
+type ReleaseNodeRangesResponse struct{}
+
+// ExternalAllocatorServer is implemented by external IPAM providers.
+type ExternalAllocatorServer interface {
+	AllocateNodeRanges(context.Context, *AllocateNodeRangesRequest) (*AllocateNodeRangesResponse, error)
+	ReleaseNodeRanges(context.Context, *ReleaseNodeRangesRequest) (*ReleaseNodeRangesResponse, error)
+}
+
+// ExternalAllocatorClient calls an external IPAM provider.
+type ExternalAllocatorClient interface {
+	AllocateNodeRanges(ctx context.Context, req *AllocateNodeRangesRequest, opts ...grpc.CallOption) (*AllocateNodeRangesResponse, error)
+	ReleaseNodeRanges(ctx context.Context, req *ReleaseNodeRangesRequest, opts ...grpc.CallOption) (*ReleaseNodeRangesResponse, error)
+}
+
+type externalAllocatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewExternalAllocatorClient returns a client of the service on the connection.
+func NewExternalAllocatorClient(cc grpc.ClientConnInterface) ExternalAllocatorClient {
+	return &externalAllocatorClient{cc: cc}
+}
+
+func (c *externalAllocatorClient) AllocateNodeRanges(ctx context.Context, req *AllocateNodeRangesRequest, opts ...grpc.CallOption) (*AllocateNodeRangesResponse, error) {
+	resp := &AllocateNodeRangesResponse{}
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/AllocateNodeRanges", req, resp, opts...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *externalAllocatorClient) ReleaseNodeRanges(ctx context.Context, req *ReleaseNodeRangesRequest, opts ...grpc.CallOption) (*ReleaseNodeRangesResponse, error) {
+	resp := &ReleaseNodeRangesResponse{}
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/ReleaseNodeRanges", req, resp, opts...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RegisterExternalAllocatorServer registers the provider on the gRPC server.
+func RegisterExternalAllocatorServer(s grpc.ServiceRegistrar, srv ExternalAllocatorServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*ExternalAllocatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AllocateNodeRanges",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &AllocateNodeRangesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(ExternalAllocatorServer).AllocateNodeRanges(ctx, req.(*AllocateNodeRangesRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/AllocateNodeRanges"}, handler)
+			},
+		},
+		{
+			MethodName: "ReleaseNodeRanges",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &ReleaseNodeRangesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(ExternalAllocatorServer).ReleaseNodeRanges(ctx, req.(*ReleaseNodeRangesRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/ReleaseNodeRanges"}, handler)
+			},
+		},
+	},
+}
+
+// Dial connects to the provider at the endpoint, a gRPC target such as
+// dns:///ipam.example.com:443. TLS with the system roots is used, except for
+// unix sockets which are local to the controller.
+func Dial(endpoint string) (*grpc.ClientConn, error) {
+	creds := credentials.NewClientTLSFromCert(nil, "")
+	if strings.HasPrefix(endpoint, "unix:") {
+		creds = insecure.NewCredentials()
+	}
+	return grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+}
+
+// jsonCodec encodes the messages of the service in JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalipam
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+type testAllocator struct {
+	released []string
+}
+
+func (a *testAllocator) AllocateNodeRanges(_ context.Context, req *AllocateNodeRangesRequest) (*AllocateNodeRangesResponse, error) {
+	if req.Node == "" {
+		return nil, fmt.Errorf("node is required")
+	}
+	return &AllocateNodeRangesResponse{CIDRs: []string{"172.16.0.0/24"}}, nil
+}
+
+func (a *testAllocator) ReleaseNodeRanges(_ context.Context, req *ReleaseNodeRangesRequest) (*ReleaseNodeRangesResponse, error) {
+	a.released = append(a.released, req.Network+"/"+req.Node)
+	return &ReleaseNodeRangesResponse{}, nil
+}
+
+func TestExternalAllocator(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ipam.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	server := grpc.NewServer()
+	allocator := &testAllocator{}
+	RegisterExternalAllocatorServer(server, allocator)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := Dial("unix:" + socket)
+	if err != nil {
+		t.Fatalf("Dial() returned err %v", err)
+	}
+	defer conn.Close()
+	client := NewExternalAllocatorClient(conn)
+
+	resp, err := client.AllocateNodeRanges(context.TODO(), &AllocateNodeRangesRequest{Network: "red", Node: "node0"})
+	if err != nil {
+		t.Fatalf("AllocateNodeRanges() returned err %v", err)
+	}
+	if len(resp.CIDRs) != 1 || resp.CIDRs[0] != "172.16.0.0/24" {
+		t.Errorf("AllocateNodeRanges() = %v, want [172.16.0.0/24]", resp.CIDRs)
+	}
+	if _, err := client.AllocateNodeRanges(context.TODO(), &AllocateNodeRangesRequest{Network: "red"}); err == nil {
+		t.Errorf("AllocateNodeRanges() returned no error, want the error of the provider")
+	}
+	if _, err := client.ReleaseNodeRanges(context.TODO(), &ReleaseNodeRangesRequest{Network: "red", Node: "node0"}); err != nil {
+		t.Fatalf("ReleaseNodeRanges() returned err %v", err)
+	}
+	if len(allocator.released) != 1 || allocator.released[0] != "red/node0" {
+		t.Errorf("got released ranges %v, want [red/node0]", allocator.released)
+	}
+}
@@ -0,0 +1,55 @@
+package ipam
+
+import (
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/klog/v2"
+)
+
+// gceProviderIDRE matches the providerID of GCE instances,
+// gce://<project>/<zone>/<instance>.
+var gceProviderIDRE = regexp.MustCompile(`^` + gce.ProviderName + `://([^/]+)/([^/]+)/([^/]+)$`)
+
+// isGCEProviderID returns true if the providerID refers to a GCE instance.
+func isGCEProviderID(providerID string) bool {
+	return gceProviderIDRE.MatchString(providerID)
+}
+
+// isMalformedGCEProviderID returns true if the providerID has the scheme of
+// GCE instances but cannot be parsed.
+func isMalformedGCEProviderID(providerID string) bool {
+	return strings.HasPrefix(providerID, gce.ProviderName+"://") && !isGCEProviderID(providerID)
+}
+
+// skipForeignNode records that the node, attached to the cluster from outside
+// of GCE, is not managed by the allocator. The event is recorded once per
+// node, since the providerID of a node never changes.
+func (ca *cloudCIDRAllocator) skipForeignNode(node *v1.Node) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.foreignNodes == nil {
+		ca.foreignNodes = make(map[string]bool)
+	}
+	if ca.foreignNodes[node.Name] {
+		return
+	}
+	ca.foreignNodes[node.Name] = true
+	skippedNodes.WithLabelValues(skippedNodeForeignProviderID).Set(float64(len(ca.foreignNodes)))
+	klog.InfoS("Skipping node whose providerID is not a GCE instance", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
+	ca.recorder.Eventf(node, v1.EventTypeNormal, wellknown.UnmanagedProviderIDReason, "Node providerID %q is not a GCE instance, its pod CIDRs are not allocated by the cloud CIDR allocator", node.Spec.ProviderID)
+}
+
+// forgetForeignNode removes a deleted node from the skipped nodes.
+func (ca *cloudCIDRAllocator) forgetForeignNode(nodeName string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if !ca.foreignNodes[nodeName] {
+		return
+	}
+	delete(ca.foreignNodes, nodeName)
+	skippedNodes.WithLabelValues(skippedNodeForeignProviderID).Set(float64(len(ca.foreignNodes)))
+}
@@ -0,0 +1,73 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestIsGCEProviderID(t *testing.T) {
+	testCases := []struct {
+		providerID string
+		want       bool
+	}{
+		{providerID: "gce://project/us-central1-a/instance", want: true},
+		{providerID: "aws:///us-east-1a/i-0123456789", want: false},
+		{providerID: "kind://docker/kind/kind-worker", want: false},
+		{providerID: "gce://project/instance", want: false},
+		{providerID: "project/us-central1-a/instance", want: false},
+	}
+	for _, tc := range testCases {
+		if got := isGCEProviderID(tc.providerID); got != tc.want {
+			t.Errorf("isGCEProviderID(%q) = %v, want %v", tc.providerID, got, tc.want)
+		}
+	}
+}
+
+func TestForeignNodesAreSkipped(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-node"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789"},
+	}
+	clientSet := fake.NewSimpleClientset(node)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	if err := sharedInformer.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+		t.Fatal(err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: sharedInformer.Core().V1().Nodes().Lister(),
+		recorder:   recorder,
+		params:     DefaultCloudAllocatorParams(),
+	})
+	gauge := skippedNodes.WithLabelValues(skippedNodeForeignProviderID)
+
+	// The cloud is not queried, which would panic as it is not set.
+	for i := 0; i < 2; i++ {
+		if err := ca.updateCIDRAllocation(node.Name); err != nil {
+			t.Fatalf("updateCIDRAllocation() returned err %v", err)
+		}
+	}
+	if got := len(recorder.Events); got != 1 {
+		t.Errorf("recorded %d events, want 1", got)
+	}
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 1 {
+		t.Errorf("skipped nodes = %v, want 1", got)
+	}
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v for a foreign node, want none", actions)
+	}
+
+	ca.ReleaseCIDR(node)
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 0 {
+		t.Errorf("skipped nodes after deletion = %v, want 0", got)
+	}
+}
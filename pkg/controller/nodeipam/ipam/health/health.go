@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health publishes the health of the cloud CIDR allocator in a
+// NodeIPAMHealth object, so that cluster diagnostics tooling can collect it
+// without scraping the metrics endpoint of the controller.
+//
+// The allocator writes a single NodeIPAMHealth, named
+// wellknown.NodeIPAMHealthName, holding the last time all the nodes were up to
+// date, the error rate of the node updates and whether updates are deferred to
+// shed load. NodeIPAMHealth is a cluster scoped resource of the
+// networking.gke.io/v1alpha1 API read and written with a dynamic client, see
+// crd/config/crds/networking.gke.io_nodeipamhealths.yaml.
+package health
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// Resource is the resource of the NodeIPAMHealths.
+var Resource = schema.GroupVersionResource{Group: "networking.gke.io", Version: "v1alpha1", Resource: "nodeipamhealths"}
+
+// Status is the health of the allocator.
+type Status struct {
+	// LastFullResyncTime is the last time every node was up to date, see
+	// wellknown.ConvergedConditionType.
+	LastFullResyncTime *metav1.Time `json:"lastFullResyncTime,omitempty"`
+	// Window is the period Updates and FailedUpdates are counted over.
+	Window metav1.Duration `json:"window"`
+	// Updates is the no. of node updates over the last Window.
+	Updates int64 `json:"updates"`
+	// FailedUpdates is the no. of failed node updates over the last Window.
+	FailedUpdates int64 `json:"failedUpdates"`
+	// ErrorRatePercent is the percentage of failed node updates over the
+	// last Window.
+	ErrorRatePercent int32 `json:"errorRatePercent"`
+	// QueuedNodes is the no. of nodes in the work queue, including the ones
+	// waiting for a retry.
+	QueuedNodes int32 `json:"queuedNodes"`
+	// ParkedNodes is the no. of nodes whose last update failed with a
+	// terminal error, not retried until their inputs change.
+	ParkedNodes int32 `json:"parkedNodes"`
+	// DeferredNodes is the no. of nodes whose update is deferred to shed
+	// load.
+	DeferredNodes int32 `json:"deferredNodes"`
+	// Conditions are the wellknown.ConvergedConditionType and
+	// wellknown.SheddingLoadConditionType conditions.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NodeIPAMHealth is the health of a CIDR allocator.
+type NodeIPAMHealth struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status Status `json:"status,omitempty"`
+}
+
+// Publisher writes the NodeIPAMHealth of the allocator.
+type Publisher struct {
+	client dynamic.ResourceInterface
+}
+
+// NewPublisher returns a Publisher writing the NodeIPAMHealth with the client.
+func NewPublisher(client dynamic.Interface) *Publisher {
+	return &Publisher{client: client.Resource(Resource)}
+}
+
+// Publish writes the status in the NodeIPAMHealth, creating it if needed. The
+// transition times of the conditions whose status did not change are kept.
+func (p *Publisher) Publish(ctx context.Context, status Status) error {
+	obj, err := p.client.Get(ctx, wellknown.NodeIPAMHealthName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		health := &NodeIPAMHealth{ObjectMeta: metav1.ObjectMeta{Name: wellknown.NodeIPAMHealthName}, Status: status}
+		obj, err := toUnstructured(health)
+		if err != nil {
+			return err
+		}
+		_, err = p.client.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	health := &NodeIPAMHealth{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, health); err != nil {
+		return fmt.Errorf("invalid NodeIPAMHealth %s: %v", wellknown.NodeIPAMHealthName, err)
+	}
+	conditions := health.Status.Conditions
+	for _, condition := range status.Conditions {
+		meta.SetStatusCondition(&conditions, condition)
+	}
+	health.Status = status
+	health.Status.Conditions = conditions
+	updated, err := toUnstructured(health)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func toUnstructured(health *NodeIPAMHealth) (*unstructured.Unstructured, error) {
+	health.APIVersion = Resource.GroupVersion().String()
+	health.Kind = "NodeIPAMHealth"
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(health)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestPublish(t *testing.T) {
+	ctx := context.TODO()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		Resource: "NodeIPAMHealthList",
+	})
+	p := NewPublisher(client)
+	get := func() Status {
+		t.Helper()
+		obj, err := client.Resource(Resource).Get(ctx, wellknown.NodeIPAMHealthName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() returned err %v", err)
+		}
+		health := &NodeIPAMHealth{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, health); err != nil {
+			t.Fatalf("FromUnstructured() returned err %v", err)
+		}
+		return health.Status
+	}
+
+	start := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	first := Status{
+		Window:        metav1.Duration{Duration: time.Minute},
+		Updates:       10,
+		FailedUpdates: 5,
+		QueuedNodes:   3,
+		Conditions: []metav1.Condition{
+			{Type: wellknown.ConvergedConditionType, Status: metav1.ConditionFalse, Reason: "NodesPending", LastTransitionTime: start},
+			{Type: wellknown.SheddingLoadConditionType, Status: metav1.ConditionFalse, Reason: "NoUpdateDeferred", LastTransitionTime: start},
+		},
+	}
+	if err := p.Publish(ctx, first); err != nil {
+		t.Fatalf("Publish() returned err %v", err)
+	}
+	if diff := cmp.Diff(first, get()); diff != "" {
+		t.Errorf("published status (-want +got):\n%s", diff)
+	}
+
+	later := metav1.NewTime(start.Add(time.Minute))
+	second := Status{
+		LastFullResyncTime: &later,
+		Window:             metav1.Duration{Duration: time.Minute},
+		Updates:            3,
+		Conditions: []metav1.Condition{
+			{Type: wellknown.ConvergedConditionType, Status: metav1.ConditionTrue, Reason: "AllNodesUpToDate", LastTransitionTime: later},
+			{Type: wellknown.SheddingLoadConditionType, Status: metav1.ConditionFalse, Reason: "NoUpdateDeferred", LastTransitionTime: later},
+		},
+	}
+	if err := p.Publish(ctx, second); err != nil {
+		t.Fatalf("Publish() returned err %v", err)
+	}
+	got := get()
+	if got.Updates != 3 || got.QueuedNodes != 0 || got.LastFullResyncTime == nil {
+		t.Errorf("published status %+v, want %+v", got, second)
+	}
+	if c := meta.FindStatusCondition(got.Conditions, wellknown.ConvergedConditionType); c == nil || !c.LastTransitionTime.Equal(&later) {
+		t.Errorf("%s condition %+v, want a transition at %v", wellknown.ConvergedConditionType, c, later)
+	}
+	if c := meta.FindStatusCondition(got.Conditions, wellknown.SheddingLoadConditionType); c == nil || !c.LastTransitionTime.Equal(&start) {
+		t.Errorf("%s condition %+v, want the transition at %v kept", wellknown.SheddingLoadConditionType, c, start)
+	}
+}
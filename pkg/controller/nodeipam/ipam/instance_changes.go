@@ -0,0 +1,38 @@
+package ipam
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-gcp/providers/gce"
+)
+
+// requeueChangedInstanceNode puts the node of an instance into the work queue
+// when the instance watcher or the instance change feed of the cloud sees the
+// instance recreated or its network interfaces change, so that the allocation
+// of the node follows its instance without waiting for an update of the node.
+func (ca *cloudCIDRAllocator) requeueChangedInstanceNode(change gce.InstanceChange) {
+	if change.Type != gce.InstanceUpdated {
+		return
+	}
+	if change.Old.Id == change.New.Id && reflect.DeepEqual(change.Old.NetworkInterfaces, change.New.NetworkInterfaces) {
+		return
+	}
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the nodes to requeue the node of a changed instance", "providerID", change.ProviderID)
+		return
+	}
+	for _, node := range nodes {
+		if node.Spec.ProviderID != change.ProviderID {
+			continue
+		}
+		klog.V(2).InfoS("Requeuing the node after a change of its instance", "nodeName", node.Name, "providerID", change.ProviderID)
+		if err := ca.AllocateOrOccupyCIDR(node); err != nil {
+			klog.ErrorS(err, "Failed to requeue the node of a changed instance", "nodeName", node.Name)
+		}
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package ipam
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/cloud-provider-gcp/providers/gce"
+)
+
+func TestRequeueChangedInstanceNode(t *testing.T) {
+	const providerID = "gce://test-project/us-central1-b/node0"
+	instance := func(id uint64, network string) *compute.Instance {
+		return &compute.Instance{Id: id, NetworkInterfaces: []*compute.NetworkInterface{{Network: network}}}
+	}
+	testCases := []struct {
+		desc      string
+		change    gce.InstanceChange
+		wantNodes []string
+	}{
+		{
+			desc:      "instance recreated",
+			change:    gce.InstanceChange{Type: gce.InstanceUpdated, ProviderID: providerID, Old: instance(1, "default"), New: instance(2, "default")},
+			wantNodes: []string{"node0"},
+		},
+		{
+			desc:      "network interfaces changed",
+			change:    gce.InstanceChange{Type: gce.InstanceUpdated, ProviderID: providerID, Old: instance(1, "default"), New: instance(1, "red")},
+			wantNodes: []string{"node0"},
+		},
+		{
+			desc:   "status changed",
+			change: gce.InstanceChange{Type: gce.InstanceUpdated, ProviderID: providerID, Old: instance(1, "default"), New: &compute.Instance{Id: 1, Status: "TERMINATED", NetworkInterfaces: []*compute.NetworkInterface{{Network: "default"}}}},
+		},
+		{
+			desc:   "instance of another node",
+			change: gce.InstanceChange{Type: gce.InstanceUpdated, ProviderID: "gce://test-project/us-central1-b/node1", Old: instance(1, "default"), New: instance(2, "default")},
+		},
+		{
+			desc:   "instance added",
+			change: gce.InstanceChange{Type: gce.InstanceAdded, ProviderID: providerID, New: instance(1, "default")},
+		},
+		{
+			desc:   "instance deleted",
+			change: gce.InstanceChange{Type: gce.InstanceDeleted, ProviderID: providerID, Old: instance(1, "default")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nodeInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}}
+			if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+				t.Fatalf("error in test setup, could not add node %s: %v", node.Name, err)
+			}
+			ca := withQueue(&cloudCIDRAllocator{
+				nodeLister: nodeInformer.Lister(),
+			})
+			ca.requeueChangedInstanceNode(tc.change)
+			var gotNodes []string
+			for len(ca.queue.Updates()) > 0 {
+				gotNodes = append(gotNodes, <-ca.queue.Updates())
+			}
+			assert.Equal(t, tc.wantNodes, gotNodes)
+		})
+	}
+}
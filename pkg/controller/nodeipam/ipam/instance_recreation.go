@@ -0,0 +1,53 @@
+package ipam
+
+import (
+	"strconv"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// The ID of the instance the pod CIDRs and multi-network annotations of a node
+// were allocated from is recorded in wellknown.InstanceIDAnnotationKey. An
+// instance recreated with the same name has a new ID, which triggers a full
+// re-allocation of the node and records a wellknown.InstanceRecreatedReason
+// event.
+
+// handleInstanceRecreation drops the state cached for the previous instance of
+// a node whose instance was recreated with the same name, so that the node is
+// re-allocated from scratch from the interfaces of the new instance: the
+// cached annotations and coordination Lease digest are forgotten, the ranges
+// of external IPAM providers are released and the node cleanup hooks are
+// called. It returns true if the instance was recreated.
+func (ca *cloudCIDRAllocator) handleInstanceRecreation(node *v1.Node, instance *compute.Instance) bool {
+	recorded, ok := node.Annotations[wellknown.InstanceIDAnnotationKey]
+	if !ok || instance.Id == 0 || recorded == strconv.FormatUint(instance.Id, 10) {
+		return false
+	}
+	klog.InfoS("Instance of the node was recreated, re-allocating the node", "nodeName", node.Name, "oldInstanceID", recorded, "instanceID", instance.Id)
+	ca.recorder.Eventf(node, v1.EventTypeNormal, wellknown.InstanceRecreatedReason, "Instance recreated (ID %s replaced by %d), re-allocating the node", recorded, instance.Id)
+	ca.annotationCache.forget(node.Name)
+	ca.forgetNodeCoordinationLease(node.Name)
+	ca.releaseExternalRanges(node)
+	if ca.params.NodeCleanupHooks {
+		if err := ca.runNodeCleanupHooks(node.Name); err != nil {
+			klog.Warningf("Failed to clean up the networks of the previous instance of node %s: %v", node.Name, err)
+		}
+	}
+	return true
+}
+
+// recordInstanceID sets wellknown.InstanceIDAnnotationKey on the node once it
+// is allocated from the instance.
+func (ca *cloudCIDRAllocator) recordInstanceID(node *v1.Node, instance *compute.Instance) error {
+	if instance.Id == 0 {
+		return nil
+	}
+	id := strconv.FormatUint(instance.Id, 10)
+	if node.Annotations[wellknown.InstanceIDAnnotationKey] == id {
+		return nil
+	}
+	return ca.publishNodeUpdate(node, nodeUpdate{Annotations: map[string]string{wellknown.InstanceIDAnnotationKey: id}, Reason: auditReasonInstanceID})
+}
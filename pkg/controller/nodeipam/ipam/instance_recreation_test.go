@@ -0,0 +1,94 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestInstanceRecreation(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		annotations   map[string]string
+		instanceID    uint64
+		wantRecreated bool
+		wantID        string
+	}{
+		{
+			desc:       "first allocation records the instance ID",
+			instanceID: 1234,
+			wantID:     "1234",
+		},
+		{
+			desc:        "same instance",
+			annotations: map[string]string{wellknown.InstanceIDAnnotationKey: "1234"},
+			instanceID:  1234,
+			wantID:      "1234",
+		},
+		{
+			desc:          "recreated instance",
+			annotations:   map[string]string{wellknown.InstanceIDAnnotationKey: "1234"},
+			instanceID:    5678,
+			wantRecreated: true,
+			wantID:        "5678",
+		},
+		{
+			desc:        "instance without ID",
+			annotations: map[string]string{wellknown.InstanceIDAnnotationKey: "1234"},
+			wantID:      "1234",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			clientSet := fake.NewSimpleClientset(node)
+			nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+			if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+				t.Fatalf("error in test setup, could not add node: %v", err)
+			}
+			recorder := record.NewFakeRecorder(10)
+			ca := &cloudCIDRAllocator{
+				client:       clientSet,
+				nodeLister:   nodeInformer.Lister(),
+				recorder:     recorder,
+				leaseDigests: map[string]string{node.Name: "digest"},
+			}
+			if _, _, err := ca.annotationCache.marshal(node.Name, nil, nil); err != nil {
+				t.Fatalf("error in test setup, could not cache annotations: %v", err)
+			}
+			instance := &compute.Instance{Id: tc.instanceID}
+
+			if got := ca.handleInstanceRecreation(node, instance); got != tc.wantRecreated {
+				t.Errorf("handleInstanceRecreation() = %t, want %t", got, tc.wantRecreated)
+			}
+			if _, cached := ca.annotationCache.entries[node.Name]; cached == tc.wantRecreated {
+				t.Errorf("cached annotations kept = %t, want %t", cached, !tc.wantRecreated)
+			}
+			if _, ok := ca.leaseDigests[node.Name]; ok == tc.wantRecreated {
+				t.Errorf("lease digest kept = %t, want %t", ok, !tc.wantRecreated)
+			}
+			if got := len(recorder.Events); got != 0 != tc.wantRecreated {
+				t.Errorf("got %d events, want an event: %t", got, tc.wantRecreated)
+			}
+
+			if err := ca.recordInstanceID(node, instance); err != nil {
+				t.Fatalf("recordInstanceID() returned err %v", err)
+			}
+			updated, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the node: %v", err)
+			}
+			if got := updated.Annotations[wellknown.InstanceIDAnnotationKey]; got != tc.wantID {
+				t.Errorf("instance ID annotation = %q, want %q", got, tc.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	nodeIpamSubsystem = "node_ipam_controller"
+
+	// skippedNodeForeignProviderID is the skipped_nodes reason of nodes whose
+	// providerID is not a GCE instance.
+	skippedNodeForeignProviderID = "foreign_provider_id"
+	// skippedNodeTerminalError is the skipped_nodes reason of nodes parked on
+	// a terminal error, see terminalError.
+	skippedNodeTerminalError = "terminal_error"
+	// skippedNodePodRangeExempt is the skipped_nodes reason of nodes exempted
+	// from having a pod alias IP range, see PodRangeExemption.
+	skippedNodePodRangeExempt = "pod_range_exempt"
+)
+
+var (
+	multiNetworkCRDsInstalled = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "multinetwork_crds_installed",
+			Help:           "Gauge set to 1 when the Network and GKENetworkParamSet CRDs are installed and multi-network CIDR allocation is active, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	shadowAllocations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "multinetwork_shadow_allocations_total",
+			Help:           "Number of multi-network allocations computed by the shadow allocator, by allocator and result (match, mismatch or error).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"allocator", "result"},
+	)
+	skippedNodes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "skipped_nodes",
+			Help:           "Number of nodes whose pod CIDRs are not allocated by the cloud CIDR allocator, by reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+	allocatorFeatureEnabled = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "allocator_feature_enabled",
+			Help:           "Gauge set to 1 for the optional features enabled in the CIDR allocator and 0 for the disabled ones, by allocator type and feature.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"allocator", "feature"},
+	)
+	pendingNodes = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_pending_nodes",
+			Help:           "Number of nodes queued or being retried by the cloud CIDR allocator.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	allocationRetries = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_retries_before_success",
+			Help:           "Number of failed updates of a node before the cloud CIDR allocator updated it successfully.",
+			Buckets:        metrics.LinearBuckets(0, 1, updateMaxRetries+1),
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	podCIDRAssignmentLatency = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_pod_cidr_assignment_duration_seconds",
+			Help:           "Time in seconds from the creation of a node to the first assignment of its pod CIDRs by the cloud CIDR allocator.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	networkRolloutPendingNodes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "multinetwork_rollout_pending_nodes",
+			Help:           "Number of nodes not yet requeued by the rollout of a change of a Network, by network.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"network"},
+	)
+	strandedPodCIDRNodes = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "stranded_pod_cidr_nodes",
+			Help:           "Number of nodes whose pod CIDR has not been used by any pod for longer than the stranded pod CIDR threshold.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	podsOutsidePodCIDRs = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "pods_outside_pod_cidrs",
+			Help:           "Number of running pods with an IP outside of the pod CIDRs published on their node.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	checkpointedNodes = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_checkpointed_nodes",
+			Help:           "Number of nodes served from their allocation checkpoint and not yet refreshed from the compute API.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	skippedAliasRanges = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "multinetwork_skipped_alias_ranges_total",
+			Help:           "Number of times an alias IP range of an additional network was skipped as smaller than the maximum alias range mask size allows, by network.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"network"},
+	)
+	nodeAPIRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_node_api_requests_total",
+			Help:           "Number of requests of the cloud CIDR allocator to the node API, by verb.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"verb"},
+	)
+	allocationErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_errors_total",
+			Help:           "Number of failed node updates of the cloud CIDR allocator, by reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+	droppedEvents = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      nodeIpamSubsystem,
+			Name:           "cloud_allocator_dropped_events_total",
+			Help:           "Number of events of the cloud CIDR allocator dropped by the rate limit of their reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+)
+
+var registerMetrics sync.Once
+
+// registerCloudAllocatorMetrics registers the metrics of the cloud CIDR allocator.
+func registerCloudAllocatorMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(multiNetworkCRDsInstalled)
+		legacyregistry.MustRegister(shadowAllocations)
+		legacyregistry.MustRegister(skippedNodes)
+		legacyregistry.MustRegister(pendingNodes)
+		legacyregistry.MustRegister(allocationRetries)
+		legacyregistry.MustRegister(podCIDRAssignmentLatency)
+		legacyregistry.MustRegister(allocationErrors)
+		legacyregistry.MustRegister(nodeAPIRequests)
+		legacyregistry.MustRegister(networkRolloutPendingNodes)
+		legacyregistry.MustRegister(strandedPodCIDRNodes)
+		legacyregistry.MustRegister(podsOutsidePodCIDRs)
+		legacyregistry.MustRegister(checkpointedNodes)
+		legacyregistry.MustRegister(skippedAliasRanges)
+		legacyregistry.MustRegister(droppedEvents)
+	})
+}
+
+var registerFeatureMetrics sync.Once
+
+// registerAllocatorFeatureMetrics registers the metrics shared by all the CIDR allocators.
+func registerAllocatorFeatureMetrics() {
+	registerFeatureMetrics.Do(func() {
+		legacyregistry.MustRegister(allocatorFeatureEnabled)
+	})
+}
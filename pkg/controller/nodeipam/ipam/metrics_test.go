@@ -0,0 +1,86 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPendingNodesMetric(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	// The nodes are not found, their updates succeed.
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Hour).Core().V1().Nodes()
+	ca := withQueue(&cloudCIDRAllocator{nodeLister: nodeInformer.Lister()})
+
+	for _, step := range []struct {
+		desc string
+		do   func()
+		want float64
+	}{
+		{desc: "first node queued", do: func() { ca.queueNode("node0", 0) }, want: 1},
+		{desc: "second node queued", do: func() { ca.queueNode("node1", 0) }, want: 2},
+		{desc: "node queued twice", do: func() { ca.queueNode("node1", 0) }, want: 2},
+		{desc: "first node done", do: func() { ca.queue.Process("node0") }, want: 1},
+		{desc: "second node done", do: func() { ca.queue.Process("node1") }, want: 0},
+	} {
+		step.do()
+		if got, _ := testutil.GetGaugeMetricValue(pendingNodes); got != step.want {
+			t.Errorf("%s: pending nodes = %v, want %v", step.desc, got, step.want)
+		}
+	}
+}
+
+func TestPodCIDRAssignmentLatencyMetric(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node0", CreationTimestamp: metav1.NewTime(now.Add(-90 * time.Second))},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/node0"},
+	}
+	inf := interfaces(defaultVPCName, defaultVPCSubnetName, "10.0.0.2", []*compute.AliasIpRange{{IpCidrRange: "10.10.0.0/24", SubnetworkRangeName: defaultSecondaryRangeA}})
+	clientSet := fake.NewSimpleClientset(node)
+	nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
+	ca := &cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: nodeInformer.Lister(),
+		recorder:   record.NewFakeRecorder(10),
+		instances:  &fakeInstances{instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{inf}}},
+		clock:      testingclock.NewFakeClock(now),
+	}
+
+	countBefore, _ := testutil.GetHistogramMetricCount(podCIDRAssignmentLatency.ObserverMetric)
+	sumBefore, _ := testutil.GetHistogramMetricValue(podCIDRAssignmentLatency.ObserverMetric)
+	if err := ca.updateCIDRAllocation(node.Name); err != nil {
+		t.Fatalf("updateCIDRAllocation() returned err %v", err)
+	}
+	count, _ := testutil.GetHistogramMetricCount(podCIDRAssignmentLatency.ObserverMetric)
+	sum, _ := testutil.GetHistogramMetricValue(podCIDRAssignmentLatency.ObserverMetric)
+	if count-countBefore != 1 || sum-sumBefore != 90 {
+		t.Errorf("got %d observations summing to %vs, want 1 of 90s", count-countBefore, sum-sumBefore)
+	}
+
+	// Nodes whose pod CIDRs are already assigned are not observed again.
+	assigned := node.DeepCopy()
+	assigned.Spec.PodCIDR = "10.10.0.0/24"
+	assigned.Spec.PodCIDRs = []string{"10.10.0.0/24"}
+	if err := nodeInformer.Informer().GetStore().Update(assigned); err != nil {
+		t.Fatalf("failed to update the node: %v", err)
+	}
+	if err := ca.updateCIDRAllocation(node.Name); err != nil {
+		t.Fatalf("updateCIDRAllocation() returned err %v", err)
+	}
+	if got, _ := testutil.GetHistogramMetricCount(podCIDRAssignmentLatency.ObserverMetric); got != count {
+		t.Errorf("got %d observations after the second update, want %d", got, count)
+	}
+}
@@ -0,0 +1,186 @@
+package ipam
+
+import (
+	"encoding/json"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+// multiNetworkAnnotationCache keeps the last serialized multi-network
+// annotations per node so that reconciles producing the same networks do not
+// marshal them again. The zero value is ready to use.
+type multiNetworkAnnotationCache struct {
+	lock    sync.Mutex
+	entries map[string]*multiNetworkAnnotationEntry
+}
+
+type multiNetworkAnnotationEntry struct {
+	northInterfaces        networkv1.NorthInterfacesAnnotation
+	additionalNodeNetworks networkv1.MultiNetworkAnnotation
+	northInterfacesAnn     string
+	additionalNodeNwAnn    string
+}
+
+// get returns the cached serialization of the given annotations for the node, if any.
+func (c *multiNetworkAnnotationCache) get(nodeName string, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation) (*multiNetworkAnnotationEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[nodeName]
+	if !ok || !northInterfacesEqual(entry.northInterfaces, northInterfaces) || !nodeNetworksEqual(entry.additionalNodeNetworks, additionalNodeNetworks) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// marshal returns the serialized north-interfaces and networks annotations,
+// reusing the cached values if the annotations did not change since the last call for the node.
+func (c *multiNetworkAnnotationCache) marshal(nodeName string, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation) (string, string, error) {
+	if entry, ok := c.get(nodeName, northInterfaces, additionalNodeNetworks); ok {
+		return entry.northInterfacesAnn, entry.additionalNodeNwAnn, nil
+	}
+	northInterfaceAnn, err := networkv1.MarshalAnnotation(northInterfaces)
+	if err != nil {
+		return "", "", err
+	}
+	additionalNodeNwAnn, err := networkv1.MarshalAnnotation(additionalNodeNetworks)
+	if err != nil {
+		return "", "", err
+	}
+	entry := &multiNetworkAnnotationEntry{
+		northInterfaces:        append(networkv1.NorthInterfacesAnnotation(nil), northInterfaces...),
+		additionalNodeNetworks: make(networkv1.MultiNetworkAnnotation, 0, len(additionalNodeNetworks)),
+		northInterfacesAnn:     northInterfaceAnn,
+		additionalNodeNwAnn:    additionalNodeNwAnn,
+	}
+	for _, nw := range additionalNodeNetworks {
+		nw.Cidrs = append([]string(nil), nw.Cidrs...)
+		entry.additionalNodeNetworks = append(entry.additionalNodeNetworks, nw)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*multiNetworkAnnotationEntry)
+	}
+	c.entries[nodeName] = entry
+	return northInterfaceAnn, additionalNodeNwAnn, nil
+}
+
+// forget drops the cached annotations of a node.
+func (c *multiNetworkAnnotationCache) forget(nodeName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, nodeName)
+}
+
+// upToDate returns true if the node already carries the given multi-network
+// annotations, in either encoding. Cached serializations are compared
+// directly; otherwise the annotations are streamed against the existing values
+// without being materialized.
+func (c *multiNetworkAnnotationCache) upToDate(node *v1.Node, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation) bool {
+	existingNorthInterfaces, ok, err := networkannotations.NodeAnnotation(node.Annotations, networkv1.NorthInterfacesAnnotationKey)
+	if err != nil || !ok {
+		return false
+	}
+	existingNodeNetworks, ok, err := networkannotations.NodeAnnotation(node.Annotations, networkv1.MultiNetworkAnnotationKey)
+	if err != nil || !ok {
+		return false
+	}
+	if entry, ok := c.get(node.Name, northInterfaces, additionalNodeNetworks); ok {
+		return entry.northInterfacesAnn == existingNorthInterfaces && entry.additionalNodeNwAnn == existingNodeNetworks
+	}
+	return jsonEqual(existingNorthInterfaces, northInterfaces) && jsonEqual(existingNodeNetworks, additionalNodeNetworks)
+}
+
+func northInterfacesEqual(a, b networkv1.NorthInterfacesAnnotation) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeNetworksEqual(a, b networkv1.MultiNetworkAnnotation) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Scope != b[i].Scope || len(a[i].Cidrs) != len(b[i].Cidrs) || (a[i].Cidrs == nil) != (b[i].Cidrs == nil) {
+			return false
+		}
+		if a[i].TrafficClass != b[i].TrafficClass || a[i].Gateway != b[i].Gateway || (a[i].DSCP == nil) != (b[i].DSCP == nil) || (a[i].DSCP != nil && *a[i].DSCP != *b[i].DSCP) {
+			return false
+		}
+		for j := range a[i].Cidrs {
+			if a[i].Cidrs[j] != b[i].Cidrs[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// jsonEqual returns true if the JSON encoding of v is exactly want. The
+// encoding is compared as it is produced instead of being stored.
+func jsonEqual(want string, v interface{}) bool {
+	w := &compareWriter{want: want}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return false
+	}
+	return w.equal()
+}
+
+// compareWriter is an io.Writer that compares the bytes written to it with a
+// reference string. The trailing newline written by json.Encoder is ignored.
+type compareWriter struct {
+	want     string
+	offset   int
+	mismatch bool
+}
+
+func (w *compareWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.mismatch {
+		return n, nil
+	}
+	if end := len(p) - 1; end >= 0 && p[end] == '\n' && w.offset+end == len(w.want) {
+		p = p[:end]
+	}
+	if len(w.want)-w.offset < len(p) || w.want[w.offset:w.offset+len(p)] != string(p) {
+		w.mismatch = true
+		return n, nil
+	}
+	w.offset += len(p)
+	return n, nil
+}
+
+func (w *compareWriter) equal() bool {
+	return !w.mismatch && w.offset == len(w.want)
+}
+
+// ipCapacityUpToDate returns true if the extended IP resources of the node
+// already match the given networks, under the resource names returned by
+// resourceName.
+func ipCapacityUpToDate(node *v1.Node, nodeNetworks networkv1.MultiNetworkAnnotation, resourceName func(network string) v1.ResourceName) bool {
+	want, err := networkIPCapacities(nodeNetworks, resourceName)
+	if err != nil {
+		return false
+	}
+	if publishedIPResources(node).Len() != len(want) {
+		return false
+	}
+	for name, quantity := range want {
+		got, ok := node.Status.Capacity[name]
+		if !ok || got.Value() != quantity.Value() {
+			return false
+		}
+	}
+	return node.Annotations[wellknown.IPResourceNamesAnnotationKey] == customIPResourceNames(want)
+}
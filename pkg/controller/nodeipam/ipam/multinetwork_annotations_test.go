@@ -0,0 +1,191 @@
+package ipam
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+func largeMultiNetworkAnnotations(count int) (networkv1.NorthInterfacesAnnotation, networkv1.MultiNetworkAnnotation) {
+	var northInterfaces networkv1.NorthInterfacesAnnotation
+	var nodeNetworks networkv1.MultiNetworkAnnotation
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("network-%d", i)
+		northInterfaces = append(northInterfaces, networkv1.NorthInterface{Network: name, IpAddress: fmt.Sprintf("10.%d.0.1", i)})
+		nodeNetworks = append(nodeNetworks, networkv1.NodeNetwork{Name: name, Scope: "host-local", Cidrs: []string{fmt.Sprintf("172.%d.1.0/24", i)}})
+	}
+	return northInterfaces, nodeNetworks
+}
+
+func annotatedNode(northInterfaces networkv1.NorthInterfacesAnnotation, nodeNetworks networkv1.MultiNetworkAnnotation) *v1.Node {
+	northInterfaceAnn, _ := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+	nodeNetworksAnn, _ := networkv1.MarshalAnnotation(nodeNetworks)
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node0",
+			Annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: northInterfaceAnn,
+				networkv1.MultiNetworkAnnotationKey:    nodeNetworksAnn,
+			},
+		},
+		Status: v1.NodeStatus{
+			Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+		},
+	}
+	for _, nw := range nodeNetworks {
+		ipCount, _ := networkIPCapacity(nw)
+		node.Status.Capacity[networkIPResourceName(nw.Name)] = *resource.NewQuantity(ipCount, resource.DecimalSI)
+	}
+	return node
+}
+
+func TestMultiNetworkAnnotationsUpToDate(t *testing.T) {
+	northInterfaces, nodeNetworks := largeMultiNetworkAnnotations(3)
+	changedNodeNetworks := append(networkv1.MultiNetworkAnnotation(nil), nodeNetworks...)
+	changedNodeNetworks[1] = networkv1.NodeNetwork{Name: "network-1", Scope: "host-local", Cidrs: []string{"172.1.2.0/24"}}
+	testCases := []struct {
+		desc            string
+		node            *v1.Node
+		northInterfaces networkv1.NorthInterfacesAnnotation
+		nodeNetworks    networkv1.MultiNetworkAnnotation
+		warmCache       bool
+		want            bool
+	}{
+		{
+			desc:            "unchanged annotations",
+			node:            annotatedNode(northInterfaces, nodeNetworks),
+			northInterfaces: northInterfaces,
+			nodeNetworks:    nodeNetworks,
+			want:            true,
+		},
+		{
+			desc:            "unchanged annotations with a warm cache",
+			node:            annotatedNode(northInterfaces, nodeNetworks),
+			northInterfaces: northInterfaces,
+			nodeNetworks:    nodeNetworks,
+			warmCache:       true,
+			want:            true,
+		},
+		{
+			desc:            "changed cidr",
+			node:            annotatedNode(northInterfaces, nodeNetworks),
+			northInterfaces: northInterfaces,
+			nodeNetworks:    changedNodeNetworks,
+			want:            false,
+		},
+		{
+			desc:            "changed cidr with a warm cache",
+			node:            annotatedNode(northInterfaces, nodeNetworks),
+			northInterfaces: northInterfaces,
+			nodeNetworks:    changedNodeNetworks,
+			warmCache:       true,
+			want:            false,
+		},
+		{
+			desc:            "network removed",
+			node:            annotatedNode(northInterfaces, nodeNetworks),
+			northInterfaces: northInterfaces[:2],
+			nodeNetworks:    nodeNetworks[:2],
+			want:            false,
+		},
+		{
+			desc:            "node without annotations",
+			node:            &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+			northInterfaces: northInterfaces,
+			nodeNetworks:    nodeNetworks,
+			want:            false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cache := &multiNetworkAnnotationCache{}
+			if tc.warmCache {
+				if _, _, err := cache.marshal(tc.node.Name, tc.northInterfaces, tc.nodeNetworks); err != nil {
+					t.Fatalf("marshal() returned err %v", err)
+				}
+			}
+			got := cache.upToDate(tc.node, tc.northInterfaces, tc.nodeNetworks) && ipCapacityUpToDate(tc.node, tc.nodeNetworks, networkIPResourceName)
+			if got != tc.want {
+				t.Errorf("up to date = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultiNetworkAnnotationCacheMarshal(t *testing.T) {
+	northInterfaces, nodeNetworks := largeMultiNetworkAnnotations(2)
+	cache := &multiNetworkAnnotationCache{}
+	gotNorth, gotNetworks, err := cache.marshal("node0", northInterfaces, nodeNetworks)
+	if err != nil {
+		t.Fatalf("marshal() returned err %v", err)
+	}
+	wantNorth, _ := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+	wantNetworks, _ := networkv1.MarshalAnnotation(nodeNetworks)
+	if gotNorth != wantNorth || gotNetworks != wantNetworks {
+		t.Errorf("marshal() = %q, %q, want %q, %q", gotNorth, gotNetworks, wantNorth, wantNetworks)
+	}
+	// Mutating the caller's slices must not corrupt the cache.
+	nodeNetworks[0].Cidrs[0] = "192.168.0.0/24"
+	_, gotNetworks, _ = cache.marshal("node0", northInterfaces, nodeNetworks)
+	if gotNetworks == wantNetworks {
+		t.Errorf("marshal() returned the stale cached annotation after the networks changed")
+	}
+	cache.forget("node0")
+	if _, ok := cache.get("node0", northInterfaces, nodeNetworks); ok {
+		t.Errorf("get() found the node after forget()")
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	northInterfaces, _ := largeMultiNetworkAnnotations(2)
+	ann, _ := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+	for _, tc := range []struct {
+		want string
+		v    interface{}
+		eq   bool
+	}{
+		{want: ann, v: northInterfaces, eq: true},
+		{want: ann + " ", v: northInterfaces, eq: false},
+		{want: ann[:len(ann)-1], v: northInterfaces, eq: false},
+		{want: "null", v: networkv1.NorthInterfacesAnnotation(nil), eq: true},
+		{want: "[]", v: networkv1.NorthInterfacesAnnotation(nil), eq: false},
+	} {
+		if got := jsonEqual(tc.want, tc.v); got != tc.eq {
+			t.Errorf("jsonEqual(%q) = %v, want %v", tc.want, got, tc.eq)
+		}
+	}
+}
+
+func BenchmarkMultiNetworkAnnotationsUpToDate(b *testing.B) {
+	for _, count := range []int{4, 32, 128} {
+		northInterfaces, nodeNetworks := largeMultiNetworkAnnotations(count)
+		node := annotatedNode(northInterfaces, nodeNetworks)
+		b.Run(fmt.Sprintf("marshal/%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				northInterfaceAnn, _ := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+				nodeNetworksAnn, _ := networkv1.MarshalAnnotation(nodeNetworks)
+				_ = northInterfaceAnn == node.Annotations[networkv1.NorthInterfacesAnnotationKey] && nodeNetworksAnn == node.Annotations[networkv1.MultiNetworkAnnotationKey]
+			}
+		})
+		b.Run(fmt.Sprintf("stream/%d", count), func(b *testing.B) {
+			cache := &multiNetworkAnnotationCache{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cache.upToDate(node, northInterfaces, nodeNetworks)
+			}
+		})
+		b.Run(fmt.Sprintf("cached/%d", count), func(b *testing.B) {
+			cache := &multiNetworkAnnotationCache{}
+			cache.marshal(node.Name, northInterfaces, nodeNetworks)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cache.upToDate(node, northInterfaces, nodeNetworks)
+			}
+		})
+	}
+}
@@ -2,29 +2,38 @@ package ipam
 
 import (
 	"fmt"
-	"strings"
 
 	compute "google.golang.org/api/compute/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
 	"k8s.io/klog/v2"
 )
 
 // PerformMultiNetworkCIDRAllocation allots pod CIDRs for all the networks that a node is connected to. It handles IPv6 only for default-network for now.
-func (ca *cloudCIDRAllocator) PerformMultiNetworkCIDRAllocation(node *v1.Node, interfaces []*compute.NetworkInterface) (defaultNwCIDRs []string, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation, err error) {
+// With node-local IPAM, the secondary ranges of additional networks are returned as delegatedRanges instead of being allotted.
+func (ca *cloudCIDRAllocator) PerformMultiNetworkCIDRAllocation(node *v1.Node, interfaces []*compute.NetworkInterface) (defaultNwCIDRs []string, northInterfaces networkv1.NorthInterfacesAnnotation, additionalNodeNetworks networkv1.MultiNetworkAnnotation, delegatedRanges DelegatedRangesAnnotation, err error) {
 	k8sNetworksList, err := ca.networksLister.List(labels.Everything())
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("error fetching networks: %v", err)
-	}
-	networks := make([]*networkv1.Network, 0)
-	// ignore networks that are under deletion.
-	// TODO: Watch network objects to react when networks are deleted.
-	for _, network := range k8sNetworksList {
-		if network.ObjectMeta.DeletionTimestamp.IsZero() {
-			networks = append(networks, network)
-		}
+		return nil, nil, nil, nil, fmt.Errorf("error fetching networks: %v", err)
 	}
+	// ignore networks that are under deletion or target other clusters.
+	networks := ca.activeNetworks(k8sNetworksList)
+	urlDefaults := ca.urlDefaults()
+	// Networks referring to a range already claimed by another Network are ignored.
+	var conflicts map[string]networkConflict
+	networks, conflicts = resolveNetworkConflicts(networks, ca.isDefaultNetwork, ca.gnpLister, urlDefaults)
+	ca.reportNetworkConflicts(k8sNetworksList, conflicts)
+	// Paused networks are held on the node as they are, see holdPausedNetworks.
+	ca.reportPausedNetworks(k8sNetworksList)
+	networks = ca.unpausedNetworks(networks)
+	// Networks whose node selector does not match the node are not attached to it.
+	networks = ca.nodeNetworks(node, networks)
 	// Fetch the GKENetworkParams for every k8s-network object.
 	// Match the fetched GKENetworkParams object with the interfaces on the node
 	// to build the per-network north-interface and node-network annotations useful for IPAM.
@@ -37,22 +46,52 @@ func (ca *cloudCIDRAllocator) PerformMultiNetworkCIDRAllocation(node *v1.Node, i
 			klog.V(4).Infof("allotting pod cidrs for network %s", network.Name)
 			gnp, err := ca.gnpLister.Get(network.Spec.ParametersRef.Name)
 			if err != nil {
-				return nil, nil, nil, err
+				return nil, nil, nil, nil, networkAllocationErrorf(ErrParamsInvalid, network.Name, "failed to get GKENetworkParamSet %s of network %s: %w", network.Spec.ParametersRef.Name, network.Name, err)
+			}
+			if !interfaceInSubnet(inf, gnp, urlDefaults) {
+				continue
+			}
+			if !gkenetworkparamset.SubnetReady(gnp) {
+				klog.V(4).Infof("subnet of GKENetworkParamSet %s cannot be used for pod networking, skipping network %s", gnp.Name, network.Name)
+				continue
+			}
+			if !ca.isDefaultNetwork(network) && !ca.paramsReady(gnp) {
+				klog.V(4).Infof("GKENetworkParamSet %s is not Ready, skipping network %s", gnp.Name, network.Name)
+				continue
+			}
+			if !interfaceMatchesNicType(inf, gnp) {
+				klog.V(4).Infof("interface %s of type %q does not have the vNIC type required by network %s", inf.Name, inf.NicType, network.Name)
+				continue
+			}
+			// Accelerator fabric networks only get a north interface.
+			if ca.interfaceOnlyNetwork(network) {
+				northInterfaces = append(northInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				continue
 			}
-			if resourceName(inf.Network) != resourceName(gnp.Spec.VPC) || resourceName(inf.Subnetwork) != resourceName(gnp.Spec.VPCSubnet) {
+			// The ranges of networks with an external IPAM provider are not alias IP ranges.
+			if ca.externalIPAMEndpoint(network) != "" {
+				cidrs, err := ca.allocateExternalRanges(node, network, inf)
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+				northInterfaces = append(northInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				additionalNodeNetworks = append(additionalNodeNetworks, networkv1.NodeNetwork{Name: network.Name, Scope: "host-local", Cidrs: cidrs})
 				continue
 			}
 			klog.V(2).Infof("interface %s matched, proceeding to find a secondary range", inf.Name)
 			// TODO: Handle IPv6 in future.
-			var secondaryRangeNames []string
-			if gnp.Spec.PodIPv4Ranges != nil {
-				secondaryRangeNames = gnp.Spec.PodIPv4Ranges.RangeNames
-			}
+			secondaryRangeNames := zonalRangeNames(gnp, nodeZone(node))
 			// In case of host networking, the node interfaces do not have the secondary ranges. We still need to update the
 			// north-interface information on the node.
-			if len(secondaryRangeNames) == 0 && !networkv1.IsDefaultNetwork(network.Name) {
+			if len(secondaryRangeNames) == 0 && !ca.isDefaultNetwork(network) {
 				northInterfaces = append(northInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
 			}
+			// The node agent attaches the alias IP range of delegated networks.
+			if len(secondaryRangeNames) > 0 && !ca.isDefaultNetwork(network) && ca.params.NodeLocalIPAM {
+				northInterfaces = append(northInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				delegatedRanges = append(delegatedRanges, DelegatedRange{Network: network.Name, Interface: inf.Name, Subnetwork: inf.Subnetwork, RangeNames: secondaryRangeNames, MaskSize: perNodeMaskSize(gnp)})
+				continue
+			}
 			// Each secondary range in a subnet corresponds to a pod-network. AliasIPRanges list on a node interface consists of IP ranges that belong to multiple secondary ranges (pod-networks).
 			// Match the secondary range names of interface and GKENetworkParams and set the right IpCidrRange for current network.
 			for _, secondaryRangeName := range secondaryRangeNames {
@@ -61,9 +100,20 @@ func (ca *cloudCIDRAllocator) PerformMultiNetworkCIDRAllocation(node *v1.Node, i
 					continue
 				}
 				klog.V(2).Infof("found an allocatable secondary range for the interface on network")
-				if networkv1.IsDefaultNetwork(network.Name) {
+				if maskSize := perNodeMaskSize(gnp); !ca.isDefaultNetwork(network) && !aliasMatchesMaskSize(ipRange.IpCidrRange, maskSize) {
+					klog.Warningf("alias IP range %s of interface %s on node %s does not have the /%d mask size of network %s, skipping it", ipRange.IpCidrRange, inf.Name, node.Name, maskSize, network.Name)
+					ca.recordNetworkEvent(network.Name, node.Name, wellknown.PodCIDRMaskSizeMismatchReason, fmt.Sprintf("Alias IP ranges of secondary range %s do not have the /%d mask size of the network", secondaryRangeName, maskSize))
+					continue
+				}
+				if maxMaskSize := ca.params.MaxAliasRangeMaskSize; !ca.isDefaultNetwork(network) && aliasRangeTooSmall(ipRange.IpCidrRange, maxMaskSize) {
+					klog.Warningf("alias IP range %s of interface %s on node %s is smaller than a /%d range, skipping network %s", ipRange.IpCidrRange, inf.Name, node.Name, maxMaskSize, network.Name)
+					ca.recordNetworkEvent(network.Name, node.Name, wellknown.AliasRangeTooSmallReason, fmt.Sprintf("Alias IP ranges of secondary range %s are smaller than a /%d range, their pod IPs are not published", secondaryRangeName, maxMaskSize))
+					skippedAliasRanges.WithLabelValues(network.Name).Inc()
+					continue
+				}
+				if ca.isDefaultNetwork(network) {
 					defaultNwCIDRs = append(defaultNwCIDRs, ipRange.IpCidrRange)
-					ipv6Addr := ca.cloud.GetIPV6Address(inf)
+					ipv6Addr := gce.InterfaceIPv6PodCIDR(inf)
 					if ipv6Addr != nil {
 						defaultNwCIDRs = append(defaultNwCIDRs, ipv6Addr.String())
 					}
@@ -75,10 +125,22 @@ func (ca *cloudCIDRAllocator) PerformMultiNetworkCIDRAllocation(node *v1.Node, i
 			}
 		}
 	}
-	return defaultNwCIDRs, northInterfaces, additionalNodeNetworks, nil
+	return defaultNwCIDRs, northInterfaces, additionalNodeNetworks, delegatedRanges, nil
+}
+
+// urlDefaults returns the project and region used to qualify the partial VPC
+// and subnet references of GKENetworkParamSets.
+func (ca *cloudCIDRAllocator) urlDefaults() gcpurl.Defaults {
+	if ca.cloud == nil {
+		return gcpurl.Defaults{}
+	}
+	return gcpurl.Defaults{Project: ca.cloud.NetworkProjectID(), Region: ca.cloud.Region()}
 }
 
-func resourceName(name string) string {
-	parts := strings.Split(name, "/")
-	return parts[len(parts)-1]
+// paramsReady returns false if RequireReadyParams is set and the
+// GKENetworkParamSet does not have a true Ready condition yet, e.g. while
+// the infrastructure of its network is still being provisioned. The default
+// network is never skipped.
+func (ca *cloudCIDRAllocator) paramsReady(gnp *networkv1alpha1.GKENetworkParamSet) bool {
+	return !ca.params.RequireReadyParams || gkenetworkparamset.Ready(gnp)
 }
@@ -10,6 +10,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
 	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
 	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
 	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
 )
@@ -82,6 +83,21 @@ func interfaces(network, subnetwork, networkIP string, aliasIPRanges []*compute.
 	}
 }
 
+// withSubnetNotReady marks the subnet of the GKENetworkParamSet as unusable for pod networking.
+func withSubnetNotReady(gnp *networkv1alpha1.GKENetworkParamSet) *networkv1alpha1.GKENetworkParamSet {
+	if gnp.Annotations == nil {
+		gnp.Annotations = map[string]string{}
+	}
+	gnp.Annotations[wellknown.ConditionsAnnotationKey] = `[{"type":"SubnetReady","status":"False","reason":"IncompatibleSubnetPurpose","message":"","lastTransitionTime":null}]`
+	return gnp
+}
+
+// withParamsReady sets a true Ready condition in the status of the GKENetworkParamSet.
+func withParamsReady(gnp *networkv1alpha1.GKENetworkParamSet) *networkv1alpha1.GKENetworkParamSet {
+	gnp.Status.Conditions = []metav1.Condition{{Type: wellknown.ParamsReadyConditionType, Status: metav1.ConditionTrue, Reason: "ParamsReady"}}
+	return gnp
+}
+
 func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 	node := &v1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "node0"},
@@ -95,8 +111,75 @@ func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 		wantDefaultNwPodCIDRs      []string
 		wantNorthInterfaces        networkv1.NorthInterfacesAnnotation
 		wantAdditionalNodeNetworks networkv1.MultiNetworkAnnotation
+		nodeLocalIPAM              bool
+		defaultNetworkName         string
+		requireReadyParams         bool
+		wantDelegatedRanges        DelegatedRangesAnnotation
 		expectErr                  bool
 	}{
+		{
+			desc:               "configured default network name - the network is allotted as the default one",
+			defaultNetworkName: "primary",
+			networks: []*networkv1.Network{
+				network("primary", defaultGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
+		{
+			desc: "default network annotation - the network is allotted as the default one",
+			networks: []*networkv1.Network{
+				withDefaultNetworkAnnotation(network("primary", defaultGKENetworkParamsName)),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
+		{
+			desc:          "node-local IPAM - additional network ranges are delegated while the default network is allotted",
+			nodeLocalIPAM: true,
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", nil),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   redNetworkName,
+					IpAddress: "10.1.1.1",
+				},
+			},
+			wantDelegatedRanges: DelegatedRangesAnnotation{
+				{
+					Network:    redNetworkName,
+					Subnetwork: redVPCSubnetName,
+					RangeNames: []string{redSecondaryRangeA, redSecondaryRangeB},
+				},
+			},
+		},
 		{
 			desc: "default network only - should return default network cidrs and no multi-network annotations",
 			networks: []*networkv1.Network{
@@ -112,6 +195,204 @@ func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 			},
 			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
 		},
+		{
+			desc: "networks on distinct subnets of the same VPC - each interface resolves the network of its subnet",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+				network(redCopyNetworkName, redCopyGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redSecondVPCSubnetName, []string{redSecondaryRangeA}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redSecondVPCSubnetName, "10.1.2.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.12.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{Network: redCopyNetworkName, IpAddress: "10.1.2.1"},
+				{Network: redNetworkName, IpAddress: "10.1.1.1"},
+			},
+			wantAdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+				{Name: redCopyNetworkName, Scope: "host-local", Cidrs: []string{"172.12.1.0/24"}},
+				{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}},
+			},
+		},
+		{
+			desc: "additional network requiring gVNIC - virtio interface is skipped",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+				network(blueNetworkName, blueGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				withNicType(gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}), "GVNIC"),
+				withNicType(gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, nil), "GVNIC"),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+				nicType(interfaces(blueVPCName, blueVPCSubnetName, "20.1.1.1", nil), "GVNIC"),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   blueNetworkName,
+					IpAddress: "20.1.1.1",
+				},
+			},
+		},
+		{
+			desc: "additional network on a subnet that is not ready - network is skipped",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				withSubnetNotReady(gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB})),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
+		{
+			desc:               "required ready params - the network whose params are not Ready is skipped",
+			requireReadyParams: true,
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+				network(blueNetworkName, blueGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+				withParamsReady(gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, nil)),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+				interfaces(blueVPCName, blueVPCSubnetName, "20.1.1.1", nil),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   blueNetworkName,
+					IpAddress: "20.1.1.1",
+				},
+			},
+		},
+		{
+			desc: "additional network whose node selector does not match the node - network is skipped",
+			networks: []*networkv1.Network{
+				withNodeSelector(network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName), map[string]string{"pool": "b"}),
+				withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{"pool": "b"}),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
+		{
+			desc: "accelerator fabric network - only the north interface is published",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				withIPAllocation(network(redNetworkName, redGKENetworkParamsName), "None"),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   redNetworkName,
+					IpAddress: "10.1.1.1",
+				},
+			},
+		},
+		{
+			desc:          "accelerator fabric network with node-local IPAM - no range is delegated",
+			nodeLocalIPAM: true,
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				withIPAllocation(network(redNetworkName, redGKENetworkParamsName), "none"),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", nil),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   redNetworkName,
+					IpAddress: "10.1.1.1",
+				},
+			},
+		},
+		{
+			desc: "default network with IP allocation disabled - pod CIDRs are still allotted",
+			networks: []*networkv1.Network{
+				withIPAllocation(network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName), "None"),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
 		{
 			desc: "one additional network along with default network",
 			networks: []*networkv1.Network{
@@ -258,6 +539,59 @@ func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "interfaces in another project with the same VPC and subnet names should be ignored",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces("https://www.googleapis.com/compute/v1/projects/otherProject/global/networks/red", "https://www.googleapis.com/compute/v1/projects/otherProject/regions/us-central1/subnetworks/red", "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+		},
+		{
+			desc: "fully qualified interface URLs match relative GKENetworkParams references",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			gkeNwParams: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA, defaultSecondaryRangeB}),
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+			},
+			interfaces: []*compute.NetworkInterface{
+				interfaces("https://www.googleapis.com/compute/v1/"+defaultVPCName, "https://www.googleapis.com/compute/v1/"+defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+					{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+				}),
+				interfaces("https://www.googleapis.com/compute/v1/"+redVPCName, "https://www.googleapis.com/compute/v1/"+redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+					{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+				}),
+			},
+			wantDefaultNwPodCIDRs: []string{"10.11.1.0/24"},
+			wantNorthInterfaces: networkv1.NorthInterfacesAnnotation{
+				{
+					Network:   redNetworkName,
+					IpAddress: "10.1.1.1",
+				},
+			},
+			wantAdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+				{
+					Name:  redNetworkName,
+					Scope: "host-local",
+					Cidrs: []string{"172.11.1.0/24"},
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -281,9 +615,10 @@ func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 			ca := &cloudCIDRAllocator{
 				networksLister: nwInformer.Lister(),
 				gnpLister:      gnpInformer.Lister(),
+				params:         CloudAllocatorParams{NodeLocalIPAM: tc.nodeLocalIPAM, DefaultNetworkName: tc.defaultNetworkName, RequireReadyParams: tc.requireReadyParams},
 			}
 			// test
-			gotDefaultNwCIDRs, gotNorthInterfaces, gotAdditionalNodeNetworks, err := ca.PerformMultiNetworkCIDRAllocation(node, tc.interfaces)
+			gotDefaultNwCIDRs, gotNorthInterfaces, gotAdditionalNodeNetworks, gotDelegatedRanges, err := ca.PerformMultiNetworkCIDRAllocation(node, tc.interfaces)
 			if tc.expectErr && err == nil {
 				t.Fatalf("expected error")
 			} else if !tc.expectErr && err != nil {
@@ -292,6 +627,7 @@ func TestPerformMultiNetworkCIDRAllocation(t *testing.T) {
 			assert.Equal(t, tc.wantDefaultNwPodCIDRs, gotDefaultNwCIDRs)
 			assert.Equal(t, tc.wantNorthInterfaces, gotNorthInterfaces)
 			assert.Equal(t, tc.wantAdditionalNodeNetworks, gotAdditionalNodeNetworks)
+			assert.Equal(t, tc.wantDelegatedRanges, gotDelegatedRanges)
 		})
 	}
 }
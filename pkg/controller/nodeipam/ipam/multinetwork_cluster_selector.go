@@ -0,0 +1,62 @@
+package ipam
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// The value of wellknown.ClusterSelectorAnnotationKey is a label selector, e.g.
+// "name in (prod-a, prod-b)", evaluated against the labels of the cluster:
+// clusterNameLabel, clusterProjectLabel and clusterRegionLabel. Networks
+// without the annotation target every cluster, and the default network is
+// never filtered.
+
+const (
+	clusterNameLabel    = "name"
+	clusterProjectLabel = "project"
+	clusterRegionLabel  = "region"
+)
+
+// clusterLabels returns the labels matched by the cluster selector of Networks.
+func (ca *cloudCIDRAllocator) clusterLabels() labels.Set {
+	set := labels.Set{clusterNameLabel: ca.params.ClusterName}
+	if ca.cloud != nil {
+		set[clusterProjectLabel] = ca.cloud.ProjectID()
+		set[clusterRegionLabel] = ca.cloud.Region()
+	}
+	return set
+}
+
+// targetsCluster returns false if the cluster selector of the Network does not
+// match this cluster. Networks with an invalid selector are ignored.
+func (ca *cloudCIDRAllocator) targetsCluster(network *networkv1.Network) bool {
+	value, ok := network.Annotations[wellknown.ClusterSelectorAnnotationKey]
+	if !ok || ca.isDefaultNetwork(network) {
+		return true
+	}
+	selector, err := labels.Parse(value)
+	if err != nil {
+		klog.Warningf("Ignoring network %s with invalid %s annotation %q: %v", network.Name, wellknown.ClusterSelectorAnnotationKey, value, err)
+		return false
+	}
+	return selector.Matches(ca.clusterLabels())
+}
+
+// activeNetworks returns the Networks that are not being deleted and target
+// this cluster.
+func (ca *cloudCIDRAllocator) activeNetworks(networks []*networkv1.Network) []*networkv1.Network {
+	active := make([]*networkv1.Network, 0, len(networks))
+	for _, network := range networks {
+		if !network.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if !ca.targetsCluster(network) {
+			klog.V(4).Infof("network %s does not target cluster %q, skipping it", network.Name, ca.params.ClusterName)
+			continue
+		}
+		active = append(active, network)
+	}
+	return active
+}
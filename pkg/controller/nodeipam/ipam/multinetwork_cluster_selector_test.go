@@ -0,0 +1,98 @@
+package ipam
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func withClusterSelector(network *networkv1.Network, selector string) *networkv1.Network {
+	if network.Annotations == nil {
+		network.Annotations = map[string]string{}
+	}
+	network.Annotations[wellknown.ClusterSelectorAnnotationKey] = selector
+	return network
+}
+
+func TestActiveNetworks(t *testing.T) {
+	now := metav1.Now()
+	deleted := network(blueNetworkName, blueGKENetworkParamsName)
+	deleted.DeletionTimestamp = &now
+	testCases := []struct {
+		desc     string
+		networks []*networkv1.Network
+		want     []string
+	}{
+		{
+			desc: "networks without selector target every cluster",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			want: []string{networkv1.DefaultPodNetworkName, redNetworkName},
+		},
+		{
+			desc: "networks being deleted are ignored",
+			networks: []*networkv1.Network{
+				network(redNetworkName, redGKENetworkParamsName),
+				deleted,
+			},
+			want: []string{redNetworkName},
+		},
+		{
+			desc: "networks selecting other clusters are ignored",
+			networks: []*networkv1.Network{
+				withClusterSelector(network(redNetworkName, redGKENetworkParamsName), "name in (prod-a, prod-b)"),
+				withClusterSelector(network(blueNetworkName, blueGKENetworkParamsName), "name=prod-b"),
+			},
+			want: []string{redNetworkName},
+		},
+		{
+			desc: "negative selector",
+			networks: []*networkv1.Network{
+				withClusterSelector(network(redNetworkName, redGKENetworkParamsName), "name!=prod-a"),
+			},
+			want: []string{},
+		},
+		{
+			desc: "networks with an invalid selector are ignored",
+			networks: []*networkv1.Network{
+				withClusterSelector(network(redNetworkName, redGKENetworkParamsName), "name in prod-a"),
+			},
+			want: []string{},
+		},
+		{
+			desc: "the default network is never filtered",
+			networks: []*networkv1.Network{
+				withClusterSelector(network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName), "name=prod-b"),
+			},
+			want: []string{networkv1.DefaultPodNetworkName},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ca := &cloudCIDRAllocator{params: CloudAllocatorParams{ClusterName: "prod-a"}}
+			got := []string{}
+			for _, network := range ca.activeNetworks(tc.networks) {
+				got = append(got, network.Name)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("activeNetworks() returned unexpected networks (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetworkChangedClusterSelector(t *testing.T) {
+	oldNetwork := network(redNetworkName, redGKENetworkParamsName)
+	newNetwork := withClusterSelector(network(redNetworkName, redGKENetworkParamsName), "name=prod-a")
+	if !networkChanged(oldNetwork, newNetwork) {
+		t.Errorf("networkChanged() = false after adding a cluster selector, want true")
+	}
+	if networkChanged(newNetwork, newNetwork.DeepCopy()) {
+		t.Errorf("networkChanged() = true for identical networks, want false")
+	}
+}
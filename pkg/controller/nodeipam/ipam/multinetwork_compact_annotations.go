@@ -0,0 +1,102 @@
+package ipam
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+const (
+	// AnnotationEncodingPerKey publishes the north interfaces and the
+	// additional networks of the nodes in the
+	// networkv1.NorthInterfacesAnnotationKey and
+	// networkv1.MultiNetworkAnnotationKey annotations.
+	AnnotationEncodingPerKey = "per-key"
+	// AnnotationEncodingCompact publishes them in the
+	// wellknown.CompactAnnotationKey annotation instead.
+	AnnotationEncodingCompact = "compact"
+	// AnnotationEncodingCompactGzip publishes them compressed in the
+	// wellknown.CompactAnnotationKey annotation.
+	AnnotationEncodingCompactGzip = "compact-gzip"
+)
+
+// validateAnnotationEncoding returns an error if the encoding of the
+// multi-network annotations is not supported, or cannot be used with
+// node-local IPAM, whose node agent owns the networks annotation.
+func validateAnnotationEncoding(encoding string, nodeLocalIPAM bool) error {
+	switch encoding {
+	case "", AnnotationEncodingPerKey:
+		return nil
+	case AnnotationEncodingCompact, AnnotationEncodingCompactGzip:
+		if nodeLocalIPAM {
+			return fmt.Errorf("annotation encoding %q is not supported with node-local IPAM", encoding)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported annotation encoding %q", encoding)
+}
+
+// compactAnnotations returns true if the multi-network annotations are
+// published in the compact encoding.
+func (ca *cloudCIDRAllocator) compactAnnotations() bool {
+	return ca.params.AnnotationEncoding == AnnotationEncodingCompact || ca.params.AnnotationEncoding == AnnotationEncodingCompactGzip
+}
+
+// annotationEncodingUpToDate returns true if the multi-network annotations of
+// the node are only published in the configured encoding, so that switching
+// the encoding rewrites the annotations of every node.
+func (ca *cloudCIDRAllocator) annotationEncodingUpToDate(node *v1.Node) bool {
+	compact, ok := node.Annotations[wellknown.CompactAnnotationKey]
+	if !ca.compactAnnotations() {
+		return !ok
+	}
+	if !ok || compact == "" {
+		return false
+	}
+	if _, ok := node.Annotations[networkv1.NorthInterfacesAnnotationKey]; ok {
+		return false
+	}
+	if _, ok := node.Annotations[networkv1.MultiNetworkAnnotationKey]; ok {
+		return false
+	}
+	compressed := compact[0] != '{'
+	return compressed == (ca.params.AnnotationEncoding == AnnotationEncodingCompactGzip)
+}
+
+// encodeMultiNetworkAnnotations adds the serialized multi-network annotations
+// to the update in the configured encoding, and removes the annotations of
+// the other encoding from the node.
+func (ca *cloudCIDRAllocator) encodeMultiNetworkAnnotations(node *v1.Node, update *nodeUpdate, northInterfaceAnn, additionalNodeNwAnn string) error {
+	if update.Annotations == nil {
+		update.Annotations = make(map[string]string, 2)
+	}
+	if !ca.compactAnnotations() {
+		update.Annotations[networkv1.NorthInterfacesAnnotationKey] = northInterfaceAnn
+		update.Annotations[networkv1.MultiNetworkAnnotationKey] = additionalNodeNwAnn
+		update.RemovedAnnotations = presentAnnotations(node, wellknown.CompactAnnotationKey)
+		ca.encodeLegacyAnnotations(node, update, northInterfaceAnn, additionalNodeNwAnn)
+		return nil
+	}
+	compact, err := networkannotations.EncodeCompact(northInterfaceAnn, additionalNodeNwAnn, ca.params.AnnotationEncoding == AnnotationEncodingCompactGzip)
+	if err != nil {
+		return err
+	}
+	update.Annotations[wellknown.CompactAnnotationKey] = compact
+	update.RemovedAnnotations = presentAnnotations(node, networkv1.NorthInterfacesAnnotationKey, networkv1.MultiNetworkAnnotationKey)
+	ca.encodeLegacyAnnotations(node, update, northInterfaceAnn, additionalNodeNwAnn)
+	return nil
+}
+
+// presentAnnotations returns the keys the node carries annotations for.
+func presentAnnotations(node *v1.Node, keys ...string) []string {
+	var present []string
+	for _, key := range keys {
+		if _, ok := node.Annotations[key]; ok {
+			present = append(present, key)
+		}
+	}
+	return present
+}
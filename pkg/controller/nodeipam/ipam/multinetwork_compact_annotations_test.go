@@ -0,0 +1,148 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+func TestValidateAnnotationEncoding(t *testing.T) {
+	testCases := []struct {
+		encoding      string
+		nodeLocalIPAM bool
+		wantErr       bool
+	}{
+		{encoding: ""},
+		{encoding: AnnotationEncodingPerKey, nodeLocalIPAM: true},
+		{encoding: AnnotationEncodingCompact},
+		{encoding: AnnotationEncodingCompactGzip},
+		{encoding: AnnotationEncodingCompactGzip, nodeLocalIPAM: true, wantErr: true},
+		{encoding: "gzip", wantErr: true},
+	}
+	for _, tc := range testCases {
+		if err := validateAnnotationEncoding(tc.encoding, tc.nodeLocalIPAM); (err != nil) != tc.wantErr {
+			t.Errorf("validateAnnotationEncoding(%q, %v) returned err %v, want error %v", tc.encoding, tc.nodeLocalIPAM, err, tc.wantErr)
+		}
+	}
+}
+
+func TestUpdateMultiNetworkAnnotationsEncoding(t *testing.T) {
+	northInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.1.1.1"}}
+	nodeNetworks := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}}
+	northInterfacesAnn, err := networkv1.MarshalAnnotation(northInterfaces)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	nodeNetworksAnn, err := networkv1.MarshalAnnotation(nodeNetworks)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	perKey := map[string]string{
+		networkv1.NorthInterfacesAnnotationKey: northInterfacesAnn,
+		networkv1.MultiNetworkAnnotationKey:    nodeNetworksAnn,
+	}
+	compact := func(compress bool) map[string]string {
+		ann, err := networkannotations.EncodeCompact(northInterfacesAnn, nodeNetworksAnn, compress)
+		if err != nil {
+			t.Fatalf("EncodeCompact() returned err %v", err)
+		}
+		return map[string]string{wellknown.CompactAnnotationKey: ann}
+	}
+	testCases := []struct {
+		desc        string
+		encoding    string
+		annotations map[string]string
+		want        map[string]string
+		wantPatch   bool
+	}{
+		{
+			desc:      "per-key annotations",
+			want:      perKey,
+			wantPatch: true,
+		},
+		{
+			desc:        "per-key annotations up to date",
+			annotations: perKey,
+			want:        perKey,
+		},
+		{
+			desc:        "per-key annotations replacing the compact annotation",
+			encoding:    AnnotationEncodingPerKey,
+			annotations: compact(true),
+			want:        perKey,
+			wantPatch:   true,
+		},
+		{
+			desc:      "compact annotation",
+			encoding:  AnnotationEncodingCompact,
+			want:      compact(false),
+			wantPatch: true,
+		},
+		{
+			desc:        "compact annotation replacing the per-key annotations",
+			encoding:    AnnotationEncodingCompact,
+			annotations: perKey,
+			want:        compact(false),
+			wantPatch:   true,
+		},
+		{
+			desc:        "compressed compact annotation replacing the compact annotation",
+			encoding:    AnnotationEncodingCompactGzip,
+			annotations: compact(false),
+			want:        compact(true),
+			wantPatch:   true,
+		},
+		{
+			desc:        "compressed compact annotation up to date",
+			encoding:    AnnotationEncodingCompactGzip,
+			annotations: compact(true),
+			want:        compact(true),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			// The capacity is up to date, so that only the annotations are patched.
+			node.Status.Capacity, err = networkIPCapacities(nodeNetworks, networkIPResourceName)
+			if err != nil {
+				t.Fatalf("networkIPCapacities() returned err %v", err)
+			}
+			fakeNodeHandler := &testutil.FakeNodeHandler{Existing: []*v1.Node{node}, Clientset: fake.NewSimpleClientset()}
+			ca := &cloudCIDRAllocator{
+				client:         fakeNodeHandler,
+				networksLister: networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking().V1().Networks().Lister(),
+				params:         CloudAllocatorParams{AnnotationEncoding: tc.encoding},
+			}
+			if err := ca.updateMultiNetworkAnnotations(node, nil, northInterfaces, nodeNetworks); err != nil {
+				t.Fatalf("updateMultiNetworkAnnotations() returned err %v", err)
+			}
+			updated := fakeNodeHandler.GetUpdatedNodesCopy()
+			if gotPatch := len(updated) > 0; gotPatch != tc.wantPatch {
+				t.Fatalf("updateMultiNetworkAnnotations() patched the node: %v, want %v", gotPatch, tc.wantPatch)
+			}
+			if !tc.wantPatch {
+				return
+			}
+			if diff := cmp.Diff(tc.want, updated[0].Annotations); diff != "" {
+				t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+			}
+			published, err := PublishedAllocation(updated[0])
+			if err != nil {
+				t.Fatalf("PublishedAllocation() returned err %v", err)
+			}
+			if diff := cmp.Diff(nodeNetworks, published.AdditionalNodeNetworks); diff != "" {
+				t.Errorf("published additional node networks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
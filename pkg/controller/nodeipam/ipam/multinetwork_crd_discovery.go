@@ -0,0 +1,68 @@
+package ipam
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/klog/v2"
+)
+
+// networkCRDResources are the resources that must be served for multi-network CIDR allocation, keyed by group version.
+var networkCRDResources = map[string]string{
+	networkv1.SchemeGroupVersion.String():       "networks",
+	networkv1alpha1.SchemeGroupVersion.String(): "gkenetworkparamsets",
+}
+
+// networkCRDsInstalled returns true if the API server serves the Network and GKENetworkParamSet resources.
+func networkCRDsInstalled(client discovery.DiscoveryInterface) (bool, error) {
+	for groupVersion, resource := range networkCRDResources {
+		resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// syncNetworkCRDs re-checks whether the multi-network CRDs are installed and
+// disables or re-enables multi-network CIDR allocation accordingly. When
+// discovery fails the previous state is kept.
+func (ca *cloudCIDRAllocator) syncNetworkCRDs() {
+	installed, err := networkCRDsInstalled(ca.client.Discovery())
+	if err != nil {
+		klog.ErrorS(err, "Failed to discover the multi-network CRDs, keeping the previous state", "crdsInstalled", !ca.networkCRDsMissing.Load())
+		return
+	}
+	if installed {
+		multiNetworkCRDsInstalled.Set(1)
+	} else {
+		multiNetworkCRDsInstalled.Set(0)
+	}
+	if ca.networkCRDsMissing.Swap(!installed) == !installed {
+		return
+	}
+	if installed {
+		klog.Infof("Network and GKENetworkParamSet CRDs found, multi-network CIDR allocation is enabled")
+	} else {
+		klog.Warningf("Network and GKENetworkParamSet CRDs are not installed, multi-network CIDR allocation is disabled until they appear")
+	}
+}
+
+// multiNetworkEnabled returns true if the allocator should run the multi-network CIDR allocation path.
+func (ca *cloudCIDRAllocator) multiNetworkEnabled() bool {
+	return ca.params.EnableMultiNetworking && !ca.networkCRDsMissing.Load()
+}
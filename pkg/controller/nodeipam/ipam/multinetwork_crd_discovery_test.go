@@ -0,0 +1,94 @@
+package ipam
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSyncNetworkCRDs(t *testing.T) {
+	networkResources := &metav1.APIResourceList{
+		GroupVersion: "networking.gke.io/v1",
+		APIResources: []metav1.APIResource{{Name: "networks"}, {Name: "networkinterfaces"}},
+	}
+	gnpResources := &metav1.APIResourceList{
+		GroupVersion: "networking.gke.io/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "gkenetworkparamsets"}},
+	}
+	testCases := []struct {
+		desc         string
+		resources    []*metav1.APIResourceList
+		multiNetwork bool
+		wantEnabled  bool
+	}{
+		{
+			desc:         "CRDs installed",
+			resources:    []*metav1.APIResourceList{networkResources, gnpResources},
+			multiNetwork: true,
+			wantEnabled:  true,
+		},
+		{
+			desc:         "no CRDs installed",
+			multiNetwork: true,
+			wantEnabled:  false,
+		},
+		{
+			desc:         "GKENetworkParamSet CRD missing",
+			resources:    []*metav1.APIResourceList{networkResources},
+			multiNetwork: true,
+			wantEnabled:  false,
+		},
+		{
+			desc: "group version served without the Network resource",
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "networking.gke.io/v1", APIResources: []metav1.APIResource{{Name: "networkinterfaces"}}},
+				gnpResources,
+			},
+			multiNetwork: true,
+			wantEnabled:  false,
+		},
+		{
+			desc:         "multi-networking disabled by configuration",
+			resources:    []*metav1.APIResourceList{networkResources, gnpResources},
+			multiNetwork: false,
+			wantEnabled:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.Discovery().(*fakediscovery.FakeDiscovery).Resources = tc.resources
+			ca := &cloudCIDRAllocator{
+				client: client,
+				params: CloudAllocatorParams{EnableMultiNetworking: tc.multiNetwork},
+			}
+			ca.syncNetworkCRDs()
+			if got := ca.multiNetworkEnabled(); got != tc.wantEnabled {
+				t.Errorf("multiNetworkEnabled() = %v, want %v", got, tc.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestSyncNetworkCRDsInstalledLater(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	ca := &cloudCIDRAllocator{
+		client: client,
+		params: CloudAllocatorParams{EnableMultiNetworking: true},
+	}
+	ca.syncNetworkCRDs()
+	if ca.multiNetworkEnabled() {
+		t.Fatalf("multiNetworkEnabled() = true before the CRDs are installed")
+	}
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.gke.io/v1", APIResources: []metav1.APIResource{{Name: "networks"}}},
+		{GroupVersion: "networking.gke.io/v1alpha1", APIResources: []metav1.APIResource{{Name: "gkenetworkparamsets"}}},
+	}
+	ca.syncNetworkCRDs()
+	if !ca.multiNetworkEnabled() {
+		t.Errorf("multiNetworkEnabled() = false after the CRDs are installed")
+	}
+}
@@ -0,0 +1,37 @@
+package ipam
+
+import (
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// isDefaultNetwork returns true if the Network is the default pod network: it
+// is annotated with wellknown.DefaultNetworkAnnotationKey, or named after the
+// configured default network name, or after the built-in default network names
+// when none is configured.
+func (ca *cloudCIDRAllocator) isDefaultNetwork(network *networkv1.Network) bool {
+	if network.Annotations[wellknown.DefaultNetworkAnnotationKey] == "true" {
+		return true
+	}
+	return ca.isDefaultNetworkName(network.Name)
+}
+
+// isDefaultNetworkName returns true if the name designates the default pod
+// network, ignoring wellknown.DefaultNetworkAnnotationKey.
+func (ca *cloudCIDRAllocator) isDefaultNetworkName(name string) bool {
+	if ca.params.DefaultNetworkName != "" {
+		return name == ca.params.DefaultNetworkName
+	}
+	return networkv1.IsDefaultNetwork(name)
+}
+
+// isDefaultNetworkRef is isDefaultNetwork for a Network referenced by name,
+// looking up its annotations if it exists.
+func (ca *cloudCIDRAllocator) isDefaultNetworkRef(name string) bool {
+	if ca.networksLister != nil {
+		if network, err := ca.networksLister.Get(name); err == nil {
+			return ca.isDefaultNetwork(network)
+		}
+	}
+	return ca.isDefaultNetworkName(name)
+}
@@ -0,0 +1,65 @@
+package ipam
+
+import (
+	"testing"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func withDefaultNetworkAnnotation(network *networkv1.Network) *networkv1.Network {
+	if network.Annotations == nil {
+		network.Annotations = map[string]string{}
+	}
+	network.Annotations[wellknown.DefaultNetworkAnnotationKey] = "true"
+	return network
+}
+
+func TestIsDefaultNetwork(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		defaultNetworkName string
+		network            *networkv1.Network
+		want               bool
+	}{
+		{
+			desc:    "built-in default pod network name",
+			network: network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+			want:    true,
+		},
+		{
+			desc:    "built-in default network name",
+			network: network(networkv1.DefaultNetworkName, defaultGKENetworkParamsName),
+			want:    true,
+		},
+		{
+			desc:    "additional network",
+			network: network(redNetworkName, redGKENetworkParamsName),
+		},
+		{
+			desc:               "configured default network name",
+			defaultNetworkName: "primary",
+			network:            network("primary", defaultGKENetworkParamsName),
+			want:               true,
+		},
+		{
+			desc:               "built-in name with a configured default network name",
+			defaultNetworkName: "primary",
+			network:            network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+		},
+		{
+			desc:               "annotated network with a configured default network name",
+			defaultNetworkName: "primary",
+			network:            withDefaultNetworkAnnotation(network("secondary", defaultGKENetworkParamsName)),
+			want:               true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ca := &cloudCIDRAllocator{params: CloudAllocatorParams{DefaultNetworkName: tc.defaultNetworkName}}
+			if got := ca.isDefaultNetwork(tc.network); got != tc.want {
+				t.Errorf("isDefaultNetwork(%s) = %v, want %v", tc.network.Name, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// networkEventFlushInterval is the interval at which the warnings about
+	// the nodes of a network are recorded on the Network.
+	networkEventFlushInterval = time.Minute
+	// networkEventSampleNodes is the number of nodes named in an aggregated
+	// event.
+	networkEventSampleNodes = 3
+)
+
+// networkEventKey identifies the warnings aggregated in one event.
+type networkEventKey struct {
+	network string
+	reason  string
+}
+
+// networkEventAggregate holds the warnings of a reason about the nodes of a
+// network since the last flush.
+type networkEventAggregate struct {
+	// message is the message of the last warning.
+	message string
+	// nodes is the set of nodes the warnings are about.
+	nodes map[string]bool
+}
+
+// recordNetworkEvent records a warning about a node on the given network.
+// Misconfigured networks fail the same way on all their nodes, so rather than
+// one event per node, which floods etcd during incidents, the warnings are
+// aggregated per network and reason and recorded as one event on the Network
+// every networkEventFlushInterval, see flushNetworkEvents. The message should
+// not depend on the node.
+func (ca *cloudCIDRAllocator) recordNetworkEvent(networkName, nodeName, reason, message string) {
+	ca.networkEventsLock.Lock()
+	defer ca.networkEventsLock.Unlock()
+	if ca.networkEvents == nil {
+		ca.networkEvents = make(map[networkEventKey]*networkEventAggregate)
+	}
+	key := networkEventKey{network: networkName, reason: reason}
+	aggregate, ok := ca.networkEvents[key]
+	if !ok {
+		aggregate = &networkEventAggregate{nodes: make(map[string]bool)}
+		ca.networkEvents[key] = aggregate
+	}
+	aggregate.message = message
+	aggregate.nodes[nodeName] = true
+}
+
+// flushNetworkEvents records one warning event per network and reason
+// aggregated since the last flush, with the number of nodes and a sample of
+// their names.
+func (ca *cloudCIDRAllocator) flushNetworkEvents() {
+	ca.networkEventsLock.Lock()
+	pending := ca.networkEvents
+	ca.networkEvents = nil
+	ca.networkEventsLock.Unlock()
+
+	for key, aggregate := range pending {
+		network := &networkv1.Network{}
+		network.Name = key.network
+		if ca.networksLister != nil {
+			nw, err := ca.networksLister.Get(key.network)
+			if apierrors.IsNotFound(err) {
+				klog.V(4).Infof("Not recording the %s events of deleted network %s", key.reason, key.network)
+				continue
+			}
+			if err == nil {
+				network = nw
+			}
+		}
+		ca.recorder.Event(networkReference(network), v1.EventTypeWarning, key.reason, aggregatedMessage(aggregate))
+	}
+}
+
+// aggregatedMessage returns the message of the event of the aggregated
+// warnings, e.g. "<message> (120 nodes, e.g. node-a, node-b, node-c)".
+func aggregatedMessage(aggregate *networkEventAggregate) string {
+	nodes := make([]string, 0, len(aggregate.nodes))
+	for node := range aggregate.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	if len(nodes) == 1 {
+		return fmt.Sprintf("%s (node %s)", aggregate.message, nodes[0])
+	}
+	sample := nodes
+	if len(sample) > networkEventSampleNodes {
+		sample = sample[:networkEventSampleNodes]
+	}
+	return fmt.Sprintf("%s (%d nodes, e.g. %s)", aggregate.message, len(nodes), strings.Join(sample, ", "))
+}
@@ -0,0 +1,124 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func TestFlushNetworkEvents(t *testing.T) {
+	type warning struct {
+		network, node, reason, message string
+	}
+	testCases := []struct {
+		desc     string
+		warnings []warning
+		want     []string
+	}{
+		{
+			desc: "no warnings",
+		},
+		{
+			desc:     "single node",
+			warnings: []warning{{redNetworkName, "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"}},
+			want:     []string{v1.EventTypeWarning + " " + wellknown.PodCIDRMaskSizeMismatchReason + " mismatch (node node0)"},
+		},
+		{
+			desc: "nodes of a network are aggregated",
+			warnings: []warning{
+				{redNetworkName, "node3", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{redNetworkName, "node1", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{redNetworkName, "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{redNetworkName, "node2", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{redNetworkName, "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+			},
+			want: []string{v1.EventTypeWarning + " " + wellknown.PodCIDRMaskSizeMismatchReason + " mismatch (4 nodes, e.g. node0, node1, node2)"},
+		},
+		{
+			desc: "networks and reasons are recorded separately",
+			warnings: []warning{
+				{redNetworkName, "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{blueNetworkName, "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"},
+				{redNetworkName, "node1", wellknown.DuplicateSliceRangeReason, "duplicate"},
+				{redNetworkName, "node2", wellknown.DuplicateSliceRangeReason, "last duplicate"},
+			},
+			want: []string{
+				v1.EventTypeWarning + " " + wellknown.DuplicateSliceRangeReason + " last duplicate (2 nodes, e.g. node1, node2)",
+				v1.EventTypeWarning + " " + wellknown.PodCIDRMaskSizeMismatchReason + " mismatch (node node0)",
+				v1.EventTypeWarning + " " + wellknown.PodCIDRMaskSizeMismatchReason + " mismatch (node node0)",
+			},
+		},
+		{
+			desc:     "deleted network",
+			warnings: []warning{{"deleted", "node0", wellknown.PodCIDRMaskSizeMismatchReason, "mismatch"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nwInformer := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Hour).Networking().V1().Networks()
+			for _, nw := range []string{redNetworkName, blueNetworkName} {
+				if err := nwInformer.Informer().GetStore().Add(network(nw, "")); err != nil {
+					t.Fatalf("error in test setup, could not create network %s: %v", nw, err)
+				}
+			}
+			recorder := record.NewFakeRecorder(10)
+			ca := &cloudCIDRAllocator{networksLister: nwInformer.Lister(), recorder: recorder}
+			for _, w := range tc.warnings {
+				ca.recordNetworkEvent(w.network, w.node, w.reason, w.message)
+			}
+
+			ca.flushNetworkEvents()
+			close(recorder.Events)
+			var got []string
+			for event := range recorder.Events {
+				got = append(got, event)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("flushNetworkEvents() recorded unexpected events (-want +got):\n%s", diff)
+			}
+			if len(ca.networkEvents) != 0 {
+				t.Errorf("got %d pending warnings after the flush, want none", len(ca.networkEvents))
+			}
+		})
+	}
+}
+
+func TestRecordAllocationErrorOfNetwork(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Hour).Core().V1().Nodes()
+	for i := 0; i < 100; i++ {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node%02d", i)}}
+		if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+			t.Fatalf("error in test setup, could not add node: %v", err)
+		}
+	}
+	recorder := record.NewFakeRecorder(200)
+	ca := &cloudCIDRAllocator{nodeLister: nodeInformer.Lister(), recorder: recorder}
+
+	for i := 0; i < 100; i++ {
+		ca.recordAllocationError(fmt.Sprintf("node%02d", i), networkAllocationErrorf(ErrParamsInvalid, redNetworkName, "no params"))
+	}
+	if got := len(recorder.Events); got != 0 {
+		t.Fatalf("got %d events before the flush, want none", got)
+	}
+	ca.flushNetworkEvents()
+	if got := len(recorder.Events); got != 1 {
+		t.Fatalf("got %d events, want 1 for the network", got)
+	}
+	want := v1.EventTypeWarning + " InvalidNetworkParams CIDR allocation failed, last error: no params (100 nodes, e.g. node00, node01, node02)"
+	if event := <-recorder.Events; event != want {
+		t.Errorf("got event %q, want %q", event, want)
+	}
+}
@@ -0,0 +1,127 @@
+package ipam
+
+import (
+	"context"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/externalipam"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// The per-node ranges of the Networks annotated with
+// wellknown.ExternalIPAMAnnotationKey are allocated by the external IPAM
+// provider listening on its gRPC endpoint, implementing the externalipam
+// service, instead of being read from the alias IP ranges of the node
+// interfaces.
+
+const (
+	// externalIPAMTimeout bounds the calls to the external IPAM providers.
+	externalIPAMTimeout = 10 * time.Second
+)
+
+// externalIPAMEndpoint returns the endpoint of the external IPAM provider of
+// the network, or "" if its ranges are read from the alias IP ranges.
+func (ca *cloudCIDRAllocator) externalIPAMEndpoint(network *networkv1.Network) string {
+	if ca.isDefaultNetwork(network) {
+		return ""
+	}
+	return network.Annotations[wellknown.ExternalIPAMAnnotationKey]
+}
+
+// externalAllocator returns the client of the provider at the endpoint,
+// connecting to it on first use.
+func (ca *cloudCIDRAllocator) externalAllocator(endpoint string) (externalipam.ExternalAllocatorClient, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if client, ok := ca.externalAllocators[endpoint]; ok {
+		return client, nil
+	}
+	conn, err := externalipam.Dial(endpoint)
+	if err != nil {
+		return nil, allocationErrorf(ErrExternalIPAM, "failed to connect to external IPAM provider %s: %w", endpoint, err)
+	}
+	if ca.externalAllocators == nil {
+		ca.externalAllocators = make(map[string]externalipam.ExternalAllocatorClient)
+	}
+	client := externalipam.NewExternalAllocatorClient(conn)
+	ca.externalAllocators[endpoint] = client
+	return client, nil
+}
+
+// allocateExternalRanges requests the ranges of the node on the network from
+// its external IPAM provider.
+func (ca *cloudCIDRAllocator) allocateExternalRanges(node *v1.Node, network *networkv1.Network, inf *compute.NetworkInterface) ([]string, error) {
+	endpoint := ca.externalIPAMEndpoint(network)
+	client, err := ca.externalAllocator(endpoint)
+	if err != nil {
+		return nil, networkAllocationErrorf(ErrExternalIPAM, network.Name, "network %s: %w", network.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), externalIPAMTimeout)
+	defer cancel()
+	resp, err := client.AllocateNodeRanges(ctx, &externalipam.AllocateNodeRangesRequest{
+		Network:     network.Name,
+		Node:        node.Name,
+		Interface:   inf.Name,
+		InterfaceIP: inf.NetworkIP,
+		Subnetwork:  inf.Subnetwork,
+	})
+	if err != nil {
+		return nil, networkAllocationErrorf(ErrExternalIPAM, network.Name, "external IPAM provider %s failed to allocate the ranges of node %s on network %s: %w", endpoint, node.Name, network.Name, err)
+	}
+	if len(resp.CIDRs) == 0 {
+		return nil, networkAllocationErrorf(ErrExternalIPAM, network.Name, "external IPAM provider %s allocated no range to node %s on network %s", endpoint, node.Name, network.Name)
+	}
+	for _, cidr := range resp.CIDRs {
+		if _, _, err := netutils.ParseCIDRSloppy(cidr); err != nil {
+			return nil, networkAllocationErrorf(ErrExternalIPAM, network.Name, "external IPAM provider %s allocated invalid range %q to node %s on network %s", endpoint, cidr, node.Name, network.Name)
+		}
+	}
+	klog.V(2).Infof("External IPAM provider %s allocated ranges %v to node %s on network %s", endpoint, resp.CIDRs, node.Name, network.Name)
+	return resp.CIDRs, nil
+}
+
+// releaseExternalRanges releases the ranges allocated to the deleted node by
+// external IPAM providers. Errors are logged, the providers are expected to
+// reclaim the ranges of nodes that no longer exist.
+func (ca *cloudCIDRAllocator) releaseExternalRanges(node *v1.Node) {
+	if ca.networksLister == nil {
+		return
+	}
+	nodeNetworks, ok, err := networkannotations.ParseNodeMultiNetwork(node.Annotations)
+	if err != nil {
+		klog.Warningf("Failed to parse the annotations of node %s: %v", node.Name, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	for _, nodeNetwork := range nodeNetworks {
+		network, err := ca.networksLister.Get(nodeNetwork.Name)
+		if err != nil {
+			continue
+		}
+		endpoint := ca.externalIPAMEndpoint(network)
+		if endpoint == "" {
+			continue
+		}
+		client, err := ca.externalAllocator(endpoint)
+		if err != nil {
+			klog.Warningf("Failed to release the ranges of node %s on network %s: %v", node.Name, network.Name, err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), externalIPAMTimeout)
+		_, err = client.ReleaseNodeRanges(ctx, &externalipam.ReleaseNodeRangesRequest{Network: network.Name, Node: node.Name})
+		cancel()
+		if err != nil {
+			klog.Warningf("External IPAM provider %s failed to release the ranges of node %s on network %s: %v", endpoint, node.Name, network.Name, err)
+			continue
+		}
+		klog.V(2).Infof("External IPAM provider %s released the ranges of node %s on network %s", endpoint, node.Name, network.Name)
+	}
+}
@@ -0,0 +1,136 @@
+package ipam
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/externalipam"
+)
+
+type fakeExternalAllocator struct {
+	cidrs    map[string][]string
+	requests []externalipam.AllocateNodeRangesRequest
+	released []externalipam.ReleaseNodeRangesRequest
+}
+
+func (a *fakeExternalAllocator) AllocateNodeRanges(_ context.Context, req *externalipam.AllocateNodeRangesRequest) (*externalipam.AllocateNodeRangesResponse, error) {
+	a.requests = append(a.requests, *req)
+	return &externalipam.AllocateNodeRangesResponse{CIDRs: a.cidrs[req.Node]}, nil
+}
+
+func (a *fakeExternalAllocator) ReleaseNodeRanges(_ context.Context, req *externalipam.ReleaseNodeRangesRequest) (*externalipam.ReleaseNodeRangesResponse, error) {
+	a.released = append(a.released, *req)
+	return &externalipam.ReleaseNodeRangesResponse{}, nil
+}
+
+// startExternalAllocator serves the allocator on a unix socket and returns its endpoint.
+func startExternalAllocator(t *testing.T, allocator externalipam.ExternalAllocatorServer) string {
+	socket := filepath.Join(t.TempDir(), "ipam.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	server := grpc.NewServer()
+	externalipam.RegisterExternalAllocatorServer(server, allocator)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return "unix:" + socket
+}
+
+func TestExternalIPAMAllocation(t *testing.T) {
+	allocator := &fakeExternalAllocator{
+		cidrs: map[string][]string{
+			"node0": {"192.168.0.0/26"},
+			"node1": {"not-a-cidr"},
+		},
+	}
+	endpoint := startExternalAllocator(t, allocator)
+
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	red := network(redNetworkName, redGKENetworkParamsName)
+	red.Annotations = map[string]string{wellknown.ExternalIPAMAnnotationKey: endpoint}
+	for _, nw := range []*networkv1.Network{red, network(blueNetworkName, blueGKENetworkParamsName)} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+		gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, []string{blueSecondaryRangeA}),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+	}
+	redInf := interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA}})
+	redInf.Name = "nic1"
+	infs := []*compute.NetworkInterface{
+		redInf,
+		interfaces(blueVPCName, blueVPCSubnetName, "10.2.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.12.1.0/24", SubnetworkRangeName: blueSecondaryRangeA}}),
+	}
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	_, northInterfaces, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	wantNorthInterfaces := networkv1.NorthInterfacesAnnotation{
+		{Network: redNetworkName, IpAddress: "10.1.1.1"},
+		{Network: blueNetworkName, IpAddress: "10.2.1.1"},
+	}
+	if diff := cmp.Diff(wantNorthInterfaces, northInterfaces); diff != "" {
+		t.Errorf("north interfaces mismatch (-want +got):\n%s", diff)
+	}
+	wantNetworks := networkv1.MultiNetworkAnnotation{
+		{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"192.168.0.0/26"}},
+		{Name: blueNetworkName, Scope: "host-local", Cidrs: []string{"172.12.1.0/24"}},
+	}
+	if diff := cmp.Diff(wantNetworks, additionalNodeNetworks); diff != "" {
+		t.Errorf("additional node networks mismatch (-want +got):\n%s", diff)
+	}
+	wantRequests := []externalipam.AllocateNodeRangesRequest{{Network: redNetworkName, Node: "node0", Interface: "nic1", InterfaceIP: "10.1.1.1", Subnetwork: redVPCSubnetName}}
+	if diff := cmp.Diff(wantRequests, allocator.requests); diff != "" {
+		t.Errorf("external IPAM requests mismatch (-want +got):\n%s", diff)
+	}
+
+	shadow, err := ca.indexedMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("indexedMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	if diff := cmp.Diff(wantNetworks, shadow.AdditionalNodeNetworks); diff != "" {
+		t.Errorf("indexed additional node networks mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, _, _, _, err := ca.PerformMultiNetworkCIDRAllocation(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}, infs); err == nil {
+		t.Errorf("PerformMultiNetworkCIDRAllocation() returned no error for an invalid external range")
+	}
+	if _, _, _, _, err := ca.PerformMultiNetworkCIDRAllocation(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}}, infs); err == nil {
+		t.Errorf("PerformMultiNetworkCIDRAllocation() returned no error without external ranges")
+	}
+
+	node.Annotations = map[string]string{networkv1.MultiNetworkAnnotationKey: `[{"name":"` + redNetworkName + `","cidrs":["192.168.0.0/26"],"scope":"host-local"},{"name":"` + blueNetworkName + `","cidrs":["172.12.1.0/24"],"scope":"host-local"}]`}
+	ca.releaseExternalRanges(node)
+	wantReleased := []externalipam.ReleaseNodeRangesRequest{{Network: redNetworkName, Node: "node0"}}
+	if diff := cmp.Diff(wantReleased, allocator.released); diff != "" {
+		t.Errorf("external IPAM releases mismatch (-want +got):\n%s", diff)
+	}
+}
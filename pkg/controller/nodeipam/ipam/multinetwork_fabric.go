@@ -0,0 +1,24 @@
+package ipam
+
+import (
+	"strings"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// The addresses of accelerator fabric Networks (RDMA, InfiniBand over
+// fabric...), annotated with wellknown.IPAllocationAnnotationKey, are managed
+// by the fabric, so the nodes attached to them only get a north interface: no
+// pod CIDRs, delegated ranges, external IPAM allocation or IP capacity,
+// whatever the ranges of their GKENetworkParamSet.
+
+// interfaceOnlyNetwork returns true if the Network is an accelerator fabric
+// network whose IPs are not allocated. The IPs of the default network always
+// are.
+func (ca *cloudCIDRAllocator) interfaceOnlyNetwork(network *networkv1.Network) bool {
+	if ca.isDefaultNetwork(network) {
+		return false
+	}
+	return strings.EqualFold(network.Annotations[wellknown.IPAllocationAnnotationKey], wellknown.IPAllocationNone)
+}
@@ -0,0 +1,24 @@
+package ipam
+
+import (
+	"testing"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func withIPAllocation(network *networkv1.Network, ipAllocation string) *networkv1.Network {
+	if network.Annotations == nil {
+		network.Annotations = map[string]string{}
+	}
+	network.Annotations[wellknown.IPAllocationAnnotationKey] = ipAllocation
+	return network
+}
+
+func TestNetworkChangedIPAllocation(t *testing.T) {
+	oldNetwork := network(redNetworkName, redGKENetworkParamsName)
+	newNetwork := withIPAllocation(network(redNetworkName, redGKENetworkParamsName), "None")
+	if !networkChanged(oldNetwork, newNetwork) {
+		t.Errorf("networkChanged() = false after disabling the IP allocation, want true")
+	}
+}
@@ -0,0 +1,72 @@
+package ipam
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/test"
+)
+
+// TestMultiNetworkFixtures replays the cluster snapshots written by
+// cmd/ipamfixture and checks that the allocator reproduces the allocation
+// that was published on the nodes.
+func TestMultiNetworkFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*.yaml"))
+	if err != nil {
+		t.Fatalf("failed to list fixtures: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+	for _, path := range paths {
+		fixture, err := test.LoadFixture(path)
+		if err != nil {
+			t.Fatalf("failed to load fixture: %v", err)
+		}
+		nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+		nwInformer := nwInfFactory.V1().Networks()
+		gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+		for i := range fixture.Networks {
+			if err := nwInformer.Informer().GetStore().Add(&fixture.Networks[i]); err != nil {
+				t.Fatalf("error in test setup, could not create network %s: %v", fixture.Networks[i].Name, err)
+			}
+		}
+		for i := range fixture.GKENetworkParamSets {
+			if err := gnpInformer.Informer().GetStore().Add(&fixture.GKENetworkParamSets[i]); err != nil {
+				t.Fatalf("error in test setup, could not create gke network param set %s: %v", fixture.GKENetworkParamSets[i].Name, err)
+			}
+		}
+		ca := &cloudCIDRAllocator{
+			networksLister: nwInformer.Lister(),
+			gnpLister:      gnpInformer.Lister(),
+		}
+		for _, nodeFixture := range fixture.Nodes {
+			t.Run(filepath.Base(path)+"/"+nodeFixture.Name, func(t *testing.T) {
+				node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixture.Name}}
+				defaultNwCIDRs, northInterfaces, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, nodeFixture.Interfaces)
+				if err != nil {
+					t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+				}
+				got := multiNetworkAllocation{
+					DefaultNwCIDRs:         defaultNwCIDRs,
+					NorthInterfaces:        northInterfaces,
+					AdditionalNodeNetworks: additionalNodeNetworks,
+				}
+				want := multiNetworkAllocation{
+					DefaultNwCIDRs:         nodeFixture.PodCIDRs,
+					NorthInterfaces:        nodeFixture.NorthInterfaces,
+					AdditionalNodeNetworks: nodeFixture.AdditionalNodeNetworks,
+				}
+				if diff := cmp.Diff(want.normalized(), got.normalized()); diff != "" {
+					t.Errorf("allocation mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// The custom resource names the IP capacity of a node is published under, see
+// the ipResourceName field of the Network spec, are recorded in
+// wellknown.IPResourceNamesAnnotationKey, so that the capacity is removed once
+// the node leaves the network or the resource name of the network changes.
+
+// validateIPResourceName returns an error if the name is not a valid extended
+// resource name.
+func validateIPResourceName(name string) error {
+	if !strings.Contains(name, "/") {
+		return fmt.Errorf("extended resource names must be prefixed by a domain")
+	}
+	if strings.Contains(name, "kubernetes.io/") || strings.HasPrefix(name, v1.DefaultResourceRequestsPrefix) {
+		return fmt.Errorf("%q is reserved to native resources", name)
+	}
+	if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ipResourceName returns the extended resource name the IP capacity of the
+// named Network is published under. Networks with an invalid resource name
+// use the default one.
+func (ca *cloudCIDRAllocator) ipResourceName(networkName string) v1.ResourceName {
+	network, err := ca.networksLister.Get(networkName)
+	if err != nil {
+		return networkIPResourceName(networkName)
+	}
+	name := network.Spec.IPResourceName
+	if name == "" {
+		return networkIPResourceName(networkName)
+	}
+	if err := validateIPResourceName(name); err != nil {
+		klog.Warningf("Ignoring network %s with invalid IP resource name %q: %v", networkName, name, err)
+		return networkIPResourceName(networkName)
+	}
+	return v1.ResourceName(name)
+}
+
+// publishedIPResources returns the names of the IP capacity resources of the
+// node, whether they have the default or a custom name.
+func publishedIPResources(node *v1.Node) sets.String {
+	names := sets.NewString()
+	for name := range node.Status.Capacity {
+		if strings.HasPrefix(name.String(), networkv1.NetworkResourceKeyPrefix) {
+			names.Insert(name.String())
+		}
+	}
+	if value := node.Annotations[wellknown.IPResourceNamesAnnotationKey]; value != "" {
+		for _, name := range strings.Split(value, ",") {
+			if _, ok := node.Status.Capacity[v1.ResourceName(name)]; ok {
+				names.Insert(name)
+			}
+		}
+	}
+	return names
+}
+
+// customIPResourceNames returns the value of
+// wellknown.IPResourceNamesAnnotationKey for the IP capacity, empty if all its
+// resources have the default name.
+func customIPResourceNames(ipCapacity v1.ResourceList) string {
+	var names []string
+	for name := range ipCapacity {
+		if !strings.HasPrefix(name.String(), networkv1.NetworkResourceKeyPrefix) {
+			names = append(names, name.String())
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
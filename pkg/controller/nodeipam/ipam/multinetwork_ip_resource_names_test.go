@@ -0,0 +1,134 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+)
+
+func TestValidateIPResourceName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "example.com/red-ip"},
+		{name: "networking.gke.io.networks/red.IP"},
+		{name: "red-ip", wantErr: true},
+		{name: "kubernetes.io/red-ip", wantErr: true},
+		{name: "requests.example.com/red-ip", wantErr: true},
+		{name: "example.com/red ip", wantErr: true},
+	}
+	for _, tc := range testCases {
+		if err := validateIPResourceName(tc.name); (err != nil) != tc.wantErr {
+			t.Errorf("validateIPResourceName(%q) returned err %v, want error %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestUpdateMultiNetworkAnnotationsIPResourceName(t *testing.T) {
+	const customName = "example.com/red-ip"
+	northInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.1.1.1"}}
+	nodeNetworks := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}}
+	northInterfacesAnn, err := networkv1.MarshalAnnotation(northInterfaces)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	nodeNetworksAnn, err := networkv1.MarshalAnnotation(nodeNetworks)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	defaultCapacity := v1.ResourceList{networkIPResourceName(redNetworkName): resource.MustParse("128")}
+	customCapacity := v1.ResourceList{customName: resource.MustParse("128")}
+	testCases := []struct {
+		desc            string
+		resourceName    string
+		capacity        v1.ResourceList
+		annotations     map[string]string
+		wantPatch       bool
+		wantCapacity    v1.ResourceList
+		wantAnnotations string
+	}{
+		{
+			desc:            "default capacity replaced by the custom resource",
+			resourceName:    customName,
+			capacity:        defaultCapacity,
+			wantPatch:       true,
+			wantCapacity:    customCapacity,
+			wantAnnotations: customName,
+		},
+		{
+			desc:            "custom resource up to date",
+			resourceName:    customName,
+			capacity:        customCapacity,
+			annotations:     map[string]string{wellknown.IPResourceNamesAnnotationKey: customName},
+			wantCapacity:    customCapacity,
+			wantAnnotations: customName,
+		},
+		{
+			desc:         "custom resource replaced by the default capacity",
+			capacity:     customCapacity,
+			annotations:  map[string]string{wellknown.IPResourceNamesAnnotationKey: customName},
+			wantPatch:    true,
+			wantCapacity: defaultCapacity,
+		},
+		{
+			desc:         "invalid resource name",
+			resourceName: "red-ip",
+			capacity:     defaultCapacity,
+			wantCapacity: defaultCapacity,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nw := network(redNetworkName, redGKENetworkParamsName)
+			nw.Spec.IPResourceName = tc.resourceName
+			nwInfFactory := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second)
+			if err := nwInfFactory.Networking().V1().Networks().Informer().GetStore().Add(nw); err != nil {
+				t.Fatalf("error in test setup, could not add network: %v", err)
+			}
+			// The multi-network annotations are up to date, so that only the
+			// capacity may change.
+			annotations := map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: northInterfacesAnn,
+				networkv1.MultiNetworkAnnotationKey:    nodeNetworksAnn,
+			}
+			for k, v := range tc.annotations {
+				annotations[k] = v
+			}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: annotations}}
+			node.Status.Capacity = tc.capacity
+			fakeNodeHandler := &testutil.FakeNodeHandler{Existing: []*v1.Node{node}, Clientset: fake.NewSimpleClientset()}
+			ca := &cloudCIDRAllocator{
+				client:         fakeNodeHandler,
+				networksLister: nwInfFactory.Networking().V1().Networks().Lister(),
+			}
+			if err := ca.updateMultiNetworkAnnotations(node, nil, northInterfaces, nodeNetworks); err != nil {
+				t.Fatalf("updateMultiNetworkAnnotations() returned err %v", err)
+			}
+			updated := fakeNodeHandler.GetUpdatedNodesCopy()
+			if gotPatch := len(updated) > 0; gotPatch != tc.wantPatch {
+				t.Fatalf("updateMultiNetworkAnnotations() patched the node: %v, want %v", gotPatch, tc.wantPatch)
+			}
+			if !tc.wantPatch {
+				return
+			}
+			got := updated[0]
+			if diff := cmp.Diff(tc.wantCapacity, got.Status.Capacity); diff != "" {
+				t.Errorf("capacity mismatch (-want +got):\n%s", diff)
+			}
+			if value := got.Annotations[wellknown.IPResourceNamesAnnotationKey]; value != tc.wantAnnotations {
+				t.Errorf("got %s annotation %q, want %q", wellknown.IPResourceNamesAnnotationKey, value, tc.wantAnnotations)
+			}
+		})
+	}
+}
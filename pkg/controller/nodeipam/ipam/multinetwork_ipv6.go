@@ -0,0 +1,47 @@
+package ipam
+
+import (
+	compute "google.golang.org/api/compute/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// The north interfaces of the dual-stack Networks, see
+// wellknown.StackTypeAnnotationKey, carry the IPv6 address of the node
+// interface, so that dual-stack pods can select IPv6 endpoints on the network.
+
+// interfaceIPv6Address returns the internal IPv6 address of the interface, or
+// its external one, or "" if the interface has no IPv6 configuration.
+func interfaceIPv6Address(inf *compute.NetworkInterface) string {
+	if inf.Ipv6Address != "" {
+		return inf.Ipv6Address
+	}
+	for _, config := range inf.Ipv6AccessConfigs {
+		if config != nil && config.ExternalIpv6 != "" {
+			return config.ExternalIpv6
+		}
+	}
+	return ""
+}
+
+// withIPv6Addresses returns the north interfaces with the IPv6 address of
+// the interfaces of the networks supporting IPv6, if any.
+func (ca *cloudCIDRAllocator) withIPv6Addresses(northInterfaces networkv1.NorthInterfacesAnnotation, interfaces []*compute.NetworkInterface) networkv1.NorthInterfacesAnnotation {
+	if northInterfaces == nil {
+		return nil
+	}
+	ret := make(networkv1.NorthInterfacesAnnotation, 0, len(northInterfaces))
+	for _, ni := range northInterfaces {
+		network, err := ca.networksLister.Get(ni.Network)
+		if err == nil && network.Annotations[wellknown.StackTypeAnnotationKey] == wellknown.StackTypeDualStack {
+			for _, inf := range interfaces {
+				if inf.NetworkIP == ni.IpAddress {
+					ni.IpV6Address = interfaceIPv6Address(inf)
+					break
+				}
+			}
+		}
+		ret = append(ret, ni)
+	}
+	return ret
+}
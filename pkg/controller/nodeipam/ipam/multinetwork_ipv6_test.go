@@ -0,0 +1,75 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func TestWithIPv6Addresses(t *testing.T) {
+	ipv6Interface := func(ip, ipv6, externalIPv6 string) *compute.NetworkInterface {
+		inf := interfaces(redVPCName, redVPCSubnetName, ip, nil)
+		inf.Ipv6Address = ipv6
+		if externalIPv6 != "" {
+			inf.Ipv6AccessConfigs = []*compute.AccessConfig{{ExternalIpv6: externalIPv6}}
+		}
+		return inf
+	}
+	northInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.0.0.2"}}
+	testCases := []struct {
+		desc       string
+		stackType  string
+		interfaces []*compute.NetworkInterface
+		wantAnn    string
+	}{
+		{
+			desc:       "dual-stack network with IPv6 interface",
+			stackType:  wellknown.StackTypeDualStack,
+			interfaces: []*compute.NetworkInterface{ipv6Interface("10.0.0.2", "2600:1900::2", "")},
+			wantAnn:    `[{"network":"Red-Network","ipAddress":"10.0.0.2","ipV6Address":"2600:1900::2"}]`,
+		},
+		{
+			desc:       "dual-stack network with external IPv6 interface",
+			stackType:  wellknown.StackTypeDualStack,
+			interfaces: []*compute.NetworkInterface{ipv6Interface("10.0.0.2", "", "2600:1901::2")},
+			wantAnn:    `[{"network":"Red-Network","ipAddress":"10.0.0.2","ipV6Address":"2600:1901::2"}]`,
+		},
+		{
+			desc:       "dual-stack network with IPv4 interface",
+			stackType:  wellknown.StackTypeDualStack,
+			interfaces: []*compute.NetworkInterface{ipv6Interface("10.0.0.2", "", "")},
+			wantAnn:    `[{"network":"Red-Network","ipAddress":"10.0.0.2"}]`,
+		},
+		{
+			desc:       "IPv4 network with IPv6 interface",
+			interfaces: []*compute.NetworkInterface{ipv6Interface("10.0.0.2", "2600:1900::2", "")},
+			wantAnn:    `[{"network":"Red-Network","ipAddress":"10.0.0.2"}]`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nwInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+			red := network(redNetworkName, redGKENetworkParamsName)
+			if tc.stackType != "" {
+				red.Annotations = map[string]string{wellknown.StackTypeAnnotationKey: tc.stackType}
+			}
+			if err := nwInformer.Informer().GetStore().Add(red); err != nil {
+				t.Fatalf("error in test setup, could not create network %s: %v", red.Name, err)
+			}
+			ca := &cloudCIDRAllocator{networksLister: nwInformer.Lister()}
+
+			ann, err := networkv1.MarshalAnnotation(ca.withIPv6Addresses(northInterfaces, tc.interfaces))
+			if err != nil {
+				t.Fatalf("MarshalAnnotation() returned err %v", err)
+			}
+			if ann != tc.wantAnn {
+				t.Errorf("north interfaces annotation = %s, want %s", ann, tc.wantAnn)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package ipam
+
+import (
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+// legacyAnnotationKeys maps the multi-network annotation keys to their legacy
+// keys.
+var legacyAnnotationKeys = map[string]string{
+	networkv1.NorthInterfacesAnnotationKey: wellknown.LegacyNorthInterfacesAnnotationKey,
+	networkv1.MultiNetworkAnnotationKey:    wellknown.LegacyMultiNetworkAnnotationKey,
+}
+
+// legacyAnnotationsUpToDate returns true if the node carries the legacy
+// annotations, with the values of the multi-network annotations in any
+// encoding, when they are enabled, and none of them otherwise.
+func (ca *cloudCIDRAllocator) legacyAnnotationsUpToDate(node *v1.Node) bool {
+	for key, legacyKey := range legacyAnnotationKeys {
+		legacy, ok := node.Annotations[legacyKey]
+		if !ca.params.LegacyAnnotations {
+			if ok {
+				return false
+			}
+			continue
+		}
+		value, found, err := networkannotations.NodeAnnotation(node.Annotations, key)
+		if err != nil || !found || !ok || legacy != value {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeLegacyAnnotations adds the serialized multi-network annotations to the
+// update under their legacy keys if they are enabled, and removes them from
+// the node otherwise. The legacy annotations always have the per-key
+// encoding.
+func (ca *cloudCIDRAllocator) encodeLegacyAnnotations(node *v1.Node, update *nodeUpdate, northInterfaceAnn, additionalNodeNwAnn string) {
+	if !ca.params.LegacyAnnotations {
+		update.RemovedAnnotations = append(update.RemovedAnnotations, presentAnnotations(node, wellknown.LegacyNorthInterfacesAnnotationKey, wellknown.LegacyMultiNetworkAnnotationKey)...)
+		return
+	}
+	update.Annotations[wellknown.LegacyNorthInterfacesAnnotationKey] = northInterfaceAnn
+	update.Annotations[wellknown.LegacyMultiNetworkAnnotationKey] = additionalNodeNwAnn
+}
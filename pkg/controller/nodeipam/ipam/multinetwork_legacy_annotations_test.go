@@ -0,0 +1,130 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+func TestUpdateMultiNetworkAnnotationsLegacy(t *testing.T) {
+	northInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.1.1.1"}}
+	nodeNetworks := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}}
+	northInterfacesAnn, err := networkv1.MarshalAnnotation(northInterfaces)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	nodeNetworksAnn, err := networkv1.MarshalAnnotation(nodeNetworks)
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	compactAnn, err := networkannotations.EncodeCompact(northInterfacesAnn, nodeNetworksAnn, false)
+	if err != nil {
+		t.Fatalf("EncodeCompact() returned err %v", err)
+	}
+	perKey := map[string]string{
+		networkv1.NorthInterfacesAnnotationKey: northInterfacesAnn,
+		networkv1.MultiNetworkAnnotationKey:    nodeNetworksAnn,
+	}
+	perKeyAndLegacy := map[string]string{
+		networkv1.NorthInterfacesAnnotationKey:       northInterfacesAnn,
+		networkv1.MultiNetworkAnnotationKey:          nodeNetworksAnn,
+		wellknown.LegacyNorthInterfacesAnnotationKey: northInterfacesAnn,
+		wellknown.LegacyMultiNetworkAnnotationKey:    nodeNetworksAnn,
+	}
+	compactAndLegacy := map[string]string{
+		wellknown.CompactAnnotationKey:               compactAnn,
+		wellknown.LegacyNorthInterfacesAnnotationKey: northInterfacesAnn,
+		wellknown.LegacyMultiNetworkAnnotationKey:    nodeNetworksAnn,
+	}
+	testCases := []struct {
+		desc        string
+		legacy      bool
+		encoding    string
+		annotations map[string]string
+		want        map[string]string
+		wantPatch   bool
+	}{
+		{
+			desc:        "legacy annotations added",
+			legacy:      true,
+			annotations: perKey,
+			want:        perKeyAndLegacy,
+			wantPatch:   true,
+		},
+		{
+			desc:        "legacy annotations up to date",
+			legacy:      true,
+			annotations: perKeyAndLegacy,
+		},
+		{
+			desc:   "stale legacy annotations updated",
+			legacy: true,
+			annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey:       northInterfacesAnn,
+				networkv1.MultiNetworkAnnotationKey:          nodeNetworksAnn,
+				wellknown.LegacyNorthInterfacesAnnotationKey: "[]",
+				wellknown.LegacyMultiNetworkAnnotationKey:    nodeNetworksAnn,
+			},
+			want:      perKeyAndLegacy,
+			wantPatch: true,
+		},
+		{
+			desc:      "legacy annotations along with the compact annotation",
+			legacy:    true,
+			encoding:  AnnotationEncodingCompact,
+			want:      compactAndLegacy,
+			wantPatch: true,
+		},
+		{
+			desc:        "legacy annotations along with the compact annotation up to date",
+			legacy:      true,
+			encoding:    AnnotationEncodingCompact,
+			annotations: compactAndLegacy,
+		},
+		{
+			desc:        "legacy annotations removed once disabled",
+			annotations: perKeyAndLegacy,
+			want:        perKey,
+			wantPatch:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			// The capacity is up to date, so that only the annotations are patched.
+			node.Status.Capacity, err = networkIPCapacities(nodeNetworks, networkIPResourceName)
+			if err != nil {
+				t.Fatalf("networkIPCapacities() returned err %v", err)
+			}
+			fakeNodeHandler := &testutil.FakeNodeHandler{Existing: []*v1.Node{node}, Clientset: fake.NewSimpleClientset()}
+			ca := &cloudCIDRAllocator{
+				client:         fakeNodeHandler,
+				networksLister: networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking().V1().Networks().Lister(),
+				params:         CloudAllocatorParams{AnnotationEncoding: tc.encoding, LegacyAnnotations: tc.legacy},
+			}
+			if err := ca.updateMultiNetworkAnnotations(node, nil, northInterfaces, nodeNetworks); err != nil {
+				t.Fatalf("updateMultiNetworkAnnotations() returned err %v", err)
+			}
+			updated := fakeNodeHandler.GetUpdatedNodesCopy()
+			if gotPatch := len(updated) > 0; gotPatch != tc.wantPatch {
+				t.Fatalf("updateMultiNetworkAnnotations() patched the node: %v, want %v", gotPatch, tc.wantPatch)
+			}
+			if !tc.wantPatch {
+				return
+			}
+			if diff := cmp.Diff(tc.want, updated[0].Annotations); diff != "" {
+				t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ipam
+
+import (
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// limitAdditionalNetworks removes the additional networks beyond
+// MaxAdditionalNetworks from the allocation, in the order in which they were
+// allocated, and records an event on the node listing the ignored networks
+// when they change. Publishing them would produce annotations that the
+// dataplane cannot honor.
+func (ca *cloudCIDRAllocator) limitAdditionalNetworks(node *v1.Node, allocation multiNetworkAllocation) multiNetworkAllocation {
+	limit := ca.params.MaxAdditionalNetworks
+	if limit <= 0 {
+		return allocation
+	}
+	kept := make(map[string]bool)
+	seen := make(map[string]bool)
+	var ignored []string
+	for _, inf := range allocation.NorthInterfaces {
+		if seen[inf.Network] {
+			continue
+		}
+		seen[inf.Network] = true
+		if len(kept) < limit {
+			kept[inf.Network] = true
+		} else {
+			ignored = append(ignored, inf.Network)
+		}
+	}
+	changed := ca.recordLimitedNetworks(node.Name, ignored)
+	if len(ignored) == 0 {
+		return allocation
+	}
+	if changed {
+		klog.Warningf("Node %s is attached to more than %d additional networks, ignoring networks %v", node.Name, limit, ignored)
+		ca.recorder.Eventf(node, v1.EventTypeWarning, wellknown.TooManyAdditionalNetworksReason, "Node is attached to more than %d additional networks, ignoring networks %v", limit, ignored)
+	}
+
+	limited := multiNetworkAllocation{DefaultNwCIDRs: allocation.DefaultNwCIDRs}
+	for _, inf := range allocation.NorthInterfaces {
+		if kept[inf.Network] {
+			limited.NorthInterfaces = append(limited.NorthInterfaces, inf)
+		}
+	}
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		if kept[nw.Name] {
+			limited.AdditionalNodeNetworks = append(limited.AdditionalNodeNetworks, nw)
+		}
+	}
+	for _, r := range allocation.DelegatedRanges {
+		if kept[r.Network] {
+			limited.DelegatedRanges = append(limited.DelegatedRanges, r)
+		}
+	}
+	return limited
+}
+
+// recordLimitedNetworks records the additional networks ignored on the node
+// and returns true if they changed since its last allocation, so that the
+// event is not recorded again on every resync of the node.
+func (ca *cloudCIDRAllocator) recordLimitedNetworks(nodeName string, ignored []string) bool {
+	sorted := append([]string(nil), ignored...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",")
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.limitedNetworks[nodeName] == key {
+		return false
+	}
+	if key == "" {
+		delete(ca.limitedNetworks, nodeName)
+		return true
+	}
+	if ca.limitedNetworks == nil {
+		ca.limitedNetworks = make(map[string]string)
+	}
+	ca.limitedNetworks[nodeName] = key
+	return true
+}
+
+// forgetLimitedNetworks forgets the networks ignored on a deleted node.
+func (ca *cloudCIDRAllocator) forgetLimitedNetworks(nodeName string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	delete(ca.limitedNetworks, nodeName)
+}
@@ -0,0 +1,135 @@
+package ipam
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestLimitAdditionalNetworks(t *testing.T) {
+	allocation := multiNetworkAllocation{
+		DefaultNwCIDRs: []string{"10.0.0.0/24"},
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: "red", IpAddress: "10.1.0.2"},
+			{Network: "blue", IpAddress: "10.2.0.2"},
+			{Network: "red", IpAddress: "10.3.0.2"},
+			{Network: "green", IpAddress: "10.4.0.2"},
+		},
+		AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+			{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}},
+			{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+			{Name: "red", Scope: "host-local", Cidrs: []string{"172.18.0.0/24"}},
+		},
+		DelegatedRanges: DelegatedRangesAnnotation{
+			{Network: "green", Interface: "nic3", Subnetwork: "green-subnet", RangeNames: []string{"green-pods"}},
+		},
+	}
+	testCases := []struct {
+		desc      string
+		limit     int
+		want      multiNetworkAllocation
+		wantEvent string
+	}{
+		{
+			desc:  "no limit",
+			limit: 0,
+			want:  allocation,
+		},
+		{
+			desc:  "within the limit",
+			limit: 3,
+			want:  allocation,
+		},
+		{
+			desc:  "networks beyond the limit are ignored",
+			limit: 1,
+			want: multiNetworkAllocation{
+				DefaultNwCIDRs: []string{"10.0.0.0/24"},
+				NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+					{Network: "red", IpAddress: "10.1.0.2"},
+					{Network: "red", IpAddress: "10.3.0.2"},
+				},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}},
+					{Name: "red", Scope: "host-local", Cidrs: []string{"172.18.0.0/24"}},
+				},
+			},
+			wantEvent: v1.EventTypeWarning + " " + wellknown.TooManyAdditionalNetworksReason + " Node is attached to more than 1 additional networks, ignoring networks [blue green]",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ca := &cloudCIDRAllocator{
+				recorder: recorder,
+				params:   CloudAllocatorParams{MaxAdditionalNetworks: tc.limit},
+			}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+			got := ca.limitAdditionalNetworks(node, allocation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("limitAdditionalNetworks() returned unexpected allocation (-want +got):\n%s", diff)
+			}
+			var gotEvent string
+			select {
+			case gotEvent = <-recorder.Events:
+			default:
+			}
+			if gotEvent != tc.wantEvent {
+				t.Errorf("recorded event %q, want %q", gotEvent, tc.wantEvent)
+			}
+		})
+	}
+}
+
+func TestLimitAdditionalNetworksEventsOnChange(t *testing.T) {
+	allocation := func(networks ...string) multiNetworkAllocation {
+		var a multiNetworkAllocation
+		for _, nw := range networks {
+			a.NorthInterfaces = append(a.NorthInterfaces, networkv1.NorthInterface{Network: nw})
+		}
+		return a
+	}
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{
+		recorder: recorder,
+		params:   CloudAllocatorParams{MaxAdditionalNetworks: 1},
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	for _, step := range []struct {
+		networks  []string
+		wantEvent bool
+	}{
+		{networks: []string{"red", "blue"}, wantEvent: true},
+		// Resyncs of the node do not record the event again.
+		{networks: []string{"red", "blue"}},
+		{networks: []string{"red", "blue"}},
+		// A newly ignored network is reported.
+		{networks: []string{"red", "blue", "green"}, wantEvent: true},
+		{networks: []string{"red", "green", "blue"}},
+		// Once no network is ignored, ignoring one again is reported.
+		{networks: []string{"red"}},
+		{networks: []string{"red", "blue"}, wantEvent: true},
+	} {
+		ca.limitAdditionalNetworks(node, allocation(step.networks...))
+		var gotEvent bool
+		select {
+		case <-recorder.Events:
+			gotEvent = true
+		default:
+		}
+		if gotEvent != step.wantEvent {
+			t.Errorf("networks %v: recorded event = %v, want %v", step.networks, gotEvent, step.wantEvent)
+		}
+	}
+
+	ca.forgetLimitedNetworks(node.Name)
+	ca.limitAdditionalNetworks(node, allocation("red", "blue"))
+	if len(recorder.Events) != 1 {
+		t.Errorf("recorded %d events after the node was forgotten, want 1", len(recorder.Events))
+	}
+}
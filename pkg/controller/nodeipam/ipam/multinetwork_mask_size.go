@@ -0,0 +1,45 @@
+package ipam
+
+import (
+	"net"
+	"strconv"
+
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// The value of wellknown.PerNodeMaskSizeAnnotationKey on a GKENetworkParamSet,
+// e.g. "26", is the prefix length of the alias IP ranges attached to every node
+// for its Network. Alias IP ranges of any size are accepted if it is not set.
+// Networks whose nodes have alias IP ranges of another size get a
+// wellknown.PodCIDRMaskSizeMismatchReason event.
+
+// perNodeMaskSize returns the per-node mask size of the GKENetworkParamSet, or
+// 0 if it is not set. Invalid values are ignored.
+func perNodeMaskSize(gnp *networkv1alpha1.GKENetworkParamSet) int {
+	value, ok := gnp.Annotations[wellknown.PerNodeMaskSizeAnnotationKey]
+	if !ok {
+		return 0
+	}
+	maskSize, err := strconv.Atoi(value)
+	if err != nil || maskSize < 1 || maskSize > 32 {
+		klog.Warningf("Ignoring invalid %s annotation %q of GKENetworkParamSet %s, it must be an IPv4 prefix length", wellknown.PerNodeMaskSizeAnnotationKey, value, gnp.Name)
+		return 0
+	}
+	return maskSize
+}
+
+// aliasMatchesMaskSize returns true if the alias IP range has the given mask
+// size, or if no mask size is set.
+func aliasMatchesMaskSize(ipCidrRange string, maskSize int) bool {
+	if maskSize == 0 {
+		return true
+	}
+	_, ipNet, err := net.ParseCIDR(ipCidrRange)
+	if err != nil {
+		return false
+	}
+	ones, bits := ipNet.Mask.Size()
+	return bits == 32 && ones == maskSize
+}
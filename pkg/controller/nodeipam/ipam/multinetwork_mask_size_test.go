@@ -0,0 +1,145 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func withPerNodeMaskSize(gnp *networkv1alpha1.GKENetworkParamSet, maskSize string) *networkv1alpha1.GKENetworkParamSet {
+	if gnp.Annotations == nil {
+		gnp.Annotations = map[string]string{}
+	}
+	gnp.Annotations[wellknown.PerNodeMaskSizeAnnotationKey] = maskSize
+	return gnp
+}
+
+func TestAliasMatchesMaskSize(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		maskSize *string
+		cidr     string
+		want     bool
+	}{
+		{desc: "no mask size", cidr: "172.11.1.0/24", want: true},
+		{desc: "same mask size", maskSize: stringPtr("26"), cidr: "172.11.1.64/26", want: true},
+		{desc: "different mask size", maskSize: stringPtr("26"), cidr: "172.11.1.0/24"},
+		{desc: "invalid mask size is ignored", maskSize: stringPtr("/26"), cidr: "172.11.1.0/24", want: true},
+		{desc: "out of range mask size is ignored", maskSize: stringPtr("33"), cidr: "172.11.1.0/24", want: true},
+		{desc: "invalid cidr", maskSize: stringPtr("24"), cidr: "172.11.1.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gnp := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil)
+			if tc.maskSize != nil {
+				gnp = withPerNodeMaskSize(gnp, *tc.maskSize)
+			}
+			if got := aliasMatchesMaskSize(tc.cidr, perNodeMaskSize(gnp)); got != tc.want {
+				t.Errorf("aliasMatchesMaskSize() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPerformMultiNetworkCIDRAllocationMaskSize(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{
+		network(redNetworkName, redGKENetworkParamsName),
+		network(blueNetworkName, blueGKENetworkParamsName),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		withPerNodeMaskSize(gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}), "26"),
+		withPerNodeMaskSize(gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, []string{blueSecondaryRangeA}), "26"),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		recorder:       recorder,
+	}
+	infs := []*compute.NetworkInterface{
+		interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.11.1.64/26", SubnetworkRangeName: redSecondaryRangeA}}),
+		interfaces(blueVPCName, blueVPCSubnetName, "10.2.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.12.1.0/24", SubnetworkRangeName: blueSecondaryRangeA}}),
+	}
+
+	_, _, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	want := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.64/26"}}}
+	if diff := cmp.Diff(want, additionalNodeNetworks); diff != "" {
+		t.Errorf("additional node networks mismatch (-want +got):\n%s", diff)
+	}
+	ca.flushNetworkEvents()
+	if got := len(recorder.Events); got != 1 {
+		t.Fatalf("got %d events, want 1 for the mismatched alias IP range", got)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeWarning+" "+wellknown.PodCIDRMaskSizeMismatchReason+" Alias IP ranges of secondary range "+blueSecondaryRangeA+" do not have the /26 mask size of the network (node "+node.Name+")" {
+		t.Errorf("got event %q", event)
+	}
+
+	capacity, err := networkIPCapacity(want[0])
+	if err != nil {
+		t.Fatalf("networkIPCapacity() returned err %v", err)
+	}
+	if capacity != 32 {
+		t.Errorf("networkIPCapacity() = %d for a /26, want 32", capacity)
+	}
+
+	shadow, err := ca.indexedMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("indexedMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	if diff := cmp.Diff(want, shadow.AdditionalNodeNetworks); diff != "" {
+		t.Errorf("indexed additional node networks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDelegatedRangeMaskSize(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	if err := nwInformer.Informer().GetStore().Add(network(redNetworkName, redGKENetworkParamsName)); err != nil {
+		t.Fatalf("error in test setup, could not create network: %v", err)
+	}
+	if err := gnpInformer.Informer().GetStore().Add(withPerNodeMaskSize(gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}), "28")); err != nil {
+		t.Fatalf("error in test setup, could not create gke network param set: %v", err)
+	}
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		params:         CloudAllocatorParams{NodeLocalIPAM: true},
+	}
+	inf := interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", nil)
+	inf.Name = "nic1"
+	_, _, _, delegatedRanges, err := ca.PerformMultiNetworkCIDRAllocation(node, []*compute.NetworkInterface{inf})
+	if err != nil {
+		t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	want := DelegatedRangesAnnotation{{Network: redNetworkName, Interface: "nic1", Subnetwork: redVPCSubnetName, RangeNames: []string{redSecondaryRangeA}, MaskSize: 28}}
+	if diff := cmp.Diff(want, delegatedRanges); diff != "" {
+		t.Errorf("delegated ranges mismatch (-want +got):\n%s", diff)
+	}
+}
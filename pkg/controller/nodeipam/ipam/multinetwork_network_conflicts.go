@@ -0,0 +1,218 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	alphanetworklister "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+	"k8s.io/klog/v2"
+)
+
+// A Network that refers to the same VPC, subnet and secondary range as an older
+// Network is ignored by the allocator until the conflict is resolved, and
+// carries the condition in wellknown.NetworkConflictAnnotationKey meanwhile.
+
+// networkConflict describes why a Network lost a conflict.
+type networkConflict struct {
+	// winner is the Network that keeps the range.
+	winner string
+	// vpc, subnet and rangeName identify the range both Networks refer to.
+	// rangeName is empty for Networks without pod ranges.
+	vpc, subnet, rangeName string
+}
+
+func (c networkConflict) message() string {
+	if c.rangeName == "" {
+		return fmt.Sprintf("subnet %s of VPC %s is already used by Network %s", c.subnet, c.vpc, c.winner)
+	}
+	return fmt.Sprintf("secondary range %s of subnet %s in VPC %s is already used by Network %s", c.rangeName, c.subnet, c.vpc, c.winner)
+}
+
+// resolveNetworkConflicts drops the Networks that refer to a VPC, subnet and
+// secondary range already claimed by another Network. Networks of the same
+// VPC on distinct subnets never conflict, while Networks on the same subnet
+// are resolved from the same interfaces and must be told apart by their
+// secondary ranges: a Network without pod ranges claims its whole subnet. The default Network
+// always wins, then the oldest Network by creation timestamp, ties being broken
+// by name, so that every reconcile picks the same winner. Networks whose
+// GKENetworkParamSet cannot be fetched are kept and do not claim any range.
+func resolveNetworkConflicts(networks []*networkv1.Network, isDefault func(*networkv1.Network) bool, gnpLister alphanetworklister.GKENetworkParamSetLister, defaults gcpurl.Defaults) ([]*networkv1.Network, map[string]networkConflict) {
+	ordered := append([]*networkv1.Network(nil), networks...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if da, db := isDefault(a), isDefault(b); da != db {
+			return da
+		}
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Name < b.Name
+	})
+	// claimed holds the Network of each claimed range, the ranges without
+	// name being whole subnets, and subnetUsers the first Network of each
+	// subnet.
+	claimed := map[networkConflict]string{}
+	subnetUsers := map[networkConflict]string{}
+	conflicts := map[string]networkConflict{}
+	for _, network := range ordered {
+		gnp, err := gnpLister.Get(network.Spec.ParametersRef.Name)
+		if err != nil {
+			continue
+		}
+		subnet := networkConflict{
+			vpc:    qualifiedRef(gnp.Spec.VPC, gcpurl.KindNetworks, defaults),
+			subnet: qualifiedRef(gnp.Spec.VPCSubnet, gcpurl.KindSubnetworks, defaults),
+		}
+		wholeSubnet := gnp.Spec.PodIPv4Ranges == nil || len(gnp.Spec.PodIPv4Ranges.RangeNames) == 0
+		var keys []networkConflict
+		if wholeSubnet {
+			keys = append(keys, subnet)
+		} else {
+			for _, rangeName := range gkenetworkparamset.PodIPv4RangeNames(gnp) {
+				key := subnet
+				key.rangeName = rangeName
+				keys = append(keys, key)
+			}
+		}
+		if winner, ok := subnetUsers[subnet]; ok && wholeSubnet {
+			subnet.winner = winner
+			conflicts[network.Name] = subnet
+			continue
+		}
+		if winner, ok := claimed[subnet]; ok {
+			subnet.winner = winner
+			conflicts[network.Name] = subnet
+			continue
+		}
+		lost := false
+		for _, k := range keys {
+			if winner, ok := claimed[k]; ok {
+				k.winner = winner
+				conflicts[network.Name] = k
+				lost = true
+				break
+			}
+		}
+		if lost {
+			continue
+		}
+		for _, k := range keys {
+			claimed[k] = network.Name
+		}
+		if _, ok := subnetUsers[subnet]; !ok {
+			subnetUsers[subnet] = network.Name
+		}
+	}
+	if len(conflicts) == 0 {
+		return networks, conflicts
+	}
+	winners := make([]*networkv1.Network, 0, len(networks)-len(conflicts))
+	for _, network := range networks {
+		if _, ok := conflicts[network.Name]; !ok {
+			winners = append(winners, network)
+		}
+	}
+	return winners, conflicts
+}
+
+// qualifiedRef returns the canonical form of a VPC or subnet reference, or
+// the reference itself if it cannot be parsed.
+func qualifiedRef(ref, kind string, defaults gcpurl.Defaults) string {
+	id, err := gcpurl.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return id.Qualified(kind, defaults).String()
+}
+
+// reportNetworkConflicts sets wellknown.NetworkConflictAnnotationKey on the
+// Networks that lost a conflict and clears it from the others, recording an
+// event on every change. It is a no-op if the allocator has no Network client.
+func (ca *cloudCIDRAllocator) reportNetworkConflicts(networks []*networkv1.Network, conflicts map[string]networkConflict) {
+	if ca.params.NetworkClient == nil {
+		return
+	}
+	for _, network := range networks {
+		existing, annotated := network.Annotations[wellknown.NetworkConflictAnnotationKey]
+		conflict, conflicting := conflicts[network.Name]
+		switch {
+		case conflicting && !conflictAnnotationUpToDate(existing, conflict):
+			condition := metav1.Condition{
+				Type:               wellknown.NetworkConflictConditionType,
+				Status:             metav1.ConditionTrue,
+				Reason:             wellknown.NetworkConflictReason,
+				Message:            conflict.message(),
+				LastTransitionTime: metav1.Now(),
+			}
+			value, err := json.Marshal(condition)
+			if err != nil {
+				klog.Errorf("Failed to marshal conflict condition of Network %s: %v", network.Name, err)
+				continue
+			}
+			klog.Warningf("Ignoring Network %s: %s", network.Name, condition.Message)
+			if err := ca.patchNetworkConflictAnnotation(network.Name, string(value)); err != nil {
+				klog.Errorf("Failed to set conflict annotation on Network %s: %v", network.Name, err)
+				continue
+			}
+			ca.recorder.Eventf(networkReference(network), v1.EventTypeWarning, wellknown.NetworkConflictReason, "Network is ignored: %s", condition.Message)
+		case annotated && !conflicting:
+			klog.Infof("Network %s no longer conflicts with another Network", network.Name)
+			if err := ca.patchNetworkConflictAnnotation(network.Name, nil); err != nil {
+				klog.Errorf("Failed to clear conflict annotation on Network %s: %v", network.Name, err)
+				continue
+			}
+			ca.recorder.Event(networkReference(network), v1.EventTypeNormal, wellknown.NetworkConflictResolvedReason, "Network no longer conflicts with another Network")
+		}
+	}
+}
+
+// conflictAnnotationUpToDate returns true if the annotation already describes the conflict.
+func conflictAnnotationUpToDate(annotation string, conflict networkConflict) bool {
+	if annotation == "" {
+		return false
+	}
+	var condition metav1.Condition
+	if err := json.Unmarshal([]byte(annotation), &condition); err != nil {
+		return false
+	}
+	return condition.Status == metav1.ConditionTrue && condition.Reason == wellknown.NetworkConflictReason && condition.Message == conflict.message()
+}
+
+// patchNetworkConflictAnnotation sets the conflict annotation of the Network to
+// value, or removes it if value is nil.
+func (ca *cloudCIDRAllocator) patchNetworkConflictAnnotation(name string, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{wellknown.NetworkConflictAnnotationKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ca.params.NetworkClient.NetworkingV1().Networks().Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// networkReference returns the reference used to record events on a Network,
+// which is not registered in the scheme of the event recorder.
+func networkReference(network *networkv1.Network) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		APIVersion: networkv1.SchemeGroupVersion.String(),
+		Kind:       "Network",
+		Name:       network.Name,
+		UID:        network.UID,
+	}
+}
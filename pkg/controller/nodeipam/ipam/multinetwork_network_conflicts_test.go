@@ -0,0 +1,303 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+)
+
+const (
+	redCopyNetworkName          = "Red-Copy-Network"
+	redCopyGKENetworkParamsName = "RedCopyGKENetworkParams"
+	redSecondVPCSubnetName      = "projects/testProject/regions/us-central1/subnetworks/red-second"
+)
+
+func createdNetwork(name, gkeNetworkParamsName string, created time.Time) *networkv1.Network {
+	nw := network(name, gkeNetworkParamsName)
+	nw.CreationTimestamp = metav1.NewTime(created)
+	return nw
+}
+
+func TestResolveNetworkConflicts(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	defaults := gcpurl.Defaults{Project: "testProject", Region: "us-central1"}
+	testCases := []struct {
+		desc          string
+		networks      []*networkv1.Network
+		gnps          []*networkv1alpha1.GKENetworkParamSet
+		wantNetworks  []string
+		wantConflicts map[string]networkConflict
+	}{
+		{
+			desc: "distinct ranges",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeB}),
+			},
+			wantNetworks:  []string{redCopyNetworkName, redNetworkName},
+			wantConflicts: map[string]networkConflict{},
+		},
+		{
+			desc: "oldest network wins",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t1),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t0),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+				// Partial references are qualified before being compared.
+				gkeNetworkParams(redCopyGKENetworkParamsName, "red", "red", []string{redSecondaryRangeB}),
+			},
+			wantNetworks: []string{redCopyNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redNetworkName: {winner: redCopyNetworkName, vpc: redVPCName, subnet: redVPCSubnetName, rangeName: redSecondaryRangeB},
+			},
+		},
+		{
+			desc: "name breaks creation timestamp ties",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t0),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+			},
+			wantNetworks: []string{redCopyNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redNetworkName: {winner: redCopyNetworkName, vpc: redVPCName, subnet: redVPCSubnetName, rangeName: redSecondaryRangeA},
+			},
+		},
+		{
+			desc: "host networks on the same subnet",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, nil),
+			},
+			wantNetworks: []string{redNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redCopyNetworkName: {winner: redNetworkName, vpc: redVPCName, subnet: redVPCSubnetName},
+			},
+		},
+		{
+			desc: "host network after a pod network on the same subnet",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, nil),
+			},
+			wantNetworks: []string{redNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redCopyNetworkName: {winner: redNetworkName, vpc: redVPCName, subnet: redVPCSubnetName},
+			},
+		},
+		{
+			desc: "pod network after a host network on the same subnet",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+			},
+			wantNetworks: []string{redNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redCopyNetworkName: {winner: redNetworkName, vpc: redVPCName, subnet: redVPCSubnetName},
+			},
+		},
+		{
+			desc: "distinct subnets of the same VPC",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil),
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redSecondVPCSubnetName, nil),
+			},
+			wantNetworks:  []string{redCopyNetworkName, redNetworkName},
+			wantConflicts: map[string]networkConflict{},
+		},
+		{
+			desc: "default network always wins",
+			networks: []*networkv1.Network{
+				createdNetwork(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName, t1),
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+				gkeNetworkParams(redGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+			},
+			wantNetworks: []string{networkv1.DefaultPodNetworkName},
+			wantConflicts: map[string]networkConflict{
+				redNetworkName: {winner: networkv1.DefaultPodNetworkName, vpc: defaultVPCName, subnet: defaultVPCSubnetName, rangeName: defaultSecondaryRangeA},
+			},
+		},
+		{
+			desc: "missing params",
+			networks: []*networkv1.Network{
+				createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+				createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t1),
+			},
+			gnps: []*networkv1alpha1.GKENetworkParamSet{
+				gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+			},
+			wantNetworks:  []string{redCopyNetworkName, redNetworkName},
+			wantConflicts: map[string]networkConflict{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gnpInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking().V1alpha1().GKENetworkParamSets()
+			for _, gnp := range tc.gnps {
+				if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+					t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+				}
+			}
+			networks, conflicts := resolveNetworkConflicts(tc.networks, (&cloudCIDRAllocator{}).isDefaultNetwork, gnpInformer.Lister(), defaults)
+			var gotNetworks []string
+			for _, nw := range networks {
+				gotNetworks = append(gotNetworks, nw.Name)
+			}
+			sort.Strings(gotNetworks)
+			if diff := cmp.Diff(tc.wantNetworks, gotNetworks); diff != "" {
+				t.Errorf("resolveNetworkConflicts() networks mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantConflicts, conflicts, cmp.AllowUnexported(networkConflict{})); diff != "" {
+				t.Errorf("resolveNetworkConflicts() conflicts mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReportNetworkConflicts(t *testing.T) {
+	red := network(redNetworkName, redGKENetworkParamsName)
+	redCopy := network(redCopyNetworkName, redCopyGKENetworkParamsName)
+	client := fake.NewSimpleClientset(red, redCopy)
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{
+		recorder: recorder,
+		params:   CloudAllocatorParams{NetworkClient: client},
+	}
+	conflicts := map[string]networkConflict{
+		redCopyNetworkName: {winner: redNetworkName, vpc: redVPCName, subnet: redVPCSubnetName, rangeName: redSecondaryRangeA},
+	}
+
+	ca.reportNetworkConflicts([]*networkv1.Network{red, redCopy}, conflicts)
+	got, err := client.NetworkingV1().Networks().Get(context.TODO(), redCopyNetworkName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", redCopyNetworkName, err)
+	}
+	var condition metav1.Condition
+	if err := json.Unmarshal([]byte(got.Annotations[wellknown.NetworkConflictAnnotationKey]), &condition); err != nil {
+		t.Fatalf("invalid conflict annotation %q: %v", got.Annotations[wellknown.NetworkConflictAnnotationKey], err)
+	}
+	if condition.Type != wellknown.NetworkConflictConditionType || condition.Status != metav1.ConditionTrue || condition.Reason != wellknown.NetworkConflictReason {
+		t.Errorf("conflict condition = %+v, want a true %s condition", condition, wellknown.NetworkConflictConditionType)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeWarning+" "+wellknown.NetworkConflictReason+" Network is ignored: "+conflicts[redCopyNetworkName].message() {
+		t.Errorf("recorded event %q", event)
+	}
+
+	// Reporting the same conflict again does not patch nor record anything.
+	client.ClearActions()
+	ca.reportNetworkConflicts([]*networkv1.Network{red, got}, conflicts)
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no API calls for an up to date conflict, got %v", actions)
+	}
+
+	// The annotation is cleared once the conflict is resolved.
+	ca.reportNetworkConflicts([]*networkv1.Network{got}, nil)
+	got, err = client.NetworkingV1().Networks().Get(context.TODO(), redCopyNetworkName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", redCopyNetworkName, err)
+	}
+	if _, ok := got.Annotations[wellknown.NetworkConflictAnnotationKey]; ok {
+		t.Errorf("conflict annotation was not removed: %v", got.Annotations)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeNormal+" "+wellknown.NetworkConflictResolvedReason+" Network no longer conflicts with another Network" {
+		t.Errorf("recorded event %q", event)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected events recorded: %d", len(recorder.Events))
+	}
+}
+
+func TestMultiNetworkAllocationIgnoresConflictingNetworks(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{
+		createdNetwork(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName, t0),
+		createdNetwork(redNetworkName, redGKENetworkParamsName, t0),
+		createdNetwork(redCopyNetworkName, redCopyGKENetworkParamsName, t0.Add(time.Minute)),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+		gkeNetworkParams(redCopyGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+	}
+	interfaces := []*compute.NetworkInterface{
+		interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+			{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+		}),
+		interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+			{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+		}),
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	for i := 0; i < 5; i++ {
+		_, northInterfaces, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, interfaces)
+		if err != nil {
+			t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+		}
+		wantNorthInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.1.1.1"}}
+		if diff := cmp.Diff(wantNorthInterfaces, northInterfaces); diff != "" {
+			t.Errorf("north interfaces mismatch (-want +got):\n%s", diff)
+		}
+		wantNodeNetworks := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}}
+		if diff := cmp.Diff(wantNodeNetworks, additionalNodeNetworks); diff != "" {
+			t.Errorf("node networks mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ipam
+
+import (
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// networkPredicate passes the Network events that require the attached nodes
+// to be reconciled again: the creations of the Networks created after the
+// allocator, as the ones listed at startup are reconciled with the nodes, the
+// changes of networkChanged, and the deletions.
+func (ca *cloudCIDRAllocator) networkPredicate() predicate.Predicate {
+	p := updatePredicate(networkChanged)
+	p.CreateFunc = func(e event.CreateEvent) bool {
+		return !e.Object.GetCreationTimestamp().Time.Before(ca.started)
+	}
+	p.GenericFunc = func(event.GenericEvent) bool { return false }
+	return p
+}
+
+// networkChanged returns true if the spec, the deletion state, the cluster
+// selector, the IP allocation, the traffic class or the paused state of the
+// Network changed.
+func networkChanged(oldNetwork, newNetwork *networkv1.Network) bool {
+	return !reflect.DeepEqual(oldNetwork.Spec, newNetwork.Spec) || oldNetwork.DeletionTimestamp.IsZero() != newNetwork.DeletionTimestamp.IsZero() ||
+		oldNetwork.Annotations[wellknown.ClusterSelectorAnnotationKey] != newNetwork.Annotations[wellknown.ClusterSelectorAnnotationKey] ||
+		oldNetwork.Annotations[wellknown.IPAllocationAnnotationKey] != newNetwork.Annotations[wellknown.IPAllocationAnnotationKey] ||
+		oldNetwork.Annotations[wellknown.TrafficClassAnnotationKey] != newNetwork.Annotations[wellknown.TrafficClassAnnotationKey] ||
+		oldNetwork.Annotations[wellknown.NetworkPausedAnnotationKey] != newNetwork.Annotations[wellknown.NetworkPausedAnnotationKey]
+}
+
+// gnpPredicate passes the GKENetworkParamSet events that require the Networks
+// referencing them to be reconciled: the creations, so that the nodes parked on
+// a terminal error about these Networks are requeued, and the changes of
+// gnpChanged.
+func gnpPredicate() predicate.Predicate {
+	p := updatePredicate(gnpChanged)
+	p.DeleteFunc = func(event.DeleteEvent) bool { return false }
+	p.GenericFunc = func(event.GenericEvent) bool { return false }
+	return p
+}
+
+// gnpChanged returns true if the spec, the subnet readiness or the Ready
+// condition of the GKENetworkParamSet changed.
+func gnpChanged(oldGNP, newGNP *networkv1alpha1.GKENetworkParamSet) bool {
+	return !reflect.DeepEqual(oldGNP.Spec, newGNP.Spec) ||
+		gkenetworkparamset.SubnetReady(oldGNP) != gkenetworkparamset.SubnetReady(newGNP) ||
+		gkenetworkparamset.Ready(oldGNP) != gkenetworkparamset.Ready(newGNP)
+}
+
+// requeueNetworkNodes puts the nodes attached to a non-default network, or
+// selected by its node selector, into the work queue. The default network carries no IP capacity, so its nodes
+// are left to the regular node updates.
+func (ca *cloudCIDRAllocator) requeueNetworkNodes(networkName string) {
+	if ca.isDefaultNetworkRef(networkName) {
+		return
+	}
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list nodes attached to the network", "network", networkName)
+		return
+	}
+	selector := ca.networkNodeSelector(networkName)
+	var matched []*v1.Node
+	for _, node := range nodes {
+		if nodeAttachedToNetwork(node, networkName) || selector.Matches(labels.Set(node.Labels)) {
+			matched = append(matched, node)
+		}
+	}
+	klog.V(2).InfoS("Requeuing nodes after a network change", "network", networkName, "nodes", len(matched))
+	ca.rolloutNetworkNodes(networkName, matched)
+}
+
+// requeueNewNetworkNodes puts all the nodes into the work queue after a
+// non-default network is created, as any of them may have an interface in it.
+func (ca *cloudCIDRAllocator) requeueNewNetworkNodes(networkName string) {
+	if ca.isDefaultNetworkRef(networkName) {
+		return
+	}
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the nodes for the new network", "network", networkName)
+		return
+	}
+	klog.V(2).InfoS("Requeuing nodes after a network creation", "network", networkName, "nodes", len(nodes))
+	ca.rolloutNetworkNodes(networkName, nodes)
+}
+
+// nodeAttachedToNetwork returns true if the node advertises IP capacity or a
+// north interface for the network.
+func nodeAttachedToNetwork(node *v1.Node, networkName string) bool {
+	if _, ok := node.Status.Capacity[networkIPResourceName(networkName)]; ok {
+		return true
+	}
+	northInterfaces, ok, err := networkannotations.ParseNodeNorthInterfaces(node.Annotations)
+	if err != nil || !ok {
+		return false
+	}
+	for _, inf := range northInterfaces {
+		if inf.Network == networkName {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMultiNetworkAnnotations returns true if the node carries annotations or
+// IP capacity of additional networks.
+func hasMultiNetworkAnnotations(node *v1.Node) bool {
+	if networkannotations.HasNodeAnnotations(node.Annotations) {
+		return true
+	}
+	return publishedIPResources(node).Len() > 0
+}
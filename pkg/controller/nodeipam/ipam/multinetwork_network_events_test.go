@@ -0,0 +1,185 @@
+package ipam
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestNetworkChangeRequeuesAttachedNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "red-capacity"},
+			Status: v1.NodeStatus{
+				Capacity: v1.ResourceList{networkIPResourceName(redNetworkName): resource.MustParse("128")},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "red-host-network",
+				Annotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: `[{"network":"Red-Network","ipAddress":"10.1.1.1"}]`},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "blue"},
+			Status: v1.NodeStatus{
+				Capacity: v1.ResourceList{networkIPResourceName(blueNetworkName): resource.MustParse("128")},
+			},
+		},
+	}
+	redNetwork := network(redNetworkName, redGKENetworkParamsName)
+	redNetworkUpdated := redNetwork.DeepCopy()
+	redNetworkUpdated.Spec.ParametersRef.Name = blueGKENetworkParamsName
+	redGNP := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB})
+	redGNPScaledDown := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA})
+	defaultNetwork := network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName)
+	defaultNetworkUpdated := defaultNetwork.DeepCopy()
+	defaultNetworkUpdated.Spec.ParametersRef.Name = redGKENetworkParamsName
+	started := time.Now()
+	newNetwork := network(blueNetworkName, blueGKENetworkParamsName)
+	newNetwork.CreationTimestamp = metav1.NewTime(started.Add(time.Second))
+	listedNetwork := network(blueNetworkName, blueGKENetworkParamsName)
+	listedNetwork.CreationTimestamp = metav1.NewTime(started.Add(-time.Hour))
+
+	testCases := []struct {
+		desc string
+		// cached are the objects in the cache of the reconciler, the red
+		// Network if nil.
+		cached    []client.Object
+		event     func(t *testing.T, r *multiNetworkReconciler)
+		wantNodes []string
+	}{
+		{
+			desc: "network spec change",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkUpdated(t, r, redNetwork, redNetworkUpdated)
+			},
+			wantNodes: []string{"red-capacity", "red-host-network"},
+		},
+		{
+			desc: "network resync without changes",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkUpdated(t, r, redNetwork, redNetwork.DeepCopy())
+			},
+		},
+		{
+			desc:   "network deleted",
+			cached: []client.Object{},
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkDeleted(t, r, redNetwork)
+			},
+			wantNodes: []string{"red-capacity", "red-host-network"},
+		},
+		{
+			desc:   "network created",
+			cached: []client.Object{redNetwork, newNetwork},
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkCreated(t, r, newNetwork)
+			},
+			wantNodes: []string{"blue", "red-capacity", "red-host-network"},
+		},
+		{
+			desc:   "network listed at startup",
+			cached: []client.Object{redNetwork, listedNetwork},
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkCreated(t, r, listedNetwork)
+			},
+		},
+		{
+			desc:   "default network change",
+			cached: []client.Object{redNetwork, defaultNetworkUpdated},
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				networkUpdated(t, r, defaultNetwork, defaultNetworkUpdated)
+			},
+		},
+		{
+			desc: "GKENetworkParamSet ranges scaled down",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				gnpUpdated(t, r, redGNP, redGNPScaledDown)
+			},
+			wantNodes: []string{"red-capacity", "red-host-network"},
+		},
+		{
+			desc: "GKENetworkParamSet subnet no longer ready",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				gnpUpdated(t, r, redGNP, withSubnetNotReady(redGNP.DeepCopy()))
+			},
+			wantNodes: []string{"red-capacity", "red-host-network"},
+		},
+		{
+			desc: "GKENetworkParamSet status change",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				updated := redGNP.DeepCopy()
+				updated.Status.PodCIDRs = &networkv1alpha1.NetworkRanges{CIDRBlocks: []string{"172.11.0.0/16"}}
+				gnpUpdated(t, r, redGNP, updated)
+			},
+		},
+		{
+			desc: "GKENetworkParamSet created",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				gnpCreated(t, r, redGNP)
+			},
+			wantNodes: []string{"red-capacity", "red-host-network"},
+		},
+		{
+			desc: "GKENetworkParamSet deleted",
+			event: func(t *testing.T, r *multiNetworkReconciler) {
+				if gnpPredicate().Delete(event.DeleteEvent{Object: redGNP}) {
+					t.Errorf("gnpPredicate() passed the deletion of the GKENetworkParamSet")
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nodeInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			for _, node := range nodes {
+				if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+					t.Fatalf("error in test setup, could not add node %s: %v", node.Name, err)
+				}
+			}
+			nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+			nwInformer := nwInfFactory.V1().Networks()
+			if err := nwInformer.Informer().GetStore().Add(redNetwork); err != nil {
+				t.Fatalf("error in test setup, could not add network: %v", err)
+			}
+			ca := withQueue(&cloudCIDRAllocator{
+				nodeLister:     nodeInformer.Lister(),
+				networksLister: nwInformer.Lister(),
+				started:        started,
+			})
+			cached := tc.cached
+			if cached == nil {
+				cached = []client.Object{redNetwork}
+			}
+			tc.event(t, newTestMultiNetworkReconciler(ca, cached...))
+			var gotNodes []string
+			for len(ca.queue.Updates()) > 0 {
+				gotNodes = append(gotNodes, <-ca.queue.Updates())
+			}
+			sort.Strings(gotNodes)
+			assert.Equal(t, tc.wantNodes, gotNodes)
+		})
+	}
+}
+
+func TestGNPChangedReady(t *testing.T) {
+	oldGNP := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA})
+	newGNP := withParamsReady(gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}))
+	if !gnpChanged(oldGNP, newGNP) {
+		t.Errorf("gnpChanged() = false after the GKENetworkParamSet became Ready, want true")
+	}
+}
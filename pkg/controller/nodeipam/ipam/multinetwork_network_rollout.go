@@ -0,0 +1,104 @@
+package ipam
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// networkRollout holds the nodes of a network not yet requeued.
+type networkRollout struct {
+	// pending are the names of the nodes to requeue, in order.
+	pending []string
+	// queued is the set of the pending nodes.
+	queued map[string]bool
+}
+
+// rolloutNetworkNodes requeues the nodes after a change of the network. In
+// large clusters requeuing all the nodes at once, e.g. when a Network is
+// created, causes a storm of node and lease updates, so the nodes are added
+// to a rollout of the network instead, from which NetworkRolloutNodesPerMinute
+// nodes are requeued, see advanceNetworkRollouts. Nodes already pending in
+// the rollout of the network keep their place.
+func (ca *cloudCIDRAllocator) rolloutNetworkNodes(networkName string, nodes []*v1.Node) {
+	if ca.params.NetworkRolloutNodesPerMinute <= 0 {
+		for _, node := range nodes {
+			ca.AllocateOrOccupyCIDR(node)
+		}
+		return
+	}
+	ca.rolloutLock.Lock()
+	defer ca.rolloutLock.Unlock()
+	if ca.rollouts == nil {
+		ca.rollouts = make(map[string]*networkRollout)
+	}
+	rollout, ok := ca.rollouts[networkName]
+	if !ok {
+		rollout = &networkRollout{queued: make(map[string]bool)}
+		ca.rollouts[networkName] = rollout
+		ca.rolloutOrder = append(ca.rolloutOrder, networkName)
+	}
+	for _, node := range nodes {
+		if rollout.queued[node.Name] {
+			continue
+		}
+		rollout.queued[node.Name] = true
+		rollout.pending = append(rollout.pending, node.Name)
+	}
+	if len(rollout.pending) == 0 {
+		ca.endNetworkRollout(networkName)
+		return
+	}
+	networkRolloutPendingNodes.WithLabelValues(networkName).Set(float64(len(rollout.pending)))
+}
+
+// advanceNetworkRollouts requeues the next node of the network rollouts,
+// taking turns between the networks so that a rollout of a large network does
+// not delay the others.
+func (ca *cloudCIDRAllocator) advanceNetworkRollouts() {
+	ca.rolloutLock.Lock()
+	if len(ca.rolloutOrder) == 0 {
+		ca.rolloutLock.Unlock()
+		return
+	}
+	networkName := ca.rolloutOrder[0]
+	rollout := ca.rollouts[networkName]
+	nodeName := rollout.pending[0]
+	rollout.pending = rollout.pending[1:]
+	delete(rollout.queued, nodeName)
+	if len(rollout.pending) == 0 {
+		ca.endNetworkRollout(networkName)
+		klog.V(2).InfoS("Requeued all the nodes of the network rollout", "network", networkName)
+	} else {
+		ca.rolloutOrder = append(ca.rolloutOrder[1:], networkName)
+		networkRolloutPendingNodes.WithLabelValues(networkName).Set(float64(len(rollout.pending)))
+	}
+	ca.rolloutLock.Unlock()
+
+	node, err := ca.nodeLister.Get(nodeName)
+	if err != nil {
+		klog.V(4).Infof("Skipping node %s of the rollout of network %s: %v", nodeName, networkName, err)
+		return
+	}
+	ca.AllocateOrOccupyCIDR(node)
+}
+
+// endNetworkRollout removes the rollout of the network. It must be called
+// with rolloutLock held.
+func (ca *cloudCIDRAllocator) endNetworkRollout(networkName string) {
+	delete(ca.rollouts, networkName)
+	for i, name := range ca.rolloutOrder {
+		if name == networkName {
+			ca.rolloutOrder = append(ca.rolloutOrder[:i], ca.rolloutOrder[i+1:]...)
+			break
+		}
+	}
+	networkRolloutPendingNodes.DeleteLabelValues(networkName)
+}
+
+// networkRolloutInterval returns the interval between the nodes requeued by
+// the network rollouts.
+func (ca *cloudCIDRAllocator) networkRolloutInterval() time.Duration {
+	return time.Minute / time.Duration(ca.params.NetworkRolloutNodesPerMinute)
+}
@@ -0,0 +1,70 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestNetworkRollout(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Hour).Core().V1().Nodes()
+	nodes := make(map[string]*v1.Node)
+	for _, name := range []string{"node0", "node1", "node2", "node3"} {
+		nodes[name] = &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if err := nodeInformer.Informer().GetStore().Add(nodes[name]); err != nil {
+			t.Fatalf("error in test setup, could not add node %s: %v", name, err)
+		}
+	}
+	// A node deleted before its turn is skipped.
+	deleted := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}}
+	params := DefaultCloudAllocatorParams()
+	params.NetworkRolloutNodesPerMinute = 120
+	ca := withQueue(&cloudCIDRAllocator{
+		nodeLister: nodeInformer.Lister(),
+		params:     params,
+	})
+	if got, want := ca.networkRolloutInterval(), 500*time.Millisecond; got != want {
+		t.Errorf("networkRolloutInterval() = %v, want %v", got, want)
+	}
+
+	ca.rolloutNetworkNodes("red", []*v1.Node{nodes["node0"], deleted, nodes["node1"]})
+	ca.rolloutNetworkNodes("blue", []*v1.Node{nodes["node3"]})
+	// Pending nodes keep their place when the network changes again.
+	ca.rolloutNetworkNodes("red", []*v1.Node{nodes["node1"], nodes["node2"]})
+	if len(ca.queue.Updates()) != 0 {
+		t.Fatalf("got %d nodes queued before the rollout advanced, want none", len(ca.queue.Updates()))
+	}
+	if got, _ := testutil.GetGaugeMetricValue(networkRolloutPendingNodes.WithLabelValues("red")); got != 4 {
+		t.Errorf("got %v pending nodes for network red, want 4", got)
+	}
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		ca.advanceNetworkRollouts()
+		for len(ca.queue.Updates()) > 0 {
+			got = append(got, <-ca.queue.Updates())
+		}
+	}
+	want := []string{"node0", "node3", "node1", "node2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("requeued nodes mismatch (-want +got):\n%s", diff)
+	}
+	if len(ca.rollouts) != 0 || len(ca.rolloutOrder) != 0 {
+		t.Errorf("got rollouts %v in order %v after all nodes were requeued, want none", ca.rollouts, ca.rolloutOrder)
+	}
+}
+
+func TestNetworkRolloutDisabled(t *testing.T) {
+	ca := withQueue(&cloudCIDRAllocator{})
+	ca.rolloutNetworkNodes("red", []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}, {ObjectMeta: metav1.ObjectMeta{Name: "node1"}}})
+	if got := len(ca.queue.Updates()); got != 2 {
+		t.Errorf("got %d nodes queued, want 2 with the rollout disabled", got)
+	}
+}
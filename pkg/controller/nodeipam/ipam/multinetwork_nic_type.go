@@ -0,0 +1,40 @@
+package ipam
+
+import (
+	"strings"
+
+	compute "google.golang.org/api/compute/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// wellknown.NicTypeAnnotationKey can be set on a GKENetworkParamSet to restrict
+// its Networks to the node interfaces of the given vNIC type, e.g. "GVNIC" or
+// "VIRTIO_NET". Interfaces of any type match if it is not set.
+
+const (
+	nicTypeGVNIC       = "GVNIC"
+	nicTypeVirtioNet   = "VIRTIO_NET"
+	nicTypeUnspecified = "UNSPECIFIED_NIC_TYPE"
+)
+
+// normalizeNicType returns the canonical compute API value of a vNIC type.
+// Interfaces without a type use VIRTIO_NET, which is the compute default.
+func normalizeNicType(nicType string) string {
+	nicType = strings.ToUpper(strings.TrimSpace(nicType))
+	switch nicType {
+	case "", nicTypeUnspecified, "VIRTIO":
+		return nicTypeVirtioNet
+	}
+	return nicType
+}
+
+// interfaceMatchesNicType returns true if the vNIC type of the interface is the
+// one required by the GKENetworkParamSet, if any.
+func interfaceMatchesNicType(inf *compute.NetworkInterface, gnp *networkv1alpha1.GKENetworkParamSet) bool {
+	want, ok := gnp.Annotations[wellknown.NicTypeAnnotationKey]
+	if !ok {
+		return true
+	}
+	return normalizeNicType(inf.NicType) == normalizeNicType(want)
+}
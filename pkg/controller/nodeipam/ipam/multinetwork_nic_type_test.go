@@ -0,0 +1,55 @@
+package ipam
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func withNicType(gnp *networkv1alpha1.GKENetworkParamSet, nicType string) *networkv1alpha1.GKENetworkParamSet {
+	if gnp.Annotations == nil {
+		gnp.Annotations = map[string]string{}
+	}
+	gnp.Annotations[wellknown.NicTypeAnnotationKey] = nicType
+	return gnp
+}
+
+func nicType(inf *compute.NetworkInterface, nicType string) *compute.NetworkInterface {
+	inf.NicType = nicType
+	return inf
+}
+
+func TestInterfaceMatchesNicType(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		interfaceNic string
+		requiredNic  *string
+		want         bool
+	}{
+		{desc: "no requirement", interfaceNic: "GVNIC", want: true},
+		{desc: "same type", interfaceNic: "GVNIC", requiredNic: stringPtr("GVNIC"), want: true},
+		{desc: "case insensitive", interfaceNic: "GVNIC", requiredNic: stringPtr("gvnic"), want: true},
+		{desc: "different type", interfaceNic: "VIRTIO_NET", requiredNic: stringPtr("GVNIC")},
+		{desc: "unset interface type is virtio", requiredNic: stringPtr("VIRTIO_NET"), want: true},
+		{desc: "unspecified interface type is virtio", interfaceNic: "UNSPECIFIED_NIC_TYPE", requiredNic: stringPtr("VIRTIO"), want: true},
+		{desc: "unset interface type is not gVNIC", requiredNic: stringPtr("GVNIC")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gnp := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil)
+			if tc.requiredNic != nil {
+				gnp = withNicType(gnp, *tc.requiredNic)
+			}
+			inf := nicType(interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", nil), tc.interfaceNic)
+			if got := interfaceMatchesNicType(inf, gnp); got != tc.want {
+				t.Errorf("interfaceMatchesNicType() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
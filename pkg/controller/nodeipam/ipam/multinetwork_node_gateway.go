@@ -0,0 +1,61 @@
+package ipam
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// nodeGateway returns the gateway address of the node in the first IPv4 CIDR
+// of the network: the first usable address, or the address at the offset of
+// the node gateway.
+func nodeGateway(cidrs []string, gateway *networkv1alpha1.NodeGateway) (string, error) {
+	offset := uint32(1)
+	if gateway.Offset != nil {
+		offset = uint32(*gateway.Offset)
+	}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || ipNet.IP.To4() == nil {
+			continue
+		}
+		ones, bits := ipNet.Mask.Size()
+		// The network and broadcast addresses are not usable.
+		if bits-ones < 2 || uint64(offset) > uint64(1)<<(bits-ones)-2 {
+			return "", fmt.Errorf("offset %d is outside of the usable addresses of CIDR %s", offset, cidr)
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(ipNet.IP.To4())+offset)
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("no IPv4 CIDR in %v", cidrs)
+}
+
+// withNodeGateways returns the additional networks of the node with their
+// gateway address, if their GKENetworkParamSet declares a node gateway.
+// Networks whose gateway address cannot be taken from the CIDR of the node
+// are reported and published without gateway.
+func (ca *cloudCIDRAllocator) withNodeGateways(node *v1.Node, nodeNetworks networkv1.MultiNetworkAnnotation) networkv1.MultiNetworkAnnotation {
+	if nodeNetworks == nil {
+		return nil
+	}
+	ret := make(networkv1.MultiNetworkAnnotation, 0, len(nodeNetworks))
+	for _, nw := range nodeNetworks {
+		if network, err := ca.networksLister.Get(nw.Name); err == nil && network.Spec.ParametersRef != nil {
+			if gnp, err := ca.gnpLister.Get(network.Spec.ParametersRef.Name); err == nil && gnp.Spec.NodeGateway != nil {
+				if gateway, err := nodeGateway(nw.Cidrs, gnp.Spec.NodeGateway); err != nil {
+					ca.recordNetworkEvent(network.Name, node.Name, wellknown.InvalidNodeGatewayReason, fmt.Sprintf("Not publishing the gateway address of node %s: %v", node.Name, err))
+				} else {
+					nw.Gateway = gateway
+				}
+			}
+		}
+		ret = append(ret, nw)
+	}
+	return ret
+}
@@ -0,0 +1,109 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/utils/pointer"
+)
+
+func TestNodeGateway(t *testing.T) {
+	testCases := []struct {
+		cidrs   []string
+		offset  *int32
+		want    string
+		wantErr bool
+	}{
+		{cidrs: []string{"10.1.1.0/24"}, want: "10.1.1.1"},
+		{cidrs: []string{"10.1.1.0/24"}, offset: pointer.Int32(254), want: "10.1.1.254"},
+		{cidrs: []string{"10.1.1.0/24"}, offset: pointer.Int32(255), wantErr: true},
+		{cidrs: []string{"2001:db8::/64", "10.1.1.128/25"}, want: "10.1.1.129"},
+		{cidrs: []string{"10.1.1.4/31"}, wantErr: true},
+		{cidrs: []string{"2001:db8::/64"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := nodeGateway(tc.cidrs, &networkv1alpha1.NodeGateway{Offset: tc.offset})
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("nodeGateway(%v) returned err %v, want error %t", tc.cidrs, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("nodeGateway(%v) = %q, want %q", tc.cidrs, got, tc.want)
+		}
+	}
+}
+
+func TestWithNodeGateways(t *testing.T) {
+	nodeNetworks := networkv1.MultiNetworkAnnotation{
+		{Name: redNetworkName, Cidrs: []string{"10.1.1.0/24"}, Scope: "host-local"},
+		{Name: blueNetworkName, Cidrs: []string{"10.2.1.0/30"}, Scope: "host-local"},
+	}
+	testCases := []struct {
+		desc        string
+		red         *networkv1alpha1.NodeGateway
+		blue        *networkv1alpha1.NodeGateway
+		wantAnn     string
+		wantInvalid bool
+	}{
+		{
+			desc:    "no node gateway",
+			wantAnn: `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local"},{"name":"Blue-Network","cidrs":["10.2.1.0/30"],"scope":"host-local"}]`,
+		},
+		{
+			desc:    "node gateways",
+			red:     &networkv1alpha1.NodeGateway{},
+			blue:    &networkv1alpha1.NodeGateway{Offset: pointer.Int32(2)},
+			wantAnn: `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local","gateway":"10.1.1.1"},{"name":"Blue-Network","cidrs":["10.2.1.0/30"],"scope":"host-local","gateway":"10.2.1.2"}]`,
+		},
+		{
+			desc:        "offset outside of the node CIDR",
+			red:         &networkv1alpha1.NodeGateway{},
+			blue:        &networkv1alpha1.NodeGateway{Offset: pointer.Int32(3)},
+			wantAnn:     `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local","gateway":"10.1.1.1"},{"name":"Blue-Network","cidrs":["10.2.1.0/30"],"scope":"host-local"}]`,
+			wantInvalid: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			informerFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second)
+			nwInformer := informerFactory.Networking().V1().Networks()
+			gnpInformer := informerFactory.Networking().V1alpha1().GKENetworkParamSets()
+			for _, nw := range []struct {
+				name, gnp string
+				gateway   *networkv1alpha1.NodeGateway
+			}{
+				{redNetworkName, redGKENetworkParamsName, tc.red},
+				{blueNetworkName, blueGKENetworkParamsName, tc.blue},
+			} {
+				if err := nwInformer.Informer().GetStore().Add(network(nw.name, nw.gnp)); err != nil {
+					t.Fatalf("error in test setup, could not create network %s: %v", nw.name, err)
+				}
+				gnp := gkeNetworkParams(nw.gnp, "vpc", "subnet", []string{"range"})
+				gnp.Spec.NodeGateway = nw.gateway
+				if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+					t.Fatalf("error in test setup, could not create GKENetworkParamSet %s: %v", gnp.Name, err)
+				}
+			}
+			ca := &cloudCIDRAllocator{networksLister: nwInformer.Lister(), gnpLister: gnpInformer.Lister()}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+
+			ann, err := networkv1.MarshalAnnotation(ca.withNodeGateways(node, nodeNetworks))
+			if err != nil {
+				t.Fatalf("MarshalAnnotation() returned err %v", err)
+			}
+			if ann != tc.wantAnn {
+				t.Errorf("networks annotation = %s, want %s", ann, tc.wantAnn)
+			}
+			_, gotInvalid := ca.networkEvents[networkEventKey{network: blueNetworkName, reason: wellknown.InvalidNodeGatewayReason}]
+			if gotInvalid != tc.wantInvalid {
+				t.Errorf("got %s event %t, want %t", wellknown.InvalidNodeGatewayReason, gotInvalid, tc.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package ipam
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/klog/v2"
+)
+
+// nodeSelector returns the selector built from the nodeSelector field of the
+// Network spec, which attaches the network only to the nodes whose labels
+// match it, e.g. {"cloud.google.com/gke-nodepool": "pool-b"}, so that the
+// rollout of a network can be staged. It returns false if the Network has no
+// node selector.
+func nodeSelector(network *networkv1.Network) (labels.Selector, bool, error) {
+	if len(network.Spec.NodeSelector) == 0 {
+		return nil, false, nil
+	}
+	selector, err := labels.ValidatedSelectorFromSet(network.Spec.NodeSelector)
+	return selector, true, err
+}
+
+// selectsNode returns false if the node selector of the Network does not match
+// the node. Nodes not matching the selector are not attached to the network
+// even if their instance has an interface on it. The default network is never
+// filtered, and Networks with an invalid selector are attached to no node.
+func (ca *cloudCIDRAllocator) selectsNode(network *networkv1.Network, node *v1.Node) bool {
+	if ca.isDefaultNetwork(network) {
+		return true
+	}
+	selector, ok, err := nodeSelector(network)
+	if err != nil {
+		klog.Warningf("Ignoring network %s with invalid node selector %v: %v", network.Name, network.Spec.NodeSelector, err)
+		return false
+	}
+	return !ok || selector.Matches(labels.Set(node.Labels))
+}
+
+// nodeNetworks returns the networks whose node selector matches the node.
+func (ca *cloudCIDRAllocator) nodeNetworks(node *v1.Node, networks []*networkv1.Network) []*networkv1.Network {
+	selected := make([]*networkv1.Network, 0, len(networks))
+	for _, network := range networks {
+		if !ca.selectsNode(network, node) {
+			klog.V(4).Infof("network %s does not select node %s, skipping it", network.Name, node.Name)
+			continue
+		}
+		selected = append(selected, network)
+	}
+	return selected
+}
+
+// networkNodeSelector returns the node selector of the named Network, or a
+// selector matching nothing if the Network has none, so that widening the
+// selector attaches the newly selected nodes.
+func (ca *cloudCIDRAllocator) networkNodeSelector(networkName string) labels.Selector {
+	network, err := ca.networksLister.Get(networkName)
+	if err != nil {
+		return labels.Nothing()
+	}
+	selector, ok, err := nodeSelector(network)
+	if !ok || err != nil {
+		return labels.Nothing()
+	}
+	return selector
+}
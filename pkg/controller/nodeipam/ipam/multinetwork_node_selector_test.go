@@ -0,0 +1,118 @@
+package ipam
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func withNodeSelector(network *networkv1.Network, selector map[string]string) *networkv1.Network {
+	network.Spec.NodeSelector = selector
+	return network
+}
+
+func TestNodeNetworks(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Labels: map[string]string{"pool": "a", "zone": "us-central1-b"}}}
+	testCases := []struct {
+		desc     string
+		networks []*networkv1.Network
+		want     []string
+	}{
+		{
+			desc: "networks without selector select every node",
+			networks: []*networkv1.Network{
+				network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+				network(redNetworkName, redGKENetworkParamsName),
+			},
+			want: []string{networkv1.DefaultPodNetworkName, redNetworkName},
+		},
+		{
+			desc: "networks selecting other nodes are ignored",
+			networks: []*networkv1.Network{
+				withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{"pool": "a", "zone": "us-central1-b"}),
+				withNodeSelector(network(blueNetworkName, blueGKENetworkParamsName), map[string]string{"pool": "b"}),
+			},
+			want: []string{redNetworkName},
+		},
+		{
+			desc: "empty selector selects every node",
+			networks: []*networkv1.Network{
+				withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{}),
+			},
+			want: []string{redNetworkName},
+		},
+		{
+			desc: "networks with an invalid selector are ignored",
+			networks: []*networkv1.Network{
+				withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{"pool": "a b"}),
+			},
+			want: []string{},
+		},
+		{
+			desc: "the default network is never filtered",
+			networks: []*networkv1.Network{
+				withNodeSelector(network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName), map[string]string{"pool": "b"}),
+			},
+			want: []string{networkv1.DefaultPodNetworkName},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ca := &cloudCIDRAllocator{}
+			got := []string{}
+			for _, network := range ca.nodeNetworks(node, tc.networks) {
+				got = append(got, network.Name)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("nodeNetworks() returned unexpected networks (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodeSelectorChangeRequeuesSelectedNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pool-a", Labels: map[string]string{"pool": "a", "tier": "x"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pool-b", Labels: map[string]string{"pool": "b", "tier": "x"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled"}},
+	}
+	oldNetwork := withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{"pool": "a"})
+	newNetwork := withNodeSelector(network(redNetworkName, redGKENetworkParamsName), map[string]string{"tier": "x"})
+	if !networkChanged(oldNetwork, newNetwork) {
+		t.Errorf("networkChanged() = false after changing the node selector, want true")
+	}
+
+	nodeInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	for _, node := range nodes {
+		if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+			t.Fatalf("error in test setup, could not add node %s: %v", node.Name, err)
+		}
+	}
+	nwInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+	if err := nwInformer.Informer().GetStore().Add(newNetwork); err != nil {
+		t.Fatalf("error in test setup, could not add network: %v", err)
+	}
+	ca := withQueue(&cloudCIDRAllocator{
+		nodeLister:     nodeInformer.Lister(),
+		networksLister: nwInformer.Lister(),
+		started:        time.Now(),
+	})
+	networkUpdated(t, newTestMultiNetworkReconciler(ca, newNetwork), oldNetwork, newNetwork)
+	var got []string
+	for len(ca.queue.Updates()) > 0 {
+		got = append(got, <-ca.queue.Updates())
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"pool-a", "pool-b"}, got); diff != "" {
+		t.Errorf("requeued unexpected nodes (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,190 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/klog/v2"
+)
+
+// While a Network is paused by wellknown.NetworkPausedAnnotationKey, e.g.
+// during a dataplane maintenance, the north interfaces, CIDRs and delegated
+// ranges of the network published on the nodes are kept as they are, and
+// nodes are neither attached to nor detached from the network. The default
+// network cannot be paused.
+
+// networkPaused returns true if the non-default Network is paused by
+// wellknown.NetworkPausedAnnotationKey.
+func (ca *cloudCIDRAllocator) networkPaused(network *networkv1.Network) bool {
+	value, ok := network.Annotations[wellknown.NetworkPausedAnnotationKey]
+	if !ok {
+		return false
+	}
+	paused, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Warningf("Ignoring invalid %s annotation %q of Network %s", wellknown.NetworkPausedAnnotationKey, value, network.Name)
+		return false
+	}
+	if paused && ca.isDefaultNetwork(network) {
+		klog.Warningf("Ignoring the %s annotation of Network %s: the default network cannot be paused", wellknown.NetworkPausedAnnotationKey, network.Name)
+		return false
+	}
+	return paused
+}
+
+// unpausedNetworks returns the networks which are not paused. The paused
+// networks are held on the nodes by holdPausedNetworks instead of being
+// allocated.
+func (ca *cloudCIDRAllocator) unpausedNetworks(networks []*networkv1.Network) []*networkv1.Network {
+	unpaused := make([]*networkv1.Network, 0, len(networks))
+	for _, network := range networks {
+		if ca.networkPaused(network) {
+			klog.V(4).Infof("network %s is paused, skipping it", network.Name)
+			continue
+		}
+		unpaused = append(unpaused, network)
+	}
+	return unpaused
+}
+
+// holdPausedNetworks replaces the paused networks of the allocation with the
+// north interfaces, additional networks and delegated ranges of these networks
+// currently published on the node, so that the node is not changed for them.
+// The allocation is returned unchanged if the annotations of the node cannot
+// be parsed.
+func (ca *cloudCIDRAllocator) holdPausedNetworks(node *v1.Node, allocation multiNetworkAllocation) multiNetworkAllocation {
+	networks, err := ca.networksLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list the networks to hold the paused ones on node %s: %v", node.Name, err)
+		return allocation
+	}
+	paused := sets.NewString()
+	for _, network := range networks {
+		if ca.networkPaused(network) {
+			paused.Insert(network.Name)
+		}
+	}
+	if paused.Len() == 0 {
+		return allocation
+	}
+	northInterfaces, _, err := networkannotations.ParseNodeNorthInterfaces(node.Annotations)
+	if err != nil {
+		klog.Warningf("Not holding the paused networks %v on node %s: %v", paused.List(), node.Name, err)
+		return allocation
+	}
+	nodeNetworks, _, err := networkannotations.ParseNodeMultiNetwork(node.Annotations)
+	if err != nil {
+		klog.Warningf("Not holding the paused networks %v on node %s: %v", paused.List(), node.Name, err)
+		return allocation
+	}
+	var delegatedRanges DelegatedRangesAnnotation
+	if value, ok := node.Annotations[wellknown.DelegatedRangesAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &delegatedRanges); err != nil {
+			klog.Warningf("Not holding the paused networks %v on node %s: invalid %s annotation: %v", paused.List(), node.Name, wellknown.DelegatedRangesAnnotationKey, err)
+			return allocation
+		}
+	}
+
+	held := multiNetworkAllocation{DefaultNwCIDRs: allocation.DefaultNwCIDRs}
+	for _, inf := range allocation.NorthInterfaces {
+		if !paused.Has(inf.Network) {
+			held.NorthInterfaces = append(held.NorthInterfaces, inf)
+		}
+	}
+	for _, inf := range northInterfaces {
+		if paused.Has(inf.Network) {
+			held.NorthInterfaces = append(held.NorthInterfaces, inf)
+		}
+	}
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		if !paused.Has(nw.Name) {
+			held.AdditionalNodeNetworks = append(held.AdditionalNodeNetworks, nw)
+		}
+	}
+	for _, nw := range nodeNetworks {
+		if paused.Has(nw.Name) {
+			held.AdditionalNodeNetworks = append(held.AdditionalNodeNetworks, nw)
+		}
+	}
+	for _, r := range allocation.DelegatedRanges {
+		if !paused.Has(r.Network) {
+			held.DelegatedRanges = append(held.DelegatedRanges, r)
+		}
+	}
+	for _, r := range delegatedRanges {
+		if paused.Has(r.Network) {
+			held.DelegatedRanges = append(held.DelegatedRanges, r)
+		}
+	}
+	return held
+}
+
+// reportPausedNetworks sets wellknown.NetworkPausedConditionAnnotationKey on
+// the paused Networks and clears it from the resumed ones, recording an event
+// on every change. It is a no-op if the allocator has no Network client.
+func (ca *cloudCIDRAllocator) reportPausedNetworks(networks []*networkv1.Network) {
+	if ca.params.NetworkClient == nil {
+		return
+	}
+	for _, network := range networks {
+		_, reported := network.Annotations[wellknown.NetworkPausedConditionAnnotationKey]
+		paused := ca.networkPaused(network)
+		switch {
+		case paused && !reported:
+			condition := metav1.Condition{
+				Type:               wellknown.NetworkPausedConditionType,
+				Status:             metav1.ConditionTrue,
+				Reason:             wellknown.NetworkPausedReason,
+				Message:            fmt.Sprintf("Changes to the nodes attached to the Network are suspended by the %s annotation", wellknown.NetworkPausedAnnotationKey),
+				LastTransitionTime: metav1.Now(),
+			}
+			value, err := json.Marshal(condition)
+			if err != nil {
+				klog.Errorf("Failed to marshal paused condition of Network %s: %v", network.Name, err)
+				continue
+			}
+			klog.Infof("Network %s is paused", network.Name)
+			if err := ca.patchNetworkPausedCondition(network.Name, string(value)); err != nil {
+				klog.Errorf("Failed to set paused condition on Network %s: %v", network.Name, err)
+				continue
+			}
+			ca.recorder.Event(networkReference(network), v1.EventTypeNormal, wellknown.NetworkPausedReason, condition.Message)
+		case reported && !paused:
+			klog.Infof("Network %s is resumed", network.Name)
+			if err := ca.patchNetworkPausedCondition(network.Name, nil); err != nil {
+				klog.Errorf("Failed to clear paused condition on Network %s: %v", network.Name, err)
+				continue
+			}
+			ca.recorder.Event(networkReference(network), v1.EventTypeNormal, wellknown.NetworkResumedReason, "Changes to the nodes attached to the Network are resumed")
+		}
+	}
+}
+
+// patchNetworkPausedCondition sets the paused condition annotation of the
+// Network to value, or removes it if value is nil.
+func (ca *cloudCIDRAllocator) patchNetworkPausedCondition(name string, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{wellknown.NetworkPausedConditionAnnotationKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ca.params.NetworkClient.NetworkingV1().Networks().Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
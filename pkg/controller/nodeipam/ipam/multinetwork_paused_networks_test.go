@@ -0,0 +1,213 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func pausedNetwork(name, gkeNetworkParamsName, paused string) *networkv1.Network {
+	nw := network(name, gkeNetworkParamsName)
+	nw.Annotations = map[string]string{wellknown.NetworkPausedAnnotationKey: paused}
+	return nw
+}
+
+func TestNetworkPaused(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		network *networkv1.Network
+		want    bool
+	}{
+		{
+			desc:    "not annotated",
+			network: network(redNetworkName, redGKENetworkParamsName),
+		},
+		{
+			desc:    "paused",
+			network: pausedNetwork(redNetworkName, redGKENetworkParamsName, "true"),
+			want:    true,
+		},
+		{
+			desc:    "resumed",
+			network: pausedNetwork(redNetworkName, redGKENetworkParamsName, "false"),
+		},
+		{
+			desc:    "invalid value",
+			network: pausedNetwork(redNetworkName, redGKENetworkParamsName, "yes please"),
+		},
+		{
+			desc:    "default network",
+			network: pausedNetwork(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName, "true"),
+		},
+	}
+	ca := &cloudCIDRAllocator{}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := ca.networkPaused(tc.network); got != tc.want {
+				t.Errorf("networkPaused() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHoldPausedNetworks(t *testing.T) {
+	nwInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+	for _, nw := range []*networkv1.Network{
+		pausedNetwork(redNetworkName, redGKENetworkParamsName, "true"),
+		network("blue", "blue-gnp"),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not add network %s: %v", nw.Name, err)
+		}
+	}
+	northInterfacesAnn, err := networkv1.MarshalAnnotation(networkv1.NorthInterfacesAnnotation{
+		{Network: redNetworkName, IpAddress: "10.1.1.1"},
+		{Network: "blue", IpAddress: "10.2.1.1"},
+	})
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	nodeNetworksAnn, err := networkv1.MarshalAnnotation(networkv1.MultiNetworkAnnotation{
+		{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}},
+		{Name: "blue", Scope: "host-local", Cidrs: []string{"172.12.1.0/24"}},
+	})
+	if err != nil {
+		t.Fatalf("MarshalAnnotation() returned err %v", err)
+	}
+	testCases := []struct {
+		desc        string
+		annotations map[string]string
+		want        multiNetworkAllocation
+	}{
+		{
+			desc: "paused network held",
+			annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: northInterfacesAnn,
+				networkv1.MultiNetworkAnnotationKey:    nodeNetworksAnn,
+			},
+			want: multiNetworkAllocation{
+				NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+					{Network: "blue", IpAddress: "10.2.2.2"},
+					{Network: redNetworkName, IpAddress: "10.1.1.1"},
+				},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.12.2.0/24"}},
+					{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}},
+				},
+			},
+		},
+		{
+			desc: "paused network not attached to the node",
+			want: multiNetworkAllocation{
+				NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "blue", IpAddress: "10.2.2.2"}},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{{Name: "blue", Scope: "host-local", Cidrs: []string{"172.12.2.0/24"}}},
+			},
+		},
+		{
+			desc:        "invalid annotations",
+			annotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: "{"},
+			want: multiNetworkAllocation{
+				NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+					{Network: "blue", IpAddress: "10.2.2.2"},
+					{Network: redNetworkName, IpAddress: "10.1.2.2"},
+				},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.12.2.0/24"}},
+					{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.2.0/24"}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ca := &cloudCIDRAllocator{networksLister: nwInformer.Lister()}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			// The interfaces of both networks moved since the node was published.
+			allocation := multiNetworkAllocation{
+				NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+					{Network: "blue", IpAddress: "10.2.2.2"},
+					{Network: redNetworkName, IpAddress: "10.1.2.2"},
+				},
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.12.2.0/24"}},
+					{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.2.0/24"}},
+				},
+			}
+			got := ca.holdPausedNetworks(node, allocation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("holdPausedNetworks() returned unexpected allocation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReportPausedNetworks(t *testing.T) {
+	red := pausedNetwork(redNetworkName, redGKENetworkParamsName, "true")
+	client := fake.NewSimpleClientset(red)
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{
+		recorder: recorder,
+		params:   CloudAllocatorParams{NetworkClient: client},
+	}
+
+	ca.reportPausedNetworks([]*networkv1.Network{red})
+	got, err := client.NetworkingV1().Networks().Get(context.TODO(), redNetworkName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", redNetworkName, err)
+	}
+	var condition metav1.Condition
+	if err := json.Unmarshal([]byte(got.Annotations[wellknown.NetworkPausedConditionAnnotationKey]), &condition); err != nil {
+		t.Fatalf("invalid paused condition annotation %q: %v", got.Annotations[wellknown.NetworkPausedConditionAnnotationKey], err)
+	}
+	if condition.Type != wellknown.NetworkPausedConditionType || condition.Status != metav1.ConditionTrue || condition.Reason != wellknown.NetworkPausedReason {
+		t.Errorf("paused condition = %+v, want a true %s condition", condition, wellknown.NetworkPausedConditionType)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeNormal+" "+wellknown.NetworkPausedReason+" "+condition.Message {
+		t.Errorf("recorded event %q", event)
+	}
+
+	// Reporting the paused network again does not patch nor record anything.
+	client.ClearActions()
+	ca.reportPausedNetworks([]*networkv1.Network{got})
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no API calls for an up to date condition, got %v", actions)
+	}
+
+	// The condition is cleared once the network is resumed.
+	delete(got.Annotations, wellknown.NetworkPausedAnnotationKey)
+	ca.reportPausedNetworks([]*networkv1.Network{got})
+	got, err = client.NetworkingV1().Networks().Get(context.TODO(), redNetworkName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", redNetworkName, err)
+	}
+	if _, ok := got.Annotations[wellknown.NetworkPausedConditionAnnotationKey]; ok {
+		t.Errorf("paused condition annotation was not removed: %v", got.Annotations)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeNormal+" "+wellknown.NetworkResumedReason+" Changes to the nodes attached to the Network are resumed" {
+		t.Errorf("recorded event %q", event)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected events recorded: %d", len(recorder.Events))
+	}
+}
+
+func TestNetworkChangedPaused(t *testing.T) {
+	oldNetwork := network(redNetworkName, redGKENetworkParamsName)
+	newNetwork := pausedNetwork(redNetworkName, redGKENetworkParamsName, "true")
+	if !networkChanged(oldNetwork, newNetwork) {
+		t.Errorf("networkChanged() = false after pausing the network, want true")
+	}
+	if !networkChanged(newNetwork, oldNetwork) {
+		t.Errorf("networkChanged() = false after resuming the network, want true")
+	}
+}
@@ -0,0 +1,155 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+	"k8s.io/klog/v2"
+)
+
+// Peered VPCs are reachable from the node, yet pod CIDRs can only be allocated
+// from the subnets of the VPCs the node is attached to, see
+// wellknown.VPCNotAttachedToNodeCondition.
+
+const (
+	// peeringCacheTTL is how long the peerings of a VPC are cached.
+	peeringCacheTTL = 10 * time.Minute
+)
+
+// peeringCacheEntry holds the VPCs peered with a VPC.
+type peeringCacheEntry struct {
+	peers   []string
+	fetched time.Time
+}
+
+// vpcPeers returns the URLs of the VPCs peered with the given VPC.
+func (ca *cloudCIDRAllocator) vpcPeers(vpc string) ([]string, error) {
+	ca.lock.Lock()
+	entry, ok := ca.peeringCache[vpc]
+	ca.lock.Unlock()
+	if ok && time.Since(entry.fetched) < peeringCacheTTL {
+		return entry.peers, nil
+	}
+
+	id, err := gcpurl.Parse(vpc)
+	if err != nil {
+		return nil, err
+	}
+	id = id.Qualified(gcpurl.KindNetworks, ca.urlDefaults())
+	getNetwork := ca.getNetwork
+	if getNetwork == nil {
+		getNetwork = func(project, name string) (*compute.Network, error) {
+			return ca.cloud.ComputeServices().GA.Networks.Get(project, name).Do()
+		}
+	}
+	network, err := getNetwork(id.Project, id.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VPC %s: %v", id, err)
+	}
+	var peers []string
+	for _, peering := range network.Peerings {
+		if peering != nil && peering.Network != "" {
+			peers = append(peers, peering.Network)
+		}
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.peeringCache == nil {
+		ca.peeringCache = make(map[string]peeringCacheEntry)
+	}
+	ca.peeringCache[vpc] = peeringCacheEntry{peers: peers, fetched: time.Now()}
+	return peers, nil
+}
+
+// peeredNotAttachedNetworks returns a description of each Network whose VPC is
+// not attached to any of the interfaces, but peered with the VPC of one of them.
+func (ca *cloudCIDRAllocator) peeredNotAttachedNetworks(interfaces []*compute.NetworkInterface) ([]string, error) {
+	networkList, err := ca.networksLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	urlDefaults := ca.urlDefaults()
+	var found []string
+	for _, network := range ca.activeNetworks(networkList) {
+		if ca.isDefaultNetwork(network) || network.Spec.ParametersRef == nil {
+			continue
+		}
+		gnp, err := ca.gnpLister.Get(network.Spec.ParametersRef.Name)
+		if err != nil {
+			continue
+		}
+		attached := false
+		for _, inf := range interfaces {
+			if gcpurl.Matches(inf.Network, gnp.Spec.VPC, gcpurl.KindNetworks, urlDefaults) {
+				attached = true
+				break
+			}
+		}
+		if attached {
+			continue
+		}
+	interfaces:
+		for _, inf := range interfaces {
+			peers, err := ca.vpcPeers(inf.Network)
+			if err != nil {
+				return nil, err
+			}
+			for _, peer := range peers {
+				if gcpurl.Matches(peer, gnp.Spec.VPC, gcpurl.KindNetworks, urlDefaults) {
+					found = append(found, fmt.Sprintf("Network %s: VPC %s is peered with VPC %s of interface %s", network.Name, gnp.Spec.VPC, inf.Network, inf.Name))
+					break interfaces
+				}
+			}
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// reportPeeredVPCs sets wellknown.VPCNotAttachedToNodeCondition on the node if
+// some Networks cannot be allocated because their VPC is only peered with the
+// VPCs of the node, and clears it once they can. Lookup errors are only logged,
+// as they do not affect the allocation.
+func (ca *cloudCIDRAllocator) reportPeeredVPCs(node *v1.Node, interfaces []*compute.NetworkInterface) {
+	found, err := ca.peeredNotAttachedNetworks(interfaces)
+	if err != nil {
+		klog.ErrorS(err, "Failed to check the VPC peerings of the node", "nodeName", node.Name)
+		return
+	}
+	condition := v1.NodeCondition{
+		Type:   wellknown.VPCNotAttachedToNodeCondition,
+		Status: v1.ConditionFalse,
+		Reason: wellknown.VPCsAttachedReason,
+	}
+	if len(found) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = wellknown.VPCPeeredReason
+		condition.Message = "Pod CIDRs are not allocated for networks whose VPC is peered with, but not attached to the node: " + strings.Join(found, "; ")
+	}
+	_, existing := nodeutil.GetNodeCondition(&node.Status, wellknown.VPCNotAttachedToNodeCondition)
+	if existing == nil && condition.Status == v1.ConditionFalse {
+		return
+	}
+	if existing != nil && existing.Status == condition.Status && existing.Message == condition.Message {
+		return
+	}
+	if condition.Status == v1.ConditionTrue {
+		klog.InfoS("Node is not attached to the VPCs of some networks", "nodeName", node.Name, "networks", found)
+	}
+	condition.LastTransitionTime = metav1.Now()
+	if err := utilnode.SetNodeCondition(ca.client, types.NodeName(node.Name), condition); err != nil {
+		klog.ErrorS(err, "Error setting the VPC attachment condition of the node", "nodeName", node.Name)
+	}
+}
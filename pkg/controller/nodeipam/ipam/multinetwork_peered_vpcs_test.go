@@ -0,0 +1,102 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+)
+
+func TestReportPeeredVPCs(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	clientSet := k8sfake.NewSimpleClientset(node)
+
+	nwClientSet := fake.NewSimpleClientset()
+	nwInfFactory := networkinformers.NewSharedInformerFactory(nwClientSet, 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{network(redNetworkName, redGKENetworkParamsName)} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, nil)} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+
+	getNetworkCalls := 0
+	ca := &cloudCIDRAllocator{
+		client:         clientSet,
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		getNetwork: func(project, name string) (*compute.Network, error) {
+			getNetworkCalls++
+			if name != "default" {
+				return &compute.Network{Name: name}, nil
+			}
+			return &compute.Network{
+				Name:     name,
+				Peerings: []*compute.NetworkPeering{{Name: "to-red", Network: redVPCName}},
+			}, nil
+		},
+	}
+	getCondition := func() *v1.NodeCondition {
+		t.Helper()
+		got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the node: %v", err)
+		}
+		node = got
+		_, condition := nodeutil.GetNodeCondition(&got.Status, wellknown.VPCNotAttachedToNodeCondition)
+		return condition
+	}
+	peered := []*compute.NetworkInterface{
+		{Name: "nic0", Network: defaultVPCName},
+	}
+	attached := []*compute.NetworkInterface{
+		{Name: "nic0", Network: defaultVPCName},
+		{Name: "nic1", Network: redVPCName},
+	}
+
+	// An attached VPC is not reported.
+	ca.reportPeeredVPCs(node, attached)
+	if condition := getCondition(); condition != nil {
+		t.Errorf("got condition %v for attached VPCs, want none", condition)
+	}
+
+	// A peered VPC is reported.
+	ca.reportPeeredVPCs(node, peered)
+	condition := getCondition()
+	if condition == nil || condition.Status != v1.ConditionTrue || condition.Reason != wellknown.VPCPeeredReason {
+		t.Fatalf("got condition %v for a peered VPC, want status True with reason %s", condition, wellknown.VPCPeeredReason)
+	}
+
+	// Peerings are cached and an unchanged condition is not written again.
+	clientSet.ClearActions()
+	ca.reportPeeredVPCs(node, peered)
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v for an unchanged condition, want none", actions)
+	}
+	if getNetworkCalls != 1 {
+		t.Errorf("got %d VPC lookups, want 1", getNetworkCalls)
+	}
+
+	// The condition is cleared once the VPC is attached.
+	ca.reportPeeredVPCs(node, attached)
+	condition = getCondition()
+	if condition == nil || condition.Status != v1.ConditionFalse || condition.Reason != wellknown.VPCsAttachedReason {
+		t.Errorf("got condition %v once the VPC is attached, want status False with reason %s", condition, wellknown.VPCsAttachedReason)
+	}
+}
@@ -0,0 +1,137 @@
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// The value of wellknown.PinnedNetworksAnnotationKey is a JSON map from
+// additional network name to CIDRs, e.g. {"red":["172.16.0.0/24"]}. The
+// pinned CIDRs are published in the networks annotation of the node instead of
+// the allocated ones, e.g. to fix the ranges of a node by hand during an
+// incident. The allocation of the network resumes once the annotation is
+// removed.
+
+// parsePinnedNetworks returns the pinned CIDRs of the node by network, and
+// false if the node has no wellknown.PinnedNetworksAnnotationKey annotation.
+func parsePinnedNetworks(node *v1.Node) (map[string][]string, bool, error) {
+	value, ok := node.Annotations[wellknown.PinnedNetworksAnnotationKey]
+	if !ok {
+		return nil, false, nil
+	}
+	var pinned map[string][]string
+	if err := json.Unmarshal([]byte(value), &pinned); err != nil {
+		return nil, true, fmt.Errorf("invalid %s annotation: %v", wellknown.PinnedNetworksAnnotationKey, err)
+	}
+	for network, cidrs := range pinned {
+		if network == "" || len(cidrs) == 0 {
+			return nil, true, fmt.Errorf("invalid %s annotation: network %q has no CIDRs", wellknown.PinnedNetworksAnnotationKey, network)
+		}
+		for _, cidr := range cidrs {
+			if _, _, err := netutils.ParseCIDRSloppy(cidr); err != nil {
+				return nil, true, fmt.Errorf("invalid %s annotation: invalid CIDR %q of network %s", wellknown.PinnedNetworksAnnotationKey, cidr, network)
+			}
+		}
+	}
+	return pinned, true, nil
+}
+
+// pinAdditionalNetworks replaces the CIDRs of the additional networks pinned by
+// the wellknown.PinnedNetworksAnnotationKey annotation of the node with the
+// pinned ones, and reports them in the wellknown.PinnedAllocationCondition of
+// the node. Pinned networks the node is not attached to are ignored. An invalid
+// annotation is reported and ignored, so the allocated CIDRs are published.
+func (ca *cloudCIDRAllocator) pinAdditionalNetworks(node *v1.Node, allocation multiNetworkAllocation) multiNetworkAllocation {
+	pinned, ok, err := parsePinnedNetworks(node)
+	if !ok {
+		ca.setPinnedAllocationCondition(node, v1.ConditionFalse, wellknown.NetworksNotPinnedReason, "")
+		return allocation
+	}
+	if err != nil {
+		klog.Warningf("Ignoring the pinned networks of node %s: %v", node.Name, err)
+		ca.setPinnedAllocationCondition(node, v1.ConditionFalse, wellknown.InvalidPinnedNetworksReason, err.Error())
+		return allocation
+	}
+
+	attached := make(map[string]bool)
+	for _, inf := range allocation.NorthInterfaces {
+		attached[inf.Network] = true
+	}
+	applied := make(map[string]bool)
+	result := allocation
+	result.AdditionalNodeNetworks = nil
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		if cidrs, ok := pinned[nw.Name]; ok {
+			nw.Cidrs = cidrs
+			applied[nw.Name] = true
+		}
+		result.AdditionalNodeNetworks = append(result.AdditionalNodeNetworks, nw)
+	}
+	names := make([]string, 0, len(pinned))
+	for network := range pinned {
+		names = append(names, network)
+	}
+	sort.Strings(names)
+	var networks, ignored []string
+	for _, network := range names {
+		switch {
+		case applied[network]:
+			networks = append(networks, network)
+		case attached[network]:
+			// Networks without allocated ranges, e.g. with host networking.
+			result.AdditionalNodeNetworks = append(result.AdditionalNodeNetworks, networkv1.NodeNetwork{Name: network, Scope: networkannotations.HostLocalScope, Cidrs: pinned[network]})
+			networks = append(networks, network)
+		default:
+			ignored = append(ignored, network)
+		}
+	}
+	if len(ignored) > 0 {
+		klog.Warningf("Ignoring the pinned networks %v of node %s, which is not attached to them", ignored, node.Name)
+	}
+	if len(networks) == 0 {
+		ca.setPinnedAllocationCondition(node, v1.ConditionFalse, wellknown.NetworksNotPinnedReason, fmt.Sprintf("Node is not attached to pinned networks %s", strings.Join(ignored, ", ")))
+		return allocation
+	}
+	klog.V(2).Infof("Publishing the pinned CIDRs of networks %v on node %s", networks, node.Name)
+	message := fmt.Sprintf("CIDRs of networks %s are pinned by the %s annotation", strings.Join(networks, ", "), wellknown.PinnedNetworksAnnotationKey)
+	if len(ignored) > 0 {
+		message += fmt.Sprintf(", node is not attached to pinned networks %s", strings.Join(ignored, ", "))
+	}
+	ca.setPinnedAllocationCondition(node, v1.ConditionTrue, wellknown.NetworksPinnedReason, message)
+	return result
+}
+
+// setPinnedAllocationCondition sets the wellknown.PinnedAllocationCondition of
+// the node if it changed. A false condition is only set on nodes that have one,
+// so that nodes never pinned are not updated.
+func (ca *cloudCIDRAllocator) setPinnedAllocationCondition(node *v1.Node, status v1.ConditionStatus, reason, message string) {
+	_, condition := nodeutil.GetNodeCondition(&node.Status, wellknown.PinnedAllocationCondition)
+	if condition == nil && status == v1.ConditionFalse && reason == wellknown.NetworksNotPinnedReason {
+		return
+	}
+	if condition != nil && condition.Status == status && condition.Reason == reason && condition.Message == message {
+		return
+	}
+	err := utilnode.SetNodeCondition(ca.client, types.NodeName(node.Name), v1.NodeCondition{
+		Type:               wellknown.PinnedAllocationCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: ca.now(),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Error setting the pinned allocation condition of the node", "nodeName", node.Name)
+	}
+}
@@ -0,0 +1,128 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+)
+
+func TestPinAdditionalNetworks(t *testing.T) {
+	allocation := multiNetworkAllocation{
+		DefaultNwCIDRs: []string{"10.0.0.0/24"},
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: "red", IpAddress: "10.1.0.2"},
+			{Network: "blue", IpAddress: "10.2.0.2"},
+			{Network: "green", IpAddress: "10.3.0.2"},
+		},
+		AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+			{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}},
+			{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+		},
+	}
+	testCases := []struct {
+		desc          string
+		annotation    *string
+		condition     *v1.NodeCondition
+		want          multiNetworkAllocation
+		wantCondition *v1.NodeCondition
+	}{
+		{
+			desc: "no annotation",
+			want: allocation,
+		},
+		{
+			desc:          "pinned networks",
+			annotation:    stringPtr(`{"red":["172.16.9.0/24"],"green":["172.18.0.0/24"]}`),
+			wantCondition: &v1.NodeCondition{Status: v1.ConditionTrue, Reason: wellknown.NetworksPinnedReason, Message: "CIDRs of networks green, red are pinned by the networking.gke.io/pinned-networks annotation"},
+			want: multiNetworkAllocation{
+				DefaultNwCIDRs:  allocation.DefaultNwCIDRs,
+				NorthInterfaces: allocation.NorthInterfaces,
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.9.0/24"}},
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+					{Name: "green", Scope: "host-local", Cidrs: []string{"172.18.0.0/24"}},
+				},
+			},
+		},
+		{
+			desc:          "network not attached",
+			annotation:    stringPtr(`{"red":["172.16.9.0/24"],"yellow":["172.19.0.0/24"]}`),
+			wantCondition: &v1.NodeCondition{Status: v1.ConditionTrue, Reason: wellknown.NetworksPinnedReason, Message: "CIDRs of networks red are pinned by the networking.gke.io/pinned-networks annotation, node is not attached to pinned networks yellow"},
+			want: multiNetworkAllocation{
+				DefaultNwCIDRs:  allocation.DefaultNwCIDRs,
+				NorthInterfaces: allocation.NorthInterfaces,
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.9.0/24"}},
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+				},
+			},
+		},
+		{
+			desc:          "invalid CIDR",
+			annotation:    stringPtr(`{"red":["172.16.9.0/33"]}`),
+			wantCondition: &v1.NodeCondition{Status: v1.ConditionFalse, Reason: wellknown.InvalidPinnedNetworksReason, Message: `invalid networking.gke.io/pinned-networks annotation: invalid CIDR "172.16.9.0/33" of network red`},
+			want:          allocation,
+		},
+		{
+			desc:          "invalid JSON",
+			annotation:    stringPtr(`["172.16.9.0/24"]`),
+			wantCondition: &v1.NodeCondition{Status: v1.ConditionFalse, Reason: wellknown.InvalidPinnedNetworksReason, Message: "invalid networking.gke.io/pinned-networks annotation: json: cannot unmarshal array into Go value of type map[string][]string"},
+			want:          allocation,
+		},
+		{
+			desc:          "annotation removed",
+			condition:     &v1.NodeCondition{Type: wellknown.PinnedAllocationCondition, Status: v1.ConditionTrue, Reason: wellknown.NetworksPinnedReason},
+			wantCondition: &v1.NodeCondition{Status: v1.ConditionFalse, Reason: wellknown.NetworksNotPinnedReason},
+			want:          allocation,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+			if tc.annotation != nil {
+				node.Annotations = map[string]string{wellknown.PinnedNetworksAnnotationKey: *tc.annotation}
+			}
+			if tc.condition != nil {
+				node.Status.Conditions = []v1.NodeCondition{*tc.condition}
+			}
+			client := fake.NewSimpleClientset(node)
+			ca := &cloudCIDRAllocator{client: client}
+
+			got := ca.pinAdditionalNetworks(node, allocation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("pinAdditionalNetworks() mismatch (-want +got):\n%s", diff)
+			}
+			updated, err := client.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the node: %v", err)
+			}
+			_, condition := nodeutil.GetNodeCondition(&updated.Status, wellknown.PinnedAllocationCondition)
+			if tc.wantCondition == nil {
+				if condition != nil {
+					t.Errorf("got condition %+v, want none", condition)
+				}
+				return
+			}
+			if condition == nil {
+				t.Fatalf("got no %s condition, want %+v", wellknown.PinnedAllocationCondition, tc.wantCondition)
+			}
+			if condition.Status != tc.wantCondition.Status || condition.Reason != tc.wantCondition.Reason || condition.Message != tc.wantCondition.Message {
+				t.Errorf("got condition %s %s %q, want %s %s %q", condition.Status, condition.Reason, condition.Message, tc.wantCondition.Status, tc.wantCondition.Reason, tc.wantCondition.Message)
+			}
+
+			// An unchanged condition is not written again.
+			client.ClearActions()
+			ca.pinAdditionalNetworks(updated, allocation)
+			if actions := client.Actions(); len(actions) != 0 {
+				t.Errorf("got API calls %v for an unchanged condition, want none", actions)
+			}
+		})
+	}
+}
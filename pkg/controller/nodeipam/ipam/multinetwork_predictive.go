@@ -0,0 +1,225 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+	"k8s.io/klog/v2"
+)
+
+// wellknown.ExpectedNetworksAnnotationKey is set on nodes whose instance is not
+// visible yet, or has no alias IP ranges yet, to the additional networks
+// predicted from the instance template of its managed instance group, so that
+// node agents can start setting up these networks before the allocation
+// completes. It is removed once the multi-network state is published.
+
+const (
+	// instanceGroupsCacheTTL is how long the managed instance groups of a zone
+	// are cached. Instance templates are immutable and cached forever.
+	instanceGroupsCacheTTL = 10 * time.Minute
+)
+
+// instanceGroupsCacheEntry holds the instance templates of the managed
+// instance groups of a zone, by base instance name.
+type instanceGroupsCacheEntry struct {
+	templates map[string]string
+	fetched   time.Time
+}
+
+// instanceGroupTemplates returns the URLs of the instance templates of the
+// managed instance groups of the zone, by base instance name.
+func (ca *cloudCIDRAllocator) instanceGroupTemplates(project, zone string) (map[string]string, error) {
+	key := project + "/" + zone
+	ca.lock.Lock()
+	entry, ok := ca.instanceGroupsCache[key]
+	ca.lock.Unlock()
+	if ok && time.Since(entry.fetched) < instanceGroupsCacheTTL {
+		return entry.templates, nil
+	}
+
+	listInstanceGroups := ca.listInstanceGroups
+	if listInstanceGroups == nil {
+		listInstanceGroups = func(project, zone string) ([]*compute.InstanceGroupManager, error) {
+			var groups []*compute.InstanceGroupManager
+			err := ca.cloud.ComputeServices().GA.InstanceGroupManagers.List(project, zone).Pages(context.Background(), func(page *compute.InstanceGroupManagerList) error {
+				groups = append(groups, page.Items...)
+				return nil
+			})
+			return groups, err
+		}
+	}
+	groups, err := listInstanceGroups(project, zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the managed instance groups of zone %s: %v", zone, err)
+	}
+	templates := make(map[string]string, len(groups))
+	for _, group := range groups {
+		if group.BaseInstanceName != "" && group.InstanceTemplate != "" {
+			templates[group.BaseInstanceName] = group.InstanceTemplate
+		}
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.instanceGroupsCache == nil {
+		ca.instanceGroupsCache = make(map[string]instanceGroupsCacheEntry)
+	}
+	ca.instanceGroupsCache[key] = instanceGroupsCacheEntry{templates: templates, fetched: time.Now()}
+	return templates, nil
+}
+
+// instanceTemplateInterfaces returns the network interfaces of the instance
+// template.
+func (ca *cloudCIDRAllocator) instanceTemplateInterfaces(template string) ([]*compute.NetworkInterface, error) {
+	ca.lock.Lock()
+	interfaces, ok := ca.templateInterfaces[template]
+	ca.lock.Unlock()
+	if ok {
+		return interfaces, nil
+	}
+
+	id, err := gcpurl.Parse(template)
+	if err != nil {
+		return nil, err
+	}
+	if id.Region != "" {
+		return nil, fmt.Errorf("regional instance template %s is not supported", template)
+	}
+	if id.Project == "" && ca.cloud != nil {
+		id.Project = ca.cloud.ProjectID()
+	}
+	getInstanceTemplate := ca.getInstanceTemplate
+	if getInstanceTemplate == nil {
+		getInstanceTemplate = func(project, name string) (*compute.InstanceTemplate, error) {
+			return ca.cloud.ComputeServices().GA.InstanceTemplates.Get(project, name).Do()
+		}
+	}
+	it, err := getInstanceTemplate(id.Project, id.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance template %s: %v", template, err)
+	}
+	if it.Properties != nil {
+		interfaces = it.Properties.NetworkInterfaces
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.templateInterfaces == nil {
+		ca.templateInterfaces = make(map[string][]*compute.NetworkInterface)
+	}
+	ca.templateInterfaces[template] = interfaces
+	return interfaces, nil
+}
+
+// predictedInterfaces returns the network interfaces of the instance template
+// of the managed instance group the node belongs to, matched by the longest
+// base instance name prefixing the instance name.
+func (ca *cloudCIDRAllocator) predictedInterfaces(node *v1.Node) ([]*compute.NetworkInterface, error) {
+	match := gceProviderIDRE.FindStringSubmatch(node.Spec.ProviderID)
+	if match == nil {
+		return nil, fmt.Errorf("invalid providerID %q", node.Spec.ProviderID)
+	}
+	project, zone, instance := match[1], match[2], match[3]
+	templates, err := ca.instanceGroupTemplates(project, zone)
+	if err != nil {
+		return nil, err
+	}
+	var baseInstanceName string
+	for name := range templates {
+		if strings.HasPrefix(instance, name+"-") && len(name) > len(baseInstanceName) {
+			baseInstanceName = name
+		}
+	}
+	if baseInstanceName == "" {
+		return nil, fmt.Errorf("instance %s is not part of a managed instance group of zone %s", instance, zone)
+	}
+	return ca.instanceTemplateInterfaces(templates[baseInstanceName])
+}
+
+// expectedNetworks returns the sorted names of the additional networks whose
+// VPC and subnet match an interface of the instance template of the node.
+// Only the networks are predicted; the addresses and ranges of the interfaces
+// are not known before the instance exists.
+func (ca *cloudCIDRAllocator) expectedNetworks(node *v1.Node) ([]string, error) {
+	interfaces, err := ca.predictedInterfaces(node)
+	if err != nil {
+		return nil, err
+	}
+	k8sNetworksList, err := ca.networksLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching networks: %v", err)
+	}
+	urlDefaults := ca.urlDefaults()
+	active, _ := resolveNetworkConflicts(ca.activeNetworks(k8sNetworksList), ca.isDefaultNetwork, ca.gnpLister, urlDefaults)
+	var names []string
+	for _, network := range ca.nodeNetworks(node, active) {
+		if ca.isDefaultNetwork(network) {
+			continue
+		}
+		gnp, err := ca.gnpLister.Get(network.Spec.ParametersRef.Name)
+		if err != nil || !gkenetworkparamset.SubnetReady(gnp) || !ca.paramsReady(gnp) {
+			continue
+		}
+		for _, inf := range interfaces {
+			if interfaceInSubnet(inf, gnp, urlDefaults) && interfaceMatchesNicType(inf, gnp) {
+				names = append(names, network.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// publishExpectedNetworks sets wellknown.ExpectedNetworksAnnotationKey on a
+// node whose instance cannot be allocated yet. Errors are logged, the
+// prediction is only an optimization.
+func (ca *cloudCIDRAllocator) publishExpectedNetworks(node *v1.Node) {
+	if !ca.params.PredictiveAllocation || !ca.multiNetworkEnabled() {
+		return
+	}
+	names, err := ca.expectedNetworks(node)
+	if err != nil {
+		klog.V(2).InfoS("Failed to predict the networks of the node", "nodeName", node.Name, "err", err)
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	ann, err := json.Marshal(names)
+	if err != nil {
+		klog.ErrorS(err, "Failed to marshal the expected networks of the node", "nodeName", node.Name)
+		return
+	}
+	if node.Annotations[wellknown.ExpectedNetworksAnnotationKey] == string(ann) {
+		return
+	}
+	if err := ca.publishNodeUpdate(node, nodeUpdate{Annotations: map[string]string{wellknown.ExpectedNetworksAnnotationKey: string(ann)}, Reason: auditReasonPredictedNetworks}); err != nil {
+		return
+	}
+	klog.V(2).InfoS("Published the networks predicted from the instance template", "nodeName", node.Name, "networks", names)
+}
+
+// clearExpectedNetworks removes wellknown.ExpectedNetworksAnnotationKey from
+// the node once its multi-network state is published.
+func (ca *cloudCIDRAllocator) clearExpectedNetworks(node *v1.Node) error {
+	if _, ok := node.Annotations[wellknown.ExpectedNetworksAnnotationKey]; !ok {
+		return nil
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, wellknown.ExpectedNetworksAnnotationKey))
+	if _, err := ca.patchNode(node.Name, types.MergePatchType, patch); err != nil {
+		return fmt.Errorf("failed to remove the expected networks of node %s: %v", node.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func TestPublishExpectedNetworks(t *testing.T) {
+	nwInfFactory := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{
+		network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+		network(redNetworkName, redGKENetworkParamsName),
+		network(blueNetworkName, blueGKENetworkParamsName),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+		gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, []string{blueSecondaryRangeA}),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	templates := map[string]*compute.InstanceTemplate{
+		"pool-a": {Properties: &compute.InstanceProperties{NetworkInterfaces: []*compute.NetworkInterface{
+			interfaces(defaultVPCName, defaultVPCSubnetName, "", nil),
+			interfaces(redVPCName, redVPCSubnetName, "", nil),
+		}}},
+		"pool-ab": {Properties: &compute.InstanceProperties{NetworkInterfaces: []*compute.NetworkInterface{
+			interfaces(defaultVPCName, defaultVPCSubnetName, "", nil),
+			interfaces(blueVPCName, blueVPCSubnetName, "", nil),
+		}}},
+	}
+	var templateGets int
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "gke-pool-ab-1234"},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/gke-pool-ab-1234"},
+	}
+	clientSet := fake.NewSimpleClientset(node)
+	ca := &cloudCIDRAllocator{
+		client:         clientSet,
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		params:         CloudAllocatorParams{EnableMultiNetworking: true, PredictiveAllocation: true},
+		listInstanceGroups: func(project, zone string) ([]*compute.InstanceGroupManager, error) {
+			return []*compute.InstanceGroupManager{
+				{BaseInstanceName: "gke-pool", InstanceTemplate: "projects/test-project/global/instanceTemplates/pool-a"},
+				{BaseInstanceName: "gke-pool-ab", InstanceTemplate: "projects/test-project/global/instanceTemplates/pool-ab"},
+			}, nil
+		},
+		getInstanceTemplate: func(project, name string) (*compute.InstanceTemplate, error) {
+			templateGets++
+			return templates[name], nil
+		},
+	}
+
+	getAnnotations := func() map[string]string {
+		t.Helper()
+		got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the node: %v", err)
+		}
+		return got.Annotations
+	}
+
+	ca.publishExpectedNetworks(node)
+	want := `["Blue-Network"]`
+	if got := getAnnotations()[wellknown.ExpectedNetworksAnnotationKey]; got != want {
+		t.Errorf("expected networks = %s, want %s", got, want)
+	}
+	ca.publishExpectedNetworks(node)
+	if templateGets != 1 {
+		t.Errorf("got %d instance template gets, want the template to be cached", templateGets)
+	}
+
+	node.Annotations = getAnnotations()
+	if err := ca.clearExpectedNetworks(node); err != nil {
+		t.Fatalf("clearExpectedNetworks() returned err %v", err)
+	}
+	if got, ok := getAnnotations()[wellknown.ExpectedNetworksAnnotationKey]; ok {
+		t.Errorf("expected networks = %s after the allocation, want none", got)
+	}
+}
+
+func TestPublishExpectedNetworksDisabled(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "gke-pool-1234"},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/gke-pool-1234"},
+	}
+	clientSet := fake.NewSimpleClientset()
+	ca := &cloudCIDRAllocator{client: clientSet, params: DefaultCloudAllocatorParams()}
+	ca.publishExpectedNetworks(node)
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v with predictive allocation disabled, want none", actions)
+	}
+}
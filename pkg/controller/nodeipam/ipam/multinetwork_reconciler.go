@@ -0,0 +1,216 @@
+package ipam
+
+import (
+	"context"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// The multi-network state of a node is reconciled separately from its default
+// network pod CIDRs:
+//
+//   - updateCIDRAllocation fetches the node interfaces, computes the
+//     multiNetworkAllocation and the default network pod CIDRs, as it does
+//     when multi-networking is disabled.
+//   - reconcileMultiNetwork then publishes the pod CIDRs along with the
+//     additional networks of the allocation on the node, see
+//     wellknown.IPCapacityPendingAnnotationKey for the ordering of the updates.
+//   - If enabled, the node coordination Lease then records the published
+//     state for node agents, see NodeCoordinationLeaseName.
+//   - Changes of the Network and GKENetworkParamSet objects are reconciled by
+//     the multiNetworkReconciler, a controller-runtime Reconciler reading them
+//     from the typed cache of its manager, whose predicates decide which
+//     changes require the attached nodes to be reconciled again. The nodes
+//     themselves are still updated by the work queue of the allocator.
+
+// reconcileMultiNetwork publishes the pod CIDRs, if not nil, and the additional
+// networks of the allocation on the node. Nodes detached from all their
+// additional networks still need their stale annotations and IP capacity
+// cleared.
+func (ca *cloudCIDRAllocator) reconcileMultiNetwork(node *v1.Node, podCIDRs []string, allocation multiNetworkAllocation) error {
+	if err := ca.publishMultiNetwork(node, podCIDRs, allocation); err != nil {
+		return err
+	}
+	return ca.publishNodeCoordinationLease(node, allocation)
+}
+
+func (ca *cloudCIDRAllocator) publishMultiNetwork(node *v1.Node, podCIDRs []string, allocation multiNetworkAllocation) error {
+	if ca.params.NodeLocalIPAM {
+		if _, ok := node.Annotations[wellknown.DelegatedRangesAnnotationKey]; ok || allocation.NorthInterfaces != nil || allocation.DelegatedRanges != nil {
+			return ca.updateDelegatedRangesAnnotations(node, podCIDRs, allocation.NorthInterfaces, allocation.DelegatedRanges)
+		}
+		return ca.publishNodeUpdate(node, nodeUpdate{PodCIDRs: podCIDRs})
+	}
+	if allocation.NorthInterfaces != nil || allocation.AdditionalNodeNetworks != nil || hasMultiNetworkAnnotations(node) {
+		return ca.updateMultiNetworkAnnotations(node, podCIDRs, allocation.NorthInterfaces, allocation.AdditionalNodeNetworks)
+	}
+	return ca.publishNodeUpdate(node, nodeUpdate{PodCIDRs: podCIDRs})
+}
+
+// multiNetworkScheme holds the types of the typed cache of the
+// multiNetworkReconciler.
+var multiNetworkScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(networkv1.AddToScheme(multiNetworkScheme))
+	utilruntime.Must(networkv1alpha1.AddToScheme(multiNetworkScheme))
+}
+
+// multiNetworkReconciler reconciles the Networks: it requeues the nodes
+// attached to a Network when the Network, or its GKENetworkParamSet, changed,
+// so that the annotations and IP capacity of the nodes are recomputed without
+// waiting for a node update.
+type multiNetworkReconciler struct {
+	ca *cloudCIDRAllocator
+	// reader reads the Networks from the typed cache of the manager.
+	reader client.Reader
+
+	lock sync.Mutex
+	// reconciled holds the Networks created after the allocator that were
+	// reconciled, see Reconcile.
+	reconciled sets.String
+}
+
+var _ reconcile.Reconciler = &multiNetworkReconciler{}
+
+func newMultiNetworkReconciler(ca *cloudCIDRAllocator) *multiNetworkReconciler {
+	return &multiNetworkReconciler{ca: ca, reconciled: sets.NewString()}
+}
+
+// SetupWithManager registers the reconciler with the manager, watching the
+// Networks and the GKENetworkParamSets they reference.
+func (r *multiNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.reader = mgr.GetCache()
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("multinetwork").
+		For(&networkv1.Network{}, builder.WithPredicates(r.ca.networkPredicate())).
+		Watches(&source.Kind{Type: &networkv1alpha1.GKENetworkParamSet{}},
+			handler.EnqueueRequestsFromMapFunc(r.gnpNetworkRequests),
+			builder.WithPredicates(gnpPredicate())).
+		Complete(r)
+}
+
+// Reconcile requeues the nodes of the Network, and the nodes parked on a
+// terminal error about it. All the nodes are requeued the first time a Network
+// created after the allocator is reconciled, as any of them may have an
+// interface in it.
+func (r *multiNetworkReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	network := &networkv1.Network{}
+	err := r.reader.Get(ctx, req.NamespacedName, network)
+	switch {
+	case apierrors.IsNotFound(err):
+		r.forget(req.Name)
+		r.ca.requeueNetworkNodes(req.Name)
+	case err != nil:
+		return reconcile.Result{}, err
+	case r.firstReconcile(network):
+		r.ca.requeueNewNetworkNodes(req.Name)
+	default:
+		r.ca.requeueNetworkNodes(req.Name)
+	}
+	r.ca.requeueParkedNetworkNodes(req.Name)
+	return reconcile.Result{}, nil
+}
+
+// firstReconcile records the reconcile of a Network created after the
+// allocator and returns true if it is the first one.
+func (r *multiNetworkReconciler) firstReconcile(network *networkv1.Network) bool {
+	if network.CreationTimestamp.Time.Before(r.ca.started) {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.reconciled.Has(network.Name) {
+		return false
+	}
+	r.reconciled.Insert(network.Name)
+	return true
+}
+
+func (r *multiNetworkReconciler) forget(networkName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reconciled.Delete(networkName)
+}
+
+// gnpNetworkRequests returns the requests of the Networks referencing the
+// GKENetworkParamSet.
+func (r *multiNetworkReconciler) gnpNetworkRequests(gnp client.Object) []reconcile.Request {
+	networks := &networkv1.NetworkList{}
+	if err := r.reader.List(context.TODO(), networks); err != nil {
+		klog.ErrorS(err, "Failed to list networks referencing the GKENetworkParamSet", "gkeNetworkParamSet", gnp.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, network := range networks.Items {
+		if network.Spec.ParametersRef != nil && network.Spec.ParametersRef.Name == gnp.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: network.Name}})
+		}
+	}
+	return requests
+}
+
+// updatePredicate returns the predicate passing the updates of the objects of
+// type T for which changed returns true, and all the other events.
+func updatePredicate[T client.Object](changed func(oldObj, newObj T) bool) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldObj, ok := e.ObjectOld.(T)
+			if !ok {
+				return false
+			}
+			newObj, ok := e.ObjectNew.(T)
+			return ok && changed(oldObj, newObj)
+		},
+	}
+}
+
+// runMultiNetworkController runs the multiNetworkReconciler until stopCh is
+// closed or its manager fails. It returns at once if the multi-network CRDs are
+// not installed, as the cache of the manager could not sync. A manager cannot
+// be started again, so every run has its own.
+func (ca *cloudCIDRAllocator) runMultiNetworkController(stopCh <-chan struct{}) {
+	if !ca.multiNetworkEnabled() {
+		return
+	}
+	mgr, err := ctrl.NewManager(ca.params.NetworkConfig, ctrl.Options{
+		Scheme: multiNetworkScheme,
+		// The metrics of the allocator are registered with the controller
+		// manager.
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+		Logger:                 klog.NewKlogr().WithName("multinetwork"),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Failed to create the multi-network controller manager")
+		return
+	}
+	if err := ca.multiNetwork.SetupWithManager(mgr); err != nil {
+		klog.ErrorS(err, "Failed to set up the multi-network reconciler")
+		return
+	}
+	ctx, cancel := wait.ContextForChannel(stopCh)
+	defer cancel()
+	if err := mgr.Start(ctx); err != nil {
+		klog.ErrorS(err, "Multi-network controller manager failed")
+	}
+}
@@ -0,0 +1,145 @@
+package ipam
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newTestMultiNetworkReconciler returns the reconciler of the allocator,
+// reading the objects from a fake cache.
+func newTestMultiNetworkReconciler(ca *cloudCIDRAllocator, objs ...client.Object) *multiNetworkReconciler {
+	r := newMultiNetworkReconciler(ca)
+	r.reader = fakeclient.NewClientBuilder().WithScheme(multiNetworkScheme).WithObjects(objs...).Build()
+	ca.multiNetwork = r
+	return r
+}
+
+// The helpers below pass the events through the predicates and reconcile the
+// Networks they map to, as the controller of the reconciler does.
+
+func reconcileRequests(t *testing.T, r *multiNetworkReconciler, requests []reconcile.Request) {
+	t.Helper()
+	for _, req := range requests {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile(%v) = %v", req, err)
+		}
+	}
+}
+
+func networkRequest(name string) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
+
+func networkCreated(t *testing.T, r *multiNetworkReconciler, network *networkv1.Network) {
+	if r.ca.networkPredicate().Create(event.CreateEvent{Object: network}) {
+		reconcileRequests(t, r, networkRequest(network.Name))
+	}
+}
+
+func networkUpdated(t *testing.T, r *multiNetworkReconciler, oldNetwork, newNetwork *networkv1.Network) {
+	if r.ca.networkPredicate().Update(event.UpdateEvent{ObjectOld: oldNetwork, ObjectNew: newNetwork}) {
+		reconcileRequests(t, r, networkRequest(newNetwork.Name))
+	}
+}
+
+func networkDeleted(t *testing.T, r *multiNetworkReconciler, network *networkv1.Network) {
+	if r.ca.networkPredicate().Delete(event.DeleteEvent{Object: network}) {
+		reconcileRequests(t, r, networkRequest(network.Name))
+	}
+}
+
+func gnpCreated(t *testing.T, r *multiNetworkReconciler, gnp *networkv1alpha1.GKENetworkParamSet) {
+	if gnpPredicate().Create(event.CreateEvent{Object: gnp}) {
+		reconcileRequests(t, r, r.gnpNetworkRequests(gnp))
+	}
+}
+
+func gnpUpdated(t *testing.T, r *multiNetworkReconciler, oldGNP, newGNP *networkv1alpha1.GKENetworkParamSet) {
+	if gnpPredicate().Update(event.UpdateEvent{ObjectOld: oldGNP, ObjectNew: newGNP}) {
+		reconcileRequests(t, r, r.gnpNetworkRequests(newGNP))
+	}
+}
+
+func TestUpdatePredicate(t *testing.T) {
+	red := network(redNetworkName, redGKENetworkParamsName)
+	blue := network(blueNetworkName, blueGKENetworkParamsName)
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+
+	p := updatePredicate(func(oldNetwork, newNetwork *networkv1.Network) bool {
+		return oldNetwork.Name != newNetwork.Name
+	})
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: red, ObjectNew: red}))
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: red, ObjectNew: blue}))
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: node, ObjectNew: blue}))
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: red, ObjectNew: node}))
+	assert.True(t, p.Create(event.CreateEvent{Object: red}))
+	assert.True(t, p.Delete(event.DeleteEvent{Object: red}))
+}
+
+func TestMultiNetworkReconcilerNewNetwork(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	}
+	started := time.Now()
+	blue := network(blueNetworkName, blueGKENetworkParamsName)
+	blue.CreationTimestamp = metav1.NewTime(started.Add(time.Second))
+
+	nodeInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	for _, node := range nodes {
+		if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+			t.Fatalf("error in test setup, could not add node %s: %v", node.Name, err)
+		}
+	}
+	nwInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+	if err := nwInformer.Informer().GetStore().Add(blue); err != nil {
+		t.Fatalf("error in test setup, could not add network: %v", err)
+	}
+	ca := &cloudCIDRAllocator{
+		nodeLister:     nodeInformer.Lister(),
+		networksLister: nwInformer.Lister(),
+		started:        started,
+	}
+	r := newTestMultiNetworkReconciler(ca, blue)
+	requeued := func() []string {
+		var got []string
+		for len(ca.queue.Updates()) > 0 {
+			got = append(got, <-ca.queue.Updates())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	// All the nodes are requeued the first time the new Network is
+	// reconciled, only its nodes afterwards, until it is deleted.
+	withQueue(ca)
+	reconcileRequests(t, r, networkRequest(blueNetworkName))
+	assert.Equal(t, []string{"node0", "node1"}, requeued())
+	withQueue(ca)
+	reconcileRequests(t, r, networkRequest(blueNetworkName))
+	assert.Empty(t, requeued())
+
+	r.reader = fakeclient.NewClientBuilder().WithScheme(multiNetworkScheme).Build()
+	reconcileRequests(t, r, networkRequest(blueNetworkName))
+	r.reader = fakeclient.NewClientBuilder().WithScheme(multiNetworkScheme).WithObjects(blue).Build()
+	withQueue(ca)
+	reconcileRequests(t, r, networkRequest(blueNetworkName))
+	assert.Equal(t, []string{"node0", "node1"}, requeued())
+}
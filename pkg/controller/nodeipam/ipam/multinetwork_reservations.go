@@ -0,0 +1,155 @@
+package ipam
+
+import (
+	"encoding/json"
+	"net"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// The reservations of wellknown.InterfaceReservationsAnnotationKey are a JSON
+// encoded []InterfaceReservation. Reservations are requested by adding entries
+// without a CIDR and released by removing them. The controller assigns free
+// addresses of the network range of the node to the requests, and drops the
+// reservations of networks the node is no longer attached to.
+
+// InterfaceReservation is an address of the range of an additional network on
+// a node reserved to a pod.
+type InterfaceReservation struct {
+	// Network is the name of the additional network.
+	Network string `json:"network"`
+	// Pod is the namespace/name of the pod the address is reserved to.
+	Pod string `json:"pod"`
+	// CIDR is the reserved address, as a single address CIDR. It is empty until
+	// the controller assigns it.
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// interfaceReservations returns the reservation ledger of the node.
+func interfaceReservations(node *v1.Node) ([]InterfaceReservation, bool, error) {
+	value, ok := node.Annotations[wellknown.InterfaceReservationsAnnotationKey]
+	if !ok {
+		return nil, false, nil
+	}
+	var reservations []InterfaceReservation
+	if err := json.Unmarshal([]byte(value), &reservations); err != nil {
+		return nil, true, allocationErrorf(ErrParamsInvalid, "invalid %s annotation: %w", wellknown.InterfaceReservationsAnnotationKey, err)
+	}
+	return reservations, true, nil
+}
+
+// hasPendingInterfaceReservations returns true if the ledger of the node has
+// requests without an address.
+func hasPendingInterfaceReservations(node *v1.Node) bool {
+	reservations, _, err := interfaceReservations(node)
+	if err != nil {
+		return false
+	}
+	for _, r := range reservations {
+		if r.CIDR == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileInterfaceReservations returns the reservation ledger of the node
+// updated for its additional networks and whether it changed. Reservations
+// that are still in the range of their network are kept; others are assigned
+// a free address of the range, in ledger order. Requests that cannot be
+// satisfied stay pending.
+func (ca *cloudCIDRAllocator) reconcileInterfaceReservations(node *v1.Node, nodeNetworks networkv1.MultiNetworkAnnotation) (string, bool, error) {
+	reservations, ok, err := interfaceReservations(node)
+	if !ok {
+		return "", false, nil
+	}
+	if err != nil {
+		klog.ErrorS(err, "Ignoring the interface reservations of the node", "nodeName", node.Name)
+		return "", false, nil
+	}
+	ranges := make(map[string]*net.IPNet)
+	for _, nw := range nodeNetworks {
+		if len(nw.Cidrs) == 0 {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(nw.Cidrs[0]); err == nil {
+			ranges[nw.Name] = ipNet
+		}
+	}
+
+	changed := false
+	kept := make([]InterfaceReservation, 0, len(reservations))
+	reserved := make(map[string]bool)
+	for _, r := range reservations {
+		ipNet, ok := ranges[r.Network]
+		if !ok {
+			klog.V(2).InfoS("Releasing the interface reservation of a detached network", "nodeName", node.Name, "network", r.Network, "pod", r.Pod)
+			changed = true
+			continue
+		}
+		if r.CIDR != "" {
+			if ip, _, err := net.ParseCIDR(r.CIDR); err != nil || !ipNet.Contains(ip) || reserved[ip.String()] {
+				r.CIDR = ""
+				changed = true
+			} else {
+				reserved[ip.String()] = true
+			}
+		}
+		kept = append(kept, r)
+	}
+
+	var pending []string
+	for i := range kept {
+		if kept[i].CIDR != "" {
+			continue
+		}
+		ip := nextFreeAddress(ranges[kept[i].Network], reserved)
+		if ip == nil {
+			pending = append(pending, kept[i].Pod)
+			continue
+		}
+		reserved[ip.String()] = true
+		kept[i].CIDR = singleAddressCIDR(ip)
+		changed = true
+	}
+	if len(pending) > 0 {
+		ca.recorder.Eventf(node, v1.EventTypeWarning, wellknown.InterfaceReservationsExhaustedReason, "No free address to reserve to pods %v", pending)
+	}
+	if !changed {
+		return node.Annotations[wellknown.InterfaceReservationsAnnotationKey], false, nil
+	}
+	value, err := json.Marshal(kept)
+	if err != nil {
+		return "", false, err
+	}
+	return string(value), true, nil
+}
+
+// nextFreeAddress returns the first address of the range that is not reserved,
+// or nil if all of them are.
+func nextFreeAddress(ipNet *net.IPNet, reserved map[string]bool) net.IP {
+	size := netutils.RangeSize(ipNet)
+	for i := int64(0); i < size; i++ {
+		ip, err := netutils.GetIndexedIP(ipNet, int(i))
+		if err != nil {
+			return nil
+		}
+		if !reserved[ip.String()] {
+			return ip
+		}
+	}
+	return nil
+}
+
+// singleAddressCIDR returns the CIDR holding only ip.
+func singleAddressCIDR(ip net.IP) string {
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		bits = 8 * net.IPv4len
+	}
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String()
+}
@@ -0,0 +1,135 @@
+package ipam
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestReconcileInterfaceReservations(t *testing.T) {
+	nodeNetworks := networkv1.MultiNetworkAnnotation{
+		{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/30"}},
+		{Name: "blue", Scope: "host-local", Cidrs: []string{"fd00::/126"}},
+	}
+	testCases := []struct {
+		desc         string
+		reservations []InterfaceReservation
+		want         []InterfaceReservation
+		wantChanged  bool
+		wantEvent    bool
+	}{
+		{
+			desc: "no ledger",
+		},
+		{
+			desc: "assigned reservations are kept",
+			reservations: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.2/32"},
+			},
+			want: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.2/32"},
+			},
+		},
+		{
+			desc: "requests get the first free addresses",
+			reservations: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.0/32"},
+				{Network: "red", Pod: "ns/b"},
+				{Network: "blue", Pod: "ns/c"},
+			},
+			want: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.0/32"},
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.1/32"},
+				{Network: "blue", Pod: "ns/c", CIDR: "fd00::/128"},
+			},
+			wantChanged: true,
+		},
+		{
+			desc: "reservations of detached networks are released",
+			reservations: []InterfaceReservation{
+				{Network: "green", Pod: "ns/a", CIDR: "10.0.0.1/32"},
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.1/32"},
+			},
+			want: []InterfaceReservation{
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.1/32"},
+			},
+			wantChanged: true,
+		},
+		{
+			desc: "reservations out of the range or duplicated are reassigned",
+			reservations: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.17.0.0/32"},
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.0/32"},
+				{Network: "red", Pod: "ns/c", CIDR: "172.16.0.0/32"},
+			},
+			want: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.1/32"},
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.0/32"},
+				{Network: "red", Pod: "ns/c", CIDR: "172.16.0.2/32"},
+			},
+			wantChanged: true,
+		},
+		{
+			desc: "requests stay pending when the range is exhausted",
+			reservations: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a"},
+				{Network: "red", Pod: "ns/b"},
+				{Network: "red", Pod: "ns/c"},
+				{Network: "red", Pod: "ns/d"},
+				{Network: "red", Pod: "ns/e"},
+			},
+			want: []InterfaceReservation{
+				{Network: "red", Pod: "ns/a", CIDR: "172.16.0.0/32"},
+				{Network: "red", Pod: "ns/b", CIDR: "172.16.0.1/32"},
+				{Network: "red", Pod: "ns/c", CIDR: "172.16.0.2/32"},
+				{Network: "red", Pod: "ns/d", CIDR: "172.16.0.3/32"},
+				{Network: "red", Pod: "ns/e"},
+			},
+			wantChanged: true,
+			wantEvent:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+			if tc.reservations != nil {
+				value, err := json.Marshal(tc.reservations)
+				if err != nil {
+					t.Fatal(err)
+				}
+				node.Annotations = map[string]string{wellknown.InterfaceReservationsAnnotationKey: string(value)}
+			}
+			recorder := record.NewFakeRecorder(10)
+			ca := &cloudCIDRAllocator{recorder: recorder}
+
+			value, changed, err := ca.reconcileInterfaceReservations(node, nodeNetworks)
+			if err != nil {
+				t.Fatalf("reconcileInterfaceReservations() returned err %v", err)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("reconcileInterfaceReservations() changed = %v, want %v", changed, tc.wantChanged)
+			}
+			if tc.reservations != nil {
+				var got []InterfaceReservation
+				if err := json.Unmarshal([]byte(value), &got); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("reconcileInterfaceReservations() unexpected ledger (-want +got):\n%s", diff)
+				}
+			}
+			if gotEvent := len(recorder.Events) > 0; gotEvent != tc.wantEvent {
+				t.Errorf("recorded event = %v, want %v", gotEvent, tc.wantEvent)
+			}
+			if got := hasPendingInterfaceReservations(&v1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{wellknown.InterfaceReservationsAnnotationKey: value}}}); got != tc.wantEvent {
+				t.Errorf("hasPendingInterfaceReservations() = %v, want %v", got, tc.wantEvent)
+			}
+		})
+	}
+}
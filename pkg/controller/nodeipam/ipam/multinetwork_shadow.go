@@ -0,0 +1,192 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// IndexedMultiNetworkAllocator resolves the GKENetworkParamSet of every
+	// Network once per node instead of once per interface.
+	IndexedMultiNetworkAllocator = "indexed"
+)
+
+// multiNetworkAllocation is the output of a multi-network allocation algorithm.
+type multiNetworkAllocation struct {
+	DefaultNwCIDRs         []string
+	NorthInterfaces        networkv1.NorthInterfacesAnnotation
+	AdditionalNodeNetworks networkv1.MultiNetworkAnnotation
+	DelegatedRanges        DelegatedRangesAnnotation
+}
+
+type multiNetworkAllocatorFunc func(ca *cloudCIDRAllocator, node *v1.Node, interfaces []*compute.NetworkInterface) (multiNetworkAllocation, error)
+
+// shadowMultiNetworkAllocators are the allocation algorithms that can be run
+// in shadow mode. Candidates for replacing PerformMultiNetworkCIDRAllocation
+// are registered here so they can be canaried before rolling them out.
+var shadowMultiNetworkAllocators = map[string]multiNetworkAllocatorFunc{
+	IndexedMultiNetworkAllocator: (*cloudCIDRAllocator).indexedMultiNetworkCIDRAllocation,
+}
+
+// validateShadowAllocator returns an error if the shadow allocator is not registered.
+func validateShadowAllocator(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := shadowMultiNetworkAllocators[name]; !ok {
+		return fmt.Errorf("unknown shadow multi-network allocator %q", name)
+	}
+	return nil
+}
+
+// runShadowAllocation computes the allocation of the node with the shadow
+// allocator and records whether it matches the active allocation. The shadow
+// result is never written to the node.
+func (ca *cloudCIDRAllocator) runShadowAllocation(node *v1.Node, interfaces []*compute.NetworkInterface, active multiNetworkAllocation, activeErr error) {
+	name := ca.params.ShadowAllocator
+	allocate, ok := shadowMultiNetworkAllocators[name]
+	if !ok {
+		return
+	}
+	shadow, err := allocate(ca, node, interfaces)
+	switch {
+	case err != nil && activeErr != nil:
+		shadowAllocations.WithLabelValues(name, "match").Inc()
+	case err != nil || activeErr != nil:
+		klog.V(2).InfoS("Shadow multi-network allocation error mismatch", "nodeName", node.Name, "allocator", name, "err", err, "activeErr", activeErr)
+		shadowAllocations.WithLabelValues(name, "error").Inc()
+	default:
+		if diff := cmp.Diff(active.normalized(), shadow.normalized()); diff != "" {
+			klog.V(2).InfoS("Shadow multi-network allocation mismatch", "nodeName", node.Name, "allocator", name, "diff", diff)
+			shadowAllocations.WithLabelValues(name, "mismatch").Inc()
+			return
+		}
+		shadowAllocations.WithLabelValues(name, "match").Inc()
+	}
+}
+
+// normalized returns a copy of the allocation with the per-network lists
+// sorted, as the order of the Networks returned by the lister is not stable.
+func (a multiNetworkAllocation) normalized() multiNetworkAllocation {
+	n := multiNetworkAllocation{
+		DefaultNwCIDRs:         a.DefaultNwCIDRs,
+		NorthInterfaces:        append(networkv1.NorthInterfacesAnnotation(nil), a.NorthInterfaces...),
+		AdditionalNodeNetworks: append(networkv1.MultiNetworkAnnotation(nil), a.AdditionalNodeNetworks...),
+		DelegatedRanges:        append(DelegatedRangesAnnotation(nil), a.DelegatedRanges...),
+	}
+	sort.Slice(n.NorthInterfaces, func(i, j int) bool {
+		if n.NorthInterfaces[i].Network != n.NorthInterfaces[j].Network {
+			return n.NorthInterfaces[i].Network < n.NorthInterfaces[j].Network
+		}
+		return n.NorthInterfaces[i].IpAddress < n.NorthInterfaces[j].IpAddress
+	})
+	sort.Slice(n.AdditionalNodeNetworks, func(i, j int) bool {
+		return n.AdditionalNodeNetworks[i].Name < n.AdditionalNodeNetworks[j].Name
+	})
+	sort.Slice(n.DelegatedRanges, func(i, j int) bool {
+		if n.DelegatedRanges[i].Network != n.DelegatedRanges[j].Network {
+			return n.DelegatedRanges[i].Network < n.DelegatedRanges[j].Network
+		}
+		return n.DelegatedRanges[i].Interface < n.DelegatedRanges[j].Interface
+	})
+	return n
+}
+
+// indexedMultiNetworkCIDRAllocation is equivalent to PerformMultiNetworkCIDRAllocation
+// but resolves the GKENetworkParamSet of every Network once, before matching the interfaces.
+func (ca *cloudCIDRAllocator) indexedMultiNetworkCIDRAllocation(node *v1.Node, interfaces []*compute.NetworkInterface) (multiNetworkAllocation, error) {
+	var result multiNetworkAllocation
+	if len(interfaces) == 0 {
+		return result, nil
+	}
+	k8sNetworksList, err := ca.networksLister.List(labels.Everything())
+	if err != nil {
+		return result, fmt.Errorf("error fetching networks: %v", err)
+	}
+	type networkParams struct {
+		network *networkv1.Network
+		gnp     *networkv1alpha1.GKENetworkParamSet
+	}
+	active := ca.activeNetworks(k8sNetworksList)
+	urlDefaults := ca.urlDefaults()
+	active, _ = resolveNetworkConflicts(active, ca.isDefaultNetwork, ca.gnpLister, urlDefaults)
+	active = ca.unpausedNetworks(active)
+	var networks []networkParams
+	for _, network := range ca.nodeNetworks(node, active) {
+		gnp, err := ca.gnpLister.Get(network.Spec.ParametersRef.Name)
+		if err != nil {
+			return result, err
+		}
+		networks = append(networks, networkParams{network: network, gnp: gnp})
+	}
+	for _, inf := range interfaces {
+		rangeNameAliasIPMap := map[string]*compute.AliasIpRange{}
+		for _, ipRange := range inf.AliasIpRanges {
+			rangeNameAliasIPMap[ipRange.SubnetworkRangeName] = ipRange
+		}
+		for _, np := range networks {
+			network, gnp := np.network, np.gnp
+			if !interfaceInSubnet(inf, gnp, urlDefaults) {
+				continue
+			}
+			if !gkenetworkparamset.SubnetReady(gnp) || (!ca.isDefaultNetwork(network) && !ca.paramsReady(gnp)) {
+				continue
+			}
+			if !interfaceMatchesNicType(inf, gnp) {
+				continue
+			}
+			if ca.interfaceOnlyNetwork(network) {
+				result.NorthInterfaces = append(result.NorthInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				continue
+			}
+			if ca.externalIPAMEndpoint(network) != "" {
+				cidrs, err := ca.allocateExternalRanges(node, network, inf)
+				if err != nil {
+					return result, err
+				}
+				result.NorthInterfaces = append(result.NorthInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				result.AdditionalNodeNetworks = append(result.AdditionalNodeNetworks, networkv1.NodeNetwork{Name: network.Name, Scope: "host-local", Cidrs: cidrs})
+				continue
+			}
+			secondaryRangeNames := zonalRangeNames(gnp, nodeZone(node))
+			isDefault := ca.isDefaultNetwork(network)
+			if !isDefault && (len(secondaryRangeNames) == 0 || ca.params.NodeLocalIPAM) {
+				result.NorthInterfaces = append(result.NorthInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+				if len(secondaryRangeNames) > 0 {
+					result.DelegatedRanges = append(result.DelegatedRanges, DelegatedRange{Network: network.Name, Interface: inf.Name, Subnetwork: inf.Subnetwork, RangeNames: secondaryRangeNames, MaskSize: perNodeMaskSize(gnp)})
+				}
+				continue
+			}
+			for _, secondaryRangeName := range secondaryRangeNames {
+				ipRange, ok := rangeNameAliasIPMap[secondaryRangeName]
+				if !ok {
+					continue
+				}
+				if !isDefault && (!aliasMatchesMaskSize(ipRange.IpCidrRange, perNodeMaskSize(gnp)) || aliasRangeTooSmall(ipRange.IpCidrRange, ca.params.MaxAliasRangeMaskSize)) {
+					continue
+				}
+				if isDefault {
+					result.DefaultNwCIDRs = append(result.DefaultNwCIDRs, ipRange.IpCidrRange)
+					if ipv6Addr := gce.InterfaceIPv6PodCIDR(inf); ipv6Addr != nil {
+						result.DefaultNwCIDRs = append(result.DefaultNwCIDRs, ipv6Addr.String())
+					}
+				} else {
+					result.NorthInterfaces = append(result.NorthInterfaces, networkv1.NorthInterface{Network: network.Name, IpAddress: inf.NetworkIP})
+					result.AdditionalNodeNetworks = append(result.AdditionalNodeNetworks, networkv1.NodeNetwork{Name: network.Name, Scope: "host-local", Cidrs: []string{ipRange.IpCidrRange}})
+				}
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package ipam
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func shadowTestAllocator(t *testing.T, params CloudAllocatorParams) *cloudCIDRAllocator {
+	t.Helper()
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{
+		network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+		network(redNetworkName, redGKENetworkParamsName),
+		network(blueNetworkName, blueGKENetworkParamsName),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+		gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, nil),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	return &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		params:         params,
+	}
+}
+
+func shadowTestInterfaces() []*compute.NetworkInterface {
+	return []*compute.NetworkInterface{
+		interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{
+			{IpCidrRange: "10.11.1.0/24", SubnetworkRangeName: defaultSecondaryRangeA},
+		}),
+		interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+			{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeB},
+		}),
+		interfaces(blueVPCName, blueVPCSubnetName, "20.1.1.1", nil),
+	}
+}
+
+func TestIndexedMultiNetworkAllocatorMatchesActive(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	for _, params := range []CloudAllocatorParams{{}, {NodeLocalIPAM: true}} {
+		ca := shadowTestAllocator(t, params)
+		defaultNwCIDRs, northInterfaces, additionalNodeNetworks, delegatedRanges, err := ca.PerformMultiNetworkCIDRAllocation(node, shadowTestInterfaces())
+		if err != nil {
+			t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+		}
+		active := multiNetworkAllocation{
+			DefaultNwCIDRs:         defaultNwCIDRs,
+			NorthInterfaces:        northInterfaces,
+			AdditionalNodeNetworks: additionalNodeNetworks,
+			DelegatedRanges:        delegatedRanges,
+		}
+		indexed, err := ca.indexedMultiNetworkCIDRAllocation(node, shadowTestInterfaces())
+		if err != nil {
+			t.Fatalf("indexedMultiNetworkCIDRAllocation() returned err %v", err)
+		}
+		if diff := cmp.Diff(active.normalized(), indexed.normalized()); diff != "" {
+			t.Errorf("NodeLocalIPAM=%v: indexed allocation mismatch (-active +indexed):\n%s", params.NodeLocalIPAM, diff)
+		}
+	}
+}
+
+func TestRunShadowAllocation(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	active := multiNetworkAllocation{
+		DefaultNwCIDRs: []string{"10.11.1.0/24"},
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: redNetworkName, IpAddress: "10.1.1.1"},
+			{Network: blueNetworkName, IpAddress: "20.1.1.1"},
+		},
+	}
+	reordered := multiNetworkAllocation{
+		DefaultNwCIDRs: []string{"10.11.1.0/24"},
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: blueNetworkName, IpAddress: "20.1.1.1"},
+			{Network: redNetworkName, IpAddress: "10.1.1.1"},
+		},
+	}
+	testCases := []struct {
+		desc       string
+		shadow     multiNetworkAllocation
+		shadowErr  error
+		activeErr  error
+		wantResult string
+	}{
+		{desc: "same allocation in a different order", shadow: reordered, wantResult: "match"},
+		{desc: "different default network CIDR", shadow: multiNetworkAllocation{DefaultNwCIDRs: []string{"10.11.2.0/24"}}, wantResult: "mismatch"},
+		{desc: "only the shadow allocator fails", shadowErr: errors.New("shadow failed"), wantResult: "error"},
+		{desc: "both allocators fail", shadowErr: errors.New("shadow failed"), activeErr: errors.New("active failed"), wantResult: "match"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			name := "test-" + tc.wantResult
+			shadowMultiNetworkAllocators[name] = func(*cloudCIDRAllocator, *v1.Node, []*compute.NetworkInterface) (multiNetworkAllocation, error) {
+				return tc.shadow, tc.shadowErr
+			}
+			defer delete(shadowMultiNetworkAllocators, name)
+			counter := shadowAllocations.WithLabelValues(name, tc.wantResult)
+			before, err := testutil.GetCounterMetricValue(counter)
+			if err != nil {
+				t.Fatalf("GetCounterMetricValue() returned err %v", err)
+			}
+			ca := &cloudCIDRAllocator{params: CloudAllocatorParams{ShadowAllocator: name}}
+			ca.runShadowAllocation(node, nil, active, tc.activeErr)
+			after, err := testutil.GetCounterMetricValue(counter)
+			if err != nil {
+				t.Fatalf("GetCounterMetricValue() returned err %v", err)
+			}
+			if after-before != 1 {
+				t.Errorf("%s count increased by %v, want 1", tc.wantResult, after-before)
+			}
+		})
+	}
+}
+
+func TestValidateShadowAllocator(t *testing.T) {
+	for name, wantErr := range map[string]bool{"": false, IndexedMultiNetworkAllocator: false, "unknown": true} {
+		if err := validateShadowAllocator(name); (err != nil) != wantErr {
+			t.Errorf("validateShadowAllocator(%q) = %v, want error %v", name, err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package ipam
+
+import (
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+	"k8s.io/klog/v2"
+)
+
+// The hosts of a multi-host accelerator (TPU or GPU) slice, see
+// wellknown.SliceLabelKey, can share the ranges of their additional networks,
+// see dedupeSliceRanges.
+
+// sliceHostIndex returns the index of the node in its slice. Nodes with an
+// invalid index come after all the others.
+func sliceHostIndex(node *v1.Node) int {
+	index, err := strconv.Atoi(node.Labels[wellknown.SliceHostLabelKey])
+	if err != nil || index < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return index
+}
+
+// sliceHostBefore returns true if host a of a slice keeps the ranges it shares
+// with host b: the host with the lowest index, then the lowest name.
+func sliceHostBefore(a, b *v1.Node) bool {
+	if ia, ib := sliceHostIndex(a), sliceHostIndex(b); ia != ib {
+		return ia < ib
+	}
+	return a.Name < b.Name
+}
+
+// dedupeSliceRanges removes from the allocation of a host of a multi-host
+// accelerator slice the additional network ranges already published by a
+// preceding host of the slice, so that the same range is never advertised by
+// two nodes. The north interfaces of the networks are kept. Following hosts
+// publishing a range of the node are requeued to drop it.
+func (ca *cloudCIDRAllocator) dedupeSliceRanges(node *v1.Node, allocation multiNetworkAllocation) multiNetworkAllocation {
+	slice := node.Labels[wellknown.SliceLabelKey]
+	if slice == "" || len(allocation.AdditionalNodeNetworks) == 0 {
+		return allocation
+	}
+	hosts, err := ca.nodeLister.List(labels.SelectorFromSet(labels.Set{wellknown.SliceLabelKey: slice}))
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the hosts of the slice", "nodeName", node.Name, "slice", slice)
+		return allocation
+	}
+	sort.Slice(hosts, func(i, j int) bool { return sliceHostBefore(hosts[i], hosts[j]) })
+
+	ranges := make(map[string]bool)
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		for _, cidr := range nw.Cidrs {
+			ranges[cidr] = true
+		}
+	}
+	claimed := make(map[string]string)
+	for _, host := range hosts {
+		if host.Name == node.Name {
+			continue
+		}
+		nodeNetworks, ok, err := networkannotations.ParseNodeMultiNetwork(host.Annotations)
+		if err != nil || !ok {
+			continue
+		}
+		for _, nw := range nodeNetworks {
+			for _, cidr := range nw.Cidrs {
+				if !ranges[cidr] {
+					continue
+				}
+				if sliceHostBefore(host, node) {
+					claimed[cidr] = host.Name
+				} else {
+					// Queued asynchronously, as the channel may be full while a worker runs.
+					go ca.AllocateOrOccupyCIDR(host)
+				}
+			}
+		}
+	}
+	if len(claimed) == 0 {
+		return allocation
+	}
+
+	deduped := allocation
+	deduped.AdditionalNodeNetworks = nil
+	for _, nw := range allocation.AdditionalNodeNetworks {
+		var cidrs []string
+		for _, cidr := range nw.Cidrs {
+			if host, ok := claimed[cidr]; ok {
+				klog.Warningf("Range %s of network %s on node %s is allocated to host %s of slice %s, skipping it", cidr, nw.Name, node.Name, host, slice)
+				ca.recordNetworkEvent(nw.Name, node.Name, wellknown.DuplicateSliceRangeReason, "Ranges are allocated to another host of the slice")
+				continue
+			}
+			cidrs = append(cidrs, cidr)
+		}
+		if len(cidrs) > 0 {
+			nw.Cidrs = cidrs
+			deduped.AdditionalNodeNetworks = append(deduped.AdditionalNodeNetworks, nw)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,142 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func sliceHost(name, slice, index, networks string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Labels:      map[string]string{wellknown.SliceLabelKey: slice, wellknown.SliceHostLabelKey: index},
+		Annotations: map[string]string{},
+	}}
+	if networks != "" {
+		node.Annotations[networkv1.MultiNetworkAnnotationKey] = networks
+	}
+	return node
+}
+
+func TestDedupeSliceRanges(t *testing.T) {
+	allocation := multiNetworkAllocation{
+		DefaultNwCIDRs: []string{"10.0.0.0/24"},
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: "red", IpAddress: "10.1.0.2"},
+			{Network: "blue", IpAddress: "10.2.0.2"},
+		},
+		AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+			{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}},
+			{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+		},
+	}
+	const redRange = `[{"name":"red","cidrs":["172.16.0.0/24"],"scope":"host-local"}]`
+	testCases := []struct {
+		desc        string
+		node        *v1.Node
+		hosts       []*v1.Node
+		want        multiNetworkAllocation
+		wantEvents  int
+		wantRequeue []string
+	}{
+		{
+			desc: "node outside of a slice",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+			want: allocation,
+		},
+		{
+			desc:  "hosts without shared ranges",
+			node:  sliceHost("host-1", "slice-a", "1", ""),
+			hosts: []*v1.Node{sliceHost("host-0", "slice-a", "0", `[{"name":"red","cidrs":["172.20.0.0/24"],"scope":"host-local"}]`)},
+			want:  allocation,
+		},
+		{
+			desc:  "secondary host skips the range of the primary host",
+			node:  sliceHost("host-1", "slice-a", "1", ""),
+			hosts: []*v1.Node{sliceHost("host-0", "slice-a", "0", redRange)},
+			want: multiNetworkAllocation{
+				DefaultNwCIDRs:  allocation.DefaultNwCIDRs,
+				NorthInterfaces: allocation.NorthInterfaces,
+				AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{
+					{Name: "blue", Scope: "host-local", Cidrs: []string{"172.17.0.0/24"}},
+				},
+			},
+			wantEvents: 1,
+		},
+		{
+			desc:  "hosts of other slices are ignored",
+			node:  sliceHost("host-1", "slice-a", "1", ""),
+			hosts: []*v1.Node{sliceHost("host-0", "slice-b", "0", redRange)},
+			want:  allocation,
+		},
+		{
+			desc:        "primary host keeps its range and requeues the secondary host",
+			node:        sliceHost("host-0", "slice-a", "0", ""),
+			hosts:       []*v1.Node{sliceHost("host-1", "slice-a", "1", redRange)},
+			want:        allocation,
+			wantRequeue: []string{"host-1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Hour).Core().V1().Nodes()
+			for _, node := range append([]*v1.Node{tc.node}, tc.hosts...) {
+				if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+					t.Fatalf("error in test setup, could not add node %s: %v", node.Name, err)
+				}
+			}
+			recorder := record.NewFakeRecorder(10)
+			ca := withQueue(&cloudCIDRAllocator{
+				nodeLister: nodeInformer.Lister(),
+				recorder:   recorder,
+			})
+			got := ca.dedupeSliceRanges(tc.node, allocation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("dedupeSliceRanges() returned unexpected allocation (-want +got):\n%s", diff)
+			}
+			ca.flushNetworkEvents()
+			if got := len(recorder.Events); got != tc.wantEvents {
+				t.Errorf("recorded %d events, want %d", got, tc.wantEvents)
+			}
+			var requeued []string
+			for range tc.wantRequeue {
+				select {
+				case name := <-ca.queue.Updates():
+					requeued = append(requeued, name)
+				case <-time.After(5 * time.Second):
+				}
+			}
+			if diff := cmp.Diff(tc.wantRequeue, requeued); diff != "" {
+				t.Errorf("requeued unexpected hosts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSliceHostBefore(t *testing.T) {
+	testCases := []struct {
+		desc string
+		a, b *v1.Node
+		want bool
+	}{
+		{desc: "lower index", a: sliceHost("b", "s", "0", ""), b: sliceHost("a", "s", "1", ""), want: true},
+		{desc: "numeric order", a: sliceHost("a", "s", "10", ""), b: sliceHost("b", "s", "9", "")},
+		{desc: "same index", a: sliceHost("a", "s", "1", ""), b: sliceHost("b", "s", "1", ""), want: true},
+		{desc: "invalid index last", a: sliceHost("a", "s", "x", ""), b: sliceHost("b", "s", "3", "")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := sliceHostBefore(tc.a, tc.b); got != tc.want {
+				t.Errorf("sliceHostBefore(%s, %s) = %v, want %v", tc.a.Name, tc.b.Name, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+)
+
+// validateMaxAliasRangeMaskSize returns an error if the mask size is not an
+// IPv4 prefix length.
+func validateMaxAliasRangeMaskSize(maskSize int) error {
+	if maskSize < 0 || maskSize > 32 {
+		return fmt.Errorf("invalid maximum alias IP range mask size %d, it must be an IPv4 prefix length", maskSize)
+	}
+	return nil
+}
+
+// aliasRangeTooSmall returns true if the prefix of the IPv4 alias IP range is
+// longer than maxMaskSize, i.e. the range has fewer IPs than a range of that
+// mask size. No range is too small if maxMaskSize is 0.
+func aliasRangeTooSmall(ipCidrRange string, maxMaskSize int) bool {
+	if maxMaskSize == 0 {
+		return false
+	}
+	_, ipNet, err := net.ParseCIDR(ipCidrRange)
+	if err != nil {
+		return false
+	}
+	ones, bits := ipNet.Mask.Size()
+	return bits == 32 && ones > maxMaskSize
+}
@@ -0,0 +1,121 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestAliasRangeTooSmall(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		maxMaskSize int
+		cidr        string
+		want        bool
+	}{
+		{desc: "no maximum mask size", cidr: "172.11.1.0/30"},
+		{desc: "larger range", maxMaskSize: 29, cidr: "172.11.1.0/24"},
+		{desc: "range of the maximum mask size", maxMaskSize: 29, cidr: "172.11.1.8/29"},
+		{desc: "smaller range", maxMaskSize: 29, cidr: "172.11.1.4/30", want: true},
+		{desc: "single IP", maxMaskSize: 29, cidr: "172.11.1.1/32", want: true},
+		{desc: "IPv6 range", maxMaskSize: 29, cidr: "fd00::/112"},
+		{desc: "invalid cidr", maxMaskSize: 29, cidr: "172.11.1.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := aliasRangeTooSmall(tc.cidr, tc.maxMaskSize); got != tc.want {
+				t.Errorf("aliasRangeTooSmall(%q, %d) = %v, want %v", tc.cidr, tc.maxMaskSize, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateMaxAliasRangeMaskSize(t *testing.T) {
+	for maskSize, wantErr := range map[int]bool{-1: true, 0: false, 29: false, 32: false, 33: true} {
+		if err := validateMaxAliasRangeMaskSize(maskSize); (err != nil) != wantErr {
+			t.Errorf("validateMaxAliasRangeMaskSize(%d) returned err %v, want error %v", maskSize, err, wantErr)
+		}
+	}
+}
+
+func TestPerformMultiNetworkCIDRAllocationSmallRanges(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	for _, nw := range []*networkv1.Network{
+		network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+		network(redNetworkName, redGKENetworkParamsName),
+		network(blueNetworkName, blueGKENetworkParamsName),
+	} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA}),
+		gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, []string{blueSecondaryRangeA}),
+	} {
+		if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+	recorder := record.NewFakeRecorder(10)
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		recorder:       recorder,
+		params:         CloudAllocatorParams{MaxAliasRangeMaskSize: 29},
+	}
+	infs := []*compute.NetworkInterface{
+		// The default network is not subject to the maximum mask size.
+		interfaces(defaultVPCName, defaultVPCSubnetName, "80.1.172.1", []*compute.AliasIpRange{{IpCidrRange: "10.11.1.0/30", SubnetworkRangeName: defaultSecondaryRangeA}}),
+		interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.11.1.8/29", SubnetworkRangeName: redSecondaryRangeA}}),
+		interfaces(blueVPCName, blueVPCSubnetName, "10.2.1.1", []*compute.AliasIpRange{{IpCidrRange: "172.12.1.4/30", SubnetworkRangeName: blueSecondaryRangeA}}),
+	}
+	skipped := skippedAliasRanges.WithLabelValues(blueNetworkName)
+	before, _ := testutil.GetCounterMetricValue(skipped)
+
+	defaultNwCIDRs, _, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	if diff := cmp.Diff([]string{"10.11.1.0/30"}, defaultNwCIDRs); diff != "" {
+		t.Errorf("default network CIDRs mismatch (-want +got):\n%s", diff)
+	}
+	want := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.8/29"}}}
+	if diff := cmp.Diff(want, additionalNodeNetworks); diff != "" {
+		t.Errorf("additional node networks mismatch (-want +got):\n%s", diff)
+	}
+	if after, _ := testutil.GetCounterMetricValue(skipped); after-before != 1 {
+		t.Errorf("skipped alias ranges of %s increased by %v, want 1", blueNetworkName, after-before)
+	}
+	ca.flushNetworkEvents()
+	if got := len(recorder.Events); got != 1 {
+		t.Fatalf("got %d events, want 1 for the small alias IP range", got)
+	}
+	if event := <-recorder.Events; event != v1.EventTypeWarning+" "+wellknown.AliasRangeTooSmallReason+" Alias IP ranges of secondary range "+blueSecondaryRangeA+" are smaller than a /29 range, their pod IPs are not published (node "+node.Name+")" {
+		t.Errorf("got event %q", event)
+	}
+
+	shadow, err := ca.indexedMultiNetworkCIDRAllocation(node, infs)
+	if err != nil {
+		t.Fatalf("indexedMultiNetworkCIDRAllocation() returned err %v", err)
+	}
+	if diff := cmp.Diff(want, shadow.AdditionalNodeNetworks); diff != "" {
+		t.Errorf("indexed additional node networks mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,15 @@
+package ipam
+
+import (
+	compute "google.golang.org/api/compute/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+)
+
+// interfaceInSubnet returns true if the interface is in the VPC and the subnet
+// of the GKENetworkParamSet. Several Networks may share a VPC and only differ
+// by subnet, so interfaces are always matched against the (VPC, subnet) pair,
+// never against the VPC alone.
+func interfaceInSubnet(inf *compute.NetworkInterface, gnp *networkv1alpha1.GKENetworkParamSet, defaults gcpurl.Defaults) bool {
+	return gcpurl.Matches(inf.Network, gnp.Spec.VPC, gcpurl.KindNetworks, defaults) && gcpurl.Matches(inf.Subnetwork, gnp.Spec.VPCSubnet, gcpurl.KindSubnetworks, defaults)
+}
@@ -0,0 +1,61 @@
+package ipam
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// The traffic class of wellknown.TrafficClassAnnotationKey is published, with
+// its DSCP value, along with the network in the networks annotation of the
+// nodes, so that dataplane agents can mark the traffic of each network. The
+// traffic is not marked if it is not set.
+
+// dscpClasses are the DSCP values of the allowed traffic classes: the class
+// selectors (RFC 2474), the assured forwarding (RFC 2597) and the expedited
+// forwarding (RFC 3246) per-hop behaviors.
+var dscpClasses = map[string]int{
+	"CS0": 0, "CS1": 8, "CS2": 16, "CS3": 24, "CS4": 32, "CS5": 40, "CS6": 48, "CS7": 56,
+	"AF11": 10, "AF12": 12, "AF13": 14,
+	"AF21": 18, "AF22": 20, "AF23": 22,
+	"AF31": 26, "AF32": 28, "AF33": 30,
+	"AF41": 34, "AF42": 36, "AF43": 38,
+	"EF": 46,
+}
+
+// parseTrafficClass returns the canonical name of the traffic class, which
+// is case insensitive.
+func parseTrafficClass(value string) (string, error) {
+	class := strings.ToUpper(strings.TrimSpace(value))
+	if _, ok := dscpClasses[class]; !ok {
+		return "", fmt.Errorf("unknown traffic class %q, it must be a class selector CS0-CS7, an assured forwarding class AF11-AF43 or EF", value)
+	}
+	return class, nil
+}
+
+// withTrafficClasses returns the additional networks of the node with their
+// traffic class and its DSCP value, if any. Networks with an invalid class
+// are reported and published without class.
+func (ca *cloudCIDRAllocator) withTrafficClasses(node *v1.Node, nodeNetworks networkv1.MultiNetworkAnnotation) networkv1.MultiNetworkAnnotation {
+	if nodeNetworks == nil {
+		return nil
+	}
+	ret := make(networkv1.MultiNetworkAnnotation, 0, len(nodeNetworks))
+	for _, nw := range nodeNetworks {
+		if network, err := ca.networksLister.Get(nw.Name); err == nil {
+			if value, ok := network.Annotations[wellknown.TrafficClassAnnotationKey]; ok {
+				if class, err := parseTrafficClass(value); err != nil {
+					ca.recordNetworkEvent(network.Name, node.Name, wellknown.InvalidTrafficClassReason, fmt.Sprintf("Ignoring the %s annotation: %v", wellknown.TrafficClassAnnotationKey, err))
+				} else {
+					dscp := dscpClasses[class]
+					nw.TrafficClass, nw.DSCP = class, &dscp
+				}
+			}
+		}
+		ret = append(ret, nw)
+	}
+	return ret
+}
@@ -0,0 +1,134 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func TestParseTrafficClass(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "EF", want: "EF"},
+		{value: "af41", want: "AF41"},
+		{value: " CS0 ", want: "CS0"},
+		{value: "AF44", wantErr: true},
+		{value: "46", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseTrafficClass(tc.value)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parseTrafficClass(%q) returned err %v, want error %t", tc.value, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("parseTrafficClass(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestWithTrafficClasses(t *testing.T) {
+	nodeNetworks := networkv1.MultiNetworkAnnotation{
+		{Name: redNetworkName, Cidrs: []string{"10.1.1.0/24"}, Scope: "host-local"},
+		{Name: blueNetworkName, Cidrs: []string{"10.2.1.0/24"}, Scope: "host-local"},
+	}
+	testCases := []struct {
+		desc        string
+		red         string
+		blue        string
+		wantAnn     string
+		wantInvalid bool
+	}{
+		{
+			desc:    "no traffic class",
+			wantAnn: `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local"},{"name":"Blue-Network","cidrs":["10.2.1.0/24"],"scope":"host-local"}]`,
+		},
+		{
+			desc:    "traffic classes",
+			red:     "ef",
+			blue:    "CS0",
+			wantAnn: `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local","trafficClass":"EF","dscp":46},{"name":"Blue-Network","cidrs":["10.2.1.0/24"],"scope":"host-local","trafficClass":"CS0","dscp":0}]`,
+		},
+		{
+			desc:        "invalid traffic class",
+			red:         "AF41",
+			blue:        "gold",
+			wantAnn:     `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local","trafficClass":"AF41","dscp":34},{"name":"Blue-Network","cidrs":["10.2.1.0/24"],"scope":"host-local"}]`,
+			wantInvalid: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			nwInformer := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+			for _, nw := range []struct{ name, gnp, class string }{
+				{redNetworkName, redGKENetworkParamsName, tc.red},
+				{blueNetworkName, blueGKENetworkParamsName, tc.blue},
+			} {
+				network := network(nw.name, nw.gnp)
+				if nw.class != "" {
+					network.Annotations = map[string]string{wellknown.TrafficClassAnnotationKey: nw.class}
+				}
+				if err := nwInformer.Informer().GetStore().Add(network); err != nil {
+					t.Fatalf("error in test setup, could not create network %s: %v", network.Name, err)
+				}
+			}
+			ca := &cloudCIDRAllocator{networksLister: nwInformer.Lister()}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}}
+
+			ann, err := networkv1.MarshalAnnotation(ca.withTrafficClasses(node, nodeNetworks))
+			if err != nil {
+				t.Fatalf("MarshalAnnotation() returned err %v", err)
+			}
+			if ann != tc.wantAnn {
+				t.Errorf("networks annotation = %s, want %s", ann, tc.wantAnn)
+			}
+			_, gotInvalid := ca.networkEvents[networkEventKey{network: blueNetworkName, reason: wellknown.InvalidTrafficClassReason}]
+			if gotInvalid != tc.wantInvalid {
+				t.Errorf("got %s event %t, want %t", wellknown.InvalidTrafficClassReason, gotInvalid, tc.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestTrafficClassAnnotationCache(t *testing.T) {
+	nodeNetworks := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Cidrs: []string{"10.1.1.0/24"}, Scope: "host-local"}}
+	cache := &multiNetworkAnnotationCache{}
+	_, before, err := cache.marshal("node0", nil, nodeNetworks)
+	if err != nil {
+		t.Fatalf("marshal() returned err %v", err)
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: map[string]string{
+		networkv1.NorthInterfacesAnnotationKey: "null",
+		networkv1.MultiNetworkAnnotationKey:    before,
+	}}}
+	dscp := 46
+	classified := networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Cidrs: []string{"10.1.1.0/24"}, Scope: "host-local", TrafficClass: "EF", DSCP: &dscp}}
+	if cache.upToDate(node, nil, classified) {
+		t.Errorf("upToDate() = true after the traffic class of the network changed")
+	}
+	_, after, err := cache.marshal("node0", nil, classified)
+	if err != nil {
+		t.Fatalf("marshal() returned err %v", err)
+	}
+	if want := `[{"name":"Red-Network","cidrs":["10.1.1.0/24"],"scope":"host-local","trafficClass":"EF","dscp":46}]`; after != want {
+		t.Errorf("networks annotation = %s, want %s", after, want)
+	}
+}
+
+func TestNetworkChangedTrafficClass(t *testing.T) {
+	oldNetwork := network(redNetworkName, redGKENetworkParamsName)
+	newNetwork := network(redNetworkName, redGKENetworkParamsName)
+	newNetwork.Annotations = map[string]string{wellknown.TrafficClassAnnotationKey: "EF"}
+	if !networkChanged(oldNetwork, newNetwork) {
+		t.Errorf("networkChanged() = false after setting the traffic class, want true")
+	}
+}
@@ -0,0 +1,57 @@
+package ipam
+
+import (
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/gkenetworkparamset"
+)
+
+// zoneSuffixRE matches the names of secondary ranges dedicated to a zone by
+// naming convention, <name>-<zone>, e.g. pods-us-central1-a.
+var zoneSuffixRE = regexp.MustCompile(`-([a-z]+-[a-z]+[0-9]+-[a-z])$`)
+
+// nodeZone returns the zone of the node from its topology label, or from its
+// providerID if it is not labeled yet.
+func nodeZone(node *v1.Node) string {
+	if zone, ok := node.Labels[v1.LabelTopologyZone]; ok {
+		return zone
+	}
+	if matches := gceProviderIDRE.FindStringSubmatch(node.Spec.ProviderID); matches != nil {
+		return matches[2]
+	}
+	return ""
+}
+
+// zonalRangeNames returns the secondary range names of the GKENetworkParamSet
+// usable by the nodes of the zone, in order of preference:
+//   - the names mapped to the zone by spec.podIPv4Ranges.zoneRangeNames, if
+//     the zone is in the map;
+//   - otherwise the range names of the spec, where the ranges of the zone by
+//     naming convention come first and the ranges of other zones are dropped,
+//     unless no range is left.
+func zonalRangeNames(gnp *networkv1alpha1.GKENetworkParamSet, zone string) []string {
+	rangeNames := gkenetworkparamset.PodIPv4RangeNames(gnp)
+	if zone == "" || len(rangeNames) == 0 {
+		return rangeNames
+	}
+	if names, ok := gnp.Spec.PodIPv4Ranges.ZoneRangeNames[zone]; ok {
+		return names
+	}
+	var zonal, unzoned []string
+	for _, name := range rangeNames {
+		matches := zoneSuffixRE.FindStringSubmatch(name)
+		switch {
+		case matches == nil:
+			unzoned = append(unzoned, name)
+		case matches[1] == zone:
+			zonal = append(zonal, name)
+		}
+	}
+	if zonal == nil && unzoned == nil {
+		// Nodes of a zone without ranges keep the ranges they are attached.
+		return rangeNames
+	}
+	return append(zonal, unzoned...)
+}
@@ -0,0 +1,189 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	fake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+)
+
+func TestNodeZone(t *testing.T) {
+	testCases := []struct {
+		desc string
+		node *v1.Node
+		want string
+	}{
+		{
+			desc: "topology label",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelTopologyZone: "us-central1-b"}},
+				Spec:       v1.NodeSpec{ProviderID: "gce://project/us-central1-a/node0"},
+			},
+			want: "us-central1-b",
+		},
+		{
+			desc: "providerID",
+			node: &v1.Node{Spec: v1.NodeSpec{ProviderID: "gce://project/us-central1-a/node0"}},
+			want: "us-central1-a",
+		},
+		{
+			desc: "unknown zone",
+			node: &v1.Node{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := nodeZone(tc.node); got != tc.want {
+				t.Errorf("nodeZone() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestZonalRangeNames(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		rangeNames     []string
+		zoneRangeNames map[string][]string
+		zone           string
+		want           []string
+	}{
+		{
+			desc:       "no zone",
+			rangeNames: []string{"pods-us-central1-a", "pods-us-central1-b"},
+			want:       []string{"pods-us-central1-a", "pods-us-central1-b"},
+		},
+		{
+			desc:       "no zonal ranges",
+			rangeNames: []string{"pods-a", "pods-b"},
+			zone:       "us-central1-a",
+			want:       []string{"pods-a", "pods-b"},
+		},
+		{
+			desc:       "naming convention",
+			rangeNames: []string{"pods", "pods-us-central1-b", "pods-us-central1-a"},
+			zone:       "us-central1-a",
+			want:       []string{"pods-us-central1-a", "pods"},
+		},
+		{
+			desc:       "no range of the zone by naming convention",
+			rangeNames: []string{"pods-us-central1-b", "pods-us-central1-c"},
+			zone:       "us-central1-a",
+			want:       []string{"pods-us-central1-b", "pods-us-central1-c"},
+		},
+		{
+			desc:       "range paths",
+			rangeNames: []string{redVPCSubnetName + "/secondaryIpRanges/pods-us-central1-a", "pods"},
+			zone:       "us-central1-a",
+			want:       []string{"pods-us-central1-a", "pods"},
+		},
+		{
+			desc:           "zone map",
+			rangeNames:     []string{"pods-a", "pods-b"},
+			zoneRangeNames: map[string][]string{"us-central1-a": {"pods-b"}},
+			zone:           "us-central1-a",
+			want:           []string{"pods-b"},
+		},
+		{
+			desc:           "zone not in the map",
+			rangeNames:     []string{"pods", "pods-us-central1-b"},
+			zoneRangeNames: map[string][]string{"us-central1-a": {"pods-a"}},
+			zone:           "us-central1-b",
+			want:           []string{"pods-us-central1-b", "pods"},
+		},
+		{
+			desc:           "host networking",
+			zoneRangeNames: map[string][]string{"us-central1-a": {"pods-a"}},
+			zone:           "us-central1-a",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gnp := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, tc.rangeNames)
+			if gnp.Spec.PodIPv4Ranges != nil {
+				gnp.Spec.PodIPv4Ranges.ZoneRangeNames = tc.zoneRangeNames
+			}
+			if diff := cmp.Diff(tc.want, zonalRangeNames(gnp, tc.zone)); diff != "" {
+				t.Errorf("zonalRangeNames() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPerformMultiNetworkCIDRAllocationZonalRanges(t *testing.T) {
+	nwInfFactory := networkinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 1*time.Second).Networking()
+	nwInformer := nwInfFactory.V1().Networks()
+	gnpInformer := nwInfFactory.V1alpha1().GKENetworkParamSets()
+	if err := nwInformer.Informer().GetStore().Add(network(redNetworkName, redGKENetworkParamsName)); err != nil {
+		t.Fatalf("error in test setup, could not create network: %v", err)
+	}
+	gnp := gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB})
+	gnp.Spec.PodIPv4Ranges.ZoneRangeNames = map[string][]string{"us-central1-a": {redSecondaryRangeA}, "us-central1-b": {redSecondaryRangeB}}
+	if err := gnpInformer.Informer().GetStore().Add(gnp); err != nil {
+		t.Fatalf("error in test setup, could not create gke network param set: %v", err)
+	}
+	ca := &cloudCIDRAllocator{
+		networksLister: nwInformer.Lister(),
+		gnpLister:      gnpInformer.Lister(),
+		recorder:       record.NewFakeRecorder(10),
+	}
+	// The interface has a range of both zones, only the range of the zone of
+	// the node is allocated.
+	infs := []*compute.NetworkInterface{
+		interfaces(redVPCName, redVPCSubnetName, "10.1.1.1", []*compute.AliasIpRange{
+			{IpCidrRange: "172.11.1.0/24", SubnetworkRangeName: redSecondaryRangeA},
+			{IpCidrRange: "172.11.2.0/24", SubnetworkRangeName: redSecondaryRangeB},
+		}),
+	}
+
+	testCases := []struct {
+		desc string
+		zone string
+		want networkv1.MultiNetworkAnnotation
+	}{
+		{
+			desc: "zone a",
+			zone: "us-central1-a",
+			want: networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}},
+		},
+		{
+			desc: "zone b",
+			zone: "us-central1-b",
+			want: networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.2.0/24"}}},
+		},
+		{
+			desc: "zone without ranges",
+			zone: "us-central1-c",
+			want: networkv1.MultiNetworkAnnotation{{Name: redNetworkName, Scope: "host-local", Cidrs: []string{"172.11.1.0/24"}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: "gce://project/" + tc.zone + "/node0"},
+			}
+			_, _, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, infs)
+			if err != nil {
+				t.Fatalf("PerformMultiNetworkCIDRAllocation() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, additionalNodeNetworks); diff != "" {
+				t.Errorf("additional node networks mismatch (-want +got):\n%s", diff)
+			}
+			shadow, err := ca.indexedMultiNetworkCIDRAllocation(node, infs)
+			if err != nil {
+				t.Fatalf("indexedMultiNetworkCIDRAllocation() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, shadow.AdditionalNodeNetworks); diff != "" {
+				t.Errorf("indexed additional node networks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package ipam
+
+import (
+	"strings"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	utilnode "k8s.io/cloud-provider-gcp/pkg/util/node"
+	"k8s.io/klog/v2"
+)
+
+// networkPerformanceTier returns the label value for the instance's
+// networkPerformanceConfig, or "" when the instance doesn't configure one.
+func networkPerformanceTier(instance *compute.Instance) string {
+	if instance == nil || instance.NetworkPerformanceConfig == nil {
+		return ""
+	}
+	return strings.ToLower(instance.NetworkPerformanceConfig.TotalEgressBandwidthTier)
+}
+
+// updateNetworkPerformanceLabel reconciles the network performance tier label on
+// the node with the tier configured on its instance. The node is only patched
+// when the label differs.
+func (ca *cloudCIDRAllocator) updateNetworkPerformanceLabel(node *v1.Node, instance *compute.Instance) error {
+	tier := networkPerformanceTier(instance)
+	current, found := node.Labels[wellknown.NetworkPerformanceTierLabelKey]
+	if current == tier && (found || tier == "") {
+		return nil
+	}
+	labels := map[string]*string{wellknown.NetworkPerformanceTierLabelKey: nil}
+	if tier != "" {
+		labels[wellknown.NetworkPerformanceTierLabelKey] = &tier
+	}
+	if err := utilnode.PatchNodeLabels(ca.client, types.NodeName(node.Name), labels); err != nil {
+		klog.ErrorS(err, "Failed to update the network performance tier label", "nodeName", node.Name, "tier", tier)
+		return err
+	}
+	klog.V(2).InfoS("Updated the network performance tier label", "nodeName", node.Name, "tier", tier)
+	return nil
+}
@@ -0,0 +1,80 @@
+package ipam
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+)
+
+func TestUpdateNetworkPerformanceLabel(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		labels      map[string]string
+		instance    *compute.Instance
+		wantPatched bool
+		wantLabel   string
+		wantFound   bool
+	}{
+		{
+			desc:     "instance without network performance config",
+			instance: &compute.Instance{},
+		},
+		{
+			desc:        "tier 1 instance",
+			instance:    &compute.Instance{NetworkPerformanceConfig: &compute.NetworkPerformanceConfig{TotalEgressBandwidthTier: "TIER_1"}},
+			wantPatched: true,
+			wantLabel:   "tier_1",
+			wantFound:   true,
+		},
+		{
+			desc:      "label already up to date",
+			labels:    map[string]string{wellknown.NetworkPerformanceTierLabelKey: "tier_1"},
+			instance:  &compute.Instance{NetworkPerformanceConfig: &compute.NetworkPerformanceConfig{TotalEgressBandwidthTier: "TIER_1"}},
+			wantLabel: "tier_1",
+			wantFound: true,
+		},
+		{
+			desc:        "tier lowered to default",
+			labels:      map[string]string{wellknown.NetworkPerformanceTierLabelKey: "tier_1"},
+			instance:    &compute.Instance{NetworkPerformanceConfig: &compute.NetworkPerformanceConfig{TotalEgressBandwidthTier: "DEFAULT"}},
+			wantPatched: true,
+			wantLabel:   "default",
+			wantFound:   true,
+		},
+		{
+			desc:        "network performance config removed",
+			labels:      map[string]string{wellknown.NetworkPerformanceTierLabelKey: "tier_1", "other": "label"},
+			instance:    &compute.Instance{},
+			wantPatched: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Labels: tc.labels}}
+			fakeNodeHandler := &testutil.FakeNodeHandler{
+				Existing:  []*v1.Node{node},
+				Clientset: fake.NewSimpleClientset(),
+			}
+			ca := &cloudCIDRAllocator{client: fakeNodeHandler}
+			if err := ca.updateNetworkPerformanceLabel(node, tc.instance); err != nil {
+				t.Fatalf("updateNetworkPerformanceLabel() returned err %v", err)
+			}
+			updated := fakeNodeHandler.GetUpdatedNodesCopy()
+			if gotPatched := len(updated) > 0; gotPatched != tc.wantPatched {
+				t.Fatalf("node patched = %v, want %v", gotPatched, tc.wantPatched)
+			}
+			if !tc.wantPatched {
+				return
+			}
+			got, found := updated[0].Labels[wellknown.NetworkPerformanceTierLabelKey]
+			if got != tc.wantLabel || found != tc.wantFound {
+				t.Errorf("network performance tier label = %q (found %v), want %q (found %v)", got, found, tc.wantLabel, tc.wantFound)
+			}
+		})
+	}
+}
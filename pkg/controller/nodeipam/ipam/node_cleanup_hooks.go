@@ -0,0 +1,224 @@
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
+)
+
+// Node cleanup hooks let external systems (firewalls, DNS, IPAM) release the
+// resources of a deleted node on the additional networks:
+//
+//   - Networks set the wellknown.NodeCleanupWebhookAnnotationKey annotation to
+//     the URL of their hook. The hook receives a POST request with a
+//     NodeCleanupRequest body and must succeed once the resources of the node
+//     are released.
+//   - While the node exists, the allocator lists the networks of the node
+//     having a hook in the wellknown.CleanupNetworksAnnotationKey annotation of
+//     the coordination Lease of the node, and adds
+//     wellknown.NodeCleanupFinalizer to it.
+//   - Once the node is deleted, the allocator calls the hooks, removing the
+//     networks from the annotation as they complete, then the finalizer, which
+//     lets the Lease be garbage collected.
+//
+// The hooks require the node coordination Leases, see
+// wellknown.PublishedAnnotationKey.
+const (
+	// nodeCleanupTimeout bounds the calls to the hooks.
+	nodeCleanupTimeout = 10 * time.Second
+)
+
+// nodeCleanupBackoff is the backoff of the retries of failed hooks. Hooks still
+// failing are retried when the allocator restarts.
+var nodeCleanupBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    10,
+	Cap:      5 * time.Minute,
+}
+
+// NodeCleanupRequest is the body of the requests sent to the node cleanup hooks.
+type NodeCleanupRequest struct {
+	// Node is the name of the deleted node.
+	Node string `json:"node"`
+	// Network is the name of the network whose hook is called.
+	Network string `json:"network"`
+}
+
+// nodeCleanupWebhook returns the URL of the node cleanup hook of the network,
+// or "" if it has none.
+func (ca *cloudCIDRAllocator) nodeCleanupWebhook(network *networkv1.Network) string {
+	if ca.isDefaultNetwork(network) {
+		return ""
+	}
+	return network.Annotations[wellknown.NodeCleanupWebhookAnnotationKey]
+}
+
+// nodeCleanupNetworks returns the value of the
+// wellknown.CleanupNetworksAnnotationKey annotation for the allocation: the
+// sorted names of its networks having a cleanup hook.
+func (ca *cloudCIDRAllocator) nodeCleanupNetworks(allocation multiNetworkAllocation) string {
+	if !ca.params.NodeCleanupHooks || ca.networksLister == nil {
+		return ""
+	}
+	var names []string
+	for _, ni := range allocation.NorthInterfaces {
+		network, err := ca.networksLister.Get(ni.Network)
+		if err != nil || ca.nodeCleanupWebhook(network) == "" || slices.Contains(names, network.Name) {
+			continue
+		}
+		names = append(names, network.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// setNodeCleanupNetworks records the networks whose hooks must be called on
+// deletion of the node in its coordination Lease, and adds or removes
+// wellknown.NodeCleanupFinalizer accordingly.
+func setNodeCleanupNetworks(lease *coordinationv1.Lease, networks string) {
+	if networks == "" {
+		delete(lease.Annotations, wellknown.CleanupNetworksAnnotationKey)
+		lease.Finalizers = slices.Filter(nil, lease.Finalizers, func(f string) bool { return f != wellknown.NodeCleanupFinalizer })
+		return
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string)
+	}
+	lease.Annotations[wellknown.CleanupNetworksAnnotationKey] = networks
+	if !slices.Contains(lease.Finalizers, wellknown.NodeCleanupFinalizer) {
+		lease.Finalizers = append(lease.Finalizers, wellknown.NodeCleanupFinalizer)
+	}
+}
+
+// cleanupNode calls the cleanup hooks of the deleted node until they complete
+// or the retries are exhausted.
+func (ca *cloudCIDRAllocator) cleanupNode(nodeName string) {
+	err := wait.ExponentialBackoff(nodeCleanupBackoff, func() (bool, error) {
+		if err := ca.runNodeCleanupHooks(nodeName); err != nil {
+			klog.Warningf("Failed to clean up the networks of deleted node %s, will retry: %v", nodeName, err)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		klog.Errorf("Gave up cleaning up the networks of deleted node %s: %v", nodeName, err)
+	}
+}
+
+// runNodeCleanupHooks calls the pending cleanup hooks of the deleted node and
+// removes wellknown.NodeCleanupFinalizer from its coordination Lease once they
+// all completed.
+func (ca *cloudCIDRAllocator) runNodeCleanupHooks(nodeName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leases := ca.client.CoordinationV1().Leases(NodeCoordinationLeaseNamespace)
+	lease, err := leases.Get(ctx, NodeCoordinationLeaseName(nodeName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get the coordination lease: %v", err)
+	}
+	if !slices.Contains(lease.Finalizers, wellknown.NodeCleanupFinalizer) {
+		return nil
+	}
+
+	var pending []string
+	var hookErr error
+	for _, name := range strings.Split(lease.Annotations[wellknown.CleanupNetworksAnnotationKey], ",") {
+		if name == "" {
+			continue
+		}
+		if hookErr == nil {
+			hookErr = ca.callNodeCleanupHook(nodeName, name)
+		}
+		if hookErr != nil {
+			pending = append(pending, name)
+		}
+	}
+
+	lease = lease.DeepCopy()
+	setNodeCleanupNetworks(lease, strings.Join(pending, ","))
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to update the coordination lease: %v", err)
+	}
+	if hookErr != nil {
+		return hookErr
+	}
+	klog.V(2).InfoS("Cleaned up the networks of the deleted node", "nodeName", nodeName)
+	return nil
+}
+
+// callNodeCleanupHook calls the cleanup hook of the network for the deleted
+// node. Networks deleted since, or whose hook was removed, need no cleanup.
+func (ca *cloudCIDRAllocator) callNodeCleanupHook(nodeName, networkName string) error {
+	network, err := ca.networksLister.Get(networkName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	url := ca.nodeCleanupWebhook(network)
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(NodeCleanupRequest{Node: nodeName, Network: networkName})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), nodeCleanupTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid cleanup hook of network %s: %v", networkName, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cleanup hook of network %s failed: %v", networkName, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cleanup hook of network %s returned %s", networkName, resp.Status)
+	}
+	klog.V(2).InfoS("Called the cleanup hook of the network", "nodeName", nodeName, "network", networkName)
+	return nil
+}
+
+// resumeNodeCleanups calls the pending cleanup hooks of the nodes deleted while
+// the allocator was not running.
+func (ca *cloudCIDRAllocator) resumeNodeCleanups() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leases, err := ca.client.CoordinationV1().Leases(NodeCoordinationLeaseNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("Failed to list the coordination leases with pending cleanup hooks: %v", err)
+		return
+	}
+	for _, lease := range leases.Items {
+		if !slices.Contains(lease.Finalizers, wellknown.NodeCleanupFinalizer) || !strings.HasPrefix(lease.Name, wellknown.NodeCoordinationLeasePrefix) {
+			continue
+		}
+		nodeName := strings.TrimPrefix(lease.Name, wellknown.NodeCoordinationLeasePrefix)
+		if _, err := ca.nodeLister.Get(nodeName); !apierrors.IsNotFound(err) {
+			continue
+		}
+		go ca.cleanupNode(nodeName)
+	}
+}
@@ -0,0 +1,103 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/utils/strings/slices"
+)
+
+func TestNodeCleanupHooks(t *testing.T) {
+	var requests []NodeCleanupRequest
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req NodeCleanupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the cleanup request: %v", err)
+		}
+		requests = append(requests, req)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	nwInformer := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking().V1().Networks()
+	red := network(redNetworkName, redGKENetworkParamsName)
+	red.Annotations = map[string]string{wellknown.NodeCleanupWebhookAnnotationKey: server.URL}
+	for _, nw := range []*networkv1.Network{red, network(blueNetworkName, blueGKENetworkParamsName)} {
+		if err := nwInformer.Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	clientSet := fake.NewSimpleClientset()
+	ca := &cloudCIDRAllocator{
+		client:         clientSet,
+		networksLister: nwInformer.Lister(),
+		params:         CloudAllocatorParams{NodeCoordinationLeases: true, NodeCleanupHooks: true},
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "test-uid"}}
+	allocation := multiNetworkAllocation{
+		NorthInterfaces: networkv1.NorthInterfacesAnnotation{
+			{Network: redNetworkName, IpAddress: "10.0.0.2"},
+			{Network: blueNetworkName, IpAddress: "10.0.1.2"},
+		},
+	}
+	getLease := func() (map[string]string, []string) {
+		t.Helper()
+		lease, err := clientSet.CoordinationV1().Leases(NodeCoordinationLeaseNamespace).Get(context.TODO(), NodeCoordinationLeaseName(node.Name), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the lease: %v", err)
+		}
+		return lease.Annotations, lease.Finalizers
+	}
+
+	if err := ca.publishNodeCoordinationLease(node, allocation); err != nil {
+		t.Fatalf("publishNodeCoordinationLease() returned err %v", err)
+	}
+	annotations, finalizers := getLease()
+	if got := annotations[wellknown.CleanupNetworksAnnotationKey]; got != redNetworkName {
+		t.Errorf("cleanup networks = %q, want %q", got, redNetworkName)
+	}
+	if !slices.Contains(finalizers, wellknown.NodeCleanupFinalizer) {
+		t.Errorf("lease finalizers = %v, want %s", finalizers, wellknown.NodeCleanupFinalizer)
+	}
+
+	if err := ca.runNodeCleanupHooks(node.Name); err == nil {
+		t.Errorf("runNodeCleanupHooks() returned no error for a failing hook")
+	}
+	if _, finalizers := getLease(); !slices.Contains(finalizers, wellknown.NodeCleanupFinalizer) {
+		t.Errorf("lease finalizers = %v after a failing hook, want %s", finalizers, wellknown.NodeCleanupFinalizer)
+	}
+
+	status = http.StatusOK
+	if err := ca.runNodeCleanupHooks(node.Name); err != nil {
+		t.Fatalf("runNodeCleanupHooks() returned err %v", err)
+	}
+	annotations, finalizers = getLease()
+	if _, ok := annotations[wellknown.CleanupNetworksAnnotationKey]; ok || len(finalizers) != 0 {
+		t.Errorf("lease annotations = %v and finalizers = %v after the cleanup, want no cleanup networks and finalizer", annotations, finalizers)
+	}
+	want := []NodeCleanupRequest{{Node: node.Name, Network: redNetworkName}, {Node: node.Name, Network: redNetworkName}}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("cleanup requests unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestNodeCleanupNetworksDisabled(t *testing.T) {
+	ca := &cloudCIDRAllocator{params: CloudAllocatorParams{NodeCoordinationLeases: true}}
+	allocation := multiNetworkAllocation{NorthInterfaces: networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.0.0.2"}}}
+	if got := ca.nodeCleanupNetworks(allocation); got != "" {
+		t.Errorf("nodeCleanupNetworks() = %q with cleanup hooks disabled, want none", got)
+	}
+}
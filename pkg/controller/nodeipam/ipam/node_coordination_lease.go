@@ -0,0 +1,137 @@
+package ipam
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// Node coordination Leases are a handshake between the allocator and the node
+// agents setting up additional networks:
+//
+//   - Once the multi-network state of a node is published, the allocator sets
+//     the wellknown.PublishedAnnotationKey annotation of the Lease of the node
+//     to a digest of that state and renews the Lease.
+//   - Node agents wait for the Node to carry the published state, set it up and
+//     copy the digest to the wellknown.AcknowledgedAnnotationKey annotation.
+//
+// The Leases live in the node lease namespace and are owned by their Node, so
+// that they are deleted along with it.
+const (
+	// NodeCoordinationLeaseNamespace is the namespace of the node coordination Leases.
+	NodeCoordinationLeaseNamespace = v1.NamespaceNodeLease
+
+	nodeCoordinationLeaseHolder = "node-ipam-controller"
+)
+
+// NodeCoordinationLeaseName returns the name of the coordination Lease of the node.
+func NodeCoordinationLeaseName(nodeName string) string {
+	return wellknown.NodeCoordinationLeasePrefix + nodeName
+}
+
+// LeaseAcknowledged returns true if the node agent acknowledged the state last
+// published by the allocator.
+func LeaseAcknowledged(lease *coordinationv1.Lease) bool {
+	published, ok := lease.Annotations[wellknown.PublishedAnnotationKey]
+	return ok && lease.Annotations[wellknown.AcknowledgedAnnotationKey] == published
+}
+
+// multiNetworkDigest returns a digest of the multi-network state of a node.
+func multiNetworkDigest(allocation multiNetworkAllocation) (string, error) {
+	data, err := json.Marshal(allocation)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// publishNodeCoordinationLease records the published multi-network state of the
+// node in its coordination Lease, if enabled. The Lease is only written when
+// the state changed since it was last recorded.
+func (ca *cloudCIDRAllocator) publishNodeCoordinationLease(node *v1.Node, allocation multiNetworkAllocation) error {
+	if !ca.params.NodeCoordinationLeases {
+		return nil
+	}
+	digest, err := multiNetworkDigest(allocation)
+	if err != nil {
+		return err
+	}
+	cleanupNetworks := ca.nodeCleanupNetworks(allocation)
+	recorded := digest + "/" + cleanupNetworks
+	ca.lock.Lock()
+	upToDate := ca.leaseDigests[node.Name] == recorded
+	ca.lock.Unlock()
+	if upToDate {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leases := ca.client.CoordinationV1().Leases(NodeCoordinationLeaseNamespace)
+	now := metav1.NewMicroTime(time.Now())
+	lease, err := leases.Get(ctx, NodeCoordinationLeaseName(node.Name), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		holder := nodeCoordinationLeaseHolder
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        NodeCoordinationLeaseName(node.Name),
+				Namespace:   NodeCoordinationLeaseNamespace,
+				Annotations: map[string]string{wellknown.PublishedAnnotationKey: digest},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(node, v1.SchemeGroupVersion.WithKind("Node")),
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity: &holder,
+				AcquireTime:    &now,
+				RenewTime:      &now,
+			},
+		}
+		setNodeCleanupNetworks(lease, cleanupNetworks)
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create the coordination lease of node %s: %v", node.Name, err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to get the coordination lease of node %s: %v", node.Name, err)
+	case lease.Annotations[wellknown.PublishedAnnotationKey] != digest || lease.Annotations[wellknown.CleanupNetworksAnnotationKey] != cleanupNetworks:
+		lease = lease.DeepCopy()
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[wellknown.PublishedAnnotationKey] = digest
+		setNodeCleanupNetworks(lease, cleanupNetworks)
+		lease.Spec.RenewTime = &now
+		if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update the coordination lease of node %s: %v", node.Name, err)
+		}
+	}
+	klog.V(2).InfoS("Published the multi-network state in the node coordination lease", "nodeName", node.Name, "digest", digest)
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.leaseDigests == nil {
+		ca.leaseDigests = make(map[string]string)
+	}
+	ca.leaseDigests[node.Name] = recorded
+	return nil
+}
+
+// forgetNodeCoordinationLease drops the recorded Lease state of a deleted
+// node. The Lease itself is garbage collected with the node.
+func (ca *cloudCIDRAllocator) forgetNodeCoordinationLease(nodeName string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	delete(ca.leaseDigests, nodeName)
+}
@@ -0,0 +1,78 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+func TestPublishNodeCoordinationLease(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "test-uid"}}
+	allocation := multiNetworkAllocation{
+		NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "red", IpAddress: "10.1.0.2"}},
+		AdditionalNodeNetworks: networkv1.MultiNetworkAnnotation{{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24"}}},
+	}
+	clientSet := fake.NewSimpleClientset()
+	ca := &cloudCIDRAllocator{client: clientSet, params: CloudAllocatorParams{NodeCoordinationLeases: true}}
+	getLease := func() map[string]string {
+		t.Helper()
+		lease, err := clientSet.CoordinationV1().Leases(NodeCoordinationLeaseNamespace).Get(context.TODO(), NodeCoordinationLeaseName(node.Name), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the lease: %v", err)
+		}
+		if len(lease.OwnerReferences) != 1 || lease.OwnerReferences[0].UID != node.UID {
+			t.Errorf("lease owner references = %v, want the node", lease.OwnerReferences)
+		}
+		if LeaseAcknowledged(lease) {
+			t.Errorf("LeaseAcknowledged() = true for a newly published state, want false")
+		}
+		lease.Annotations[wellknown.AcknowledgedAnnotationKey] = lease.Annotations[wellknown.PublishedAnnotationKey]
+		if !LeaseAcknowledged(lease) {
+			t.Errorf("LeaseAcknowledged() = false after the agent acknowledged the state, want true")
+		}
+		return lease.Annotations
+	}
+
+	if err := ca.publishNodeCoordinationLease(node, allocation); err != nil {
+		t.Fatalf("publishNodeCoordinationLease() returned err %v", err)
+	}
+	published := getLease()[wellknown.PublishedAnnotationKey]
+
+	clientSet.ClearActions()
+	if err := ca.publishNodeCoordinationLease(node, allocation); err != nil {
+		t.Fatalf("publishNodeCoordinationLease() returned err %v", err)
+	}
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v for an unchanged state, want none", actions)
+	}
+
+	allocation.AdditionalNodeNetworks[0].Cidrs = []string{"172.16.1.0/24"}
+	if err := ca.publishNodeCoordinationLease(node, allocation); err != nil {
+		t.Fatalf("publishNodeCoordinationLease() returned err %v", err)
+	}
+	if got := getLease()[wellknown.PublishedAnnotationKey]; got == published {
+		t.Errorf("published digest %q did not change with the state", got)
+	}
+
+	ca.forgetNodeCoordinationLease(node.Name)
+	if _, ok := ca.leaseDigests[node.Name]; ok {
+		t.Errorf("forgetNodeCoordinationLease() kept the digest of the node")
+	}
+}
+
+func TestPublishNodeCoordinationLeaseDisabled(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	ca := &cloudCIDRAllocator{client: clientSet, params: DefaultCloudAllocatorParams()}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	if err := ca.publishNodeCoordinationLease(node, multiNetworkAllocation{}); err != nil {
+		t.Fatalf("publishNodeCoordinationLease() returned err %v", err)
+	}
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v with coordination leases disabled, want none", actions)
+	}
+}
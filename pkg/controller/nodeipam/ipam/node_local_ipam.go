@@ -0,0 +1,66 @@
+package ipam
+
+import (
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// Node-local IPAM splits the allocation of additional networks between the
+// controller and a node agent:
+//
+//  1. The controller matches the node interfaces with the GKENetworkParamSets
+//     of every Network, and publishes the north interfaces together with the
+//     wellknown.DelegatedRangesAnnotationKey annotation. It does not read or
+//     publish the alias IP ranges of additional networks.
+//  2. The node agent attaches an alias IP range from one of the published
+//     secondary ranges to the interface, and publishes the resulting pod CIDRs
+//     in the networkv1.MultiNetworkAnnotationKey annotation along with the IP
+//     capacity of the network.
+//
+// The default network is always allocated by the controller.
+
+// DelegatedRangesAnnotation is the value of the delegated ranges annotation.
+type DelegatedRangesAnnotation []DelegatedRange
+
+// DelegatedRange describes the secondary ranges of a subnetwork from which the
+// node agent attaches an alias IP range for a network.
+type DelegatedRange struct {
+	// Network is the name of the Network object.
+	Network string `json:"network"`
+	// Interface is the name of the node interface connected to the network, e.g. nic1.
+	Interface string `json:"interface"`
+	// Subnetwork is the subnetwork of the interface.
+	Subnetwork string `json:"subnetwork"`
+	// RangeNames are the candidate secondary range names, in order of preference.
+	RangeNames []string `json:"rangeNames"`
+	// MaskSize is the prefix length of the alias IP range to attach, 0 if
+	// the network accepts any size.
+	MaskSize int `json:"maskSize,omitempty"`
+}
+
+// updateDelegatedRangesAnnotations publishes the pod CIDRs, if not nil, along
+// with the north interfaces and the delegated ranges of the node. Unlike updateMultiNetworkAnnotations it merges
+// the annotations, leaving the networks annotation and the IP capacity owned
+// by the node agent untouched.
+func (ca *cloudCIDRAllocator) updateDelegatedRangesAnnotations(node *v1.Node, podCIDRs []string, northInterfaces networkv1.NorthInterfacesAnnotation, delegatedRanges DelegatedRangesAnnotation) error {
+	update := nodeUpdate{PodCIDRs: podCIDRs}
+	if jsonEqual(node.Annotations[networkv1.NorthInterfacesAnnotationKey], northInterfaces) && jsonEqual(node.Annotations[wellknown.DelegatedRangesAnnotationKey], delegatedRanges) {
+		klog.V(4).InfoS("Delegated range annotations are up to date", "nodeName", node.Name)
+		return ca.publishNodeUpdate(node, update)
+	}
+	northInterfaceAnn, err := networkv1.MarshalAnnotation(northInterfaces)
+	if err != nil {
+		return err
+	}
+	delegatedRangesAnn, err := networkv1.MarshalAnnotation(delegatedRanges)
+	if err != nil {
+		return err
+	}
+	update.Annotations = map[string]string{
+		networkv1.NorthInterfacesAnnotationKey: northInterfaceAnn,
+		wellknown.DelegatedRangesAnnotationKey: delegatedRangesAnn,
+	}
+	return ca.publishNodeUpdate(node, update)
+}
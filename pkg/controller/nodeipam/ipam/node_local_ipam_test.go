@@ -0,0 +1,82 @@
+package ipam
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/testutil"
+)
+
+func TestUpdateDelegatedRangesAnnotations(t *testing.T) {
+	northInterfaces := networkv1.NorthInterfacesAnnotation{{Network: redNetworkName, IpAddress: "10.1.1.1"}}
+	delegatedRanges := DelegatedRangesAnnotation{{Network: redNetworkName, Interface: "nic1", Subnetwork: redVPCSubnetName, RangeNames: []string{redSecondaryRangeA}}}
+	northInterfaceAnn, _ := networkv1.MarshalNorthInterfacesAnnotation(northInterfaces)
+	delegatedRangesAnn, _ := networkv1.MarshalAnnotation(delegatedRanges)
+	// The networks annotation is owned by the node agent in node-local IPAM mode.
+	agentNetworksAnn := `[{"name":"Red-Network","scope":"host-local","cidrs":["172.11.1.0/24"]}]`
+
+	testCases := []struct {
+		desc        string
+		annotations map[string]string
+		wantPatched bool
+	}{
+		{
+			desc:        "node without annotations",
+			wantPatched: true,
+		},
+		{
+			desc: "node with the networks annotation of the node agent",
+			annotations: map[string]string{
+				networkv1.MultiNetworkAnnotationKey: agentNetworksAnn,
+			},
+			wantPatched: true,
+		},
+		{
+			desc: "annotations up to date",
+			annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: northInterfaceAnn,
+				wellknown.DelegatedRangesAnnotationKey: delegatedRangesAnn,
+				networkv1.MultiNetworkAnnotationKey:    agentNetworksAnn,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			fakeNodeHandler := &testutil.FakeNodeHandler{
+				Existing:  []*v1.Node{node},
+				Clientset: fake.NewSimpleClientset(),
+			}
+			ca := &cloudCIDRAllocator{client: fakeNodeHandler}
+			if err := ca.updateDelegatedRangesAnnotations(node, nil, northInterfaces, delegatedRanges); err != nil {
+				t.Fatalf("updateDelegatedRangesAnnotations() returned err %v", err)
+			}
+			updated := fakeNodeHandler.GetUpdatedNodesCopy()
+			if gotPatched := len(updated) > 0; gotPatched != tc.wantPatched {
+				t.Fatalf("node patched = %v, want %v", gotPatched, tc.wantPatched)
+			}
+			if !tc.wantPatched {
+				return
+			}
+			want := map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: northInterfaceAnn,
+				wellknown.DelegatedRangesAnnotationKey: delegatedRangesAnn,
+			}
+			if a, ok := tc.annotations[networkv1.MultiNetworkAnnotationKey]; ok {
+				want[networkv1.MultiNetworkAnnotationKey] = a
+			}
+			for k, v := range want {
+				if got := updated[0].Annotations[k]; got != v {
+					t.Errorf("annotation %s = %q, want %q", k, got, v)
+				}
+			}
+			if len(updated[0].Status.Capacity) != 0 {
+				t.Errorf("node capacity = %v, want it untouched", updated[0].Status.Capacity)
+			}
+		})
+	}
+}
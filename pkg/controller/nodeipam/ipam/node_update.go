@@ -0,0 +1,292 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	nodeutil "k8s.io/cloud-provider-gcp/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+// The pod CIDRs and the multi-network state of a node are published in two
+// ordered phases, so that observers never see them out of sync:
+//
+//  1. spec.podCIDRs and the multi-network annotations are set by a single
+//     server-side apply of the node. If the IP capacity of the additional
+//     networks changes too, the same apply sets
+//     wellknown.IPCapacityPendingAnnotationKey to "true".
+//  2. The IP capacity is set by a server-side apply of the node status, which
+//     sets wellknown.IPCapacityPendingAnnotationKey to "false" in the same
+//     request.
+//
+// Each reason of the updates has its own field manager, see fieldManager, so
+// that the applies of one reason never drop the fields of another. An apply
+// carries all the fields its manager owns: they are extracted from the node
+// the update was computed from and the update is applied on top of them, so
+// that the fields the manager no longer sets are removed by the API server.
+// The applies are forced, the allocator being the authority on the fields it
+// publishes.
+//
+// The nodes are read from the node informer cache, never from the API server.
+// The second phase is computed from the node returned by the first phase and
+// is conditional on its resourceVersion: on a conflict, i.e. if another client
+// wrote the node in between, the node is read from the API server once and the
+// apply is computed again.
+//
+// Removing a field by omission only works if the manager is its sole owner.
+// The annotations and IP capacity the update removes but that are still set
+// after an apply, e.g. written by a patch before the allocator applied the
+// node, are removed by a merge patch following the apply of their phase.
+//
+// Consumers comparing the IP capacity of a node with its multi-network
+// annotations must ignore the node while
+// wellknown.IPCapacityPendingAnnotationKey is "true". If the second phase
+// fails, the annotation stays "true" until the retried update succeeds.
+
+// nodeIPAMFieldManager prefixes the field managers of the server-side applies
+// of the nodes.
+const nodeIPAMFieldManager = "node-ipam-controller"
+
+// nodeUpdate is the part of a node that needs to be published.
+type nodeUpdate struct {
+	// PodCIDRs are the pod CIDRs of the node, nil if they are up to date.
+	PodCIDRs []string
+	// Annotations are the annotations to set, nil if they are up to date.
+	Annotations map[string]string
+	// RemovedAnnotations are the keys of the annotations to remove.
+	RemovedAnnotations []string
+	// IPCapacity is the IP capacity of the additional networks of the node,
+	// nil if it is up to date. The capacity of other networks is removed.
+	IPCapacity v1.ResourceList
+	// Reason is why the node is updated, recorded in the audit sink. It
+	// defaults to auditReasonCIDRAllocation.
+	Reason string
+}
+
+// fieldManager returns the field manager of the applies of the node updates
+// made for reason.
+func fieldManager(reason string) string {
+	if reason == "" {
+		reason = auditReasonCIDRAllocation
+	}
+	return nodeIPAMFieldManager + "/" + reason
+}
+
+// publishNodeUpdate publishes the update of the node in two phases, see
+// wellknown.IPCapacityPendingAnnotationKey.
+func (ca *cloudCIDRAllocator) publishNodeUpdate(node *v1.Node, update nodeUpdate) error {
+	if update.PodCIDRs == nil && update.Annotations == nil && update.RemovedAnnotations == nil && update.IPCapacity == nil {
+		return nil
+	}
+	manager := fieldManager(update.Reason)
+	nodeApply, err := specAndAnnotationsApply(node, update, manager)
+	if err != nil {
+		return err
+	}
+	var applied *v1.Node
+	for i := 0; i < cidrUpdateRetries; i++ {
+		if applied, err = ca.applyNode(nodeApply, manager); err == nil {
+			break
+		}
+	}
+	if err == nil {
+		applied, err = ca.removeLeftoverAnnotations(applied, update.RemovedAnnotations)
+	}
+	if err != nil {
+		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRAssignmentFailed")
+		klog.ErrorS(err, "Failed to update the node PodCIDR and annotations after multiple attempts", "nodeName", node.Name, "podCIDRs", update.PodCIDRs)
+		return &AllocationError{Kind: ErrNodeUpdate, Err: err}
+	}
+	if update.PodCIDRs != nil {
+		klog.InfoS("Set the node PodCIDRs", "nodeName", node.Name, "cidrStrings", update.PodCIDRs)
+	}
+	ca.auditNodeUpdate(node, update.Reason, specAndAnnotationsChanges(node, update))
+	if update.IPCapacity == nil {
+		return nil
+	}
+	if err = ca.applyIPCapacity(applied, update.IPCapacity, manager); err != nil {
+		nodeutil.RecordNodeStatusChange(ca.recorder, node, "CIDRAssignmentFailed")
+		klog.ErrorS(err, "Failed to update the node capacity for multi-networking", "nodeName", node.Name)
+		return &AllocationError{Kind: ErrNodeUpdate, Err: err}
+	}
+	ca.auditNodeUpdate(node, update.Reason, ipCapacityChanges(applied, update.IPCapacity))
+	return nil
+}
+
+// applyIPCapacity applies the IP capacity of the node, conditionally on the
+// version of the node returned by the first phase. On a conflict, the apply is
+// computed again from the node read from the API server.
+func (ca *cloudCIDRAllocator) applyIPCapacity(node *v1.Node, ipCapacity v1.ResourceList, manager string) error {
+	nodeApply, err := ipCapacityApply(node, ipCapacity, manager)
+	if err != nil {
+		return err
+	}
+	applied, err := ca.applyNodeStatus(nodeApply, manager)
+	if apierrors.IsConflict(err) {
+		klog.V(2).InfoS("Node changed since the first phase, applying the IP capacity of the current node", "nodeName", node.Name)
+		if node, err = ca.getNode(node.Name); err != nil {
+			return err
+		}
+		if nodeApply, err = ipCapacityApply(node, ipCapacity, manager); err != nil {
+			return err
+		}
+		applied, err = ca.applyNodeStatus(nodeApply, manager)
+	}
+	if err != nil {
+		return err
+	}
+	return ca.removeLeftoverIPCapacity(node, applied, ipCapacity)
+}
+
+// removeLeftoverAnnotations removes the annotations of keys still set on the
+// applied node, owned by other field managers. It returns the patched node, or
+// the applied node if there was nothing to remove.
+func (ca *cloudCIDRAllocator) removeLeftoverAnnotations(applied *v1.Node, keys []string) (*v1.Node, error) {
+	annotations := make(map[string]interface{})
+	for _, k := range keys {
+		if _, ok := applied.Annotations[k]; ok {
+			annotations[k] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return applied, nil
+	}
+	patchBytes, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the node patch: %v", err)
+	}
+	return ca.patchNode(applied.Name, types.MergePatchType, patchBytes)
+}
+
+// removeLeftoverIPCapacity removes the IP capacity that node published, that
+// is not in ipCapacity but is still set on the applied node, owned by other
+// field managers. The patch fails with a conflict if the node changed since it
+// was applied.
+func (ca *cloudCIDRAllocator) removeLeftoverIPCapacity(node, applied *v1.Node, ipCapacity v1.ResourceList) error {
+	capacity := make(map[v1.ResourceName]interface{})
+	for name := range publishedIPResources(node) {
+		if _, ok := ipCapacity[v1.ResourceName(name)]; ok {
+			continue
+		}
+		if _, ok := applied.Status.Capacity[v1.ResourceName(name)]; ok {
+			capacity[v1.ResourceName(name)] = nil
+		}
+	}
+	metadata := map[string]interface{}{}
+	if _, ok := applied.Annotations[wellknown.IPResourceNamesAnnotationKey]; ok && customIPResourceNames(ipCapacity) == "" {
+		metadata["annotations"] = map[string]interface{}{wellknown.IPResourceNamesAnnotationKey: nil}
+	}
+	if len(capacity) == 0 && len(metadata) == 0 {
+		return nil
+	}
+	if applied.ResourceVersion != "" {
+		metadata["resourceVersion"] = applied.ResourceVersion
+	}
+	patchBytes, err := json.Marshal(map[string]interface{}{
+		"metadata": metadata,
+		"status":   map[string]interface{}{"capacity": capacity},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build the node status patch for multi-networking: %v", err)
+	}
+	_, err = ca.patchNode(applied.Name, types.MergePatchType, patchBytes, "status")
+	return err
+}
+
+// applyNode applies the node with the field manager, counting the request as
+// a patch. It returns the applied node.
+func (ca *cloudCIDRAllocator) applyNode(nodeApply *corev1ac.NodeApplyConfiguration, manager string) (*v1.Node, error) {
+	nodeAPIRequests.WithLabelValues("patch").Inc()
+	return ca.client.CoreV1().Nodes().Apply(context.TODO(), nodeApply, metav1.ApplyOptions{FieldManager: manager, Force: true})
+}
+
+// applyNodeStatus applies the node status with the field manager, counting the
+// request as a patch. It returns the applied node.
+func (ca *cloudCIDRAllocator) applyNodeStatus(nodeApply *corev1ac.NodeApplyConfiguration, manager string) (*v1.Node, error) {
+	nodeAPIRequests.WithLabelValues("patch").Inc()
+	return ca.client.CoreV1().Nodes().ApplyStatus(context.TODO(), nodeApply, metav1.ApplyOptions{FieldManager: manager, Force: true})
+}
+
+// patchNode patches the node, or one of its subresources, counting the
+// request. It returns the patched node.
+func (ca *cloudCIDRAllocator) patchNode(nodeName string, pt types.PatchType, data []byte, subresources ...string) (*v1.Node, error) {
+	nodeAPIRequests.WithLabelValues("patch").Inc()
+	return ca.client.CoreV1().Nodes().Patch(context.TODO(), nodeName, pt, data, metav1.PatchOptions{}, subresources...)
+}
+
+// getNode reads the node from the API server, counting the request. The nodes
+// are otherwise read from the informer cache.
+func (ca *cloudCIDRAllocator) getNode(nodeName string) (*v1.Node, error) {
+	nodeAPIRequests.WithLabelValues("get").Inc()
+	return ca.client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+}
+
+// specAndAnnotationsApply returns the apply configuration of the first phase:
+// the fields of the node owned by the manager, with the update applied.
+func specAndAnnotationsApply(node *v1.Node, update nodeUpdate, manager string) (*corev1ac.NodeApplyConfiguration, error) {
+	nodeApply, err := corev1ac.ExtractNode(node, manager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the fields of the node owned by %s: %v", manager, err)
+	}
+	if update.PodCIDRs != nil {
+		if nodeApply.Spec == nil {
+			nodeApply.WithSpec(corev1ac.NodeSpec())
+		}
+		nodeApply.Spec.PodCIDRs = nil
+		nodeApply.Spec.WithPodCIDR(update.PodCIDRs[0]).WithPodCIDRs(update.PodCIDRs...)
+	}
+	nodeApply.WithAnnotations(update.Annotations)
+	for _, k := range update.RemovedAnnotations {
+		delete(nodeApply.Annotations, k)
+	}
+	if update.IPCapacity != nil {
+		nodeApply.WithAnnotations(map[string]string{wellknown.IPCapacityPendingAnnotationKey: "true"})
+	}
+	return nodeApply, nil
+}
+
+// ipCapacityApply returns the apply configuration of the node status of the
+// second phase: the fields of the node status owned by the manager, with the IP
+// capacity of networks the node is no longer attached to removed and the custom
+// resource names of the capacity recorded in
+// wellknown.IPResourceNamesAnnotationKey. The apply fails with a conflict if
+// the node changed since it was read.
+func ipCapacityApply(node *v1.Node, ipCapacity v1.ResourceList, manager string) (*corev1ac.NodeApplyConfiguration, error) {
+	nodeApply, err := corev1ac.ExtractNodeStatus(node, manager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the fields of the node status owned by %s: %v", manager, err)
+	}
+	if nodeApply.Status == nil {
+		nodeApply.WithStatus(corev1ac.NodeStatus())
+	}
+	capacity := v1.ResourceList{}
+	if nodeApply.Status.Capacity != nil {
+		for name, quantity := range *nodeApply.Status.Capacity {
+			capacity[name] = quantity
+		}
+	}
+	for name := range publishedIPResources(node) {
+		delete(capacity, v1.ResourceName(name))
+	}
+	for name, quantity := range ipCapacity {
+		capacity[name] = quantity
+	}
+	nodeApply.Status.WithCapacity(capacity)
+	nodeApply.WithAnnotations(map[string]string{wellknown.IPCapacityPendingAnnotationKey: "false"})
+	if names := customIPResourceNames(ipCapacity); names != "" {
+		nodeApply.WithAnnotations(map[string]string{wellknown.IPResourceNamesAnnotationKey: names})
+	} else {
+		delete(nodeApply.Annotations, wellknown.IPResourceNamesAnnotationKey)
+	}
+	if node.ResourceVersion != "" {
+		nodeApply.WithResourceVersion(node.ResourceVersion)
+	}
+	return nodeApply, nil
+}
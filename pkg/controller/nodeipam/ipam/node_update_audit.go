@@ -0,0 +1,77 @@
+package ipam
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/audit"
+	"k8s.io/klog/v2"
+)
+
+// The reasons of the node updates recorded in the audit sink.
+const (
+	auditReasonCIDRAllocation    = "CIDRAllocation"
+	auditReasonInstanceID        = "InstanceID"
+	auditReasonPredictedNetworks = "PredictedNetworks"
+	auditReasonStrandedPodCIDR   = "StrandedPodCIDR"
+)
+
+// auditNodeUpdate records the changes of the node in the audit sink, if any.
+// Failing to record them does not fail the update of the node.
+func (ca *cloudCIDRAllocator) auditNodeUpdate(node *v1.Node, reason string, changes []audit.Change) {
+	if ca.params.AuditSink == nil || len(changes) == 0 {
+		return
+	}
+	if reason == "" {
+		reason = auditReasonCIDRAllocation
+	}
+	record := audit.Record{Time: ca.now().Time, Node: node.Name, Reason: reason, Changes: changes}
+	if err := ca.params.AuditSink.Record(record); err != nil {
+		klog.ErrorS(err, "Failed to record the node update in the audit sink", "nodeName", node.Name, "reason", reason)
+	}
+}
+
+// specAndAnnotationsChanges returns the changes of the pod CIDRs and the
+// annotations of the node made by the first phase of the update.
+func specAndAnnotationsChanges(node *v1.Node, update nodeUpdate) []audit.Change {
+	var changes []audit.Change
+	if update.PodCIDRs != nil {
+		before, after := strings.Join(node.Spec.PodCIDRs, ","), strings.Join(update.PodCIDRs, ",")
+		if before != after {
+			changes = append(changes, audit.Change{Field: "spec.podCIDRs", Before: before, After: after})
+		}
+	}
+	keys := sets.StringKeySet(update.Annotations).Insert(update.RemovedAnnotations...)
+	for _, k := range keys.List() {
+		before, after := node.Annotations[k], update.Annotations[k]
+		if before != after {
+			changes = append(changes, audit.Change{Field: fmt.Sprintf("metadata.annotations[%s]", k), Before: before, After: after})
+		}
+	}
+	return changes
+}
+
+// ipCapacityChanges returns the changes of the IP capacity of the node made by
+// the second phase of the update.
+func ipCapacityChanges(node *v1.Node, ipCapacity v1.ResourceList) []audit.Change {
+	names := publishedIPResources(node)
+	for name := range ipCapacity {
+		names.Insert(name.String())
+	}
+	var changes []audit.Change
+	for _, name := range names.List() {
+		var before, after string
+		if quantity, ok := node.Status.Capacity[v1.ResourceName(name)]; ok {
+			before = quantity.String()
+		}
+		if quantity, ok := ipCapacity[v1.ResourceName(name)]; ok {
+			after = quantity.String()
+		}
+		if before != after {
+			changes = append(changes, audit.Change{Field: fmt.Sprintf("status.capacity[%s]", name), Before: before, After: after})
+		}
+	}
+	return changes
+}
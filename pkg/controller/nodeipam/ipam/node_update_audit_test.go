@@ -0,0 +1,122 @@
+package ipam
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/audit"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// fakeAuditSink holds the recorded node updates.
+type fakeAuditSink struct {
+	records []audit.Record
+	err     error
+}
+
+func (f *fakeAuditSink) Record(record audit.Record) error {
+	f.records = append(f.records, record)
+	return f.err
+}
+
+func TestPublishNodeUpdateAudit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	staleResource := networkIPResourceName("Old-Network")
+	newResource := networkIPResourceName("Blue-Network")
+	testCases := []struct {
+		desc    string
+		update  nodeUpdate
+		sinkErr error
+		want    []audit.Record
+	}{
+		{
+			desc: "up to date node is not recorded",
+		},
+		{
+			desc:   "unchanged values are not recorded",
+			update: nodeUpdate{PodCIDRs: []string{"10.0.0.0/24"}, Annotations: map[string]string{wellknown.InstanceIDAnnotationKey: "1"}},
+		},
+		{
+			desc: "pod CIDRs, annotations and capacity",
+			update: nodeUpdate{
+				PodCIDRs:           []string{"10.0.1.0/24"},
+				Annotations:        map[string]string{wellknown.InstanceIDAnnotationKey: "2"},
+				RemovedAnnotations: []string{wellknown.StrandedSinceAnnotationKey},
+				IPCapacity:         v1.ResourceList{newResource: resource.MustParse("64")},
+			},
+			want: []audit.Record{
+				{
+					Time:   now,
+					Node:   "node0",
+					Reason: auditReasonCIDRAllocation,
+					Changes: []audit.Change{
+						{Field: "spec.podCIDRs", Before: "10.0.0.0/24", After: "10.0.1.0/24"},
+						{Field: "metadata.annotations[" + wellknown.InstanceIDAnnotationKey + "]", Before: "1", After: "2"},
+						{Field: "metadata.annotations[" + wellknown.StrandedSinceAnnotationKey + "]", Before: "2023-01-01T00:00:00Z"},
+					},
+				},
+				{
+					Time:   now,
+					Node:   "node0",
+					Reason: auditReasonCIDRAllocation,
+					Changes: []audit.Change{
+						{Field: "status.capacity[" + newResource.String() + "]", After: "64"},
+						{Field: "status.capacity[" + staleResource.String() + "]", Before: "128"},
+					},
+				},
+			},
+		},
+		{
+			desc:    "failed record does not fail the update",
+			update:  nodeUpdate{Annotations: map[string]string{wellknown.StrandedSinceAnnotationKey: "2023-01-02T00:00:00Z"}, Reason: auditReasonStrandedPodCIDR},
+			sinkErr: errors.New("injected error"),
+			want: []audit.Record{
+				{
+					Time:    now,
+					Node:    "node0",
+					Reason:  auditReasonStrandedPodCIDR,
+					Changes: []audit.Change{{Field: "metadata.annotations[" + wellknown.StrandedSinceAnnotationKey + "]", Before: "2023-01-01T00:00:00Z", After: "2023-01-02T00:00:00Z"}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node0",
+					Annotations: map[string]string{
+						wellknown.InstanceIDAnnotationKey:    "1",
+						wellknown.StrandedSinceAnnotationKey: "2023-01-01T00:00:00Z",
+					},
+				},
+				Spec: v1.NodeSpec{PodCIDR: "10.0.0.0/24", PodCIDRs: []string{"10.0.0.0/24"}},
+				Status: v1.NodeStatus{Capacity: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("4"),
+					staleResource:  resource.MustParse("128"),
+				}},
+			}
+			sink := &fakeAuditSink{err: tc.sinkErr}
+			ca := &cloudCIDRAllocator{
+				client:   fake.NewSimpleClientset(node),
+				clock:    testingclock.NewFakeClock(now),
+				recorder: record.NewFakeRecorder(10),
+				params:   CloudAllocatorParams{AuditSink: sink},
+			}
+			if err := ca.publishNodeUpdate(node, tc.update); err != nil {
+				t.Fatalf("publishNodeUpdate() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, sink.records); diff != "" {
+				t.Errorf("unexpected audit records (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
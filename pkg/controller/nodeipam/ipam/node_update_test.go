@@ -0,0 +1,303 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestPublishNodeUpdate(t *testing.T) {
+	staleResource := networkIPResourceName("Old-Network")
+	newResource := networkIPResourceName("Blue-Network")
+	testCases := []struct {
+		desc            string
+		update          nodeUpdate
+		failStatusPatch bool
+		wantPatches     int
+		wantPodCIDRs    []string
+		wantPending     bool
+		wantCapacity    v1.ResourceList
+		wantErr         bool
+	}{
+		{
+			desc:         "up to date node is not patched",
+			wantCapacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), staleResource: resource.MustParse("128")},
+		},
+		{
+			desc:         "pod CIDRs only",
+			update:       nodeUpdate{PodCIDRs: []string{"10.0.0.0/24"}},
+			wantPatches:  1,
+			wantPodCIDRs: []string{"10.0.0.0/24"},
+			wantCapacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), staleResource: resource.MustParse("128")},
+		},
+		{
+			desc: "pod CIDRs, annotations and capacity",
+			update: nodeUpdate{
+				PodCIDRs:    []string{"10.0.0.0/24"},
+				Annotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: "[]"},
+				IPCapacity:  v1.ResourceList{newResource: resource.MustParse("64")},
+			},
+			// The stale capacity, not owned by the allocator, is removed by a
+			// patch following the apply of the status.
+			wantPatches:  3,
+			wantPodCIDRs: []string{"10.0.0.0/24"},
+			wantCapacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), newResource: resource.MustParse("64")},
+		},
+		{
+			desc: "failed capacity update leaves the pending annotation",
+			update: nodeUpdate{
+				PodCIDRs:    []string{"10.0.0.0/24"},
+				Annotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: "[]"},
+				IPCapacity:  v1.ResourceList{newResource: resource.MustParse("64")},
+			},
+			failStatusPatch: true,
+			wantPatches:     2,
+			wantPodCIDRs:    []string{"10.0.0.0/24"},
+			wantPending:     true,
+			wantCapacity:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), staleResource: resource.MustParse("128")},
+			wantErr:         true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Status: v1.NodeStatus{Capacity: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("4"),
+					staleResource:  resource.MustParse("128"),
+				}},
+			}
+			client := fake.NewSimpleClientset(node)
+			if tc.failStatusPatch {
+				client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() == "status" {
+						return true, nil, errors.New("injected error")
+					}
+					return false, nil, nil
+				})
+			}
+			ca := &cloudCIDRAllocator{client: client, recorder: record.NewFakeRecorder(10)}
+			err := ca.publishNodeUpdate(node, tc.update)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("publishNodeUpdate() returned err %v, want error %v", err, tc.wantErr)
+			}
+			if got := len(client.Actions()); got != tc.wantPatches {
+				t.Errorf("publishNodeUpdate() made %d API calls, want %d: %v", got, tc.wantPatches, client.Actions())
+			}
+			got, err := client.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Get(%s) returned err %v", node.Name, err)
+			}
+			if diff := cmp.Diff(tc.wantPodCIDRs, got.Spec.PodCIDRs); diff != "" {
+				t.Errorf("unexpected pod CIDRs (-want +got):\n%s", diff)
+			}
+			for k, v := range tc.update.Annotations {
+				if got.Annotations[k] != v {
+					t.Errorf("annotation %s = %q, want %q", k, got.Annotations[k], v)
+				}
+			}
+			if gotPending := got.Annotations[wellknown.IPCapacityPendingAnnotationKey] == "true"; gotPending != tc.wantPending {
+				t.Errorf("pending annotation set = %v, want %v", gotPending, tc.wantPending)
+			}
+			if diff := cmp.Diff(tc.wantCapacity, got.Status.Capacity); diff != "" {
+				t.Errorf("unexpected capacity (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPublishNodeUpdateResourceVersion(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	staleResource := networkIPResourceName("Old-Network")
+	newResource := networkIPResourceName("Blue-Network")
+	cached := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node0", ResourceVersion: "1"},
+		Status:     v1.NodeStatus{Capacity: v1.ResourceList{staleResource: resource.MustParse("128")}},
+	}
+	client := fake.NewSimpleClientset(cached)
+	// The API server bumps the resourceVersion of the node on the first phase,
+	// and rejects the status patches conditional on any other version.
+	client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetSubresource() == "status" {
+			if !strings.Contains(string(patch.GetPatch()), `"resourceVersion":"2"`) {
+				return true, nil, apierrors.NewConflict(v1.Resource("nodes"), "node0", errors.New("stale resourceVersion"))
+			}
+			return false, nil, nil
+		}
+		_, obj, err := k8stesting.ObjectReaction(client.Tracker())(action)
+		if err != nil {
+			return true, nil, err
+		}
+		node := obj.(*v1.Node)
+		node.ResourceVersion = "2"
+		return true, node, client.Tracker().Update(v1.SchemeGroupVersion.WithResource("nodes"), node, "")
+	})
+	ca := &cloudCIDRAllocator{client: client, recorder: record.NewFakeRecorder(10)}
+	gets, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("get"))
+	patches, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("patch"))
+
+	update := nodeUpdate{
+		Annotations: map[string]string{networkv1.NorthInterfacesAnnotationKey: "[]"},
+		IPCapacity:  v1.ResourceList{newResource: resource.MustParse("64")},
+	}
+	if err := ca.publishNodeUpdate(cached, update); err != nil {
+		t.Fatalf("publishNodeUpdate() returned err %v", err)
+	}
+	got, err := client.CoreV1().Nodes().Get(context.TODO(), cached.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", cached.Name, err)
+	}
+	if diff := cmp.Diff(v1.ResourceList{newResource: resource.MustParse("64")}, got.Status.Capacity); diff != "" {
+		t.Errorf("unexpected capacity (-want +got):\n%s", diff)
+	}
+	if pending := got.Annotations[wellknown.IPCapacityPendingAnnotationKey]; pending != "false" {
+		t.Errorf("pending annotation = %q, want %q", pending, "false")
+	}
+	// The status apply is conditional on the version returned by the first
+	// phase, so the node is not read again. The stale capacity, not owned by
+	// the allocator, is removed by a third patch.
+	if got, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("get")); got-gets != 0 {
+		t.Errorf("got %v node reads, want 0", got-gets)
+	}
+	if got, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("patch")); got-patches != 3 {
+		t.Errorf("got %v node patches, want 3", got-patches)
+	}
+}
+
+func TestPublishNodeUpdateConcurrentWrite(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	staleResource := networkIPResourceName("Old-Network")
+	unseenResource := networkIPResourceName("Red-Network")
+	newResource := networkIPResourceName("Blue-Network")
+	cached := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node0", ResourceVersion: "1"},
+		Status:     v1.NodeStatus{Capacity: v1.ResourceList{staleResource: resource.MustParse("128")}},
+	}
+	client := fake.NewSimpleClientset(cached)
+	// Another client publishes the IP capacity of Red-Network between the two
+	// phases, so the first status patch conflicts.
+	concurrentWrite := false
+	client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetSubresource() != "status" || concurrentWrite {
+			return false, nil, nil
+		}
+		concurrentWrite = true
+		obj, err := client.Tracker().Get(v1.SchemeGroupVersion.WithResource("nodes"), "", "node0")
+		if err != nil {
+			return true, nil, err
+		}
+		node := obj.(*v1.Node)
+		node.Status.Capacity[unseenResource] = resource.MustParse("32")
+		if err := client.Tracker().Update(v1.SchemeGroupVersion.WithResource("nodes"), node, ""); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(v1.Resource("nodes"), "node0", errors.New("stale resourceVersion"))
+	})
+	ca := &cloudCIDRAllocator{client: client, recorder: record.NewFakeRecorder(10)}
+	gets, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("get"))
+	patches, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("patch"))
+
+	update := nodeUpdate{IPCapacity: v1.ResourceList{newResource: resource.MustParse("64")}}
+	if err := ca.publishNodeUpdate(cached, update); err != nil {
+		t.Fatalf("publishNodeUpdate() returned err %v", err)
+	}
+	got, err := client.CoreV1().Nodes().Get(context.TODO(), cached.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) returned err %v", cached.Name, err)
+	}
+	if diff := cmp.Diff(v1.ResourceList{newResource: resource.MustParse("64")}, got.Status.Capacity); diff != "" {
+		t.Errorf("unexpected capacity (-want +got):\n%s", diff)
+	}
+	// The annotations apply, the conflicting status apply, the read of the
+	// current node, the status apply computed from it and the patch removing
+	// the capacity the allocator does not own.
+	if got, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("get")); got-gets != 1 {
+		t.Errorf("got %v node reads, want 1", got-gets)
+	}
+	if got, _ := testutil.GetCounterMetricValue(nodeAPIRequests.WithLabelValues("patch")); got-patches != 4 {
+		t.Errorf("got %v node patches, want 4", got-patches)
+	}
+}
+
+func TestPublishNodeUpdateOwnedFields(t *testing.T) {
+	manager := fieldManager(auditReasonCIDRAllocation)
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node0",
+			Annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: "[]",
+				networkv1.NodeNetworkAnnotationKey:     "[]",
+				wellknown.InstanceIDAnnotationKey:      "1234",
+			},
+			// The allocator applied both multi-network annotations, and the
+			// instance ID for another reason.
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					Manager:    manager,
+					Operation:  metav1.ManagedFieldsOperationApply,
+					APIVersion: "v1",
+					FieldsType: "FieldsV1",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{` +
+						`"f:` + networkv1.NorthInterfacesAnnotationKey + `":{},` +
+						`"f:` + networkv1.NodeNetworkAnnotationKey + `":{}}}}`)},
+				},
+				{
+					Manager:    fieldManager(auditReasonInstanceID),
+					Operation:  metav1.ManagedFieldsOperationApply,
+					APIVersion: "v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` + wellknown.InstanceIDAnnotationKey + `":{}}}}`)},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(node)
+	ca := &cloudCIDRAllocator{client: client, recorder: record.NewFakeRecorder(10)}
+	update := nodeUpdate{
+		PodCIDRs:           []string{"10.0.0.0/24"},
+		RemovedAnnotations: []string{networkv1.NodeNetworkAnnotationKey},
+	}
+	if err := ca.publishNodeUpdate(node, update); err != nil {
+		t.Fatalf("publishNodeUpdate() returned err %v", err)
+	}
+	actions := client.Actions()
+	if len(actions) == 0 {
+		t.Fatalf("publishNodeUpdate() made no API calls")
+	}
+	apply, ok := actions[0].(k8stesting.PatchAction)
+	if !ok || apply.GetPatchType() != types.ApplyPatchType {
+		t.Fatalf("first API call is %v, want an apply", actions[0])
+	}
+	// The apply carries the annotation the manager owns, so that it is not
+	// removed, but neither the removed annotation nor the annotation of
+	// another manager.
+	var got v1.Node
+	if err := json.Unmarshal(apply.GetPatch(), &got); err != nil {
+		t.Fatalf("failed to decode the apply: %v", err)
+	}
+	wantAnnotations := map[string]string{networkv1.NorthInterfacesAnnotationKey: "[]"}
+	if diff := cmp.Diff(wantAnnotations, got.Annotations); diff != "" {
+		t.Errorf("unexpected applied annotations (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(update.PodCIDRs, got.Spec.PodCIDRs); diff != "" {
+		t.Errorf("unexpected applied pod CIDRs (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,118 @@
+package ipam
+
+import (
+	"strconv"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+	"k8s.io/utils/strings/slices"
+)
+
+// priorPodCIDRsTTL is how long the pod CIDRs of a deleted node are kept for
+// the node to be recreated on the same instance.
+const priorPodCIDRsTTL = time.Hour
+
+// priorPodCIDRs holds the pod CIDRs of a deleted node.
+type priorPodCIDRs struct {
+	// providerID is the providerID of the deleted node.
+	providerID string
+	// instanceID is the wellknown.InstanceIDAnnotationKey of the deleted node.
+	instanceID string
+	// cidrs are the pod CIDRs of the deleted node.
+	cidrs []string
+	// released is the time the node was deleted.
+	released time.Time
+}
+
+// rememberPodCIDRs keeps the pod CIDRs of a deleted node, so that they are
+// published again if the node is recreated on the same instance, e.g. when
+// the node object is deleted and registered again by the kubelet during a
+// reboot or an upgrade, see preferPriorPodCIDRs.
+func (ca *cloudCIDRAllocator) rememberPodCIDRs(node *v1.Node) {
+	if len(node.Spec.PodCIDRs) == 0 || node.Spec.ProviderID == "" {
+		return
+	}
+	now := ca.now().Time
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.priorPodCIDRs == nil {
+		ca.priorPodCIDRs = make(map[string]priorPodCIDRs)
+	}
+	for name, prior := range ca.priorPodCIDRs {
+		if now.Sub(prior.released) > priorPodCIDRsTTL {
+			delete(ca.priorPodCIDRs, name)
+		}
+	}
+	ca.priorPodCIDRs[node.Name] = priorPodCIDRs{
+		providerID: node.Spec.ProviderID,
+		instanceID: node.Annotations[wellknown.InstanceIDAnnotationKey],
+		cidrs:      slices.Clone(node.Spec.PodCIDRs),
+		released:   now,
+	}
+}
+
+// preferPriorPodCIDRs returns the pod CIDRs a node without pod CIDRs had
+// before it was deleted, if it is recreated on the same instance and the
+// interface the given pod CIDRs are allocated from still has them. Otherwise
+// the given pod CIDRs are returned. The instance may have several alias IP
+// ranges usable as pod CIDRs, and publishing the same one as before avoids
+// reprogramming the dataplane of the node.
+func (ca *cloudCIDRAllocator) preferPriorPodCIDRs(node *v1.Node, instance *compute.Instance, cidrs []string) []string {
+	if node.Spec.PodCIDR != "" {
+		return cidrs
+	}
+	now := ca.now().Time
+	ca.lock.Lock()
+	prior, ok := ca.priorPodCIDRs[node.Name]
+	delete(ca.priorPodCIDRs, node.Name)
+	ca.lock.Unlock()
+	if !ok || now.Sub(prior.released) > priorPodCIDRsTTL || prior.providerID != node.Spec.ProviderID {
+		return cidrs
+	}
+	if prior.instanceID != "" && instance.Id != 0 && prior.instanceID != strconv.FormatUint(instance.Id, 10) {
+		klog.V(2).InfoS("Not reusing the pod CIDRs of the deleted node, its instance was recreated", "nodeName", node.Name, "podCIDRs", prior.cidrs)
+		return cidrs
+	}
+	if slices.Equal(prior.cidrs, cidrs) {
+		return cidrs
+	}
+	inf := podCIDRsInterface(instance, cidrs)
+	if inf == nil {
+		return cidrs
+	}
+	available := make(map[string]bool)
+	for _, ipRange := range inf.AliasIpRanges {
+		if _, ipNet, err := netutils.ParseCIDRSloppy(ipRange.IpCidrRange); err == nil {
+			available[ipNet.String()] = true
+		}
+	}
+	if ipv6Addr := gce.InterfaceIPv6PodCIDR(inf); ipv6Addr != nil {
+		available[ipv6Addr.String()] = true
+	}
+	for _, cidr := range prior.cidrs {
+		if !available[cidr] {
+			klog.V(2).InfoS("Not reusing the pod CIDRs of the deleted node, the instance no longer has them", "nodeName", node.Name, "podCIDRs", prior.cidrs)
+			return cidrs
+		}
+	}
+	klog.V(2).InfoS("Reusing the pod CIDRs the node had before it was recreated", "nodeName", node.Name, "podCIDRs", prior.cidrs, "discoveredPodCIDRs", cidrs)
+	return prior.cidrs
+}
+
+// podCIDRsInterface returns the interface of the instance whose alias IP
+// ranges include one of the pod CIDRs, or nil.
+func podCIDRsInterface(instance *compute.Instance, cidrs []string) *compute.NetworkInterface {
+	for _, inf := range instance.NetworkInterfaces {
+		for _, ipRange := range inf.AliasIpRanges {
+			if _, ipNet, err := netutils.ParseCIDRSloppy(ipRange.IpCidrRange); err == nil && slices.Contains(cidrs, ipNet.String()) {
+				return inf
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package ipam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPreferPriorPodCIDRs(t *testing.T) {
+	const providerID = "gce://test-project/us-central1-b/node0"
+	instance := &compute.Instance{
+		Id: 1234,
+		NetworkInterfaces: []*compute.NetworkInterface{
+			{Name: "nic0", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "10.0.0.0/24"}, {IpCidrRange: "10.0.1.0/24"}}},
+			{Name: "nic1", AliasIpRanges: []*compute.AliasIpRange{{IpCidrRange: "172.16.0.0/24"}}},
+		},
+	}
+	discovered := []string{"10.0.0.0/24"}
+	testCases := []struct {
+		desc        string
+		deleted     *v1.Node
+		node        *v1.Node
+		elapsed     time.Duration
+		wantCIDRs   []string
+		wantPending bool
+	}{
+		{
+			desc:      "no deleted node",
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			wantCIDRs: discovered,
+		},
+		{
+			desc: "recreated on the same instance",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: map[string]string{wellknown.InstanceIDAnnotationKey: "1234"}},
+				Spec:       v1.NodeSpec{ProviderID: providerID, PodCIDR: "10.0.1.0/24", PodCIDRs: []string{"10.0.1.0/24"}},
+			},
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			wantCIDRs: []string{"10.0.1.0/24"},
+		},
+		{
+			desc: "recreated on a new instance",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: map[string]string{wellknown.InstanceIDAnnotationKey: "1111"}},
+				Spec:       v1.NodeSpec{ProviderID: providerID, PodCIDR: "10.0.1.0/24", PodCIDRs: []string{"10.0.1.0/24"}},
+			},
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			wantCIDRs: discovered,
+		},
+		{
+			desc: "other providerID",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-c/node0", PodCIDR: "10.0.1.0/24", PodCIDRs: []string{"10.0.1.0/24"}},
+			},
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			wantCIDRs: discovered,
+		},
+		{
+			desc: "range no longer on the interface",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: providerID, PodCIDR: "172.16.0.0/24", PodCIDRs: []string{"172.16.0.0/24"}},
+			},
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			wantCIDRs: discovered,
+		},
+		{
+			desc: "expired",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: providerID, PodCIDR: "10.0.1.0/24", PodCIDRs: []string{"10.0.1.0/24"}},
+			},
+			node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			elapsed:   2 * priorPodCIDRsTTL,
+			wantCIDRs: discovered,
+		},
+		{
+			desc: "node with pod CIDRs",
+			deleted: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+				Spec:       v1.NodeSpec{ProviderID: providerID, PodCIDR: "10.0.1.0/24", PodCIDRs: []string{"10.0.1.0/24"}},
+			},
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: providerID, PodCIDR: "10.0.0.0/24", PodCIDRs: []string{"10.0.0.0/24"}}},
+			wantCIDRs:   discovered,
+			wantPending: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			clock := testingclock.NewFakeClock(time.Now())
+			ca := &cloudCIDRAllocator{instances: &fakeInstances{}, clock: clock}
+			if tc.deleted != nil {
+				ca.rememberPodCIDRs(tc.deleted)
+			}
+			clock.SetTime(clock.Now().Add(tc.elapsed))
+
+			got := ca.preferPriorPodCIDRs(tc.node, instance, discovered)
+			if diff := cmp.Diff(tc.wantCIDRs, got); diff != "" {
+				t.Errorf("preferPriorPodCIDRs() mismatch (-want +got):\n%s", diff)
+			}
+			if _, pending := ca.priorPodCIDRs[tc.node.Name]; pending != tc.wantPending {
+				t.Errorf("prior pod CIDRs kept = %t, want %t", pending, tc.wantPending)
+			}
+		})
+	}
+}
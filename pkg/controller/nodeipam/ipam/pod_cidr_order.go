@@ -0,0 +1,39 @@
+package ipam
+
+import (
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// canonicalPodCIDRs returns the pod CIDRs of the default network in canonical
+// form: duplicates removed, at most one CIDR per IP family, the family of the
+// primary cluster CIDR first. Entries that are not CIDRs are kept last, so
+// that parsing reports them. The order of the interfaces and alias IP ranges
+// of the instance then never changes the pod CIDRs written to the node.
+func (ca *cloudCIDRAllocator) canonicalPodCIDRs(cidrs []string) []string {
+	var v4, v6, invalid []string
+	for _, cidr := range cidrs {
+		_, ipNet, err := netutils.ParseCIDRSloppy(cidr)
+		if err != nil {
+			invalid = append(invalid, cidr)
+			continue
+		}
+		family := &v4
+		if netutils.IsIPv6CIDR(ipNet) {
+			family = &v6
+		}
+		switch {
+		case len(*family) == 0:
+			*family = append(*family, ipNet.String())
+		case (*family)[0] != ipNet.String():
+			klog.InfoS("Got more than one pod CIDR per IP family, ignoring the extra one", "cidrStrings", cidrs, "ignored", cidr)
+		}
+	}
+	canonical := make([]string, 0, len(cidrs))
+	if ca.params.IPv6PrimaryPodCIDR {
+		canonical = append(append(canonical, v6...), v4...)
+	} else {
+		canonical = append(append(canonical, v4...), v6...)
+	}
+	return append(canonical, invalid...)
+}
@@ -0,0 +1,60 @@
+package ipam
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalPodCIDRs(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		cidrs       []string
+		ipv6Primary bool
+		want        []string
+	}{
+		{
+			desc:  "v4 only",
+			cidrs: []string{"10.10.10.0/24"},
+			want:  []string{"10.10.10.0/24"},
+		},
+		{
+			desc:  "v6 before v4 is reordered",
+			cidrs: []string{"2001:db8::/64", "10.10.10.0/24"},
+			want:  []string{"10.10.10.0/24", "2001:db8::/64"},
+		},
+		{
+			desc:        "v6 primary",
+			cidrs:       []string{"10.10.10.0/24", "2001:db8::/64"},
+			ipv6Primary: true,
+			want:        []string{"2001:db8::/64", "10.10.10.0/24"},
+		},
+		{
+			desc:  "duplicates are removed",
+			cidrs: []string{"10.10.10.0/24", "2001:db8::/64", "10.10.10.0/24", "2001:db8:0::/64"},
+			want:  []string{"10.10.10.0/24", "2001:db8::/64"},
+		},
+		{
+			desc:  "first CIDR of each family is kept",
+			cidrs: []string{"10.10.10.0/24", "10.10.11.0/24", "2001:db8::/64"},
+			want:  []string{"10.10.10.0/24", "2001:db8::/64"},
+		},
+		{
+			desc:  "invalid CIDRs are kept last",
+			cidrs: []string{"10.10..0/24", "10.10.10.0/24"},
+			want:  []string{"10.10.10.0/24", "10.10..0/24"},
+		},
+		{
+			desc: "no CIDRs",
+			want: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ca := &cloudCIDRAllocator{params: CloudAllocatorParams{IPv6PrimaryPodCIDR: tc.ipv6Primary}}
+			if diff := cmp.Diff(tc.want, ca.canonicalPodCIDRs(tc.cidrs)); diff != "" {
+				t.Errorf("canonicalPodCIDRs() unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/pointer"
+)
+
+// The pod CIDR ownership Lease asserts which allocator owns the pod CIDRs of
+// the nodes of the cluster, so that a node IPAM controller of the cloud
+// controller manager and one of the kube-controller-manager, or two cloud
+// controller managers with different allocators, do not both allocate them.
+//
+//   - An allocator claims the Lease before it starts, and waits while the
+//     Lease is held by another allocator and renewed.
+//   - The owner renews the Lease, and stops allocating once another allocator
+//     holds the Lease or the Lease could not be renewed before it expired.
+//
+// An allocator which stopped renewing the Lease, e.g. because it was turned
+// off, hands over the ownership once the Lease expires. A stopped allocator
+// waits to own the pod CIDRs again, and then resumes allocating them.
+//
+// The node IPAM controller of the kube-controller-manager does not claim the
+// Lease. The tooling enabling it, i.e. running the kube-controller-manager
+// with --allocate-node-cidrs, claims the pod CIDRs on its behalf with a Lease
+// held by PodCIDROwnerKubeControllerManager without a duration, which never
+// expires, and deletes the Lease when it turns the controller off.
+const (
+	// PodCIDROwnershipLeaseNamespace is the namespace of the pod CIDR
+	// ownership Lease.
+	PodCIDROwnershipLeaseNamespace = metav1.NamespaceSystem
+
+	// PodCIDROwnerKubeControllerManager is the holder identity of the pod
+	// CIDR ownership Lease claimed for the kube-controller-manager.
+	PodCIDROwnerKubeControllerManager = "kube-controller-manager"
+
+	podCIDROwnershipLeaseDuration = 60 * time.Second
+	podCIDROwnershipRenewPeriod   = 20 * time.Second
+)
+
+// PodCIDROwnerIdentity returns the holder identity of the pod CIDR ownership
+// Lease of the allocators of the given type of the cloud controller manager.
+func PodCIDROwnerIdentity(allocatorType CIDRAllocatorType) string {
+	return "cloud-controller-manager/" + string(allocatorType)
+}
+
+// PodCIDROwnership claims the pod CIDR ownership Lease for an allocator.
+type PodCIDROwnership struct {
+	client   clientset.Interface
+	identity string
+	// clock is the real clock unless replaced by tests.
+	clock clock.Clock
+}
+
+// NewPodCIDROwnership returns the PodCIDROwnership of the allocator of the
+// given holder identity, see PodCIDROwnerIdentity.
+func NewPodCIDROwnership(client clientset.Interface, identity string) *PodCIDROwnership {
+	return &PodCIDROwnership{client: client, identity: identity, clock: clock.RealClock{}}
+}
+
+// Acquire blocks until the allocator owns the pod CIDRs, and returns false if
+// stop is closed first. Once they are owned, the ownership is renewed until
+// stop is closed, and the returned channel is closed once the allocator must
+// stop allocating, i.e. stop is closed or the ownership is lost.
+func (o *PodCIDROwnership) Acquire(stop <-chan struct{}) (<-chan struct{}, bool) {
+	for {
+		holder, err := o.tryAcquire()
+		switch {
+		case err != nil:
+			klog.Errorf("Failed to acquire the pod CIDR ownership: %v", err)
+		case holder != o.identity:
+			klog.Errorf("Not allocating pod CIDRs: they are owned by allocator %q, see Lease %s/%s", holder, PodCIDROwnershipLeaseNamespace, wellknown.PodCIDROwnershipLeaseName)
+		default:
+			klog.Infof("Acquired the pod CIDR ownership as %q", o.identity)
+			owned := make(chan struct{})
+			go o.renew(stop, owned)
+			return owned, true
+		}
+		select {
+		case <-stop:
+			return nil, false
+		case <-o.clock.After(podCIDROwnershipRenewPeriod):
+		}
+	}
+}
+
+// renew renews the ownership every podCIDROwnershipRenewPeriod until stop is
+// closed or the ownership is lost, and then closes owned.
+func (o *PodCIDROwnership) renew(stop <-chan struct{}, owned chan<- struct{}) {
+	defer close(owned)
+	renewed := o.clock.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-o.clock.After(podCIDROwnershipRenewPeriod):
+		}
+		holder, err := o.tryAcquire()
+		switch {
+		case err != nil:
+			if o.clock.Since(renewed) < podCIDROwnershipLeaseDuration {
+				klog.Warningf("Failed to renew the pod CIDR ownership: %v", err)
+				continue
+			}
+			klog.Errorf("Stopped allocating pod CIDRs: the pod CIDR ownership expired: %v", err)
+			return
+		case holder != o.identity:
+			klog.Errorf("Stopped allocating pod CIDRs: they are owned by allocator %q, see Lease %s/%s", holder, PodCIDROwnershipLeaseNamespace, wellknown.PodCIDROwnershipLeaseName)
+			return
+		}
+		renewed = o.clock.Now()
+	}
+}
+
+// tryAcquire claims the pod CIDR ownership Lease unless it is held by another
+// allocator and not expired, and returns the identity of its holder.
+func (o *PodCIDROwnership) tryAcquire() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leases := o.client.CoordinationV1().Leases(PodCIDROwnershipLeaseNamespace)
+	now := metav1.NewMicroTime(o.clock.Now())
+	durationSeconds := int32(podCIDROwnershipLeaseDuration / time.Second)
+	lease, err := leases.Get(ctx, wellknown.PodCIDROwnershipLeaseName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		identity := o.identity
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      wellknown.PodCIDROwnershipLeaseName,
+				Namespace: PodCIDROwnershipLeaseNamespace,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to create the pod CIDR ownership lease: %v", err)
+		}
+		return o.identity, nil
+	case err != nil:
+		return "", fmt.Errorf("failed to get the pod CIDR ownership lease: %v", err)
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != o.identity && holder != "" && !leaseExpired(lease, now.Time) {
+		return holder, nil
+	}
+	lease = lease.DeepCopy()
+	if holder != o.identity {
+		identity := o.identity
+		lease.Spec.HolderIdentity = &identity
+		lease.Spec.AcquireTime = &now
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions += *lease.Spec.LeaseTransitions
+		}
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to update the pod CIDR ownership lease: %v", err)
+	}
+	return o.identity, nil
+}
+
+// leaseExpired returns true if the holder of the Lease did not renew it in
+// time. The Lease claimed for the kube-controller-manager without a duration
+// does not expire.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.LeaseDurationSeconds == nil && pointer.StringDeref(lease.Spec.HolderIdentity, "") == PodCIDROwnerKubeControllerManager {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
@@ -0,0 +1,164 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+)
+
+const otherPodCIDROwner = "kube-controller-manager/RangeAllocator"
+
+func podCIDROwnershipLease(holder string, renewTime time.Time, transitions int32) *coordinationv1.Lease {
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: wellknown.PodCIDROwnershipLeaseName, Namespace: PodCIDROwnershipLeaseNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       pointer.String(holder),
+			LeaseDurationSeconds: pointer.Int32(60),
+			RenewTime:            &renew,
+			LeaseTransitions:     pointer.Int32(transitions),
+		},
+	}
+}
+
+func TestPodCIDROwnershipTryAcquire(t *testing.T) {
+	identity := PodCIDROwnerIdentity(CloudAllocatorType)
+	now := time.Unix(1000, 0)
+	testCases := []struct {
+		desc            string
+		lease           *coordinationv1.Lease
+		wantHolder      string
+		wantTransitions int32
+	}{
+		{
+			desc:       "no lease",
+			wantHolder: identity,
+		},
+		{
+			desc:       "renewed",
+			lease:      podCIDROwnershipLease(identity, now.Add(-30*time.Second), 0),
+			wantHolder: identity,
+		},
+		{
+			desc:            "owned by another allocator",
+			lease:           podCIDROwnershipLease(otherPodCIDROwner, now.Add(-30*time.Second), 2),
+			wantHolder:      otherPodCIDROwner,
+			wantTransitions: 2,
+		},
+		{
+			desc:            "expired ownership of another allocator",
+			lease:           podCIDROwnershipLease(otherPodCIDROwner, now.Add(-2*time.Minute), 2),
+			wantHolder:      identity,
+			wantTransitions: 3,
+		},
+		{
+			desc: "claimed for the kube-controller-manager",
+			lease: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: wellknown.PodCIDROwnershipLeaseName, Namespace: PodCIDROwnershipLeaseNamespace},
+				Spec:       coordinationv1.LeaseSpec{HolderIdentity: pointer.String(PodCIDROwnerKubeControllerManager)},
+			},
+			wantHolder: PodCIDROwnerKubeControllerManager,
+		},
+		{
+			desc:            "released",
+			lease:           podCIDROwnershipLease("", now, 2),
+			wantHolder:      identity,
+			wantTransitions: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tc.lease != nil {
+				client = fake.NewSimpleClientset(tc.lease)
+			}
+			o := NewPodCIDROwnership(client, identity)
+			o.clock = testingclock.NewFakeClock(now)
+			holder, err := o.tryAcquire()
+			if err != nil {
+				t.Fatalf("tryAcquire() returned err %v", err)
+			}
+			if holder != tc.wantHolder {
+				t.Errorf("tryAcquire() = %q, want %q", holder, tc.wantHolder)
+			}
+			lease, err := client.CoordinationV1().Leases(PodCIDROwnershipLeaseNamespace).Get(context.TODO(), wellknown.PodCIDROwnershipLeaseName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the lease: %v", err)
+			}
+			if got := *lease.Spec.HolderIdentity; got != tc.wantHolder {
+				t.Errorf("lease holder = %q, want %q", got, tc.wantHolder)
+			}
+			if tc.wantHolder == identity && !lease.Spec.RenewTime.Time.Equal(now) {
+				t.Errorf("lease renew time = %v, want %v", lease.Spec.RenewTime, now)
+			}
+			if got := pointer.Int32Deref(lease.Spec.LeaseTransitions, 0); got != tc.wantTransitions {
+				t.Errorf("lease transitions = %d, want %d", got, tc.wantTransitions)
+			}
+		})
+	}
+}
+
+func TestPodCIDROwnershipAcquire(t *testing.T) {
+	identity := PodCIDROwnerIdentity(CloudAllocatorType)
+	fakeClock := testingclock.NewFakeClock(time.Unix(1000, 0))
+	client := fake.NewSimpleClientset(podCIDROwnershipLease(otherPodCIDROwner, fakeClock.Now(), 0))
+	o := NewPodCIDROwnership(client, identity)
+	o.clock = fakeClock
+	stop := make(chan struct{})
+	defer close(stop)
+
+	type result struct {
+		owned <-chan struct{}
+		ok    bool
+	}
+	acquired := make(chan result)
+	go func() {
+		owned, ok := o.Acquire(stop)
+		acquired <- result{owned, ok}
+	}()
+	// step waits for the ownership to wait on the clock and advances it.
+	step := func(d time.Duration) {
+		t.Helper()
+		if err := wait.PollImmediate(time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("the ownership did not wait on the clock")
+		}
+		fakeClock.Step(d)
+	}
+
+	// The other allocator stops renewing its lease, which expires.
+	step(podCIDROwnershipRenewPeriod)
+	step(podCIDROwnershipRenewPeriod)
+	select {
+	case <-acquired:
+		t.Fatalf("Acquire() returned while another allocator owns the pod CIDRs")
+	default:
+	}
+	step(podCIDROwnershipRenewPeriod)
+	step(podCIDROwnershipRenewPeriod)
+	res := <-acquired
+	if !res.ok {
+		t.Fatalf("Acquire() returned false, want true")
+	}
+
+	// The other allocator takes the pod CIDRs over.
+	lease := podCIDROwnershipLease(otherPodCIDROwner, fakeClock.Now(), 2)
+	if _, err := client.CoordinationV1().Leases(PodCIDROwnershipLeaseNamespace).Update(context.TODO(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the lease: %v", err)
+	}
+	step(podCIDROwnershipRenewPeriod)
+	select {
+	case <-res.owned:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("the ownership was not lost after another allocator acquired it")
+	}
+}
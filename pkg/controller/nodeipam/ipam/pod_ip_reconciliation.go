@@ -0,0 +1,102 @@
+package ipam
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+const (
+	// podIPReconciliationInterval is the interval at which the IPs of the pods
+	// are checked against the pod CIDRs of their node.
+	podIPReconciliationInterval = time.Minute
+	// maxPodIPOutsidePodCIDRsEvents is the max no. of events recorded per
+	// check, so that a drift of the whole cluster is sampled rather than
+	// flooding the API server.
+	maxPodIPOutsidePodCIDRsEvents = 10
+)
+
+// podIPsOutside returns the IPs of the pod that fall outside of the CIDRs.
+func podIPsOutside(pod *v1.Pod, cidrs []*net.IPNet) []string {
+	var outside []string
+	for _, podIP := range pod.Status.PodIPs {
+		ip := netutils.ParseIPSloppy(podIP.IP)
+		if ip == nil {
+			continue
+		}
+		contained := false
+		for _, cidr := range cidrs {
+			if cidr.Contains(ip) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			outside = append(outside, podIP.IP)
+		}
+	}
+	return outside
+}
+
+// reconcilePodIPs exports the number of running pods with an IP outside of
+// the pod CIDRs published on their node, which happens when the allocation of
+// the node is stale or its dataplane drifted from it, and records an event on
+// a sample of the pods newly found outside of them.
+func (ca *cloudCIDRAllocator) reconcilePodIPs() {
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the nodes to reconcile the pod IPs")
+		return
+	}
+	ca.lock.Lock()
+	reported := ca.podIPsOutsidePodCIDRs
+	ca.lock.Unlock()
+	outside := make(map[types.UID]bool)
+	events := 0
+	for _, node := range nodes {
+		if len(node.Spec.PodCIDRs) == 0 {
+			continue
+		}
+		cidrs, err := netutils.ParseCIDRs(node.Spec.PodCIDRs)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse the pod CIDRs of the node", "nodeName", node.Name, "podCIDRs", node.Spec.PodCIDRs)
+			continue
+		}
+		objs, err := ca.podIndexer.ByIndex(podNodeNameIndex, node.Name)
+		if err != nil {
+			klog.ErrorS(err, "Failed to get the pods of the node", "nodeName", node.Name)
+			continue
+		}
+		for _, obj := range objs {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || pod.Spec.HostNetwork || pod.Status.Phase != v1.PodRunning {
+				continue
+			}
+			ips := podIPsOutside(pod, cidrs)
+			if len(ips) == 0 {
+				continue
+			}
+			outside[pod.UID] = true
+			if reported[pod.UID] {
+				continue
+			}
+			klog.V(2).InfoS("Pod IPs are outside of the pod CIDRs of the node", "pod", klog.KObj(pod), "podIPs", ips, "nodeName", node.Name, "podCIDRs", node.Spec.PodCIDRs)
+			if events < maxPodIPOutsidePodCIDRsEvents {
+				events++
+				ca.recorder.Eventf(pod, v1.EventTypeWarning, wellknown.PodIPOutsidePodCIDRsReason, "Pod IPs %s are outside of the pod CIDRs %s of node %s", strings.Join(ips, ","), strings.Join(node.Spec.PodCIDRs, ","), node.Name)
+			}
+		}
+	}
+
+	ca.lock.Lock()
+	ca.podIPsOutsidePodCIDRs = outside
+	ca.lock.Unlock()
+	podsOutsidePodCIDRs.Set(float64(len(outside)))
+}
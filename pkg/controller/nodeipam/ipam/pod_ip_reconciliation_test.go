@@ -0,0 +1,95 @@
+package ipam
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestReconcilePodIPs(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := func(name string, podCIDRs ...string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: v1.NodeSpec{PodCIDRs: podCIDRs}}
+	}
+	pod := func(name, nodeName string, podIPs ...string) *v1.Pod {
+		p := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec:       v1.PodSpec{NodeName: nodeName},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}
+		for _, ip := range podIPs {
+			p.Status.PodIPs = append(p.Status.PodIPs, v1.PodIP{IP: ip})
+		}
+		return p
+	}
+	hostNetworkPod := pod("host", "node-a", "10.128.0.2")
+	hostNetworkPod.Spec.HostNetwork = true
+	succeededPod := pod("job", "node-a", "10.1.0.3")
+	succeededPod.Status.Phase = v1.PodSucceeded
+
+	clientSet := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(clientSet, 0)
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	for _, n := range []*v1.Node{
+		node("node-a", "10.0.0.0/24"),
+		node("node-b", "10.0.1.0/24", "2600:1900::/112"),
+		node("unallocated"),
+	} {
+		nodeInformer.Informer().GetIndexer().Add(n)
+	}
+	recorder := record.NewFakeRecorder(2 * maxPodIPOutsidePodCIDRsEvents)
+	ca := &cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: nodeInformer.Lister(),
+		recorder:   recorder,
+		params:     CloudAllocatorParams{PodIPReconciliation: true},
+	}
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := ca.trackPodCIDRUsage(podInformer); err != nil {
+		t.Fatalf("trackPodCIDRUsage() returned err %v", err)
+	}
+	for _, p := range []*v1.Pod{
+		pod("web", "node-a", "10.0.0.5"),
+		pod("stale", "node-a", "10.0.1.5"),
+		pod("dual-stack", "node-b", "10.0.1.6", "2600:1900::6"),
+		pod("drifted", "node-b", "10.0.1.7", "2600:1901::7"),
+		pod("pending", "unallocated", "10.0.2.8"),
+		hostNetworkPod,
+		succeededPod,
+	} {
+		podInformer.Informer().GetIndexer().Add(p)
+	}
+
+	check := func(step string, wantOutside float64, wantEvents int) {
+		t.Helper()
+		ca.reconcilePodIPs()
+		if got, _ := testutil.GetGaugeMetricValue(podsOutsidePodCIDRs); got != wantOutside {
+			t.Errorf("%s: got %v pods outside of the pod CIDRs, want %v", step, got, wantOutside)
+		}
+		if got := len(recorder.Events); got != wantEvents {
+			t.Errorf("%s: recorded %d events, want %d", step, got, wantEvents)
+		}
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+	}
+	check("first check", 2, 2)
+	// The pods already reported get no new event.
+	check("second check", 2, 0)
+
+	podInformer.Informer().GetIndexer().Delete(pod("stale", "node-a"))
+	check("stale pod deleted", 1, 0)
+
+	// The events are capped per check.
+	for i := 0; i < maxPodIPOutsidePodCIDRsEvents+5; i++ {
+		podInformer.Informer().GetIndexer().Add(pod(fmt.Sprintf("moved-%d", i), "node-a", "10.0.9.1"))
+	}
+	check("many pods outside", float64(maxPodIPOutsidePodCIDRsEvents+6), maxPodIPOutsidePodCIDRsEvents)
+}
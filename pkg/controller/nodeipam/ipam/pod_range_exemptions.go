@@ -0,0 +1,97 @@
+package ipam
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// PodRangeExemption selects nodes whose instance has no pod alias IP range on
+// purpose, e.g. control-plane or one-off nodes running host network pods
+// only. The allocator skips them with a warning rather than retrying them and
+// counting them as allocation errors. A node is selected if it matches both
+// the selector and the taint key.
+type PodRangeExemption struct {
+	// Selector matches the labels of the nodes. Nil matches all the nodes.
+	Selector labels.Selector
+	// TaintKey is the key of a taint of the nodes. Empty matches all the
+	// nodes.
+	TaintKey string
+}
+
+// NewPodRangeExemption returns the exemption of the nodes matching the label
+// selector and having a taint with the key, at least one of which is set.
+func NewPodRangeExemption(labelSelector, taintKey string) (PodRangeExemption, error) {
+	if labelSelector == "" && taintKey == "" {
+		return PodRangeExemption{}, errors.New("pod range exemption with neither a label selector nor a taint key")
+	}
+	e := PodRangeExemption{TaintKey: taintKey}
+	if labelSelector != "" {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return PodRangeExemption{}, fmt.Errorf("invalid label selector %q of pod range exemption: %v", labelSelector, err)
+		}
+		e.Selector = selector
+	}
+	return e, nil
+}
+
+// Matches returns true if the exemption selects the node.
+func (e PodRangeExemption) Matches(node *v1.Node) bool {
+	if e.Selector != nil && !e.Selector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if e.TaintKey == "" {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == e.TaintKey {
+			return true
+		}
+	}
+	return false
+}
+
+// skipPodRangeExemptNode returns true if the node, whose instance has no pod
+// alias IP range, is selected by a PodRangeExemption, in which case it is not
+// failed. The warning and the event are recorded once per node.
+func (ca *cloudCIDRAllocator) skipPodRangeExemptNode(node *v1.Node) bool {
+	exempt := false
+	for _, e := range ca.params.PodRangeExemptions {
+		if e.Matches(node) {
+			exempt = true
+			break
+		}
+	}
+	if !exempt {
+		return false
+	}
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.podRangeExemptNodes == nil {
+		ca.podRangeExemptNodes = make(map[string]bool)
+	}
+	if ca.podRangeExemptNodes[node.Name] {
+		return true
+	}
+	ca.podRangeExemptNodes[node.Name] = true
+	skippedNodes.WithLabelValues(skippedNodePodRangeExempt).Set(float64(len(ca.podRangeExemptNodes)))
+	klog.Warningf("Skipping node %q: its instance has no pod alias IP range and the node is exempted from having one", node.Name)
+	ca.recorder.Event(node, v1.EventTypeWarning, wellknown.PodRangeExemptReason, "The instance of the node has no pod alias IP range, its pod CIDRs are not allocated")
+	return true
+}
+
+// forgetPodRangeExemptNode removes a deleted node from the skipped nodes.
+func (ca *cloudCIDRAllocator) forgetPodRangeExemptNode(nodeName string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if !ca.podRangeExemptNodes[nodeName] {
+		return
+	}
+	delete(ca.podRangeExemptNodes, nodeName)
+	skippedNodes.WithLabelValues(skippedNodePodRangeExempt).Set(float64(len(ca.podRangeExemptNodes)))
+}
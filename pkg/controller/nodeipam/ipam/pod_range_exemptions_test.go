@@ -0,0 +1,134 @@
+package ipam
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestPodRangeExemptionMatches(t *testing.T) {
+	controlPlane := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"node-role.kubernetes.io/control-plane": ""}},
+		Spec:       v1.NodeSpec{Taints: []v1.Taint{{Key: "node-role.kubernetes.io/control-plane", Effect: v1.TaintEffectNoSchedule}}},
+	}
+	worker := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"cloud.google.com/gke-nodepool": "default-pool"}},
+	}
+	testCases := []struct {
+		desc          string
+		labelSelector string
+		taintKey      string
+		wantErr       bool
+		wantMatches   []bool
+	}{
+		{
+			desc:          "label selector",
+			labelSelector: "node-role.kubernetes.io/control-plane",
+			wantMatches:   []bool{true, false},
+		},
+		{
+			desc:        "taint key",
+			taintKey:    "node-role.kubernetes.io/control-plane",
+			wantMatches: []bool{true, false},
+		},
+		{
+			desc:          "label selector and taint key",
+			labelSelector: "cloud.google.com/gke-nodepool=default-pool",
+			taintKey:      "node-role.kubernetes.io/control-plane",
+			wantMatches:   []bool{false, false},
+		},
+		{
+			desc:    "neither label selector nor taint key",
+			wantErr: true,
+		},
+		{
+			desc:          "invalid label selector",
+			labelSelector: "a=b=c",
+			wantErr:       true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e, err := NewPodRangeExemption(tc.labelSelector, tc.taintKey)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("NewPodRangeExemption() returned err %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			for i, node := range []*v1.Node{controlPlane, worker} {
+				if got := e.Matches(node); got != tc.wantMatches[i] {
+					t.Errorf("Matches(node %d) = %v, want %v", i, got, tc.wantMatches[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPodRangeExemptNodesAreSkipped(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	exemption, err := NewPodRangeExemption("node-role.kubernetes.io/control-plane", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlPlane := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "control-plane", Labels: map[string]string{"node-role.kubernetes.io/control-plane": ""}},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/control-plane"},
+	}
+	worker := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker"},
+		Spec:       v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/worker"},
+	}
+	clientSet := fake.NewSimpleClientset(controlPlane, worker)
+	sharedInformer := informers.NewSharedInformerFactory(clientSet, 1*time.Hour)
+	for _, node := range []*v1.Node{controlPlane, worker} {
+		if err := sharedInformer.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder := record.NewFakeRecorder(10)
+	params := DefaultCloudAllocatorParams()
+	params.EnableMultiNetworking = false
+	params.PodRangeExemptions = []PodRangeExemption{exemption}
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		nodeLister: sharedInformer.Core().V1().Nodes().Lister(),
+		recorder:   recorder,
+		instances:  &fakeInstances{instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{interfaces(defaultVPCName, defaultVPCSubnetName, "10.0.0.2", nil)}}},
+		params:     params,
+	})
+	gauge := skippedNodes.WithLabelValues(skippedNodePodRangeExempt)
+
+	for i := 0; i < 2; i++ {
+		if err := ca.updateCIDRAllocation(controlPlane.Name); err != nil {
+			t.Fatalf("updateCIDRAllocation() returned err %v for an exempted node", err)
+		}
+	}
+	if got := len(recorder.Events); got != 1 {
+		t.Errorf("recorded %d events, want 1", got)
+	}
+	<-recorder.Events
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 1 {
+		t.Errorf("skipped nodes = %v, want 1", got)
+	}
+	if actions := clientSet.Actions(); len(actions) != 0 {
+		t.Errorf("got API calls %v for an exempted node, want none", actions)
+	}
+
+	if err := ca.updateCIDRAllocation(worker.Name); !errors.Is(err, ErrNoMatchingRange) {
+		t.Errorf("updateCIDRAllocation() returned err %v for a node not exempted, want %v", err, ErrNoMatchingRange)
+	}
+
+	ca.ReleaseCIDR(controlPlane)
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 0 {
+		t.Errorf("skipped nodes after deletion = %v, want 0", got)
+	}
+}
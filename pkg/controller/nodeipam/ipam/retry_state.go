@@ -0,0 +1,73 @@
+package ipam
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// persistedRetries returns the retry count recorded on the node, or 0. The
+// retry count, and thus the backoff, is restored from it when the node is
+// queued again, so that a restart of the controller does not reset the
+// backoff of failing nodes.
+func persistedRetries(node *v1.Node) int {
+	value, ok := node.Annotations[wellknown.CIDRAllocationRetriesAnnotationKey]
+	if !ok {
+		return 0
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		klog.Warningf("Ignoring invalid %s annotation %q of node %s", wellknown.CIDRAllocationRetriesAnnotationKey, value, node.Name)
+		return 0
+	}
+	return retries
+}
+
+// restoredRetries returns the retry count of a node to queue from its
+// annotation. Nodes that exhausted their retries keep one, so that they are
+// still updated, at the maximum backoff, when they change.
+func (ca *cloudCIDRAllocator) restoredRetries(node *v1.Node) int {
+	retries := persistedRetries(node)
+	if max := ca.params.UpdateMaxRetries - 1; retries > max {
+		retries = max
+	}
+	if retries < 0 {
+		return 0
+	}
+	return retries
+}
+
+// persistRetries records the retry count of the node in its annotation.
+// Errors are logged, the count is still tracked in the work queue.
+func (ca *cloudCIDRAllocator) persistRetries(nodeName string, retries int) {
+	if err := ca.patchRetriesAnnotation(nodeName, strconv.Quote(strconv.Itoa(retries))); err != nil {
+		klog.ErrorS(err, "Failed to record the CIDR allocation retries of the node", "nodeName", nodeName, "retries", retries)
+	}
+}
+
+// clearPersistedRetries removes the retry count annotation of the node after a
+// successful update.
+func (ca *cloudCIDRAllocator) clearPersistedRetries(nodeName string) {
+	node, err := ca.nodeLister.Get(nodeName)
+	if err != nil {
+		return
+	}
+	if _, ok := node.Annotations[wellknown.CIDRAllocationRetriesAnnotationKey]; !ok {
+		return
+	}
+	if err := ca.patchRetriesAnnotation(nodeName, "null"); err != nil {
+		klog.ErrorS(err, "Failed to clear the CIDR allocation retries of the node", "nodeName", nodeName)
+	}
+}
+
+// patchRetriesAnnotation sets the retry count annotation of the node to the
+// JSON value.
+func (ca *cloudCIDRAllocator) patchRetriesAnnotation(nodeName, value string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%s}}}`, wellknown.CIDRAllocationRetriesAnnotationKey, value))
+	_, err := ca.patchNode(nodeName, types.MergePatchType, patch)
+	return err
+}
@@ -0,0 +1,139 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPersistedRetries(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		annotations map[string]string
+		want        int
+	}{
+		{
+			desc: "no annotation",
+		},
+		{
+			desc:        "retry count",
+			annotations: map[string]string{wellknown.CIDRAllocationRetriesAnnotationKey: "3"},
+			want:        3,
+		},
+		{
+			desc:        "invalid count",
+			annotations: map[string]string{wellknown.CIDRAllocationRetriesAnnotationKey: "three"},
+		},
+		{
+			desc:        "negative count",
+			annotations: map[string]string{wellknown.CIDRAllocationRetriesAnnotationKey: "-1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tc.annotations}}
+			if got := persistedRetries(node); got != tc.want {
+				t.Errorf("persistedRetries() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetriesSurviveRestart(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		retries     string
+		wantRetries int
+		wantDelay   bool
+	}{
+		{
+			desc: "node without failures is queued at once",
+		},
+		{
+			desc:        "failing node resumes its backoff",
+			retries:     "3",
+			wantRetries: 3,
+			wantDelay:   true,
+		},
+		{
+			desc:        "node that exhausted its retries keeps one",
+			retries:     "12",
+			wantRetries: 9,
+			wantDelay:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}, Spec: v1.NodeSpec{ProviderID: "gce://test-project/us-central1-b/node0"}}
+			if tc.retries != "" {
+				node.Annotations = map[string]string{wellknown.CIDRAllocationRetriesAnnotationKey: tc.retries}
+			}
+			clientSet := fake.NewSimpleClientset(node)
+			nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+			if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+				t.Fatalf("error in test setup, could not add node: %v", err)
+			}
+			params := DefaultCloudAllocatorParams()
+			params.UpdateRetryTimeout = 10 * time.Millisecond
+			params.MaxUpdateRetryTimeout = 100 * time.Millisecond
+			fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+			ca := withQueue(&cloudCIDRAllocator{
+				client:     clientSet,
+				instances:  &fakeInstances{err: errors.New("compute API unavailable")},
+				clock:      fakeClock,
+				nodeLister: nodeInformer.Lister(),
+				recorder:   record.NewFakeRecorder(10),
+				params:     params,
+			})
+
+			if err := ca.AllocateOrOccupyCIDR(node); err != nil {
+				t.Fatalf("AllocateOrOccupyCIDR() returned err %v", err)
+			}
+			if got := len(ca.queue.Updates()); (got == 0) != tc.wantDelay {
+				t.Errorf("got %d queued nodes right after AllocateOrOccupyCIDR(), want delay %v", got, tc.wantDelay)
+			}
+			// The backoff is at most 3/2 of the maximum timeout.
+			fakeClock.Step(3 * params.MaxUpdateRetryTimeout / 2)
+			select {
+			case <-ca.queue.Updates():
+			default:
+				t.Fatalf("node was not queued")
+			}
+			if got := ca.failureCount(node.Name) - 1; got != tc.wantRetries {
+				t.Errorf("restored %d retries, want %d", got, tc.wantRetries)
+			}
+
+			// A failed update records the new retry count.
+			ca.queue.Process(node.Name)
+			got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the node: %v", err)
+			}
+			if persistedRetries(got) != tc.wantRetries+1 {
+				t.Errorf("recorded retries %q, want %d", got.Annotations[wellknown.CIDRAllocationRetriesAnnotationKey], tc.wantRetries+1)
+			}
+
+			// A successful update clears it.
+			if err := nodeInformer.Informer().GetStore().Update(got); err != nil {
+				t.Fatalf("failed to update the node: %v", err)
+			}
+			ca.clearPersistedRetries(node.Name)
+			got, err = clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the node: %v", err)
+			}
+			if value, ok := got.Annotations[wellknown.CIDRAllocationRetriesAnnotationKey]; ok {
+				t.Errorf("retries annotation %q not cleared", value)
+			}
+		})
+	}
+}
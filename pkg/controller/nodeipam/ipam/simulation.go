@@ -0,0 +1,100 @@
+package ipam
+
+import (
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networklisters "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1"
+	alphanetworklisters "k8s.io/cloud-provider-gcp/crd/client/network/listers/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/util/networkannotations"
+)
+
+// SimulatedAllocation is the multi-network allocation of a node computed by
+// SimulateMultiNetworkAllocation. Its networks are sorted by name.
+type SimulatedAllocation struct {
+	// PodCIDRs are the pod CIDRs of the default network.
+	PodCIDRs []string
+	// NorthInterfaces is the value of the north-interfaces annotation.
+	NorthInterfaces networkv1.NorthInterfacesAnnotation
+	// AdditionalNodeNetworks is the value of the networks annotation.
+	AdditionalNodeNetworks networkv1.MultiNetworkAnnotation
+}
+
+// SimulateMultiNetworkAllocation computes the multi-network allocation of a
+// node from the interfaces of its instance, the Networks and the
+// GKENetworkParamSets given instead of those of the cluster. Nothing is read
+// from or written to the cluster, so that proposed network changes can be
+// evaluated before they are applied.
+func SimulateMultiNetworkAllocation(node *v1.Node, interfaces []*compute.NetworkInterface, networks []*networkv1.Network, params []*networkv1alpha1.GKENetworkParamSet) (*SimulatedAllocation, error) {
+	networkIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, network := range networks {
+		if err := networkIndexer.Add(network); err != nil {
+			return nil, fmt.Errorf("failed to add network %s: %v", network.Name, err)
+		}
+	}
+	gnpIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, gnp := range params {
+		if err := gnpIndexer.Add(gnp); err != nil {
+			return nil, fmt.Errorf("failed to add GKENetworkParamSet %s: %v", gnp.Name, err)
+		}
+	}
+	ca := &cloudCIDRAllocator{
+		networksLister: networklisters.NewNetworkLister(networkIndexer),
+		gnpLister:      alphanetworklisters.NewGKENetworkParamSetLister(gnpIndexer),
+		recorder:       &record.FakeRecorder{},
+		instances:      simulatedInstances{},
+	}
+	defaultNwCIDRs, northInterfaces, additionalNodeNetworks, _, err := ca.PerformMultiNetworkCIDRAllocation(node, interfaces)
+	if err != nil {
+		return nil, err
+	}
+	allocation := multiNetworkAllocation{
+		DefaultNwCIDRs:         defaultNwCIDRs,
+		NorthInterfaces:        northInterfaces,
+		AdditionalNodeNetworks: additionalNodeNetworks,
+	}.normalized()
+	return &SimulatedAllocation{
+		PodCIDRs:               allocation.DefaultNwCIDRs,
+		NorthInterfaces:        allocation.NorthInterfaces,
+		AdditionalNodeNetworks: allocation.AdditionalNodeNetworks,
+	}, nil
+}
+
+// simulatedInstances never fetches instances: simulations are given the
+// interfaces of the nodes.
+type simulatedInstances struct{}
+
+func (simulatedInstances) InstanceByProviderID(providerID string) (*compute.Instance, error) {
+	return nil, fmt.Errorf("instances are not fetched by simulations")
+}
+
+// PublishedAllocation returns the multi-network allocation published on the
+// node, in the form returned by SimulateMultiNetworkAllocation.
+func PublishedAllocation(node *v1.Node) (*SimulatedAllocation, error) {
+	allocation := multiNetworkAllocation{DefaultNwCIDRs: node.Spec.PodCIDRs}
+	northInterfaces, ok, err := networkannotations.ParseNodeNorthInterfaces(node.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		allocation.NorthInterfaces = northInterfaces
+	}
+	additionalNodeNetworks, ok, err := networkannotations.ParseNodeMultiNetwork(node.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		allocation.AdditionalNodeNetworks = additionalNodeNetworks
+	}
+	allocation = allocation.normalized()
+	return &SimulatedAllocation{
+		PodCIDRs:               allocation.DefaultNwCIDRs,
+		NorthInterfaces:        allocation.NorthInterfaces,
+		AdditionalNodeNetworks: allocation.AdditionalNodeNetworks,
+	}, nil
+}
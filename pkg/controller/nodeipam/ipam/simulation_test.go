@@ -0,0 +1,85 @@
+package ipam
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"k8s.io/cloud-provider-gcp/pkg/controller/nodeipam/ipam/test"
+)
+
+func TestSimulateMultiNetworkAllocation(t *testing.T) {
+	fixture, err := test.LoadFixture(filepath.Join("testdata", "fixtures", "two-additional-networks.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	var networks []*networkv1.Network
+	for i := range fixture.Networks {
+		networks = append(networks, &fixture.Networks[i])
+	}
+	nodeFixture := fixture.Nodes[0]
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixture.Name}}
+
+	testCases := []struct {
+		desc   string
+		modify func(gnp *networkv1alpha1.GKENetworkParamSet)
+		want   *SimulatedAllocation
+	}{
+		{
+			desc: "current params",
+			want: &SimulatedAllocation{
+				PodCIDRs:               nodeFixture.PodCIDRs,
+				NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "blue", IpAddress: "10.1.0.2"}, {Network: "red", IpAddress: "10.0.0.2"}},
+				AdditionalNodeNetworks: nodeFixture.AdditionalNodeNetworks,
+			},
+		},
+		{
+			desc: "range of the node removed",
+			modify: func(gnp *networkv1alpha1.GKENetworkParamSet) {
+				if gnp.Name == "red-params" {
+					gnp.Spec.PodIPv4Ranges.RangeNames = []string{"red-pods-a"}
+				}
+			},
+			want: &SimulatedAllocation{
+				PodCIDRs:        nodeFixture.PodCIDRs,
+				NorthInterfaces: networkv1.NorthInterfacesAnnotation{{Network: "blue", IpAddress: "10.1.0.2"}},
+			},
+		},
+		{
+			desc: "other range removed",
+			modify: func(gnp *networkv1alpha1.GKENetworkParamSet) {
+				if gnp.Name == "red-params" {
+					gnp.Spec.PodIPv4Ranges.RangeNames = []string{"red-pods-b"}
+				}
+			},
+			want: &SimulatedAllocation{
+				PodCIDRs:               nodeFixture.PodCIDRs,
+				NorthInterfaces:        networkv1.NorthInterfacesAnnotation{{Network: "blue", IpAddress: "10.1.0.2"}, {Network: "red", IpAddress: "10.0.0.2"}},
+				AdditionalNodeNetworks: nodeFixture.AdditionalNodeNetworks,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var params []*networkv1alpha1.GKENetworkParamSet
+			for i := range fixture.GKENetworkParamSets {
+				gnp := fixture.GKENetworkParamSets[i].DeepCopy()
+				if tc.modify != nil {
+					tc.modify(gnp)
+				}
+				params = append(params, gnp)
+			}
+			got, err := SimulateMultiNetworkAllocation(node, nodeFixture.Interfaces, networks, params)
+			if err != nil {
+				t.Fatalf("SimulateMultiNetworkAllocation() returned err %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SimulateMultiNetworkAllocation() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+//go:build soak && !providerless
+// +build soak,!providerless
+
+package ipam
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// The soak test runs the allocator against fakes which fail, conflict and
+// respond slowly according to a seeded schedule, and asserts the invariants
+// the allocator must keep under faults. It is only built with the soak tag:
+//
+//	go test -tags soak -run TestSoak ./pkg/controller/nodeipam/ipam -soak.seed=42
+//
+// A failure is reproduced by running it again with the seed it logs.
+var (
+	soakSeed      = flag.Int64("soak.seed", 0, "seed of the fault schedule of the soak test, a random seed is used if 0")
+	soakRounds    = flag.Int("soak.rounds", 50, "number of rounds in which every node is reconciled under faults")
+	soakNodes     = flag.Int("soak.nodes", 20, "number of nodes of the soak test")
+	soakFaultRate = flag.Float64("soak.fault-rate", 0.3, "probability that a call to a fake fails, conflicts or is slow")
+	soakMaxDelay  = flag.Duration("soak.max-delay", 5*time.Millisecond, "maximum delay of slow responses")
+)
+
+// soakOwnerAnnotationKey is set on the nodes by another controller, the
+// allocator must never remove it.
+const soakOwnerAnnotationKey = "example.com/owner"
+
+// faultSchedule decides from a seeded source which calls to the fakes fail,
+// conflict or are slow.
+type faultSchedule struct {
+	lock     sync.Mutex
+	rand     *rand.Rand
+	rate     float64
+	maxDelay time.Duration
+	enabled  bool
+	injected map[string]int
+}
+
+type fault int
+
+const (
+	noFault fault = iota
+	apiErrorFault
+	conflictFault
+	slowFault
+)
+
+func (f fault) String() string {
+	return [...]string{"none", "error", "conflict", "slow"}[f]
+}
+
+func newFaultSchedule(seed int64, rate float64, maxDelay time.Duration) *faultSchedule {
+	return &faultSchedule{
+		rand:     rand.New(rand.NewSource(seed)),
+		rate:     rate,
+		maxDelay: maxDelay,
+		enabled:  true,
+		injected: map[string]int{},
+	}
+}
+
+// next returns the fault injected in the next call to the fake, and the
+// delay of slow calls.
+func (s *faultSchedule) next(call string) (fault, time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.enabled || s.rand.Float64() >= s.rate {
+		return noFault, 0
+	}
+	f := fault(1 + s.rand.Intn(3))
+	s.injected[fmt.Sprintf("%s/%s", call, f)]++
+	if f != slowFault || s.maxDelay <= 0 {
+		return f, 0
+	}
+	return f, time.Duration(s.rand.Int63n(int64(s.maxDelay)))
+}
+
+// lags returns whether the watch of the nodes lags behind, leaving the
+// informer stale.
+func (s *faultSchedule) lags() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.enabled || s.rand.Float64() >= s.rate {
+		return false
+	}
+	s.injected["watch nodes/lag"]++
+	return true
+}
+
+func (s *faultSchedule) setEnabled(enabled bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.enabled = enabled
+}
+
+func (s *faultSchedule) shuffle(names []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rand.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+}
+
+// reactor injects the faults of the schedule in the writes to the nodes.
+func (s *faultSchedule) reactor(action k8stesting.Action) (bool, runtime.Object, error) {
+	f, delay := s.next(action.GetVerb() + " " + action.GetResource().Resource)
+	switch f {
+	case apiErrorFault:
+		return true, nil, apierrors.NewInternalError(fmt.Errorf("injected error"))
+	case conflictFault:
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "nodes"}, "", fmt.Errorf("injected conflict"))
+	case slowFault:
+		time.Sleep(delay)
+	}
+	return false, nil, nil
+}
+
+// soakInstances serves the instances of the nodes, injecting the faults of
+// the schedule.
+type soakInstances struct {
+	fakeInstances
+	schedule  *faultSchedule
+	instances map[string]*compute.Instance
+}
+
+func (f *soakInstances) InstanceByProviderID(providerID string) (*compute.Instance, error) {
+	switch fault, delay := f.schedule.next("get instances"); fault {
+	case apiErrorFault:
+		return nil, &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "injected error"}
+	case conflictFault:
+		return nil, &googleapi.Error{Code: http.StatusTooManyRequests, Message: "injected rate limit"}
+	case slowFault:
+		time.Sleep(delay)
+	}
+	instance, ok := f.instances[providerID]
+	if !ok {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "instance not found"}
+	}
+	return instance, nil
+}
+
+func soakInstance(i int) *compute.Instance {
+	return &compute.Instance{
+		Id: uint64(1000 + i),
+		NetworkInterfaces: []*compute.NetworkInterface{
+			interfaces(defaultVPCName, defaultVPCSubnetName, fmt.Sprintf("80.1.%d.%d", i/250, i%250+1), []*compute.AliasIpRange{
+				{IpCidrRange: fmt.Sprintf("10.%d.%d.0/24", 100+i/256, i%256), SubnetworkRangeName: defaultSecondaryRangeA},
+			}),
+			interfaces(redVPCName, redVPCSubnetName, fmt.Sprintf("10.1.%d.%d", i/250, i%250+1), []*compute.AliasIpRange{
+				{IpCidrRange: fmt.Sprintf("172.%d.%d.0/24", 16+i/256, i%256), SubnetworkRangeName: redSecondaryRangeB},
+			}),
+			interfaces(blueVPCName, blueVPCSubnetName, fmt.Sprintf("20.1.%d.%d", i/250, i%250+1), nil),
+		},
+	}
+}
+
+// soakState is what the invariants are checked against: the pod CIDRs and
+// the multi-network annotations seen on a node.
+type soakState struct {
+	podCIDRs    []string
+	annotations map[string]string
+}
+
+func TestSoak(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	seed := *soakSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("Soak test seed: %d (rerun with -soak.seed=%d)", seed, seed)
+	schedule := newFaultSchedule(seed, *soakFaultRate, *soakMaxDelay)
+
+	networks := []*networkv1.Network{
+		network(networkv1.DefaultPodNetworkName, defaultGKENetworkParamsName),
+		network(redNetworkName, redGKENetworkParamsName),
+		network(blueNetworkName, blueGKENetworkParamsName),
+	}
+	params := []*networkv1alpha1.GKENetworkParamSet{
+		gkeNetworkParams(defaultGKENetworkParamsName, defaultVPCName, defaultVPCSubnetName, []string{defaultSecondaryRangeA}),
+		gkeNetworkParams(redGKENetworkParamsName, redVPCName, redVPCSubnetName, []string{redSecondaryRangeA, redSecondaryRangeB}),
+		gkeNetworkParams(blueGKENetworkParamsName, blueVPCName, blueVPCSubnetName, nil),
+	}
+	nwInfFactory := networkinformers.NewSharedInformerFactory(networkfake.NewSimpleClientset(), time.Second).Networking()
+	for _, nw := range networks {
+		if err := nwInfFactory.V1().Networks().Informer().GetStore().Add(nw); err != nil {
+			t.Fatalf("error in test setup, could not create network %s: %v", nw.Name, err)
+		}
+	}
+	for _, gnp := range params {
+		if err := nwInfFactory.V1alpha1().GKENetworkParamSets().Informer().GetStore().Add(gnp); err != nil {
+			t.Fatalf("error in test setup, could not create gke network param set %s: %v", gnp.Name, err)
+		}
+	}
+
+	clientSet := fake.NewSimpleClientset()
+	nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+	instances := &soakInstances{schedule: schedule, instances: map[string]*compute.Instance{}}
+	var names []string
+	want := map[string]*SimulatedAllocation{}
+	for i := 0; i < *soakNodes; i++ {
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("node%d", i),
+				Annotations: map[string]string{soakOwnerAnnotationKey: "soak"},
+			},
+			Spec: v1.NodeSpec{ProviderID: fmt.Sprintf("gce://test-project/us-central1-b/node%d", i)},
+		}
+		instance := soakInstance(i)
+		instances.instances[node.Spec.ProviderID] = instance
+		allocation, err := SimulateMultiNetworkAllocation(node, instance.NetworkInterfaces, networks, params)
+		if err != nil {
+			t.Fatalf("error in test setup, could not simulate the allocation of node %s: %v", node.Name, err)
+		}
+		want[node.Name] = allocation
+		if _, err := clientSet.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("error in test setup, could not create node %s: %v", node.Name, err)
+		}
+		names = append(names, node.Name)
+	}
+	// Faults are injected once the nodes exist.
+	clientSet.PrependReactor("patch", "nodes", schedule.reactor)
+	clientSet.PrependReactor("update", "nodes", schedule.reactor)
+
+	ca := &cloudCIDRAllocator{
+		client:         clientSet,
+		nodeLister:     nodeInformer.Lister(),
+		networksLister: nwInfFactory.V1().Networks().Lister(),
+		gnpLister:      nwInfFactory.V1alpha1().GKENetworkParamSets().Lister(),
+		recorder:       &record.FakeRecorder{},
+		instances:      instances,
+		clock:          testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)),
+		params:         CloudAllocatorParams{EnableMultiNetworking: true},
+	}
+
+	seen := map[string]soakState{}
+	// syncNode updates the informer with the node, unless the watch lags
+	// behind, and checks the invariants against what was seen before.
+	syncNode := func(round int, name string) {
+		t.Helper()
+		node, err := clientSet.Tracker().Get(v1.SchemeGroupVersion.WithResource("nodes"), "", name)
+		if err != nil {
+			t.Fatalf("round %d: failed to get node %s: %v", round, name, err)
+		}
+		got := node.(*v1.Node)
+		if !schedule.lags() {
+			if err := nodeInformer.Informer().GetStore().Update(got); err != nil {
+				t.Fatalf("round %d: failed to update the informer with node %s: %v", round, name, err)
+			}
+		}
+		if _, ok := got.Annotations[soakOwnerAnnotationKey]; !ok {
+			t.Fatalf("round %d: annotation %s of node %s was lost", round, soakOwnerAnnotationKey, name)
+		}
+		prev, ok := seen[name]
+		state := soakState{podCIDRs: got.Spec.PodCIDRs, annotations: map[string]string{}}
+		for _, key := range []string{networkv1.NorthInterfacesAnnotationKey, networkv1.MultiNetworkAnnotationKey} {
+			if value, ok := got.Annotations[key]; ok {
+				state.annotations[key] = value
+			}
+		}
+		if ok && len(prev.podCIDRs) > 0 && !cmp.Equal(prev.podCIDRs, state.podCIDRs) {
+			t.Fatalf("round %d: pod CIDRs of node %s flapped from %v to %v", round, name, prev.podCIDRs, state.podCIDRs)
+		}
+		for key, value := range prev.annotations {
+			if _, ok := state.annotations[key]; !ok {
+				t.Fatalf("round %d: annotation %s of node %s was lost, it was %s", round, key, name, value)
+			}
+		}
+		seen[name] = state
+	}
+	reconcile := func(round int) {
+		t.Helper()
+		order := append([]string(nil), names...)
+		schedule.shuffle(order)
+		for _, name := range order {
+			// Errors are retried in the next round, as the workers do.
+			_ = ca.updateCIDRAllocation(name)
+			syncNode(round, name)
+		}
+	}
+
+	for _, name := range names {
+		syncNode(0, name)
+	}
+	for round := 1; round <= *soakRounds; round++ {
+		reconcile(round)
+	}
+	// Without faults, every node converges in a round once the informer has
+	// caught up.
+	schedule.setEnabled(false)
+	for round := *soakRounds + 1; round <= *soakRounds+2; round++ {
+		reconcile(round)
+	}
+
+	for _, name := range names {
+		node, err := clientSet.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get node %s: %v", name, err)
+		}
+		if diff := cmp.Diff(want[name].PodCIDRs, node.Spec.PodCIDRs); diff != "" {
+			t.Errorf("pod CIDRs of node %s did not converge (-want +got):\n%s", name, diff)
+		}
+		nodeNetworks, err := networkv1.ParseMultiNetworkAnnotation(node.Annotations[networkv1.MultiNetworkAnnotationKey])
+		if err != nil {
+			t.Fatalf("failed to parse the networks annotation of node %s: %v", name, err)
+		}
+		sort.Slice(nodeNetworks, func(i, j int) bool { return nodeNetworks[i].Name < nodeNetworks[j].Name })
+		if diff := cmp.Diff(want[name].AdditionalNodeNetworks, nodeNetworks); diff != "" {
+			t.Errorf("networks annotation of node %s did not converge (-want +got):\n%s", name, diff)
+		}
+		if !nodeNetworkUnavailableFalse(node) {
+			t.Errorf("node %s has no %s=False condition", name, v1.NodeNetworkUnavailable)
+		}
+	}
+	t.Logf("Injected faults: %v", schedule.injected)
+}
+
+func nodeNetworkUnavailableFalse(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeNetworkUnavailable {
+			return condition.Status == v1.ConditionFalse
+		}
+	}
+	return false
+}
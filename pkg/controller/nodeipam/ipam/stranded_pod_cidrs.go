@@ -0,0 +1,167 @@
+package ipam
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/klog/v2"
+)
+
+// Cluster autoscaler policies and capacity reclamation tooling can use
+// wellknown.StrandedSinceAnnotationKey as a scale-down hint, as the IPs of the
+// pod CIDR are stranded on the node. It is removed once a pod uses the pod CIDR
+// again.
+
+const (
+	// strandedPodCIDRCheckInterval is the interval at which the pod CIDR
+	// usage of the nodes is checked.
+	strandedPodCIDRCheckInterval = time.Minute
+	// podNodeNameIndex is the index of the pods by the name of their node.
+	podNodeNameIndex = "nodeName"
+)
+
+// trackPodCIDRUsage indexes the pods of the informer by node, so that
+// reportStrandedPodCIDRs finds the pods using the pod CIDR of each node.
+func (ca *cloudCIDRAllocator) trackPodCIDRUsage(podInformer informers.PodInformer) error {
+	informer := podInformer.Informer()
+	if err := informer.AddIndexers(cache.Indexers{podNodeNameIndex: podNodeName}); err != nil {
+		return fmt.Errorf("failed to index the pods by node: %v", err)
+	}
+	ca.podIndexer = informer.GetIndexer()
+	ca.podsSynced = informer.HasSynced
+	return nil
+}
+
+// podNodeName returns the name of the node of a scheduled pod.
+func podNodeName(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// usesPodCIDR returns whether the pod holds an IP of the pod CIDR of its
+// node. Pods of the host network and terminated pods don't, and the pods of
+// DaemonSets are ignored as they run on every node, like the autoscaler
+// ignores them when scaling down.
+func usesPodCIDR(pod *v1.Pod) bool {
+	if pod.Spec.HostNetwork || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
+
+// podCIDRInUse returns whether a pod of the node uses its pod CIDR.
+func (ca *cloudCIDRAllocator) podCIDRInUse(nodeName string) (bool, error) {
+	objs, err := ca.podIndexer.ByIndex(podNodeNameIndex, nodeName)
+	if err != nil {
+		return false, err
+	}
+	for _, obj := range objs {
+		if pod, ok := obj.(*v1.Pod); ok && usesPodCIDR(pod) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// idleSince returns the time since which the pod CIDR of the node is
+// unused, recording now for a node whose pod CIDR just became unused. The
+// time published on the node is kept across restarts of the controller.
+func (ca *cloudCIDRAllocator) idleSince(node *v1.Node, now time.Time) time.Time {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if since, ok := ca.podCIDRIdleSince[node.Name]; ok {
+		return since
+	}
+	since := now
+	if value, ok := node.Annotations[wellknown.StrandedSinceAnnotationKey]; ok {
+		if published, err := time.Parse(time.RFC3339, value); err == nil && published.Before(now) {
+			since = published
+		}
+	}
+	if ca.podCIDRIdleSince == nil {
+		ca.podCIDRIdleSince = make(map[string]time.Time)
+	}
+	ca.podCIDRIdleSince[node.Name] = since
+	return since
+}
+
+// reportStrandedPodCIDRs sets wellknown.StrandedSinceAnnotationKey on the nodes
+// whose pod CIDR has not been used by any pod for StrandedPodCIDRThreshold,
+// removes it from the nodes whose pod CIDR is used again and exports the number
+// of stranded nodes.
+func (ca *cloudCIDRAllocator) reportStrandedPodCIDRs() {
+	nodes, err := ca.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list the nodes to report the stranded pod CIDRs")
+		return
+	}
+	now := ca.now().Time
+	idle := make(map[string]bool, len(nodes))
+	stranded := 0
+	for _, node := range nodes {
+		if node.Spec.PodCIDR == "" {
+			continue
+		}
+		inUse, err := ca.podCIDRInUse(node.Name)
+		if err != nil {
+			klog.ErrorS(err, "Failed to get the pods of the node", "nodeName", node.Name)
+			continue
+		}
+		if inUse {
+			if err := ca.clearStrandedPodCIDR(node); err != nil {
+				klog.ErrorS(err, "Failed to clear the stranded pod CIDR of the node", "nodeName", node.Name)
+			}
+			continue
+		}
+		idle[node.Name] = true
+		since := ca.idleSince(node, now)
+		if now.Sub(since) < ca.params.StrandedPodCIDRThreshold {
+			continue
+		}
+		stranded++
+		value := since.UTC().Format(time.RFC3339)
+		if node.Annotations[wellknown.StrandedSinceAnnotationKey] == value {
+			continue
+		}
+		if err := ca.publishNodeUpdate(node, nodeUpdate{Annotations: map[string]string{wellknown.StrandedSinceAnnotationKey: value}, Reason: auditReasonStrandedPodCIDR}); err != nil {
+			continue
+		}
+		klog.V(2).InfoS("Reported the stranded pod CIDR of the node", "nodeName", node.Name, "podCIDR", node.Spec.PodCIDR, "idleSince", value)
+	}
+
+	ca.lock.Lock()
+	for name := range ca.podCIDRIdleSince {
+		if !idle[name] {
+			delete(ca.podCIDRIdleSince, name)
+		}
+	}
+	ca.lock.Unlock()
+	strandedPodCIDRNodes.Set(float64(stranded))
+}
+
+// clearStrandedPodCIDR removes wellknown.StrandedSinceAnnotationKey from the
+// node.
+func (ca *cloudCIDRAllocator) clearStrandedPodCIDR(node *v1.Node) error {
+	if _, ok := node.Annotations[wellknown.StrandedSinceAnnotationKey]; !ok {
+		return nil
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, wellknown.StrandedSinceAnnotationKey))
+	if _, err := ca.patchNode(node.Name, types.MergePatchType, patch); err != nil {
+		return fmt.Errorf("failed to remove the stranded pod CIDR annotation of node %s: %v", node.Name, err)
+	}
+	klog.V(2).InfoS("The pod CIDR of the node is used again", "nodeName", node.Name)
+	return nil
+}
@@ -0,0 +1,153 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/component-base/metrics/testutil"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+)
+
+func TestUsesPodCIDR(t *testing.T) {
+	testCases := []struct {
+		desc string
+		pod  *v1.Pod
+		want bool
+	}{
+		{
+			desc: "running pod",
+			pod:  &v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}},
+			want: true,
+		},
+		{
+			desc: "pending pod",
+			pod:  &v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending}},
+			want: true,
+		},
+		{
+			desc: "host network pod",
+			pod:  &v1.Pod{Spec: v1.PodSpec{HostNetwork: true}, Status: v1.PodStatus{Phase: v1.PodRunning}},
+		},
+		{
+			desc: "succeeded pod",
+			pod:  &v1.Pod{Status: v1.PodStatus{Phase: v1.PodSucceeded}},
+		},
+		{
+			desc: "failed pod",
+			pod:  &v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed}},
+		},
+		{
+			desc: "DaemonSet pod",
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", Controller: pointer.Bool(true)}}},
+				Status:     v1.PodStatus{Phase: v1.PodRunning},
+			},
+		},
+		{
+			desc: "ReplicaSet pod",
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web", Controller: pointer.Bool(true)}}},
+				Status:     v1.PodStatus{Phase: v1.PodRunning},
+			},
+			want: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := usesPodCIDR(tc.pod); got != tc.want {
+				t.Errorf("usesPodCIDR() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportStrandedPodCIDRs(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	fakeClock := testingclock.NewFakeClock(start)
+	node := func(name, podCIDR string, annotations map[string]string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}, Spec: v1.NodeSpec{PodCIDR: podCIDR}}
+	}
+	pod := func(name, nodeName string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Spec: v1.PodSpec{NodeName: nodeName}, Status: v1.PodStatus{Phase: v1.PodRunning}}
+	}
+	nodes := []*v1.Node{
+		node("used", "10.0.0.0/24", nil),
+		node("idle", "10.0.1.0/24", nil),
+		node("unallocated", "", nil),
+		node("stranded", "10.0.2.0/24", map[string]string{wellknown.StrandedSinceAnnotationKey: "2023-05-01T08:00:00Z"}),
+		node("reused", "10.0.3.0/24", map[string]string{wellknown.StrandedSinceAnnotationKey: "2023-05-01T08:00:00Z"}),
+	}
+	hostNetworkPod := pod("host", "idle")
+	hostNetworkPod.Spec.HostNetwork = true
+	pods := []*v1.Pod{pod("web", "used"), pod("db", "reused"), hostNetworkPod}
+
+	clientSet := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(clientSet, 0)
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	for _, n := range nodes {
+		if _, err := clientSet.CoreV1().Nodes().Create(context.TODO(), n, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("error in test setup, could not create node %s: %v", n.Name, err)
+		}
+		nodeInformer.Informer().GetIndexer().Add(n)
+	}
+	ca := &cloudCIDRAllocator{
+		client:     clientSet,
+		clock:      fakeClock,
+		nodeLister: nodeInformer.Lister(),
+		params:     CloudAllocatorParams{StrandedPodCIDRThreshold: time.Hour},
+	}
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := ca.trackPodCIDRUsage(podInformer); err != nil {
+		t.Fatalf("trackPodCIDRUsage() returned err %v", err)
+	}
+	for _, p := range pods {
+		podInformer.Informer().GetIndexer().Add(p)
+	}
+
+	getAnnotations := func() map[string]string {
+		t.Helper()
+		annotations := map[string]string{}
+		for _, n := range nodes {
+			got, err := clientSet.CoreV1().Nodes().Get(context.TODO(), n.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get node %s: %v", n.Name, err)
+			}
+			if value, ok := got.Annotations[wellknown.StrandedSinceAnnotationKey]; ok {
+				annotations[n.Name] = value
+			}
+			nodeInformer.Informer().GetIndexer().Update(got)
+		}
+		return annotations
+	}
+	check := func(step string, wantAnnotations map[string]string, wantStranded float64) {
+		t.Helper()
+		ca.reportStrandedPodCIDRs()
+		if diff := cmp.Diff(wantAnnotations, getAnnotations()); diff != "" {
+			t.Errorf("%s: stranded annotations mismatch (-want +got):\n%s", step, diff)
+		}
+		if got, _ := testutil.GetGaugeMetricValue(strandedPodCIDRNodes); got != wantStranded {
+			t.Errorf("%s: got %v stranded nodes, want %v", step, got, wantStranded)
+		}
+	}
+
+	check("first check", map[string]string{"stranded": "2023-05-01T08:00:00Z"}, 1)
+	fakeClock.Step(30 * time.Minute)
+	check("before the threshold", map[string]string{"stranded": "2023-05-01T08:00:00Z"}, 1)
+	fakeClock.Step(30 * time.Minute)
+	check("after the threshold", map[string]string{"stranded": "2023-05-01T08:00:00Z", "idle": "2023-05-01T10:00:00Z"}, 2)
+
+	podInformer.Informer().GetIndexer().Add(pod("batch", "idle"))
+	check("pod scheduled", map[string]string{"stranded": "2023-05-01T08:00:00Z"}, 1)
+	if _, ok := ca.podCIDRIdleSince["idle"]; ok {
+		t.Errorf("the idle time of a used pod CIDR is still tracked")
+	}
+}
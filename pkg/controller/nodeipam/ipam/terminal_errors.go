@@ -0,0 +1,95 @@
+package ipam
+
+import (
+	"errors"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Terminal errors are caused by invalid inputs that retrying the update of the
+// node cannot fix. Nodes failing with them are parked: they leave the work
+// queue without backoff, freeing the workers and the retries for recoverable
+// errors, and are queued again only when their inputs change, that is on an
+// update of the node, or of the Network or the GKENetworkParamSet the error
+// is about.
+
+// terminalError returns true for the errors caused by invalid inputs: the
+// network parameters of the node and the providerID of GCE instances that
+// cannot be parsed.
+func terminalError(err error) bool {
+	return errors.Is(err, ErrParamsInvalid) || errors.Is(err, ErrMalformedProviderID)
+}
+
+// parkNode records that the update of the node failed with a terminal error.
+// The network the error is about, if any, is recorded with it, see
+// requeueParkedNetworkNodes.
+func (ca *cloudCIDRAllocator) parkNode(nodeName string, err error) {
+	var network string
+	var allocErr *AllocationError
+	if errors.As(err, &allocErr) {
+		network = allocErr.Network
+	}
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.parkedNodes == nil {
+		ca.parkedNodes = make(map[string]string)
+	}
+	ca.parkedNodes[nodeName] = network
+	skippedNodes.WithLabelValues(skippedNodeTerminalError).Set(float64(len(ca.parkedNodes)))
+}
+
+// unparkNode forgets the terminal error of a node updated successfully or
+// deleted.
+func (ca *cloudCIDRAllocator) unparkNode(nodeName string) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if _, ok := ca.parkedNodes[nodeName]; !ok {
+		return
+	}
+	delete(ca.parkedNodes, nodeName)
+	skippedNodes.WithLabelValues(skippedNodeTerminalError).Set(float64(len(ca.parkedNodes)))
+}
+
+// nodeParked returns true if the last update of the node failed with a
+// terminal error.
+func (ca *cloudCIDRAllocator) nodeParked(nodeName string) bool {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	_, ok := ca.parkedNodes[nodeName]
+	return ok
+}
+
+// requeueParkedNetworkNodes puts the nodes parked on a terminal error about
+// the network into the work queue, as the change of the network may fix it.
+// They are not necessarily attached to the network, see requeueNetworkNodes.
+func (ca *cloudCIDRAllocator) requeueParkedNetworkNodes(networkName string) {
+	ca.lock.Lock()
+	var nodeNames []string
+	for nodeName, network := range ca.parkedNodes {
+		if network == networkName {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	ca.lock.Unlock()
+	for _, nodeName := range nodeNames {
+		node, err := ca.nodeLister.Get(nodeName)
+		if err != nil {
+			klog.V(4).Infof("Not requeuing parked node %s: %v", nodeName, err)
+			continue
+		}
+		klog.V(2).InfoS("Requeuing the node parked on a terminal error after a network change", "nodeName", nodeName, "network", networkName)
+		if err := ca.AllocateOrOccupyCIDR(node); err != nil {
+			klog.ErrorS(err, "Failed to requeue the parked node", "nodeName", nodeName)
+		}
+	}
+}
+
+// nodeInputsChanged returns true if the providerID, the labels or the
+// annotations of the node changed, which may fix a terminal error.
+func nodeInputsChanged(oldNode, newNode *v1.Node) bool {
+	return oldNode.Spec.ProviderID != newNode.Spec.ProviderID ||
+		!reflect.DeepEqual(oldNode.Labels, newNode.Labels) ||
+		!reflect.DeepEqual(oldNode.Annotations, newNode.Annotations)
+}
@@ -0,0 +1,148 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+	"k8s.io/component-base/metrics/testutil"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestTerminalErrorParksNode(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: map[string]string{wellknown.CIDRAllocationRetriesAnnotationKey: "2"}},
+		Spec:       v1.NodeSpec{ProviderID: "gce://project/instance"},
+	}
+	clientSet := fake.NewSimpleClientset(node)
+	nodeInformer := informers.NewSharedInformerFactory(clientSet, time.Hour).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
+	fakeClock := testingclock.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	ca := withQueue(&cloudCIDRAllocator{
+		client:     clientSet,
+		clock:      fakeClock,
+		nodeLister: nodeInformer.Lister(),
+		recorder:   record.NewFakeRecorder(10),
+		params:     DefaultCloudAllocatorParams(),
+	})
+	gauge := skippedNodes.WithLabelValues(skippedNodeTerminalError)
+
+	// The cloud is not queried, which would panic as it is not set.
+	ca.queue.Add(node.Name)
+	ca.queue.Process(<-ca.queue.Updates())
+	if hasNodeInProcessing(ca, node.Name) {
+		t.Errorf("node with a malformed providerID is still processed")
+	}
+	if fakeClock.HasWaiters() {
+		t.Errorf("a retry is scheduled for a node with a malformed providerID")
+	}
+	if !ca.nodeParked(node.Name) {
+		t.Errorf("node with a malformed providerID is not parked")
+	}
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 1 {
+		t.Errorf("skipped_nodes{reason=%q} = %v, want 1", skippedNodeTerminalError, got)
+	}
+	// The backoff of earlier failures does not delay the update once the
+	// providerID is fixed.
+	updated, err := clientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the node: %v", err)
+	}
+	if value, ok := updated.Annotations[wellknown.CIDRAllocationRetriesAnnotationKey]; ok {
+		t.Errorf("got retries annotation %q on a parked node, want none", value)
+	}
+
+	if err := ca.ReleaseCIDR(node); err != nil {
+		t.Fatalf("ReleaseCIDR() returned err %v", err)
+	}
+	if ca.nodeParked(node.Name) {
+		t.Errorf("deleted node is still parked")
+	}
+	if got, _ := testutil.GetGaugeMetricValue(gauge); got != 0 {
+		t.Errorf("skipped_nodes{reason=%q} = %v after the node deletion, want 0", skippedNodeTerminalError, got)
+	}
+}
+
+func TestRequeueParkedNetworkNodes(t *testing.T) {
+	registerCloudAllocatorMetrics()
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+	}
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), time.Hour).Core().V1().Nodes()
+	for _, node := range nodes {
+		if err := nodeInformer.Informer().GetStore().Add(node); err != nil {
+			t.Fatalf("error in test setup, could not add node: %v", err)
+		}
+	}
+	ca := withQueue(&cloudCIDRAllocator{
+		nodeLister: nodeInformer.Lister(),
+		params:     DefaultCloudAllocatorParams(),
+	})
+	ca.parkNode("node0", networkAllocationErrorf(ErrParamsInvalid, redNetworkName, "no params"))
+	ca.parkNode("node1", networkAllocationErrorf(ErrParamsInvalid, "blue-network", "no params"))
+	ca.parkNode("node2", allocationErrorf(ErrParamsInvalid, "invalid reservations"))
+
+	ca.requeueParkedNetworkNodes(redNetworkName)
+	if got := len(ca.queue.Updates()); got != 1 {
+		t.Fatalf("got %d queued nodes, want 1", got)
+	}
+	if got := <-ca.queue.Updates(); got != "node0" {
+		t.Errorf("got queued node %q, want node0", got)
+	}
+}
+
+func TestNodeInputsChanged(t *testing.T) {
+	base := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node0",
+			Labels:      map[string]string{"pool": "a"},
+			Annotations: map[string]string{wellknown.InterfaceReservationsAnnotationKey: "[]"},
+		},
+		Spec: v1.NodeSpec{ProviderID: "gce://project/instance"},
+	}
+	testCases := []struct {
+		desc   string
+		update func(node *v1.Node)
+		want   bool
+	}{
+		{
+			desc:   "status only",
+			update: func(node *v1.Node) { node.Status.Phase = v1.NodeRunning },
+		},
+		{
+			desc:   "providerID",
+			update: func(node *v1.Node) { node.Spec.ProviderID = "gce://project/us-central1-a/instance" },
+			want:   true,
+		},
+		{
+			desc:   "labels",
+			update: func(node *v1.Node) { node.Labels["pool"] = "b" },
+			want:   true,
+		},
+		{
+			desc:   "annotations",
+			update: func(node *v1.Node) { delete(node.Annotations, wellknown.InterfaceReservationsAnnotationKey) },
+			want:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			newNode := base.DeepCopy()
+			tc.update(newNode)
+			if got := nodeInputsChanged(base, newNode); got != tc.want {
+				t.Errorf("nodeInputsChanged() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
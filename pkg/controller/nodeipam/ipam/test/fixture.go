@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"fmt"
+	"os"
+
+	compute "google.golang.org/api/compute/v1"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// Fixture is a snapshot of the multi-network configuration of a cluster, as
+// written by cmd/ipamfixture, used to reproduce real-world configurations in
+// the allocator unit tests.
+type Fixture struct {
+	// Networks are the Network objects of the cluster.
+	Networks []networkv1.Network `json:"networks,omitempty"`
+	// GKENetworkParamSets are the GKENetworkParamSet objects of the cluster.
+	GKENetworkParamSets []networkv1alpha1.GKENetworkParamSet `json:"gkeNetworkParamSets,omitempty"`
+	// Nodes are the sanitized instance interfaces of the nodes along with
+	// the allocation published on them at the time of the snapshot.
+	Nodes []NodeFixture `json:"nodes,omitempty"`
+}
+
+// NodeFixture holds the interfaces of a node and its expected allocation.
+type NodeFixture struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// Interfaces are the sanitized network interfaces of the node's instance.
+	Interfaces []*compute.NetworkInterface `json:"interfaces,omitempty"`
+	// PodCIDRs are the pod CIDRs of the default network on the node.
+	PodCIDRs []string `json:"podCIDRs,omitempty"`
+	// NorthInterfaces is the north-interfaces annotation of the node.
+	NorthInterfaces networkv1.NorthInterfacesAnnotation `json:"northInterfaces,omitempty"`
+	// AdditionalNodeNetworks is the networks annotation of the node.
+	AdditionalNodeNetworks networkv1.MultiNetworkAnnotation `json:"additionalNodeNetworks,omitempty"`
+}
+
+// LoadFixture reads a Fixture from a YAML file.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &Fixture{}
+	if err := yaml.UnmarshalStrict(data, fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %q: %v", path, err)
+	}
+	return fixture, nil
+}
+
+// SanitizeInterface returns a copy of the interface keeping only the fields
+// used for CIDR allocation. External access configs, fingerprints and other
+// details that may identify the instance are dropped.
+func SanitizeInterface(inf *compute.NetworkInterface) *compute.NetworkInterface {
+	sanitized := &compute.NetworkInterface{
+		Name:           inf.Name,
+		Network:        inf.Network,
+		Subnetwork:     inf.Subnetwork,
+		NetworkIP:      inf.NetworkIP,
+		NicType:        inf.NicType,
+		StackType:      inf.StackType,
+		Ipv6AccessType: inf.Ipv6AccessType,
+		Ipv6Address:    inf.Ipv6Address,
+	}
+	for _, r := range inf.AliasIpRanges {
+		sanitized.AliasIpRanges = append(sanitized.AliasIpRanges, &compute.AliasIpRange{
+			IpCidrRange:         r.IpCidrRange,
+			SubnetworkRangeName: r.SubnetworkRangeName,
+		})
+	}
+	for _, ac := range inf.Ipv6AccessConfigs {
+		sanitized.Ipv6AccessConfigs = append(sanitized.Ipv6AccessConfigs, &compute.AccessConfig{
+			Type:                     ac.Type,
+			ExternalIpv6:             ac.ExternalIpv6,
+			ExternalIpv6PrefixLength: ac.ExternalIpv6PrefixLength,
+		})
+	}
+	return sanitized
+}
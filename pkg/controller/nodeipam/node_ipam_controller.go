@@ -82,7 +82,8 @@ func NewNodeIpamController(
 	serviceCIDR *net.IPNet,
 	secondaryServiceCIDR *net.IPNet,
 	nodeCIDRMaskSizes []int,
-	allocatorType ipam.CIDRAllocatorType) (*Controller, error) {
+	allocatorType ipam.CIDRAllocatorType,
+	cloudAllocatorParams ipam.CloudAllocatorParams) (*Controller, error) {
 
 	if kubeClient == nil {
 		klog.Fatalf("kubeClient is nil when starting Controller")
@@ -132,6 +133,7 @@ func NewNodeIpamController(
 			ServiceCIDR:          ic.serviceCIDR,
 			SecondaryServiceCIDR: ic.secondaryServiceCIDR,
 			NodeCIDRMaskSizes:    nodeCIDRMaskSizes,
+			Cloud:                cloudAllocatorParams,
 		}
 
 		ic.cidrAllocator, err = ipam.New(kubeClient, cloud, nodeInformer, nwInformer, gnpInformer, ic.allocatorType, allocatorParams)
@@ -147,6 +149,8 @@ func NewNodeIpamController(
 }
 
 // Run starts an asynchronous loop that monitors the status of cluster nodes.
+// It returns once stopCh is closed and the allocator stopped, after which it
+// can be called again, e.g. when the pod CIDR ownership is re-acquired.
 func (nc *Controller) Run(stopCh <-chan struct{}, controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics) {
 	defer utilruntime.HandleCrash()
 
@@ -160,7 +164,9 @@ func (nc *Controller) Run(stopCh <-chan struct{}, controllerManagerMetrics *cont
 	}
 
 	if nc.allocatorType != ipam.IPAMFromClusterAllocatorType && nc.allocatorType != ipam.IPAMFromCloudAllocatorType {
-		go nc.cidrAllocator.Run(stopCh)
+		// The allocator runs in the foreground, so that the controller can be
+		// run again once it returned.
+		nc.cidrAllocator.Run(stopCh)
 	}
 
 	<-stopCh
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routegc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+	"k8s.io/klog/v2"
+
+	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
+)
+
+// DefaultCollectPeriod is the default period between two collections of the
+// orphaned routes.
+const DefaultCollectPeriod = 10 * time.Minute
+
+// routeCollector deletes the routes of the cluster whose node no longer
+// exists. It is implemented by gce.Cloud.
+type routeCollector interface {
+	DeleteOrphanedRoutes(ctx context.Context, clusterName string, nodeExists func(nodeName types.NodeName) (bool, error)) ([]string, error)
+}
+
+// Controller periodically deletes the routes created by the provider for
+// nodes which were deleted, e.g. while the route controller was not running.
+// The routes of the nodes whose instance was deleted, as notified by
+// InstanceChanged, are deleted right away: their next hop no longer exists,
+// and the route controller recreates them if the instance is recreated.
+//
+// The firewall rules of the provider are not collected: they are owned by
+// the load balancers of services, not by nodes, and their descriptions do not
+// record their cluster yet.
+type Controller struct {
+	collector     routeCollector
+	clusterName   string
+	nodeLister    corelisters.NodeLister
+	nodesSynced   cache.InformerSynced
+	collectPeriod time.Duration
+
+	// collectNow triggers a collection after instances were deleted.
+	collectNow chan struct{}
+	lock       sync.Mutex
+	// deletedInstances holds the names of the deleted instances whose node
+	// may still exist. Node names are instance names on GCE.
+	deletedInstances map[types.NodeName]bool
+}
+
+// NewRouteGCController returns a new route garbage collection controller
+// for the routes of the cluster of the given name.
+func NewRouteGCController(
+	collector routeCollector,
+	clusterName string,
+	nodeLister corelisters.NodeLister,
+	nodesSynced cache.InformerSynced,
+	collectPeriod time.Duration,
+) *Controller {
+	return &Controller{
+		collector:     collector,
+		clusterName:   clusterName,
+		nodeLister:    nodeLister,
+		nodesSynced:   nodesSynced,
+		collectPeriod: collectPeriod,
+		collectNow:    make(chan struct{}, 1),
+
+		deletedInstances: make(map[types.NodeName]bool),
+	}
+}
+
+// InstanceChanged records the deletions and recreations of the instances, see
+// gce.Cloud.AddInstanceChangeHandler.
+func (c *Controller) InstanceChanged(change gce.InstanceChange) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch change.Type {
+	case gce.InstanceDeleted:
+		c.deletedInstances[types.NodeName(change.Old.Name)] = true
+		select {
+		case c.collectNow <- struct{}{}:
+		default:
+		}
+	case gce.InstanceAdded:
+		delete(c.deletedInstances, types.NodeName(change.New.Name))
+	}
+}
+
+// Run collects the orphaned routes every collect period until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}, controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics) {
+	defer utilruntime.HandleCrash()
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	klog.Infof("Starting routegc controller")
+	defer klog.Infof("Shutting down routegc controller")
+	controllerManagerMetrics.ControllerStarted("routegc")
+	defer controllerManagerMetrics.ControllerStopped("routegc")
+
+	if !cache.WaitForNamedCacheSync("routegc", stopCh, c.nodesSynced) {
+		return
+	}
+	go c.run(ctx)
+
+	<-stopCh
+}
+
+// run collects the orphaned routes every collect period, and after instances
+// were deleted, until ctx is done.
+func (c *Controller) run(ctx context.Context) {
+	ticker := time.NewTicker(c.collectPeriod)
+	defer ticker.Stop()
+	for {
+		c.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.collectNow:
+		}
+	}
+}
+
+// collect deletes the routes of the nodes missing from the node lister, or
+// whose instance was deleted.
+func (c *Controller) collect(ctx context.Context) {
+	deleted, err := c.collector.DeleteOrphanedRoutes(ctx, c.clusterName, c.nodeExists)
+	if len(deleted) > 0 {
+		klog.Infof("Deleted %d orphaned routes: %v", len(deleted), deleted)
+	}
+	if err != nil {
+		klog.Errorf("Failed to delete the orphaned routes of cluster %s: %v", c.clusterName, err)
+	}
+	c.forgetDeletedNodes()
+}
+
+// nodeExists returns false for the nodes missing from the node lister, and
+// for the nodes whose instance was deleted.
+func (c *Controller) nodeExists(nodeName types.NodeName) (bool, error) {
+	_, err := c.nodeLister.Get(string(nodeName))
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return !c.deletedInstances[nodeName], nil
+}
+
+// forgetDeletedNodes forgets the deleted instances whose node was deleted too.
+func (c *Controller) forgetDeletedNodes() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for nodeName := range c.deletedInstances {
+		if _, err := c.nodeLister.Get(string(nodeName)); apierrors.IsNotFound(err) {
+			delete(c.deletedInstances, nodeName)
+		}
+	}
+}
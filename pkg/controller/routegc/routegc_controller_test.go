@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routegc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	compute "google.golang.org/api/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/cloud-provider-gcp/providers/gce"
+)
+
+// fakeCollector deletes the routes, by node name, of the nodes that do not
+// exist.
+type fakeCollector struct {
+	routes      map[types.NodeName]string
+	clusterName string
+}
+
+func (f *fakeCollector) DeleteOrphanedRoutes(ctx context.Context, clusterName string, nodeExists func(nodeName types.NodeName) (bool, error)) ([]string, error) {
+	f.clusterName = clusterName
+	var deleted []string
+	for nodeName, route := range f.routes {
+		exists, err := nodeExists(nodeName)
+		if err != nil {
+			return deleted, err
+		}
+		if !exists {
+			deleted = append(deleted, route)
+			delete(f.routes, nodeName)
+		}
+	}
+	return deleted, nil
+}
+
+func TestCollect(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	nodeInformer := informers.NewSharedInformerFactory(client, 0).Core().V1().Nodes()
+	if err := nodeInformer.Informer().GetStore().Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}); err != nil {
+		t.Fatalf("error in test setup, could not add node: %v", err)
+	}
+	collector := &fakeCollector{routes: map[types.NodeName]string{
+		"node-1": "cluster-node-1",
+		"node-2": "cluster-node-2",
+	}}
+	c := NewRouteGCController(collector, "cluster", nodeInformer.Lister(), nodeInformer.Informer().HasSynced, DefaultCollectPeriod)
+
+	c.collect(context.Background())
+	if collector.clusterName != "cluster" {
+		t.Errorf("collected the routes of cluster %q, want %q", collector.clusterName, "cluster")
+	}
+	want := map[types.NodeName]string{"node-1": "cluster-node-1"}
+	if diff := cmp.Diff(want, collector.routes); diff != "" {
+		t.Errorf("unexpected remaining routes (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectDeletedInstances(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	nodeInformer := informers.NewSharedInformerFactory(client, 0).Core().V1().Nodes()
+	for _, name := range []string{"node-1", "node-2"} {
+		if err := nodeInformer.Informer().GetStore().Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}); err != nil {
+			t.Fatalf("error in test setup, could not add node: %v", err)
+		}
+	}
+	collector := &fakeCollector{routes: map[types.NodeName]string{
+		"node-1": "cluster-node-1",
+		"node-2": "cluster-node-2",
+	}}
+	c := NewRouteGCController(collector, "cluster", nodeInformer.Lister(), nodeInformer.Informer().HasSynced, DefaultCollectPeriod)
+
+	c.InstanceChanged(gce.InstanceChange{Type: gce.InstanceDeleted, Old: &compute.Instance{Name: "node-1"}})
+	c.InstanceChanged(gce.InstanceChange{Type: gce.InstanceDeleted, Old: &compute.Instance{Name: "node-2"}})
+	c.InstanceChanged(gce.InstanceChange{Type: gce.InstanceAdded, New: &compute.Instance{Name: "node-2"}})
+	select {
+	case <-c.collectNow:
+	default:
+		t.Fatalf("no collection was triggered by the deleted instances")
+	}
+	c.collect(context.Background())
+	want := map[types.NodeName]string{"node-2": "cluster-node-2"}
+	if diff := cmp.Diff(want, collector.routes); diff != "" {
+		t.Errorf("unexpected remaining routes (-want +got):\n%s", diff)
+	}
+
+	if err := nodeInformer.Informer().GetStore().Delete(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}); err != nil {
+		t.Fatalf("failed to delete node: %v", err)
+	}
+	c.collect(context.Background())
+	if len(c.deletedInstances) != 0 {
+		t.Errorf("deleted instances %v are still tracked after their node was deleted", c.deletedInstances)
+	}
+}
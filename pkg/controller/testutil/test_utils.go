@@ -325,7 +325,9 @@ func (m *FakeNodeHandler) Patch(_ context.Context, name string, pt types.PatchTy
 			klog.Error(err.Error())
 			return nil, nil
 		}
-	case types.StrategicMergePatchType:
+	case types.StrategicMergePatchType, types.ApplyPatchType:
+		// Like the object tracker of the fake clientset, server-side applies
+		// are strategic merge patches: fields are never removed by omission.
 		if patchedObjJS, err = strategicpatch.StrategicMergePatch(originalObjJS, data, originalNode); err != nil {
 			klog.Error(err.Error())
 			return nil, nil
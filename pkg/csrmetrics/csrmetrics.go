@@ -81,6 +81,10 @@ var (
 		Name: "outbound_rpc_latency",
 		Help: "Latency of outbound RPCs to GCE and GKE, in seconds",
 	}, []string{"status", "kind"})
+	kmsSigningLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csr_kms_signing_latencies",
+		Help: "Latency of Cloud KMS signatures of certificates, in seconds",
+	}, []string{"status", "key_version"})
 )
 
 func init() {
@@ -91,6 +95,7 @@ func init() {
 		approvalLatency,
 		outboundRPCCount,
 		outboundRPCLatency,
+		kmsSigningLatency,
 	)
 }
 
@@ -126,3 +131,13 @@ func OutboundRPCStartRecorder(kind string) func(status OutboundRPCStatus) {
 		outboundRPCLatency.WithLabelValues(string(status), kind).Observe(time.Since(start).Seconds())
 	}
 }
+
+// KMSSigningStartRecorder marks the start of the signature of a certificate
+// by a Cloud KMS key version. Caller is responsible for calling the returned
+// function, which records Prometheus metrics for this operation.
+func KMSSigningStartRecorder(keyVersion string) func(status OutboundRPCStatus) {
+	start := time.Now()
+	return func(status OutboundRPCStatus) {
+		kmsSigningLatency.WithLabelValues(string(status), keyVersion).Observe(time.Since(start).Seconds())
+	}
+}
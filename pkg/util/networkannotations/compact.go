@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkannotations
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+// compactAnnotation is the value of the wellknown.CompactAnnotationKey
+// annotation.
+type compactAnnotation struct {
+	NorthInterfaces json.RawMessage `json:"northInterfaces,omitempty"`
+	Networks        json.RawMessage `json:"networks,omitempty"`
+}
+
+// EncodeCompact returns the value of the wellknown.CompactAnnotationKey
+// annotation holding the given values of the
+// networkv1.NorthInterfacesAnnotationKey and
+// networkv1.MultiNetworkAnnotationKey annotations, gzip compressed and base64
+// encoded if compress is true.
+func EncodeCompact(northInterfaces, nodeNetworks string, compress bool) (string, error) {
+	ann, err := json.Marshal(compactAnnotation{NorthInterfaces: json.RawMessage(northInterfaces), Networks: json.RawMessage(nodeNetworks)})
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", wellknown.CompactAnnotationKey, err)
+	}
+	if !compress {
+		return string(ann), nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(ann); err != nil {
+		return "", fmt.Errorf("%s: %v", wellknown.CompactAnnotationKey, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("%s: %v", wellknown.CompactAnnotationKey, err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeCompact returns the values of the
+// networkv1.NorthInterfacesAnnotationKey and
+// networkv1.MultiNetworkAnnotationKey annotations held by the value of the
+// wellknown.CompactAnnotationKey annotation, compressed or not. The values are
+// empty if the annotation does not hold them.
+func DecodeCompact(annotation string) (northInterfaces, nodeNetworks string, err error) {
+	data := []byte(annotation)
+	if len(data) > 0 && data[0] != '{' {
+		compressed, err := base64.StdEncoding.DecodeString(annotation)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: invalid base64: %v", wellknown.CompactAnnotationKey, err)
+		}
+		r, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return "", "", fmt.Errorf("%s: invalid gzip: %v", wellknown.CompactAnnotationKey, err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return "", "", fmt.Errorf("%s: invalid gzip: %v", wellknown.CompactAnnotationKey, err)
+		}
+	}
+	var ann compactAnnotation
+	if err := unmarshal(wellknown.CompactAnnotationKey, string(data), &ann); err != nil {
+		return "", "", err
+	}
+	return string(ann.NorthInterfaces), string(ann.Networks), nil
+}
+
+// NodeAnnotation returns the value of the
+// networkv1.NorthInterfacesAnnotationKey or networkv1.MultiNetworkAnnotationKey
+// annotation of a node, read from the wellknown.CompactAnnotationKey annotation
+// if the node carries it, else from the annotation itself. It returns false if
+// the node does not carry the value.
+func NodeAnnotation(annotations map[string]string, key string) (string, bool, error) {
+	compact, ok := annotations[wellknown.CompactAnnotationKey]
+	if !ok {
+		ann, ok := annotations[key]
+		return ann, ok, nil
+	}
+	northInterfaces, nodeNetworks, err := DecodeCompact(compact)
+	if err != nil {
+		return "", false, err
+	}
+	var ann string
+	switch key {
+	case networkv1.NorthInterfacesAnnotationKey:
+		ann = northInterfaces
+	case networkv1.MultiNetworkAnnotationKey:
+		ann = nodeNetworks
+	default:
+		return "", false, fmt.Errorf("%s: does not hold annotation %s", wellknown.CompactAnnotationKey, key)
+	}
+	return ann, ann != "", nil
+}
+
+// HasNodeAnnotations returns true if the node carries multi-network
+// annotations, in either encoding.
+func HasNodeAnnotations(annotations map[string]string) bool {
+	for _, key := range []string{wellknown.CompactAnnotationKey, networkv1.NorthInterfacesAnnotationKey, networkv1.MultiNetworkAnnotationKey} {
+		if _, ok := annotations[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNodeNorthInterfaces parses and validates the north interfaces of a
+// node, in either encoding. It returns false if the node does not carry them.
+func ParseNodeNorthInterfaces(annotations map[string]string) (networkv1.NorthInterfacesAnnotation, bool, error) {
+	ann, ok, err := NodeAnnotation(annotations, networkv1.NorthInterfacesAnnotationKey)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	northInterfaces, err := ParseNorthInterfaces(ann)
+	if err != nil {
+		return nil, false, err
+	}
+	return northInterfaces, true, nil
+}
+
+// ParseNodeMultiNetwork parses and validates the additional networks of a
+// node, in either encoding. It returns false if the node does not carry them.
+func ParseNodeMultiNetwork(annotations map[string]string) (networkv1.MultiNetworkAnnotation, bool, error) {
+	ann, ok, err := NodeAnnotation(annotations, networkv1.MultiNetworkAnnotationKey)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	nodeNetworks, err := ParseMultiNetwork(ann)
+	if err != nil {
+		return nil, false, err
+	}
+	return nodeNetworks, true, nil
+}
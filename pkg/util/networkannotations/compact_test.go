@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkannotations
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	"k8s.io/cloud-provider-gcp/crd/apis/network/wellknown"
+)
+
+const (
+	testNorthInterfaces = `[{"network":"red","ipAddress":"10.0.0.2"}]`
+	testNodeNetworks    = `[{"name":"red","scope":"host-local","cidrs":["172.11.1.0/24"]}]`
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		ann, err := EncodeCompact(testNorthInterfaces, testNodeNetworks, compress)
+		if err != nil {
+			t.Fatalf("EncodeCompact(compress=%v) returned err %v", compress, err)
+		}
+		if compressed := ann[0] != '{'; compressed != compress {
+			t.Errorf("EncodeCompact(compress=%v) = %q", compress, ann)
+		}
+		northInterfaces, nodeNetworks, err := DecodeCompact(ann)
+		if err != nil {
+			t.Fatalf("DecodeCompact(%q) returned err %v", ann, err)
+		}
+		if northInterfaces != testNorthInterfaces || nodeNetworks != testNodeNetworks {
+			t.Errorf("DecodeCompact(%q) = %q, %q, want %q, %q", ann, northInterfaces, nodeNetworks, testNorthInterfaces, testNodeNetworks)
+		}
+	}
+}
+
+func TestDecodeCompactInvalid(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		annotation string
+		wantErr    string
+	}{
+		{
+			desc:       "invalid JSON",
+			annotation: `{"networks":`,
+			wantErr:    "networking.gke.io/compact-networks: invalid JSON: unexpected end of JSON input",
+		},
+		{
+			desc:       "invalid base64",
+			annotation: "H4sI!",
+			wantErr:    "networking.gke.io/compact-networks: invalid base64: illegal base64 data at input byte 4",
+		},
+		{
+			desc:       "not gzip",
+			annotation: "bm90IGEgZ3ppcCBzdHJlYW0=",
+			wantErr:    "networking.gke.io/compact-networks: invalid gzip: gzip: invalid header",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, _, err := DecodeCompact(tc.annotation); errString(err) != tc.wantErr {
+				t.Errorf("DecodeCompact() returned err %q, want %q", errString(err), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseNodeAnnotations(t *testing.T) {
+	compressed, err := EncodeCompact(testNorthInterfaces, testNodeNetworks, true)
+	if err != nil {
+		t.Fatalf("EncodeCompact() returned err %v", err)
+	}
+	wantNorthInterfaces := networkv1.NorthInterfacesAnnotation{{Network: "red", IpAddress: "10.0.0.2"}}
+	wantNodeNetworks := networkv1.MultiNetworkAnnotation{{Name: "red", Scope: HostLocalScope, Cidrs: []string{"172.11.1.0/24"}}}
+	testCases := []struct {
+		desc                string
+		annotations         map[string]string
+		wantNorthInterfaces networkv1.NorthInterfacesAnnotation
+		wantNodeNetworks    networkv1.MultiNetworkAnnotation
+		wantErr             bool
+	}{
+		{
+			desc: "no annotations",
+		},
+		{
+			desc: "per-key annotations",
+			annotations: map[string]string{
+				networkv1.NorthInterfacesAnnotationKey: testNorthInterfaces,
+				networkv1.MultiNetworkAnnotationKey:    testNodeNetworks,
+			},
+			wantNorthInterfaces: wantNorthInterfaces,
+			wantNodeNetworks:    wantNodeNetworks,
+		},
+		{
+			desc: "compact annotation",
+			annotations: map[string]string{
+				wellknown.CompactAnnotationKey: `{"northInterfaces":` + testNorthInterfaces + `,"networks":` + testNodeNetworks + `}`,
+			},
+			wantNorthInterfaces: wantNorthInterfaces,
+			wantNodeNetworks:    wantNodeNetworks,
+		},
+		{
+			desc: "compressed compact annotation preferred over per-key annotations",
+			annotations: map[string]string{
+				wellknown.CompactAnnotationKey:      compressed,
+				networkv1.MultiNetworkAnnotationKey: `[]`,
+			},
+			wantNorthInterfaces: wantNorthInterfaces,
+			wantNodeNetworks:    wantNodeNetworks,
+		},
+		{
+			desc: "compact annotation without networks",
+			annotations: map[string]string{
+				wellknown.CompactAnnotationKey: `{"northInterfaces":` + testNorthInterfaces + `}`,
+			},
+			wantNorthInterfaces: wantNorthInterfaces,
+		},
+		{
+			desc: "invalid compact annotation",
+			annotations: map[string]string{
+				wellknown.CompactAnnotationKey:      "{",
+				networkv1.MultiNetworkAnnotationKey: testNodeNetworks,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			northInterfaces, ok, err := ParseNodeNorthInterfaces(tc.annotations)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseNodeNorthInterfaces() returned err %v, want error %v", err, tc.wantErr)
+			}
+			if ok != (tc.wantNorthInterfaces != nil) {
+				t.Errorf("ParseNodeNorthInterfaces() returned found %v", ok)
+			}
+			if diff := cmp.Diff(tc.wantNorthInterfaces, northInterfaces); diff != "" {
+				t.Errorf("ParseNodeNorthInterfaces() mismatch (-want +got):\n%s", diff)
+			}
+			nodeNetworks, ok, err := ParseNodeMultiNetwork(tc.annotations)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseNodeMultiNetwork() returned err %v, want error %v", err, tc.wantErr)
+			}
+			if ok != (tc.wantNodeNetworks != nil) {
+				t.Errorf("ParseNodeMultiNetwork() returned found %v", ok)
+			}
+			if diff := cmp.Diff(tc.wantNodeNetworks, nodeNetworks); diff != "" {
+				t.Errorf("ParseNodeMultiNetwork() mismatch (-want +got):\n%s", diff)
+			}
+			if got, want := HasNodeAnnotations(tc.annotations), len(tc.annotations) > 0; got != want {
+				t.Errorf("HasNodeAnnotations() = %v, want %v", got, want)
+			}
+		})
+	}
+}
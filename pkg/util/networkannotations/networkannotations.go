@@ -0,0 +1,161 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package networkannotations parses and validates the multi-network
+// annotations of nodes and pods.
+//
+// The parsers of the network API only decode the JSON of the annotations, so
+// a corrupted annotation, e.g. written by hand or by an older agent, yields
+// entries without a network name or with an invalid CIDR that every
+// controller reading it must guard against. The parsers of this package
+// reject such annotations with an error naming the annotation and the invalid
+// field, e.g.
+//
+//	networking.gke.io/networks[1].cidrs[0]: Invalid value: "10.0.0.0/33": must be a valid CIDR
+//
+// Unknown fields are ignored, so that annotations written by newer versions
+// can still be read.
+package networkannotations
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	netutils "k8s.io/utils/net"
+)
+
+const (
+	// HostLocalScope is the scope of node networks whose CIDRs are local to
+	// the node.
+	HostLocalScope = "host-local"
+	// GlobalScope is the scope of node networks whose CIDRs are shared across
+	// the node pool.
+	GlobalScope = "global"
+)
+
+var supportedScopes = []string{HostLocalScope, GlobalScope}
+
+// ParseNorthInterfaces parses and validates the value of the
+// networkv1.NorthInterfacesAnnotationKey annotation.
+func ParseNorthInterfaces(annotation string) (networkv1.NorthInterfacesAnnotation, error) {
+	var northInterfaces networkv1.NorthInterfacesAnnotation
+	if err := unmarshal(networkv1.NorthInterfacesAnnotationKey, annotation, &northInterfaces); err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for i, inf := range northInterfaces {
+		path := field.NewPath(networkv1.NorthInterfacesAnnotationKey).Index(i)
+		if inf.Network == "" {
+			errs = append(errs, field.Required(path.Child("network"), ""))
+		}
+		errs = append(errs, validateIP(path.Child("ipAddress"), inf.IpAddress)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return northInterfaces, nil
+}
+
+// ParseMultiNetwork parses and validates the value of the
+// networkv1.MultiNetworkAnnotationKey annotation.
+func ParseMultiNetwork(annotation string) (networkv1.MultiNetworkAnnotation, error) {
+	var nodeNetworks networkv1.MultiNetworkAnnotation
+	if err := unmarshal(networkv1.MultiNetworkAnnotationKey, annotation, &nodeNetworks); err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for i, nw := range nodeNetworks {
+		path := field.NewPath(networkv1.MultiNetworkAnnotationKey).Index(i)
+		if nw.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		}
+		if nw.Scope != HostLocalScope && nw.Scope != GlobalScope {
+			errs = append(errs, field.NotSupported(path.Child("scope"), nw.Scope, supportedScopes))
+		}
+		for j, cidr := range nw.Cidrs {
+			if _, _, err := netutils.ParseCIDRSloppy(cidr); err != nil {
+				errs = append(errs, field.Invalid(path.Child("cidrs").Index(j), cidr, "must be a valid CIDR"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return nodeNetworks, nil
+}
+
+// ParsePodIPs parses and validates the value of the
+// networkv1.PodIPsAnnotationKey annotation.
+func ParsePodIPs(annotation string) (networkv1.PodIPsAnnotation, error) {
+	var podIPs networkv1.PodIPsAnnotation
+	if err := unmarshal(networkv1.PodIPsAnnotationKey, annotation, &podIPs); err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for i, podIP := range podIPs {
+		path := field.NewPath(networkv1.PodIPsAnnotationKey).Index(i)
+		if podIP.NetworkName == "" {
+			errs = append(errs, field.Required(path.Child("networkName"), ""))
+		}
+		errs = append(errs, validateIP(path.Child("ip"), podIP.IP)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return podIPs, nil
+}
+
+// ParseInterfaces parses and validates the value of the
+// networkv1.InterfaceAnnotationKey annotation.
+func ParseInterfaces(annotation string) (networkv1.InterfaceAnnotation, error) {
+	var interfaces networkv1.InterfaceAnnotation
+	if err := unmarshal(networkv1.InterfaceAnnotationKey, annotation, &interfaces); err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for i, inf := range interfaces {
+		path := field.NewPath(networkv1.InterfaceAnnotationKey).Index(i)
+		switch {
+		case inf.Network != nil && inf.Interface != nil:
+			errs = append(errs, field.Forbidden(path.Child("interface"), "may not be set with network"))
+		case inf.Network != nil && *inf.Network == "":
+			errs = append(errs, field.Required(path.Child("network"), ""))
+		case inf.Interface != nil && *inf.Interface == "":
+			errs = append(errs, field.Required(path.Child("interface"), ""))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return interfaces, nil
+}
+
+// unmarshal decodes the JSON list of an annotation.
+func unmarshal(key, annotation string, v interface{}) error {
+	if err := json.Unmarshal([]byte(annotation), v); err != nil {
+		return fmt.Errorf("%s: invalid JSON: %v", key, err)
+	}
+	return nil
+}
+
+func validateIP(path *field.Path, ip string) field.ErrorList {
+	if netutils.ParseIPSloppy(ip) == nil {
+		return field.ErrorList{field.Invalid(path, ip, "must be a valid IP address")}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkannotations
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+)
+
+func TestParseNorthInterfaces(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		annotation string
+		want       networkv1.NorthInterfacesAnnotation
+		wantErr    string
+	}{
+		{
+			desc:       "valid",
+			annotation: `[{"network":"red","ipAddress":"10.0.0.2"},{"network":"blue","ipAddress":"2001:db8::1","ipV6Address":"2001:db8::2"}]`,
+			want:       networkv1.NorthInterfacesAnnotation{{Network: "red", IpAddress: "10.0.0.2"}, {Network: "blue", IpAddress: "2001:db8::1", IpV6Address: "2001:db8::2"}},
+		},
+		{
+			desc:       "empty list",
+			annotation: `[]`,
+			want:       networkv1.NorthInterfacesAnnotation{},
+		},
+		{
+			desc:       "not a list",
+			annotation: `{"network":"red"}`,
+			wantErr:    "networking.gke.io/north-interfaces: invalid JSON: json: cannot unmarshal object into Go value of type v1.NorthInterfacesAnnotation",
+		},
+		{
+			desc:       "missing network",
+			annotation: `[{"ipAddress":"10.0.0.2"}]`,
+			wantErr:    "networking.gke.io/north-interfaces[0].network: Required value",
+		},
+		{
+			desc:       "invalid IP",
+			annotation: `[{"network":"red","ipAddress":"10.0.0.2"},{"network":"blue","ipAddress":"10.0.0"}]`,
+			wantErr:    `networking.gke.io/north-interfaces[1].ipAddress: Invalid value: "10.0.0": must be a valid IP address`,
+		},
+		{
+			desc:       "null entry",
+			annotation: `[null]`,
+			wantErr:    `[networking.gke.io/north-interfaces[0].network: Required value, networking.gke.io/north-interfaces[0].ipAddress: Invalid value: "": must be a valid IP address]`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseNorthInterfaces(tc.annotation)
+			if gotErr := errString(err); gotErr != tc.wantErr {
+				t.Fatalf("ParseNorthInterfaces() returned err %q, want %q", gotErr, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseNorthInterfaces() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMultiNetwork(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		annotation string
+		want       networkv1.MultiNetworkAnnotation
+		wantErr    string
+	}{
+		{
+			desc:       "valid",
+			annotation: `[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24","2001:db8::/112"]},{"name":"blue","scope":"global","cidrs":[]}]`,
+			want: networkv1.MultiNetworkAnnotation{
+				{Name: "red", Scope: "host-local", Cidrs: []string{"172.16.0.0/24", "2001:db8::/112"}},
+				{Name: "blue", Scope: "global", Cidrs: []string{}},
+			},
+		},
+		{
+			desc:       "invalid JSON",
+			annotation: `[{"name":"red"`,
+			wantErr:    "networking.gke.io/networks: invalid JSON: unexpected end of JSON input",
+		},
+		{
+			desc:       "missing name",
+			annotation: `[{"scope":"host-local","cidrs":["172.16.0.0/24"]}]`,
+			wantErr:    "networking.gke.io/networks[0].name: Required value",
+		},
+		{
+			desc:       "unknown scope",
+			annotation: `[{"name":"red","scope":"cluster","cidrs":["172.16.0.0/24"]}]`,
+			wantErr:    `networking.gke.io/networks[0].scope: Unsupported value: "cluster": supported values: "host-local", "global"`,
+		},
+		{
+			desc:       "invalid CIDR",
+			annotation: `[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24","172.16.1.0/33"]}]`,
+			wantErr:    `networking.gke.io/networks[0].cidrs[1]: Invalid value: "172.16.1.0/33": must be a valid CIDR`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseMultiNetwork(tc.annotation)
+			if gotErr := errString(err); gotErr != tc.wantErr {
+				t.Fatalf("ParseMultiNetwork() returned err %q, want %q", gotErr, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseMultiNetwork() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParsePodIPs(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		annotation string
+		want       networkv1.PodIPsAnnotation
+		wantErr    string
+	}{
+		{
+			desc:       "valid",
+			annotation: `[{"networkName":"red","ip":"172.16.0.5"}]`,
+			want:       networkv1.PodIPsAnnotation{{NetworkName: "red", IP: "172.16.0.5"}},
+		},
+		{
+			desc:       "missing network name",
+			annotation: `[{"ip":"172.16.0.5"}]`,
+			wantErr:    "networking.gke.io/pod-ips[0].networkName: Required value",
+		},
+		{
+			desc:       "invalid IP",
+			annotation: `[{"networkName":"red","ip":"172.16.0.5/32"}]`,
+			wantErr:    `networking.gke.io/pod-ips[0].ip: Invalid value: "172.16.0.5/32": must be a valid IP address`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParsePodIPs(tc.annotation)
+			if gotErr := errString(err); gotErr != tc.wantErr {
+				t.Fatalf("ParsePodIPs() returned err %q, want %q", gotErr, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParsePodIPs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseInterfaces(t *testing.T) {
+	red, nic := "red", "nic"
+	testCases := []struct {
+		desc       string
+		annotation string
+		want       networkv1.InterfaceAnnotation
+		wantErr    string
+	}{
+		{
+			desc:       "valid",
+			annotation: `[{"interfaceName":"eth0","network":"default"},{"interfaceName":"eth1","network":"red"},{"interfaceName":"eth2","interface":"nic"}]`,
+			want: networkv1.InterfaceAnnotation{
+				{InterfaceName: "eth0", Network: stringPtr("default")},
+				{InterfaceName: "eth1", Network: &red},
+				{InterfaceName: "eth2", Interface: &nic},
+			},
+		},
+		{
+			desc:       "network and interface",
+			annotation: `[{"interfaceName":"eth1","network":"red","interface":"nic"}]`,
+			wantErr:    "networking.gke.io/interfaces[0].interface: Forbidden: may not be set with network",
+		},
+		{
+			desc:       "empty network",
+			annotation: `[{"interfaceName":"eth1","network":""}]`,
+			wantErr:    "networking.gke.io/interfaces[0].network: Required value",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseInterfaces(tc.annotation)
+			if gotErr := errString(err); gotErr != tc.wantErr {
+				t.Fatalf("ParseInterfaces() returned err %q, want %q", gotErr, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseInterfaces() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// The fuzz tests check that the parsers never panic, and that an accepted
+// annotation is accepted again once marshaled, as controllers re-publish the
+// values they parse.
+
+func FuzzParseNorthInterfaces(f *testing.F) {
+	for _, seed := range []string{
+		`[{"network":"red","ipAddress":"10.0.0.2"}]`,
+		`[{"network":"red","ipAddress":"2001:db8::1","ipV6Address":"2001:db8::2"}]`,
+		`[null]`,
+		`null`,
+		`[{"network":1}]`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, annotation string) {
+		northInterfaces, err := ParseNorthInterfaces(annotation)
+		if err != nil {
+			return
+		}
+		marshaled, err := networkv1.MarshalAnnotation(northInterfaces)
+		if err != nil {
+			t.Fatalf("MarshalAnnotation(%v) returned err %v", northInterfaces, err)
+		}
+		if _, err := ParseNorthInterfaces(marshaled); err != nil {
+			t.Errorf("ParseNorthInterfaces(%q) returned err %v for a value accepted from %q", marshaled, err, annotation)
+		}
+	})
+}
+
+func FuzzParseMultiNetwork(f *testing.F) {
+	for _, seed := range []string{
+		`[{"name":"red","scope":"host-local","cidrs":["172.16.0.0/24"]}]`,
+		`[{"name":"red","scope":"global","cidrs":["2001:db8::/112","172.16.0.0/24"]}]`,
+		`[{"name":"red","scope":"host-local","cidrs":null}]`,
+		`[{"name":"red","scope":"host-local","cidrs":["010.16.0.0/24"]}]`,
+		`{}`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, annotation string) {
+		nodeNetworks, err := ParseMultiNetwork(annotation)
+		if err != nil {
+			return
+		}
+		marshaled, err := networkv1.MarshalAnnotation(nodeNetworks)
+		if err != nil {
+			t.Fatalf("MarshalAnnotation(%v) returned err %v", nodeNetworks, err)
+		}
+		if _, err := ParseMultiNetwork(marshaled); err != nil {
+			t.Errorf("ParseMultiNetwork(%q) returned err %v for a value accepted from %q", marshaled, err, annotation)
+		}
+	})
+}
+
+func FuzzParsePodIPs(f *testing.F) {
+	for _, seed := range []string{
+		`[{"networkName":"red","ip":"172.16.0.5"}]`,
+		`[{"networkName":"red","ip":"::ffff:172.16.0.5"}]`,
+		`[{}]`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, annotation string) {
+		podIPs, err := ParsePodIPs(annotation)
+		if err != nil {
+			return
+		}
+		marshaled, err := networkv1.MarshalAnnotation(podIPs)
+		if err != nil {
+			t.Fatalf("MarshalAnnotation(%v) returned err %v", podIPs, err)
+		}
+		if _, err := ParsePodIPs(marshaled); err != nil {
+			t.Errorf("ParsePodIPs(%q) returned err %v for a value accepted from %q", marshaled, err, annotation)
+		}
+	})
+}
+
+func FuzzParseInterfaces(f *testing.F) {
+	for _, seed := range []string{
+		`[{"interfaceName":"eth1","network":"red"}]`,
+		`[{"interfaceName":"eth1","interface":"nic"}]`,
+		`[{"network":null}]`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, annotation string) {
+		interfaces, err := ParseInterfaces(annotation)
+		if err != nil {
+			return
+		}
+		marshaled, err := networkv1.MarshalAnnotation(interfaces)
+		if err != nil {
+			t.Fatalf("MarshalAnnotation(%v) returned err %v", interfaces, err)
+		}
+		if _, err := ParseInterfaces(marshaled); err != nil {
+			t.Errorf("ParseInterfaces(%q) returned err %v for a value accepted from %q", marshaled, err, annotation)
+		}
+	})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
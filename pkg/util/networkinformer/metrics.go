@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkinformer
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const networkInformerSubsystem = "network_informer"
+
+var (
+	lists = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      networkInformerSubsystem,
+			Name:           "lists_total",
+			Help:           "Number of full lists of the network CRDs, by resource.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource"},
+	)
+	throttledRelists = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      networkInformerSubsystem,
+			Name:           "throttled_relists_total",
+			Help:           "Number of re-lists of the network CRDs delayed to respect the minimum re-list interval, by resource.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource"},
+	)
+	watchRestarts = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      networkInformerSubsystem,
+			Name:           "watch_restarts_total",
+			Help:           "Number of times the watch of the network CRDs was restarted, by resource.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource"},
+	)
+)
+
+var registerOnce sync.Once
+
+// registerMetrics registers the metrics of the network informers.
+func registerMetrics() {
+	registerOnce.Do(func() {
+		legacyregistry.MustRegister(lists)
+		legacyregistry.MustRegister(throttledRelists)
+		legacyregistry.MustRegister(watchRestarts)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package networkinformer builds the informers of the Network and
+// GKENetworkParamSet CRDs shared by the controllers of the cloud controller
+// manager.
+//
+// Clusters may have hundreds of these objects, so the informers avoid full
+// re-lists where they can:
+//
+//   - watches request bookmarks, so that the resourceVersion of the informer
+//     keeps up with the API server even when the objects do not change, and a
+//     restarted watch resumes from it instead of failing with "resource version
+//     too old" and re-listing;
+//   - re-lists that still happen are spaced at least minRelistInterval apart,
+//     so that a flapping watch does not turn into a stream of full lists.
+//
+// The lists and watch restarts are exported as metrics.
+package networkinformer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkv1alpha1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1alpha1"
+	networkclientset "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned"
+	networkinformers "k8s.io/cloud-provider-gcp/crd/client/network/informers/externalversions"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// minRelistInterval is the minimum time between two lists of the same resource.
+const minRelistInterval = 10 * time.Second
+
+// NewSharedInformerFactory returns a shared informer factory for the network
+// CRDs whose Network and GKENetworkParamSet informers use bookmarks and
+// throttled re-lists.
+func NewSharedInformerFactory(client networkclientset.Interface, resyncPeriod time.Duration) networkinformers.SharedInformerFactory {
+	registerMetrics()
+	factory := networkinformers.NewSharedInformerFactory(client, resyncPeriod)
+	// The factory keeps the first informer registered for a type, so the
+	// informers returned by Networking() are the ones built here.
+	factory.InformerFor(&networkv1.Network{}, func(client networkclientset.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+		lw := newThrottledListWatch("networks", clock.RealClock{}, &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1().Networks().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1().Networks().Watch(context.TODO(), options)
+			},
+		})
+		return cache.NewSharedIndexInformer(lw, &networkv1.Network{}, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+	factory.InformerFor(&networkv1alpha1.GKENetworkParamSet{}, func(client networkclientset.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+		lw := newThrottledListWatch("gkenetworkparamsets", clock.RealClock{}, &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1alpha1().GKENetworkParamSets().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1alpha1().GKENetworkParamSets().Watch(context.TODO(), options)
+			},
+		})
+		return cache.NewSharedIndexInformer(lw, &networkv1alpha1.GKENetworkParamSet{}, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+	return factory
+}
+
+// throttledListWatch is a cache.ListerWatcher that requests watch bookmarks,
+// spaces lists at least minRelistInterval apart and counts both.
+type throttledListWatch struct {
+	resource string
+	clock    clock.Clock
+	lw       cache.ListerWatcher
+
+	lock     sync.Mutex
+	lastList time.Time
+	watched  bool
+}
+
+func newThrottledListWatch(resource string, clock clock.Clock, lw cache.ListerWatcher) *throttledListWatch {
+	return &throttledListWatch{resource: resource, clock: clock, lw: lw}
+}
+
+func (t *throttledListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	t.lock.Lock()
+	if !t.lastList.IsZero() {
+		if wait := minRelistInterval - t.clock.Since(t.lastList); wait > 0 {
+			klog.V(2).Infof("Delaying re-list of %s by %v", t.resource, wait)
+			throttledRelists.WithLabelValues(t.resource).Inc()
+			t.clock.Sleep(wait)
+		}
+	}
+	t.lastList = t.clock.Now()
+	t.lock.Unlock()
+
+	lists.WithLabelValues(t.resource).Inc()
+	return t.lw.List(options)
+}
+
+func (t *throttledListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	t.lock.Lock()
+	if t.watched {
+		klog.V(4).Infof("Restarting watch of %s from resourceVersion %q", t.resource, options.ResourceVersion)
+		watchRestarts.WithLabelValues(t.resource).Inc()
+	}
+	t.watched = true
+	t.lock.Unlock()
+
+	options.AllowWatchBookmarks = true
+	return t.lw.Watch(options)
+}
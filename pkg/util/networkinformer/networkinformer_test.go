@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkinformer
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	networkv1 "k8s.io/cloud-provider-gcp/crd/apis/network/v1"
+	networkfake "k8s.io/cloud-provider-gcp/crd/client/network/clientset/versioned/fake"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestThrottledListWatch(t *testing.T) {
+	start := time.Now()
+	fakeClock := testingclock.NewFakeClock(start)
+	var watchOptions []metav1.ListOptions
+	lw := newThrottledListWatch("networks", fakeClock, &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &networkv1.NetworkList{}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			watchOptions = append(watchOptions, options)
+			return watch.NewFake(), nil
+		},
+	})
+
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("List() returned error: %v", err)
+	}
+	if got := fakeClock.Since(start); got != 0 {
+		t.Errorf("first List() waited %v, want no wait", got)
+	}
+	fakeClock.Step(4 * time.Second)
+	if _, err := lw.List(metav1.ListOptions{ResourceVersion: "10"}); err != nil {
+		t.Fatalf("List() returned error: %v", err)
+	}
+	if got := fakeClock.Since(start); got != minRelistInterval {
+		t.Errorf("re-list after 4s happened %v after the first list, want %v", got, minRelistInterval)
+	}
+	fakeClock.Step(2 * minRelistInterval)
+	now := fakeClock.Now()
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("List() returned error: %v", err)
+	}
+	if got := fakeClock.Since(now); got != 0 {
+		t.Errorf("re-list after %v waited %v, want no wait", 2*minRelistInterval, got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := lw.Watch(metav1.ListOptions{ResourceVersion: "10"}); err != nil {
+			t.Fatalf("Watch() returned error: %v", err)
+		}
+	}
+	for _, options := range watchOptions {
+		if !options.AllowWatchBookmarks {
+			t.Errorf("Watch() options = %+v, want AllowWatchBookmarks", options)
+		}
+		if options.ResourceVersion != "10" {
+			t.Errorf("Watch() resourceVersion = %q, want %q", options.ResourceVersion, "10")
+		}
+	}
+}
+
+func TestNewSharedInformerFactory(t *testing.T) {
+	network := &networkv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "blue-network"}}
+	client := networkfake.NewSimpleClientset(network)
+	factory := NewSharedInformerFactory(client, 0)
+	informer := factory.Networking().V1().Networks()
+	// Instantiate the GKENetworkParamSet informer so that the factory starts it.
+	factory.Networking().V1alpha1().GKENetworkParamSets().Informer()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	for typ, synced := range factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			t.Fatalf("informer for %v did not sync", typ)
+		}
+	}
+	if _, err := informer.Lister().Get(network.Name); err != nil {
+		t.Errorf("Lister().Get(%q) returned error: %v", network.Name, err)
+	}
+}
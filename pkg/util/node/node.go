@@ -92,3 +92,31 @@ func PatchNodeCIDRs(c clientset.Interface, node types.NodeName, cidrs []string)
 	}
 	return nil
 }
+
+type nodeForLabelsMergePatch struct {
+	Metadata nodeMetadataForMergePatch `json:"metadata"`
+}
+
+type nodeMetadataForMergePatch struct {
+	Labels map[string]*string `json:"labels"`
+}
+
+// PatchNodeLabels sets the given labels on the node. Labels mapped to nil are
+// removed from the node.
+func PatchNodeLabels(c clientset.Interface, node types.NodeName, labels map[string]*string) error {
+	patch := nodeForLabelsMergePatch{
+		Metadata: nodeMetadataForMergePatch{
+			Labels: labels,
+		},
+	}
+
+	patchBytes, err := json.Marshal(&patch)
+	if err != nil {
+		return fmt.Errorf("failed to json.Marshal labels: %v", err)
+	}
+	klog.V(4).Infof("labels patch bytes are:%s", string(patchBytes))
+	if _, err := c.CoreV1().Nodes().Patch(context.TODO(), string(node), types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch node labels: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// metadataTokenEarlyRefresh is how long before its expiry the cached
+	// metadata server token is refreshed. Tokens of the metadata server are
+	// valid for about an hour.
+	metadataTokenEarlyRefresh = 5 * time.Minute
+)
+
+var (
+	metadataTokenRequestCounter = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "metadata_token_request_count",
+			Help:           "Counter of total token requests to the metadata server",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	sharedMetadataTokenSourceOnce sync.Once
+	sharedMetadataTokenSource     oauth2.TokenSource
+)
+
+func init() {
+	legacyregistry.MustRegister(metadataTokenRequestCounter)
+}
+
+// MetadataTokenSource returns the token source of the default service account
+// of the instance, shared by all its callers in the process. The token is
+// cached and refreshed ahead of its expiry, and concurrent demands of a new
+// token make a single request to the metadata server.
+func MetadataTokenSource() oauth2.TokenSource {
+	sharedMetadataTokenSourceOnce.Do(func() {
+		sharedMetadataTokenSource = NewCachingTokenSource(metadataTokenSource{}, metadataTokenEarlyRefresh)
+	})
+	return sharedMetadataTokenSource
+}
+
+// cachingTokenSource caches the token of a source, refreshing it when less
+// than earlyRefresh is left before its expiry. Concurrent callers needing a
+// new token share a single call to the source.
+type cachingTokenSource struct {
+	source       oauth2.TokenSource
+	earlyRefresh time.Duration
+	now          func() time.Time
+	group        singleflight.Group
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewCachingTokenSource returns a token source caching the tokens of source
+// and refreshing them earlyRefresh before their expiry. Unlike
+// oauth2.ReuseTokenSource, a failed early refresh keeps serving the cached
+// token until it expires.
+func NewCachingTokenSource(source oauth2.TokenSource, earlyRefresh time.Duration) oauth2.TokenSource {
+	return &cachingTokenSource{
+		source:       source,
+		earlyRefresh: earlyRefresh,
+		now:          time.Now,
+	}
+}
+
+// Token returns the cached token or a new one from the source.
+func (c *cachingTokenSource) Token() (*oauth2.Token, error) {
+	c.mu.Lock()
+	cached := c.token
+	c.mu.Unlock()
+	if c.fresh(cached) {
+		return cached, nil
+	}
+	tok, err, _ := c.group.Do("token", func() (interface{}, error) {
+		tok, err := c.source.Token()
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.token = tok
+		c.mu.Unlock()
+		return tok, nil
+	})
+	if err != nil {
+		if cached != nil && c.now().Before(cached.Expiry) {
+			klog.Warningf("Failed to refresh the token ahead of its expiry at %v: %v", cached.Expiry, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	return tok.(*oauth2.Token), nil
+}
+
+// fresh returns true if the token does not need a refresh yet.
+func (c *cachingTokenSource) fresh(tok *oauth2.Token) bool {
+	if tok == nil || tok.AccessToken == "" {
+		return false
+	}
+	return tok.Expiry.IsZero() || c.now().Add(c.earlyRefresh).Before(tok.Expiry)
+}
+
+// metadataTokenSource requests a token of the default service account from
+// the metadata server on every call. Unlike google.ComputeTokenSource, it does
+// not cache the token, which is left to cachingTokenSource.
+type metadataTokenSource struct{}
+
+func (metadataTokenSource) Token() (*oauth2.Token, error) {
+	if !metadata.OnGCE() {
+		return nil, errors.New("can't get a token from the metadata server: not running on GCE")
+	}
+	metadataTokenRequestCounter.Inc()
+	tokenJSON, err := metadata.Get("instance/service-accounts/default/token")
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		AccessToken  string `json:"access_token" datapolicy:"token"`
+		ExpiresInSec int    `json:"expires_in"`
+		TokenType    string `json:"token_type"`
+	}
+	if err := json.NewDecoder(strings.NewReader(tokenJSON)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("invalid token JSON from the metadata server: %v", err)
+	}
+	if res.ExpiresInSec == 0 || res.AccessToken == "" {
+		return nil, errors.New("incomplete token received from the metadata server")
+	}
+	tok := &oauth2.Token{
+		AccessToken: res.AccessToken,
+		TokenType:   res.TokenType,
+		Expiry:      time.Now().Add(time.Duration(res.ExpiresInSec) * time.Second),
+	}
+	// Client libraries detect credentials of the metadata server from this
+	// extra, like for the tokens of google.ComputeTokenSource.
+	return tok.WithExtra(map[string]interface{}{
+		"oauth2.google.tokenSource":    "compute-metadata",
+		"oauth2.google.serviceAccount": "default",
+	}), nil
+}
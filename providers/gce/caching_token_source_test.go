@@ -0,0 +1,135 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// countingTokenSource returns a new token valid for ttl on every call, or err,
+// after waiting for release if set.
+type countingTokenSource struct {
+	mu      sync.Mutex
+	calls   int
+	now     func() time.Time
+	ttl     time.Duration
+	err     error
+	release chan struct{}
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &oauth2.Token{
+		AccessToken: fmt.Sprintf("token-%d", s.calls),
+		Expiry:      s.now().Add(s.ttl),
+	}, nil
+}
+
+func TestCachingTokenSourceEarlyRefresh(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	source := &countingTokenSource{now: clock, ttl: time.Hour}
+	ts := NewCachingTokenSource(source, 5*time.Minute).(*cachingTokenSource)
+	ts.now = clock
+
+	tok, err := ts.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok.AccessToken)
+
+	now = now.Add(54 * time.Minute)
+	tok, err = ts.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok.AccessToken, "token outside of the early refresh window")
+
+	now = now.Add(2 * time.Minute)
+	tok, err = ts.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", tok.AccessToken, "token inside of the early refresh window")
+	assert.Equal(t, 2, source.calls)
+}
+
+func TestCachingTokenSourceRefreshError(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	source := &countingTokenSource{now: clock, ttl: time.Hour}
+	ts := NewCachingTokenSource(source, 5*time.Minute).(*cachingTokenSource)
+	ts.now = clock
+
+	_, err := ts.Token()
+	require.NoError(t, err)
+
+	// A failed early refresh serves the cached token until it expires.
+	source.err = errors.New("metadata server unavailable")
+	now = now.Add(58 * time.Minute)
+	tok, err := ts.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok.AccessToken)
+
+	now = now.Add(2 * time.Minute)
+	_, err = ts.Token()
+	assert.Error(t, err)
+}
+
+func TestCachingTokenSourceSingleflight(t *testing.T) {
+	source := &countingTokenSource{now: time.Now, ttl: time.Hour, release: make(chan struct{})}
+	ts := NewCachingTokenSource(source, 5*time.Minute)
+
+	const callers = 10
+	var started, done sync.WaitGroup
+	started.Add(callers)
+	done.Add(callers)
+	tokens := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer done.Done()
+			started.Done()
+			tok, err := ts.Token()
+			if err == nil {
+				tokens[i] = tok.AccessToken
+			}
+		}(i)
+	}
+	started.Wait()
+	// Let the callers join the in-flight call before releasing it.
+	time.Sleep(100 * time.Millisecond)
+	close(source.release)
+	done.Wait()
+
+	assert.Equal(t, 1, source.calls)
+	for i, tok := range tokens {
+		assert.Equal(t, "token-1", tok, "caller %d", i)
+	}
+}
@@ -40,6 +40,7 @@ import (
 	compute "google.golang.org/api/compute/v1"
 	container "google.golang.org/api/container/v1"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
 
@@ -55,6 +56,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/flowcontrol"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
 	"k8s.io/klog/v2"
 )
 
@@ -102,6 +104,64 @@ const NetworkStackIPV6 StackType = "IPV6"
 
 // Cloud is an implementation of Interface, LoadBalancer and Instances for Google Compute Engine.
 type Cloud struct {
+	// cloudState is the state of the Cloud shared with the Clouds returned
+	// by ForController.
+	*cloudState
+
+	service          *compute.Service
+	serviceBeta      *computebeta.Service
+	serviceAlpha     *computealpha.Service
+	containerService *container.Service
+	tpuService       *tpuService
+	// computeClient is the HTTP client of the compute services, see
+	// ForController.
+	computeClient *http.Client
+	projectID     string
+	region        string
+	regional      bool
+	localZone     string // The zone in which we are running
+	// allZonesManaged is true if all the zones of the region are managed,
+	// see managedZones.
+	allZonesManaged bool
+	// instanceChangeFeed delivers the changes of the instances to the
+	// instance watcher, nil if there is none.
+	instanceChangeFeed InstanceChangeFeed
+	networkURL         string
+	// DEPRECATED: Do not rely on this value as it may be incorrect.
+	secondaryRangeName       string
+	networkProjectID         string
+	onXPN                    bool
+	nodeTags                 []string // List of tags to use on firewall rules for load balancers
+	nodeInstancePrefix       string   // If non-"", an advisory prefix for all nodes in the cluster
+	useMetadataServer        bool
+	operationPollRateLimiter flowcontrol.RateLimiter
+	manager                  diskServiceManager
+	// AlphaFeatureGate gates gce alpha features in Cloud instance.
+	// Related wrapper functions that interacts with gce alpha api should examine whether
+	// the corresponding api is enabled.
+	// If not enabled, it should return error.
+	AlphaFeatureGate *AlphaFeatureGate
+
+	// New code generated interface to the GCE compute library.
+	c cloud.Cloud
+
+	// Keep a reference of this around so we can inject a new cloud.RateLimiter implementation.
+	s *cloud.Service
+
+	metricsCollector loadbalancerMetricsCollector
+
+	// the compute API endpoint with the `projects/` element.
+	projectsBasePath string
+	// stackType indicates whether the cluster is a single stack IPv4, single
+	// stack IPv6 or a dual stack cluster
+	stackType StackType
+}
+
+// cloudState is the part of a Cloud that changes after it is created. It is
+// shared by the Clouds returned by ForController, so that they see the same
+// nodes, zones and instances and serialize their operations on the same
+// locks.
+type cloudState struct {
 	// ClusterID contains functionality for getting (and initializing) the ingress-uid. Call Cloud.Initialize()
 	// for the cloudprovider to start watching the configmap.
 	ClusterID ClusterID
@@ -113,42 +173,31 @@ type Cloud struct {
 	// it is run from Kubelets, as there can be thousands  of them.
 	subnetworkURLAndIsLegacyNetworkInitializer sync.Once
 
-	service          *compute.Service
-	serviceBeta      *computebeta.Service
-	serviceAlpha     *computealpha.Service
-	containerService *container.Service
-	tpuService       *tpuService
 	client           clientset.Interface
 	clientBuilder    cloudprovider.ControllerClientBuilder
 	eventBroadcaster record.EventBroadcaster
 	eventRecorder    record.EventRecorder
-	projectID        string
-	region           string
-	regional         bool
-	localZone        string // The zone in which we are running
 	// managedZones will be set to the 1 zone if running a single zone cluster
 	// it will be set to ALL zones in region for any multi-zone cluster
 	// Use GetAllCurrentZones to get only zones that contain nodes
-	managedZones []string
-	networkURL   string
+	// Read it with getManagedZones, as it follows the zones of the region
+	// when allZonesManaged is true.
+	managedZones     []string
+	managedZonesLock sync.RWMutex
+	// zonesCache holds the zones of the project, see ListZonesInRegion.
+	zonesCache zonesCache
+	// instancesWatcher holds the instances of the managed zones, see
+	// AlphaFeatureSharedInstanceWatcher.
+	instancesWatcher instancesWatcher
 	// unsafeIsLegacyNetwork should be used only via IsLegacyNetwork() accessor,
 	// to ensure it was properly initialized.
 	unsafeIsLegacyNetwork bool
 	// unsafeSubnetworkURL should be used only via SubnetworkURL() accessor,
 	// to ensure it was properly initialized.
-	unsafeSubnetworkURL string
-	// DEPRECATED: Do not rely on this value as it may be incorrect.
-	secondaryRangeName       string
-	networkProjectID         string
-	onXPN                    bool
-	nodeTags                 []string    // List of tags to use on firewall rules for load balancers
-	lastComputedNodeTags     []string    // List of node tags calculated in GetHostTags()
-	lastKnownNodeNames       sets.String // List of hostnames used to calculate lastComputedHostTags in GetHostTags(names)
-	computeNodeTagLock       sync.Mutex  // Lock for computing and setting node tags
-	nodeInstancePrefix       string      // If non-"", an advisory prefix for all nodes in the cluster
-	useMetadataServer        bool
-	operationPollRateLimiter flowcontrol.RateLimiter
-	manager                  diskServiceManager
+	unsafeSubnetworkURL  string
+	lastComputedNodeTags []string    // List of node tags calculated in GetHostTags()
+	lastKnownNodeNames   sets.String // List of hostnames used to calculate lastComputedHostTags in GetHostTags(names)
+	computeNodeTagLock   sync.Mutex  // Lock for computing and setting node tags
 	// Lock for access to nodeZones
 	nodeZonesLock sync.Mutex
 	// nodeZones is a mapping from Zone to a sets.String of Node's names in the Zone
@@ -160,25 +209,6 @@ type Cloud struct {
 	// lock to prevent shared resources from being prematurely deleted while the operation is
 	// in progress.
 	sharedResourceLock sync.Mutex
-	// AlphaFeatureGate gates gce alpha features in Cloud instance.
-	// Related wrapper functions that interacts with gce alpha api should examine whether
-	// the corresponding api is enabled.
-	// If not enabled, it should return error.
-	AlphaFeatureGate *AlphaFeatureGate
-
-	// New code generated interface to the GCE compute library.
-	c cloud.Cloud
-
-	// Keep a reference of this around so we can inject a new cloud.RateLimiter implementation.
-	s *cloud.Service
-
-	metricsCollector loadbalancerMetricsCollector
-
-	// the compute API endpoint with the `projects/` element.
-	projectsBasePath string
-	// stackType indicates whether the cluster is a single stack IPv4, single
-	// stack IPv6 or a dual stack cluster
-	stackType StackType
 }
 
 // ConfigGlobal is the in memory representation of the gce.conf config data
@@ -216,6 +246,12 @@ type ConfigGlobal struct {
 	// Default to none.
 	// For example: MyFeatureFlag
 	AlphaFeatures []string `gcfg:"alpha-features"`
+	// InstanceChangeSubscription is the Pub/Sub subscription, of the form
+	// projects/PROJECT/subscriptions/SUBSCRIPTION, to a Cloud Asset Inventory
+	// feed of the instances of the project. If set, the changes of the
+	// instances are delivered to the controllers as they happen, and the
+	// shared instance watcher lists the instances less often.
+	InstanceChangeSubscription string `gcfg:"instance-change-subscription"`
 }
 
 // ConfigFile is the struct used to parse the /etc/gce.conf configuration file.
@@ -250,6 +286,9 @@ type CloudConfig struct {
 	UseMetadataServer  bool
 	AlphaFeatureGate   *AlphaFeatureGate
 	StackType          string
+	// InstanceChangeSubscription is the Pub/Sub subscription of the instance
+	// change feed, see ConfigGlobal.
+	InstanceChangeSubscription string
 }
 
 func init() {
@@ -315,7 +354,7 @@ func readConfig(reader io.Reader) (*ConfigFile, error) {
 func generateCloudConfig(configFile *ConfigFile) (cloudConfig *CloudConfig, err error) {
 	cloudConfig = &CloudConfig{}
 	// By default, fetch token from GCE metadata server
-	cloudConfig.TokenSource = google.ComputeTokenSource("")
+	cloudConfig.TokenSource = MetadataTokenSource()
 	cloudConfig.UseMetadataServer = true
 	cloudConfig.AlphaFeatureGate = NewAlphaFeatureGate([]string{})
 	if configFile != nil {
@@ -409,6 +448,7 @@ func generateCloudConfig(configFile *ConfigFile) (cloudConfig *CloudConfig, err
 
 	if configFile != nil {
 		cloudConfig.StackType = configFile.Global.StackType
+		cloudConfig.InstanceChangeSubscription = configFile.Global.InstanceChangeSubscription
 	}
 
 	return cloudConfig, err
@@ -432,19 +472,30 @@ func CreateGCECloud(config *CloudConfig) (*Cloud, error) {
 		config.NetworkProjectID = config.ProjectID
 	}
 
-	service, err := compute.NewService(context.Background(), option.WithTokenSource(config.TokenSource))
+	// All versions of the compute service share a client recording the calls
+	// made per method, see computeMetricsTransport, and retrying the calls
+	// throttled by the API, see computeRetryTransport.
+	computeTransport, err := htransport.NewTransport(context.Background(), newComputeRetryTransport(newComputeMetricsTransport(http.DefaultTransport)),
+		option.WithTokenSource(config.TokenSource), option.WithScopes(compute.CloudPlatformScope, compute.ComputeScope))
+	if err != nil {
+		return nil, err
+	}
+	computeHTTPClient := &http.Client{Transport: computeTransport}
+	computeClient := option.WithHTTPClient(computeHTTPClient)
+
+	service, err := compute.NewService(context.Background(), computeClient)
 	if err != nil {
 		return nil, err
 	}
 	service.UserAgent = userAgent
 
-	serviceBeta, err := computebeta.NewService(context.Background(), option.WithTokenSource(config.TokenSource))
+	serviceBeta, err := computebeta.NewService(context.Background(), computeClient)
 	if err != nil {
 		return nil, err
 	}
 	serviceBeta.UserAgent = userAgent
 
-	serviceAlpha, err := computealpha.NewService(context.Background(), option.WithTokenSource(config.TokenSource))
+	serviceAlpha, err := computealpha.NewService(context.Background(), computeClient)
 	if err != nil {
 		return nil, err
 	}
@@ -509,7 +560,8 @@ func CreateGCECloud(config *CloudConfig) (*Cloud, error) {
 	// the provider is initialized also for Kubelets (and there can be thousands
 	// of them) we defer to lazy initialization here.
 
-	if len(config.ManagedZones) == 0 {
+	allZonesManaged := len(config.ManagedZones) == 0
+	if allZonesManaged {
 		config.ManagedZones, err = getZonesForRegion(service, config.ProjectID, config.Region)
 		if err != nil {
 			return nil, err
@@ -522,28 +574,32 @@ func CreateGCECloud(config *CloudConfig) (*Cloud, error) {
 	operationPollRateLimiter := flowcontrol.NewTokenBucketRateLimiter(5, 5) // 5 qps, 5 burst.
 
 	gce := &Cloud{
+		cloudState: &cloudState{
+			managedZones:          config.ManagedZones,
+			unsafeIsLegacyNetwork: isLegacyNetwork,
+			unsafeSubnetworkURL:   subnetURL,
+			nodeZones:             map[string]sets.String{},
+		},
 		service:                  service,
 		serviceAlpha:             serviceAlpha,
 		serviceBeta:              serviceBeta,
 		containerService:         containerService,
 		tpuService:               tpuService,
+		computeClient:            computeHTTPClient,
 		projectID:                projID,
 		networkProjectID:         netProjID,
 		onXPN:                    onXPN,
 		region:                   config.Region,
 		regional:                 config.Regional,
 		localZone:                config.Zone,
-		managedZones:             config.ManagedZones,
+		allZonesManaged:          allZonesManaged,
 		networkURL:               networkURL,
-		unsafeIsLegacyNetwork:    isLegacyNetwork,
-		unsafeSubnetworkURL:      subnetURL,
 		secondaryRangeName:       config.SecondaryRangeName,
 		nodeTags:                 config.NodeTags,
 		nodeInstancePrefix:       config.NodeInstancePrefix,
 		useMetadataServer:        config.UseMetadataServer,
 		operationPollRateLimiter: operationPollRateLimiter,
 		AlphaFeatureGate:         config.AlphaFeatureGate,
-		nodeZones:                map[string]sets.String{},
 		metricsCollector:         newLoadBalancerMetrics(),
 		projectsBasePath:         getProjectsBasePath(service.BasePath),
 		stackType:                StackType(config.StackType),
@@ -559,9 +615,62 @@ func CreateGCECloud(config *CloudConfig) (*Cloud, error) {
 	}
 	gce.c = cloud.NewGCE(gce.s)
 
+	if config.InstanceChangeSubscription != "" {
+		feed, err := newPubSubInstanceChangeFeed(client, config.InstanceChangeSubscription)
+		if err != nil {
+			return nil, err
+		}
+		gce.instanceChangeFeed = feed
+	}
+
 	return gce, nil
 }
 
+// ForController returns a Cloud sharing the state of g, whose compute API
+// calls are attributed to the named controller in the per-method compute API
+// metrics. Most methods of the Cloud make their calls with a context of their
+// own, which WithController cannot reach.
+//
+// The returned Cloud only owns its compute services: everything set or
+// changed after g is created, e.g. by Initialize, the watchers and the locks,
+// lives in the cloudState it shares with g. It can therefore be created
+// before g is initialized.
+func (g *Cloud) ForController(name string) (*Cloud, error) {
+	if g.computeClient == nil {
+		// Fake clouds have no compute client to tag.
+		return g, nil
+	}
+	computeClient := option.WithHTTPClient(&http.Client{Transport: &controllerTransport{base: g.computeClient.Transport, controller: name}})
+	service, err := compute.NewService(context.Background(), computeClient)
+	if err != nil {
+		return nil, err
+	}
+	service.BasePath, service.UserAgent = g.service.BasePath, g.service.UserAgent
+	serviceBeta, err := computebeta.NewService(context.Background(), computeClient)
+	if err != nil {
+		return nil, err
+	}
+	serviceBeta.BasePath, serviceBeta.UserAgent = g.serviceBeta.BasePath, g.serviceBeta.UserAgent
+	serviceAlpha, err := computealpha.NewService(context.Background(), computeClient)
+	if err != nil {
+		return nil, err
+	}
+	serviceAlpha.BasePath, serviceAlpha.UserAgent = g.serviceAlpha.BasePath, g.serviceAlpha.UserAgent
+
+	gce := *g
+	gce.service, gce.serviceBeta, gce.serviceAlpha = service, serviceBeta, serviceAlpha
+	gce.manager = &gceServiceManager{&gce}
+	gce.s = &cloud.Service{
+		GA:            service,
+		Alpha:         serviceAlpha,
+		Beta:          serviceBeta,
+		ProjectRouter: &gceProjectRouter{&gce},
+		RateLimiter:   &gceRateLimiter{&gce},
+	}
+	gce.c = cloud.NewGCE(gce.s)
+	return &gce, nil
+}
+
 // initializeNetworkConfig() is supposed to be called under sync.Once()
 // for accessors to subnetworkURL and isLegacyNetwork fields.
 func (g *Cloud) initializeSubnetworkURLAndIsLegacyNetwork() {
@@ -576,7 +685,7 @@ func (g *Cloud) initializeSubnetworkURLAndIsLegacyNetwork() {
 	// Determine the type of network and attempt to discover the correct subnet for AUTO mode.
 	// Gracefully fail because kubelet calls CreateGCECloud without any config, and minions
 	// lack the proper credentials for API calls.
-	if networkName := lastComponent(g.NetworkURL()); networkName != "" {
+	if networkName := resourceName(g.NetworkURL()); networkName != "" {
 		if n, err := getNetwork(g.service, g.NetworkProjectID(), networkName); err != nil {
 			klog.Warningf("Could not retrieve network %q; err: %v", networkName, err)
 		} else {
@@ -671,8 +780,15 @@ func (g *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	g.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: g.client.CoreV1().Events("")})
 	g.eventRecorder = g.eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "g-cloudprovider"})
 
-	go g.watchClusterID(stop)
+	g.watchClusterID(stop)
 	go g.metricsCollector.Run(stop)
+	go g.runZonesRefresh(stop)
+	if g.AlphaFeatureGate.Enabled(AlphaFeatureSharedInstanceWatcher) {
+		go g.runInstancesRefresh(stop)
+	}
+	if g.instanceChangeFeed != nil {
+		go g.runInstanceChangeFeed(stop)
+	}
 }
 
 // LoadBalancer returns an implementation of LoadBalancer for Google Compute Engine.
@@ -852,15 +968,16 @@ func gceSubnetworkURL(apiEndpoint, project, region, subnetwork string) string {
 // getRegionInURL parses full resource URLS and shorter URLS
 // https://www.googleapis.com/compute/v1/projects/myproject/regions/us-central1/subnetworks/a
 // projects/myproject/regions/us-central1/subnetworks/a
-// All return "us-central1"
+// All return "us-central1". Malformed URLs return "".
 func getRegionInURL(urlStr string) string {
-	fields := strings.Split(urlStr, "/")
-	for i, v := range fields {
-		if v == "regions" && i < len(fields)-1 {
-			return fields[i+1]
-		}
+	id, err := gcpurl.Parse(urlStr)
+	if err != nil {
+		return ""
 	}
-	return ""
+	if id.Kind == gcpurl.KindRegions {
+		return id.Name
+	}
+	return id.Region
 }
 
 func getNetworkNameViaMetadata() (string, error) {
@@ -868,11 +985,7 @@ func getNetworkNameViaMetadata() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	parts := strings.Split(result, "/")
-	if len(parts) != 4 {
-		return "", fmt.Errorf("unexpected response: %s", result)
-	}
-	return parts[3], nil
+	return metadataResourceName(result, gcpurl.KindNetworks)
 }
 
 // getNetwork returns a GCP network
@@ -911,7 +1024,7 @@ func getZonesForRegion(svc *compute.Service, projectID, region string) ([]string
 	var zones []string
 	var accumulator = func(response *compute.ZoneList) error {
 		for _, zone := range response.Items {
-			regionName := lastComponent(zone.Region)
+			regionName := resourceName(zone.Region)
 			if regionName == region {
 				zones = append(zones, zone.Name)
 			}
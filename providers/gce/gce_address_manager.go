@@ -20,6 +20,8 @@ limitations under the License.
 package gce
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -39,6 +41,25 @@ type addressManager struct {
 	region      string
 	subnetURL   string
 	tryRelease  bool
+	// keepReservation leaves the controller-owned address reserved on
+	// ReleaseAddress, for the static IPs of the services.
+	keepReservation bool
+}
+
+// addressConflictError is returned when the target IP is reserved by an
+// address of another service.
+type addressConflictError struct {
+	ip, address, serviceName string
+}
+
+func (e *addressConflictError) Error() string {
+	return fmt.Sprintf("IP %q is already reserved by address %q of service %s", e.ip, e.address, e.serviceName)
+}
+
+// isAddressConflict returns true if err is an addressConflictError.
+func isAddressConflict(err error) bool {
+	var conflict *addressConflictError
+	return errors.As(err, &conflict)
 }
 
 func newAddressManager(svc CloudAddressService, serviceName, region, subnetURL, name, targetIP string, addressType cloud.LbScheme) *addressManager {
@@ -102,6 +123,10 @@ func (am *addressManager) ReleaseAddress() error {
 		klog.V(4).Infof("%v: not attempting release of address %q.", am.logPrefix, am.targetIP)
 		return nil
 	}
+	if am.keepReservation {
+		klog.V(4).Infof("%v: keeping the reservation of static IP %q named %q", am.logPrefix, am.targetIP, am.name)
+		return nil
+	}
 
 	klog.V(4).Infof("%v: releasing address %q named %q", am.logPrefix, am.targetIP, am.name)
 	// Controller only ever tries to unreserve the address named with the load balancer's name.
@@ -169,6 +194,10 @@ func (am *addressManager) ensureAddressReservation() (string, error) {
 		return "", err
 	}
 
+	if owner := addressServiceName(addr); !am.isManagedAddress(addr) && owner != "" && owner != am.serviceName {
+		return "", &addressConflictError{ip: am.targetIP, address: addr.Name, serviceName: owner}
+	}
+
 	if am.isManagedAddress(addr) {
 		// The address with this name is checked at the beginning of 'HoldAddress()', but for some reason
 		// it was re-created by this point. May be possible that two controllers are running.
@@ -197,6 +226,18 @@ func (am *addressManager) isManagedAddress(addr *compute.Address) bool {
 	return addr.Name == am.name
 }
 
+// addressServiceName returns the name of the service the address was reserved
+// for by a controller, or the empty string for the addresses of the users.
+func addressServiceName(addr *compute.Address) string {
+	var desc struct {
+		ServiceName string `json:"kubernetes.io/service-name"`
+	}
+	if err := json.Unmarshal([]byte(addr.Description), &desc); err != nil {
+		return ""
+	}
+	return desc.ServiceName
+}
+
 func ensureAddressDeleted(svc CloudAddressService, name, region string) error {
 	return ignoreNotFound(svc.DeleteRegionAddress(name, region))
 }
@@ -129,6 +129,37 @@ func TestAddressManagerBadExternallyOwned(t *testing.T) {
 	require.Equal(t, ad, "")
 }
 
+// TestAddressManagerReservedByOtherService tests the case where the target IP is
+// reserved by the address of the load balancer of another service.
+func TestAddressManagerReservedByOtherService(t *testing.T) {
+	svc, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	targetIP := "1.1.1.1"
+
+	addr := &compute.Address{Name: "a222222222222222", Address: targetIP, AddressType: string(cloud.SchemeInternal), Description: `{"kubernetes.io/service-name":"default/other-service"}`}
+	err = svc.ReserveRegionAddress(addr, vals.Region)
+	require.NoError(t, err)
+
+	mgr := newAddressManager(svc, testSvcName, vals.Region, testSubnet, testLBName, targetIP, cloud.SchemeInternal)
+	_, err = mgr.HoldAddress()
+	assert.True(t, isAddressConflict(err), "HoldAddress() = %v, want a conflict", err)
+}
+
+// TestAddressManagerKeepReservation tests that a static IP stays reserved on release.
+func TestAddressManagerKeepReservation(t *testing.T) {
+	svc, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	targetIP := "1.1.1.1"
+
+	mgr := newAddressManager(svc, testSvcName, vals.Region, testSubnet, testLBName, targetIP, cloud.SchemeInternal)
+	mgr.keepReservation = true
+	testHoldAddress(t, mgr, svc, testLBName, vals.Region, targetIP, string(cloud.SchemeInternal))
+	require.NoError(t, mgr.ReleaseAddress())
+	addr, err := svc.GetRegionAddress(testLBName, vals.Region)
+	require.NoError(t, err)
+	assert.Equal(t, targetIP, addr.Address)
+}
+
 func testHoldAddress(t *testing.T, mgr *addressManager, svc CloudAddressService, name, region, targetIP, scheme string) {
 	ipToUse, err := mgr.HoldAddress()
 	require.NoError(t, err)
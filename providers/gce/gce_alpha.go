@@ -27,6 +27,12 @@ const (
 	// AlphaFeatureSkipIGsManagement enabled L4 Regional Backend Services and
 	// disables instance group management in service controller
 	AlphaFeatureSkipIGsManagement = "SkipIGsManagement"
+
+	// AlphaFeatureSharedInstanceWatcher lists the instances of the managed
+	// zones periodically and serves the instances read by the route, node
+	// lifecycle and node IPAM controllers from that listing, instead of
+	// getting the instance of each node from the API.
+	AlphaFeatureSharedInstanceWatcher = "SharedInstanceWatcher"
 )
 
 // AlphaFeatureGate contains a mapping of alpha features to whether they are enabled
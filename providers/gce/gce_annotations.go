@@ -21,6 +21,9 @@ package gce
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"k8s.io/klog/v2"
 
@@ -64,6 +67,12 @@ const (
 	// cluster is created in.
 	ServiceAnnotationILBSubnet = "networking.gke.io/internal-load-balancer-subnet"
 
+	// ServiceAnnotationILBStaticIP is annotated on a service with the internal IP
+	// its ILB is pinned to. The controller keeps the IP reserved with a regional
+	// address named after the load balancer for the lifetime of the service,
+	// and releases it when the service is deleted or the annotation removed.
+	ServiceAnnotationILBStaticIP = "networking.gke.io/internal-load-balancer-ip"
+
 	// NetworkTierAnnotationKey is annotated on a Service object to indicate which
 	// network tier a GCP LB should use. The valid values are "Standard" and
 	// "Premium" (default).
@@ -81,6 +90,22 @@ const (
 
 	// RBSEnabled is an annotation to indicate the Service is opt-in for RBS
 	RBSEnabled = "enabled"
+
+	// ServiceAnnotationHealthCheckInterval is annotated on an external
+	// LoadBalancer service with the interval in seconds between the health
+	// checks of its target pool.
+	ServiceAnnotationHealthCheckInterval = "networking.gke.io/l4-health-check-interval"
+	// ServiceAnnotationHealthCheckPort is annotated on an external
+	// LoadBalancer service with the node port its target pool health checks.
+	ServiceAnnotationHealthCheckPort = "networking.gke.io/l4-health-check-port"
+	// ServiceAnnotationHealthCheckRequestPath is annotated on an external
+	// LoadBalancer service with the path of the HTTP requests of the health
+	// checks of its target pool.
+	ServiceAnnotationHealthCheckRequestPath = "networking.gke.io/l4-health-check-request-path"
+
+	// maxHealthCheckIntervalSeconds is the largest check interval accepted by
+	// GCE.
+	maxHealthCheckIntervalSeconds = 300
 )
 
 // GetLoadBalancerAnnotationType returns the type of GCP load balancer which should be assembled.
@@ -147,6 +172,8 @@ type ILBOptions struct {
 	AllowGlobalAccess bool
 	// SubnetName indicates which subnet the LoadBalancer VIPs should be assigned from
 	SubnetName string
+	// StaticIP is the internal IP kept reserved for the LoadBalancer
+	StaticIP string
 }
 
 // GetLoadBalancerAnnotationAllowGlobalAccess returns if global access is enabled
@@ -162,3 +189,56 @@ func GetLoadBalancerAnnotationSubnet(service *v1.Service) string {
 	}
 	return ""
 }
+
+// GetLoadBalancerAnnotationStaticIP returns the internal IP the LoadBalancer
+// is pinned to, or an error if it is not a valid IPv4 address.
+func GetLoadBalancerAnnotationStaticIP(service *v1.Service) (string, error) {
+	val, exists := service.Annotations[ServiceAnnotationILBStaticIP]
+	if !exists {
+		return "", nil
+	}
+	if ip := net.ParseIP(val); ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("annotation %s: %q is not a valid IPv4 address", ServiceAnnotationILBStaticIP, val)
+	}
+	return val, nil
+}
+
+// HealthCheckOverrides holds the health check parameters of an external
+// LoadBalancer service set by its annotations. Zero values keep the defaults.
+type HealthCheckOverrides struct {
+	IntervalSec int64
+	Port        int32
+	RequestPath string
+}
+
+// IsSet returns true if any health check parameter is overridden.
+func (o HealthCheckOverrides) IsSet() bool {
+	return o != HealthCheckOverrides{}
+}
+
+// GetLoadBalancerAnnotationHealthCheck returns the health check parameters set
+// by the annotations of the service, or an error if any of them is invalid.
+func GetLoadBalancerAnnotationHealthCheck(service *v1.Service) (HealthCheckOverrides, error) {
+	var o HealthCheckOverrides
+	if val, ok := service.Annotations[ServiceAnnotationHealthCheckInterval]; ok {
+		interval, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || interval < 1 || interval > maxHealthCheckIntervalSeconds {
+			return o, fmt.Errorf("invalid value %q of annotation %s: want a number of seconds between 1 and %d", val, ServiceAnnotationHealthCheckInterval, maxHealthCheckIntervalSeconds)
+		}
+		o.IntervalSec = interval
+	}
+	if val, ok := service.Annotations[ServiceAnnotationHealthCheckPort]; ok {
+		port, err := strconv.ParseInt(val, 10, 32)
+		if err != nil || port < 1 || port > 65535 {
+			return o, fmt.Errorf("invalid value %q of annotation %s: want a port between 1 and 65535", val, ServiceAnnotationHealthCheckPort)
+		}
+		o.Port = int32(port)
+	}
+	if val, ok := service.Annotations[ServiceAnnotationHealthCheckRequestPath]; ok {
+		if !strings.HasPrefix(val, "/") {
+			return o, fmt.Errorf("invalid value %q of annotation %s: want an absolute path", val, ServiceAnnotationHealthCheckRequestPath)
+		}
+		o.RequestPath = val
+	}
+	return o, nil
+}
@@ -72,3 +72,42 @@ func TestServiceNetworkTierAnnotationKey(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLoadBalancerAnnotationHealthCheck(t *testing.T) {
+	for testName, testCase := range map[string]struct {
+		annotations map[string]string
+		expected    HealthCheckOverrides
+		expectErr   bool
+	}{
+		"No annotation": {},
+		"All annotations": {
+			annotations: map[string]string{
+				ServiceAnnotationHealthCheckInterval:    "20",
+				ServiceAnnotationHealthCheckPort:        "8080",
+				ServiceAnnotationHealthCheckRequestPath: "/ready",
+			},
+			expected: HealthCheckOverrides{IntervalSec: 20, Port: 8080, RequestPath: "/ready"},
+		},
+		"Interval out of range": {
+			annotations: map[string]string{ServiceAnnotationHealthCheckInterval: "301"},
+			expectErr:   true,
+		},
+		"Invalid port": {
+			annotations: map[string]string{ServiceAnnotationHealthCheckPort: "http"},
+			expectErr:   true,
+		},
+		"Relative request path": {
+			annotations: map[string]string{ServiceAnnotationHealthCheckRequestPath: "ready"},
+			expectErr:   true,
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: testCase.annotations}}
+			actual, err := GetLoadBalancerAnnotationHealthCheck(svc)
+			assert.Equal(t, testCase.expectErr, err != nil)
+			if !testCase.expectErr {
+				assert.Equal(t, testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -62,15 +62,19 @@ const (
 
 // ClusterID is the struct for maintaining information about this cluster's ID
 type ClusterID struct {
-	idLock     sync.RWMutex
-	client     clientset.Interface
-	cfgMapKey  string
-	store      cache.Store
+	idLock    sync.RWMutex
+	client    clientset.Interface
+	cfgMapKey string
+	store     cache.Store
+	// synced returns true once store holds the config map, if it exists.
+	synced     cache.InformerSynced
 	providerID *string
 	clusterID  *string
 }
 
-// Continually watches for changes to the cluster id config map
+// Continually watches for changes to the cluster id config map. The ClusterID
+// is set before watchClusterID returns, so that it is ready for the callers
+// of the Cloud, and of the Clouds sharing its state, once Initialize returns.
 func (g *Cloud) watchClusterID(stop <-chan struct{}) {
 	g.ClusterID = ClusterID{
 		cfgMapKey: fmt.Sprintf("%v/%v", UIDNamespace, UIDConfigMapName),
@@ -116,8 +120,9 @@ func (g *Cloud) watchClusterID(stop <-chan struct{}) {
 	listerWatcher := cache.NewListWatchFromClient(g.ClusterID.client.CoreV1().RESTClient(), "configmaps", UIDNamespace, fields.Everything())
 	var controller cache.Controller
 	g.ClusterID.store, controller = cache.NewInformer(newSingleObjectListerWatcher(listerWatcher, UIDConfigMapName), &v1.ConfigMap{}, updateFuncFrequency, mapEventHandler)
+	g.ClusterID.synced = controller.HasSynced
 
-	controller.Run(stop)
+	go controller.Run(stop)
 }
 
 // GetID returns the id which is unique to this cluster
@@ -171,6 +176,10 @@ func (ci *ClusterID) getOrInitialize() error {
 	if ci.store == nil {
 		return errors.New("Cloud.ClusterID is not ready. Call Initialize() before using")
 	}
+	if ci.synced != nil && !ci.synced() {
+		// The config map may exist but not be listed yet.
+		return errors.New("Cloud.ClusterID is not ready. The cluster id config map is not listed yet")
+	}
 
 	if ci.clusterID != nil {
 		return nil
@@ -238,7 +238,7 @@ func (manager *gceServiceManager) GetDiskFromCloudProvider(
 		return nil, err
 	}
 
-	zoneInfo := singleZone{strings.TrimSpace(lastComponent(diskStable.Zone))}
+	zoneInfo := singleZone{strings.TrimSpace(resourceName(diskStable.Zone))}
 	if zoneInfo.zone == "" {
 		zoneInfo = singleZone{zone}
 	}
@@ -270,12 +270,12 @@ func (manager *gceServiceManager) GetRegionalDiskFromCloudProvider(
 
 	zones := sets.NewString()
 	for _, zoneURI := range diskBeta.ReplicaZones {
-		zones.Insert(lastComponent(zoneURI))
+		zones.Insert(resourceName(zoneURI))
 	}
 
 	return &Disk{
 		ZoneInfo: multiZone{zones},
-		Region:   lastComponent(diskBeta.Region),
+		Region:   resourceName(diskBeta.Region),
 		Name:     diskBeta.Name,
 		Kind:     diskBeta.Kind,
 		Type:     diskBeta.Type,
@@ -677,9 +677,15 @@ func (g *Cloud) BulkDisksAreAttached(diskByNodes map[types.NodeName][]string) (m
 	// List all instances with the given instance names
 	// Then for each instance listed, add the disks attached to that instance to a map
 	listedInstances, err := g.getFoundInstanceByNames(instanceNames)
-	if err != nil {
+	zoneErrs, partial := err.(zoneErrors)
+	if err != nil && !partial {
 		return nil, fmt.Errorf("error listing instances: %v", err)
 	}
+	if partial {
+		// The instances that were not found may be in the failed zones, only
+		// the nodes whose instance was found are verified.
+		klog.Warningf("BulkDisksAreAttached: failed to list the instances of some zones, verifying the disks of the %d instances found: %v", len(listedInstances), zoneErrs)
+	}
 	listedInstanceNamesToDisks := make(map[string][]*compute.AttachedDisk)
 	for _, instance := range listedInstances {
 		listedInstanceNamesToDisks[instance.Name] = instance.Disks
@@ -690,7 +696,10 @@ func (g *Cloud) BulkDisksAreAttached(diskByNodes map[types.NodeName][]string) (m
 	// For each node and its desired attached disks that needs to be verified
 	for nodeName, disksToVerify := range diskByNodes {
 		instanceName := canonicalizeInstanceName(mapNodeNameToInstanceName(nodeName))
-		disksActuallyAttached := listedInstanceNamesToDisks[instanceName]
+		disksActuallyAttached, ok := listedInstanceNamesToDisks[instanceName]
+		if !ok && partial {
+			continue
+		}
 		verifyDisksAttached[nodeName] = verifyDisksAttachedToNode(disksToVerify, disksActuallyAttached)
 	}
 
@@ -949,7 +958,7 @@ func (g *Cloud) GetDiskByNameUnknownZone(diskName string) (*Disk, error) {
 	// on create)
 
 	var found *Disk
-	for _, zone := range g.managedZones {
+	for _, zone := range g.getManagedZones() {
 		disk, err := g.findDiskByName(diskName, zone)
 		if err != nil {
 			return nil, err
@@ -978,7 +987,7 @@ func (g *Cloud) GetDiskByNameUnknownZone(diskName string) (*Disk, error) {
 		return found, nil
 	}
 	klog.Warningf("GCE persistent disk %q not found in managed zones (%s)",
-		diskName, strings.Join(g.managedZones, ","))
+		diskName, strings.Join(g.getManagedZones(), ","))
 
 	return nil, cloudprovider.DiskNotFound
 }
@@ -46,12 +46,14 @@ func TestCreateDisk_Basic(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       []string{"zone1"},
-		projectID:          gceProjectID,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       []string{"zone1"},
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		projectID:        gceProjectID,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	diskName := "disk"
@@ -101,11 +103,13 @@ func TestCreateRegionalDisk_Basic(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		projectID:          gceProjectID,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:   fakeManager,
+		projectID: gceProjectID,
 	}
 
 	diskName := "disk"
@@ -155,11 +159,13 @@ func TestCreateDisk_DiskAlreadyExists(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	// Inject disk AlreadyExists error.
@@ -185,10 +191,13 @@ func TestCreateDisk_WrongZone(t *testing.T) {
 	zonesWithNodes := []string{"zone1"}
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true }}
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager: fakeManager,
+	}
 
 	diskName := "disk"
 	diskType := DiskTypeSSD
@@ -210,10 +219,13 @@ func TestCreateDisk_NoManagedZone(t *testing.T) {
 	zonesWithNodes := []string{}
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true }}
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager: fakeManager,
+	}
 
 	diskName := "disk"
 	diskType := DiskTypeSSD
@@ -234,10 +246,14 @@ func TestCreateDisk_BadDiskType(t *testing.T) {
 	gceRegion := "fake-region"
 	zonesWithNodes := []string{"zone1"}
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
-	gce := Cloud{manager: fakeManager,
-		managedZones:       zonesWithNodes,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true }}
+	gce := Cloud{
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager: fakeManager,
+	}
 
 	diskName := "disk"
 	diskType := "arbitrary-disk"
@@ -261,11 +277,13 @@ func TestCreateDisk_MultiZone(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	diskType := DiskTypeStandard
@@ -289,11 +307,13 @@ func TestDeleteDisk_Basic(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	diskName := "disk"
 	diskType := DiskTypeSSD
@@ -323,11 +343,13 @@ func TestDeleteDisk_NotFound(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	diskName := "disk"
 
@@ -348,11 +370,13 @@ func TestDeleteDisk_ResourceBeingUsed(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	diskName := "disk"
 	diskType := DiskTypeSSD
@@ -379,11 +403,13 @@ func TestDeleteDisk_SameDiskMultiZone(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	diskName := "disk"
 	diskType := DiskTypeSSD
@@ -413,11 +439,13 @@ func TestDeleteDisk_DiffDiskMultiZone(t *testing.T) {
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	diskType := DiskTypeSSD
@@ -469,11 +497,13 @@ func TestGetLabelsForVolume_Basic(t *testing.T) {
 	const sizeGb int64 = 128
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	gce.CreateDisk(diskName, diskType, zone, sizeGb, nil)
@@ -506,11 +536,13 @@ func TestGetLabelsForVolume_NoZone(t *testing.T) {
 	const sizeGb int64 = 128
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	gce.CreateDisk(diskName, diskType, zone, sizeGb, nil)
 
@@ -542,10 +574,14 @@ func TestGetLabelsForVolume_DiskNotFound(t *testing.T) {
 	zonesWithNodes := []string{zone}
 	fakeManager := newFakeManager(gceProjectID, gceRegion)
 	diskName := "disk"
-	gce := Cloud{manager: fakeManager,
-		managedZones:       zonesWithNodes,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true }}
+	gce := Cloud{
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager: fakeManager,
+	}
 
 	/* Act */
 	_, err := gce.GetLabelsForVolume(ctx, pv(diskName, zone))
@@ -567,11 +603,13 @@ func TestGetLabelsForVolume_DiskNotFoundAndNoZone(t *testing.T) {
 	diskName := "disk"
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	pv := pv(diskName, zone)
@@ -600,11 +638,13 @@ func TestGetLabelsForVolume_DupDisk(t *testing.T) {
 
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	for _, zone := range gce.managedZones {
 		gce.CreateDisk(diskName, diskType, zone, sizeGb, nil)
@@ -640,11 +680,13 @@ func TestGetLabelsForVolume_DupDiskNoZone(t *testing.T) {
 
 	alphaFeatureGate := NewAlphaFeatureGate([]string{})
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 	for _, zone := range gce.managedZones {
 		gce.CreateDisk(diskName, diskType, zone, sizeGb, nil)
@@ -674,11 +716,13 @@ func TestGetAutoLabelsForPD(t *testing.T) {
 	const sizeGb int64 = 128
 
 	gce := Cloud{
-		manager:            fakeManager,
-		managedZones:       zonesWithNodes,
-		AlphaFeatureGate:   alphaFeatureGate,
-		nodeZones:          createNodeZones(zonesWithNodes),
-		nodeInformerSynced: func() bool { return true },
+		cloudState: &cloudState{
+			managedZones:       zonesWithNodes,
+			nodeZones:          createNodeZones(zonesWithNodes),
+			nodeInformerSynced: func() bool { return true },
+		},
+		manager:          fakeManager,
+		AlphaFeatureGate: alphaFeatureGate,
 	}
 
 	testCases := []struct {
@@ -927,7 +971,7 @@ func (manager *FakeServiceManager) GetDiskFromCloudProvider(
 
 	return &Disk{
 		Region:   manager.gceRegion,
-		ZoneInfo: singleZone{lastComponent(zone)},
+		ZoneInfo: singleZone{resourceName(zone)},
 		Name:     diskName,
 		Kind:     "compute#disk",
 		Type:     "type",
@@ -70,12 +70,14 @@ func NewFakeGCECloud(vals TestClusterValues) *Cloud {
 		panic(err)
 	}
 	gce := &Cloud{
+		cloudState: &cloudState{
+			managedZones: []string{vals.ZoneName},
+			ClusterID:    fakeClusterID(vals.ClusterID),
+		},
 		region:           vals.Region,
 		service:          service,
-		managedZones:     []string{vals.ZoneName},
 		projectID:        vals.ProjectID,
 		networkProjectID: vals.ProjectID,
-		ClusterID:        fakeClusterID(vals.ClusterID),
 		onXPN:            vals.OnXPN,
 		metricsCollector: newLoadBalancerMetrics(),
 		projectsBasePath: getProjectsBasePath(service.BasePath),
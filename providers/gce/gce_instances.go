@@ -25,6 +25,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
@@ -50,6 +51,15 @@ const (
 	networkInterfaceExternalIP    = "instance/network-interfaces/%s/access-configs/%s/external-ip"
 )
 
+const (
+	// ipv6InterfacePrefixLength is the length of the IPv6 prefix delegated to
+	// the network interfaces of dual-stack instances.
+	ipv6InterfacePrefixLength = 96
+	// ipv6PodCIDRPrefixLength is the length of the IPv6 pod CIDRs, the first
+	// subrange of the prefix of the interface.
+	ipv6PodCIDRPrefixLength = 112
+)
+
 func newInstancesMetricContext(request, zone string) *metricContext {
 	return newGenericMetricContext("instances", request, unusedMetricLabel, zone, computeV1Version)
 }
@@ -216,6 +226,9 @@ func (g *Cloud) instanceByProviderID(providerID string) (*gceInstance, error) {
 	if err != nil {
 		return nil, err
 	}
+	if res, ok := g.cachedInstance(providerID); ok {
+		return toGCEInstance(res), nil
+	}
 
 	instance, err := g.getInstanceFromProjectInZoneByName(project, zone, name)
 	if err != nil {
@@ -258,15 +271,33 @@ func (g *Cloud) nodeAddressesFromInstance(instance *compute.Instance) ([]v1.Node
 }
 
 func getIPV6AddressFromInterface(nic *compute.NetworkInterface) string {
-	ipv6Addr := nic.Ipv6Address
-	if ipv6Addr == "" && nic.Ipv6AccessType == "EXTERNAL" {
-		for _, r := range nic.Ipv6AccessConfigs {
-			ipv6Addr = r.ExternalIpv6
-		}
+	ipv6Addr, _ := interfaceIPv6Prefix(nic)
+	if i := strings.Index(ipv6Addr, "/"); i >= 0 {
+		ipv6Addr = ipv6Addr[:i]
 	}
 	return ipv6Addr
 }
 
+// interfaceIPv6Prefix returns the IPv6 address of the network interface and
+// the length of the prefix delegated to the interface with it: the internal
+// IPv6 address, or the first external IPv6 address of the access configs of
+// interfaces of the EXTERNAL IPv6 access type. The length is 0 if the API did
+// not report it.
+func interfaceIPv6Prefix(nic *compute.NetworkInterface) (string, int) {
+	if nic.Ipv6Address != "" {
+		return nic.Ipv6Address, 0
+	}
+	if nic.Ipv6AccessType != "EXTERNAL" {
+		return "", 0
+	}
+	for _, r := range nic.Ipv6AccessConfigs {
+		if r.ExternalIpv6 != "" {
+			return r.ExternalIpv6, int(r.ExternalIpv6PrefixLength)
+		}
+	}
+	return "", 0
+}
+
 // InstanceTypeByProviderID returns the cloudprovider instance type of the node
 // with the specified unique providerID This method will not be called from the
 // node that is requesting this ID. i.e. metadata service and other local
@@ -345,7 +376,7 @@ func (g *Cloud) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprov
 		return nil, err
 	}
 
-	instanceType = lastComponent(instance.MachineType)
+	instanceType = resourceName(instance.MachineType)
 
 	return &cloudprovider.InstanceMetadata{
 		ProviderID:    providerID,
@@ -476,7 +507,7 @@ func (g *Cloud) GetAllZonesFromCloudProvider() (sets.String, error) {
 	defer cancel()
 
 	zones := sets.NewString()
-	for _, zone := range g.managedZones {
+	for _, zone := range g.getManagedZones() {
 		instances, err := g.c.Instances().List(ctx, zone, filter.None)
 		if err != nil {
 			return sets.NewString(), err
@@ -551,8 +582,7 @@ func (g *Cloud) AliasRangesByProviderID(providerID string) (cidrs []string, err
 		for _, r := range networkInterface.AliasIpRanges {
 			cidrs = append(cidrs, r.IpCidrRange)
 		}
-		ipv6Addr := g.GetIPV6Address(networkInterface)
-		if ipv6Addr != nil {
+		if ipv6Addr := InterfaceIPv6PodCIDR(networkInterface); ipv6Addr != nil {
 			cidrs = append(cidrs, ipv6Addr.String())
 		}
 	}
@@ -560,17 +590,44 @@ func (g *Cloud) AliasRangesByProviderID(providerID string) (cidrs []string, err
 }
 
 // GetIPV6Address fetches the IPv6 addressses associated with a network interface.
+//
+// Deprecated: use InterfaceIPv6PodCIDR, which does not need a Cloud.
 func (g *Cloud) GetIPV6Address(networkInterface *compute.NetworkInterface) *net.IPNet {
-	ipv6Addr := getIPV6AddressFromInterface(networkInterface)
+	return InterfaceIPv6PodCIDR(networkInterface)
+}
+
+// InterfaceIPv6PodCIDR returns the IPv6 pod CIDR of the network interface of
+// an instance, read from the interface alone, or nil if the interface has no
+// IPv6 address. The pod CIDR is the first /112 subrange of the prefix, a /96
+// unless the API reports another length, delegated to the interface. The
+// address may be reported in CIDR notation, e.g. 2600:1900:4000:1::/96.
+func InterfaceIPv6PodCIDR(networkInterface *compute.NetworkInterface) *net.IPNet {
+	ipv6Addr, prefixLength := interfaceIPv6Prefix(networkInterface)
 	if ipv6Addr == "" {
 		return nil
 	}
 	addr := net.ParseIP(ipv6Addr)
-	// The podCIDR range is the first /112 subrange from the /96 assigned to
-	// the node
-	mask := net.CIDRMask(112, 128)
+	if strings.Contains(ipv6Addr, "/") {
+		var prefix *net.IPNet
+		var err error
+		if addr, prefix, err = net.ParseCIDR(ipv6Addr); err == nil {
+			prefixLength, _ = prefix.Mask.Size()
+		}
+	}
+	if addr == nil || addr.To4() != nil {
+		klog.Warningf("Ignoring invalid IPv6 address %q of network interface %q", ipv6Addr, networkInterface.Name)
+		return nil
+	}
+	if prefixLength == 0 {
+		prefixLength = ipv6InterfacePrefixLength
+	}
+	if prefixLength > ipv6PodCIDRPrefixLength {
+		klog.Warningf("Ignoring IPv6 prefix %s/%d of network interface %q, too small for a /%d pod CIDR", addr, prefixLength, networkInterface.Name, ipv6PodCIDRPrefixLength)
+		return nil
+	}
+	mask := net.CIDRMask(ipv6PodCIDRPrefixLength, 128)
 	return &net.IPNet{
-		IP:   addr.Mask(mask),
+		IP:   addr.Mask(net.CIDRMask(prefixLength, 128)),
 		Mask: mask,
 	}
 }
@@ -609,29 +666,31 @@ func (g *Cloud) AddAliasToInstanceByProviderID(providerID string, alias *net.IPN
 	})
 
 	mc := newInstancesMetricContext("add_alias", zone)
-	err = g.c.BetaInstances().UpdateNetworkInterface(ctx, meta.ZonalKey(instance.Name, lastComponent(instance.Zone)), iface.Name, iface)
+	err = g.c.BetaInstances().UpdateNetworkInterface(ctx, meta.ZonalKey(instance.Name, resourceName(instance.Zone)), iface.Name, iface)
 	return mc.Observe(err)
 }
 
 // Gets the named instances, returning cloudprovider.InstanceNotFound if any
-// instance is not found
+// instance is not found. If listing the instances failed in some zones, the
+// instances found in the other zones are returned with a zoneErrors.
 func (g *Cloud) getInstancesByNames(names []string) ([]*gceInstance, error) {
 	foundInstances, err := g.getFoundInstanceByNames(names)
-	if err != nil {
+	if _, ok := err.(zoneErrors); err != nil && !ok {
 		return nil, err
 	}
-	if len(foundInstances) != len(names) {
+	if err == nil && len(foundInstances) != len(names) {
 		if len(foundInstances) == 0 {
 			// return error so the TargetPool nodecount does not drop to 0 unexpectedly.
 			return nil, cloudprovider.InstanceNotFound
 		}
 		klog.Warningf("getFoundInstanceByNames - input instances %d, found %d. Continuing LoadBalancer Update", len(names), len(foundInstances))
 	}
-	return foundInstances, nil
+	return foundInstances, err
 }
 
 // Gets the named instances, returning a list of gceInstances it was able to find from the provided
-// list of names.
+// list of names. If listing the instances failed in some zones, the instances
+// found in the other zones are returned with a zoneErrors.
 func (g *Cloud) getFoundInstanceByNames(names []string) ([]*gceInstance, error) {
 	ctx, cancel := cloud.ContextWithCallTimeout()
 	defer cancel()
@@ -649,13 +708,25 @@ func (g *Cloud) getFoundInstanceByNames(names []string) ([]*gceInstance, error)
 		found[name] = nil
 	}
 
-	for _, zone := range g.managedZones {
-		if remaining == 0 {
-			break
+	// The zones are listed in parallel so that a slow zone does not add up
+	// with the others. The lists still running are cancelled once all the
+	// instances are found.
+	var lock sync.Mutex
+	err := forEachZone("getFoundInstanceByNames", g.getManagedZones(), func(zone string) error {
+		lock.Lock()
+		done := remaining == 0
+		lock.Unlock()
+		if done {
+			return nil
 		}
 		instances, err := g.c.Instances().List(ctx, zone, filter.Regexp("name", nodeInstancePrefix+".*"))
+		lock.Lock()
+		defer lock.Unlock()
+		if remaining == 0 {
+			return nil
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
 		for _, inst := range instances {
 			if remaining == 0 {
@@ -673,11 +744,15 @@ func (g *Cloud) getFoundInstanceByNames(names []string) ([]*gceInstance, error)
 				Name:  inst.Name,
 				ID:    inst.Id,
 				Disks: inst.Disks,
-				Type:  lastComponent(inst.MachineType),
+				Type:  resourceName(inst.MachineType),
 			}
 			remaining--
 		}
-	}
+		if remaining == 0 {
+			cancel()
+		}
+		return nil
+	})
 
 	var ret []*gceInstance
 	var failed []string
@@ -692,13 +767,16 @@ func (g *Cloud) getFoundInstanceByNames(names []string) ([]*gceInstance, error)
 		klog.Errorf("Failed to retrieve instances: %v", failed)
 	}
 
-	return ret, nil
+	return ret, err
 }
 
 // Gets the named instance, returning cloudprovider.InstanceNotFound if the instance is not found
 func (g *Cloud) getInstanceByName(name string) (*gceInstance, error) {
 	// Avoid changing behaviour when not managing multiple zones
-	for _, zone := range g.managedZones {
+	for _, zone := range g.getManagedZones() {
+		if res, ok := g.cachedInstance(instanceProviderID(g.projectID, zone, canonicalizeInstanceName(name))); ok {
+			return toGCEInstance(res), nil
+		}
 		instance, err := g.getInstanceFromProjectInZoneByName(g.projectID, zone, name)
 		if err != nil {
 			if isHTTPErrorCode(err, http.StatusNotFound) {
@@ -724,13 +802,17 @@ func (g *Cloud) getInstanceFromProjectInZoneByName(project, zone, name string) (
 	if err != nil {
 		return nil, err
 	}
+	return toGCEInstance(res), nil
+}
+
+func toGCEInstance(res *compute.Instance) *gceInstance {
 	return &gceInstance{
-		Zone:  lastComponent(res.Zone),
+		Zone:  resourceName(res.Zone),
 		Name:  res.Name,
 		ID:    res.Id,
 		Disks: res.Disks,
-		Type:  lastComponent(res.MachineType),
-	}, nil
+		Type:  resourceName(res.MachineType),
+	}
 }
 
 func getInstanceIDViaMetadata() (string, error) {
@@ -750,12 +832,7 @@ func getCurrentMachineTypeViaMetadata() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("couldn't get machine type: %v", err)
 	}
-	parts := strings.Split(mType, "/")
-	if len(parts) != 4 {
-		return "", fmt.Errorf("unexpected response for machine type: %s", mType)
-	}
-
-	return parts[3], nil
+	return metadataResourceName(mType, "machineTypes")
 }
 
 // isCurrentInstance uses metadata server to check if specified
@@ -869,6 +946,10 @@ func (g *Cloud) GetNodeTags(nodeNames []string) ([]string, error) {
 }
 
 // NodeNetworkInterfacesByProviderID returns a list of node interfaces that exist on the node.
+// The instance is always read from the API, never from the instance watcher,
+// whose listing may predate a recreation of the instance: node IPAM detects
+// the recreations from the ID of the instance, and gets the changes of the
+// listed instances from AddInstanceChangeHandler instead.
 func (g *Cloud) InstanceByProviderID(providerID string) (res *compute.Instance, err error) {
 	ctx, cancel := cloud.ContextWithCallTimeout()
 	defer cancel()
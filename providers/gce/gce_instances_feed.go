@@ -0,0 +1,303 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// instancesFeedRefreshPeriod is how often the instances of the managed
+	// zones are listed again by the instance watcher when the changes of the
+	// instances are also delivered by an InstanceChangeFeed. The listing only
+	// catches up with the changes missed by the feed.
+	instancesFeedRefreshPeriod = 30 * time.Minute
+	// instanceChangeFeedRetryPeriod is how long the instance change feed is
+	// not pulled after an error.
+	instanceChangeFeedRetryPeriod = 10 * time.Second
+	// instanceAssetType is the Cloud Asset Inventory type of the instances.
+	instanceAssetType = "compute.googleapis.com/Instance"
+	// pubsubBasePath is the endpoint of the Pub/Sub API.
+	pubsubBasePath = "https://pubsub.googleapis.com/v1/"
+	// pubsubMaxMessages is the maximum number of messages pulled at once.
+	pubsubMaxMessages = 100
+)
+
+// instanceAssetNameRE matches the Cloud Asset Inventory names of the
+// instances, e.g.
+// //compute.googleapis.com/projects/my-project/zones/us-central1-b/instances/my-instance.
+var instanceAssetNameRE = regexp.MustCompile(`^//compute\.googleapis\.com/projects/([^/]+)/zones/([^/]+)/instances/([^/]+)$`)
+
+// InstanceChangeFeed delivers the changes of the instances as they happen,
+// e.g. from a Cloud Asset Inventory feed, so that the controllers follow the
+// instances of their nodes without listing all of them periodically.
+type InstanceChangeFeed interface {
+	// Pull returns the next changes of the instances, blocking until there
+	// are some or ctx is done. The changes are not returned again once
+	// Pull returned them.
+	Pull(ctx context.Context) ([]InstanceChange, error)
+}
+
+// pubsubInstanceChangeFeed is the InstanceChangeFeed of a Pub/Sub subscription
+// to a Cloud Asset Inventory feed of the instances, created with e.g.
+//
+//	gcloud asset feeds create instances --project=my-project \
+//	  --asset-types=compute.googleapis.com/Instance --content-type=resource \
+//	  --pubsub-topic=projects/my-project/topics/instances
+//
+// Its messages are TemporalAssets, see
+// https://cloud.google.com/asset-inventory/docs/monitoring-asset-changes.
+type pubsubInstanceChangeFeed struct {
+	client *http.Client
+	// basePath is the endpoint of the Pub/Sub API.
+	basePath string
+	// subscription is the name of the subscription, of the form
+	// projects/PROJECT/subscriptions/SUBSCRIPTION.
+	subscription string
+}
+
+var _ InstanceChangeFeed = &pubsubInstanceChangeFeed{}
+
+// newPubSubInstanceChangeFeed returns the InstanceChangeFeed of the
+// subscription, pulling its messages with the client.
+func newPubSubInstanceChangeFeed(client *http.Client, subscription string) (*pubsubInstanceChangeFeed, error) {
+	parts := strings.Split(subscription, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "subscriptions" || parts[1] == "" || parts[3] == "" {
+		return nil, fmt.Errorf("invalid Pub/Sub subscription %q, want projects/PROJECT/subscriptions/SUBSCRIPTION", subscription)
+	}
+	return &pubsubInstanceChangeFeed{client: client, basePath: pubsubBasePath, subscription: subscription}, nil
+}
+
+type pubsubPullRequest struct {
+	MaxMessages int `json:"maxMessages"`
+}
+
+type pubsubPullResponse struct {
+	ReceivedMessages []pubsubReceivedMessage `json:"receivedMessages"`
+}
+
+type pubsubReceivedMessage struct {
+	AckID   string        `json:"ackId"`
+	Message pubsubMessage `json:"message"`
+}
+
+type pubsubMessage struct {
+	// Data is the TemporalAsset of the message, base64 encoded in the JSON
+	// representation of the message.
+	Data      []byte `json:"data"`
+	MessageID string `json:"messageId"`
+}
+
+type pubsubAcknowledgeRequest struct {
+	AckIDs []string `json:"ackIds"`
+}
+
+// Pull pulls the next messages of the subscription and acknowledges them.
+// Messages which are not changes of instances are dropped.
+func (f *pubsubInstanceChangeFeed) Pull(ctx context.Context) ([]InstanceChange, error) {
+	var resp pubsubPullResponse
+	if err := f.call(ctx, "pull", pubsubPullRequest{MaxMessages: pubsubMaxMessages}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.ReceivedMessages) == 0 {
+		return nil, nil
+	}
+	var changes []InstanceChange
+	ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+	for _, msg := range resp.ReceivedMessages {
+		ackIDs = append(ackIDs, msg.AckID)
+		change, ok, err := assetFeedChange(msg.Message.Data)
+		if err != nil {
+			klog.Warningf("Dropping message %s of subscription %s: %v", msg.Message.MessageID, f.subscription, err)
+			continue
+		}
+		if ok {
+			changes = append(changes, change)
+		}
+	}
+	if err := f.call(ctx, "acknowledge", pubsubAcknowledgeRequest{AckIDs: ackIDs}, nil); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// call calls the method of the subscription with the request, and decodes
+// the response into resp unless it is nil.
+func (f *pubsubInstanceChangeFeed) call(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	url := f.basePath + f.subscription + ":" + method
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := f.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s of subscription %s failed with status %d: %s", method, f.subscription, httpResp.StatusCode, data)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
+
+type asset struct {
+	Name      string `json:"name"`
+	AssetType string `json:"assetType"`
+	Resource  *struct {
+		// Data is the instance, in the representation of the compute API.
+		Data *compute.Instance `json:"data"`
+	} `json:"resource"`
+}
+
+func (a *asset) instance() *compute.Instance {
+	if a == nil || a.Resource == nil {
+		return nil
+	}
+	return a.Resource.Data
+}
+
+type temporalAsset struct {
+	Asset      *asset `json:"asset"`
+	PriorAsset *asset `json:"priorAsset"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// assetFeedChange returns the change of the instance of a TemporalAsset of a
+// Cloud Asset Inventory feed. It returns false if the asset is not an
+// instance, or if the instance did not change in a way reported by the
+// instance watcher, e.g. only its labels changed.
+func assetFeedChange(data []byte) (InstanceChange, bool, error) {
+	var ta temporalAsset
+	if err := json.Unmarshal(data, &ta); err != nil {
+		return InstanceChange{}, false, fmt.Errorf("invalid asset: %v", err)
+	}
+	if ta.Asset == nil || ta.Asset.AssetType != instanceAssetType {
+		return InstanceChange{}, false, nil
+	}
+	match := instanceAssetNameRE.FindStringSubmatch(ta.Asset.Name)
+	if match == nil {
+		return InstanceChange{}, false, fmt.Errorf("invalid instance asset name %q", ta.Asset.Name)
+	}
+	change := InstanceChange{ProviderID: instanceProviderID(match[1], match[2], match[3])}
+	old, instance := ta.PriorAsset.instance(), ta.Asset.instance()
+	switch {
+	case ta.Deleted:
+		if old == nil {
+			old = instance
+		}
+		change.Type, change.Old = InstanceDeleted, old
+	case instance == nil:
+		return InstanceChange{}, false, fmt.Errorf("instance asset %q has no resource data", ta.Asset.Name)
+	case old == nil:
+		change.Type, change.New = InstanceAdded, instance
+	case instanceChanged(old, instance):
+		change.Type, change.Old, change.New = InstanceUpdated, old, instance
+	default:
+		return InstanceChange{}, false, nil
+	}
+	return change, true, nil
+}
+
+// pullInstanceChanges pulls the next changes of the instance change feed,
+// applies the changes of the instances of the managed zones to the instances
+// listed by the instance watcher, if any, and calls the change handlers.
+func (g *Cloud) pullInstanceChanges(ctx context.Context) error {
+	changes, err := g.instanceChangeFeed.Pull(ctx)
+	if err != nil {
+		return err
+	}
+	zones := sets.NewString(g.getManagedZones()...)
+	var managed []InstanceChange
+	for _, change := range changes {
+		project, zone, name, err := splitProviderID(change.ProviderID)
+		if err != nil || project != g.projectID || !zones.Has(zone) || !strings.HasPrefix(name, g.nodeInstancePrefix) {
+			continue
+		}
+		managed = append(managed, change)
+	}
+
+	w := &g.instancesWatcher
+	w.lock.Lock()
+	if w.instances != nil {
+		for _, change := range managed {
+			if change.Type == InstanceDeleted {
+				delete(w.instances, change.ProviderID)
+			} else {
+				w.instances[change.ProviderID] = change.New
+			}
+		}
+	}
+	handlers := w.handlers
+	w.lock.Unlock()
+
+	for _, change := range managed {
+		klog.V(4).Infof("Instance %s changed: %s", change.ProviderID, change.Type)
+		for _, handler := range handlers {
+			handler(change)
+		}
+	}
+	return nil
+}
+
+// runInstanceChangeFeed pulls the changes of the instance change feed until
+// stop is closed, retrying every instanceChangeFeedRetryPeriod on errors.
+func (g *Cloud) runInstanceChangeFeed(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	wait.Until(func() {
+		for ctx.Err() == nil {
+			if err := g.pullInstanceChanges(ctx); err != nil {
+				if ctx.Err() == nil {
+					klog.Warningf("Failed to pull the changes of the instances of project %s: %v", g.projectID, err)
+				}
+				return
+			}
+		}
+	}, instanceChangeFeedRetryPeriod, stop)
+}
@@ -0,0 +1,231 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ga "google.golang.org/api/compute/v1"
+)
+
+const testInstanceAssetName = "//compute.googleapis.com/projects/my-project/zones/us-central1-b/instances/node-a"
+
+// testTemporalAsset returns a TemporalAsset of the instance asset, with the
+// prior asset if prior is not nil.
+func testTemporalAsset(t *testing.T, assetType string, prior, instance *ga.Instance, deleted bool) []byte {
+	newAsset := func(instance *ga.Instance) map[string]interface{} {
+		a := map[string]interface{}{"name": testInstanceAssetName, "assetType": assetType}
+		if instance != nil {
+			a["resource"] = map[string]interface{}{"data": instance}
+		}
+		return a
+	}
+	ta := map[string]interface{}{"asset": newAsset(instance), "deleted": deleted}
+	if prior != nil {
+		ta["priorAsset"] = newAsset(prior)
+	}
+	data, err := json.Marshal(ta)
+	require.NoError(t, err)
+	return data
+}
+
+func TestAssetFeedChange(t *testing.T) {
+	instance := func(id uint64, network string) *ga.Instance {
+		return &ga.Instance{Name: "node-a", Id: id, Status: "RUNNING", NetworkInterfaces: []*ga.NetworkInterface{{Network: network}}}
+	}
+	labeled := instance(1, "default")
+	labeled.Labels = map[string]string{"team": "red"}
+	testCases := []struct {
+		desc     string
+		data     []byte
+		want     InstanceChangeType
+		wantOld  *ga.Instance
+		wantNew  *ga.Instance
+		wantSkip bool
+		wantErr  bool
+	}{
+		{
+			desc:    "added",
+			data:    testTemporalAsset(t, instanceAssetType, nil, instance(1, "default"), false),
+			want:    InstanceAdded,
+			wantNew: instance(1, "default"),
+		},
+		{
+			desc:    "network interfaces changed",
+			data:    testTemporalAsset(t, instanceAssetType, instance(1, "default"), instance(1, "red"), false),
+			want:    InstanceUpdated,
+			wantOld: instance(1, "default"),
+			wantNew: instance(1, "red"),
+		},
+		{
+			desc:     "labels changed",
+			data:     testTemporalAsset(t, instanceAssetType, instance(1, "default"), labeled, false),
+			wantSkip: true,
+		},
+		{
+			desc:    "deleted",
+			data:    testTemporalAsset(t, instanceAssetType, instance(1, "default"), nil, true),
+			want:    InstanceDeleted,
+			wantOld: instance(1, "default"),
+		},
+		{
+			desc:     "not an instance",
+			data:     testTemporalAsset(t, "compute.googleapis.com/Disk", nil, instance(1, "default"), false),
+			wantSkip: true,
+		},
+		{
+			desc:    "no resource data",
+			data:    testTemporalAsset(t, instanceAssetType, instance(1, "default"), nil, false),
+			wantErr: true,
+		},
+		{
+			desc:    "invalid JSON",
+			data:    []byte("{"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			change, ok, err := assetFeedChange(tc.data)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantSkip {
+				assert.False(t, ok, "change %+v should be skipped", change)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tc.want, change.Type)
+			assert.Equal(t, "gce://my-project/us-central1-b/node-a", change.ProviderID)
+			assert.Equal(t, tc.wantOld, change.Old)
+			assert.Equal(t, tc.wantNew, change.New)
+		})
+	}
+}
+
+func TestNewPubSubInstanceChangeFeed(t *testing.T) {
+	for _, subscription := range []string{"instances", "projects/p/topics/t", "projects//subscriptions/s"} {
+		_, err := newPubSubInstanceChangeFeed(http.DefaultClient, subscription)
+		assert.Error(t, err, "subscription %q should be invalid", subscription)
+	}
+	_, err := newPubSubInstanceChangeFeed(http.DefaultClient, "projects/p/subscriptions/s")
+	assert.NoError(t, err)
+}
+
+func TestPubSubInstanceChangeFeed(t *testing.T) {
+	messages := [][]byte{
+		testTemporalAsset(t, instanceAssetType, nil, &ga.Instance{Name: "node-a", Id: 1}, false),
+		[]byte("{"),
+		testTemporalAsset(t, "compute.googleapis.com/Disk", nil, &ga.Instance{Name: "node-a"}, false),
+	}
+	var acked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/p/subscriptions/s:pull":
+			var resp pubsubPullResponse
+			for i, data := range messages {
+				resp.ReceivedMessages = append(resp.ReceivedMessages, pubsubReceivedMessage{
+					AckID:   string(rune('a' + i)),
+					Message: pubsubMessage{Data: data, MessageID: string(rune('1' + i))},
+				})
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/projects/p/subscriptions/s:acknowledge":
+			var req pubsubAcknowledgeRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			acked = append(acked, req.AckIDs...)
+			w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	feed, err := newPubSubInstanceChangeFeed(server.Client(), "projects/p/subscriptions/s")
+	require.NoError(t, err)
+	feed.basePath = server.URL + "/"
+	changes, err := feed.Pull(context.Background())
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, InstanceAdded, changes[0].Type)
+	assert.Equal(t, uint64(1), changes[0].New.Id)
+	assert.Equal(t, []string{"a", "b", "c"}, acked, "all the messages should be acknowledged")
+
+	feed.subscription = "projects/p/subscriptions/missing"
+	_, err = feed.Pull(context.Background())
+	assert.Error(t, err)
+}
+
+// fakeInstanceChangeFeed returns its changes on the first pull.
+type fakeInstanceChangeFeed struct {
+	changes []InstanceChange
+}
+
+func (f *fakeInstanceChangeFeed) Pull(ctx context.Context) ([]InstanceChange, error) {
+	changes := f.changes
+	f.changes = nil
+	return changes, nil
+}
+
+func TestPullInstanceChanges(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	c := gce.c.(*cloud.MockGCE)
+	key := meta.ZonalKey("node-a", vals.ZoneName)
+	c.MockInstances.Objects[*key] = &cloud.MockInstancesObj{Obj: &ga.Instance{Name: "node-a", Id: 1, Zone: vals.ZoneName}}
+	feed := &fakeInstanceChangeFeed{}
+	gce.instanceChangeFeed = feed
+	assert.Equal(t, instancesFeedRefreshPeriod, gce.instancesRefreshPeriod())
+
+	var got []InstanceChange
+	gce.AddInstanceChangeHandler(func(change InstanceChange) {
+		got = append(got, change)
+	})
+	require.NoError(t, gce.refreshInstances())
+
+	providerID := instanceProviderID(vals.ProjectID, vals.ZoneName, "node-a")
+	updated := InstanceChange{Type: InstanceUpdated, ProviderID: providerID, Old: &ga.Instance{Name: "node-a", Id: 1}, New: &ga.Instance{Name: "node-a", Id: 2}}
+	feed.changes = []InstanceChange{
+		updated,
+		{Type: InstanceAdded, ProviderID: instanceProviderID(vals.ProjectID, "other-zone", "node-b"), New: &ga.Instance{Name: "node-b"}},
+		{Type: InstanceAdded, ProviderID: instanceProviderID("other-project", vals.ZoneName, "node-c"), New: &ga.Instance{Name: "node-c"}},
+	}
+	require.NoError(t, gce.pullInstanceChanges(context.Background()))
+	assert.Equal(t, []InstanceChange{updated}, got, "only the changes of the instances of the managed zones should be handled")
+	instance, ok := gce.cachedInstance(providerID)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), instance.Id, "the change should be applied to the listed instances")
+
+	feed.changes = []InstanceChange{{Type: InstanceDeleted, ProviderID: providerID, Old: instance}}
+	require.NoError(t, gce.pullInstanceChanges(context.Background()))
+	_, ok = gce.cachedInstance(providerID)
+	assert.False(t, ok, "the deleted instance should be removed from the listed instances")
+}
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -112,6 +113,23 @@ func TestNodeAddresses(t *testing.T) {
 	}
 	instanceMap["n2"] = instance
 
+	// n3 is dual stack instance with an external IPv6 delegated prefix
+	instance = &ga.Instance{
+		Name: "n3",
+		Zone: "us-central1-b",
+		NetworkInterfaces: []*ga.NetworkInterface{
+			{
+				NetworkIP:      "10.1.1.3",
+				StackType:      "IPV4_IPV6",
+				Ipv6AccessType: "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{
+					{ExternalIpv6: "2600:1900:4000:1::/96"},
+				},
+			},
+		},
+	}
+	instanceMap["n3"] = instance
+
 	// n4 is instance with invalid network interfaces
 	instance = &ga.Instance{
 		Name: "n4",
@@ -168,6 +186,14 @@ func TestNodeAddresses(t *testing.T) {
 				{Type: v1.NodeInternalIP, Address: "2001:1900::0:2"},
 			},
 		},
+		{
+			name:     "external dual stack instance with a delegated prefix",
+			nodeName: "n3",
+			wantAddrs: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.1.1.3"},
+				{Type: v1.NodeInternalIP, Address: "2600:1900:4000:1::"},
+			},
+		},
 		{
 			name:     "instance not found",
 			nodeName: "x1",
@@ -330,6 +356,110 @@ func TestAliasRangesByProviderID(t *testing.T) {
 	}
 }
 
+func TestInterfaceIPv6PodCIDR(t *testing.T) {
+	testcases := []struct {
+		name string
+		nic  *ga.NetworkInterface
+		want string
+	}{
+		{
+			name: "internal IPv6 address",
+			nic:  &ga.NetworkInterface{StackType: "IPV4_IPV6", Ipv6Address: "2001:2d00::1:0:0"},
+			want: "2001:2d00::1:0:0/112",
+		},
+		{
+			name: "external IPv6 address",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2001:1900::2:0:0"}},
+			},
+			want: "2001:1900::2:0:0/112",
+		},
+		{
+			name: "internal IPv6 address preferred",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6Address:       "2001:2d00::1:0:0",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2001:1900::2:0:0"}},
+			},
+			want: "2001:2d00::1:0:0/112",
+		},
+		{
+			name: "external IPv6 access config of an internal access type",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "INTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2001:1900::2:0:0"}},
+			},
+		},
+		{
+			name: "external IPv6 delegated prefix",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2600:1900:4000:1::", ExternalIpv6PrefixLength: 96}},
+			},
+			want: "2600:1900:4000:1::/112",
+		},
+		{
+			name: "external IPv6 address inside of its delegated prefix",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2600:1900:4000:1:0:0:2:5", ExternalIpv6PrefixLength: 96}},
+			},
+			want: "2600:1900:4000:1::/112",
+		},
+		{
+			name: "external IPv6 prefix in CIDR notation",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2600:1900:4000:1::/96"}},
+			},
+			want: "2600:1900:4000:1::/112",
+		},
+		{
+			name: "first external IPv6 access config with an address",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{Name: "empty"}, {ExternalIpv6: "2600:1900:4000:1::", ExternalIpv6PrefixLength: 96}, {ExternalIpv6: "2600:1900:4000:2::"}},
+			},
+			want: "2600:1900:4000:1::/112",
+		},
+		{
+			name: "external IPv6 prefix too small for a pod CIDR",
+			nic: &ga.NetworkInterface{
+				StackType:         "IPV4_IPV6",
+				Ipv6AccessType:    "EXTERNAL",
+				Ipv6AccessConfigs: []*ga.AccessConfig{{ExternalIpv6: "2600:1900:4000:1::", ExternalIpv6PrefixLength: 128}},
+			},
+		},
+		{
+			name: "invalid IPv6 address",
+			nic:  &ga.NetworkInterface{StackType: "IPV4_IPV6", Ipv6Address: "10.1.1.5"},
+		},
+		{
+			name: "single stack",
+			nic:  &ga.NetworkInterface{StackType: "IPV4", NetworkIP: "10.1.1.5"},
+		},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			got := InterfaceIPv6PodCIDR(test.nic)
+			if test.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, test.want, got.String())
+		})
+	}
+}
+
 func TestInstanceByProviderID(t *testing.T) {
 	gce, err := fakeGCECloud(DefaultTestClusterValues())
 	require.NoError(t, err)
@@ -406,3 +536,41 @@ func TestInstanceByProviderID(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFoundInstanceByNamesZoneFailure(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	gce.managedZones = []string{vals.ZoneName, vals.SecondaryZoneName}
+	_, err = createAndInsertNodes(gce, []string{"n1"}, vals.ZoneName)
+	require.NoError(t, err)
+	_, err = createAndInsertNodes(gce, []string{"n2"}, vals.SecondaryZoneName)
+	require.NoError(t, err)
+
+	mockGCE := gce.c.(*cloud.MockGCE)
+	mockGCE.MockInstances.ListHook = func(ctx context.Context, zone string, fl *filter.F, m *cloud.MockInstances) (bool, []*ga.Instance, error) {
+		if zone == vals.SecondaryZoneName {
+			return true, nil, fmt.Errorf("zone %s is unavailable", zone)
+		}
+		return false, nil, nil
+	}
+	instances, err := gce.getFoundInstanceByNames([]string{"n1", "n2"})
+	require.IsType(t, zoneErrors{}, err)
+	assert.Contains(t, err.(zoneErrors), vals.SecondaryZoneName)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "n1", instances[0].Name)
+
+	// The zones still being listed once all the instances are found are
+	// cancelled, their errors are ignored.
+	mockGCE.MockInstances.ListHook = func(ctx context.Context, zone string, fl *filter.F, m *cloud.MockInstances) (bool, []*ga.Instance, error) {
+		if zone == vals.SecondaryZoneName {
+			<-ctx.Done()
+			return true, nil, ctx.Err()
+		}
+		return false, nil, nil
+	}
+	instances, err = gce.getFoundInstanceByNames([]string{"n1"})
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "n1", instances[0].Name)
+}
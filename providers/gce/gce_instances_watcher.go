@@ -0,0 +1,220 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// instancesRefreshPeriod is how often the instances of the managed zones are
+// listed again by the instance watcher.
+const instancesRefreshPeriod = time.Minute
+
+// InstanceChangeType is the type of a change of an instance seen by the
+// instance watcher.
+type InstanceChangeType string
+
+const (
+	// InstanceAdded is the type of the changes of the instances listed for
+	// the first time.
+	InstanceAdded InstanceChangeType = "Added"
+	// InstanceUpdated is the type of the changes of the instances whose
+	// status, network interfaces or contents changed, or which were recreated
+	// with the same name.
+	InstanceUpdated InstanceChangeType = "Updated"
+	// InstanceDeleted is the type of the changes of the instances no longer
+	// listed.
+	InstanceDeleted InstanceChangeType = "Deleted"
+)
+
+// InstanceChange is a change of an instance seen by the instance watcher.
+type InstanceChange struct {
+	Type InstanceChangeType
+	// ProviderID is the provider ID of the nodes of the instance.
+	ProviderID string
+	// Old is the instance before the change, nil if it was added.
+	Old *compute.Instance
+	// New is the instance after the change, nil if it was deleted.
+	New *compute.Instance
+}
+
+// InstanceChangeHandler is called with the changes of the instances seen by
+// the instance watcher.
+type InstanceChangeHandler func(change InstanceChange)
+
+// instancesWatcher holds the instances of the managed zones, listed
+// periodically and shared by all the controllers reading the instances of the
+// nodes through the Cloud, so that each of them does not get the instances
+// one by one. It runs if AlphaFeatureSharedInstanceWatcher is enabled. The
+// changes of an InstanceChangeFeed, if any, are applied to the listed
+// instances as they are pulled.
+type instancesWatcher struct {
+	lock sync.Mutex
+	// instances maps the provider IDs to the instances. It is nil until the
+	// instances are first listed.
+	instances map[string]*compute.Instance
+	// listed is when the instances were last listed.
+	listed   time.Time
+	handlers []InstanceChangeHandler
+	// now returns the current time, time.Now if nil.
+	now func() time.Time
+}
+
+// AddInstanceChangeHandler registers a handler called with the changes of the
+// instances seen by the instance watcher or pulled from the instance change
+// feed. Handlers are not called for the first listing, and never if neither
+// the watcher nor the feed run.
+func (g *Cloud) AddInstanceChangeHandler(handler InstanceChangeHandler) {
+	g.instancesWatcher.lock.Lock()
+	defer g.instancesWatcher.lock.Unlock()
+	g.instancesWatcher.handlers = append(g.instancesWatcher.handlers, handler)
+}
+
+func (w *instancesWatcher) currentTime() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
+
+// cachedInstance returns the instance with the provider ID listed by the
+// instance watcher. It returns false if the instance was not listed, or if
+// the instances were not listed recently, in which case the instance must be
+// read from the API: it may have been created since.
+func (g *Cloud) cachedInstance(providerID string) (*compute.Instance, bool) {
+	w := &g.instancesWatcher
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.instances == nil || w.currentTime().Sub(w.listed) > 2*g.instancesRefreshPeriod() {
+		return nil, false
+	}
+	instance, ok := w.instances[providerID]
+	return instance, ok
+}
+
+// instanceProviderID returns the provider ID of the nodes of an instance of
+// the project in the zone.
+func instanceProviderID(project, zone, name string) string {
+	return fmt.Sprintf("%s://%s/%s/%s", ProviderName, project, zone, name)
+}
+
+// refreshInstances lists the instances of the managed zones, updates the
+// cache and calls the change handlers with the changes since the previous
+// listing. The cache is left as is if any zone fails to be listed.
+func (g *Cloud) refreshInstances() error {
+	ctx, cancel := cloud.ContextWithCallTimeout()
+	defer cancel()
+
+	fl := filter.None
+	if g.nodeInstancePrefix != "" {
+		fl = filter.Regexp("name", g.nodeInstancePrefix+".*")
+	}
+	instances := make(map[string]*compute.Instance)
+	for _, zone := range g.getManagedZones() {
+		mc := newInstancesMetricContext("list", zone)
+		list, err := g.c.Instances().List(ctx, zone, fl)
+		if err = mc.Observe(err); err != nil {
+			return err
+		}
+		for _, instance := range list {
+			instances[instanceProviderID(g.projectID, zone, instance.Name)] = instance
+		}
+	}
+
+	w := &g.instancesWatcher
+	w.lock.Lock()
+	old := w.instances
+	w.instances = instances
+	w.listed = w.currentTime()
+	handlers := w.handlers
+	w.lock.Unlock()
+
+	if old == nil {
+		return nil
+	}
+	changes := instanceChanges(old, instances)
+	if len(changes) > 0 {
+		klog.V(2).Infof("Instances of the managed zones changed: %d changes", len(changes))
+	}
+	for _, change := range changes {
+		for _, handler := range handlers {
+			handler(change)
+		}
+	}
+	return nil
+}
+
+// instanceChanges returns the changes between two listings of the instances,
+// sorted by provider ID.
+func instanceChanges(old, instances map[string]*compute.Instance) []InstanceChange {
+	var changes []InstanceChange
+	for providerID, instance := range instances {
+		prev, ok := old[providerID]
+		switch {
+		case !ok:
+			changes = append(changes, InstanceChange{Type: InstanceAdded, ProviderID: providerID, New: instance})
+		case instanceChanged(prev, instance):
+			changes = append(changes, InstanceChange{Type: InstanceUpdated, ProviderID: providerID, Old: prev, New: instance})
+		}
+	}
+	for providerID, prev := range old {
+		if _, ok := instances[providerID]; !ok {
+			changes = append(changes, InstanceChange{Type: InstanceDeleted, ProviderID: providerID, Old: prev})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ProviderID < changes[j].ProviderID })
+	return changes
+}
+
+// instanceChanged returns whether an instance was recreated, or its status,
+// network interfaces or contents changed.
+func instanceChanged(old, instance *compute.Instance) bool {
+	return old.Id != instance.Id || old.Status != instance.Status || old.Fingerprint != instance.Fingerprint ||
+		!reflect.DeepEqual(old.NetworkInterfaces, instance.NetworkInterfaces)
+}
+
+// instancesRefreshPeriod returns how often the instances are listed again,
+// less often if their changes are delivered by an instance change feed.
+func (g *Cloud) instancesRefreshPeriod() time.Duration {
+	if g.instanceChangeFeed != nil {
+		return instancesFeedRefreshPeriod
+	}
+	return instancesRefreshPeriod
+}
+
+// runInstancesRefresh refreshes the instances every instancesRefreshPeriod
+// until stop is closed.
+func (g *Cloud) runInstancesRefresh(stop <-chan struct{}) {
+	wait.Until(func() {
+		if err := g.refreshInstances(); err != nil {
+			klog.Warningf("Failed to refresh the instances of project %s: %v", g.projectID, err)
+		}
+	}, g.instancesRefreshPeriod(), stop)
+}
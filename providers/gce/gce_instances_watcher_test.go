@@ -0,0 +1,189 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	ga "google.golang.org/api/compute/v1"
+)
+
+func TestInstancesWatcher(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	gce.instancesWatcher.now = func() time.Time { return now }
+	c := gce.c.(*cloud.MockGCE)
+	insert := func(name string, id uint64, network string) {
+		key := meta.ZonalKey(name, vals.ZoneName)
+		c.MockInstances.Objects[*key] = &cloud.MockInstancesObj{Obj: &ga.Instance{
+			Name:              name,
+			Id:                id,
+			Zone:              vals.ZoneName,
+			NetworkInterfaces: []*ga.NetworkInterface{{Network: network}},
+		}}
+	}
+	gets := 0
+	c.MockInstances.GetHook = func(ctx context.Context, key *meta.Key, m *cloud.MockInstances) (bool, *ga.Instance, error) {
+		gets++
+		return false, nil, nil
+	}
+	var changes []InstanceChange
+	gce.AddInstanceChangeHandler(func(change InstanceChange) {
+		changes = append(changes, change)
+	})
+	providerID := func(name string) string {
+		return instanceProviderID(vals.ProjectID, vals.ZoneName, name)
+	}
+	insert("node-a", 1, "default")
+	insert("node-b", 2, "default")
+
+	_, err = gce.InstanceByProviderID(providerID("node-a"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, gets, "instances should be read from the API before they are listed")
+
+	require.NoError(t, gce.refreshInstances())
+	assert.Empty(t, changes, "the first listing should not call the change handlers")
+	instance, err := gce.instanceByProviderID(providerID("node-a"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), instance.ID)
+	exists, err := gce.InstanceExistsByProviderID(context.TODO(), providerID("node-b"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+	route, err := gce.getInstanceByName("node-b")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), route.ID)
+	assert.Equal(t, 1, gets, "listed instances should not be read from the API")
+
+	insert("node-a", 3, "default")
+	insert("node-c", 4, "default")
+	delete(c.MockInstances.Objects, *meta.ZonalKey("node-b", vals.ZoneName))
+	insert("node-d", 5, "default")
+	require.NoError(t, gce.refreshInstances())
+	var got []string
+	for _, change := range changes {
+		got = append(got, string(change.Type)+" "+change.ProviderID)
+	}
+	assert.Equal(t, []string{
+		"Updated " + providerID("node-a"),
+		"Deleted " + providerID("node-b"),
+		"Added " + providerID("node-c"),
+		"Added " + providerID("node-d"),
+	}, got)
+	assert.Equal(t, uint64(1), changes[0].Old.Id)
+	assert.Equal(t, uint64(3), changes[0].New.Id)
+
+	exists, err = gce.InstanceExistsByProviderID(context.TODO(), providerID("node-b"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 2, gets, "instances missing from the listing should be read from the API")
+
+	// The recreation of node-a is not listed yet.
+	insert("node-a", 6, "default")
+	fresh, err := gce.InstanceByProviderID(providerID("node-a"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), fresh.Id, "InstanceByProviderID should read the instance from the API")
+	assert.Equal(t, 3, gets)
+
+	now = now.Add(3 * instancesRefreshPeriod)
+	_, err = gce.instanceByProviderID(providerID("node-c"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, gets, "instances should be read from the API when the listing is stale")
+}
+
+func TestInstanceChanged(t *testing.T) {
+	instance := func(id uint64, status, fingerprint, network string) *ga.Instance {
+		return &ga.Instance{Id: id, Status: status, Fingerprint: fingerprint, NetworkInterfaces: []*ga.NetworkInterface{{Network: network}}}
+	}
+	base := instance(1, "RUNNING", "abc", "default")
+	testCases := []struct {
+		desc     string
+		instance *ga.Instance
+		want     bool
+	}{
+		{desc: "unchanged", instance: instance(1, "RUNNING", "abc", "default")},
+		{desc: "recreated", instance: instance(2, "RUNNING", "abc", "default"), want: true},
+		{desc: "stopped", instance: instance(1, "TERMINATED", "abc", "default"), want: true},
+		{desc: "updated", instance: instance(1, "RUNNING", "def", "default"), want: true},
+		{desc: "network interfaces changed", instance: instance(1, "RUNNING", "abc", "red"), want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, instanceChanged(base, tc.instance))
+		})
+	}
+}
+
+func TestInstanceChangesOfControllerCloud(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	gce.computeClient = &http.Client{Transport: http.DefaultTransport}
+	gce.serviceBeta, gce.serviceAlpha = &computebeta.Service{}, &computealpha.Service{}
+	feed := &fakeInstanceChangeFeed{}
+	gce.instanceChangeFeed = feed
+	c := gce.c.(*cloud.MockGCE)
+	insert := func(name string, id uint64) {
+		c.MockInstances.Objects[*meta.ZonalKey(name, vals.ZoneName)] = &cloud.MockInstancesObj{Obj: &ga.Instance{Name: name, Id: id, Zone: vals.ZoneName}}
+	}
+
+	// The controllers register their handlers on their own Clouds, while the
+	// refreshes and the change feed run on the initialized cloud.
+	controllerCloud, err := gce.ForController("route")
+	require.NoError(t, err)
+	require.NotSame(t, gce, controllerCloud)
+	var changes []string
+	controllerCloud.AddInstanceChangeHandler(func(change InstanceChange) {
+		changes = append(changes, string(change.Type)+" "+change.New.Name)
+	})
+	var zonesChanges []string
+	controllerCloud.AddZonesChangeHandler(func(region string, added, removed []string) {
+		zonesChanges = append(zonesChanges, added...)
+	})
+
+	insert("node-a", 1)
+	require.NoError(t, gce.refreshInstances())
+	insert("node-b", 2)
+	require.NoError(t, gce.refreshInstances())
+	assert.Equal(t, []string{"Added node-b"}, changes, "the handler of the controller cloud should be called by the refresh")
+	_, ok := controllerCloud.cachedInstance(instanceProviderID(vals.ProjectID, vals.ZoneName, "node-b"))
+	assert.True(t, ok, "the controller cloud should read the listed instances")
+
+	providerID := instanceProviderID(vals.ProjectID, vals.ZoneName, "node-c")
+	feed.changes = []InstanceChange{{Type: InstanceAdded, ProviderID: providerID, New: &ga.Instance{Name: "node-c"}}}
+	require.NoError(t, gce.pullInstanceChanges(context.Background()))
+	assert.Equal(t, []string{"Added node-b", "Added node-c"}, changes, "the handler of the controller cloud should be called by the change feed")
+
+	_, err = gce.ListZonesInRegion(vals.Region)
+	require.NoError(t, err)
+	insertZone(c, vals.Region, vals.SecondaryZoneName)
+	require.NoError(t, gce.refreshZones())
+	assert.Equal(t, []string{vals.SecondaryZoneName}, zonesChanges, "the zones handler of the controller cloud should be called by the refresh")
+}
@@ -222,8 +222,20 @@ func (g *Cloud) ensureExternalLoadBalancer(clusterName string, clusterID string,
 	if err != nil && !isHTTPErrorCode(err, http.StatusNotFound) {
 		return nil, fmt.Errorf("error checking HTTP health check for load balancer (%s): %v", lbRefStr, err)
 	}
-	if path, healthCheckNodePort := servicehelpers.GetServiceHealthCheckPathPort(apiService); path != "" {
-		klog.V(4).Infof("ensureExternalLoadBalancer(%s): Service needs local traffic health checks on: %d%s.", lbRefStr, healthCheckNodePort, path)
+	hcOverrides, err := GetLoadBalancerAnnotationHealthCheck(apiService)
+	if err != nil {
+		return nil, fmt.Errorf("error getting health check parameters for load balancer (%s): %v", lbRefStr, err)
+	}
+	path, healthCheckNodePort := servicehelpers.GetServiceHealthCheckPathPort(apiService)
+	if path == "" && hcOverrides.IsSet() {
+		// Customized health checks cannot be shared with the other services:
+		// the service gets its own, based on the nodes health check.
+		path, healthCheckNodePort = GetNodesHealthCheckPath(), GetNodesHealthCheckPort()
+	}
+	if path != "" {
+		hcToCreate = makeHTTPHealthCheck(loadBalancerName, path, healthCheckNodePort)
+		hcOverrides.apply(hcToCreate)
+		klog.V(4).Infof("ensureExternalLoadBalancer(%s): Service needs its own health checks on: %d%s.", lbRefStr, hcToCreate.Port, hcToCreate.RequestPath)
 		if hcLocalTrafficExisting == nil {
 			// This logic exists to detect a transition for non-OnlyLocal to OnlyLocal service
 			// turn on the tpNeedsRecreation flag to delete/recreate fwdrule/tpool updating the
@@ -234,7 +246,6 @@ func (g *Cloud) ensureExternalLoadBalancer(clusterName string, clusterID string,
 			}
 			tpNeedsRecreation = true
 		}
-		hcToCreate = makeHTTPHealthCheck(loadBalancerName, path, healthCheckNodePort)
 	} else {
 		klog.V(4).Infof("ensureExternalLoadBalancer(%s): Service needs nodes health checks.", lbRefStr)
 		if hcLocalTrafficExisting != nil {
@@ -298,12 +309,21 @@ func (g *Cloud) updateExternalLoadBalancer(clusterName string, service *v1.Servi
 	}
 
 	hosts, err := g.getInstancesByNames(nodeNames(nodes))
-	if err != nil {
+	zoneErrs, partial := err.(zoneErrors)
+	if err != nil && !partial {
 		return err
 	}
 
+	// The instances of the zones that could not be listed are left in the
+	// target pool, the hosts of the other zones are updated.
 	loadBalancerName := g.GetLoadBalancerName(context.TODO(), clusterName, service)
-	return g.updateTargetPool(loadBalancerName, hosts)
+	if err := g.updateTargetPool(loadBalancerName, hosts, zoneErrs); err != nil {
+		return err
+	}
+	if partial {
+		return zoneErrs
+	}
+	return nil
 }
 
 // ensureExternalLoadBalancerDeleted is the external implementation of LoadBalancer.EnsureLoadBalancerDeleted
@@ -521,19 +541,25 @@ func (g *Cloud) ensureTargetPoolAndHealthCheck(tpExists, tpNeedsRecreation bool,
 			klog.Infof("ensureTargetPoolAndHealthCheck(%s): Created target pool.", lbRefStr)
 		} else {
 			klog.Infof("ensureTargetPoolAndHealthCheck(%s): Created initial target pool (now updating the remaining %d hosts).", lbRefStr, len(hosts)-maxTargetPoolCreateInstances)
-			if err := g.updateTargetPool(loadBalancerName, hosts); err != nil {
+			if err := g.updateTargetPool(loadBalancerName, hosts, nil); err != nil {
 				return fmt.Errorf("failed to update target pool for load balancer (%s): %v", lbRefStr, err)
 			}
 			klog.Infof("ensureTargetPoolAndHealthCheck(%s): Updated target pool (with %d hosts).", lbRefStr, len(hosts)-maxTargetPoolCreateInstances)
 		}
 	} else if tpExists {
 		// Ensure hosts are updated even if there is no other changes required on target pool.
-		if err := g.updateTargetPool(loadBalancerName, hosts); err != nil {
+		if err := g.updateTargetPool(loadBalancerName, hosts, nil); err != nil {
 			return fmt.Errorf("failed to update target pool for load balancer (%s): %v", lbRefStr, err)
 		}
 		klog.Infof("ensureTargetPoolAndHealthCheck(%s): Updated target pool (with %d hosts).", lbRefStr, len(hosts))
 		if hcToCreate != nil {
-			if hc, err := g.ensureHTTPHealthCheck(hcToCreate.Name, hcToCreate.RequestPath, int32(hcToCreate.Port)); err != nil || hc == nil {
+			if hcToCreate.Name == loadBalancerName {
+				// The port of the service's own health check may have changed.
+				if err := g.ensureHTTPHealthCheckFirewall(svc, serviceName.String(), ipAddressToUse, g.region, clusterID, hosts, hcToCreate.Name, int32(hcToCreate.Port), false); err != nil {
+					return err
+				}
+			}
+			if hc, err := g.ensureHTTPHealthCheckWithOverrides(hcToCreate.Name, hcToCreate.RequestPath, int32(hcToCreate.Port), healthCheckOverrides(svc, hcToCreate.Name, loadBalancerName)); err != nil || hc == nil {
 				return fmt.Errorf("failed to ensure health check for %v port %d path %v: %v", loadBalancerName, hcToCreate.Port, hcToCreate.RequestPath, err)
 			}
 		}
@@ -563,7 +589,7 @@ func (g *Cloud) createTargetPoolAndHealthCheck(svc *v1.Service, name, serviceNam
 		}
 		var err error
 		hcRequestPath, hcPort := hc.RequestPath, hc.Port
-		if hc, err = g.ensureHTTPHealthCheck(hc.Name, hc.RequestPath, int32(hc.Port)); err != nil || hc == nil {
+		if hc, err = g.ensureHTTPHealthCheckWithOverrides(hc.Name, hc.RequestPath, int32(hc.Port), healthCheckOverrides(svc, hc.Name, name)); err != nil || hc == nil {
 			return fmt.Errorf("failed to ensure health check for %v port %d path %v: %v", name, hcPort, hcRequestPath, err)
 		}
 		hcLinks = append(hcLinks, hc.SelfLink)
@@ -588,13 +614,20 @@ func (g *Cloud) createTargetPoolAndHealthCheck(svc *v1.Service, name, serviceNam
 	return nil
 }
 
-func (g *Cloud) updateTargetPool(loadBalancerName string, hosts []*gceInstance) error {
+// updateTargetPool sets the instances of the target pool to the hosts. The
+// instances in the failed zones are kept, since their hosts are not known.
+func (g *Cloud) updateTargetPool(loadBalancerName string, hosts []*gceInstance, failedZones zoneErrors) error {
 	pool, err := g.GetTargetPool(loadBalancerName, g.region)
 	if err != nil {
 		return err
 	}
 	existing := sets.NewString()
+	kept := 0
 	for _, instance := range pool.Instances {
+		if _, ok := failedZones[zoneFromURL(instance)]; ok {
+			kept++
+			continue
+		}
 		existing.Insert(hostURLToComparablePath(instance))
 	}
 
@@ -646,10 +679,10 @@ func (g *Cloud) updateTargetPool(loadBalancerName string, hosts []*gceInstance)
 	if err != nil {
 		return err
 	}
-	if len(updatedPool.Instances) != len(hosts) {
+	if len(updatedPool.Instances) != len(hosts)+kept {
 		klog.Errorf("Unexpected number of instances (%d) in target pool %s after updating (expected %d). Instances in updated pool: %s",
-			len(updatedPool.Instances), loadBalancerName, len(hosts), strings.Join(updatedPool.Instances, ","))
-		return fmt.Errorf("unexpected number of instances (%d) in target pool %s after update (expected %d)", len(updatedPool.Instances), loadBalancerName, len(hosts))
+			len(updatedPool.Instances), loadBalancerName, len(hosts)+kept, strings.Join(updatedPool.Instances, ","))
+		return fmt.Errorf("unexpected number of instances (%d) in target pool %s after update (expected %d)", len(updatedPool.Instances), loadBalancerName, len(hosts)+kept)
 	}
 	return nil
 }
@@ -710,8 +743,51 @@ func needToUpdateHTTPHealthChecks(hc, newHC *compute.HttpHealthCheck) bool {
 	return false
 }
 
+// apply sets the overridden parameters on the health check. A health check
+// timeout longer than the interval is rejected by GCE, so it is shortened to
+// the interval.
+func (o HealthCheckOverrides) apply(hc *compute.HttpHealthCheck) {
+	if o.IntervalSec != 0 {
+		hc.CheckIntervalSec = o.IntervalSec
+		if hc.TimeoutSec > hc.CheckIntervalSec {
+			hc.TimeoutSec = hc.CheckIntervalSec
+		}
+	}
+	if o.Port != 0 {
+		hc.Port = int64(o.Port)
+	}
+	if o.RequestPath != "" {
+		hc.RequestPath = o.RequestPath
+	}
+}
+
+// healthCheckOverrides returns the health check parameters set by the
+// annotations of the service for its own health check, but none for the
+// nodes health check shared with the other services.
+func healthCheckOverrides(svc *v1.Service, hcName, loadBalancerName string) HealthCheckOverrides {
+	if hcName != loadBalancerName {
+		return HealthCheckOverrides{}
+	}
+	o, err := GetLoadBalancerAnnotationHealthCheck(svc)
+	if err != nil {
+		// Invalid annotations are rejected before the health check is ensured.
+		klog.Warningf("Ignoring the health check annotations of service %s/%s: %v", svc.Namespace, svc.Name, err)
+		return HealthCheckOverrides{}
+	}
+	return o
+}
+
 func (g *Cloud) ensureHTTPHealthCheck(name, path string, port int32) (hc *compute.HttpHealthCheck, err error) {
+	return g.ensureHTTPHealthCheckWithOverrides(name, path, port, HealthCheckOverrides{})
+}
+
+// ensureHTTPHealthCheckWithOverrides ensures the health check with the
+// parameters overridden by the annotations of the service. An overridden
+// interval is set exactly, while the other intervals are only raised to the
+// defaults.
+func (g *Cloud) ensureHTTPHealthCheckWithOverrides(name, path string, port int32, overrides HealthCheckOverrides) (hc *compute.HttpHealthCheck, err error) {
 	newHC := makeHTTPHealthCheck(name, path, port)
+	overrides.apply(newHC)
 	hc, err = g.GetHTTPHealthCheck(name)
 	if hc == nil || err != nil && isHTTPErrorCode(err, http.StatusNotFound) {
 		klog.Infof("Did not find health check %v, creating port %v path %v", name, port, path)
@@ -728,9 +804,10 @@ func (g *Cloud) ensureHTTPHealthCheck(name, path string, port int32) (hc *comput
 	}
 	// Validate health check fields
 	klog.V(4).Infof("Checking http health check params %s", name)
-	if needToUpdateHTTPHealthChecks(hc, newHC) {
+	if needToUpdateHTTPHealthChecks(hc, newHC) || overrides.IntervalSec != 0 && hc.CheckIntervalSec != overrides.IntervalSec {
 		klog.Warningf("Health check %v exists but parameters have drifted - updating...", name)
 		mergeHTTPHealthChecks(hc, newHC)
+		overrides.apply(newHC)
 		if err := g.UpdateHTTPHealthCheck(newHC); err != nil {
 			klog.Warningf("Failed to reconcile http health check %v parameters", name)
 			return nil, err
@@ -1526,6 +1526,57 @@ func TestExternalLoadBalancerEnsureHttpHealthCheck(t *testing.T) {
 
 }
 
+func TestEnsureExternalLoadBalancerHealthCheckAnnotations(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	nodeNames := []string{"test-node-1"}
+
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	c := gce.c.(*cloud.MockGCE)
+	c.MockHttpHealthChecks.UpdateHook = func(ctx context.Context, key *meta.Key, obj *compute.HttpHealthCheck, m *cloud.MockHttpHealthChecks) error {
+		m.Objects[*key] = &cloud.MockHttpHealthChecksObj{Obj: obj}
+		return nil
+	}
+
+	svc := fakeLoadbalancerService("")
+	svc.Annotations[ServiceAnnotationHealthCheckInterval] = "30"
+	svc.Annotations[ServiceAnnotationHealthCheckPort] = "8080"
+	svc.Annotations[ServiceAnnotationHealthCheckRequestPath] = "/ready"
+	_, err = createExternalLoadBalancer(gce, svc, nodeNames, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	require.NoError(t, err)
+
+	// The service gets its own health check instead of the nodes health check.
+	lbName := gce.GetLoadBalancerName(context.TODO(), "", svc)
+	hc, err := gce.GetHTTPHealthCheck(lbName)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), hc.CheckIntervalSec)
+	assert.Equal(t, int64(8080), hc.Port)
+	assert.Equal(t, "/ready", hc.RequestPath)
+	fw, err := gce.GetFirewall(MakeHealthCheckFirewallName(vals.ClusterID, lbName, false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8080"}, fw.Allowed[0].Ports)
+
+	// A shorter interval and a new port are applied on update.
+	svc.Annotations[ServiceAnnotationHealthCheckInterval] = "2"
+	svc.Annotations[ServiceAnnotationHealthCheckPort] = "8081"
+	_, err = createExternalLoadBalancer(gce, svc, nodeNames, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	require.NoError(t, err)
+	hc, err = gce.GetHTTPHealthCheck(lbName)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), hc.CheckIntervalSec)
+	assert.Equal(t, int64(8081), hc.Port)
+	fw, err = gce.GetFirewall(MakeHealthCheckFirewallName(vals.ClusterID, lbName, false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8081"}, fw.Allowed[0].Ports)
+
+	// Invalid annotations are reported.
+	svc.Annotations[ServiceAnnotationHealthCheckRequestPath] = "ready"
+	_, err = createExternalLoadBalancer(gce, svc, nodeNames, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	assert.Error(t, err)
+}
+
 func TestMergeHttpHealthChecks(t *testing.T) {
 	t.Parallel()
 	for _, tc := range []struct {
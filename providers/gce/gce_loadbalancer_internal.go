@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
@@ -92,6 +93,11 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	}
 	scheme := cloud.SchemeInternal
 	options := getILBOptions(svc)
+	staticIP, err := GetLoadBalancerAnnotationStaticIP(svc)
+	if err != nil {
+		return nil, err
+	}
+	options.StaticIP = staticIP
 	if g.IsLegacyNetwork() {
 		g.eventRecorder.Event(svc, v1.EventTypeWarning, "ILBOptionsIgnored", "Internal LoadBalancer options are not supported with Legacy Networks.")
 		options = ILBOptions{}
@@ -104,14 +110,15 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	// Ensure instance groups exist and nodes are assigned to groups
 	igName := makeInstanceGroupName(clusterID)
 	igLinks, err := g.ensureInternalInstanceGroups(igName, nodes)
-	if err != nil {
+	failedZones, partial := err.(zoneErrors)
+	if err != nil && !partial {
 		return nil, err
 	}
 
 	// Get existing backend service (if exists)
 	var existingBackendService *compute.BackendService
 	if existingFwdRule != nil && existingFwdRule.BackendService != "" {
-		existingBSName := getNameFromLink(existingFwdRule.BackendService)
+		existingBSName := resourceName(existingFwdRule.BackendService)
 		if existingBackendService, err = g.GetRegionBackendService(existingBSName, g.region); err != nil && !isNotFound(err) {
 			return nil, err
 		}
@@ -146,6 +153,13 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	// Determine IP which will be used for this LB. If no forwarding rule has been established
 	// or specified in the Service spec, then requestedIP = "".
 	ipToUse := ilbIPToUse(svc, existingFwdRule, subnetworkURL)
+	if options.StaticIP != "" {
+		if err := g.checkInternalIPNotInUse(loadBalancerName, options.StaticIP); err != nil {
+			g.eventRecorder.Event(svc, v1.EventTypeWarning, "StaticIPConflict", err.Error())
+			return nil, err
+		}
+		ipToUse = options.StaticIP
+	}
 
 	klog.V(2).Infof("ensureInternalLoadBalancer(%v): Using subnet %s for LoadBalancer IP %s", loadBalancerName, options.SubnetName, ipToUse)
 
@@ -153,8 +167,13 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	// If the network is not a legacy network, use the address manager
 	if !g.IsLegacyNetwork() {
 		addrMgr = newAddressManager(g, nm.String(), g.Region(), subnetworkURL, loadBalancerName, ipToUse, cloud.SchemeInternal)
+		// A static IP stays reserved until the service is deleted or unpins it.
+		addrMgr.keepReservation = options.StaticIP != ""
 		ipToUse, err = addrMgr.HoldAddress()
 		if err != nil {
+			if isAddressConflict(err) {
+				g.eventRecorder.Event(svc, v1.EventTypeWarning, "StaticIPConflict", err.Error())
+			}
 			return nil, err
 		}
 		klog.V(2).Infof("ensureInternalLoadBalancer(%v): reserved IP %q for the forwarding rule", loadBalancerName, ipToUse)
@@ -208,7 +227,7 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	}
 
 	bsDescription := makeBackendServiceDescription(nm, sharedBackend)
-	err = g.ensureInternalBackendService(backendServiceName, bsDescription, svc.Spec.SessionAffinity, scheme, protocol, igLinks, hc.SelfLink)
+	err = g.ensureInternalBackendService(backendServiceName, bsDescription, svc.Spec.SessionAffinity, scheme, protocol, igLinks, failedZones, hc.SelfLink)
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +268,12 @@ func (g *Cloud) ensureInternalLoadBalancer(clusterName, clusterID string, svc *v
 	}
 	klog.V(6).Infof("Internal Loadbalancer for Service %s ensured, updating its state %v in metrics cache", nm, serviceState)
 
+	// The load balancer serves the healthy zones, the service is retried for
+	// the instance groups of the failed zones.
+	if partial {
+		return nil, failedZones
+	}
+
 	status := &v1.LoadBalancerStatus{}
 	status.Ingress = []v1.LoadBalancerIngress{{IP: updatedFwdRule.IPAddress}}
 	return status, nil
@@ -265,7 +290,7 @@ func (g *Cloud) clearPreviousInternalResources(svc *v1.Service, loadBalancerName
 
 	// If a new health check was created, delete the old one.
 	if len(existingBackendService.HealthChecks) == 1 {
-		existingHCName := getNameFromLink(existingBackendService.HealthChecks[0])
+		existingHCName := resourceName(existingBackendService.HealthChecks[0])
 		if existingHCName != expectedHCName {
 			klog.V(2).Infof("clearPreviousInternalResources(%v): expected health check %q does not match previous %q - deleting health check", loadBalancerName, expectedHCName, existingHCName)
 			if err := g.teardownInternalHealthCheckAndFirewall(svc, existingHCName); err != nil {
@@ -289,7 +314,8 @@ func (g *Cloud) updateInternalLoadBalancer(clusterName, clusterID string, svc *v
 
 	igName := makeInstanceGroupName(clusterID)
 	igLinks, err := g.ensureInternalInstanceGroups(igName, nodes)
-	if err != nil {
+	failedZones, partial := err.(zoneErrors)
+	if err != nil && !partial {
 		return err
 	}
 
@@ -299,7 +325,13 @@ func (g *Cloud) updateInternalLoadBalancer(clusterName, clusterID string, svc *v
 	loadBalancerName := g.GetLoadBalancerName(context.TODO(), clusterName, svc)
 	backendServiceName := makeBackendServiceName(loadBalancerName, clusterID, shareBackendService(svc), scheme, protocol, svc.Spec.SessionAffinity)
 	// Ensure the backend service has the proper backend/instance-group links
-	return g.ensureInternalBackendServiceGroups(backendServiceName, igLinks)
+	if err := g.ensureInternalBackendServiceGroups(backendServiceName, igLinks, failedZones); err != nil {
+		return err
+	}
+	if partial {
+		return failedZones
+	}
+	return nil
 }
 
 func (g *Cloud) ensureInternalLoadBalancerDeleted(clusterName, clusterID string, svc *v1.Service) error {
@@ -595,8 +627,12 @@ func (g *Cloud) ensureInternalInstanceGroup(name, zone string, nodes []*v1.Node)
 		}
 
 		for _, ins := range instances {
-			parts := strings.Split(ins.Instance, "/")
-			gceNodes.Insert(parts[len(parts)-1])
+			insName := resourceName(ins.Instance)
+			if insName == "" {
+				klog.Warningf("ensureInternalInstanceGroup(%v, %v): ignoring invalid instance URL %q", name, zone, ins.Instance)
+				continue
+			}
+			gceNodes.Insert(insName)
 		}
 	}
 
@@ -624,30 +660,45 @@ func (g *Cloud) ensureInternalInstanceGroup(name, zone string, nodes []*v1.Node)
 }
 
 // ensureInternalInstanceGroups generates an unmanaged instance group for every zone
-// where a K8s node exists. It also ensures that each node belongs to an instance group
+// where a K8s node exists. It also ensures that each node belongs to an instance group.
+// If this failed in some zones, the links of the groups of the other zones are
+// returned with a zoneErrors.
 func (g *Cloud) ensureInternalInstanceGroups(name string, nodes []*v1.Node) ([]string, error) {
 	zonedNodes := splitNodesByZone(nodes)
 	klog.V(2).Infof("ensureInternalInstanceGroups(%v): %d nodes over %d zones in region %v", name, len(nodes), len(zonedNodes), g.region)
-	var igLinks []string
-	for zone, nodes := range zonedNodes {
+	var (
+		mu      sync.Mutex
+		igLinks []string
+		zones   []string
+	)
+	for zone := range zonedNodes {
+		zones = append(zones, zone)
+	}
+	// The instance groups of the zones are independent: a failing zone does
+	// not prevent the nodes of the other zones from joining their groups.
+	err := forEachZone("ensureInternalInstanceGroups", zones, func(zone string) error {
+		var links []string
 		if g.AlphaFeatureGate.Enabled(AlphaFeatureSkipIGsManagement) {
 			igs, err := g.FilterInstanceGroupsByNamePrefix(name, zone)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			for _, ig := range igs {
-				igLinks = append(igLinks, ig.SelfLink)
+				links = append(links, ig.SelfLink)
 			}
 		} else {
-			igLink, err := g.ensureInternalInstanceGroup(name, zone, nodes)
+			igLink, err := g.ensureInternalInstanceGroup(name, zone, zonedNodes[zone])
 			if err != nil {
-				return nil, err
+				return err
 			}
-			igLinks = append(igLinks, igLink)
+			links = append(links, igLink)
 		}
-	}
-
-	return igLinks, nil
+		mu.Lock()
+		igLinks = append(igLinks, links...)
+		mu.Unlock()
+		return nil
+	})
+	return igLinks, err
 }
 
 func (g *Cloud) ensureInternalInstanceGroupsDeleted(name string) error {
@@ -660,23 +711,34 @@ func (g *Cloud) ensureInternalInstanceGroupsDeleted(name string) error {
 	// Skip Instance Group deletion if IG management was moved out of k/k code
 	if !g.AlphaFeatureGate.Enabled(AlphaFeatureSkipIGsManagement) {
 		klog.V(2).Infof("ensureInternalInstanceGroupsDeleted(%v): attempting delete instance group in all %d zones", name, len(zones))
+		var zoneNames []string
 		for _, z := range zones {
-			if err := g.DeleteInstanceGroup(name, z.Name); err != nil && !isNotFoundOrInUse(err) {
+			zoneNames = append(zoneNames, z.Name)
+		}
+		return forEachZone("ensureInternalInstanceGroupsDeleted", zoneNames, func(zone string) error {
+			if err := g.DeleteInstanceGroup(name, zone); err != nil && !isNotFoundOrInUse(err) {
 				return err
 			}
-		}
+			return nil
+		})
 	}
 	return nil
 }
 
-func (g *Cloud) ensureInternalBackendService(name, description string, affinityType v1.ServiceAffinity, scheme cloud.LbScheme, protocol v1.Protocol, igLinks []string, hcLink string) error {
+// ensureInternalBackendService ensures the backend service and its backends.
+// The backends in the failed zones are kept as they are.
+func (g *Cloud) ensureInternalBackendService(name, description string, affinityType v1.ServiceAffinity, scheme cloud.LbScheme, protocol v1.Protocol, igLinks []string, failedZones zoneErrors, hcLink string) error {
 	klog.V(2).Infof("ensureInternalBackendService(%v, %v, %v): checking existing backend service with %d groups", name, scheme, protocol, len(igLinks))
 	bs, err := g.GetRegionBackendService(name, g.region)
 	if err != nil && !isNotFound(err) {
 		return err
 	}
 
-	backends := backendsFromGroupLinks(igLinks)
+	var existingBackends []*compute.Backend
+	if bs != nil {
+		existingBackends = bs.Backends
+	}
+	backends := backendsFromGroupLinks(withFailedZoneGroups(igLinks, existingBackends, failedZones))
 	expectedBS := &compute.BackendService{
 		Name:                name,
 		Protocol:            string(protocol),
@@ -713,14 +775,15 @@ func (g *Cloud) ensureInternalBackendService(name, description string, affinityT
 }
 
 // ensureInternalBackendServiceGroups updates backend services if their list of backend instance groups is incorrect.
-func (g *Cloud) ensureInternalBackendServiceGroups(name string, igLinks []string) error {
+// The backends in the failed zones are kept as they are.
+func (g *Cloud) ensureInternalBackendServiceGroups(name string, igLinks []string, failedZones zoneErrors) error {
 	klog.V(2).Infof("ensureInternalBackendServiceGroups(%v): checking existing backend service's groups", name)
 	bs, err := g.GetRegionBackendService(name, g.region)
 	if err != nil {
 		return err
 	}
 
-	backends := backendsFromGroupLinks(igLinks)
+	backends := backendsFromGroupLinks(withFailedZoneGroups(igLinks, bs.Backends, failedZones))
 	if backendsListEqual(bs.Backends, backends) {
 		return nil
 	}
@@ -740,6 +803,17 @@ func shareBackendService(svc *v1.Service) bool {
 	return GetLoadBalancerAnnotationBackendShare(svc) && !servicehelpers.RequestsOnlyLocalTraffic(svc)
 }
 
+// withFailedZoneGroups appends to igLinks the groups of the backends in the
+// failed zones, whose instance groups could not be ensured.
+func withFailedZoneGroups(igLinks []string, backends []*compute.Backend, failedZones zoneErrors) []string {
+	for _, backend := range backends {
+		if _, ok := failedZones[zoneFromURL(backend.Group)]; ok {
+			igLinks = append(igLinks, backend.Group)
+		}
+	}
+	return igLinks
+}
+
 func backendsFromGroupLinks(igLinks []string) (backends []*compute.Backend) {
 	for _, igLink := range igLinks {
 		backends = append(backends, &compute.Backend{
@@ -911,15 +985,6 @@ func (g *Cloud) getBackendServiceLink(name string) string {
 	return g.projectsBasePath + strings.Join([]string{g.projectID, "regions", g.region, "backendServices", name}, "/")
 }
 
-func getNameFromLink(link string) string {
-	if link == "" {
-		return ""
-	}
-
-	fields := strings.Split(link, "/")
-	return fields[len(fields)-1]
-}
-
 // ilbIPToUse determines which IP address needs to be used in the ForwardingRule. If an IP has been
 // specified by the user, that is used. If there is an existing ForwardingRule, the ip address from
 // that is reused. In case a subnetwork change is requested, the existing ForwardingRule IP is ignored.
@@ -937,6 +1002,27 @@ func ilbIPToUse(svc *v1.Service, fwdRule *compute.ForwardingRule, requestedSubne
 	return fwdRule.IPAddress
 }
 
+// checkInternalIPNotInUse returns an error if a forwarding rule other than the
+// one of the load balancer already uses the internal IP.
+func (g *Cloud) checkInternalIPNotInUse(loadBalancerName, ip string) error {
+	rules, err := g.ListRegionForwardingRules(g.region)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.IPAddress != ip || rule.Name == loadBalancerName {
+			continue
+		}
+		owner := rule.Name
+		d := &forwardingRuleDescription{}
+		if err := d.unmarshal(rule.Description); err == nil && d.ServiceName != "" {
+			owner = fmt.Sprintf("%s of service %s", rule.Name, d.ServiceName)
+		}
+		return fmt.Errorf("internal IP %q is already used by forwarding rule %s", ip, owner)
+	}
+	return nil
+}
+
 func getILBOptions(svc *v1.Service) ILBOptions {
 	return ILBOptions{AllowGlobalAccess: GetLoadBalancerAnnotationAllowGlobalAccess(svc),
 		SubnetName: GetLoadBalancerAnnotationSubnet(svc),
@@ -22,6 +22,7 @@ package gce
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"sort"
 	"strings"
@@ -34,6 +35,7 @@ import (
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/mock"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -76,11 +78,11 @@ func TestEnsureInternalBackendServiceUpdates(t *testing.T) {
 
 	sharedBackend := shareBackendService(svc)
 	bsName := makeBackendServiceName(lbName, vals.ClusterID, sharedBackend, cloud.SchemeInternal, "TCP", svc.Spec.SessionAffinity)
-	err = gce.ensureInternalBackendService(bsName, "description", svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, "")
+	err = gce.ensureInternalBackendService(bsName, "description", svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, nil, "")
 	require.NoError(t, err)
 
 	// Update the Internal Backend Service with a new ServiceAffinity
-	err = gce.ensureInternalBackendService(bsName, "description", v1.ServiceAffinityNone, cloud.SchemeInternal, "TCP", igLinks, "")
+	err = gce.ensureInternalBackendService(bsName, "description", v1.ServiceAffinityNone, cloud.SchemeInternal, "TCP", igLinks, nil, "")
 	require.NoError(t, err)
 
 	bs, err := gce.GetRegionBackendService(bsName, gce.region)
@@ -124,7 +126,7 @@ func TestEnsureInternalBackendServiceGroups(t *testing.T) {
 			sharedBackend := shareBackendService(svc)
 			bsName := makeBackendServiceName(lbName, vals.ClusterID, sharedBackend, cloud.SchemeInternal, "TCP", svc.Spec.SessionAffinity)
 
-			err = gce.ensureInternalBackendService(bsName, "description", svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, "")
+			err = gce.ensureInternalBackendService(bsName, "description", svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, nil, "")
 			require.NoError(t, err)
 
 			// Update the BackendService with new InstanceGroups
@@ -132,7 +134,7 @@ func TestEnsureInternalBackendServiceGroups(t *testing.T) {
 				tc.mockModifier(gce.c.(*cloud.MockGCE))
 			}
 			newIGLinks := []string{"new-test-ig-1", "new-test-ig-2"}
-			err = gce.ensureInternalBackendServiceGroups(bsName, newIGLinks)
+			err = gce.ensureInternalBackendServiceGroups(bsName, newIGLinks, nil)
 			if tc.mockModifier != nil {
 				assert.Error(t, err)
 				return
@@ -199,6 +201,88 @@ func TestEnsureMultipleInstanceGroups(t *testing.T) {
 	}
 }
 
+func TestUpdateInternalLoadBalancerZoneFailure(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	nodes, err := createAndInsertNodes(gce, []string{"n1"}, vals.ZoneName)
+	require.NoError(t, err)
+	secondaryNodes, err := createAndInsertNodes(gce, []string{"n2"}, vals.SecondaryZoneName)
+	require.NoError(t, err)
+	svc := fakeLoadbalancerService(string(LBTypeInternal))
+	svc, err = gce.client.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = gce.ensureInternalLoadBalancer(vals.ClusterName, vals.ClusterID, svc, nil, append(nodes, secondaryNodes...))
+	require.NoError(t, err)
+
+	c := gce.c.(*cloud.MockGCE)
+	// The hook is called from the goroutines of the zones, so it delegates to
+	// the serialized hook of fakeGCECloud.
+	addInstances := c.MockInstanceGroups.AddInstancesHook
+	c.MockInstanceGroups.AddInstancesHook = func(ctx context.Context, key *meta.Key, req *compute.InstanceGroupsAddInstancesRequest, m *cloud.MockInstanceGroups) error {
+		if key.Zone == vals.SecondaryZoneName {
+			return &googleapi.Error{Code: http.StatusServiceUnavailable}
+		}
+		return addInstances(ctx, key, req, m)
+	}
+	newNodes, err := createAndInsertNodes(gce, []string{"n3"}, vals.ZoneName)
+	require.NoError(t, err)
+	newSecondaryNodes, err := createAndInsertNodes(gce, []string{"n4"}, vals.SecondaryZoneName)
+	require.NoError(t, err)
+	err = gce.updateInternalLoadBalancer(vals.ClusterName, vals.ClusterID, svc, append(append(nodes, newNodes...), append(secondaryNodes, newSecondaryNodes...)...))
+	require.IsType(t, zoneErrors{}, err)
+
+	// The backend of the failed zone is kept.
+	lbName := gce.GetLoadBalancerName(context.TODO(), "", svc)
+	bs, err := gce.GetRegionBackendService(makeBackendServiceName(lbName, vals.ClusterID, shareBackendService(svc), cloud.SchemeInternal, "TCP", svc.Spec.SessionAffinity), gce.region)
+	require.NoError(t, err)
+	var zones []string
+	for _, backend := range bs.Backends {
+		zones = append(zones, zoneFromURL(backend.Group))
+	}
+	assert.ElementsMatch(t, []string{vals.ZoneName, vals.SecondaryZoneName}, zones)
+
+	igName := makeInstanceGroupName(vals.ClusterID)
+	instances, err := gce.ListInstancesInInstanceGroup(igName, vals.ZoneName, allInstances)
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+}
+
+func TestEnsureInternalInstanceGroupsZoneFailure(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	c := gce.c.(*cloud.MockGCE)
+	c.MockInstanceGroups.InsertHook = func(ctx context.Context, key *meta.Key, obj *compute.InstanceGroup, m *cloud.MockInstanceGroups) (bool, error) {
+		if key.Zone == vals.SecondaryZoneName {
+			return true, &googleapi.Error{Code: http.StatusServiceUnavailable}
+		}
+		return false, nil
+	}
+
+	nodes, err := createAndInsertNodes(gce, []string{"n1"}, vals.ZoneName)
+	require.NoError(t, err)
+	secondaryNodes, err := createAndInsertNodes(gce, []string{"n2"}, vals.SecondaryZoneName)
+	require.NoError(t, err)
+
+	igName := makeInstanceGroupName(vals.ClusterID)
+	igLinks, err := gce.ensureInternalInstanceGroups(igName, append(nodes, secondaryNodes...))
+	require.IsType(t, zoneErrors{}, err)
+	assert.Contains(t, err.Error(), vals.SecondaryZoneName)
+	require.Len(t, igLinks, 1)
+	assert.Equal(t, vals.ZoneName, zoneFromURL(igLinks[0]))
+
+	// The node of the healthy zone joined its instance group regardless.
+	instances, err := gce.ListInstancesInInstanceGroup(igName, vals.ZoneName, allInstances)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.True(t, strings.HasSuffix(instances[0].Instance, "/n1"))
+}
+
 func TestEnsureInternalLoadBalancer(t *testing.T) {
 	t.Parallel()
 
@@ -293,7 +377,7 @@ func TestEnsureInternalLoadBalancerWithExistingResources(t *testing.T) {
 	sharedBackend := shareBackendService(svc)
 	bsDescription := makeBackendServiceDescription(nm, sharedBackend)
 	bsName := makeBackendServiceName(lbName, vals.ClusterID, sharedBackend, cloud.SchemeInternal, "TCP", svc.Spec.SessionAffinity)
-	err = gce.ensureInternalBackendService(bsName, bsDescription, svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, existingHC.SelfLink)
+	err = gce.ensureInternalBackendService(bsName, bsDescription, svc.Spec.SessionAffinity, cloud.SchemeInternal, "TCP", igLinks, nil, existingHC.SelfLink)
 	require.NoError(t, err)
 
 	_, err = createInternalLoadBalancer(gce, svc, nil, nodeNames, vals.ClusterName, vals.ClusterID, vals.ZoneName)
@@ -553,6 +637,64 @@ func TestEnsureInternalLoadBalancerDeleted(t *testing.T) {
 	assertInternalLbResourcesDeleted(t, gce, svc, vals, true)
 }
 
+func TestEnsureInternalLoadBalancerStaticIP(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+
+	recorder := record.NewFakeRecorder(1024)
+	gce.eventRecorder = recorder
+
+	staticIP := "10.0.0.100"
+	svc := fakeLoadbalancerService(string(LBTypeInternal))
+	svc.Annotations[ServiceAnnotationILBStaticIP] = staticIP
+	svc, err = gce.client.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	status, err := createInternalLoadBalancer(gce, svc, nil, []string{"test-node-1"}, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	require.NoError(t, err)
+	assert.Equal(t, staticIP, status.Ingress[0].IP)
+
+	// The address stays reserved after the load balancer is created.
+	lbName := gce.GetLoadBalancerName(context.TODO(), "", svc)
+	addr, err := gce.GetRegionAddress(lbName, gce.region)
+	require.NoError(t, err)
+	assert.Equal(t, staticIP, addr.Address)
+
+	// Another service cannot pin the same IP.
+	other := fakeLoadbalancerService(string(LBTypeInternal))
+	other.Name = "other-service"
+	other.UID = "other-uid"
+	other.Annotations[ServiceAnnotationILBStaticIP] = staticIP
+	other, err = gce.client.CoreV1().Services(other.Namespace).Create(context.TODO(), other, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = createInternalLoadBalancer(gce, other, nil, []string{"test-node-1"}, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	assert.Error(t, err)
+	checkEvent(t, recorder, v1.EventTypeWarning+" StaticIPConflict", true)
+
+	// The address is released with the load balancer.
+	err = gce.ensureInternalLoadBalancerDeleted(vals.ClusterName, vals.ClusterID, svc)
+	require.NoError(t, err)
+	_, err = gce.GetRegionAddress(lbName, gce.region)
+	assert.True(t, isNotFound(err))
+}
+
+func TestEnsureInternalLoadBalancerInvalidStaticIP(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+
+	svc := fakeLoadbalancerService(string(LBTypeInternal))
+	svc.Annotations[ServiceAnnotationILBStaticIP] = "not-an-ip"
+	svc, err = gce.client.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = createInternalLoadBalancer(gce, svc, nil, []string{"test-node-1"}, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	assert.Error(t, err)
+}
+
 func TestSkipInstanceGroupDeletion(t *testing.T) {
 	t.Parallel()
 
@@ -641,7 +783,7 @@ func TestClearPreviousInternalResources(t *testing.T) {
 	hc2, err := gce.ensureInternalHealthCheck("hc2", nm, false, "healthz", 12346)
 	require.NoError(t, err)
 
-	err = gce.ensureInternalBackendService(svc.ObjectMeta.Name, "", svc.Spec.SessionAffinity, cloud.SchemeInternal, v1.ProtocolTCP, []string{}, "")
+	err = gce.ensureInternalBackendService(svc.ObjectMeta.Name, "", svc.Spec.SessionAffinity, cloud.SchemeInternal, v1.ProtocolTCP, []string{}, nil, "")
 	require.NoError(t, err)
 	backendSvc, err := gce.GetRegionBackendService(svc.ObjectMeta.Name, gce.region)
 	require.NoError(t, err)
@@ -21,20 +21,124 @@ package gce
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
-	"path"
+	"strings"
 	"time"
 
 	"google.golang.org/api/compute/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
 )
 
+const (
+	routeFamilyIPv4 = "ipv4"
+	routeFamilyIPv6 = "ipv6"
+
+	// ipv6RouteSuffix ends the names of the IPv6 routes, which share their
+	// name hint, the UID of the node, with the IPv4 routes in dual-stack
+	// clusters.
+	ipv6RouteSuffix = "-v6"
+)
+
+var (
+	routeCount = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "cloudprovider_gce_routes",
+			Help:           "Number of routes of the cluster by IP family, as of the last listing",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"ip_family"},
+	)
+	routeQuotaExceeded = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "cloudprovider_gce_route_quota_exceeded_total",
+			Help:           "Number of routes not created because the routes quota of the project is exhausted, by IP family",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"ip_family"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(routeCount)
+	legacyregistry.MustRegister(routeQuotaExceeded)
+}
+
+// routeFamily returns the IP family of the destination range of a route.
+func routeFamily(destRange string) string {
+	ip, _, err := net.ParseCIDR(destRange)
+	if err == nil && ip.To4() == nil {
+		return routeFamilyIPv6
+	}
+	return routeFamilyIPv4
+}
+
+// routeName returns the name of the route of the node with the name hint to
+// the destination range. The names of the IPv4 routes are kept unchanged for
+// the existing clusters; the IPv6 routes drop the dashes of the UID of the
+// node to fit their suffix in the 63 characters of a route name.
+func routeName(clusterName, nameHint, destRange string) string {
+	if routeFamily(destRange) == routeFamilyIPv6 {
+		return truncateClusterName(clusterName) + "-" + strings.ReplaceAll(nameHint, "-", "") + ipv6RouteSuffix
+	}
+	return truncateClusterName(clusterName) + "-" + nameHint
+}
+
+// routeDescription identifies the cluster and the node owning a route created
+// by the provider. Routes do not carry labels, so it is serialized after
+// k8sNodeRouteTag in the description of the route.
+type routeDescription struct {
+	ClusterName string `json:"kubernetes.io/cluster-name"`
+	ClusterUID  string `json:"kubernetes.io/cluster-uid,omitempty"`
+	NodeName    string `json:"kubernetes.io/node-name"`
+}
+
+func (d routeDescription) marshal() string {
+	data, err := json.Marshal(d)
+	if err != nil {
+		// Not expected for a struct of strings; the route stays recognizable
+		// by its tag.
+		klog.Errorf("Failed to marshal the description of the route of node %s: %v", d.NodeName, err)
+		return k8sNodeRouteTag
+	}
+	return k8sNodeRouteTag + " " + string(data)
+}
+
+// parseRouteDescription returns the owner of a route, and false if the route
+// has no owner, e.g. because it was created before the owners were recorded.
+func parseRouteDescription(description string) (routeDescription, bool) {
+	var d routeDescription
+	data := strings.TrimPrefix(description, k8sNodeRouteTag+" ")
+	if data == description {
+		return d, false
+	}
+	if err := json.Unmarshal([]byte(data), &d); err != nil || d.ClusterName == "" || d.NodeName == "" {
+		return d, false
+	}
+	return d, true
+}
+
+// clusterUID returns the unique ID of the cluster, or an empty string if it
+// is not known.
+func (g *Cloud) clusterUID() string {
+	uid, err := g.ClusterID.GetID()
+	if err != nil {
+		klog.V(4).Infof("Not recording the cluster UID: %v", err)
+		return ""
+	}
+	return uid
+}
+
 func newRoutesMetricContext(request string) *metricContext {
 	return newGenericMetricContext("routes", request, unusedMetricLabel, unusedMetricLabel, computeV1Version)
 }
@@ -46,22 +150,29 @@ func (g *Cloud) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpro
 
 	mc := newRoutesMetricContext("list")
 	prefix := truncateClusterName(clusterName)
-	f := filter.Regexp("name", prefix+"-.*").AndRegexp("network", g.NetworkURL()).AndRegexp("description", k8sNodeRouteTag)
+	f := filter.Regexp("name", prefix+"-.*").AndRegexp("network", g.NetworkURL()).AndRegexp("description", k8sNodeRouteTag+".*")
 	routes, err := g.c.Routes().List(timeoutCtx, f)
 	if err != nil {
 		return nil, mc.Observe(err)
 	}
 	var croutes []*cloudprovider.Route
+	counts := map[string]int{routeFamilyIPv4: 0, routeFamilyIPv6: 0}
 	for _, r := range routes {
-		target := path.Base(r.NextHopInstance)
-		// TODO: Should we lastComponent(target) this?
-		targetNodeName := types.NodeName(target) // NodeName == Instance Name on GCE
+		counts[routeFamily(r.DestRange)]++
+		// Routes without a next hop instance target no node.
+		var targetNodeName types.NodeName
+		if target, err := gcpurl.Parse(r.NextHopInstance); err == nil && target.Kind == gcpurl.KindInstances {
+			targetNodeName = types.NodeName(target.Name) // NodeName == Instance Name on GCE
+		}
 		croutes = append(croutes, &cloudprovider.Route{
 			Name:            r.Name,
 			TargetNode:      targetNodeName,
 			DestinationCIDR: r.DestRange,
 		})
 	}
+	for family, count := range counts {
+		routeCount.WithLabelValues(family).Set(float64(count))
+	}
 	return croutes, mc.Observe(nil)
 }
 
@@ -76,20 +187,25 @@ func (g *Cloud) CreateRoute(ctx context.Context, clusterName string, nameHint st
 	if err != nil {
 		return mc.Observe(err)
 	}
+	family := routeFamily(route.DestinationCIDR)
 	cr := &compute.Route{
 		// TODO(thockin): generate a unique name for node + route cidr. Don't depend on name hints.
-		Name:            truncateClusterName(clusterName) + "-" + nameHint,
+		Name:            routeName(clusterName, nameHint, route.DestinationCIDR),
 		DestRange:       route.DestinationCIDR,
 		NextHopInstance: fmt.Sprintf("zones/%s/instances/%s", targetInstance.Zone, targetInstance.Name),
 		Network:         g.NetworkURL(),
 		Priority:        1000,
-		Description:     k8sNodeRouteTag,
+		Description:     routeDescription{ClusterName: clusterName, ClusterUID: g.clusterUID(), NodeName: string(route.TargetNode)}.marshal(),
 	}
 	err = g.c.Routes().Insert(timeoutCtx, meta.GlobalKey(cr.Name), cr)
 	if isHTTPErrorCode(err, http.StatusConflict) {
 		klog.Infof("Route %q already exists.", cr.Name)
 		err = nil
 	}
+	if throttleReasonOfError(err) == quotaExceededReason {
+		routeQuotaExceeded.WithLabelValues(family).Inc()
+		klog.Warningf("Failed to create %s route %q to %s: the routes quota of the project is exhausted", family, cr.Name, cr.DestRange)
+	}
 	return mc.Observe(err)
 }
 
@@ -99,9 +215,52 @@ func (g *Cloud) DeleteRoute(ctx context.Context, clusterName string, route *clou
 	defer cancel()
 
 	mc := newRoutesMetricContext("delete")
+	// Routes are deleted by name: the IPv4 and IPv6 routes of a node are
+	// distinct routes, and deleting one leaves the other in place.
+	klog.V(2).Infof("Deleting %s route %q to %s", routeFamily(route.DestinationCIDR), route.Name, route.DestinationCIDR)
 	return mc.Observe(g.c.Routes().Delete(timeoutCtx, meta.GlobalKey(route.Name)))
 }
 
+// DeleteOrphanedRoutes deletes the routes created by the provider for the
+// cluster whose node no longer exists, and returns their names. Routes created
+// before their owner was recorded in their description, or owned by another
+// cluster with the same name, are left untouched.
+func (g *Cloud) DeleteOrphanedRoutes(ctx context.Context, clusterName string, nodeExists func(nodeName types.NodeName) (bool, error)) ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 1*time.Hour)
+	defer cancel()
+
+	mc := newRoutesMetricContext("list")
+	f := filter.Regexp("network", g.NetworkURL()).AndRegexp("description", k8sNodeRouteTag+" .*")
+	routes, err := g.c.Routes().List(timeoutCtx, f)
+	if err = mc.Observe(err); err != nil {
+		return nil, err
+	}
+	uid := g.clusterUID()
+	var deleted []string
+	for _, r := range routes {
+		owner, ok := parseRouteDescription(r.Description)
+		if !ok || owner.ClusterName != clusterName {
+			continue
+		}
+		if uid != "" && owner.ClusterUID != "" && owner.ClusterUID != uid {
+			continue
+		}
+		exists, err := nodeExists(types.NodeName(owner.NodeName))
+		if err != nil {
+			return deleted, err
+		}
+		if exists {
+			continue
+		}
+		klog.Infof("Deleting route %s of deleted node %s", r.Name, owner.NodeName)
+		if err := g.DeleteRoute(timeoutCtx, clusterName, &cloudprovider.Route{Name: r.Name}); err != nil && !isNotFound(err) {
+			return deleted, err
+		}
+		deleted = append(deleted, r.Name)
+	}
+	return deleted, nil
+}
+
 func truncateClusterName(clusterName string) string {
 	if len(clusterName) > 26 {
 		return clusterName[:26]
@@ -0,0 +1,165 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ga "google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+func TestParseRouteDescription(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		description string
+		want        routeDescription
+		wantOK      bool
+	}{
+		{
+			desc:        "owned route",
+			description: routeDescription{ClusterName: "cluster", ClusterUID: "uid", NodeName: "node-1"}.marshal(),
+			want:        routeDescription{ClusterName: "cluster", ClusterUID: "uid", NodeName: "node-1"},
+			wantOK:      true,
+		},
+		{
+			desc:        "route created before the owners were recorded",
+			description: k8sNodeRouteTag,
+		},
+		{
+			desc:        "invalid owner",
+			description: k8sNodeRouteTag + " {",
+		},
+		{
+			desc:        "owner without node",
+			description: k8sNodeRouteTag + ` {"kubernetes.io/cluster-name":"cluster"}`,
+			want:        routeDescription{ClusterName: "cluster"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := parseRouteDescription(tc.description)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDeleteOrphanedRoutes(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	ctx := context.Background()
+	const clusterName = "test-cluster"
+
+	_, err = createAndInsertNodes(gce, []string{"node-1", "node-2"}, vals.ZoneName)
+	require.NoError(t, err)
+	for _, route := range []*cloudprovider.Route{
+		{TargetNode: "node-1", DestinationCIDR: "10.0.1.0/24"},
+		{TargetNode: "node-2", DestinationCIDR: "10.0.2.0/24"},
+	} {
+		require.NoError(t, gce.CreateRoute(ctx, clusterName, string(route.TargetNode), route))
+	}
+	// Routes of deleted nodes the cluster does not own.
+	for _, r := range []*ga.Route{
+		{Name: clusterName + "-legacy", Network: gce.NetworkURL(), Description: k8sNodeRouteTag},
+		{Name: clusterName + "-other-uid", Network: gce.NetworkURL(), Description: routeDescription{ClusterName: clusterName, ClusterUID: "other-uid", NodeName: "node-3"}.marshal()},
+		{Name: "other-cluster-node-3", Network: gce.NetworkURL(), Description: routeDescription{ClusterName: "other-cluster", NodeName: "node-3"}.marshal()},
+	} {
+		require.NoError(t, gce.c.Routes().Insert(ctx, meta.GlobalKey(r.Name), r))
+	}
+
+	routes, err := gce.ListRoutes(ctx, clusterName)
+	require.NoError(t, err)
+	var names []string
+	for _, r := range routes {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{clusterName + "-legacy", clusterName + "-node-1", clusterName + "-node-2", clusterName + "-other-uid"}, names)
+
+	nodeExists := func(nodeName types.NodeName) (bool, error) {
+		return nodeName == "node-1", nil
+	}
+	deleted, err := gce.DeleteOrphanedRoutes(ctx, clusterName, nodeExists)
+	require.NoError(t, err)
+	assert.Equal(t, []string{clusterName + "-node-2"}, deleted)
+
+	remaining, err := gce.c.Routes().List(ctx, nil)
+	require.NoError(t, err)
+	names = nil
+	for _, r := range remaining {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"other-cluster-node-3", clusterName + "-legacy", clusterName + "-node-1", clusterName + "-other-uid"}, names)
+}
+
+func TestDualStackRoutes(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	ctx := context.Background()
+	const (
+		clusterName = "test-cluster"
+		nodeUID     = "6f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+	)
+
+	_, err = createAndInsertNodes(gce, []string{"node-1"}, vals.ZoneName)
+	require.NoError(t, err)
+	for _, cidr := range []string{"10.0.1.0/24", "fd00:10:1::/64"} {
+		route := &cloudprovider.Route{TargetNode: "node-1", DestinationCIDR: cidr}
+		require.NoError(t, gce.CreateRoute(ctx, clusterName, nodeUID, route))
+	}
+
+	ipv4Name := clusterName + "-" + nodeUID
+	ipv6Name := clusterName + "-6f1e2d3c4b5a69788796a5b4c3d2e1f0-v6"
+	routes, err := gce.ListRoutes(ctx, clusterName)
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, r := range routes {
+		assert.Equal(t, types.NodeName("node-1"), r.TargetNode)
+		got[r.Name] = r.DestinationCIDR
+	}
+	assert.Equal(t, map[string]string{ipv4Name: "10.0.1.0/24", ipv6Name: "fd00:10:1::/64"}, got)
+
+	// Deleting the IPv6 route leaves the IPv4 route of the node in place.
+	require.NoError(t, gce.DeleteRoute(ctx, clusterName, &cloudprovider.Route{Name: ipv6Name, TargetNode: "node-1", DestinationCIDR: "fd00:10:1::/64"}))
+	routes, err = gce.ListRoutes(ctx, clusterName)
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.Equal(t, ipv4Name, routes[0].Name)
+}
+
+func TestRouteName(t *testing.T) {
+	const nodeUID = "6f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+	longClusterName := "a-very-long-cluster-name-exceeding-the-limit"
+	for _, cidr := range []string{"10.0.1.0/24", "fd00:10:1::/64"} {
+		name := routeName(longClusterName, nodeUID, cidr)
+		assert.LessOrEqual(t, len(name), 63, "name of the route to %s", cidr)
+	}
+	assert.NotEqual(t, routeName("cluster", nodeUID, "10.0.1.0/24"), routeName("cluster", nodeUID, "fd00:10:1::/64"))
+}
@@ -25,8 +25,6 @@ import (
 	"strings"
 	"testing"
 
-	"golang.org/x/oauth2/google"
-
 	cloudprovider "k8s.io/cloud-provider"
 )
 
@@ -352,7 +350,7 @@ func TestGenerateCloudConfigs(t *testing.T) {
 		SubnetworkURL:      "",
 		SecondaryRangeName: "",
 		NodeTags:           []string{"node-tag"},
-		TokenSource:        google.ComputeTokenSource(""),
+		TokenSource:        MetadataTokenSource(),
 		NodeInstancePrefix: "node-prefix",
 		UseMetadataServer:  true,
 		AlphaFeatureGate:   &AlphaFeatureGate{map[string]bool{}},
@@ -552,6 +550,10 @@ func TestGetRegionInURL(t *testing.T) {
 		"regions/europe-north2": "europe-north2",
 		"my-url":                "",
 		"":                      "",
+		// Malformed URLs.
+		"projects/my-project/regions/us-central1/subnetworks":                              "",
+		"https://www.googleapis.com/compute/v1/projects/regions/us-central1/subnetworks/a": "",
+		"https://www.googleapis.com/compute":                                               "",
 	}
 	for input, output := range cases {
 		result := getRegionInURL(input)
@@ -588,16 +590,20 @@ func TestFindSubnetForRegion(t *testing.T) {
 	}
 }
 
-func TestLastComponent(t *testing.T) {
+func TestResourceName(t *testing.T) {
 	cases := map[string]string{
 		"https://www.googleapis.com/compute/v1/projects/my-project/regions/us-central1/subnetworks/a": "a",
 		"https://www.googleapis.com/compute/v1/projects/my-project/regions/us-central1/subnetworks/b": "b",
 		"projects/my-project/regions/us-central1/subnetworks/c":                                       "c",
 		"d": "d",
 		"":  "",
+		// Malformed URLs.
+		"projects/my-project/regions/us-central1/subnetworks":                               "",
+		"https://www.googleapis.com/compute/v1/projects/my-project/global/backendServices/": "",
+		"https://www.googleapis.com/compute":                                                "",
 	}
 	for input, output := range cases {
-		result := lastComponent(input)
+		result := resourceName(input)
 		if result != output {
 			t.Errorf("Actual result %q does not match expected result %q for input: %q", result, output, input)
 		}
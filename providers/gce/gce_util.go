@@ -32,6 +32,7 @@ import (
 
 	"cloud.google.com/go/compute/metadata"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
 	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/mock"
 	compute "google.golang.org/api/compute/v1"
@@ -42,6 +43,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/fake"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
 	servicehelper "k8s.io/cloud-provider/service/helpers"
 	netutils "k8s.io/utils/net"
 )
@@ -71,9 +73,10 @@ func fakeGCECloud(vals TestClusterValues) (*Cloud, error) {
 		InstanceMap: make(map[meta.Key]map[string]*compute.InstanceWithNamedPorts),
 		Lock:        &sync.Mutex{},
 	}
-	mockGCE.MockInstanceGroups.AddInstancesHook = mock.AddInstancesHook
-	mockGCE.MockInstanceGroups.RemoveInstancesHook = mock.RemoveInstancesHook
-	mockGCE.MockInstanceGroups.ListInstancesHook = mock.ListInstancesHook
+	hooks := &instanceGroupHooks{}
+	mockGCE.MockInstanceGroups.AddInstancesHook = hooks.addInstances
+	mockGCE.MockInstanceGroups.RemoveInstancesHook = hooks.removeInstances
+	mockGCE.MockInstanceGroups.ListInstancesHook = hooks.listInstances
 
 	mockGCE.MockRegionBackendServices.UpdateHook = mock.UpdateRegionBackendServiceHook
 	mockGCE.MockHealthChecks.UpdateHook = mock.UpdateHealthCheckHook
@@ -88,6 +91,31 @@ func fakeGCECloud(vals TestClusterValues) (*Cloud, error) {
 	return gce, nil
 }
 
+// instanceGroupHooks serializes the instance group hooks of the mock, which
+// read and write the attributes of the mock without locking them, as the
+// instance groups of the zones are updated in parallel, see forEachZone.
+type instanceGroupHooks struct {
+	lock sync.Mutex
+}
+
+func (h *instanceGroupHooks) addInstances(ctx context.Context, key *meta.Key, req *compute.InstanceGroupsAddInstancesRequest, m *cloud.MockInstanceGroups) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return mock.AddInstancesHook(ctx, key, req, m)
+}
+
+func (h *instanceGroupHooks) removeInstances(ctx context.Context, key *meta.Key, req *compute.InstanceGroupsRemoveInstancesRequest, m *cloud.MockInstanceGroups) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return mock.RemoveInstancesHook(ctx, key, req, m)
+}
+
+func (h *instanceGroupHooks) listInstances(ctx context.Context, key *meta.Key, req *compute.InstanceGroupsListInstancesRequest, fl *filter.F, m *cloud.MockInstanceGroups) ([]*compute.InstanceWithNamedPorts, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return mock.ListInstancesHook(ctx, key, req, fl, m)
+}
+
 func registerTargetPoolAddInstanceHook(gce *Cloud, callback func(*compute.TargetPoolsAddInstanceRequest)) error {
 	mockGCE, ok := gce.c.(*cloud.MockGCE)
 	if !ok {
@@ -138,11 +166,10 @@ func getProjectAndZone() (string, string, error) {
 	if err != nil {
 		return "", "", err
 	}
-	parts := strings.Split(result, "/")
-	if len(parts) != 4 {
-		return "", "", fmt.Errorf("unexpected response: %s", result)
+	zone, err := metadataResourceName(result, gcpurl.KindZones)
+	if err != nil {
+		return "", "", err
 	}
-	zone := parts[3]
 	projectID, err := metadata.ProjectID()
 	if err != nil {
 		return "", "", err
@@ -160,7 +187,7 @@ func (g *Cloud) raiseFirewallChangeNeededEvent(svc *v1.Service, cmd string) {
 // FirewallToGCloudCreateCmd generates a gcloud command to create a firewall with specified params
 func FirewallToGCloudCreateCmd(fw *compute.Firewall, projectID string) string {
 	args := firewallToGcloudArgs(fw, projectID)
-	return fmt.Sprintf("gcloud compute firewall-rules create %v --network %v %v", fw.Name, getNameFromLink(fw.Network), args)
+	return fmt.Sprintf("gcloud compute firewall-rules create %v --network %v %v", fw.Name, resourceName(fw.Network), args)
 }
 
 // FirewallToGCloudUpdateCmd generates a gcloud command to update a firewall to specified params
@@ -203,14 +230,25 @@ func canonicalizeInstanceName(name string) string {
 	return name
 }
 
-// Returns the last component of a URL, i.e. anything after the last slash
-// If there is no slash, returns the whole string
-func lastComponent(s string) string {
-	lastSlash := strings.LastIndex(s, "/")
-	if lastSlash != -1 {
-		s = s[lastSlash+1:]
+// resourceName returns the name of the compute resource referenced by a URL,
+// a relative path or a bare name, or "" if the reference is invalid.
+func resourceName(ref string) string {
+	id, err := gcpurl.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return id.Name
+}
+
+// metadataResourceName returns the name of the resource of the given kind
+// referenced by a response of the metadata server, e.g.
+// projects/123456/zones/us-central1-b.
+func metadataResourceName(result, kind string) (string, error) {
+	id, err := gcpurl.Parse(result)
+	if err != nil || id.Kind != kind {
+		return "", fmt.Errorf("unexpected response: %s", result)
 	}
-	return s
+	return id.Name, nil
 }
 
 // mapNodeNameToInstanceName maps a k8s NodeName to a GCE Instance Name
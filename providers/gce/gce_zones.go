@@ -22,16 +22,89 @@ package gce
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	compute "google.golang.org/api/compute/v1"
 
-	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
-	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
 	"k8s.io/apimachinery/pkg/types"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-gcp/providers/gce/gcpurl"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
 )
 
+var zonalOperationErrors = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Name:           "cloudprovider_gce_zonal_operation_errors_total",
+		Help:           "Number of failures of the per-zone steps of operations fanned out over the zones, by operation and zone",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"operation", "zone"},
+)
+
+func init() {
+	legacyregistry.MustRegister(zonalOperationErrors)
+}
+
+// zoneErrors holds the errors of the zones in which an operation fanned out by
+// forEachZone failed.
+type zoneErrors map[string]error
+
+func (e zoneErrors) Error() string {
+	zones := make([]string, 0, len(e))
+	for zone := range e {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	msgs := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		msgs = append(msgs, fmt.Sprintf("zone %s: %v", zone, e[zone]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// forEachZone calls fn for all the zones in parallel, so that a zone whose API
+// calls fail or hang does not hold back the others. It returns the errors by
+// zone, or nil if fn succeeded in all the zones.
+func forEachZone(operation string, zones []string, fn func(zone string) error) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = zoneErrors{}
+	)
+	for _, zone := range zones {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+			if err := fn(zone); err != nil {
+				klog.Errorf("%s failed in zone %s: %v", operation, zone, err)
+				zonalOperationErrors.WithLabelValues(operation, zone).Inc()
+				mu.Lock()
+				errs[zone] = err
+				mu.Unlock()
+			}
+		}(zone)
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// zoneFromURL returns the zone of the URL of a zonal resource, or "" if it
+// has none.
+func zoneFromURL(url string) string {
+	id, err := gcpurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return id.Zone
+}
+
 func newZonesMetricContext(request, region string) *metricContext {
 	return newGenericMetricContext("zones", request, region, unusedMetricLabel, computeV1Version)
 }
@@ -75,19 +148,10 @@ func (g *Cloud) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName)
 	return cloudprovider.Zone{FailureDomain: instance.Zone, Region: region}, nil
 }
 
-// ListZonesInRegion returns all zones in a GCP region
+// ListZonesInRegion returns all zones in a GCP region. The zones are cached
+// and refreshed periodically once the Cloud is initialized.
 func (g *Cloud) ListZonesInRegion(region string) ([]*compute.Zone, error) {
-	ctx, cancel := cloud.ContextWithCallTimeout()
-	defer cancel()
-
-	mc := newZonesMetricContext("list", region)
-	// Use regex match instead of an exact regional link constructed from getRegionalLink below.
-	// See comments in issue kubernetes/kubernetes#87905
-	list, err := g.c.Zones().List(ctx, filter.Regexp("region", fmt.Sprintf(".*/regions/%s", region)))
-	if err != nil {
-		return nil, mc.Observe(err)
-	}
-	return list, mc.Observe(err)
+	return g.cachedZonesInRegion(region)
 }
 
 func (g *Cloud) getRegionLink(region string) string {
@@ -0,0 +1,165 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// zonesRefreshPeriod is how often the zones of the project are listed again.
+const zonesRefreshPeriod = 10 * time.Minute
+
+// ZonesChangeHandler is called with the zones added to and removed from a
+// region when the zones are refreshed.
+type ZonesChangeHandler func(region string, added, removed []string)
+
+// zonesCache holds the zones of the project per region, shared by all the
+// callers of the Cloud so that they do not list the zones on every operation.
+type zonesCache struct {
+	lock sync.Mutex
+	// zones maps the regions to their zones, sorted by name. It is nil until
+	// the zones are first listed.
+	zones    map[string][]*compute.Zone
+	handlers []ZonesChangeHandler
+}
+
+// AddZonesChangeHandler registers a handler called when zones are added to or
+// removed from a region. Handlers are not called for the first listing.
+func (g *Cloud) AddZonesChangeHandler(handler ZonesChangeHandler) {
+	g.zonesCache.lock.Lock()
+	defer g.zonesCache.lock.Unlock()
+	g.zonesCache.handlers = append(g.zonesCache.handlers, handler)
+}
+
+// cachedZonesInRegion returns the zones of the region, listing the zones of
+// the project if they are not cached yet.
+func (g *Cloud) cachedZonesInRegion(region string) ([]*compute.Zone, error) {
+	g.zonesCache.lock.Lock()
+	zones := g.zonesCache.zones
+	g.zonesCache.lock.Unlock()
+	if zones == nil {
+		if err := g.refreshZones(); err != nil {
+			return nil, err
+		}
+		g.zonesCache.lock.Lock()
+		zones = g.zonesCache.zones
+		g.zonesCache.lock.Unlock()
+	}
+	return append([]*compute.Zone(nil), zones[region]...), nil
+}
+
+// refreshZones lists the zones of the project, updates the cache and calls the
+// change handlers for the regions whose zones changed. The managed zones of
+// clusters managing all the zones of their region are updated as well.
+func (g *Cloud) refreshZones() error {
+	ctx, cancel := cloud.ContextWithCallTimeout()
+	defer cancel()
+
+	mc := newZonesMetricContext("list", unusedMetricLabel)
+	list, err := g.c.Zones().List(ctx, filter.None)
+	if err = mc.Observe(err); err != nil {
+		return err
+	}
+	zones := make(map[string][]*compute.Zone)
+	for _, zone := range list {
+		region := resourceName(zone.Region)
+		zones[region] = append(zones[region], zone)
+	}
+	for _, regionZones := range zones {
+		sort.Slice(regionZones, func(i, j int) bool { return regionZones[i].Name < regionZones[j].Name })
+	}
+
+	g.zonesCache.lock.Lock()
+	old := g.zonesCache.zones
+	g.zonesCache.zones = zones
+	handlers := g.zonesCache.handlers
+	g.zonesCache.lock.Unlock()
+
+	if g.allZonesManaged {
+		if regionZones := zoneNames(zones[g.region]); len(regionZones) > 0 {
+			g.setManagedZones(regionZones)
+		}
+	}
+	if old == nil {
+		return nil
+	}
+	regions := sets.NewString()
+	for region := range old {
+		regions.Insert(region)
+	}
+	for region := range zones {
+		regions.Insert(region)
+	}
+	for _, region := range regions.List() {
+		oldZones, newZones := sets.NewString(zoneNames(old[region])...), sets.NewString(zoneNames(zones[region])...)
+		added, removed := newZones.Difference(oldZones).List(), oldZones.Difference(newZones).List()
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		klog.Infof("Zones of region %s changed, added: %v, removed: %v", region, added, removed)
+		for _, handler := range handlers {
+			handler(region, added, removed)
+		}
+	}
+	return nil
+}
+
+// runZonesRefresh refreshes the zones every zonesRefreshPeriod until stop is closed.
+func (g *Cloud) runZonesRefresh(stop <-chan struct{}) {
+	wait.Until(func() {
+		if err := g.refreshZones(); err != nil {
+			klog.Warningf("Failed to refresh the zones of project %s: %v", g.projectID, err)
+		}
+	}, zonesRefreshPeriod, stop)
+}
+
+// getManagedZones returns the zones managed by the cluster.
+func (g *Cloud) getManagedZones() []string {
+	g.managedZonesLock.RLock()
+	defer g.managedZonesLock.RUnlock()
+	return g.managedZones
+}
+
+// setManagedZones replaces the zones managed by the cluster.
+func (g *Cloud) setManagedZones(zones []string) {
+	g.managedZonesLock.Lock()
+	defer g.managedZonesLock.Unlock()
+	if !sets.NewString(g.managedZones...).Equal(sets.NewString(zones...)) {
+		klog.Infof("Managing zones %v", zones)
+	}
+	g.managedZones = zones
+}
+
+func zoneNames(zones []*compute.Zone) []string {
+	names := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		names = append(names, zone.Name)
+	}
+	return names
+}
@@ -0,0 +1,88 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/filter"
+	"github.com/GoogleCloudPlatform/k8s-cloud-provider/pkg/cloud/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ga "google.golang.org/api/compute/v1"
+)
+
+func insertZone(c *cloud.MockGCE, region, zone string) {
+	c.MockZones.Objects[*meta.GlobalKey(zone)] = &cloud.MockZonesObj{Obj: &ga.Zone{
+		Name:   zone,
+		Region: "https://www.googleapis.com/compute/v1/projects/test-project/regions/" + region,
+	}}
+}
+
+func TestZonesCache(t *testing.T) {
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+	gce.allZonesManaged = true
+	// fakeGCECloud creates the zone of the cluster.
+	c := gce.c.(*cloud.MockGCE)
+	insertZone(c, "europe-west1", "europe-west1-b")
+	lists := 0
+	c.MockZones.ListHook = func(ctx context.Context, fl *filter.F, m *cloud.MockZones) (bool, []*ga.Zone, error) {
+		lists++
+		return false, nil, nil
+	}
+	type change struct {
+		region         string
+		added, removed []string
+	}
+	var changes []change
+	gce.AddZonesChangeHandler(func(region string, added, removed []string) {
+		changes = append(changes, change{region: region, added: added, removed: removed})
+	})
+
+	for i := 0; i < 2; i++ {
+		zones, err := gce.ListZonesInRegion(vals.Region)
+		require.NoError(t, err)
+		assert.Equal(t, []string{vals.ZoneName}, zoneNames(zones))
+	}
+	assert.Equal(t, 1, lists, "zones should be listed once")
+	assert.Empty(t, changes, "the first listing should not call the change handlers")
+	assert.Equal(t, []string{vals.ZoneName}, gce.getManagedZones())
+
+	insertZone(c, vals.Region, vals.SecondaryZoneName)
+	delete(c.MockZones.Objects, *meta.GlobalKey("europe-west1-b"))
+	require.NoError(t, gce.refreshZones())
+	zones, err := gce.ListZonesInRegion(vals.Region)
+	require.NoError(t, err)
+	assert.Equal(t, []string{vals.ZoneName, vals.SecondaryZoneName}, zoneNames(zones))
+	assert.Equal(t, []change{
+		{region: "europe-west1", added: []string{}, removed: []string{"europe-west1-b"}},
+		{region: vals.Region, added: []string{vals.SecondaryZoneName}, removed: []string{}},
+	}, changes)
+	assert.Equal(t, []string{vals.ZoneName, vals.SecondaryZoneName}, gce.getManagedZones())
+
+	gce.allZonesManaged = false
+	insertZone(c, vals.Region, "us-central1-f")
+	require.NoError(t, gce.refreshZones())
+	assert.Equal(t, []string{vals.ZoneName, vals.SecondaryZoneName}, gce.getManagedZones(), "managed zones should only follow the region if all zones are managed")
+}
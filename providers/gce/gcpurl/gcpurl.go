@@ -0,0 +1,215 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gcpurl parses, normalizes and compares the URLs of compute
+// resources such as networks, subnetworks and instances.
+//
+// The compute API and the users of our CRDs refer to the same resource in
+// several forms:
+//
+//	https://www.googleapis.com/compute/v1/projects/p/regions/r/subnetworks/s
+//	projects/p/regions/r/subnetworks/s
+//	s
+//
+// Parse accepts all of them and Matches compares two references taking the
+// project and location into account, so that resources with the same name in
+// different projects or regions are not mistaken for each other.
+package gcpurl
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// KindNetworks is the collection name of VPC networks.
+	KindNetworks = "networks"
+	// KindSubnetworks is the collection name of subnetworks.
+	KindSubnetworks = "subnetworks"
+	// KindInstances is the collection name of VM instances.
+	KindInstances = "instances"
+	// KindZones is the collection name of zones.
+	KindZones = "zones"
+	// KindRegions is the collection name of regions.
+	KindRegions = "regions"
+	// KindSecondaryRanges is the collection name of the secondary ranges in
+	// the full path of a secondary range, e.g.
+	// projects/p/regions/r/subnetworks/s/secondaryIpRanges/pods.
+	KindSecondaryRanges = "secondaryIpRanges"
+)
+
+// computeHostPrefixes are the prefixes stripped from fully qualified resource URLs.
+var computeHostPrefixes = []string{
+	"https://www.googleapis.com/compute/",
+	"https://compute.googleapis.com/compute/",
+	"http://www.googleapis.com/compute/",
+	"www.googleapis.com/compute/",
+	"compute.googleapis.com/compute/",
+}
+
+// ResourceID identifies a compute resource. Fields that are not known from
+// the parsed reference are left empty.
+type ResourceID struct {
+	// Project is the project that owns the resource.
+	Project string
+	// Region is set for regional resources such as subnetworks.
+	Region string
+	// Zone is set for zonal resources such as instances.
+	Zone string
+	// Kind is the collection of the resource, e.g. "subnetworks".
+	Kind string
+	// Name is the name of the resource.
+	Name string
+}
+
+// Parse parses a fully qualified URL, a relative "projects/..." path, a
+// partial path starting with the location of the resource, e.g.
+// "zones/z/instances/i", or a bare resource name.
+func Parse(ref string) (*ResourceID, error) {
+	s := strings.TrimSpace(ref)
+	if s == "" {
+		return nil, fmt.Errorf("empty resource reference")
+	}
+	for _, prefix := range computeHostPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			s = strings.TrimPrefix(s, prefix)
+			// Drop the API version (v1, beta, alpha).
+			if i := strings.Index(s, "/"); i >= 0 {
+				s = s[i+1:]
+			} else {
+				return nil, fmt.Errorf("invalid resource URL %q", ref)
+			}
+			break
+		}
+	}
+	s = strings.Trim(s, "/")
+	parts := strings.Split(s, "/")
+	if len(parts) == 1 {
+		return &ResourceID{Name: parts[0]}, nil
+	}
+	id := &ResourceID{}
+	rest := parts
+	switch {
+	case parts[0] == "projects":
+		if len(parts) < 4 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid resource reference %q", ref)
+		}
+		id.Project, rest = parts[1], parts[2:]
+	case parts[0] != "global" && parts[0] != KindRegions && parts[0] != KindZones:
+		// Paths without a project start with the location of the resource.
+		return nil, fmt.Errorf("invalid resource reference %q", ref)
+	}
+	switch {
+	case rest[0] == "global":
+		rest = rest[1:]
+	case rest[0] == KindRegions && len(rest) > 2:
+		id.Region, rest = rest[1], rest[2:]
+	case rest[0] == KindZones && len(rest) > 2:
+		id.Zone, rest = rest[1], rest[2:]
+	}
+	if len(rest) != 2 || rest[0] == "" || rest[1] == "" {
+		return nil, fmt.Errorf("invalid resource reference %q", ref)
+	}
+	id.Kind, id.Name = rest[0], rest[1]
+	return id, nil
+}
+
+// ParseSecondaryRange parses a secondary range name or the full path of a
+// secondary range: the URL or relative path of its subnetwork followed by
+// "/secondaryIpRanges/<name>". It returns the subnetwork of a full path, nil
+// for a bare name, and the name of the range.
+func ParseSecondaryRange(ref string) (*ResourceID, string, error) {
+	s := strings.Trim(strings.TrimSpace(ref), "/")
+	i := strings.LastIndex(s, "/"+KindSecondaryRanges+"/")
+	if i < 0 {
+		if s == "" || strings.Contains(s, "/") {
+			return nil, "", fmt.Errorf("invalid secondary range reference %q", ref)
+		}
+		return nil, s, nil
+	}
+	name := s[i+len(KindSecondaryRanges)+2:]
+	if name == "" || strings.Contains(name, "/") {
+		return nil, "", fmt.Errorf("invalid secondary range reference %q", ref)
+	}
+	subnet, err := Parse(s[:i])
+	if err != nil || subnet.Kind != KindSubnetworks {
+		return nil, "", fmt.Errorf("invalid secondary range reference %q: not in a subnetwork", ref)
+	}
+	return subnet, name, nil
+}
+
+// String returns the canonical relative path of the resource, or its bare
+// name if the project is not known.
+func (r *ResourceID) String() string {
+	if r.Project == "" || r.Kind == "" {
+		return r.Name
+	}
+	switch {
+	case r.Region != "":
+		return fmt.Sprintf("projects/%s/regions/%s/%s/%s", r.Project, r.Region, r.Kind, r.Name)
+	case r.Zone != "":
+		return fmt.Sprintf("projects/%s/zones/%s/%s/%s", r.Project, r.Zone, r.Kind, r.Name)
+	}
+	return fmt.Sprintf("projects/%s/global/%s/%s", r.Project, r.Kind, r.Name)
+}
+
+// Defaults holds the scope used to qualify partial references, typically the
+// project and region of the cluster.
+type Defaults struct {
+	Project string
+	Region  string
+}
+
+// Qualified returns a copy of r with the unknown project and, for
+// subnetworks, the unknown region filled in from defaults.
+func (r *ResourceID) Qualified(kind string, defaults Defaults) *ResourceID {
+	q := *r
+	if q.Kind == "" {
+		q.Kind = kind
+	}
+	if q.Project == "" {
+		q.Project = defaults.Project
+	}
+	if q.Kind == KindSubnetworks && q.Region == "" {
+		q.Region = defaults.Region
+	}
+	return &q
+}
+
+// Matches reports whether references a and b point at the same resource of
+// the given kind. Partial references are qualified with defaults first;
+// fields still unknown on either side after that are not compared. Unparsable
+// references never match.
+func Matches(a, b, kind string, defaults Defaults) bool {
+	idA, err := Parse(a)
+	if err != nil {
+		return false
+	}
+	idB, err := Parse(b)
+	if err != nil {
+		return false
+	}
+	idA, idB = idA.Qualified(kind, defaults), idB.Qualified(kind, defaults)
+	return idA.Name == idB.Name &&
+		fieldMatches(idA.Kind, idB.Kind) &&
+		fieldMatches(idA.Project, idB.Project) &&
+		fieldMatches(idA.Region, idB.Region) &&
+		fieldMatches(idA.Zone, idB.Zone)
+}
+
+func fieldMatches(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpurl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		ref       string
+		want      *ResourceID
+		wantStr   string
+		expectErr bool
+	}{
+		{
+			ref:     "https://www.googleapis.com/compute/v1/projects/p1/global/networks/default",
+			want:    &ResourceID{Project: "p1", Kind: KindNetworks, Name: "default"},
+			wantStr: "projects/p1/global/networks/default",
+		},
+		{
+			ref:     "https://www.googleapis.com/compute/beta/projects/p1/regions/us-central1/subnetworks/red",
+			want:    &ResourceID{Project: "p1", Region: "us-central1", Kind: KindSubnetworks, Name: "red"},
+			wantStr: "projects/p1/regions/us-central1/subnetworks/red",
+		},
+		{
+			ref:     "projects/p1/zones/us-central1-a/instances/vm-1",
+			want:    &ResourceID{Project: "p1", Zone: "us-central1-a", Kind: KindInstances, Name: "vm-1"},
+			wantStr: "projects/p1/zones/us-central1-a/instances/vm-1",
+		},
+		{
+			ref:     "/projects/p1/regions/us-central1/subnetworks/red/",
+			want:    &ResourceID{Project: "p1", Region: "us-central1", Kind: KindSubnetworks, Name: "red"},
+			wantStr: "projects/p1/regions/us-central1/subnetworks/red",
+		},
+		{
+			ref:     "projects/p1/zones/us-central1-a",
+			want:    &ResourceID{Project: "p1", Kind: KindZones, Name: "us-central1-a"},
+			wantStr: "projects/p1/global/zones/us-central1-a",
+		},
+		{
+			ref:     "zones/us-central1-a/instances/vm-1",
+			want:    &ResourceID{Zone: "us-central1-a", Kind: KindInstances, Name: "vm-1"},
+			wantStr: "vm-1",
+		},
+		{
+			ref:     "global/networks/default",
+			want:    &ResourceID{Kind: KindNetworks, Name: "default"},
+			wantStr: "default",
+		},
+		{
+			ref:     "default",
+			want:    &ResourceID{Name: "default"},
+			wantStr: "default",
+		},
+		{ref: "", expectErr: true},
+		{ref: "networks/default", expectErr: true},
+		{ref: "projects/p1/global/networks", expectErr: true},
+		{ref: "projects/p1/foo/bar/networks/default", expectErr: true},
+		{ref: "https://www.googleapis.com/compute/", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, err := Parse(tc.ref)
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Fatalf("Parse(%q) returned err %v, want error %v", tc.ref, err, tc.expectErr)
+			}
+			if tc.expectErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Parse(%q) returned unexpected ID (-want +got):\n%s", tc.ref, diff)
+			}
+			if got.String() != tc.wantStr {
+				t.Errorf("Parse(%q).String() = %q, want %q", tc.ref, got.String(), tc.wantStr)
+			}
+		})
+	}
+}
+
+func TestParseSecondaryRange(t *testing.T) {
+	testCases := []struct {
+		ref        string
+		wantSubnet *ResourceID
+		wantName   string
+		expectErr  bool
+	}{
+		{
+			ref:      "pods",
+			wantName: "pods",
+		},
+		{
+			ref:        "projects/p1/regions/us-central1/subnetworks/red/secondaryIpRanges/pods",
+			wantSubnet: &ResourceID{Project: "p1", Region: "us-central1", Kind: KindSubnetworks, Name: "red"},
+			wantName:   "pods",
+		},
+		{
+			ref:        "https://www.googleapis.com/compute/v1/projects/p1/regions/us-central1/subnetworks/red/secondaryIpRanges/pods",
+			wantSubnet: &ResourceID{Project: "p1", Region: "us-central1", Kind: KindSubnetworks, Name: "red"},
+			wantName:   "pods",
+		},
+		{ref: "", expectErr: true},
+		{ref: "red/pods", expectErr: true},
+		{ref: "projects/p1/regions/us-central1/subnetworks/red/secondaryIpRanges/", expectErr: true},
+		{ref: "projects/p1/global/networks/red/secondaryIpRanges/pods", expectErr: true},
+		{ref: "projects/p1/regions/us-central1/subnetworks/red/secondaryIpRanges/pods/extra", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			gotSubnet, gotName, err := ParseSecondaryRange(tc.ref)
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Fatalf("ParseSecondaryRange(%q) returned err %v, want error %v", tc.ref, err, tc.expectErr)
+			}
+			if diff := cmp.Diff(tc.wantSubnet, gotSubnet); diff != "" {
+				t.Errorf("ParseSecondaryRange(%q) returned unexpected subnet (-want +got):\n%s", tc.ref, diff)
+			}
+			if gotName != tc.wantName {
+				t.Errorf("ParseSecondaryRange(%q) returned name %q, want %q", tc.ref, gotName, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	defaults := Defaults{Project: "p1", Region: "us-central1"}
+	testCases := []struct {
+		desc     string
+		a, b     string
+		kind     string
+		defaults Defaults
+		want     bool
+	}{
+		{
+			desc: "full URL and relative path",
+			a:    "https://www.googleapis.com/compute/v1/projects/p1/global/networks/red",
+			b:    "projects/p1/global/networks/red",
+			kind: KindNetworks,
+			want: true,
+		},
+		{
+			desc:     "bare name qualified with the default project",
+			a:        "https://www.googleapis.com/compute/v1/projects/p1/global/networks/red",
+			b:        "red",
+			kind:     KindNetworks,
+			defaults: defaults,
+			want:     true,
+		},
+		{
+			desc:     "bare name does not match another project",
+			a:        "https://www.googleapis.com/compute/v1/projects/p2/global/networks/red",
+			b:        "red",
+			kind:     KindNetworks,
+			defaults: defaults,
+			want:     false,
+		},
+		{
+			desc: "same name in different projects",
+			a:    "projects/p1/global/networks/red",
+			b:    "projects/p2/global/networks/red",
+			kind: KindNetworks,
+			want: false,
+		},
+		{
+			desc: "same subnetwork name in different regions",
+			a:    "projects/p1/regions/us-central1/subnetworks/red",
+			b:    "projects/p1/regions/europe-west1/subnetworks/red",
+			kind: KindSubnetworks,
+			want: false,
+		},
+		{
+			desc:     "bare subnetwork qualified with the default region",
+			a:        "projects/p1/regions/europe-west1/subnetworks/red",
+			b:        "red",
+			kind:     KindSubnetworks,
+			defaults: defaults,
+			want:     false,
+		},
+		{
+			desc: "bare names without defaults",
+			a:    "projects/p2/regions/us-central1/subnetworks/red",
+			b:    "red",
+			kind: KindSubnetworks,
+			want: true,
+		},
+		{
+			desc: "different kinds",
+			a:    "projects/p1/global/networks/red",
+			b:    "projects/p1/regions/us-central1/subnetworks/red",
+			kind: KindNetworks,
+			want: false,
+		},
+		{
+			desc: "unparsable reference",
+			a:    "networks/red",
+			b:    "red",
+			kind: KindNetworks,
+			want: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := Matches(tc.a, tc.b, tc.kind, tc.defaults); got != tc.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
@@ -34,8 +34,9 @@ const (
 )
 
 type apiCallMetrics struct {
-	latency *metrics.HistogramVec
-	errors  *metrics.CounterVec
+	latency   *metrics.HistogramVec
+	errors    *metrics.CounterVec
+	throttled *metrics.CounterVec
 }
 
 var (
@@ -64,7 +65,12 @@ func (mc *metricContext) Observe(err error) error {
 	apiMetrics.latency.WithLabelValues(mc.attributes...).Observe(
 		time.Since(mc.start).Seconds())
 	if err != nil {
-		apiMetrics.errors.WithLabelValues(mc.attributes...).Inc()
+		// Throttled calls are counted separately from the other errors.
+		if reason := throttleReasonOfError(err); reason != "" {
+			apiMetrics.throttled.WithLabelValues(append(mc.attributes, reason)...).Inc()
+		} else {
+			apiMetrics.errors.WithLabelValues(mc.attributes...).Inc()
+		}
 	}
 
 	return err
@@ -102,10 +108,19 @@ func registerAPIMetrics() *apiCallMetrics {
 			},
 			metricLabels,
 		),
+		throttled: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name:           "cloudprovider_gce_api_request_throttled",
+				Help:           "Number of API calls rejected because of rate limits or quotas",
+				StabilityLevel: metrics.ALPHA,
+			},
+			append(metricLabels, "reason"),
+		),
 	}
 
 	legacyregistry.MustRegister(metrics.latency)
 	legacyregistry.MustRegister(metrics.errors)
+	legacyregistry.MustRegister(metrics.throttled)
 
 	return metrics
 }
@@ -0,0 +1,200 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+type controllerKey struct{}
+
+// WithController returns a context whose compute API calls are attributed to
+// the named controller in the per-method compute API metrics.
+func WithController(ctx context.Context, controller string) context.Context {
+	return context.WithValue(ctx, controllerKey{}, controller)
+}
+
+// controllerFromContext returns the controller set by WithController.
+func controllerFromContext(ctx context.Context) string {
+	if controller, ok := ctx.Value(controllerKey{}).(string); ok && controller != "" {
+		return controller
+	}
+	return unusedMetricLabel
+}
+
+// controllerTransport attributes the compute API calls made through it to a
+// controller, unless their context already names one, see Cloud.ForController.
+type controllerTransport struct {
+	base       http.RoundTripper
+	controller string
+}
+
+func (t *controllerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Value(controllerKey{}).(string); !ok {
+		req = req.WithContext(WithController(req.Context(), t.controller))
+	}
+	return t.base.RoundTrip(req)
+}
+
+var (
+	computeMethodLabels = []string{
+		"method",     // Compute API method, e.g. instances.get.
+		"version",    // API version.
+		"controller", // Controller set with WithController or Cloud.ForController (optional).
+	}
+
+	computeCallMetrics = registerComputeCallMetrics()
+)
+
+type computeCallMetricsVec struct {
+	calls     *metrics.CounterVec
+	latency   *metrics.HistogramVec
+	throttled *metrics.CounterVec
+}
+
+func registerComputeCallMetrics() *computeCallMetricsVec {
+	m := &computeCallMetricsVec{
+		calls: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name:           "cloudprovider_gce_compute_api_calls_total",
+				Help:           "Number of compute API calls by method and controller",
+				StabilityLevel: metrics.ALPHA,
+			},
+			computeMethodLabels,
+		),
+		latency: metrics.NewHistogramVec(
+			&metrics.HistogramOpts{
+				Name:           "cloudprovider_gce_compute_api_call_duration_seconds",
+				Help:           "Latency of compute API calls by method and controller",
+				StabilityLevel: metrics.ALPHA,
+			},
+			computeMethodLabels,
+		),
+		throttled: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name:           "cloudprovider_gce_compute_api_throttled_total",
+				Help:           "Number of compute API calls rejected because of rate limits or quotas, by method, controller and reason",
+				StabilityLevel: metrics.ALPHA,
+			},
+			append(computeMethodLabels, "reason"),
+		),
+	}
+	legacyregistry.MustRegister(m.calls)
+	legacyregistry.MustRegister(m.latency)
+	legacyregistry.MustRegister(m.throttled)
+	return m
+}
+
+// computeMetricsTransport records the compute API calls made through it. Unlike
+// metricContext, it observes every call of the compute services, including
+// the ones made by the generated cloud library and operation polling.
+type computeMetricsTransport struct {
+	base http.RoundTripper
+}
+
+func newComputeMetricsTransport(base http.RoundTripper) http.RoundTripper {
+	return &computeMetricsTransport{base: base}
+}
+
+func (t *computeMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	method, version := computeMethod(req.Method, req.URL.Path)
+	labels := []string{method, version, controllerFromContext(req.Context())}
+	computeCallMetrics.calls.WithLabelValues(labels...).Inc()
+	computeCallMetrics.latency.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// computeMethod returns the compute API method, e.g. instances.get, and the API
+// version of a request from its HTTP method and path, e.g.
+// /compute/v1/projects/p/zones/z/instances/i.
+func computeMethod(httpMethod, path string) (string, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	projects := -1
+	for i, s := range segments {
+		if s == "projects" {
+			projects = i
+			break
+		}
+	}
+	if projects < 1 || projects+1 >= len(segments) {
+		return unusedMetricLabel, unusedMetricLabel
+	}
+	version := segments[projects-1]
+	rest := segments[projects+2:]
+	if len(rest) == 0 {
+		return "projects." + standardMethod(httpMethod, true), version
+	}
+	switch rest[0] {
+	case "aggregated":
+		if len(rest) < 2 {
+			return unusedMetricLabel, version
+		}
+		return rest[1] + ".aggregatedList", version
+	case "global":
+		rest = rest[1:]
+	case "regions", "zones":
+		if len(rest) <= 2 {
+			return rest[0] + "." + standardMethod(httpMethod, len(rest) == 2), version
+		}
+		rest = rest[2:]
+	default:
+		// Project level methods, e.g. projects/p/setCommonInstanceMetadata.
+		return "projects." + rest[0], version
+	}
+	switch len(rest) {
+	case 0:
+		return unusedMetricLabel, version
+	case 1:
+		return rest[0] + "." + standardMethod(httpMethod, false), version
+	case 2:
+		return rest[0] + "." + standardMethod(httpMethod, true), version
+	default:
+		return rest[0] + "." + rest[len(rest)-1], version
+	}
+}
+
+// standardMethod returns the name of the standard method of a resource
+// collection, or of a single resource if named is true.
+func standardMethod(httpMethod string, named bool) string {
+	switch httpMethod {
+	case http.MethodGet:
+		if named {
+			return "get"
+		}
+		return "list"
+	case http.MethodPost:
+		return "insert"
+	case http.MethodDelete:
+		return "delete"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodPut:
+		return "update"
+	}
+	return strings.ToLower(httpMethod)
+}
@@ -0,0 +1,198 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestComputeMethod(t *testing.T) {
+	for _, tc := range []struct {
+		httpMethod  string
+		path        string
+		wantMethod  string
+		wantVersion string
+	}{
+		{http.MethodGet, "/compute/v1/projects/p/zones/z/instances/i", "instances.get", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p/zones/z/instances", "instances.list", "v1"},
+		{http.MethodPost, "/compute/beta/projects/p/zones/z/instances", "instances.insert", "beta"},
+		{http.MethodPost, "/compute/beta/projects/p/zones/z/instances/i/updateNetworkInterface", "instances.updateNetworkInterface", "beta"},
+		{http.MethodGet, "/compute/v1/projects/p/regions/r/addresses", "addresses.list", "v1"},
+		{http.MethodDelete, "/compute/v1/projects/p/regions/r/addresses/a", "addresses.delete", "v1"},
+		{http.MethodPost, "/compute/v1/projects/p/global/routes", "routes.insert", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p/global/operations/op", "operations.get", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p/aggregated/addresses", "addresses.aggregatedList", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p/regions/r", "regions.get", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p/zones", "zones.list", "v1"},
+		{http.MethodGet, "/compute/v1/projects/p", "projects.get", "v1"},
+		{http.MethodPost, "/compute/v1/projects/p/setCommonInstanceMetadata", "projects.setCommonInstanceMetadata", "v1"},
+		{http.MethodGet, "/healthz", unusedMetricLabel, unusedMetricLabel},
+	} {
+		method, version := computeMethod(tc.httpMethod, tc.path)
+		if method != tc.wantMethod || version != tc.wantVersion {
+			t.Errorf("computeMethod(%s, %s) = (%s, %s), want (%s, %s)", tc.httpMethod, tc.path, method, version, tc.wantMethod, tc.wantVersion)
+		}
+	}
+}
+
+func TestComputeMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: newComputeMetricsTransport(http.DefaultTransport)}
+	calls := computeCallMetrics.calls.WithLabelValues("routes.insert", "v1", "route")
+	before, _ := testutil.GetCounterMetricValue(calls)
+
+	req, err := http.NewRequestWithContext(WithController(context.Background(), "route"), http.MethodPost, server.URL+"/compute/v1/projects/p/global/routes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if after, _ := testutil.GetCounterMetricValue(calls); after-before != 1 {
+		t.Errorf("routes.insert calls of the route controller increased by %v, want 1", after-before)
+	}
+}
+
+func TestForController(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	g := &Cloud{
+		cloudState:    &cloudState{},
+		computeClient: &http.Client{Transport: newComputeMetricsTransport(http.DefaultTransport)},
+		service:       &compute.Service{BasePath: server.URL + "/compute/v1/"},
+		serviceBeta:   &computebeta.Service{BasePath: server.URL + "/compute/beta/"},
+		serviceAlpha:  &computealpha.Service{BasePath: server.URL + "/compute/alpha/"},
+	}
+	c, err := g.ForController("route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.cloudState != g.cloudState {
+		t.Errorf("ForController() did not share the state of the cloud")
+	}
+	calls := computeCallMetrics.calls.WithLabelValues("routes.get", "v1", "route")
+	before, _ := testutil.GetCounterMetricValue(calls)
+
+	if _, err := c.service.Routes.Get("p", "r").Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	if after, _ := testutil.GetCounterMetricValue(calls); after-before != 1 {
+		t.Errorf("routes.get calls of the route controller increased by %v, want 1", after-before)
+	}
+}
+
+// testClientBuilder builds the clients of the API server at config.
+type testClientBuilder struct {
+	config *restclient.Config
+}
+
+func (b testClientBuilder) Config(name string) (*restclient.Config, error) {
+	return restclient.CopyConfig(b.config), nil
+}
+
+func (b testClientBuilder) ConfigOrDie(name string) *restclient.Config {
+	return restclient.CopyConfig(b.config)
+}
+
+func (b testClientBuilder) Client(name string) (clientset.Interface, error) {
+	return clientset.NewForConfig(b.config)
+}
+
+func (b testClientBuilder) ClientOrDie(name string) clientset.Interface {
+	return clientset.NewForConfigOrDie(b.config)
+}
+
+func TestForControllerBeforeInitialize(t *testing.T) {
+	// The API server holds the cluster ID config map, and accepts the events.
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("watch") == "true":
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/"+UIDNamespace+"/configmaps":
+			fmt.Fprintf(w, `{"kind":"ConfigMapList","apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[`+
+				`{"metadata":{"name":%q,"namespace":%q,"resourceVersion":"1"},"data":{%q:"cluster-uid"}}]}`, UIDConfigMapName, UIDNamespace, UIDCluster)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			io.Copy(w, r.Body)
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	g, err := fakeGCECloud(DefaultTestClusterValues())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.computeClient = &http.Client{Transport: http.DefaultTransport}
+	g.serviceBeta, g.serviceAlpha = &computebeta.Service{}, &computealpha.Service{}
+	// The controller manager creates the Clouds of the controllers before it
+	// initializes the cloud.
+	c, err := g.ForController("service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == g {
+		t.Fatalf("ForController() returned the cloud itself")
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	g.Initialize(testClientBuilder{config: &restclient.Config{Host: server.URL}}, stop)
+	defer g.eventBroadcaster.Shutdown()
+
+	if c.client == nil || c.eventRecorder == nil {
+		t.Errorf("the controller cloud did not get the client and event recorder of the initialized cloud")
+	}
+	var id string
+	if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		id, err = c.ClusterID.GetID()
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("ClusterID.GetID() of the controller cloud returned err %v", err)
+	}
+	if id != "cluster-uid" {
+		t.Errorf("ClusterID.GetID() of the controller cloud = %q, want %q", id, "cluster-uid")
+	}
+}
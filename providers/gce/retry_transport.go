@@ -0,0 +1,204 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Reasons of the errors returned by the compute API when throttling calls.
+	rateLimitExceededReason     = "rateLimitExceeded"
+	userRateLimitExceededReason = "userRateLimitExceeded"
+	quotaExceededReason         = "quotaExceeded"
+
+	// computeMaxRetries is the number of times a throttled call is retried.
+	computeMaxRetries = 4
+	// computeInitialBackoff is the delay before the first retry, doubled on
+	// every retry up to computeMaxBackoff.
+	computeInitialBackoff = time.Second
+	computeMaxBackoff     = 30 * time.Second
+)
+
+// throttleReason returns the reason of the error if the compute API throttled
+// the call, or "" otherwise.
+func throttleReason(code int, reasons []string) string {
+	if code != http.StatusForbidden && code != http.StatusTooManyRequests {
+		return ""
+	}
+	for _, reason := range reasons {
+		switch reason {
+		case rateLimitExceededReason, userRateLimitExceededReason:
+			return rateLimitExceededReason
+		case quotaExceededReason:
+			return quotaExceededReason
+		}
+	}
+	if code == http.StatusTooManyRequests {
+		return rateLimitExceededReason
+	}
+	return ""
+}
+
+// throttleReasonOfError returns the reason of the error if it is a throttling
+// error of the compute API, or "" otherwise.
+func throttleReasonOfError(err error) string {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
+		return ""
+	}
+	var reasons []string
+	for _, item := range apiErr.Errors {
+		reasons = append(reasons, item.Reason)
+	}
+	return throttleReason(apiErr.Code, reasons)
+}
+
+// computeRetryTransport retries the compute API calls rejected because of
+// rate limits or quotas, after the delay requested by the Retry-After header
+// of the response or an exponential backoff, whichever is longer. Rate limited
+// calls are always retried; calls exceeding a quota are only retried if the
+// response has a Retry-After header, as resource quotas are not expected to
+// free up quickly.
+type computeRetryTransport struct {
+	base           http.RoundTripper
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newComputeRetryTransport(base http.RoundTripper) http.RoundTripper {
+	return &computeRetryTransport{
+		base:           base,
+		maxRetries:     computeMaxRetries,
+		initialBackoff: computeInitialBackoff,
+		maxBackoff:     computeMaxBackoff,
+	}
+}
+
+func (t *computeRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.initialBackoff
+	for retry := 0; ; retry++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		reason := responseThrottleReason(resp)
+		if reason == "" {
+			return resp, nil
+		}
+		method, version := computeMethod(req.Method, req.URL.Path)
+		computeCallMetrics.throttled.WithLabelValues(method, version, controllerFromContext(req.Context()), reason).Inc()
+
+		retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if retry >= t.maxRetries || (reason == quotaExceededReason && !hasRetryAfter) || retryAfter > t.maxBackoff {
+			return resp, nil
+		}
+		// Only requests whose body can be sent again are retried.
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, nil
+		}
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		klog.V(2).Infof("Compute API call %s was throttled (%s), retrying in %v", method, reason, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return resp, nil
+		case <-timer.C:
+		}
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		backoff *= 2
+		if backoff > t.maxBackoff {
+			backoff = t.maxBackoff
+		}
+	}
+}
+
+// responseThrottleReason returns the reason of the error of the response if
+// the call was throttled, or "" otherwise. The body of the response is
+// restored after being read.
+func responseThrottleReason(resp *http.Response) string {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return ""
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var errResp struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	var reasons []string
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		for _, item := range errResp.Error.Errors {
+			reasons = append(reasons, item.Reason)
+		}
+	}
+	return throttleReason(resp.StatusCode, reasons)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
@@ -0,0 +1,176 @@
+//go:build !providerless
+// +build !providerless
+
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func throttledBody(reason string) string {
+	return `{"error": {"code": 403, "message": "throttled", "errors": [{"reason": "` + reason + `"}]}}`
+}
+
+func TestComputeRetryTransport(t *testing.T) {
+	type response struct {
+		code       int
+		body       string
+		retryAfter string
+	}
+	for _, tc := range []struct {
+		desc      string
+		responses []response
+		wantCalls int
+		wantCode  int
+	}{
+		{
+			desc:      "success",
+			responses: []response{{code: http.StatusOK}},
+			wantCalls: 1,
+			wantCode:  http.StatusOK,
+		},
+		{
+			desc:      "rate limited then success",
+			responses: []response{{code: http.StatusForbidden, body: throttledBody("rateLimitExceeded")}, {code: http.StatusForbidden, body: throttledBody("userRateLimitExceeded")}, {code: http.StatusOK}},
+			wantCalls: 3,
+			wantCode:  http.StatusOK,
+		},
+		{
+			desc:      "too many requests until the retries are exhausted",
+			responses: []response{{code: http.StatusTooManyRequests}},
+			wantCalls: 3,
+			wantCode:  http.StatusTooManyRequests,
+		},
+		{
+			desc:      "quota exceeded is not retried",
+			responses: []response{{code: http.StatusForbidden, body: throttledBody("quotaExceeded")}},
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			desc:      "quota exceeded with Retry-After",
+			responses: []response{{code: http.StatusForbidden, body: throttledBody("quotaExceeded"), retryAfter: "0"}, {code: http.StatusOK}},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			desc:      "Retry-After longer than the maximum backoff",
+			responses: []response{{code: http.StatusForbidden, body: throttledBody("rateLimitExceeded"), retryAfter: "3600"}},
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			desc:      "other forbidden errors are not retried",
+			responses: []response{{code: http.StatusForbidden, body: throttledBody("forbidden")}},
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != "route" {
+					t.Errorf("got request body %q, want it to be sent again on retries", body)
+				}
+				resp := tc.responses[len(tc.responses)-1]
+				if calls < len(tc.responses) {
+					resp = tc.responses[calls]
+				}
+				calls++
+				if resp.retryAfter != "" {
+					w.Header().Set("Retry-After", resp.retryAfter)
+				}
+				w.WriteHeader(resp.code)
+				w.Write([]byte(resp.body))
+			}))
+			defer server.Close()
+			client := &http.Client{Transport: &computeRetryTransport{
+				base:           http.DefaultTransport,
+				maxRetries:     2,
+				initialBackoff: time.Millisecond,
+				maxBackoff:     10 * time.Millisecond,
+			}}
+
+			resp, err := client.Post(server.URL+"/compute/v1/projects/p/global/routes", "text/plain", strings.NewReader("route"))
+			if err != nil {
+				t.Fatalf("Post() returned err %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.wantCode)
+			}
+			if resp.StatusCode == http.StatusForbidden && string(body) != tc.responses[len(tc.responses)-1].body {
+				t.Errorf("got body %q, want the body of the last response", body)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value     string
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{value: ""},
+		{value: "10", wantDelay: 10 * time.Second, wantOK: true},
+		{value: "-1"},
+		{value: "Wed, 01 Mar 2023 12:00:30 GMT", wantDelay: 30 * time.Second, wantOK: true},
+		{value: "Wed, 01 Mar 2023 11:00:00 GMT", wantOK: true},
+		{value: "soon"},
+	} {
+		delay, ok := parseRetryAfter(tc.value, now)
+		if delay != tc.wantDelay || ok != tc.wantOK {
+			t.Errorf("parseRetryAfter(%q) = (%v, %v), want (%v, %v)", tc.value, delay, ok, tc.wantDelay, tc.wantOK)
+		}
+	}
+}
+
+func TestObserveThrottledError(t *testing.T) {
+	labels := []string{"foo_throttled", "us-central1", unusedMetricLabel, computeV1Version}
+	throttled := apiMetrics.throttled.WithLabelValues(append(labels, rateLimitExceededReason)...)
+	errors := apiMetrics.errors.WithLabelValues(labels...)
+	throttledBefore, _ := testutil.GetCounterMetricValue(throttled)
+	errorsBefore, _ := testutil.GetCounterMetricValue(errors)
+
+	mc := newGenericMetricContext("foo", "throttled", "us-central1", "", computeV1Version)
+	mc.Observe(&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: rateLimitExceededReason}}})
+	mc.Observe(&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}})
+
+	if after, _ := testutil.GetCounterMetricValue(throttled); after-throttledBefore != 1 {
+		t.Errorf("throttled errors increased by %v, want 1", after-throttledBefore)
+	}
+	if after, _ := testutil.GetCounterMetricValue(errors); after-errorsBefore != 1 {
+		t.Errorf("errors increased by %v, want 1", after-errorsBefore)
+	}
+}
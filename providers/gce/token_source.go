@@ -27,7 +27,6 @@ import (
 	"time"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
 
 	"k8s.io/client-go/util/flowcontrol"
@@ -119,7 +118,7 @@ func (a *AltTokenSource) token() (*oauth2.Token, error) {
 
 // NewAltTokenSource constructs a new alternate token source for generating tokens.
 func NewAltTokenSource(tokenURL, tokenBody string) oauth2.TokenSource {
-	client := oauth2.NewClient(context.Background(), google.ComputeTokenSource(""))
+	client := oauth2.NewClient(context.Background(), MetadataTokenSource())
 	a := &AltTokenSource{
 		oauthClient: client,
 		tokenURL:    tokenURL,